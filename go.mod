@@ -3,6 +3,10 @@ module golang-installer
 go 1.24.2
 
 require (
-	github.com/gopherjs/gopherjs v1.17.2 // indirect
-	github.com/therecipe/qt v0.0.0-20200904063919-c0c124a5770d // indirect
+	github.com/nwaples/rardecode v1.1.3
+	github.com/therecipe/qt v0.0.0-20200904063919-c0c124a5770d
+	golang.org/x/net v0.19.0
+	golang.org/x/text v0.20.0
 )
+
+require github.com/gopherjs/gopherjs v1.17.2 // indirect
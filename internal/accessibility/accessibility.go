@@ -0,0 +1,91 @@
+// Package accessibility озвучивает ход долгих операций для экранного диктора
+// (Orca). Индикатор прогресса меняется молча, и игрок с диктором всю установку
+// слышит тишину. Tracker следит за индикатором: при смене этапа и на каждых
+// Step процентах он меняет доступное имя индикатора и посылает событие Alert,
+// которое диктор зачитывает, даже если фокус на другом элементе окна.
+//
+// Как и индикатор, Tracker можно вызывать из обработчика установки. Методы
+// можно вызывать у nil, тогда они ничего не делают.
+package accessibility
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/therecipe/qt/gui"
+	"github.com/therecipe/qt/widgets"
+)
+
+// Step — через сколько процентов озвучивается прогресс
+const Step = 10
+
+// Tracker озвучивает этапы и прогресс одного индикатора
+type Tracker struct {
+	bar *widgets.QProgressBar
+
+	mu        sync.Mutex
+	phase     string
+	milestone int
+}
+
+// Track начинает озвучивать индикатор bar
+func Track(bar *widgets.QProgressBar) *Tracker {
+	t := &Tracker{bar: bar}
+	bar.ConnectValueChanged(t.progress)
+	return t
+}
+
+// Phase сообщает о начале этапа, например «Распаковка». Рубежи прогресса после
+// этого озвучиваются вместе с названием этапа.
+func (t *Tracker) Phase(name string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.phase = name
+	t.milestone = t.current()
+	t.mu.Unlock()
+	t.Say(name)
+}
+
+// Say зачитывает сообщение о ходе операции: ожидание, итог, ошибку
+func (t *Tracker) Say(text string) {
+	if t == nil {
+		return
+	}
+	t.bar.SetAccessibleName(text)
+	if gui.QAccessible_IsActive() {
+		gui.QAccessible_UpdateAccessibility2(gui.NewQAccessibleEvent2(t.bar, gui.QAccessible__Alert))
+	}
+}
+
+// progress озвучивает пройденный рубеж. Индикатор нового этапа начинает с
+// нуля, и спад до меньшего рубежа не озвучивается.
+func (t *Tracker) progress(int) {
+	t.mu.Lock()
+	milestone := t.current()
+	if milestone == t.milestone {
+		t.mu.Unlock()
+		return
+	}
+	passed := milestone > t.milestone && milestone > 0
+	t.milestone = milestone
+	text := fmt.Sprintf("%d%%", milestone)
+	if t.phase != "" {
+		text = t.phase + ": " + text
+	}
+	t.mu.Unlock()
+	if passed {
+		t.Say(text)
+	}
+}
+
+// current — пройденный рубеж индикатора; -1, если прогресс неизвестен.
+// Вызывается под t.mu.
+func (t *Tracker) current() int {
+	span := t.bar.Maximum() - t.bar.Minimum()
+	if span <= 0 {
+		return -1
+	}
+	return (t.bar.Value() - t.bar.Minimum()) * 100 / span / Step * Step
+}
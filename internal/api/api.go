@@ -0,0 +1,294 @@
+// Package api — REST API менеджера игр для домашних панелей управления и средств
+// удаленного администрирования. Сервер слушает только локальный адрес, каждый запрос
+// должен нести токен из файла ~/.config/go-qt-installer/api-token.
+//
+//	GET  /api/v1/games                    — установленные игры
+//	POST /api/v1/games/{slug}/uninstall   — удалить игру (?trash=1 — в корзину)
+//	POST /api/v1/install                  — запустить установщик {"installer": "...", "preseed": "..."}
+//	GET  /api/v1/events                   — ход операций, поток Server-Sent Events
+package api
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultAddr — адрес сервера, если при запуске указан только --api
+const DefaultAddr = "127.0.0.1:47800"
+
+// ErrNotFound возвращает Backend, если игры с таким идентификатором нет
+var ErrNotFound = errors.New("игра не найдена")
+
+// Game — установленная игра в ответе API
+type Game struct {
+	Slug        string    `json:"slug"`
+	Name        string    `json:"name"`
+	Version     string    `json:"version,omitempty"`
+	InstallPath string    `json:"install_path"`
+	InstallDate time.Time `json:"install_date"`
+	Offline     bool      `json:"offline,omitempty"` // Игра на отключенном диске
+	InstallID   string    `json:"install_id,omitempty"`
+}
+
+// Event — событие операции, запущенной через API или в окне менеджера
+type Event struct {
+	Type   string `json:"type"`   // started, progress, finished или failed
+	Action string `json:"action"` // install или uninstall
+	Game   string `json:"game"`
+	Value  int    `json:"value,omitempty"` // Прогресс: выполнено шагов из Max
+	Max    int    `json:"max,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// ErrorCode — класс ошибки установки по коду выхода установщика: config,
+	// space, download, extraction, permissions, cancelled или other
+	ErrorCode string `json:"error_code,omitempty"`
+}
+
+// Backend выполняет команды API. Uninstall и Install только ставят операцию в очередь,
+// результат приходит событиями.
+type Backend interface {
+	Games() []Game
+	Uninstall(slug string, toTrash bool) error
+	Install(installer, preseed string) error
+}
+
+// Server — запущенный сервер API
+type Server struct {
+	backend Backend
+	token   string
+
+	mu          sync.Mutex
+	subscribers map[chan Event]bool
+}
+
+// TokenPath возвращает файл с токеном доступа
+func TokenPath() string {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(os.Getenv("HOME"), ".config")
+	}
+	return filepath.Join(configHome, "go-qt-installer", "api-token")
+}
+
+// loadToken читает токен и создает его при первом запуске. Файл доступен только
+// владельцу: кто может его прочитать, тот может управлять играми. Чужой файл
+// или ссылка отвергаются, а файл, который могли прочитать другие, заменяется
+// новым токеном.
+func loadToken() (string, error) {
+	path := TokenPath()
+	f, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+	switch {
+	case err == nil:
+		token, err := readToken(f)
+		f.Close()
+		if err != nil {
+			return "", fmt.Errorf("файл токена API %s: %v", path, err)
+		}
+		if token != "" {
+			return token, nil
+		}
+	case !os.IsNotExist(err):
+		return "", fmt.Errorf("не удалось прочитать токен API %s: %v", path, err)
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("не удалось сгенерировать токен API: %v", err)
+	}
+	token := hex.EncodeToString(buf)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	// Новый файл создается с правами 0600 и заменяет старый переименованием,
+	// поэтому права старого файла на него не переходят
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".api-token-*")
+	if err != nil {
+		return "", fmt.Errorf("не удалось сохранить токен API: %v", err)
+	}
+	if _, err := tmp.WriteString(token + "\n"); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("не удалось сохранить токен API: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("не удалось сохранить токен API: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("не удалось сохранить токен API: %v", err)
+	}
+	return token, nil
+}
+
+// readToken читает токен из открытого файла. Файл другого владельца — ошибка;
+// для файла, доступного группе или всем, возвращается пустой токен, чтобы
+// вместо него был создан новый.
+func readToken(f *os.File) (string, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("это не обычный файл")
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok && int(st.Uid) != os.Getuid() {
+		return "", fmt.Errorf("файл принадлежит другому пользователю (uid %d)", st.Uid)
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return "", nil
+	}
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Start запускает сервер на addr. Адрес должен быть локальным: API управляет
+// файлами пользователя и не предназначен для доступа по сети.
+func Start(addr string, backend Backend) (*Server, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("неверный адрес API %s: %v", addr, err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("API может слушать только локальный адрес, а не %s", host)
+	}
+	token, err := loadToken()
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось запустить API на %s: %v", addr, err)
+	}
+
+	s := &Server{backend: backend, token: token, subscribers: make(map[chan Event]bool)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/games", s.games)
+	mux.HandleFunc("POST /api/v1/games/{slug}/uninstall", s.uninstall)
+	mux.HandleFunc("POST /api/v1/install", s.install)
+	mux.HandleFunc("GET /api/v1/events", s.events)
+	go http.Serve(listener, s.authorize(mux))
+	return s, nil
+}
+
+// Publish отправляет событие всем подписчикам. Медленный подписчик пропускает
+// события, но не задерживает менеджер.
+func (s *Server) Publish(e Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subscribers {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// authorize пропускает запросы с токеном в заголовке Authorization: Bearer.
+// Параметр token принимается только для GET /api/v1/events: EventSource в браузере
+// не умеет добавлять заголовки, а ссылка с токеном на команду могла бы попасть
+// в историю браузера или журнал прокси.
+func (s *Server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok && r.Method == http.MethodGet && r.URL.Path == "/api/v1/events" {
+			token = r.URL.Query().Get("token")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "неверный токен")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) games(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.backend.Games())
+}
+
+func (s *Server) uninstall(w http.ResponseWriter, r *http.Request) {
+	toTrash := r.URL.Query().Get("trash") == "1"
+	err := s.backend.Uninstall(r.PathValue("slug"), toTrash)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case err != nil:
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "queued"})
+	}
+}
+
+func (s *Server) install(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Installer string `json:"installer"`
+		Preseed   string `json:"preseed"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Installer == "" {
+		writeError(w, http.StatusBadRequest, "нужен путь к установщику в поле installer")
+		return
+	}
+	if err := s.backend.Install(req.Installer, req.Preseed); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "started"})
+}
+
+func (s *Server) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "поток событий не поддерживается")
+		return
+	}
+	ch := make(chan Event, 64)
+	s.mu.Lock()
+	s.subscribers[ch] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.subscribers, ch)
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case e := <-ch:
+			data, _ := json.Marshal(e)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
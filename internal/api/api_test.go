@@ -0,0 +1,92 @@
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadToken(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	path := TokenPath()
+
+	token, err := loadToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("новый файл с правами %v, ожидалось 0600", info.Mode().Perm())
+	}
+	if again, err := loadToken(); err != nil || again != token {
+		t.Errorf("повторное чтение: %q, %v; ожидалось %q", again, err, token)
+	}
+
+	// Файл, который могли прочитать другие, заменяется новым токеном
+	if err := os.Chmod(path, 0644); err != nil {
+		t.Fatal(err)
+	}
+	replaced, err := loadToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replaced == token {
+		t.Error("токен из файла, доступного всем, используется дальше")
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("замененный файл с правами %v, ожидалось 0600", info.Mode().Perm())
+	}
+	if data, _ := ioutil.ReadFile(path); strings.TrimSpace(string(data)) != replaced {
+		t.Errorf("в файле %q, ожидалось %q", data, replaced)
+	}
+
+	// Ссылка на другой файл отвергается
+	target := filepath.Join(t.TempDir(), "token")
+	if err := ioutil.WriteFile(target, []byte("planted\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(path)
+	if err := os.Symlink(target, path); err != nil {
+		t.Fatal(err)
+	}
+	if token, err := loadToken(); err == nil {
+		t.Errorf("ссылка принята, токен %q", token)
+	}
+}
+
+func TestAuthorizeQueryToken(t *testing.T) {
+	s := &Server{token: "secret"}
+	h := s.authorize(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, tt := range []struct {
+		method, target, header string
+		want                   int
+	}{
+		{http.MethodGet, "/api/v1/games", "Bearer secret", http.StatusOK},
+		{http.MethodPost, "/api/v1/install", "Bearer secret", http.StatusOK},
+		{http.MethodGet, "/api/v1/games", "Bearer wrong", http.StatusUnauthorized},
+		{http.MethodGet, "/api/v1/events?token=secret", "", http.StatusOK},
+		{http.MethodGet, "/api/v1/events?token=wrong", "", http.StatusUnauthorized},
+		{http.MethodGet, "/api/v1/games?token=secret", "", http.StatusUnauthorized},
+		{http.MethodPost, "/api/v1/install?token=secret", "", http.StatusUnauthorized},
+		{http.MethodPost, "/api/v1/games/celeste/uninstall?token=secret", "", http.StatusUnauthorized},
+		{http.MethodPost, "/api/v1/events?token=secret", "", http.StatusUnauthorized},
+	} {
+		r := httptest.NewRequest(tt.method, tt.target, nil)
+		if tt.header != "" {
+			r.Header.Set("Authorization", tt.header)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s %s (%q): %d, ожидалось %d", tt.method, tt.target, tt.header, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+// Package archive описывает общий интерфейс распаковки архивов с играми.
+// Форматы регистрируются по расширению и сигнатуре, поэтому установщик не зависит
+// от конкретного формата.
+package archive
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang-installer/internal/chaos"
+	"golang-installer/internal/membudget"
+)
+
+// Entry — запись архива
+type Entry struct {
+	Name  string      // Путь внутри архива с разделителями "/"
+	Size  int64       // Размер после распаковки
+	Mode  os.FileMode // Права доступа, если формат их хранит
+	IsDir bool
+}
+
+// Extractor перечисляет и распаковывает записи одного архива
+type Extractor interface {
+	// Enumerate возвращает все записи архива в порядке хранения
+	Enumerate() ([]Entry, error)
+	// Extract распаковывает одну запись в файл dst
+	Extract(ctx context.Context, entry Entry, dst string) error
+	// TotalBytes возвращает суммарный размер записей после распаковки
+	TotalBytes() int64
+	Close() error
+}
+
+// Format — зарегистрированный формат архива
+type Format struct {
+	Name       string
+	Extensions []string // Расширения в нижнем регистре, например ".zip" или ".tar.gz"
+	Magic      []byte   // Сигнатура в начале файла, может быть пустой
+	Open       func(path string) (Extractor, error)
+}
+
+var (
+	mu      sync.RWMutex
+	formats []Format
+)
+
+// Register добавляет формат в реестр
+func Register(f Format) {
+	mu.Lock()
+	defer mu.Unlock()
+	formats = append(formats, f)
+	// Более длинные расширения проверяются раньше (".tar.gz" до ".gz")
+	sort.SliceStable(formats, func(i, j int) bool {
+		return longestExt(formats[i]) > longestExt(formats[j])
+	})
+}
+
+func longestExt(f Format) int {
+	n := 0
+	for _, ext := range f.Extensions {
+		if len(ext) > n {
+			n = len(ext)
+		}
+	}
+	return n
+}
+
+// Detect определяет формат файла сначала по сигнатуре, затем по расширению
+func Detect(path string) (Format, error) {
+	header := make([]byte, 512)
+	if f, err := os.Open(path); err == nil {
+		n, _ := io.ReadFull(f, header)
+		header = header[:n]
+		f.Close()
+	} else {
+		return Format{}, err
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, f := range formats {
+		if len(f.Magic) > 0 && bytes.HasPrefix(header, f.Magic) {
+			return f, nil
+		}
+	}
+	name := strings.ToLower(filepath.Base(path))
+	for _, f := range formats {
+		for _, ext := range f.Extensions {
+			if strings.HasSuffix(name, ext) {
+				return f, nil
+			}
+		}
+	}
+	return Format{}, fmt.Errorf("неизвестный формат архива: %s", filepath.Base(path))
+}
+
+// Open открывает архив подходящим зарегистрированным форматом. Директория
+// открывается как архив из ее файлов.
+func Open(path string) (Extractor, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return openDir(path)
+	}
+	f, err := Detect(path)
+	if err != nil {
+		return nil, err
+	}
+	return f.Open(path)
+}
+
+// ctxReader прерывает чтение при отмене контекста
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// copyBufferSize — размер буфера при записи файла, берется из общего бюджета памяти
+const copyBufferSize = 1 << 20
+
+// WriteFile записывает содержимое записи в dst с учетом прав и отмены контекста.
+// Используется реализациями форматов.
+func WriteFile(ctx context.Context, r io.Reader, dst string, mode os.FileMode) error {
+	perm := mode.Perm()
+	if perm == 0 {
+		perm = 0644
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return err
+	}
+	// Ссылка на месте файла заменяется им, а не записывается насквозь
+	if info, err := os.Lstat(dst); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(dst); err != nil {
+			return err
+		}
+	}
+	buf, release, err := membudget.Buffer(ctx, copyBufferSize)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	// Обертка скрывает ReadFrom у файла, иначе io.CopyBuffer выделит свой буфер
+	if _, err := io.CopyBuffer(struct{ io.Writer }{chaos.Writer(out, dst)}, ctxReader{ctx: ctx, r: r}, buf); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// FindHelper ищет внешнюю программу распаковки сначала рядом с установщиком
+// (поставляемую вместе с ним), затем в PATH
+func FindHelper(names ...string) (string, error) {
+	if self, err := os.Executable(); err == nil {
+		// Установщик могут запустить по символьной ссылке, программы лежат рядом с файлом
+		if resolved, err := filepath.EvalSymlinks(self); err == nil {
+			self = resolved
+		}
+		for _, name := range names {
+			path := filepath.Join(filepath.Dir(self), name)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path, nil
+			}
+		}
+	}
+	for _, name := range names {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("не найдена программа для распаковки (%s)", strings.Join(names, ", "))
+}
+
+// moveFile переносит файл, а между разными дисками копирует его
+func moveFile(src, dst string, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err == nil {
+		// chmod ссылки изменил бы права файла, на который она указывает
+		if info, err := os.Lstat(dst); err == nil && info.Mode().IsRegular() {
+			if perm := mode.Perm(); perm != 0 {
+				os.Chmod(dst, perm)
+			}
+		}
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := WriteFile(context.Background(), in, dst, mode); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// parsePerm разбирает права в виде "-rwxr-xr-x". Ссылки ("lrwxrwxrwx")
+// получают os.ModeSymlink.
+func parsePerm(s string) os.FileMode {
+	if len(s) != 10 {
+		return 0
+	}
+	var mode os.FileMode
+	if s[0] == 'l' {
+		mode = os.ModeSymlink
+	}
+	for i, c := range s[1:] {
+		if c != '-' {
+			mode |= 1 << uint(8-i)
+		}
+	}
+	return mode
+}
+
+// StagingRoot — директория для временных файлов, выбранная пользователем.
+// Если не задана, используется системная временная директория.
+var StagingRoot string
+
+// StagingDir возвращает директорию для временных файлов внешних распаковщиков
+func StagingDir() string {
+	if StagingRoot != "" {
+		return StagingRoot
+	}
+	return os.TempDir()
+}
+
+// Stager реализуют распаковщики, которые сначала распаковывают весь архив
+// в StagingDir и только потом раскладывают файлы по местам
+type Stager interface {
+	// StagingBytes возвращает, сколько места архив займет во временной директории
+	StagingBytes() int64
+}
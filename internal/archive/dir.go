@@ -0,0 +1,66 @@
+package archive
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+)
+
+// dirExtractor ставит игру из обычной директории: «распаковка» копирует файлы.
+// Так устанавливаются сборки, полученные rsync без упаковки в архив.
+type dirExtractor struct {
+	root    string
+	entries []Entry
+}
+
+func openDir(root string) (Extractor, error) {
+	d := &dirExtractor{root: root}
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		// Символические ссылки не копируются: они могут вести за пределы сборки
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+		entry := Entry{Name: filepath.ToSlash(rel), Mode: info.Mode(), IsDir: info.IsDir()}
+		if !entry.IsDir {
+			entry.Size = info.Size()
+		}
+		d.entries = append(d.entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *dirExtractor) Enumerate() ([]Entry, error) {
+	return d.entries, nil
+}
+
+func (d *dirExtractor) Extract(ctx context.Context, entry Entry, dst string) error {
+	in, err := os.Open(filepath.Join(d.root, filepath.FromSlash(entry.Name)))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return WriteFile(ctx, in, dst, entry.Mode)
+}
+
+func (d *dirExtractor) TotalBytes() int64 {
+	var total int64
+	for _, e := range d.entries {
+		total += e.Size
+	}
+	return total
+}
+
+func (d *dirExtractor) Close() error {
+	return nil
+}
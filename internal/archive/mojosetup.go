@@ -0,0 +1,162 @@
+package archive
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Установщики GOG и Humble Bundle для Linux — скрипт makeself с MojoSetup, к которому
+// дописан zip с данными. archive/zip сам находит архив в конце файла. Файлы игры
+// лежат в data/noarch/game/ у GOG и в data/ у Humble, остальное — сам MojoSetup.
+func init() {
+	Register(Format{
+		Name:       "mojosetup",
+		Extensions: []string{".sh", ".run"},
+		Open:       openMojoSetup,
+	})
+}
+
+// Metadata — архив, который знает название и версию игры
+type Metadata interface {
+	GameInfo() (name, version string)
+}
+
+// mojoDataRoots — где лежат файлы игры, в порядке проверки
+var mojoDataRoots = []string{"data/noarch/game/", "data/"}
+
+// Описание пакета в scripts/config.lua: description = "Игра", version = "1.0"
+var (
+	luaDescription = regexp.MustCompile(`(?m)^\s*description\s*=\s*"([^"]*)"`)
+	luaVersion     = regexp.MustCompile(`(?m)^\s*version\s*=\s*"([^"]*)"`)
+)
+
+type mojoExtractor struct {
+	r       *zip.ReadCloser
+	files   map[string]*zip.File // По имени без корня данных
+	entries []Entry
+	name    string
+	version string
+}
+
+func openMojoSetup(path string) (Extractor, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s не похож на установщик GOG или Humble Bundle: %v", path, err)
+	}
+
+	root := ""
+	for _, candidate := range mojoDataRoots {
+		for _, f := range r.File {
+			if strings.HasPrefix(f.Name, candidate) {
+				root = candidate
+				break
+			}
+		}
+		if root != "" {
+			break
+		}
+	}
+	if root == "" {
+		r.Close()
+		return nil, fmt.Errorf("в установщике %s не найдены файлы игры", path)
+	}
+
+	z := &mojoExtractor{r: r, files: make(map[string]*zip.File)}
+	for _, f := range r.File {
+		name, ok := strings.CutPrefix(f.Name, root)
+		if !ok || name == "" {
+			continue
+		}
+		z.files[name] = f
+		z.entries = append(z.entries, Entry{
+			Name:  name,
+			Size:  int64(f.UncompressedSize64),
+			Mode:  f.Mode(),
+			IsDir: f.FileInfo().IsDir(),
+		})
+	}
+	z.readInfo()
+	return z, nil
+}
+
+// readInfo читает название и версию: gameinfo у GOG (первые строки — название
+// и версия), scripts/config.lua у Humble
+func (z *mojoExtractor) readInfo() {
+	for _, f := range z.r.File {
+		switch f.Name {
+		case "data/noarch/gameinfo":
+			lines := readLines(f, 2)
+			if len(lines) > 0 {
+				z.name = lines[0]
+			}
+			if len(lines) > 1 {
+				z.version = lines[1]
+			}
+		case "scripts/config.lua":
+			rc, err := f.Open()
+			if err != nil {
+				continue
+			}
+			data, _ := io.ReadAll(io.LimitReader(rc, 1<<20))
+			rc.Close()
+			if m := luaDescription.FindSubmatch(data); m != nil && z.name == "" {
+				z.name = string(m[1])
+			}
+			if m := luaVersion.FindSubmatch(data); m != nil && z.version == "" {
+				z.version = string(m[1])
+			}
+		}
+	}
+}
+
+func readLines(f *zip.File, n int) []string {
+	rc, err := f.Open()
+	if err != nil {
+		return nil
+	}
+	defer rc.Close()
+	var lines []string
+	scanner := bufio.NewScanner(rc)
+	for len(lines) < n && scanner.Scan() {
+		lines = append(lines, strings.TrimSpace(scanner.Text()))
+	}
+	return lines
+}
+
+func (z *mojoExtractor) GameInfo() (string, string) {
+	return z.name, z.version
+}
+
+func (z *mojoExtractor) Enumerate() ([]Entry, error) {
+	return z.entries, nil
+}
+
+func (z *mojoExtractor) Extract(ctx context.Context, entry Entry, dst string) error {
+	f, ok := z.files[entry.Name]
+	if !ok {
+		return fmt.Errorf("запись %s не найдена в установщике", entry.Name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return WriteFile(ctx, rc, dst, f.Mode())
+}
+
+func (z *mojoExtractor) TotalBytes() int64 {
+	var total int64
+	for _, e := range z.entries {
+		total += e.Size
+	}
+	return total
+}
+
+func (z *mojoExtractor) Close() error {
+	return z.r.Close()
+}
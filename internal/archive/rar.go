@@ -0,0 +1,158 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/nwaples/rardecode"
+)
+
+// RAR читается только последовательно, поэтому Extract ожидает записи в порядке
+// Enumerate; при обращении к уже пройденной записи архив открывается заново.
+// Следующие тома (.r00, .part2.rar и т.д.) подключаются библиотекой автоматически.
+func init() {
+	// Тома старого формата (.r00, .r01 ...) тоже начинаются с сигнатуры RAR,
+	// но на случай поврежденного заголовка узнаются и по расширению
+	extensions := []string{".rar"}
+	for i := 0; i < 100; i++ {
+		extensions = append(extensions, fmt.Sprintf(".r%02d", i))
+	}
+	Register(Format{
+		Name:       "rar",
+		Extensions: extensions,
+		Magic:      []byte("Rar!\x1a\x07"),
+		Open:       openRar,
+	})
+}
+
+var (
+	oldVolumeRe = regexp.MustCompile(`(?i)\.r\d\d$`)
+	newVolumeRe = regexp.MustCompile(`(?i)\.part(\d+)\.rar$`)
+)
+
+// isContinuationVolume проверяет, что файл — не первый том многотомного архива
+func isContinuationVolume(path string) bool {
+	name := filepath.Base(path)
+	if oldVolumeRe.MatchString(name) {
+		return true
+	}
+	if m := newVolumeRe.FindStringSubmatch(name); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return n > 1
+	}
+	return false
+}
+
+type rarExtractor struct {
+	path    string
+	entries []Entry
+
+	rc  *rardecode.ReadCloser
+	pos int // Индекс следующей записи, которую вернет rc.Next
+}
+
+func openRar(path string) (Extractor, error) {
+	// Следующие тома распаковываются вместе с первым, отдельно их не обрабатываем
+	if isContinuationVolume(path) {
+		return &rarExtractor{path: path}, nil
+	}
+
+	rc, err := rardecode.OpenReader(path, "")
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть RAR-архив %s: %v", path, err)
+	}
+	defer rc.Close()
+
+	z := &rarExtractor{path: path}
+	for {
+		h, err := rc.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения RAR-архива %s: %v", path, err)
+		}
+		z.entries = append(z.entries, Entry{
+			Name:  h.Name,
+			Size:  h.UnPackedSize,
+			Mode:  h.Mode(),
+			IsDir: h.IsDir,
+		})
+	}
+	return z, nil
+}
+
+// Volumes возвращает файлы всех томов архива
+func (z *rarExtractor) Volumes() []string {
+	if z.rc != nil {
+		return z.rc.Volumes()
+	}
+	return []string{z.path}
+}
+
+func (z *rarExtractor) Enumerate() ([]Entry, error) {
+	return z.entries, nil
+}
+
+func (z *rarExtractor) TotalBytes() int64 {
+	var total int64
+	for _, e := range z.entries {
+		total += e.Size
+	}
+	return total
+}
+
+func (z *rarExtractor) Extract(ctx context.Context, entry Entry, dst string) error {
+	index := -1
+	for i, e := range z.entries {
+		if e.Name == entry.Name {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return fmt.Errorf("запись %s не найдена в архиве", entry.Name)
+	}
+
+	// Поток нельзя перемотать назад — открываем архив заново
+	if z.rc == nil || index < z.pos {
+		if z.rc != nil {
+			z.rc.Close()
+		}
+		rc, err := rardecode.OpenReader(z.path, "")
+		if err != nil {
+			z.rc = nil
+			return err
+		}
+		z.rc, z.pos = rc, 0
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		h, err := z.rc.Next()
+		if err != nil {
+			return fmt.Errorf("ошибка чтения RAR-архива: %v", err)
+		}
+		z.pos++
+		if z.pos-1 == index {
+			if !strings.EqualFold(h.Name, entry.Name) {
+				return fmt.Errorf("неожиданная запись %s вместо %s", h.Name, entry.Name)
+			}
+			return WriteFile(ctx, z.rc, dst, h.Mode())
+		}
+	}
+}
+
+func (z *rarExtractor) Close() error {
+	if z.rc != nil {
+		return z.rc.Close()
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang-installer/internal/runner"
+)
+
+// 7z распаковывается внешней программой 7z/7za/7zz (из p7zip или 7-Zip),
+// которая после -bb1 печатает "- имя" для каждого распаковываемого файла
+func init() {
+	Register(Format{
+		Name:       "7z",
+		Extensions: []string{".7z"},
+		Magic:      []byte{'7', 'z', 0xBC, 0xAF, 0x27, 0x1C},
+		Open:       openSevenZip,
+	})
+}
+
+func openSevenZip(path string) (Extractor, error) {
+	helper, err := FindHelper("7zz", "7z", "7za")
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := runner.Output(listTimeout, helper, "l", "-slt", "-ba", "--", path)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать 7z-архив %s: %v", path, err)
+	}
+
+	command := func(staging string) *exec.Cmd {
+		return exec.Command(helper, "x", "-y", "-bb1", "-bd", "-o"+staging, "--", path)
+	}
+	parseLine := func(line, _ string) (string, bool) {
+		return strings.CutPrefix(line, "- ")
+	}
+	return newStagedExtractor("7z", parseSevenZipList(out), command, parseLine), nil
+}
+
+// parseSevenZipList разбирает технический вывод "7z l -slt": блоки "Ключ = значение",
+// разделенные пустыми строками
+func parseSevenZipList(out []byte) []Entry {
+	var entries []Entry
+	var cur *Entry
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		key, value, ok := strings.Cut(line, " = ")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "Path":
+			entries = append(entries, Entry{Name: filepath.ToSlash(value)})
+			cur = &entries[len(entries)-1]
+		case "Size":
+			if cur != nil {
+				cur.Size, _ = strconv.ParseInt(value, 10, 64)
+			}
+		case "Folder":
+			if cur != nil && value == "+" {
+				cur.IsDir = true
+			}
+		case "Attributes":
+			if cur == nil {
+				continue
+			}
+			// Например "D_ drwxr-xr-x" или "A_ -rwxr-xr-x"
+			fields := strings.Fields(value)
+			if len(fields) > 0 && strings.HasPrefix(fields[0], "D") {
+				cur.IsDir = true
+			}
+			if len(fields) > 1 {
+				cur.Mode = parsePerm(fields[len(fields)-1])
+			}
+		}
+	}
+	return entries
+}
@@ -0,0 +1,56 @@
+package archive
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestParseSevenZipList(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		out  string
+		want []Entry
+	}{
+		{
+			name: "файлы и директория",
+			out: "Path = bin\nSize = 0\nFolder = +\nAttributes = D_ drwxr-xr-x\n\n" +
+				"Path = bin/game\nSize = 12345\nFolder = -\nAttributes = A_ -rwxr-xr-x\n\n",
+			want: []Entry{
+				{Name: "bin", Mode: 0755, IsDir: true},
+				{Name: "bin/game", Size: 12345, Mode: 0755},
+			},
+		},
+		{
+			name: "ссылка",
+			out:  "Path = lib/libgame.so\nSize = 12\nFolder = -\nAttributes = A_ lrwxrwxrwx\n",
+			want: []Entry{{Name: "lib/libgame.so", Size: 12, Mode: os.ModeSymlink | 0777}},
+		},
+		{
+			name: "директория без прав unix",
+			out:  "Path = data\nSize = 0\nFolder = -\nAttributes = D\n",
+			want: []Entry{{Name: "data", IsDir: true}},
+		},
+		{
+			name: "пробелы и знак равенства в имени",
+			out:  "Path = Мои файлы/a = b.txt\nSize = 3\nAttributes = A_ -rw-r--r--\n",
+			want: []Entry{{Name: "Мои файлы/a = b.txt", Size: 3, Mode: 0644}},
+		},
+		{
+			name: "строки без значения и ключи до первой записи",
+			out:  "Size = 10\n----------\nPath = a\nModified = 2024-01-01 00:00:00\n",
+			want: []Entry{{Name: "a"}},
+		},
+		{
+			name: "пустой вывод",
+			out:  "",
+			want: nil,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseSevenZipList([]byte(tt.out)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSevenZipList() = %+v, ожидалось %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"golang-installer/internal/runner"
+)
+
+// squashfs распаковывается программой unsquashfs (squashfs-tools). С флагом -i она
+// печатает путь каждого распакованного файла внутри директории назначения.
+func init() {
+	Register(Format{
+		Name:       "squashfs",
+		Extensions: []string{".squashfs", ".sqfs", ".sfs"},
+		Magic:      []byte("hsqs"),
+		Open:       openSquashfs,
+	})
+}
+
+// squashfsListRoot — корень, который unsquashfs подставляет в пути при выводе списка
+const squashfsListRoot = "squashfs-root"
+
+func openSquashfs(path string) (Extractor, error) {
+	helper, err := FindHelper("unsquashfs")
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := runner.Output(listTimeout, helper, "-lln", "-d", squashfsListRoot, path)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать образ squashfs %s: %v", path, err)
+	}
+
+	command := func(staging string) *exec.Cmd {
+		return exec.Command(helper, "-i", "-f", "-d", staging, path)
+	}
+	parseLine := func(line, staging string) (string, bool) {
+		return strings.CutPrefix(line, staging+"/")
+	}
+	return newStagedExtractor("squashfs", parseSquashfsList(out), command, parseLine), nil
+}
+
+// parseSquashfsList разбирает вывод "unsquashfs -lln", строки вида
+// "-rwxr-xr-x 1000/1000  12345 2024-01-01 12:00 squashfs-root/bin/game"
+func parseSquashfsList(out []byte) []Entry {
+	var entries []Entry
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 || len(fields[0]) != 10 || !strings.ContainsAny(fields[0][:1], "-dl") {
+			continue
+		}
+		name := strings.Join(fields[5:], " ")
+		name, _, _ = strings.Cut(name, " -> ")
+		name, ok := strings.CutPrefix(name, squashfsListRoot+"/")
+		if !ok {
+			// Сам корень образа
+			continue
+		}
+
+		size, _ := strconv.ParseInt(fields[2], 10, 64)
+		entries = append(entries, Entry{
+			Name:  name,
+			Size:  size,
+			Mode:  parsePerm(fields[0]),
+			IsDir: fields[0][0] == 'd',
+		})
+	}
+	return entries
+}
@@ -0,0 +1,55 @@
+package archive
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestParseSquashfsList(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		out  string
+		want []Entry
+	}{
+		{
+			name: "корень, директория и файл",
+			out: "drwxr-xr-x 0/0 45 2024-01-01 12:00 squashfs-root\n" +
+				"drwxr-xr-x 1000/1000 28 2024-01-01 12:00 squashfs-root/bin\n" +
+				"-rwxr-xr-x 1000/1000 12345 2024-01-01 12:00 squashfs-root/bin/game\n",
+			want: []Entry{
+				{Name: "bin", Size: 28, Mode: 0755, IsDir: true},
+				{Name: "bin/game", Size: 12345, Mode: 0755},
+			},
+		},
+		{
+			name: "ссылка",
+			out:  "lrwxrwxrwx 1000/1000 12 2024-01-01 12:00 squashfs-root/lib/libgame.so -> libgame.so.1\n",
+			want: []Entry{{Name: "lib/libgame.so", Size: 12, Mode: os.ModeSymlink | 0777}},
+		},
+		{
+			name: "пробелы в имени",
+			out:  "-rw-r--r-- 1000/1000 3 2024-01-01 12:00 squashfs-root/Мои файлы/save 1.sav\n",
+			want: []Entry{{Name: "Мои файлы/save 1.sav", Size: 3, Mode: 0644}},
+		},
+		{
+			name: "устройства, каналы и посторонние строки",
+			out: "Parallel unsquashfs: Using 4 processors\n" +
+				"crw-r--r-- 0/0 1,3 2024-01-01 12:00 squashfs-root/dev/null\n" +
+				"prw-r--r-- 0/0 0 2024-01-01 12:00 squashfs-root/fifo\n" +
+				"-rw-r--r-- 0/0 1 2024-01-01 12:00 other-root/a\n",
+			want: nil,
+		},
+		{
+			name: "пустой вывод",
+			out:  "",
+			want: nil,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseSquashfsList([]byte(tt.out)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSquashfsList() = %+v, ожидалось %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package archive
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang-installer/internal/runner"
+)
+
+// listTimeout ограничивает чтение списка файлов архива внешней программой
+const listTimeout = 5 * time.Minute
+
+// stagedExtractor распаковывает весь архив внешней программой во временную директорию
+// одним вызовом. Программа печатает имя каждого распакованного файла, поэтому Extract
+// дожидается своей записи и переносит ее на место: архив читается один раз,
+// а прогресс остается пофайловым.
+type stagedExtractor struct {
+	name    string
+	entries []Entry
+	// command строит команду распаковки в staging
+	command func(staging string) *exec.Cmd
+	// parseLine извлекает из строки вывода имя записи, распаковка которой началась
+	parseLine func(line, staging string) (string, bool)
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	staging  string
+	resolved string // staging без символических ссылок в пути
+	cmd      *exec.Cmd
+	done     map[string]bool
+	finished bool
+	err      error
+}
+
+func newStagedExtractor(name string, entries []Entry, command func(string) *exec.Cmd,
+	parseLine func(string, string) (string, bool)) *stagedExtractor {
+	z := &stagedExtractor{
+		name:      name,
+		entries:   entries,
+		command:   command,
+		parseLine: parseLine,
+		done:      make(map[string]bool),
+	}
+	z.cond = sync.NewCond(&z.mu)
+	return z
+}
+
+func (z *stagedExtractor) Enumerate() ([]Entry, error) {
+	return z.entries, nil
+}
+
+func (z *stagedExtractor) TotalBytes() int64 {
+	var total int64
+	for _, e := range z.entries {
+		total += e.Size
+	}
+	return total
+}
+
+func (z *stagedExtractor) StagingBytes() int64 {
+	return z.TotalBytes()
+}
+
+// start запускает распаковку всего архива во временную директорию
+func (z *stagedExtractor) start() error {
+	staging, err := os.MkdirTemp(StagingDir(), z.name+"-")
+	if err != nil {
+		return err
+	}
+	z.staging = staging
+	if z.resolved, err = filepath.EvalSymlinks(staging); err != nil {
+		return err
+	}
+
+	z.cmd = z.command(staging)
+	z.cmd.Env = runner.Env()
+	stdout, err := z.cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := z.cmd.Start(); err != nil {
+		return err
+	}
+
+	go func() {
+		// Начало распаковки следующего файла означает, что предыдущий уже готов
+		var prev string
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			name, ok := z.parseLine(scanner.Text(), staging)
+			if !ok {
+				continue
+			}
+			z.mu.Lock()
+			if prev != "" {
+				z.done[prev] = true
+			}
+			prev = strings.TrimSuffix(filepath.ToSlash(name), "/")
+			z.mu.Unlock()
+			z.cond.Broadcast()
+		}
+		err := z.cmd.Wait()
+
+		z.mu.Lock()
+		z.finished = true
+		if err != nil {
+			z.err = fmt.Errorf("ошибка распаковки архива %s: %v", z.name, err)
+		}
+		z.mu.Unlock()
+		z.cond.Broadcast()
+	}()
+	return nil
+}
+
+func (z *stagedExtractor) Extract(ctx context.Context, entry Entry, dst string) error {
+	z.mu.Lock()
+	if z.cmd == nil {
+		if err := z.start(); err != nil {
+			z.mu.Unlock()
+			return err
+		}
+	}
+
+	// Отмена контекста будит ожидание и останавливает внешнюю программу
+	stop := context.AfterFunc(ctx, func() {
+		z.mu.Lock()
+		if z.cmd != nil && z.cmd.Process != nil && !z.finished {
+			z.cmd.Process.Kill()
+		}
+		z.mu.Unlock()
+		z.cond.Broadcast()
+	})
+	defer stop()
+
+	name := strings.TrimSuffix(entry.Name, "/")
+	for !z.done[name] && !z.finished && ctx.Err() == nil {
+		z.cond.Wait()
+	}
+	err := z.err
+	z.mu.Unlock()
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	src := filepath.Join(z.staging, filepath.FromSlash(name))
+	info, statErr := os.Lstat(src)
+	if statErr != nil {
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("запись %s не найдена после распаковки", entry.Name)
+	}
+
+	// Внешняя программа воссоздает ссылки из архива как есть, и запись внутри
+	// ссылки на директорию может оказаться где угодно
+	if !z.inside(filepath.Dir(src)) {
+		return fmt.Errorf("запись %s ведет за пределы архива через символическую ссылку", entry.Name)
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return z.copyLink(ctx, name, src, dst)
+	}
+	return moveFile(src, dst, entry.Mode)
+}
+
+// inside проверяет, что путь с учетом символических ссылок остается в staging
+func (z *stagedExtractor) inside(path string) bool {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return false
+	}
+	return resolved == z.resolved || strings.HasPrefix(resolved, z.resolved+string(os.PathSeparator))
+}
+
+// copyLink распаковывает символическую ссылку копией файла, на который она
+// указывает, как распаковываются ссылки из zip. Ссылки за пределы архива,
+// на директории и на другие ссылки не распаковываются.
+func (z *stagedExtractor) copyLink(ctx context.Context, name, src, dst string) error {
+	target, err := os.Readlink(src)
+	if err != nil {
+		return err
+	}
+	if !filepath.IsLocal(filepath.Join(filepath.Dir(filepath.FromSlash(name)), target)) {
+		return fmt.Errorf("символическая ссылка %s ведет за пределы архива", name)
+	}
+
+	// Файл, на который указывает ссылка, может быть уже перенесен на место
+	candidates := []string{filepath.Join(filepath.Dir(src), target), filepath.Join(filepath.Dir(dst), target)}
+	for i, candidate := range candidates {
+		info, err := os.Lstat(candidate)
+		if err != nil || !info.Mode().IsRegular() || (i == 0 && !z.inside(filepath.Dir(candidate))) {
+			continue
+		}
+		in, err := os.Open(candidate)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		return WriteFile(ctx, in, dst, info.Mode())
+	}
+	return fmt.Errorf("символическая ссылка %s указывает не на файл архива", name)
+}
+
+func (z *stagedExtractor) Close() error {
+	z.mu.Lock()
+	if z.cmd != nil && z.cmd.Process != nil && !z.finished {
+		z.cmd.Process.Kill()
+	}
+	staging := z.staging
+	z.mu.Unlock()
+
+	if staging != "" {
+		return os.RemoveAll(staging)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package archive
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+)
+
+func init() {
+	Register(Format{
+		Name:       "zip",
+		Extensions: []string{".zip"},
+		Magic:      []byte("PK\x03\x04"),
+		Open:       openZip,
+	})
+}
+
+type zipExtractor struct {
+	r     *zip.ReadCloser
+	files map[string]*zip.File
+}
+
+func openZip(path string) (Extractor, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]*zip.File, len(r.File))
+	for _, f := range r.File {
+		files[f.Name] = f
+	}
+	return &zipExtractor{r: r, files: files}, nil
+}
+
+func (z *zipExtractor) Enumerate() ([]Entry, error) {
+	entries := make([]Entry, 0, len(z.r.File))
+	for _, f := range z.r.File {
+		entries = append(entries, Entry{
+			Name:  f.Name,
+			Size:  int64(f.UncompressedSize64),
+			Mode:  f.Mode(),
+			IsDir: f.FileInfo().IsDir(),
+		})
+	}
+	return entries, nil
+}
+
+func (z *zipExtractor) Extract(ctx context.Context, entry Entry, dst string) error {
+	f, ok := z.files[entry.Name]
+	if !ok {
+		return fmt.Errorf("запись %s не найдена в архиве", entry.Name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return WriteFile(ctx, rc, dst, f.Mode())
+}
+
+func (z *zipExtractor) TotalBytes() int64 {
+	var total int64
+	for _, f := range z.r.File {
+		total += int64(f.UncompressedSize64)
+	}
+	return total
+}
+
+func (z *zipExtractor) Close() error {
+	return z.r.Close()
+}
@@ -0,0 +1,155 @@
+// Package auth получает токен, без которого CDN издателя не отдает файлы игры.
+// Способ входа выбирается полем type в конфигурации; установщик показывает
+// пользователю то, что попросит способ входа, и добавляет полученный токен
+// ко всем запросам загрузки ресурсов.
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Config — настройки входа из config.json
+type Config struct {
+	Type           string `json:"type"` // Способ входа, например oauth_device
+	ClientID       string `json:"client_id"`
+	DeviceEndpoint string `json:"device_endpoint"` // Адрес выдачи кода устройства
+	TokenEndpoint  string `json:"token_endpoint"`
+	Scope          string `json:"scope"`
+	Header         string `json:"header"`      // Заголовок для токена, по умолчанию Authorization: Bearer
+	QueryParam     string `json:"query_param"` // Если задан, токен передается параметром ссылки, а не заголовком
+	// EntitlementEndpoint — адрес проверки, что игра куплена в учетной записи
+	EntitlementEndpoint string `json:"entitlement_endpoint"`
+}
+
+// Prompt — что показать пользователю, чтобы он подтвердил вход на другом устройстве
+type Prompt struct {
+	UserCode                string
+	VerificationURI         string
+	VerificationURIComplete string // Ссылка, в которой код уже подставлен
+	ExpiresIn               time.Duration
+}
+
+// Token — результат входа
+type Token struct {
+	AccessToken  string
+	RefreshToken string
+	Expiry       time.Time // Нулевое значение — срок не сообщен
+}
+
+// Provider — способ входа
+type Provider interface {
+	// Login выполняет вход. prompt вызывается из горутины входа, когда пользователю
+	// нужно что-то показать; Login возвращается после входа, ошибки или отмены ctx.
+	Login(ctx context.Context, prompt func(Prompt)) (*Token, error)
+}
+
+// PasswordProvider — способ входа по имени пользователя и паролю. Для него
+// установщик показывает форму, а Login не используется.
+type PasswordProvider interface {
+	LoginPassword(ctx context.Context, username, password string) (*Token, error)
+}
+
+// ErrNotOwned — игры нет в учетной записи, в которую выполнен вход
+var ErrNotOwned = errors.New("игра не куплена в этой учетной записи")
+
+var providers = make(map[string]func(Config) Provider)
+
+// Register добавляет способ входа с именем kind
+func Register(kind string, factory func(Config) Provider) {
+	providers[kind] = factory
+}
+
+// New создает способ входа по конфигурации
+func New(cfg Config) (Provider, error) {
+	factory, ok := providers[cfg.Type]
+	if !ok {
+		var known []string
+		for kind := range providers {
+			known = append(known, kind)
+		}
+		sort.Strings(known)
+		return nil, fmt.Errorf("неизвестный способ входа %q, поддерживаются: %s", cfg.Type, strings.Join(known, ", "))
+	}
+	return factory(cfg), nil
+}
+
+// Attach возвращает функцию, которая добавляет токен к запросу так, как ждет CDN
+func (c Config) Attach(token string) func(*http.Request) {
+	return func(req *http.Request) {
+		switch {
+		case c.QueryParam != "":
+			q := req.URL.Query()
+			q.Set(c.QueryParam, token)
+			req.URL.RawQuery = q.Encode()
+		case c.Header != "":
+			req.Header.Set(c.Header, token)
+		default:
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+}
+
+// Refresh получает новый токен по сохраненному токену обновления, чтобы не спрашивать
+// пользователя при каждой установке
+func Refresh(ctx context.Context, cfg Config, refreshToken string) (*Token, error) {
+	var resp tokenResponse
+	err := postForm(ctx, cfg.TokenEndpoint, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+		"client_id":     {cfg.ClientID},
+	}, &resp)
+	if resp.Error != "" {
+		return nil, fmt.Errorf("ошибка обновления входа: %s %s", resp.Error, resp.Description)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка обновления входа: %v", err)
+	}
+	token := resp.token()
+	// Сервер может не выдать новый токен обновления, тогда старый остается в силе
+	if token.RefreshToken == "" {
+		token.RefreshToken = refreshToken
+	}
+	return token, nil
+}
+
+// CheckEntitlement проверяет, что игра есть в учетной записи. Сервер отвечает 200,
+// если игра куплена, и 403 или 404, если нет; ответ {"owned": false} тоже означает отказ.
+// Без адреса проверки игра считается доступной.
+func CheckEntitlement(ctx context.Context, cfg Config, token string) error {
+	if cfg.EntitlementEndpoint == "" {
+		return nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.EntitlementEndpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("не удалось проверить покупку игры: %v", err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusForbidden, http.StatusNotFound:
+		return ErrNotOwned
+	default:
+		return fmt.Errorf("не удалось проверить покупку игры: сервер вернул %s", resp.Status)
+	}
+	var body struct {
+		Owned *bool `json:"owned"`
+	}
+	if json.NewDecoder(resp.Body).Decode(&body) == nil && body.Owned != nil && !*body.Owned {
+		return ErrNotOwned
+	}
+	return nil
+}
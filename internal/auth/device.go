@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+func init() {
+	Register("oauth_device", func(cfg Config) Provider { return deviceFlow{cfg} })
+}
+
+// deviceFlow — вход OAuth 2.0 для устройств (RFC 8628): установщик показывает код,
+// пользователь вводит его на сайте издателя с телефона или в браузере
+type deviceFlow struct {
+	cfg Config
+}
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+	Error        string `json:"error"`
+	Description  string `json:"error_description"`
+}
+
+func (d deviceFlow) Login(ctx context.Context, prompt func(Prompt)) (*Token, error) {
+	var code struct {
+		DeviceCode              string `json:"device_code"`
+		UserCode                string `json:"user_code"`
+		VerificationURI         string `json:"verification_uri"`
+		VerificationURIComplete string `json:"verification_uri_complete"`
+		ExpiresIn               int    `json:"expires_in"`
+		Interval                int    `json:"interval"`
+	}
+	form := url.Values{"client_id": {d.cfg.ClientID}}
+	if d.cfg.Scope != "" {
+		form.Set("scope", d.cfg.Scope)
+	}
+	if err := postForm(ctx, d.cfg.DeviceEndpoint, form, &code); err != nil {
+		return nil, fmt.Errorf("не удалось получить код входа: %v", err)
+	}
+	if code.DeviceCode == "" || code.UserCode == "" {
+		return nil, fmt.Errorf("сервер входа не выдал код устройства")
+	}
+	prompt(Prompt{
+		UserCode:                code.UserCode,
+		VerificationURI:         code.VerificationURI,
+		VerificationURIComplete: code.VerificationURIComplete,
+		ExpiresIn:               time.Duration(code.ExpiresIn) * time.Second,
+	})
+
+	interval := time.Duration(code.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	if code.ExpiresIn > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(code.ExpiresIn)*time.Second)
+		defer cancel()
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return nil, fmt.Errorf("срок действия кода входа истек")
+			}
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+
+		var resp tokenResponse
+		err := postForm(ctx, d.cfg.TokenEndpoint, url.Values{
+			"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+			"device_code": {code.DeviceCode},
+			"client_id":   {d.cfg.ClientID},
+		}, &resp)
+		switch {
+		case resp.Error == "authorization_pending":
+			continue
+		case resp.Error == "slow_down":
+			interval += 5 * time.Second
+			continue
+		case resp.Error == "access_denied":
+			return nil, fmt.Errorf("вход отклонен")
+		case resp.Error != "":
+			return nil, fmt.Errorf("ошибка входа: %s %s", resp.Error, resp.Description)
+		case err != nil:
+			return nil, fmt.Errorf("ошибка входа: %v", err)
+		}
+		return resp.token(), nil
+	}
+}
+
+func (r tokenResponse) token() *Token {
+	t := &Token{AccessToken: r.AccessToken, RefreshToken: r.RefreshToken}
+	if r.ExpiresIn > 0 {
+		t.Expiry = time.Now().Add(time.Duration(r.ExpiresIn) * time.Second)
+	}
+	return t
+}
+
+// postForm отправляет форму и разбирает JSON-ответ. Ответ с ошибкой OAuth тоже
+// разбирается в out, чтобы вызывающий видел поле error.
+func postForm(ctx context.Context, endpoint string, form url.Values, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("сервер вернул %s", resp.Status)
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("сервер вернул %s", resp.Status)
+	}
+	return nil
+}
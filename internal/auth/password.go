@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+func init() {
+	Register("oauth_password", func(cfg Config) Provider { return passwordFlow{cfg} })
+}
+
+// passwordFlow — вход по имени и паролю учетной записи издателя (OAuth 2.0,
+// grant_type=password). Пароль уходит только на token_endpoint и нигде не хранится.
+type passwordFlow struct {
+	cfg Config
+}
+
+func (passwordFlow) Login(ctx context.Context, prompt func(Prompt)) (*Token, error) {
+	return nil, errors.New("для входа нужны имя пользователя и пароль")
+}
+
+func (p passwordFlow) LoginPassword(ctx context.Context, username, password string) (*Token, error) {
+	form := url.Values{
+		"grant_type": {"password"},
+		"username":   {username},
+		"password":   {password},
+		"client_id":  {p.cfg.ClientID},
+	}
+	if p.cfg.Scope != "" {
+		form.Set("scope", p.cfg.Scope)
+	}
+	var resp tokenResponse
+	err := postForm(ctx, p.cfg.TokenEndpoint, form, &resp)
+	switch {
+	case resp.Error == "invalid_grant":
+		return nil, errors.New("неверное имя пользователя или пароль")
+	case resp.Error != "":
+		return nil, fmt.Errorf("ошибка входа: %s %s", resp.Error, resp.Description)
+	case err != nil:
+		return nil, fmt.Errorf("ошибка входа: %v", err)
+	}
+	return resp.token(), nil
+}
@@ -0,0 +1,242 @@
+// Package backup сохраняет файлы, которые заменяет обновление игры, в backup/<версия>/
+// внутри директории установки и умеет откатывать обновление по этим копиям.
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DirName — директория с резервными копиями внутри директории установки
+const DirName = "backup"
+
+const manifestName = "manifest.json"
+
+// Manifest описывает резервную копию одного обновления
+type Manifest struct {
+	Version    string    `json:"version"`     // Версия, файлы которой сохранены
+	NewVersion string    `json:"new_version"` // Версия, установленная поверх
+	Date       time.Time `json:"date"`
+	Replaced   []string  `json:"replaced"` // Замененные файлы, прежние версии лежат в files/
+	Added      []string  `json:"added"`    // Файлы, которых до обновления не было
+
+	Dir string `json:"-"` // Директория резервной копии
+}
+
+// Session собирает резервную копию во время распаковки обновления
+type Session struct {
+	root     string
+	manifest Manifest
+}
+
+// dirName превращает версию в имя директории
+func dirName(version string) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '/' || r == os.PathSeparator || r < ' ' {
+			return '_'
+		}
+		return r
+	}, version)
+	switch name {
+	case "":
+		name = "unversioned"
+	case ".", "..":
+		name = "_" + name
+	}
+	return name
+}
+
+// Begin начинает резервную копию перед установкой newVersion поверх version.
+// Если копия этой версии уже есть, к имени директории добавляется номер.
+func Begin(root, version, newVersion string) *Session {
+	base := filepath.Join(root, DirName, dirName(version))
+	dir := base
+	for i := 2; ; i++ {
+		if _, err := os.Lstat(dir); os.IsNotExist(err) {
+			break
+		}
+		dir = base + "-" + strconv.Itoa(i)
+	}
+	return &Session{
+		root: root,
+		manifest: Manifest{
+			Version:    version,
+			NewVersion: newVersion,
+			Date:       time.Now(),
+			Dir:        dir,
+		},
+	}
+}
+
+// Save переносит существующий файл в резервную копию перед заменой
+func (s *Session) Save(name string) error {
+	dst := filepath.Join(s.manifest.Dir, "files", name)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("не удалось создать директорию резервной копии: %v", err)
+	}
+	if err := os.Rename(filepath.Join(s.root, name), dst); err != nil {
+		return fmt.Errorf("не удалось сохранить %s: %v", name, err)
+	}
+	s.manifest.Replaced = append(s.manifest.Replaced, name)
+	return nil
+}
+
+// Restore возвращает сохраненный файл на место, если замена не удалась
+func (s *Session) Restore(name string) error {
+	for i, replaced := range s.manifest.Replaced {
+		if replaced != name {
+			continue
+		}
+		if err := os.Rename(filepath.Join(s.manifest.Dir, "files", name), filepath.Join(s.root, name)); err != nil {
+			return err
+		}
+		s.manifest.Replaced = append(s.manifest.Replaced[:i], s.manifest.Replaced[i+1:]...)
+		return nil
+	}
+	return nil
+}
+
+// Added отмечает файл, появившийся с обновлением, чтобы откат его удалил
+func (s *Session) Added(name string) {
+	s.manifest.Added = append(s.manifest.Added, name)
+}
+
+// Finish записывает описание резервной копии. Если обновление ничего не изменило,
+// копия не создается.
+func (s *Session) Finish() error {
+	if len(s.manifest.Replaced) == 0 && len(s.manifest.Added) == 0 {
+		os.RemoveAll(s.manifest.Dir)
+		return nil
+	}
+	if err := os.MkdirAll(s.manifest.Dir, 0755); err != nil {
+		return fmt.Errorf("не удалось создать директорию резервной копии: %v", err)
+	}
+	data, err := json.MarshalIndent(s.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(s.manifest.Dir, manifestName), data, 0644)
+}
+
+// List возвращает резервные копии игры, начиная с самой новой
+func List(root string) ([]*Manifest, error) {
+	dirs, err := ioutil.ReadDir(filepath.Join(root, DirName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var manifests []*Manifest
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		dir := filepath.Join(root, DirName, d.Name())
+		data, err := ioutil.ReadFile(filepath.Join(dir, manifestName))
+		if err != nil {
+			continue
+		}
+		var m Manifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			continue
+		}
+		m.Dir = dir
+		manifests = append(manifests, &m)
+	}
+	sort.Slice(manifests, func(i, j int) bool {
+		return manifests[i].Date.After(manifests[j].Date)
+	})
+	return manifests, nil
+}
+
+// Latest возвращает последнюю резервную копию или nil, если откатывать нечего
+func Latest(root string) *Manifest {
+	manifests, err := List(root)
+	if err != nil || len(manifests) == 0 {
+		return nil
+	}
+	return manifests[0]
+}
+
+// Prune удаляет самые старые резервные копии, оставляя keep последних
+func Prune(root string, keep int) error {
+	manifests, err := List(root)
+	if err != nil {
+		return err
+	}
+	for len(manifests) > keep {
+		oldest := manifests[len(manifests)-1]
+		if err := os.RemoveAll(oldest.Dir); err != nil {
+			return fmt.Errorf("не удалось удалить резервную копию %s: %v", oldest.Dir, err)
+		}
+		manifests = manifests[:len(manifests)-1]
+	}
+	return nil
+}
+
+// Rollback откатывает обновление: удаляет добавленные им файлы и возвращает
+// замененные. После успешного отката резервная копия удаляется.
+func Rollback(root string, m *Manifest) error {
+	var firstErr error
+	fail := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	for _, name := range m.Added {
+		if !filepath.IsLocal(name) {
+			fail(fmt.Errorf("недопустимый путь в резервной копии: %s", name))
+			continue
+		}
+		path := filepath.Join(root, name)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			fail(fmt.Errorf("не удалось удалить %s: %v", name, err))
+			continue
+		}
+		removeEmptyParents(root, filepath.Dir(path))
+	}
+
+	for _, name := range m.Replaced {
+		if !filepath.IsLocal(name) {
+			fail(fmt.Errorf("недопустимый путь в резервной копии: %s", name))
+			continue
+		}
+		dst := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			fail(err)
+			continue
+		}
+		if err := os.Rename(filepath.Join(m.Dir, "files", name), dst); err != nil {
+			fail(fmt.Errorf("не удалось восстановить %s: %v", name, err))
+		}
+	}
+
+	if firstErr != nil {
+		return firstErr
+	}
+	if err := os.RemoveAll(m.Dir); err != nil {
+		return err
+	}
+	os.Remove(filepath.Join(root, DirName))
+	return nil
+}
+
+// removeEmptyParents удаляет опустевшие директории от dir вверх до root
+func removeEmptyParents(root, dir string) {
+	root = filepath.Clean(root)
+	for dir = filepath.Clean(dir); dir != root && strings.HasPrefix(dir, root+string(os.PathSeparator)); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Package banner — баннер в шапке окна установщика: картинка или анимация,
+// GIF или видео MP4 и WebM, как в лаунчерах игр. Видео проигрывается по кругу
+// через QtMultimedia без звука; звук игрок включает кнопкой на баннере.
+//
+// Пока анимация загружается, и если ее не удалось проиграть (нет файла, нет
+// кодеков GStreamer), на баннере остается картинка.
+package banner
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/therecipe/qt/core"
+	"github.com/therecipe/qt/gui"
+	"github.com/therecipe/qt/multimedia"
+	"github.com/therecipe/qt/widgets"
+)
+
+// DefaultHeight — высота анимированного баннера, если картинки нет
+const DefaultHeight = 150
+
+// movieExtensions — анимации, которые показывает QMovie без QtMultimedia
+var movieExtensions = []string{".gif", ".webp"}
+
+// New создает баннер с картинкой still. Если задана анимация animation, она
+// заменяет картинку, когда начнет проигрываться. sound включает звук видео сразу.
+func New(still, animation string, sound bool) *widgets.QWidget {
+	label := widgets.NewQLabel(nil, 0)
+	pixmap := gui.NewQPixmap3(still, "", 0)
+	label.SetPixmap(pixmap)
+	label.SetScaledContents(true)
+	if animation == "" {
+		return label.QWidget_PTR()
+	}
+	if _, err := os.Stat(animation); err != nil {
+		log.Printf("Анимированный баннер не найден, показывается картинка: %v", err)
+		return label.QWidget_PTR()
+	}
+
+	// Размер шапки не зависит от размера кадров анимации
+	height := DefaultHeight
+	if !pixmap.IsNull() {
+		height = pixmap.Height()
+	}
+	stack := widgets.NewQStackedWidget(nil)
+	stack.SetFixedHeight(height)
+	stack.AddWidget(label)
+
+	if isMovie(animation) {
+		playMovie(stack, animation)
+		return stack.QWidget_PTR()
+	}
+
+	// Кнопка звука лежит поверх видео в правом верхнем углу
+	container := widgets.NewQWidget(nil, 0)
+	layout := widgets.NewQGridLayout(container)
+	layout.SetContentsMargins(0, 0, 0, 0)
+	layout.AddWidget3(stack, 0, 0, 1, 1, 0)
+	soundButton := widgets.NewQToolButton(nil)
+	soundButton.SetCheckable(true)
+	soundButton.Hide()
+	layout.AddWidget3(soundButton, 0, 0, 1, 1, core.Qt__AlignTop|core.Qt__AlignRight)
+	playVideo(stack, soundButton, animation, sound)
+	return container
+}
+
+func isMovie(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range movieExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// playMovie показывает GIF или анимированный WebP
+func playMovie(stack *widgets.QStackedWidget, path string) {
+	movie := gui.NewQMovie3(path, core.NewQByteArray(), stack)
+	if !movie.IsValid() {
+		log.Printf("Анимированный баннер %s не читается, показывается картинка: %s", path, movie.LastErrorString())
+		return
+	}
+	label := widgets.NewQLabel(nil, 0)
+	label.SetScaledContents(true)
+	label.SetMovie(movie)
+	stack.AddWidget(label)
+	stack.SetCurrentWidget(label)
+	movie.Start()
+}
+
+// playVideo проигрывает видео по кругу и показывает его, когда оно загрузится
+func playVideo(stack *widgets.QStackedWidget, soundButton *widgets.QToolButton, path string, sound bool) {
+	view := multimedia.NewQVideoWidget(nil)
+	// Видео заполняет шапку целиком, лишнее по краям обрезается
+	view.SetAspectRatioMode(core.Qt__KeepAspectRatioByExpanding)
+	stack.AddWidget(view)
+
+	player := multimedia.NewQMediaPlayer(stack, 0)
+	player.SetVideoOutput(view)
+	playlist := multimedia.NewQMediaPlaylist(player)
+	playlist.AddMedia(multimedia.NewQMediaContent2(core.QUrl_FromLocalFile(path)))
+	playlist.SetPlaybackMode(multimedia.QMediaPlaylist__Loop)
+	player.SetPlaylist(playlist)
+
+	setSound := func(on bool) {
+		player.SetMuted(!on)
+		if on {
+			soundButton.SetText("Выключить звук")
+		} else {
+			soundButton.SetText("Включить звук")
+		}
+	}
+	setSound(sound)
+	soundButton.SetChecked(sound)
+	soundButton.ConnectToggled(setSound)
+
+	fail := func() {
+		log.Printf("Не удалось проиграть баннер %s, показывается картинка: %s", path, player.ErrorString())
+		player.Stop()
+		stack.SetCurrentIndex(0)
+		soundButton.Hide()
+	}
+	player.ConnectMediaStatusChanged(func(status multimedia.QMediaPlayer__MediaStatus) {
+		switch status {
+		case multimedia.QMediaPlayer__LoadedMedia, multimedia.QMediaPlayer__BufferedMedia:
+			stack.SetCurrentWidget(view)
+			soundButton.Show()
+		case multimedia.QMediaPlayer__InvalidMedia:
+			fail()
+		}
+	})
+	player.ConnectError2(func(multimedia.QMediaPlayer__Error) {
+		fail()
+	})
+	player.Play()
+}
@@ -0,0 +1,134 @@
+// Package branding загружает шрифты игры из пакета установщика, чтобы окно
+// установщика было оформлено так же, как игра. Шрифты регистрируются только для
+// этого процесса через QFontDatabase: в систему ничего не устанавливается, а
+// после выхода установщика шрифтов игры в системе нет.
+//
+// Оформление не должно мешать установке: шрифт, который не читается, не
+// загружается или не содержит нужных символов, заменяется системным.
+package branding
+
+import (
+	"log"
+	"os"
+
+	"github.com/therecipe/qt/gui"
+	"github.com/therecipe/qt/widgets"
+)
+
+// Размеры шрифта в пунктах, которые принимаются из конфигурации. Остальные
+// делают окно нечитаемым или не помещаются в него.
+const (
+	MinSize = 7
+	MaxSize = 20
+)
+
+// Fonts — шрифты оформления в конфигурации установщика
+type Fonts struct {
+	Files    []string `json:"files"`    // Файлы шрифтов .ttf, .otf относительно config.json
+	Family   string   `json:"family"`   // Семейство основного текста, по умолчанию первое из файлов
+	Heading  string   `json:"heading"`  // Семейство кнопки установки и заголовков, по умолчанию Family
+	Size     int      `json:"size"`     // Размер основного текста в пунктах, 0 — системный
+	Fallback []string `json:"fallback"` // Семейства для символов, которых нет в шрифте, например кириллицы
+}
+
+// heading — семейства заголовков после загрузки, пусто — системный шрифт
+var heading []string
+
+// Apply загружает шрифты f и назначает их приложению. Шрифты, которые не
+// удалось загрузить, пропускаются с записью в журнал.
+func Apply(app *widgets.QApplication, f Fonts) {
+	heading = nil
+	var loaded []string
+	for _, file := range f.Files {
+		if _, err := os.Stat(file); err != nil {
+			log.Printf("Шрифт оформления %s не найден: %v", file, err)
+			continue
+		}
+		id := gui.QFontDatabase_AddApplicationFont(file)
+		if id < 0 {
+			log.Printf("Шрифт оформления %s не загружен: файл поврежден или это не шрифт", file)
+			continue
+		}
+		families := gui.QFontDatabase_ApplicationFontFamilies(id)
+		log.Printf("Загружен шрифт оформления %s: %v", file, families)
+		loaded = append(loaded, families...)
+	}
+
+	family := f.Family
+	if family == "" && len(loaded) > 0 {
+		family = loaded[0]
+	}
+	body := families(family, loaded, f.Fallback)
+	if f.Heading != "" {
+		heading = families(f.Heading, loaded, f.Fallback)
+	} else {
+		heading = body
+	}
+
+	if body == nil && f.Size == 0 {
+		return
+	}
+	font := app.Font()
+	if body != nil {
+		font.SetFamilies(body)
+	}
+	switch {
+	case f.Size == 0:
+	case f.Size < MinSize || f.Size > MaxSize:
+		log.Printf("Размер шрифта %d вне пределов %d–%d, используется системный", f.Size, MinSize, MaxSize)
+	default:
+		font.SetPointSize(f.Size)
+	}
+	widgets.QApplication_SetFont(font, "")
+}
+
+// Heading назначает виджету шрифт заголовков, если он загружен
+func Heading(widget widgets.QWidget_ITF) {
+	if heading == nil {
+		return
+	}
+	w := widget.QWidget_PTR()
+	font := w.Font()
+	font.SetFamilies(heading)
+	w.SetFont(font)
+}
+
+// families возвращает семейство family с запасными для недостающих символов или
+// nil, если такого семейства нет ни среди загруженных, ни в системе. Последним
+// идет системный шрифт: текст, для которого нет символов ни в одном семействе,
+// виден хотя бы им.
+func families(family string, loaded, fallback []string) []string {
+	if family == "" {
+		return nil
+	}
+	if !available(family, loaded) {
+		log.Printf("Семейство шрифта %s не найдено, используется системный шрифт", family)
+		return nil
+	}
+	list := []string{family}
+	for _, f := range fallback {
+		if available(f, loaded) {
+			list = append(list, f)
+		}
+	}
+	system := gui.QFontDatabase_SystemFont(gui.QFontDatabase__GeneralFont).Family()
+	if system != "" && system != family {
+		list = append(list, system)
+	}
+	return list
+}
+
+// available сообщает, что семейство загружено из пакета или есть в системе
+func available(family string, loaded []string) bool {
+	for _, f := range loaded {
+		if f == family {
+			return true
+		}
+	}
+	for _, f := range gui.NewQFontDatabase().Families(gui.QFontDatabase__Any) {
+		if f == family {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+// Package bundle открывает пакет установщика: config.json с файлами игры рядом
+// или zip-архив с config.json внутри (.gqi, .gpk, .zip). Архив распаковывается в кэш
+// пользователя, а установщик работает с распакованной директорией так же, как с
+// config.json в текущей директории.
+package bundle
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ConfigName — конфигурация установщика в пакете
+const ConfigName = "config.json"
+
+// Extensions — расширения архивов, которые могут быть пакетами
+var Extensions = []string{".gqi", ".gpk", ".zip"}
+
+// ErrNoConfig — в архиве нет config.json: это не пакет, а архив с файлами игры
+var ErrNoConfig = errors.New("в архиве нет " + ConfigName)
+
+// IsArchive сообщает, что у файла расширение пакета
+func IsArchive(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range Extensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// Dir возвращает директорию распакованных пакетов
+func Dir() string {
+	cacheHome := os.Getenv("XDG_CACHE_HOME")
+	if cacheHome == "" {
+		cacheHome = filepath.Join(os.Getenv("HOME"), ".cache")
+	}
+	return filepath.Join(cacheHome, "go-qt-installer", "packages")
+}
+
+// Open возвращает путь к config.json пакета path: самого файла конфигурации,
+// директории с ним или архива. Архив без config.json дает ErrNoConfig.
+func Open(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		config := filepath.Join(path, ConfigName)
+		if _, err := os.Stat(config); err != nil {
+			return "", fmt.Errorf("в директории %s нет %s", path, ConfigName)
+		}
+		return config, nil
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return path, nil
+	}
+	return extract(path, info)
+}
+
+// extract распаковывает архив-пакет. Директория зависит от пути, размера и
+// времени изменения архива, поэтому тот же пакет повторно не распаковывается.
+func extract(path string, info os.FileInfo) (string, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return "", fmt.Errorf("не удалось открыть %s: %v", path, err)
+	}
+	defer r.Close()
+
+	// config.json может лежать в корне или в единственной директории архива
+	prefix := ""
+	found := false
+	for _, f := range r.File {
+		if name := filepath.ToSlash(f.Name); name == ConfigName || strings.Count(name, "/") == 1 && strings.HasSuffix(name, "/"+ConfigName) {
+			prefix = strings.TrimSuffix(name, ConfigName)
+			found = true
+			break
+		}
+	}
+	if !found {
+		return "", ErrNoConfig
+	}
+
+	abs, _ := filepath.Abs(path)
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s %d %d", abs, info.Size(), info.ModTime().UnixNano())))
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	dir := filepath.Join(Dir(), fmt.Sprintf("%s-%x", name, sum[:4]))
+	config := filepath.Join(dir, ConfigName)
+	if _, err := os.Stat(config); err == nil {
+		return config, nil
+	}
+
+	tmp := dir + ".tmp"
+	os.RemoveAll(tmp)
+	for _, f := range r.File {
+		name := filepath.ToSlash(f.Name)
+		if !strings.HasPrefix(name, prefix) || strings.HasSuffix(name, "/") {
+			continue
+		}
+		target := filepath.Join(tmp, filepath.FromSlash(strings.TrimPrefix(name, prefix)))
+		// Записи вне директории пакета пропускаются
+		if rel, err := filepath.Rel(tmp, target); err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		if err := extractFile(f, target); err != nil {
+			os.RemoveAll(tmp)
+			return "", fmt.Errorf("ошибка при распаковке %s: %v", f.Name, err)
+		}
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		os.RemoveAll(tmp)
+		return "", err
+	}
+	return config, nil
+}
+
+func extractFile(f *zip.File, target string) error {
+	if !f.Mode().IsRegular() {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	in, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, f.Mode().Perm()|0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
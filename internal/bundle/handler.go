@@ -0,0 +1,101 @@
+package bundle
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"golang-installer/internal/common"
+	"golang-installer/internal/runner"
+)
+
+// MIMEType — тип пакетов установщика .gqi
+const MIMEType = "application/x-go-qt-installer"
+
+// HandlerID — ярлык установщика, который открывает пакеты
+const HandlerID = "io.github.foxixus1.goqtinstaller.desktop"
+
+// mimeName — файл описания типа в ~/.local/share/mime/packages
+const mimeName = "go-qt-installer.xml"
+
+// mimePackage описывает тип по расширению: внутри .gqi — обычный zip, и по
+// содержимому его не отличить от других архивов
+const mimePackage = `<?xml version="1.0" encoding="UTF-8"?>
+<mime-info xmlns="http://www.freedesktop.org/standards/shared-mime-info">
+  <mime-type type="` + MIMEType + `">
+    <comment>Game installer package</comment>
+    <comment xml:lang="ru">Пакет установщика игры</comment>
+    <sub-class-of type="application/zip"/>
+    <generic-icon name="package-x-generic"/>
+    <glob pattern="*.gqi"/>
+  </mime-type>
+</mime-info>
+`
+
+// handlerEntry — ярлык обработчика. NoDisplay прячет его из меню: он нужен
+// только файловому менеджеру.
+const handlerEntry = `[Desktop Entry]
+Type=Application
+Name=Game installer
+Name[ru]=Установщик игры
+Comment=Install a game from an installer package
+Comment[ru]=Установить игру из пакета установщика
+Exec=%s --package %%f
+Icon=package-x-generic
+Terminal=false
+NoDisplay=true
+MimeType=` + MIMEType + `;
+`
+
+func dataHome() string {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.Getenv("HOME"), ".local", "share")
+}
+
+// RegisterHandler регистрирует тип пакетов .gqi для текущего пользователя и
+// делает установщик exe программой по умолчанию для него: двойной щелчок по
+// пакету в файловом менеджере запускает exe --package <пакет>.
+func RegisterHandler(exe string) error {
+	mimeDir := filepath.Join(dataHome(), "mime")
+	if err := os.MkdirAll(filepath.Join(mimeDir, "packages"), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(mimeDir, "packages", mimeName), []byte(mimePackage), 0644); err != nil {
+		return err
+	}
+	if err := runner.Run("update-mime-database", mimeDir); err != nil {
+		return fmt.Errorf("не удалось обновить базу типов файлов: %w", err)
+	}
+
+	applications := filepath.Join(dataHome(), "applications")
+	if err := os.MkdirAll(applications, 0755); err != nil {
+		return err
+	}
+	entry := fmt.Sprintf(handlerEntry, common.DesktopQuote(exe))
+	if err := ioutil.WriteFile(filepath.Join(applications, HandlerID), []byte(entry), 0644); err != nil {
+		return err
+	}
+	// Кэш ярлыков необязателен: без него обработчик найдется при следующем входе
+	runner.Run("update-desktop-database", applications)
+
+	if err := runner.Run("xdg-mime", "default", HandlerID, MIMEType); err != nil {
+		return fmt.Errorf("не удалось назначить установщик программой для пакетов: %w", err)
+	}
+	return nil
+}
+
+// UnregisterHandler убирает тип пакетов и ярлык обработчика
+func UnregisterHandler() error {
+	mimeDir := filepath.Join(dataHome(), "mime")
+	for _, path := range []string{filepath.Join(mimeDir, "packages", mimeName), filepath.Join(dataHome(), "applications", HandlerID)} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	runner.Run("update-mime-database", mimeDir)
+	runner.Run("update-desktop-database", filepath.Join(dataHome(), "applications"))
+	return nil
+}
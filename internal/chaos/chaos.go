@@ -0,0 +1,154 @@
+// Package chaos внедряет сбои для проверки установщика: нехватку места на диске,
+// неполную запись, обрывы соединения и задержки. Включается скрытым параметром
+// --chaos или переменной GO_QT_INSTALLER_CHAOS и нужен только тестировщикам,
+// чтобы пройти все ветки обработки ошибок, откат и продолжение установки.
+package chaos
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// EnvName — переменная окружения: "1" включает сбои с вероятностью по умолчанию,
+// число от 0 до 1 задает вероятность сбоя на каждую операцию чтения или записи
+const EnvName = "GO_QT_INSTALLER_CHAOS"
+
+// DefaultRate — вероятность сбоя на операцию по умолчанию
+const DefaultRate = 0.01
+
+// delayFactor — во столько раз задержки случаются чаще сбоев
+const delayFactor = 5
+
+// maxDelay — наибольшая внедряемая задержка
+const maxDelay = 300 * time.Millisecond
+
+var (
+	mu   sync.Mutex
+	rate float64
+	rnd  *rand.Rand
+)
+
+// Enable включает сбои с вероятностью r на операцию. Одинаковый seed дает
+// одинаковую последовательность сбоев, что помогает воспроизвести ошибку.
+func Enable(r float64, seed int64) {
+	mu.Lock()
+	defer mu.Unlock()
+	rate = r
+	rnd = rand.New(rand.NewSource(seed))
+	if r > 0 {
+		log.Printf("Режим сбоев включен: вероятность %.3f, seed %d", r, seed)
+	}
+}
+
+// Setup включает сбои по параметру --chaos или переменной окружения
+func Setup(args []string) {
+	value := os.Getenv(EnvName)
+	for _, arg := range args {
+		if arg == "--chaos" && value == "" {
+			value = "1"
+		} else if v, ok := strings.CutPrefix(arg, "--chaos="); ok {
+			value = v
+		}
+	}
+	if value == "" || value == "0" {
+		return
+	}
+	r := DefaultRate
+	if value != "1" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed < 0 || parsed > 1 {
+			log.Printf("Некорректная вероятность сбоев %q, используется %.2f", value, DefaultRate)
+		} else {
+			r = parsed
+		}
+	}
+	Enable(r, time.Now().UnixNano())
+}
+
+// Enabled сообщает, включены ли сбои
+func Enabled() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return rate > 0
+}
+
+// roll возвращает true с вероятностью p
+func roll(p float64) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return rate > 0 && rnd.Float64() < p*rate
+}
+
+// delay иногда приостанавливает операцию
+func delay() {
+	if !roll(delayFactor) {
+		return
+	}
+	mu.Lock()
+	d := time.Duration(rnd.Int63n(int64(maxDelay)))
+	mu.Unlock()
+	time.Sleep(d)
+}
+
+type writer struct {
+	w    io.Writer
+	name string
+}
+
+// Writer оборачивает запись на диск: иногда она задерживается, записывает не все
+// данные или завершается ошибкой ENOSPC. name попадает в журнал. Без режима сбоев
+// возвращает w как есть.
+func Writer(w io.Writer, name string) io.Writer {
+	if !Enabled() {
+		return w
+	}
+	return &writer{w: w, name: name}
+}
+
+func (c *writer) Write(p []byte) (int, error) {
+	delay()
+	switch {
+	case roll(0.5):
+		log.Printf("Сбой: нет места на диске при записи %s", c.name)
+		return 0, &os.PathError{Op: "write", Path: c.name, Err: syscall.ENOSPC}
+	case roll(0.5) && len(p) > 1:
+		n, err := c.w.Write(p[:len(p)/2])
+		if err == nil {
+			log.Printf("Сбой: неполная запись %s", c.name)
+			err = io.ErrShortWrite
+		}
+		return n, err
+	}
+	return c.w.Write(p)
+}
+
+type reader struct {
+	r    io.Reader
+	name string
+}
+
+// Reader оборачивает чтение из сети: иногда оно задерживается или соединение
+// обрывается с ошибкой ECONNRESET. Без режима сбоев возвращает r как есть.
+func Reader(r io.Reader, name string) io.Reader {
+	if !Enabled() {
+		return r
+	}
+	return &reader{r: r, name: name}
+}
+
+func (c *reader) Read(p []byte) (int, error) {
+	delay()
+	if roll(1) {
+		log.Printf("Сбой: обрыв соединения при загрузке %s", c.name)
+		return 0, fmt.Errorf("read %s: %w", c.name, syscall.ECONNRESET)
+	}
+	return c.r.Read(p)
+}
@@ -0,0 +1,490 @@
+// Package cloudsave переносит сохранения игры через облачное хранилище: после
+// установки забирает их оттуда, перед удалением отправляет обратно. Поэтому при
+// переустановке на другом компьютере прогресс игрока восстанавливается.
+//
+// В хранилище у игры два объекта: архив saves.tar.gz и описание saves.json с хэшем
+// содержимого. Хэш последней синхронизации хранится в записи об установке, и по
+// трем хэшам — локальному, облачному и последнему общему — видно, какая сторона
+// изменилась и есть ли конфликт.
+package cloudsave
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang-installer/internal/engine"
+	"golang-installer/internal/objstore"
+)
+
+const (
+	archiveName  = "saves.tar.gz"
+	manifestName = "saves.json"
+
+	// gamePrefix и homePrefix отделяют в архиве файлы из директории игры от файлов
+	// в домашней директории игрока
+	gamePrefix = "game/"
+	homePrefix = "home/"
+)
+
+// Config — облачные сохранения в конфигурации установщика
+type Config struct {
+	objstore.Config
+	Prefix string   `json:"prefix"` // Директория игрока в хранилище, допускаются поля {{id}}
+	Paths  []string `json:"paths"`  // Шаблоны файлов сохранений относительно директории игры или от ~/
+}
+
+// Enabled сообщает, включены ли облачные сохранения
+func (c Config) Enabled() bool {
+	return c.Type != "" && len(c.Paths) > 0
+}
+
+// Record — облачные сохранения в записи об установке. Секрет подключения хранится
+// в связке ключей, в записи его нет.
+type Record struct {
+	Config
+	Hash     string    `json:"hash,omitempty"`      // Хэш сохранений при последней синхронизации
+	SyncedAt time.Time `json:"synced_at,omitempty"` // Время последней синхронизации
+}
+
+// Manifest — описание сохранений в хранилище
+type Manifest struct {
+	Hash      string    `json:"hash"`
+	Host      string    `json:"host"` // Компьютер, с которого сохранения отправлены
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Status — соотношение локальных и облачных сохранений
+type Status int
+
+const (
+	// InSync — сохранения совпадают или их нет нигде
+	InSync Status = iota
+	// LocalNewer — сохранения изменились только на этом компьютере
+	LocalNewer
+	// RemoteNewer — сохранения изменились только в облаке
+	RemoteNewer
+	// Conflict — сохранения изменились и здесь, и в облаке
+	Conflict
+)
+
+// Compare определяет статус по хэшам локальных сохранений, облачных и последней
+// синхронизации. Пустой хэш означает, что сохранений нет.
+func Compare(local, remote, last string) Status {
+	switch {
+	case local == remote:
+		return InSync
+	case remote == "":
+		return LocalNewer
+	case local == "" || local == last:
+		return RemoteNewer
+	case remote == last:
+		return LocalNewer
+	}
+	return Conflict
+}
+
+// Session — синхронизация сохранений одной установленной игры
+type Session struct {
+	store       objstore.Store
+	dir         string
+	installPath string
+	home        string
+	paths       []string
+}
+
+// New подключается к хранилищу. Объекты игры лежат в <prefix>/<slug>/.
+func New(c Config, slug, installPath string) (*Session, error) {
+	store, err := objstore.Open(c.Config)
+	if err != nil {
+		return nil, err
+	}
+	dir := slug
+	if prefix := strings.Trim(c.Prefix, "/"); prefix != "" {
+		dir = prefix + "/" + slug
+	}
+	return &Session{
+		store:       store,
+		dir:         dir,
+		installPath: installPath,
+		home:        os.Getenv("HOME"),
+		paths:       c.Paths,
+	}, nil
+}
+
+// file — файл сохранения: имя в архиве и путь на диске
+type file struct {
+	name string
+	path string
+}
+
+// collect находит файлы сохранений по шаблонам из конфигурации
+func (s *Session) collect() ([]file, error) {
+	found := make(map[string]string)
+	for _, pattern := range s.paths {
+		root, prefix := s.installPath, gamePrefix
+		if rest, ok := strings.CutPrefix(pattern, "~/"); ok {
+			root, prefix, pattern = s.home, homePrefix, rest
+		}
+		// Обходим только неизменную часть шаблона до первого подстановочного символа
+		static := pattern
+		if i := strings.IndexAny(static, "*?["); i >= 0 {
+			static = path.Dir(static[:i+1])
+		}
+		err := filepath.Walk(filepath.Join(root, filepath.FromSlash(static)), func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+			rel, err := filepath.Rel(root, p)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			if engine.IsUserData([]string{pattern}, rel) {
+				found[prefix+rel] = p
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	files := make([]file, 0, len(found))
+	for name, p := range found {
+		files = append(files, file{name: name, path: p})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].name < files[j].name })
+	return files, nil
+}
+
+// hasher считает хэш сохранений по именам и содержимому файлов в порядке имен.
+// Время изменения в хэш не входит, поэтому распакованный архив дает тот же хэш.
+type hasher struct {
+	hash.Hash
+}
+
+func newHasher() hasher {
+	return hasher{sha256.New()}
+}
+
+func (h hasher) add(name string, size int64, content io.Reader) error {
+	fmt.Fprintf(h, "%s\x00%d\x00", name, size)
+	_, err := io.Copy(h, content)
+	return err
+}
+
+func (h hasher) sum() string {
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// LocalHash возвращает хэш сохранений на этом компьютере, пустую строку — если их нет
+func (s *Session) LocalHash() (string, error) {
+	files, err := s.collect()
+	if err != nil || len(files) == 0 {
+		return "", err
+	}
+	h := newHasher()
+	for _, f := range files {
+		if err := hashFile(h, f); err != nil {
+			return "", err
+		}
+	}
+	return h.sum(), nil
+}
+
+func hashFile(h hasher, f file) error {
+	in, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	return h.add(f.name, info.Size(), in)
+}
+
+// Remote читает описание облачных сохранений; nil означает, что их нет
+func (s *Session) Remote(ctx context.Context) (*Manifest, error) {
+	body, _, err := s.store.Get(ctx, s.dir+"/"+manifestName)
+	if errors.Is(err, objstore.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	var m Manifest
+	if err := json.NewDecoder(body).Decode(&m); err != nil {
+		return nil, fmt.Errorf("ошибка при разборе %s: %v", manifestName, err)
+	}
+	return &m, nil
+}
+
+// Archive упаковывает локальные сохранения в файл archivePath и возвращает их хэш
+func (s *Session) Archive(archivePath string) (string, error) {
+	files, err := s.collect()
+	if err != nil {
+		return "", err
+	}
+	out, err := os.Create(archivePath)
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+	h := newHasher()
+	for _, f := range files {
+		if err := addFile(tw, h, f); err != nil {
+			return "", err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return "", err
+	}
+	if err := gz.Close(); err != nil {
+		return "", err
+	}
+	if len(files) == 0 {
+		return "", out.Close()
+	}
+	return h.sum(), out.Close()
+}
+
+func addFile(tw *tar.Writer, h hasher, f file) error {
+	in, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = f.name
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	// Хэш считается по тем же байтам, что попадают в архив
+	return h.add(f.name, info.Size(), io.TeeReader(in, tw))
+}
+
+// Push отправляет локальные сохранения в облако и возвращает их хэш. Если name
+// не пустой, архив сохраняется под этим именем как копия, а описание не меняется.
+func (s *Session) Push(ctx context.Context, name string) (string, error) {
+	tmp, err := ioutil.TempFile("", "cloudsave-*.tar.gz")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	tmp.Close()
+
+	hash, err := s.Archive(tmp.Name())
+	if err != nil {
+		return "", fmt.Errorf("ошибка при упаковке сохранений: %v", err)
+	}
+	if hash == "" {
+		return "", nil
+	}
+	key := archiveName
+	if name != "" {
+		key = name
+	}
+	if err := s.upload(ctx, key, tmp.Name()); err != nil {
+		return "", err
+	}
+	if name != "" {
+		return hash, nil
+	}
+
+	host, _ := os.Hostname()
+	manifest, err := json.MarshalIndent(Manifest{Hash: hash, Host: host, UpdatedAt: time.Now()}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := s.store.Put(ctx, s.dir+"/"+manifestName, bytes.NewReader(manifest), int64(len(manifest))); err != nil {
+		return "", fmt.Errorf("ошибка при отправке %s: %v", manifestName, err)
+	}
+	return hash, nil
+}
+
+func (s *Session) upload(ctx context.Context, key, filePath string) error {
+	in, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if err := s.store.Put(ctx, s.dir+"/"+key, in, info.Size()); err != nil {
+		return fmt.Errorf("ошибка при отправке %s: %v", key, err)
+	}
+	return nil
+}
+
+// download скачивает архив сохранений во временный файл
+func (s *Session) download(ctx context.Context) (string, error) {
+	body, _, err := s.store.Get(ctx, s.dir+"/"+archiveName)
+	if err != nil {
+		return "", fmt.Errorf("ошибка при загрузке %s: %v", archiveName, err)
+	}
+	defer body.Close()
+	tmp, err := ioutil.TempFile("", "cloudsave-*.tar.gz")
+	if err != nil {
+		return "", err
+	}
+	defer tmp.Close()
+	if _, err := io.Copy(tmp, body); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("ошибка при загрузке %s: %v", archiveName, err)
+	}
+	return tmp.Name(), nil
+}
+
+// CopyRemote сохраняет облачный архив под именем name, чтобы его не потерять
+// при замене сохранениями с этого компьютера
+func (s *Session) CopyRemote(ctx context.Context, name string) error {
+	archivePath, err := s.download(ctx)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(archivePath)
+	return s.upload(ctx, name, archivePath)
+}
+
+// Pull скачивает облачные сохранения и распаковывает их поверх локальных. Хэш
+// содержимого сверяется с описанием до того, как что-то будет записано.
+func (s *Session) Pull(ctx context.Context, remote *Manifest) error {
+	archivePath, err := s.download(ctx)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(archivePath)
+
+	h := newHasher()
+	if err := s.walkArchive(archivePath, func(name string, header *tar.Header, r io.Reader) error {
+		return h.add(name, header.Size, r)
+	}); err != nil {
+		return err
+	}
+	if h.sum() != remote.Hash {
+		return fmt.Errorf("архив сохранений в облаке поврежден: хэш не совпадает с %s", manifestName)
+	}
+
+	return s.walkArchive(archivePath, func(name string, header *tar.Header, r io.Reader) error {
+		target, err := s.target(name)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(header.Mode)&0777|0600)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, r); err != nil {
+			out.Close()
+			return err
+		}
+		if err := out.Close(); err != nil {
+			return err
+		}
+		return os.Chtimes(target, header.ModTime, header.ModTime)
+	})
+}
+
+// walkArchive вызывает fn для каждого файла архива сохранений
+func (s *Session) walkArchive(archivePath string, fn func(name string, header *tar.Header, r io.Reader) error) error {
+	in, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		return fmt.Errorf("ошибка при чтении архива сохранений: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("ошибка при чтении архива сохранений: %v", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := fn(header.Name, header, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// target возвращает путь на диске для файла архива и не выпускает его за пределы
+// директории игры или домашней директории
+func (s *Session) target(name string) (string, error) {
+	root, rel := s.installPath, ""
+	if rest, ok := strings.CutPrefix(name, gamePrefix); ok {
+		rel = rest
+	} else if rest, ok := strings.CutPrefix(name, homePrefix); ok {
+		root, rel = s.home, rest
+	} else {
+		return "", fmt.Errorf("неизвестный файл в архиве сохранений: %s", name)
+	}
+	if reason := engine.CheckEntryName(root, rel); reason != "" || rel == "" {
+		return "", fmt.Errorf("опасный файл в архиве сохранений %s: %s", name, reason)
+	}
+	return filepath.Join(root, filepath.FromSlash(rel)), nil
+}
+
+// Backup упаковывает локальные сохранения в директорию dir перед заменой облачными
+// и возвращает путь к архиву
+func (s *Session) Backup(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	archivePath := filepath.Join(dir, "saves-"+time.Now().Format("20060102-150405")+".tar.gz")
+	if _, err := s.Archive(archivePath); err != nil {
+		os.Remove(archivePath)
+		return "", err
+	}
+	return archivePath, nil
+}
+
+// ConflictName возвращает имя копии сохранений, которая остается в облаке при конфликте
+func ConflictName(host string) string {
+	return "saves-conflict-" + host + "-" + time.Now().Format("20060102-150405") + ".tar.gz"
+}
@@ -0,0 +1,158 @@
+// Package codesign подписывает собранные установщики и проверяет подписи. На
+// Linux это отдельная подпись GPG (.asc рядом с файлом), для сборок Windows —
+// подпись Authenticode через osslsigncode, для macOS — codesign с hardened
+// runtime и меткой времени, как требует нотаризация. Сведения о подписях
+// хранятся в манифесте сборки, по ним --verify-build проверяет скачанный файл.
+package codesign
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang-installer/internal/runner"
+)
+
+// Способы подписи
+const (
+	GPG          = "gpg"
+	Osslsigncode = "osslsigncode"
+	Codesign     = "codesign"
+)
+
+// signTimeout — подпись может ждать пароля ключа и сервера меток времени
+const signTimeout = 5 * time.Minute
+
+// TimestampURL — сервер меток времени для Authenticode: без метки подпись
+// перестает проверяться, когда истекает сертификат
+var TimestampURL = "http://timestamp.digicert.com"
+
+// Signer — способ подписи и ключ
+type Signer struct {
+	Tool string
+	// Key для gpg — идентификатор ключа (по умолчанию ключ gpg по умолчанию),
+	// для osslsigncode — "сертификат.pem,ключ.pem" или файл .p12,
+	// для codesign — имя сертификата (Developer ID Application: ...)
+	Key string
+}
+
+// Signature — запись о подписи в манифесте сборки
+type Signature struct {
+	Tool string `json:"tool"`
+	Key  string `json:"key,omitempty"`
+	// File — отдельный файл подписи относительно подписанного файла. Встроенные
+	// подписи Authenticode и codesign хранятся в самом файле.
+	File string `json:"file,omitempty"`
+}
+
+// Parse разбирает способ подписи вида gpg, gpg:<ключ>, osslsigncode:<ключ>, codesign:<сертификат>
+func Parse(spec string) (Signer, error) {
+	tool, key, _ := strings.Cut(spec, ":")
+	switch tool {
+	case GPG:
+	case Osslsigncode, Codesign:
+		if key == "" {
+			return Signer{}, fmt.Errorf("для %s нужен ключ: %s:<ключ>", tool, tool)
+		}
+	default:
+		return Signer{}, fmt.Errorf("неизвестный способ подписи %q, поддерживаются %s, %s и %s", tool, GPG, Osslsigncode, Codesign)
+	}
+	return Signer{Tool: tool, Key: key}, nil
+}
+
+// Embedded сообщает, что подпись записывается в сам файл и меняет его
+func (s Signer) Embedded() bool {
+	return s.Tool != GPG
+}
+
+// Sign подписывает файл path
+func (s Signer) Sign(path string) (Signature, error) {
+	sig := Signature{Tool: s.Tool, Key: s.Key}
+	var err error
+	switch s.Tool {
+	case GPG:
+		sig.File = filepath.Base(path) + ".asc"
+		args := []string{"--batch", "--yes", "--armor", "--detach-sign", "--output", path + ".asc"}
+		if s.Key != "" {
+			args = append(args, "--local-user", s.Key)
+		}
+		_, err = runner.Output(signTimeout, "gpg", append(args, path)...)
+	case Osslsigncode:
+		if err := requireFormat(path, peMagic, "программы Windows (PE)"); err != nil {
+			return sig, err
+		}
+		// osslsigncode не подписывает файл на месте: подписанная копия заменяет исходный
+		signed := path + ".signed"
+		args := []string{"sign", "-h", "sha256", "-ts", TimestampURL}
+		if cert, key, ok := strings.Cut(s.Key, ","); ok {
+			args = append(args, "-certs", cert, "-key", key)
+		} else {
+			args = append(args, "-pkcs12", s.Key)
+		}
+		args = append(args, "-in", path, "-out", signed)
+		if _, err = runner.Output(signTimeout, "osslsigncode", args...); err == nil {
+			err = os.Rename(signed, path)
+		}
+		os.Remove(signed)
+	case Codesign:
+		if err := requireFormat(path, machoMagic, "программы macOS (Mach-O)"); err != nil {
+			return sig, err
+		}
+		_, err = runner.Output(signTimeout, "codesign", "--force", "--timestamp", "--options", "runtime", "--sign", s.Key, path)
+	}
+	if err != nil {
+		return sig, fmt.Errorf("%s не подписал %s: %w", s.Tool, filepath.Base(path), err)
+	}
+	return sig, nil
+}
+
+// Verify проверяет подпись sig файла path
+func Verify(path string, sig Signature) error {
+	var err error
+	switch sig.Tool {
+	case GPG:
+		if sig.File == "" {
+			return fmt.Errorf("в записи о подписи GPG нет файла подписи")
+		}
+		_, err = runner.Output(time.Minute, "gpg", "--batch", "--verify", filepath.Join(filepath.Dir(path), sig.File), path)
+	case Osslsigncode:
+		_, err = runner.Output(time.Minute, "osslsigncode", "verify", "-in", path)
+	case Codesign:
+		_, err = runner.Output(time.Minute, "codesign", "--verify", "--strict", path)
+	default:
+		return fmt.Errorf("неизвестный способ подписи %q", sig.Tool)
+	}
+	if err != nil {
+		return fmt.Errorf("подпись %s не прошла проверку: %w", sig.Tool, err)
+	}
+	return nil
+}
+
+var (
+	peMagic    = [][]byte{[]byte("MZ")}
+	machoMagic = [][]byte{
+		{0xfe, 0xed, 0xfa, 0xce}, {0xfe, 0xed, 0xfa, 0xcf}, {0xce, 0xfa, 0xed, 0xfe},
+		{0xcf, 0xfa, 0xed, 0xfe}, {0xca, 0xfe, 0xba, 0xbe},
+	}
+)
+
+// requireFormat проверяет, что файл — программа нужной платформы: Authenticode
+// и codesign подписывают только свои форматы
+func requireFormat(path string, magics [][]byte, format string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	head := make([]byte, 4)
+	n, _ := f.Read(head)
+	for _, magic := range magics {
+		if bytes.HasPrefix(head[:n], magic) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s — не %s", filepath.Base(path), format)
+}
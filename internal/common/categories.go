@@ -0,0 +1,119 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+)
+
+// mainCategories — основные категории спецификации Desktop Menu. Ярлык без
+// основной категории меню относят к «Прочим» или не показывают вовсе.
+var mainCategories = []string{
+	"AudioVideo", "Audio", "Video", "Development", "Education", "Game", "Graphics",
+	"Network", "Office", "Science", "Settings", "System", "Utility",
+}
+
+// additionalCategories — дополнительные категории спецификации
+var additionalCategories = []string{
+	"Building", "Debugger", "IDE", "GUIDesigner", "Profiling", "RevisionControl", "Translation",
+	"Calendar", "ContactManagement", "Database", "Dictionary", "Chart", "Email", "Finance",
+	"FlowChart", "PDA", "ProjectManagement", "Presentation", "Spreadsheet", "WordProcessor",
+	"2DGraphics", "VectorGraphics", "RasterGraphics", "3DGraphics", "Scanning", "OCR",
+	"Photography", "Publishing", "Viewer", "TextTools", "DesktopSettings", "HardwareSettings",
+	"Printing", "PackageManager", "Dialup", "InstantMessaging", "Chat", "IRCClient", "Feed",
+	"FileTransfer", "HamRadio", "News", "P2P", "RemoteAccess", "Telephony", "TelephonyTools",
+	"VideoConference", "WebBrowser", "WebDevelopment", "Midi", "Mixer", "Sequencer", "Tuner",
+	"TV", "AudioVideoEditing", "Player", "Recorder", "DiscBurning",
+	"ActionGame", "AdventureGame", "ArcadeGame", "BoardGame", "BlocksGame", "CardGame",
+	"KidsGame", "LogicGame", "RolePlaying", "Shooter", "Simulation", "SportsGame", "StrategyGame",
+	"Art", "Construction", "Music", "Languages", "ArtificialIntelligence", "Astronomy",
+	"Biology", "Chemistry", "ComputerScience", "DataVisualization", "Economy", "Electricity",
+	"Geography", "Geology", "Geoscience", "History", "Humanities", "ImageProcessing",
+	"Literature", "Maps", "Math", "NumericalAnalysis", "MedicalSoftware", "Physics", "Robotics",
+	"Spirituality", "Sports", "ParallelComputing", "Amusement", "Archiving", "Compression",
+	"Electronics", "Emulator", "Engineering", "FileTools", "FileManager", "TerminalEmulator",
+	"Filesystem", "Monitor", "Security", "Accessibility", "Calculator", "Clock", "TextEditor",
+	"Documentation", "Adult", "Core", "KDE", "GNOME", "XFCE", "DDE", "GTK", "Qt", "Motif",
+	"Java", "ConsoleOnly",
+}
+
+// Genres — категории для жанров из поля genre конфигурации
+var Genres = map[string][]string{
+	"action":       {"ActionGame"},
+	"adventure":    {"AdventureGame"},
+	"arcade":       {"ArcadeGame"},
+	"board":        {"BoardGame"},
+	"card":         {"CardGame"},
+	"educational":  {"Education"},
+	"emulator":     {"Emulator"},
+	"fighting":     {"ActionGame"},
+	"kids":         {"KidsGame"},
+	"platformer":   {"ActionGame", "ArcadeGame"},
+	"puzzle":       {"LogicGame"},
+	"racing":       {"SportsGame", "Simulation"},
+	"roguelike":    {"RolePlaying"},
+	"rpg":          {"RolePlaying"},
+	"shooter":      {"Shooter", "ActionGame"},
+	"simulation":   {"Simulation"},
+	"sports":       {"SportsGame"},
+	"strategy":     {"StrategyGame"},
+	"tetris":       {"BlocksGame"},
+	"visual-novel": {"AdventureGame"},
+}
+
+// canonicalCategory возвращает категорию в написании спецификации. Категории
+// расширений X-... допустимы любые.
+func canonicalCategory(category string) (string, bool) {
+	if strings.HasPrefix(category, "X-") && len(category) > 2 {
+		return category, true
+	}
+	for _, list := range [][]string{mainCategories, additionalCategories} {
+		for _, known := range list {
+			if strings.EqualFold(known, category) {
+				return known, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Categories строит значение ключа Categories из жанров genre (через запятую) и
+// категорий custom. Неизвестные жанры и категории отбрасываются и возвращаются
+// как предупреждения: одна ошибка в списке не должна прятать ярлык из меню.
+// Основная категория Game добавляется всегда.
+func Categories(genre, custom string) (string, []string) {
+	var categories, warnings []string
+	seen := make(map[string]bool)
+	add := func(category string) {
+		if !seen[category] {
+			seen[category] = true
+			categories = append(categories, category)
+		}
+	}
+
+	add("Game")
+	for _, g := range strings.Split(genre, ",") {
+		g = strings.ToLower(strings.Join(strings.Fields(g), "-"))
+		if g == "" {
+			continue
+		}
+		mapped, ok := Genres[g]
+		if !ok {
+			warnings = append(warnings, fmt.Sprintf("неизвестный жанр %q", g))
+			continue
+		}
+		for _, category := range mapped {
+			add(category)
+		}
+	}
+	// В ручном списке часто встречаются запятые и пробелы вместо ";"
+	separators := func(r rune) bool { return r == ';' || r == ',' || r == ' ' }
+	for _, category := range strings.FieldsFunc(custom, separators) {
+		known, ok := canonicalCategory(category)
+		if !ok {
+			warnings = append(warnings, fmt.Sprintf("неизвестная категория %q", category))
+			continue
+		}
+		add(known)
+	}
+	return strings.Join(categories, ";") + ";", warnings
+}
@@ -0,0 +1,131 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang-installer/internal/deploy"
+	"golang-installer/internal/runner"
+)
+
+// DefaultAppIDPrefix — префикс идентификатора ярлыка, если в конфигурации нет app_id
+const DefaultAppIDPrefix = "io.github.foxixus1.goqtinstaller."
+
+// DefaultAppID строит идентификатор ярлыка из идентификатора игры
+func DefaultAppID(slug string) string {
+	id := strings.ReplaceAll(slug, "-", "_")
+	if id[0] >= '0' && id[0] <= '9' {
+		id = "_" + id
+	}
+	return DefaultAppIDPrefix + id
+}
+
+// ValidAppID проверяет идентификатор по спецификации Desktop Entry: не меньше двух
+// элементов через точку из латинских букв, цифр, "_" и "-", элемент не начинается с цифры
+func ValidAppID(id string) bool {
+	parts := strings.Split(id, ".")
+	if len(parts) < 2 || len(id) > 255 {
+		return false
+	}
+	for _, part := range parts {
+		if part == "" || (part[0] >= '0' && part[0] <= '9') {
+			return false
+		}
+		for _, r := range part {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ApplicationsDir возвращает директорию ярлыков меню приложений пользователя или,
+// при установке для всех, общую директорию ярлыков
+func ApplicationsDir() string {
+	if SystemWide {
+		return deploy.ApplicationsDir
+	}
+	return filepath.Join(os.Getenv("HOME"), ".local", "share", "applications")
+}
+
+// DesktopDir возвращает рабочий стол пользователя или пустую строку, если его нет
+func DesktopDir() string {
+	desktopDir := filepath.Join(os.Getenv("HOME"), "Desktop")
+	if _, err := os.Stat(desktopDir); os.IsNotExist(err) {
+		// Если директория Desktop не существует, пробуем локализованное имя
+		desktopDir = filepath.Join(os.Getenv("HOME"), "Рабочий стол")
+	}
+	if _, err := os.Stat(desktopDir); err != nil {
+		return ""
+	}
+	return desktopDir
+}
+
+// DesktopQuote заключает аргумент ключа Exec в кавычки по спецификации Desktop Entry
+func DesktopQuote(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\\\`, `"`, `\\"`, "`", "\\\\`", "$", `\\$`, "%", "%%")
+	return `"` + replacer.Replace(s) + `"`
+}
+
+// WriteShortcut записывает ярлык и разрешает его запуск в GNOME
+func WriteShortcut(path, content string) error {
+	if err := ioutil.WriteFile(path, []byte(content), 0755); err != nil {
+		return err
+	}
+	runner.Run("gio", "set", path, "metadata::trusted", "yes")
+	runner.Run("gio", "set", path, "metadata::trusted", "true")
+	return nil
+}
+
+// Способы добавить в меню пункт удаления игры
+const (
+	UninstallAction = "action" // Действие «Удалить игру» в контекстном меню ярлыка игры
+	UninstallMenu   = "menu"   // Отдельный ярлык «Удалить <игра>» в разделе «Настройки»
+)
+
+// uninstallExec — ключ Exec, который открывает менеджер игр на этой игре
+func uninstallExec(info *InstallInfo) string {
+	return DesktopQuote(info.UninstallerPath) + " " + DesktopQuote("--select="+info.InstallPath)
+}
+
+// UninstallActionEntry возвращает строки, которые добавляют в конец ярлыка игры
+// действие «Удалить игру»
+func UninstallActionEntry(info *InstallInfo) string {
+	content := "Actions=uninstall;\n"
+	content += "\n[Desktop Action uninstall]\n"
+	content += "Name=Удалить игру\n"
+	content += "Name[en]=Uninstall\n"
+	content += "Exec=" + uninstallExec(info) + "\n"
+	return content
+}
+
+// UninstallShortcut возвращает отдельный ярлык удаления игры. Он лежит в разделе
+// «Настройки», чтобы не мешаться среди игр.
+func UninstallShortcut(info *InstallInfo) string {
+	content := "[Desktop Entry]\n"
+	content += "Type=Application\n"
+	content += "Name=Удалить " + info.GameName + "\n"
+	content += "Name[en]=" + info.GameName + " Uninstaller\n"
+	content += "Comment=Открыть менеджер игр на " + info.GameName + ", чтобы удалить игру\n"
+	content += "Exec=" + uninstallExec(info) + "\n"
+	if info.IconPath != "" {
+		content += "Icon=" + info.IconPath + "\n"
+	}
+	content += "Terminal=false\n"
+	content += "Categories=Settings;\n"
+	return content
+}
+
+// UninstallShortcutPath возвращает путь отдельного ярлыка удаления игры с
+// идентификатором ярлыка игры appID
+func UninstallShortcutPath(appID string) string {
+	return filepath.Join(ApplicationsDir(), appID+".Uninstaller.desktop")
+}
+
+// UpdateDesktopDatabase обновляет кэш меню приложений
+func UpdateDesktopDatabase() {
+	runner.Run("update-desktop-database", ApplicationsDir())
+}
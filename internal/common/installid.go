@@ -0,0 +1,29 @@
+package common
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+)
+
+// NewInstallID возвращает случайный UUID версии 4 для новой установки
+func NewInstallID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// SupportCode возвращает короткий код установки, например 3F2A-9C1B. Его легко
+// продиктовать поддержке, а она находит по нему полный идентификатор в журналах
+// и событиях. Для записей без идентификатора возвращает пустую строку.
+func SupportCode(id string) string {
+	hex := strings.ToUpper(strings.ReplaceAll(id, "-", ""))
+	if len(hex) < 8 {
+		return ""
+	}
+	return hex[:4] + "-" + hex[4:8]
+}
@@ -0,0 +1,192 @@
+// Package common — то, что установщик и менеджер игр должны понимать одинаково:
+// запись об установке, общий реестр и ярлыки .desktop. Раньше каждая программа
+// держала свою копию структуры записи, и копии расходились.
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang-installer/internal/cloudsave"
+	"golang-installer/internal/flatpak"
+	"golang-installer/internal/gate"
+	"golang-installer/internal/maintenance"
+	"golang-installer/internal/pin"
+	"golang-installer/internal/receipt"
+	"golang-installer/internal/signature"
+	"golang-installer/internal/snapshot"
+	"golang-installer/internal/survey"
+	"golang-installer/internal/timeline"
+)
+
+// SystemRegistryDir — реестр игр, установленных администратором для всех пользователей
+const SystemRegistryDir = "/var/lib/go-qt-installer/registry"
+
+// SystemWide включает установку для всех пользователей: записи попадают в общий
+// для компьютера реестр, а ярлыки меню — в /usr/local/share/applications
+var SystemWide bool
+
+// SentinelFileName — файл-метка, по которой менеджер узнает директорию, созданную установщиком
+const SentinelFileName = ".go-qt-installer"
+
+// InstallInfo — запись об установке: лежит в logs директории игры и в общем реестре
+type InstallInfo struct {
+	SchemaVersion     int                 `json:"schema_version"`
+	GameName          string              `json:"game_name"`
+	InstallPath       string              `json:"install_path"`
+	InstallDate       time.Time           `json:"install_date"`
+	DesktopFile       string              `json:"desktop_file"`
+	MenuFile          string              `json:"menu_file"`
+	InstallerPath     string              `json:"installer_path"`
+	InstallerDir      string              `json:"installer_dir"`
+	UninstallerPath   string              `json:"uninstaller_path"`
+	Version           string              `json:"version,omitempty"`
+	BannerPath        string              `json:"banner_path,omitempty"` // Копия баннера для менеджера
+	ExecPath          string              `json:"exec_path,omitempty"`   // Полный путь к исполняемому файлу игры
+	IconPath          string              `json:"icon_path,omitempty"`   // Иконка, использованная в ярлыках
+	RegistryFile      string              `json:"registry_file,omitempty"`
+	Slug              string              `json:"slug,omitempty"`                // Идентификатор игры в именах файлов
+	AppID             string              `json:"app_id,omitempty"`              // Идентификатор .desktop в стиле обратного DNS
+	WMClass           string              `json:"wm_class,omitempty"`            // Класс окна игры для StartupWMClass
+	Categories        string              `json:"categories,omitempty"`          // Значение Categories ярлыка
+	WorkDir           string              `json:"work_dir,omitempty"`            // Рабочая директория игры
+	LaunchArgs        []string            `json:"launch_args,omitempty"`         // Аргументы запуска
+	LaunchEnv         []string            `json:"launch_env,omitempty"`          // Переменные окружения "ИМЯ=значение"
+	Options           map[string]string   `json:"options,omitempty"`             // Значения полей с дополнительных страниц
+	History           []VersionEntry      `json:"history,omitempty"`             // Ранее установленные версии, от старых к новым
+	Snapshot          *snapshot.Volume    `json:"snapshot,omitempty"`            // Подтом btrfs или набор данных ZFS со снимками игры
+	Receipt           *receipt.Receipt    `json:"receipt,omitempty"`             // Пакет-квитанция в пакетной базе дистрибутива
+	Flatpak           *flatpak.Export     `json:"flatpak,omitempty"`             // Приложение Flatpak, в которое упакована игра
+	MountPoint        string              `json:"mount_point,omitempty"`         // Точка монтирования отдельного диска с игрой
+	InstallID         string              `json:"install_id,omitempty"`          // UUID установки, сохраняется при обновлениях; по нему поддержка находит установку в журналах и событиях
+	InstallToken      string              `json:"-"`                             // Токен для привязки установки на сайте издателя, хранится в связке ключей
+	Secrets           []string            `json:"secrets,omitempty"`             // Ключи секретов игры в связке ключей; сами секреты в запись не попадают
+	Webhooks          []string            `json:"webhooks,omitempty"`            // Адреса уведомлений из конфигурации, используются при удалении
+	SupportURL        string              `json:"support_url,omitempty"`         // Адрес для отчетов в поддержку из конфигурации
+	Provisioning      string              `json:"provisioning,omitempty"`        // Ярлык, который раздается на рабочие столы всех пользователей
+	Pin               *pin.Record         `json:"pin,omitempty"`                 // Где ярлык закреплен: избранное KDE или папка приложений GNOME
+	CloudSave         *cloudsave.Record   `json:"cloud_save,omitempty"`          // Облачные сохранения: хранилище и хэш последней синхронизации
+	Gate              *gate.Confirmation  `json:"gate,omitempty"`                // Подтверждение возраста и региона перед установкой
+	UpdateManifest    string              `json:"update_manifest,omitempty"`     // Манифест последней версии: по нему служба менеджера ищет обновления
+	UserData          []string            `json:"user_data,omitempty"`           // Шаблоны файлов пользователя из конфигурации: проверка файлов их не трогает
+	Maintenance       *maintenance.Config `json:"maintenance,omitempty"`         // Кэши шейдеров и файлы настроек игры для обслуживания из менеджера
+	Survey            *survey.Config      `json:"survey,omitempty"`              // Опрос при удалении из конфигурации
+	UninstallEntry    string              `json:"uninstall_entry,omitempty"`     // Пункт удаления игры в меню: action или menu
+	UninstallMenuFile string              `json:"uninstall_menu_file,omitempty"` // Отдельный ярлык удаления игры
+	Imported          bool                `json:"imported,omitempty"`            // Игра добавлена в менеджер, а не установлена установщиком: удаление требует подтверждения
+	Events            []timeline.Event    `json:"events,omitempty"`              // Ключевые события установок с временем, для поддержки
+	Signature         string              `json:"signature,omitempty"`           // HMAC-подпись для обнаружения изменений
+}
+
+// VersionEntry — версия игры, установленная в эту директорию ранее
+type VersionEntry struct {
+	Version string    `json:"version"`
+	Date    time.Time `json:"date"`
+}
+
+// RegistryDir возвращает директорию общего реестра установленных игр
+func RegistryDir() string {
+	if SystemWide {
+		return SystemRegistryDir
+	}
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(os.Getenv("HOME"), ".local", "share")
+	}
+	return filepath.Join(dataHome, "go-qt-installer", "registry")
+}
+
+// SecretKey возвращает ключ секрета name игры slug в связке ключей
+func SecretKey(slug, name string) string {
+	return slug + " " + name
+}
+
+// InstallTokenSecret — имя секрета с токеном установки
+const InstallTokenSecret = "install_token"
+
+// CloudSaveSecret — имя секрета с ключом или паролем хранилища облачных сохранений
+const CloudSaveSecret = "cloud_save"
+
+// RecordName возвращает имя файла записи для идентификатора игры
+func RecordName(slug string) string {
+	return slug + "-install.json"
+}
+
+// GameSlug возвращает идентификатор, под которым установщик сохранил файлы игры.
+// Старые установщики не записывали его и строили имя из названия игры.
+func GameSlug(info *InstallInfo) string {
+	if info.Slug != "" {
+		return info.Slug
+	}
+	return strings.ReplaceAll(strings.ToLower(info.GameName), " ", "-")
+}
+
+// RegistryPath возвращает файл игры в общем реестре
+func RegistryPath(info *InstallInfo) string {
+	if info.RegistryFile != "" {
+		// Записи игр, установленных для всех, остаются в реестре компьютера
+		if filepath.Dir(info.RegistryFile) == SystemRegistryDir {
+			return info.RegistryFile
+		}
+		return filepath.Join(RegistryDir(), filepath.Base(info.RegistryFile))
+	}
+	return filepath.Join(RegistryDir(), RecordName(GameSlug(info)))
+}
+
+// Load читает запись об установке и приводит ее к текущей версии формата
+func Load(filePath string) (*InstallInfo, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при чтении файла %s: %v", filePath, err)
+	}
+	if data, _, err = Migrate(data); err != nil {
+		return nil, err
+	}
+	var info InstallInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("ошибка при разборе JSON: %v", err)
+	}
+	// Токен записи версии 1, который еще не перенесен в связку ключей
+	var legacy struct {
+		Token string `json:"install_token"`
+	}
+	json.Unmarshal(data, &legacy)
+	info.InstallToken = legacy.Token
+	return &info, nil
+}
+
+// Marshal подписывает запись и возвращает ее в виде JSON для записи на диск
+func Marshal(info *InstallInfo) ([]byte, error) {
+	info.SchemaVersion = SchemaVersion
+	info.Signature = ""
+	data, err := json.Marshal(info)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при сериализации информации об установке: %v", err)
+	}
+	// Подпись позволяет менеджеру обнаружить изменение записи
+	info.Signature, err = signature.Sign(data)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при подписи информации об установке: %v", err)
+	}
+	return json.MarshalIndent(info, "", "  ")
+}
+
+// Resign подписывает JSON записи ключом текущего пользователя, сохраняя поля,
+// которых нет в InstallInfo
+func Resign(data []byte) ([]byte, error) {
+	sig, err := signature.Sign(data)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	fields[signature.Field], _ = json.Marshal(sig)
+	return json.MarshalIndent(fields, "", "  ")
+}
@@ -0,0 +1,65 @@
+package common
+
+import (
+	"os"
+	"strings"
+
+	"golang-installer/internal/desktopfile"
+)
+
+// RebasePath переносит путь из старой директории установки в новую
+func RebasePath(path, oldRoot, newRoot string) string {
+	if path == oldRoot || strings.HasPrefix(path, oldRoot+string(os.PathSeparator)) {
+		return newRoot + strings.TrimPrefix(path, oldRoot)
+	}
+	return path
+}
+
+// RebaseShortcut переносит пути игры в ключах Exec, TryExec, Path и Icon ярлыка.
+// Остальные строки и пути, которые только начинаются так же, как директория игры,
+// не меняются.
+func RebaseShortcut(content, oldRoot, newRoot string) string {
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "TryExec", "Path", "Icon":
+			lines[i] = key + "=" + RebasePath(value, oldRoot, newRoot)
+		case "Exec":
+			lines[i] = key + "=" + rebaseExec(value, oldRoot, newRoot)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// rebaseExec переносит пути игры в словах ключа Exec, в том числе в значениях
+// ИМЯ=путь и --ключ=путь. Если путей игры нет, значение остается как было.
+func rebaseExec(value, oldRoot, newRoot string) string {
+	args := desktopfile.Group{"Exec": value}.Args()
+	changed := false
+	for i, arg := range args {
+		rebased := RebasePath(arg, oldRoot, newRoot)
+		if name, p, ok := strings.Cut(arg, "="); ok && rebased == arg {
+			rebased = name + "=" + RebasePath(p, oldRoot, newRoot)
+		}
+		if rebased != arg {
+			args[i], changed = rebased, true
+		}
+	}
+	if !changed {
+		return value
+	}
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		// Коды полей вроде %U остаются без кавычек
+		if len(arg) == 2 && arg[0] == '%' {
+			quoted[i] = arg
+			continue
+		}
+		quoted[i] = DesktopQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
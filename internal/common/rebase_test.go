@@ -0,0 +1,80 @@
+package common
+
+import "testing"
+
+func TestRebasePath(t *testing.T) {
+	for _, tt := range []struct {
+		path string
+		want string
+	}{
+		{"/games/foo", "/mnt/foo"},
+		{"/games/foo/bin/game", "/mnt/foo/bin/game"},
+		{"/games/foobar/bin/game", "/games/foobar/bin/game"},
+		{"/games/foo.bak", "/games/foo.bak"},
+		{"/games", "/games"},
+		{"games/foo/bin", "games/foo/bin"},
+		{"", ""},
+	} {
+		if got := RebasePath(tt.path, "/games/foo", "/mnt/foo"); got != tt.want {
+			t.Errorf("RebasePath(%q) = %q, ожидалось %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRebaseExec(t *testing.T) {
+	for _, tt := range []struct {
+		value string
+		want  string
+	}{
+		{`/games/foo/start.sh`, `"/mnt/foo/start.sh"`},
+		{`"/games/foo/start.sh" %U`, `"/mnt/foo/start.sh" %U`},
+		{`"/games/foo/My Game/run.sh" --fullscreen`, `"/mnt/foo/My Game/run.sh" "--fullscreen"`},
+		{`env WINEPREFIX=/games/foo/prefix wine "/games/foo/game.exe"`, `"env" "WINEPREFIX=/mnt/foo/prefix" "wine" "/mnt/foo/game.exe"`},
+		{`/games/foo/run --data=/games/foo/data`, `"/mnt/foo/run" "--data=/mnt/foo/data"`},
+		// Экранирование внутри кавычек сохраняется
+		{`"/games/foo/run" "cost \\$5"`, `"/mnt/foo/run" "cost \\$5"`},
+		// Путь соседней игры с тем же началом имени не трогается, значение остается как было
+		{`"/games/foobar/start.sh"  %F`, `"/games/foobar/start.sh"  %F`},
+		{`steam steam://rungameid/42`, `steam steam://rungameid/42`},
+	} {
+		if got := rebaseExec(tt.value, "/games/foo", "/mnt/foo"); got != tt.want {
+			t.Errorf("rebaseExec(%q) = %q, ожидалось %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestRebaseShortcut(t *testing.T) {
+	content := `[Desktop Entry]
+Type=Application
+Name=/games/foo
+Comment=Игра из /games/foo/readme.txt
+Exec="/games/foo/start.sh" %U
+TryExec=/games/foo/start.sh
+Path=/games/foo
+Icon=/games/foo/icon.png
+X-Install-Path=/games/foo
+URL=file:///games/foo/manual.html
+
+[Desktop Action sibling]
+Exec=/games/foobar/start.sh
+Icon=/games/foobar/icon.png
+`
+	want := `[Desktop Entry]
+Type=Application
+Name=/games/foo
+Comment=Игра из /games/foo/readme.txt
+Exec="/mnt/foo/start.sh" %U
+TryExec=/mnt/foo/start.sh
+Path=/mnt/foo
+Icon=/mnt/foo/icon.png
+X-Install-Path=/games/foo
+URL=file:///games/foo/manual.html
+
+[Desktop Action sibling]
+Exec=/games/foobar/start.sh
+Icon=/games/foobar/icon.png
+`
+	if got := RebaseShortcut(content, "/games/foo", "/mnt/foo"); got != want {
+		t.Errorf("RebaseShortcut:\n%s\nожидалось:\n%s", got, want)
+	}
+}
@@ -0,0 +1,172 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang-installer/internal/keyring"
+	"golang-installer/internal/signature"
+)
+
+// SchemaVersion — версия формата записи об установке. Записи старых установщиков
+// не содержат schema_version и считаются версией 0.
+const SchemaVersion = 2
+
+// schemaField — имя поля с версией формата
+const schemaField = "schema_version"
+
+// migrations[i] переводит запись из версии i в версию i+1. Миграции работают с полями
+// JSON, а не со структурой, чтобы не терять поля, о которых эта версия не знает.
+// Миграции ничего не меняют вне записи; false означает, что запись останется
+// в версии i, пока нужный шаг не сделает Upgrade.
+var migrations = []func(fields map[string]json.RawMessage) bool{
+	migrateV0,
+	migrateV1,
+}
+
+// migrateV0 дополняет записи установщиков, которые еще не сохраняли идентификаторы
+// игры и ярлыка и путь к деинсталлятору
+func migrateV0(fields map[string]json.RawMessage) bool {
+	var name, installPath, menuFile string
+	json.Unmarshal(fields["game_name"], &name)
+	json.Unmarshal(fields["install_path"], &installPath)
+	json.Unmarshal(fields["menu_file"], &menuFile)
+
+	if missing(fields, "slug") {
+		// Старые установщики строили имена файлов из названия игры
+		fields["slug"], _ = json.Marshal(strings.ReplaceAll(strings.ToLower(name), " ", "-"))
+	}
+	if missing(fields, "app_id") && menuFile != "" {
+		fields["app_id"], _ = json.Marshal(strings.TrimSuffix(filepath.Base(menuFile), ".desktop"))
+	}
+	if missing(fields, "uninstaller_path") && installPath != "" {
+		fields["uninstaller_path"], _ = json.Marshal(filepath.Join(installPath, "uninstaller"))
+	}
+	return true
+}
+
+// migrateV1 пропускает записи без токена установки. Запись с токеном остается
+// в версии 1, пока Upgrade не перенесет токен в связку ключей.
+func migrateV1(fields map[string]json.RawMessage) bool {
+	return missing(fields, InstallTokenSecret)
+}
+
+// moveInstallToken переносит токен установки из записи версии 1 в связку ключей.
+// Если связка ключей недоступна, токен остается в записи: потерять его хуже, чем
+// оставить открытым.
+func moveInstallToken(data []byte) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("ошибка при разборе JSON: %v", err)
+	}
+	var token, slug string
+	json.Unmarshal(fields[InstallTokenSecret], &token)
+	json.Unmarshal(fields["slug"], &slug)
+	if slug == "" {
+		return nil, fmt.Errorf("в записи нет идентификатора игры")
+	}
+	if err := keyring.Set(SecretKey(slug, InstallTokenSecret), "Токен установки "+slug, token); err != nil {
+		return nil, err
+	}
+	var secrets []string
+	json.Unmarshal(fields["secrets"], &secrets)
+	fields["secrets"], _ = json.Marshal(append(secrets, SecretKey(slug, InstallTokenSecret)))
+	delete(fields, InstallTokenSecret)
+	return json.Marshal(fields)
+}
+
+// tokenFailed — записи, токен которых уже не удалось перенести в этом запуске.
+// Связка ключей не появится до конца сеанса, а каждая попытка ждет secret-tool.
+var tokenFailed sync.Map
+
+func missing(fields map[string]json.RawMessage, key string) bool {
+	var s string
+	raw, ok := fields[key]
+	return !ok || json.Unmarshal(raw, &s) != nil || s == ""
+}
+
+// fieldsOf разбирает JSON записи на поля; ошибку разбора уже вернул Migrate
+func fieldsOf(data []byte) map[string]json.RawMessage {
+	var fields map[string]json.RawMessage
+	json.Unmarshal(data, &fields)
+	return fields
+}
+
+// recordVersion возвращает версию формата записи
+func recordVersion(fields map[string]json.RawMessage) int {
+	var version int
+	json.Unmarshal(fields[schemaField], &version)
+	return version
+}
+
+// Migrate приводит JSON записи к текущей версии формата, насколько это возможно
+// без изменений вне записи. Второе значение сообщает, что запись изменилась.
+// Записи новее текущей версии возвращаются как есть: поля, которые понимает эта
+// версия, в них сохраняются. Migrate вызывается при каждом чтении записи, поэтому
+// ничего не запускает.
+func Migrate(data []byte) ([]byte, bool, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, false, fmt.Errorf("ошибка при разборе JSON: %v", err)
+	}
+	start := recordVersion(fields)
+	if start > SchemaVersion {
+		log.Printf("Запись об установке версии %d новее поддерживаемой (%d)", start, SchemaVersion)
+		return data, false, nil
+	}
+	version := start
+	for version < SchemaVersion && migrations[version](fields) {
+		version++
+	}
+	if version == start {
+		return data, false, nil
+	}
+	fields[schemaField], _ = json.Marshal(version)
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return nil, false, err
+	}
+	return out, true, nil
+}
+
+// Upgrade переписывает файл записи в текущем формате и переносит токен установки
+// в связку ключей. Версия записи повышается, только если токен сохранен. Запись
+// с неверной подписью или без подписи не трогается: переподписав ее, менеджер
+// выдал бы ручные изменения за сделанные установщиком.
+func Upgrade(filePath string) error {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	migrated, changed, err := Migrate(data)
+	if err != nil {
+		return err
+	}
+	if err := signature.Verify(data); err != nil {
+		return nil
+	}
+	if _, failed := tokenFailed.Load(filePath); !failed && recordVersion(fieldsOf(migrated)) == 1 {
+		if moved, err := moveInstallToken(migrated); err != nil {
+			log.Printf("Токен установки из %s не перенесен в связку ключей: %v", filePath, err)
+			tokenFailed.Store(filePath, true)
+		} else if migrated, _, err = Migrate(moved); err != nil {
+			return err
+		} else {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	record, err := Resign(migrated)
+	if err != nil {
+		return err
+	}
+	log.Printf("Запись %s переведена в формат версии %d", filePath, SchemaVersion)
+	return ioutil.WriteFile(filePath, record, 0644)
+}
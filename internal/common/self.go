@@ -0,0 +1,30 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Executable возвращает путь к запущенной программе с раскрытыми символьными
+// ссылками. По os.Args[0] его не найти: при запуске через PATH или из
+// файлового менеджера там только имя программы, а ссылка в ~/.local/bin
+// указывает не туда, где лежат файлы пакета.
+func Executable() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	return exe, nil
+}
+
+// ExecutableDir возвращает директорию запущенной программы или пустую строку
+func ExecutableDir() string {
+	exe, err := Executable()
+	if err != nil {
+		return ""
+	}
+	return filepath.Dir(exe)
+}
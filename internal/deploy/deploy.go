@@ -0,0 +1,107 @@
+// Package deploy устанавливает игру один раз для всех пользователей компьютера —
+// для компьютерных классов и клубов. Игра ставится в /opt/games, ярлык меню —
+// в /usr/local/share/applications, а ярлык на рабочий стол каждый пользователь
+// получает при входе в систему: скрипт из автозапуска копирует ярлыки всех игр,
+// установленных для всех, и убирает ярлыки удаленных игр.
+package deploy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// Root — директория игр, установленных для всех пользователей
+	Root = "/opt/games"
+	// ApplicationsDir — ярлыки меню, общие для всех пользователей
+	ApplicationsDir = "/usr/local/share/applications"
+
+	shareDir      = "/usr/local/share/go-qt-installer"
+	scriptPath    = shareDir + "/provision-shortcuts.sh"
+	autostartPath = "/etc/xdg/autostart/go-qt-installer-provision.desktop"
+)
+
+// ShortcutsDir — ярлыки, которые раздаются пользователям на рабочий стол
+var ShortcutsDir = filepath.Join(shareDir, "desktop")
+
+// script копирует ярлыки на рабочий стол пользователя. Список розданных ярлыков
+// хранится у пользователя: удаленный им ярлык не появляется снова, а ярлык удаленной
+// игры убирается с рабочего стола.
+const script = `#!/bin/sh
+src="` + shareDir + `/desktop"
+desktop=$(xdg-user-dir DESKTOP 2>/dev/null)
+[ -n "$desktop" ] || desktop="$HOME/Desktop"
+state="${XDG_DATA_HOME:-$HOME/.local/share}/go-qt-installer"
+list="$state/provisioned-shortcuts"
+mkdir -p "$state" "$desktop" || exit 0
+touch "$list"
+
+for file in "$src"/*.desktop; do
+	[ -e "$file" ] || continue
+	name=$(basename "$file")
+	grep -qxF "$name" "$list" && continue
+	cp "$file" "$desktop/$name" && chmod 755 "$desktop/$name"
+	gio set "$desktop/$name" metadata::trusted true 2>/dev/null
+	echo "$name" >> "$list"
+done
+
+kept=""
+while read -r name; do
+	if [ -e "$src/$name" ]; then
+		kept="$kept$name
+"
+	else
+		rm -f "$desktop/$name"
+	fi
+done < "$list"
+printf '%s' "$kept" > "$list"
+`
+
+const autostart = `[Desktop Entry]
+Type=Application
+Name=Ярлыки игр
+Exec=` + scriptPath + `
+NoDisplay=true
+X-GNOME-Autostart-Phase=Applications
+`
+
+// Available сообщает, можно ли установить игру для всех: нужны права root
+func Available() bool {
+	return os.Geteuid() == 0
+}
+
+// Provision раздает ярлык content пользователям под именем appID.desktop. Сразу
+// ярлык появится у пользователей, которые войдут в систему позже; у того, кто
+// запустил установщик через sudo, — при следующем входе. Возвращает созданный файл.
+func Provision(appID, content string) (string, error) {
+	if err := os.MkdirAll(ShortcutsDir, 0755); err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(scriptPath, []byte(script), 0755); err != nil {
+		return "", fmt.Errorf("не удалось создать скрипт раздачи ярлыков: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(autostartPath), 0755); err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(autostartPath, []byte(autostart), 0644); err != nil {
+		return "", fmt.Errorf("не удалось добавить раздачу ярлыков в автозапуск: %v", err)
+	}
+
+	path := filepath.Join(ShortcutsDir, appID+".desktop")
+	if err := ioutil.WriteFile(path, []byte(content), 0755); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Unprovision перестает раздавать ярлык: при следующем входе пользователей он исчезнет
+// с их рабочих столов. Скрипт остается в автозапуске — он убирает ярлыки удаленных
+// игр у тех, кто еще не входил в систему после удаления.
+func Unprovision(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,377 @@
+// Package desktopfile читает и проверяет ярлыки .desktop так, как их видит меню
+// приложений: по спецификациям Desktop Entry, Icon Theme и Menu. Менеджер игр
+// использует его, чтобы объяснить, почему ярлык игры не появился в меню.
+package desktopfile
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang-installer/internal/runner"
+)
+
+// Group — ключи группы [Desktop Entry]
+type Group map[string]string
+
+// Parse читает группу [Desktop Entry]. Ярлык без нее считается испорченным.
+func Parse(path string) (Group, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	group, current := Group{}, ""
+	found := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			current = line[1 : len(line)-1]
+			found = found || current == "Desktop Entry"
+			continue
+		}
+		if current != "Desktop Entry" {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok {
+			group[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("в %s нет группы [Desktop Entry]", path)
+	}
+	return group, nil
+}
+
+// Bool возвращает логическое значение ключа
+func (g Group) Bool(key string) bool {
+	return g[key] == "true"
+}
+
+// Program возвращает программу из ключа Exec без кавычек и экранирования
+func (g Group) Program() string {
+	if args := g.Args(); len(args) > 0 {
+		return args[0]
+	}
+	return ""
+}
+
+// Args возвращает ключ Exec по словам без кавычек и экранирования
+func (g Group) Args() []string {
+	// Сначала снимается экранирование строкового значения, затем кавычки Exec
+	value := strings.ReplaceAll(g["Exec"], `\\`, `\`)
+	var args []string
+	var b strings.Builder
+	quoted, escaped, started := false, false, false
+	for _, r := range value {
+		switch {
+		case escaped:
+			b.WriteRune(r)
+			escaped = false
+		case quoted && r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+			started = true
+		case !quoted && (r == ' ' || r == '\t'):
+			if started {
+				args = append(args, strings.ReplaceAll(b.String(), "%%", "%"))
+				b.Reset()
+				started = false
+			}
+		default:
+			b.WriteRune(r)
+			started = true
+		}
+	}
+	if started {
+		args = append(args, strings.ReplaceAll(b.String(), "%%", "%"))
+	}
+	return args
+}
+
+// Target возвращает программу, которую запустит ярлык: для Exec вида
+// env ИМЯ=значение … программа это первое слово после переменных
+func (g Group) Target() string {
+	args := g.Args()
+	if len(args) > 0 && filepath.Base(args[0]) == "env" {
+		args = args[1:]
+		for len(args) > 0 && strings.Contains(args[0], "=") && !strings.HasPrefix(args[0], "-") {
+			args = args[1:]
+		}
+	}
+	if len(args) == 0 {
+		return ""
+	}
+	return args[0]
+}
+
+// Validate проверяет ярлык и возвращает найденные ошибки. Если установлен
+// desktop-file-validate, учитываются и его замечания.
+func Validate(path string) []string {
+	group, err := Parse(path)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	var issues []string
+	for _, key := range []string{"Type", "Name"} {
+		if group[key] == "" {
+			issues = append(issues, "нет ключа "+key)
+		}
+	}
+	if group["Type"] == "Application" {
+		if program := group.Target(); program == "" {
+			issues = append(issues, "нет ключа Exec")
+		} else if _, err := lookProgram(program); err != nil {
+			issues = append(issues, "программа из Exec не найдена: "+program)
+		}
+	}
+	if dir := group["Path"]; dir != "" {
+		if _, err := os.Stat(dir); err != nil {
+			issues = append(issues, "рабочая директория из Path не найдена: "+dir)
+		}
+	}
+
+	out, err := runner.Output(10*time.Second, "desktop-file-validate", path)
+	if err == nil || len(out) > 0 {
+		for _, line := range strings.Split(string(out), "\n") {
+			// Подсказки (hint) не мешают показу ярлыка
+			if strings.Contains(line, ": error: ") {
+				issues = append(issues, strings.TrimSpace(line[strings.Index(line, ": error: ")+2:]))
+			}
+		}
+	}
+	return issues
+}
+
+// lookProgram ищет программу по пути или в PATH
+func lookProgram(program string) (string, error) {
+	if strings.Contains(program, "/") {
+		info, err := os.Stat(program)
+		if err != nil {
+			return "", err
+		}
+		if info.IsDir() || info.Mode().Perm()&0111 == 0 {
+			return "", fmt.Errorf("%s не исполняемый", program)
+		}
+		return program, nil
+	}
+	return exec.LookPath(program)
+}
+
+// dataHome возвращает $XDG_DATA_HOME или ~/.local/share
+func dataHome() string {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.Getenv("HOME"), ".local", "share")
+}
+
+// DataDirs возвращает директории данных в порядке приоритета: пользовательская,
+// затем $XDG_DATA_DIRS
+func DataDirs() []string {
+	dirs := []string{dataHome()}
+	system := os.Getenv("XDG_DATA_DIRS")
+	if system == "" {
+		system = "/usr/local/share:/usr/share"
+	}
+	for _, dir := range filepath.SplitList(system) {
+		if dir != "" {
+			dirs = append(dirs, filepath.Clean(dir))
+		}
+	}
+	return dirs
+}
+
+// iconExtensions — форматы иконок, которые понимают меню
+var iconExtensions = []string{".png", ".svg", ".xpm"}
+
+// ResolveIcon ищет файл иконки из ключа Icon: абсолютный путь проверяется как
+// есть, имя ищется в темах иконок и в pixmaps. Возвращает найденный файл.
+func ResolveIcon(icon string) (string, error) {
+	if icon == "" {
+		return "", fmt.Errorf("иконка не указана")
+	}
+	if filepath.IsAbs(icon) {
+		if _, err := os.Stat(icon); err != nil {
+			return "", err
+		}
+		return icon, nil
+	}
+	roots := []string{filepath.Join(os.Getenv("HOME"), ".icons")}
+	for _, dir := range DataDirs() {
+		roots = append(roots, filepath.Join(dir, "icons"))
+	}
+	for _, root := range roots {
+		for _, ext := range iconExtensions {
+			// Тема/размер/категория/имя, например hicolor/48x48/apps/game.png
+			if matches, _ := filepath.Glob(filepath.Join(root, "*", "*", "*", icon+ext)); len(matches) > 0 {
+				return matches[0], nil
+			}
+		}
+	}
+	for _, ext := range iconExtensions {
+		for _, path := range []string{filepath.Join(roots[1], icon+ext), filepath.Join("/usr/share/pixmaps", icon+ext)} {
+			if _, err := os.Stat(path); err == nil {
+				return path, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("иконка %s не найдена в темах иконок", icon)
+}
+
+// ID возвращает идентификатор ярлыка по спецификации Menu: путь относительно
+// директории applications с "-" вместо "/". Ярлык вне этих директорий меню не видит.
+func ID(path string) (string, bool) {
+	for _, dir := range DataDirs() {
+		rel, err := filepath.Rel(filepath.Join(dir, "applications"), path)
+		if err == nil && !strings.HasPrefix(rel, "..") {
+			return strings.ReplaceAll(rel, string(os.PathSeparator), "-"), true
+		}
+	}
+	return "", false
+}
+
+// Visible проверяет, что меню покажет ярлык: он лежит в директории, где меню
+// ищет ярлыки, не перекрыт ярлыком с тем же идентификатором, не скрыт и
+// предназначен для текущего рабочего стола
+func Visible(path string) error {
+	id, ok := ID(path)
+	if !ok {
+		return fmt.Errorf("%s вне директорий, где меню ищет ярлыки (%s)", filepath.Dir(path), strings.Join(DataDirs(), ":"))
+	}
+	// Из ярлыков с одинаковым идентификатором меню берет первый по приоритету
+	for _, dir := range DataDirs() {
+		applications := filepath.Join(dir, "applications")
+		if strings.HasPrefix(path, applications+string(os.PathSeparator)) {
+			break
+		}
+		if other := filepath.Join(applications, id); fileExists(other) {
+			return fmt.Errorf("ярлык перекрыт файлом %s с тем же идентификатором", other)
+		}
+	}
+
+	group, err := Parse(path)
+	if err != nil {
+		return err
+	}
+	if group.Bool("Hidden") {
+		return fmt.Errorf("в ярлыке задано Hidden=true")
+	}
+	if group.Bool("NoDisplay") {
+		return fmt.Errorf("в ярлыке задано NoDisplay=true")
+	}
+	if tryExec := group["TryExec"]; tryExec != "" {
+		if _, err := lookProgram(tryExec); err != nil {
+			return fmt.Errorf("программа из TryExec не найдена: %s", tryExec)
+		}
+	}
+	desktops := strings.Split(os.Getenv("XDG_CURRENT_DESKTOP"), ":")
+	if only := group["OnlyShowIn"]; only != "" && !listed(only, desktops) {
+		return fmt.Errorf("ярлык показывается только в %s", strings.TrimSuffix(only, ";"))
+	}
+	if not := group["NotShowIn"]; not != "" && listed(not, desktops) {
+		return fmt.Errorf("ярлык скрыт в %s", strings.TrimSuffix(not, ";"))
+	}
+	return nil
+}
+
+// Problem — почему ярлык не сработает и что с этим сделать
+type Problem struct {
+	Issue string
+	Hint  string
+}
+
+// Verify проверяет только что записанный ярлык так, как его откроет меню:
+// программа из Exec есть и запускается, иконка находится, ярлык виден в меню и,
+// если он открывает файлы, попал в базу desktop-file-database. Кэш меню к этому
+// времени уже должен быть обновлен.
+func Verify(path string) []Problem {
+	group, err := Parse(path)
+	if err != nil {
+		return []Problem{{Issue: err.Error(), Hint: "ярлык испорчен: переустановите игру или нажмите «Нет в меню?» в менеджере игр"}}
+	}
+
+	var problems []Problem
+	if group["Type"] == "Application" {
+		program := group.Target()
+		info, err := os.Stat(program)
+		switch {
+		case program == "":
+			problems = append(problems, Problem{"в ярлыке не указана программа", "в установщике не задан исполняемый файл игры: сообщите издателю"})
+		case !strings.Contains(program, "/"):
+			if _, err := exec.LookPath(program); err != nil {
+				problems = append(problems, Problem{"программа " + program + " не найдена в PATH", "установите " + program + " из репозитория дистрибутива"})
+			}
+		case err != nil:
+			problems = append(problems, Problem{"файл игры не найден: " + program,
+				"проверьте файлы игры в менеджере игр или переустановите игру; если файла нет в раздаче, сообщите издателю"})
+		case info.IsDir() || info.Mode().Perm()&0111 == 0:
+			problems = append(problems, Problem{"файл игры не исполняемый: " + program,
+				"разрешите запуск: chmod +x " + program + "; на разделах с noexec игру нужно установить в другую директорию"})
+		}
+	}
+	if icon := group["Icon"]; icon != "" {
+		if _, err := ResolveIcon(icon); err != nil {
+			problems = append(problems, Problem{err.Error(), "меню покажет игру со стандартной иконкой; иконку вернет переустановка игры"})
+		}
+	}
+	if err := Visible(path); err != nil {
+		problems = append(problems, Problem{err.Error(), "нажмите «Нет в меню?» в менеджере игр: он пересоздаст ярлык и обновит кэш меню"})
+	} else if group["MimeType"] != "" {
+		id, _ := ID(path)
+		cache, err := ioutil.ReadFile(filepath.Join(filepath.Dir(path), "mimeinfo.cache"))
+		if err != nil || !strings.Contains(string(cache), id) {
+			problems = append(problems, Problem{"ярлыка " + id + " нет в базе типов файлов",
+				"установите update-desktop-database (пакет desktop-file-utils) и нажмите «Нет в меню?» в менеджере игр"})
+		}
+	}
+	return problems
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// listed сообщает, что в списке ключа через ";" есть один из рабочих столов
+func listed(list string, desktops []string) bool {
+	for _, item := range strings.Split(list, ";") {
+		for _, desktop := range desktops {
+			if item != "" && strings.EqualFold(item, desktop) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Trusted сообщает, что GNOME разрешает запуск ярлыка на рабочем столе. Без gio
+// проверить это нельзя, и ярлык считается доверенным.
+func Trusted(path string) (bool, error) {
+	out, err := runner.Output(10*time.Second, "gio", "info", "-a", "metadata::trusted", path)
+	if err != nil {
+		return true, err
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(line), "metadata::trusted:"); ok {
+			value = strings.TrimSpace(value)
+			return value == "yes" || value == "true", nil
+		}
+	}
+	return false, nil
+}
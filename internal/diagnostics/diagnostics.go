@@ -0,0 +1,241 @@
+// Package diagnostics проверяет окружение, от которого зависит появление ярлыков:
+// служебные программы, переменные XDG, права на запись в директории ярлыков и
+// иконок, тип графической сессии и локаль. Результат показывается в окне
+// диагностики и прикладывается к отчету для поддержки, чтобы жалоба «ярлык не
+// появился» приходила вместе с причиной.
+package diagnostics
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang-installer/internal/common"
+	"golang-installer/internal/deploy"
+)
+
+// Status — итог проверки
+type Status int
+
+const (
+	Pass Status = iota
+	Warn        // Работает, но часть возможностей недоступна
+	Fail        // Ярлыки или установка работать не будут
+)
+
+// String возвращает метку итога для текстового отчета
+func (s Status) String() string {
+	switch s {
+	case Warn:
+		return "ПРЕДУПРЕЖДЕНИЕ"
+	case Fail:
+		return "ОШИБКА"
+	}
+	return "OK"
+}
+
+// Check — одна проверка
+type Check struct {
+	Name   string
+	Status Status
+	Detail string // Что найдено
+	Hint   string // Что сделать, если проверка не пройдена
+}
+
+// tools — программы, которые установщик вызывает для ярлыков
+var tools = []struct {
+	name, purpose string
+	status        Status
+}{
+	{"update-desktop-database", "обновляет кэш меню приложений; без него ярлык может появиться только после перезахода", Warn},
+	{"gio", "помечает ярлык на рабочем столе GNOME как доверенный; без него GNOME не даст его запустить", Warn},
+	{"gtk-update-icon-cache", "обновляет кэш иконок", Warn},
+	{"desktop-file-validate", "проверяет ярлыки при поиске неполадок", Warn},
+}
+
+// Run выполняет все проверки
+func Run() []Check {
+	var checks []Check
+	for _, tool := range tools {
+		checks = append(checks, checkTool(tool.name, tool.purpose, tool.status))
+	}
+	checks = append(checks, checkDesktop(), checkDataHome(), checkDataDirs())
+	checks = append(checks, checkWritable("Ярлыки меню", common.ApplicationsDir()))
+	checks = append(checks, checkWritable("Иконки", filepath.Join(os.Getenv("HOME"), ".local", "share", "icons")))
+	if dir := common.DesktopDir(); dir != "" {
+		checks = append(checks, checkWritable("Директория рабочего стола", dir))
+	} else {
+		checks = append(checks, Check{Name: "Директория рабочего стола", Status: Warn,
+			Detail: "директория рабочего стола не найдена", Hint: "ярлык на рабочем столе создан не будет"})
+	}
+	checks = append(checks, checkDisplay(), checkLocale())
+	return checks
+}
+
+func checkTool(name, purpose string, status Status) Check {
+	c := Check{Name: "Программа " + name}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		c.Status = status
+		c.Detail = "не найдена в PATH"
+		c.Hint = "установите ее из репозитория дистрибутива: она " + purpose
+		return c
+	}
+	c.Detail = path
+	return c
+}
+
+// checkDesktop проверяет, что известен рабочий стол: от него зависят обходные
+// пути для ярлыков
+func checkDesktop() Check {
+	c := Check{Name: "Окружение рабочего стола (XDG_CURRENT_DESKTOP)"}
+	c.Detail = os.Getenv("XDG_CURRENT_DESKTOP")
+	if c.Detail == "" {
+		c.Status = Warn
+		c.Detail = "не задан"
+		c.Hint = "программа запущена вне графической сессии или через sudo; запустите ее от своего пользователя"
+	}
+	return c
+}
+
+// checkDataHome сверяет XDG_DATA_HOME с директорией, куда установщик пишет ярлыки
+func checkDataHome() Check {
+	c := Check{Name: "XDG_DATA_HOME"}
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	defaultHome := filepath.Join(os.Getenv("HOME"), ".local", "share")
+	switch {
+	case dataHome == "":
+		c.Detail = "не задан, используется " + defaultHome
+	case filepath.Clean(dataHome) == defaultHome:
+		c.Detail = dataHome
+	default:
+		c.Status = Warn
+		c.Detail = dataHome
+		c.Hint = "меню ищет ярлыки в " + filepath.Join(dataHome, "applications") + ", а установщик пишет их в " +
+			filepath.Join(defaultHome, "applications") + "; уберите переменную или свяжите директории ссылкой"
+	}
+	return c
+}
+
+// checkDataDirs проверяет, что меню ищет ярлыки в директории установки для всех
+func checkDataDirs() Check {
+	c := Check{Name: "XDG_DATA_DIRS"}
+	dataDirs := os.Getenv("XDG_DATA_DIRS")
+	if dataDirs == "" {
+		// По спецификации это /usr/local/share:/usr/share
+		c.Detail = "не задан, используется /usr/local/share:/usr/share"
+		return c
+	}
+	c.Detail = dataDirs
+	shared := filepath.Dir(deploy.ApplicationsDir)
+	for _, dir := range filepath.SplitList(dataDirs) {
+		if filepath.Clean(dir) == shared {
+			return c
+		}
+	}
+	c.Status = Warn
+	c.Hint = "в списке нет " + shared + ": ярлыки игр, установленных для всех пользователей, не появятся в меню"
+	return c
+}
+
+// checkWritable проверяет, что в директорию можно записать файл. Несуществующая
+// директория будет создана, поэтому проверяется ближайшая существующая.
+func checkWritable(name, dir string) Check {
+	c := Check{Name: name, Detail: dir}
+	existing := dir
+	for {
+		if _, err := os.Stat(existing); err == nil || existing == filepath.Dir(existing) {
+			break
+		}
+		existing = filepath.Dir(existing)
+	}
+	f, err := ioutil.TempFile(existing, ".go-qt-installer-check-")
+	if err != nil {
+		c.Status = Fail
+		c.Detail = fmt.Sprintf("%s: нет доступа на запись (%v)", dir, err)
+		c.Hint = "проверьте владельца директории: ее могли создать от root при запуске через sudo"
+		return c
+	}
+	f.Close()
+	os.Remove(f.Name())
+	if existing != dir {
+		c.Detail = dir + " (будет создана)"
+	}
+	return c
+}
+
+// checkDisplay определяет тип графической сессии
+func checkDisplay() Check {
+	c := Check{Name: "Графическая сессия"}
+	session := os.Getenv("XDG_SESSION_TYPE")
+	wayland, x11 := os.Getenv("WAYLAND_DISPLAY"), os.Getenv("DISPLAY")
+	switch {
+	case wayland != "" && x11 != "":
+		c.Detail = fmt.Sprintf("Wayland (%s), XWayland (%s)", wayland, x11)
+	case wayland != "":
+		c.Detail = fmt.Sprintf("Wayland (%s) без XWayland", wayland)
+		c.Status = Warn
+		c.Hint = "игры для X11 не запустятся; установите XWayland"
+	case x11 != "":
+		c.Detail = fmt.Sprintf("X11 (%s)", x11)
+	default:
+		c.Detail = "не найдена: нет DISPLAY и WAYLAND_DISPLAY"
+		c.Status = Fail
+		c.Hint = "запустите программу из графической сессии"
+	}
+	if session != "" {
+		c.Detail += ", XDG_SESSION_TYPE=" + session
+	}
+	return c
+}
+
+// checkLocale проверяет, что локаль в UTF-8: иначе ломаются русские названия в
+// ярлыках и пути с не латинскими буквами
+func checkLocale() Check {
+	c := Check{Name: "Локаль"}
+	locale := ""
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if locale = os.Getenv(name); locale != "" {
+			c.Detail = name + "=" + locale
+			break
+		}
+	}
+	switch {
+	case locale == "" || locale == "C" || locale == "POSIX":
+		c.Status = Warn
+		if c.Detail == "" {
+			c.Detail = "не задана"
+		}
+		c.Hint = "названия и пути не на латинице могут отображаться неверно; выберите локаль UTF-8 в настройках системы"
+	case !strings.Contains(strings.ToLower(strings.ReplaceAll(locale, "-", "")), "utf8"):
+		c.Status = Warn
+		c.Hint = "локаль не в UTF-8: названия не на латинице могут отображаться неверно"
+	}
+	return c
+}
+
+// Worst возвращает худший итог проверок
+func Worst(checks []Check) Status {
+	worst := Pass
+	for _, c := range checks {
+		if c.Status > worst {
+			worst = c.Status
+		}
+	}
+	return worst
+}
+
+// Text описывает результаты проверок для отчета и буфера обмена
+func Text(checks []Check) string {
+	var b strings.Builder
+	for _, c := range checks {
+		fmt.Fprintf(&b, "[%s] %s: %s\n", c.Status, c.Name, c.Detail)
+		if c.Status != Pass && c.Hint != "" {
+			fmt.Fprintf(&b, "    %s\n", c.Hint)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,81 @@
+package diagnostics
+
+import (
+	"github.com/therecipe/qt/core"
+	"github.com/therecipe/qt/gui"
+	"github.com/therecipe/qt/widgets"
+)
+
+// statusIcons — значки итогов проверок
+var statusIcons = map[Status]widgets.QStyle__StandardPixmap{
+	Pass: widgets.QStyle__SP_DialogApplyButton,
+	Warn: widgets.QStyle__SP_MessageBoxWarning,
+	Fail: widgets.QStyle__SP_MessageBoxCritical,
+}
+
+// ShowDialog показывает окно диагностики. Проверки можно повторить после
+// исправления и скопировать, чтобы отправить в поддержку.
+func ShowDialog() {
+	ShowResults("Диагностика", "Проверить снова", Run)
+}
+
+// ShowResults показывает итоги проверок run. Кнопка rerun запускает их заново.
+func ShowResults(title, rerun string, run func() []Check) {
+	dialog := widgets.NewQDialog(nil, 0)
+	dialog.SetWindowTitle(title)
+
+	summary := widgets.NewQLabel2("", nil, 0)
+	summary.SetWordWrap(true)
+	list := widgets.NewQListWidget(nil)
+	list.SetWordWrap(true)
+
+	var checks []Check
+	fill := func() {
+		checks = run()
+		list.Clear()
+		for _, c := range checks {
+			text := c.Name + ": " + c.Detail
+			if c.Status != Pass && c.Hint != "" {
+				text += "\n" + c.Hint
+			}
+			item := widgets.NewQListWidgetItem2(text, list, 0)
+			item.SetIcon(dialog.Style().StandardIcon(statusIcons[c.Status], nil, nil))
+		}
+		switch Worst(checks) {
+		case Pass:
+			summary.SetText("Все проверки пройдены.")
+		case Warn:
+			summary.SetText("Есть предупреждения: часть возможностей недоступна. Подробности у отмеченных пунктов.")
+		default:
+			summary.SetText("Найдены ошибки. Подробности и что сделать — у отмеченных пунктов.")
+		}
+	}
+	fill()
+
+	rerunButton := widgets.NewQPushButton2(rerun, nil)
+	rerunButton.ConnectClicked(func(bool) {
+		fill()
+	})
+	copyButton := widgets.NewQPushButton2("Скопировать", nil)
+	copyButton.ConnectClicked(func(bool) {
+		gui.QGuiApplication_Clipboard().SetText(Text(checks), gui.QClipboard__Clipboard)
+	})
+	closeButton := widgets.NewQPushButton2("Закрыть", nil)
+	closeButton.ConnectClicked(func(bool) {
+		dialog.Reject()
+	})
+
+	buttonsLayout := widgets.NewQHBoxLayout()
+	buttonsLayout.AddWidget(rerunButton, 0, 0)
+	buttonsLayout.AddWidget(copyButton, 0, 0)
+	buttonsLayout.AddStretch(1)
+	buttonsLayout.AddWidget(closeButton, 0, 0)
+
+	layout := widgets.NewQVBoxLayout()
+	layout.AddWidget(summary, 0, 0)
+	layout.AddWidget(list, 1, 0)
+	layout.AddLayout(buttonsLayout, 0)
+	dialog.SetLayout(layout)
+	dialog.Resize(core.NewQSize2(600, 450))
+	dialog.Exec()
+}
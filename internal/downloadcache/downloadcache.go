@@ -0,0 +1,339 @@
+// Package downloadcache хранит скачанные ресурсы игр по их SHA-256, чтобы повторная
+// установка или установка под другой учетной записью не качала данные заново.
+//
+// Файлы записываются только в личный кэш пользователя. Общий кэш, который
+// наполняет администратор, только читается: файл из него копируется в личный
+// кэш и проверяется по хэшу уже в копии, которую другой пользователь не может
+// изменить после проверки. Индекс ссылок читается только из личного кэша: по
+// чужому индексу можно было бы подсунуть файл для ресурса без известного хэша.
+//
+// Установщик сам не пополняет общий кэш: загрузка под одной учетной записью не
+// попадает к другим, пока администратор не добавит файлы через Fill (режим
+// установщика --fill-shared-cache). Так в общем кэше нет файлов, записанных
+// обычными пользователями.
+package downloadcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SharedDir — общий для всех пользователей кэш. Установщик его только читает,
+// наполняет администратор через Fill.
+const SharedDir = "/var/cache/go-qt-installer"
+
+// DefaultLimit — ограничение размера кэша по умолчанию
+const DefaultLimit = 10 << 30
+
+// Cache — директории с файлами вида <dir>/<sha256> и индексом ссылок <dir>/urls/<sha256 ссылки>.
+// В первую директорию файлы записываются, остальные только читаются.
+type Cache struct {
+	dirs  []string
+	Limit int64 // Максимальный размер записываемой директории в байтах, 0 — без ограничений
+}
+
+// CustomDir — личный кэш, выбранный в настройках; пустая строка — $XDG_CACHE_HOME
+var CustomDir string
+
+// UserDir возвращает личный кэш загрузок: CustomDir или директорию в $XDG_CACHE_HOME
+func UserDir() string {
+	if CustomDir != "" {
+		return CustomDir
+	}
+	cacheHome := os.Getenv("XDG_CACHE_HOME")
+	if cacheHome == "" {
+		cacheHome = filepath.Join(os.Getenv("HOME"), ".cache")
+	}
+	return filepath.Join(cacheHome, "go-qt-installer", "downloads")
+}
+
+// New создает кэш: файлы записываются в личный кэш пользователя, общий только
+// читается
+func New(limit int64) *Cache {
+	return &Cache{Limit: limit, dirs: []string{UserDir(), SharedDir}}
+}
+
+// Fill добавляет файл path в общий кэш dir под именем его SHA-256 и возвращает
+// хэш. Файл сначала копируется во временный файл в dir, хэш считается по копии.
+func Fill(dir, path string) (string, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp(dir, ".partial-*")
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), src); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	// Файл читают все пользователи, а изменить может только владелец
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	if err := os.Rename(f.Name(), filepath.Join(dir, sum)); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return sum, nil
+}
+
+// Dir возвращает директорию, в которую записываются новые файлы
+func (c *Cache) Dir() string {
+	return c.dirs[0]
+}
+
+func urlKey(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:])
+}
+
+// NormalizeHash приводит "sha256:ABC..." и "abc..." к виду без префикса в нижнем регистре
+func NormalizeHash(h string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(h), "sha256:"))
+}
+
+// FileHash считает SHA-256 файла
+func FileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Lookup ищет файл с хэшем sum и проверяет его содержимое. Испорченные файлы
+// в записываемой директории удаляются. Файл из общего кэша возвращается копией в
+// личном кэше.
+func (c *Cache) Lookup(sum string) (string, bool) {
+	sum = NormalizeHash(sum)
+	if len(sum) != sha256.Size*2 {
+		return "", false
+	}
+	for i, dir := range c.dirs {
+		path := filepath.Join(dir, sum)
+		if i > 0 {
+			if _, err := os.Stat(path); err != nil {
+				continue
+			}
+			if copied, err := c.copyShared(path, sum); err == nil {
+				return copied, true
+			}
+			continue
+		}
+		actual, err := FileHash(path)
+		if err != nil {
+			continue
+		}
+		if actual != sum {
+			if i == 0 {
+				os.Remove(path)
+			}
+			continue
+		}
+		// Время изменения служит отметкой последнего использования для вытеснения
+		now := time.Now()
+		os.Chtimes(path, now, now)
+		return path, true
+	}
+	return "", false
+}
+
+// LookupURL ищет файл, ранее скачанный по ссылке url, когда хэш заранее
+// неизвестен. Индекс ссылок берется только из личного кэша.
+func (c *Cache) LookupURL(url string) (string, bool) {
+	data, err := os.ReadFile(filepath.Join(c.Dir(), "urls", urlKey(url)))
+	if err != nil {
+		return "", false
+	}
+	return c.Lookup(string(data))
+}
+
+// copyShared копирует файл общего кэша в личный и проверяет хэш копии
+func (c *Cache) copyShared(path, sum string) (string, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	w, err := c.Create()
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(w, src); err != nil {
+		w.Abort()
+		return "", err
+	}
+	return w.Commit("", sum)
+}
+
+// Writer — файл, скачиваемый в кэш. Хэш считается по ходу записи.
+type Writer struct {
+	cache *Cache
+	file  *os.File
+	hash  hash.Hash
+}
+
+// Create начинает запись нового файла в кэш
+func (c *Cache) Create() (*Writer, error) {
+	if err := os.MkdirAll(c.Dir(), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(c.Dir(), ".partial-*")
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{cache: c, file: f, hash: sha256.New()}, nil
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	n, err := w.file.Write(p)
+	w.hash.Write(p[:n])
+	return n, err
+}
+
+// Abort удаляет недокачанный файл
+func (w *Writer) Abort() {
+	w.file.Close()
+	os.Remove(w.file.Name())
+}
+
+// Commit проверяет хэш, если он известен, и переносит файл в кэш.
+// Ссылка url запоминается, чтобы найти файл без известного хэша.
+func (w *Writer) Commit(url, expected string) (string, error) {
+	if err := w.file.Close(); err != nil {
+		os.Remove(w.file.Name())
+		return "", err
+	}
+	sum := hex.EncodeToString(w.hash.Sum(nil))
+	if expected != "" && NormalizeHash(expected) != sum {
+		os.Remove(w.file.Name())
+		return "", fmt.Errorf("контрольная сумма не совпадает: ожидалась %s, получена %s", NormalizeHash(expected), sum)
+	}
+
+	path := filepath.Join(w.cache.Dir(), sum)
+	if err := os.Rename(w.file.Name(), path); err != nil {
+		os.Remove(w.file.Name())
+		return "", err
+	}
+
+	if url != "" {
+		urlsDir := filepath.Join(w.cache.Dir(), "urls")
+		if err := os.MkdirAll(urlsDir, 0755); err == nil {
+			os.WriteFile(filepath.Join(urlsDir, urlKey(url)), []byte(sum), 0644)
+		}
+	}
+
+	if err := w.cache.Trim(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+type cachedFile struct {
+	path string
+	size int64
+	used time.Time
+}
+
+func (c *Cache) files() []cachedFile {
+	entries, err := os.ReadDir(c.Dir())
+	if err != nil {
+		return nil
+	}
+	var files []cachedFile
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, cachedFile{filepath.Join(c.Dir(), e.Name()), info.Size(), info.ModTime()})
+	}
+	return files
+}
+
+// Size возвращает размер записываемой директории кэша
+func (c *Cache) Size() int64 {
+	var total int64
+	for _, f := range c.files() {
+		total += f.size
+	}
+	return total
+}
+
+// Trim удаляет давно не использованные файлы, пока кэш не уложится в ограничение.
+// Файл keep не удаляется, даже если он один превышает ограничение.
+func (c *Cache) Trim(keep string) error {
+	if c.Limit <= 0 {
+		return nil
+	}
+	files := c.files()
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].used.Before(files[j].used)
+	})
+
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+	for _, f := range files {
+		if total <= c.Limit {
+			break
+		}
+		if f.path == keep {
+			continue
+		}
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		total -= f.size
+	}
+	return nil
+}
+
+// Clear удаляет файлы из личного кэша
+func (c *Cache) Clear() error {
+	for _, f := range c.files() {
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) && !os.IsPermission(err) {
+			return err
+		}
+	}
+	urls, _ := os.ReadDir(filepath.Join(c.Dir(), "urls"))
+	for _, e := range urls {
+		if err := os.Remove(filepath.Join(c.Dir(), "urls", e.Name())); err != nil && !os.IsNotExist(err) && !os.IsPermission(err) {
+			return err
+		}
+	}
+	return nil
+}
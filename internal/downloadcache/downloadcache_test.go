@@ -0,0 +1,50 @@
+package downloadcache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFillShared(t *testing.T) {
+	dir := t.TempDir()
+	shared := filepath.Join(dir, "shared")
+	c := &Cache{dirs: []string{filepath.Join(dir, "user"), shared}}
+
+	src := filepath.Join(dir, "game.zip")
+	if err := ioutil.WriteFile(src, []byte("game data"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	sum, err := Fill(shared, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := FileHash(src); sum != want {
+		t.Fatalf("Fill вернул %s, ожидалось %s", sum, want)
+	}
+	info, err := os.Stat(filepath.Join(shared, sum))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("права %v, ожидалось 0644", info.Mode().Perm())
+	}
+
+	path, ok := c.Lookup("sha256:" + sum)
+	if !ok {
+		t.Fatal("файл из общего кэша не найден")
+	}
+	if filepath.Dir(path) != c.Dir() {
+		t.Errorf("Lookup вернул %s, а не копию в личном кэше", path)
+	}
+
+	// Подмененный в общем кэше файл не проходит проверку хэша в копии
+	if err := ioutil.WriteFile(filepath.Join(shared, sum), []byte("evil"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(path)
+	if _, ok := c.Lookup(sum); ok {
+		t.Error("подмененный файл из общего кэша принят")
+	}
+}
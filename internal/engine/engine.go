@@ -0,0 +1,403 @@
+// Package engine — распаковка игры: проверяет записи архивов на выход за пределы
+// директории игры, распаковывает их, бережет файлы пользователя и ведет резервную
+// копию обновления. Окно установщика распаковывает архивы через Extraction,
+// отвечая на вопросы через UI, а Install прогоняет тот же код без Qt для тестов.
+package engine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang-installer/internal/archive"
+	"golang-installer/internal/backup"
+	"golang-installer/internal/membudget"
+)
+
+// Unsafe описывает запись архива, которая не может быть безопасно распакована
+type Unsafe struct {
+	Archive string
+	Name    string
+	Reason  string
+}
+
+// UnsafeError возвращается, если в архивах есть опасные записи, а они не разрешены
+type UnsafeError struct {
+	Entries []Unsafe
+}
+
+func (e *UnsafeError) Error() string {
+	return fmt.Sprintf("в архивах обнаружено опасных записей: %d", len(e.Entries))
+}
+
+// CheckEntryName проверяет имя записи архива и возвращает причину, по которой она опасна,
+// или пустую строку, если запись можно распаковать в root
+func CheckEntryName(root, name string) string {
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "/") {
+		return "абсолютный путь"
+	}
+
+	root = filepath.Clean(root)
+	fpath := filepath.Join(root, name)
+	if fpath != root && !strings.HasPrefix(fpath, root+string(os.PathSeparator)) {
+		return "выход за пределы директории установки"
+	}
+
+	return ""
+}
+
+// ScanUnsafe проверяет записи архивов в порядке assets и собирает опасные
+func ScanUnsafe(root string, assets []string, entries map[string][]archive.Entry) []Unsafe {
+	var unsafe []Unsafe
+	for _, asset := range assets {
+		for _, e := range entries[asset] {
+			if reason := CheckEntryName(root, e.Name); reason != "" {
+				unsafe = append(unsafe, Unsafe{Archive: asset, Name: e.Name, Reason: reason})
+			}
+		}
+	}
+	return unsafe
+}
+
+// IsUserData проверяет, подпадает ли запись под шаблоны файлов пользователя. Шаблон
+// "dir/**" охватывает все вложенные файлы, остальные сравниваются по правилам path.Match.
+func IsUserData(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "/**"); ok {
+			if strings.HasPrefix(name, prefix+"/") {
+				return true
+			}
+			continue
+		}
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// UI — окно установщика, которому распаковка сообщает о ходе работы и через
+// которое спрашивает пользователя. Методы вызываются из горутины распаковки.
+type UI interface {
+	// Extracted сообщает о распакованной записи размером size, у директорий он 0
+	Extracted(size int64)
+	// Warn сообщает об ошибке, из-за которой пропущена запись; err может быть nil
+	Warn(message, file string, err error)
+	// ConfirmUserData спрашивает, заменить ли файлы пользователя новыми версиями
+	ConfirmUserData(changed []ChangedFile) bool
+}
+
+// ChangedFile — файл пользователя, новая версия которого отличается от имеющейся
+type ChangedFile struct {
+	Name    string // Путь внутри архива
+	Path    string // Файл пользователя
+	Pending string // Новая версия, распакованная рядом
+	OldSize int64
+	NewSize int64
+}
+
+// Extraction — распаковка архивов одной установки
+type Extraction struct {
+	Root        string          // Директория, в которую распаковываются архивы
+	UserData    []string        // Шаблоны файлов пользователя
+	Backup      *backup.Session // Резервная копия обновления, nil — без нее
+	Quarantined map[string]bool // Пропускаемые записи, ключ — архив и имя через "\x00"
+	UI          UI
+
+	changed  []ChangedFile
+	resolved string // Root без символических ссылок в пути
+}
+
+// Archive распаковывает записи entries архива asset. Ошибки отдельных записей
+// уходят в UI, и распаковка продолжается; прерывает ее только отмена ctx.
+// Возвращает объем распакованных данных.
+func (x *Extraction) Archive(ctx context.Context, asset string, ext archive.Extractor, entries []archive.Entry) (int64, error) {
+	var extracted int64
+	for _, e := range entries {
+		// Записи из карантина уже попали в отчет безопасности
+		if x.Quarantined[asset+"\x00"+e.Name] {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return extracted, err
+		}
+
+		// Проверка на путь выхода за пределы, в том числе через уже существующие ссылки
+		fpath := filepath.Join(x.Root, e.Name)
+		if CheckEntryName(x.Root, e.Name) != "" || !x.resolvesInside(fpath) {
+			x.UI.Warn("Обнаружена попытка распаковки за пределы директории установки", e.Name, nil)
+			continue
+		}
+
+		if e.IsDir {
+			os.MkdirAll(fpath, os.ModePerm)
+			x.UI.Extracted(0)
+			continue
+		}
+
+		// Существующие файлы пользователя распаковываем рядом и сравниваем после
+		target := fpath
+		existed := regularFile(fpath)
+		protected := IsUserData(x.UserData, e.Name) && existed
+		if protected {
+			target = pendingPath(fpath)
+		}
+
+		// Заменяемый файл переносим в резервную копию, новый запоминаем для отката
+		backedUp := false
+		if x.Backup != nil && !protected {
+			if existed {
+				if err := x.Backup.Save(e.Name); err != nil {
+					x.UI.Warn("Ошибка резервного копирования", e.Name, err)
+					continue
+				}
+				backedUp = true
+			} else {
+				x.Backup.Added(e.Name)
+			}
+		}
+
+		// Директории для файла распаковщик создает сам
+		if err := ext.Extract(ctx, e, target); err != nil {
+			x.UI.Warn("Ошибка распаковки", e.Name, err)
+			if backedUp {
+				x.Backup.Restore(e.Name)
+			}
+			continue
+		}
+		if protected {
+			if changed, ok := compareUserData(e.Name, fpath, target); ok {
+				x.changed = append(x.changed, changed)
+			}
+		}
+		extracted += e.Size
+		x.UI.Extracted(e.Size)
+	}
+	return extracted, nil
+}
+
+// Finish вызывается после всех архивов: заменяет измененные файлы пользователя,
+// если UI согласен, и записывает резервную копию обновления. Возвращает файлы
+// пользователя, оставленные без изменений.
+func (x *Extraction) Finish() []string {
+	var kept []string
+	if len(x.changed) > 0 {
+		kept = applyUserData(x.changed, x.UI.ConfirmUserData(x.changed), x.Backup)
+	}
+	if x.Backup != nil {
+		if err := x.Backup.Finish(); err != nil {
+			log.Printf("Ошибка при сохранении резервной копии: %v", err)
+			x.UI.Warn("Не удалось сохранить резервную копию обновления", "", err)
+		}
+	}
+	return kept
+}
+
+// resolvesInside проверяет, что ближайшая существующая директория пути с учетом
+// символических ссылок лежит внутри Root. Имя записи проверяет CheckEntryName,
+// а ссылка, оставшаяся в директории игры, может увести запись куда угодно.
+func (x *Extraction) resolvesInside(path string) bool {
+	if x.resolved == "" {
+		resolved, err := filepath.EvalSymlinks(x.Root)
+		if err != nil {
+			return false
+		}
+		x.resolved = resolved
+	}
+	dir := filepath.Dir(path)
+	for {
+		if _, err := os.Lstat(dir); err == nil {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return false
+	}
+	return resolved == x.resolved || strings.HasPrefix(resolved, x.resolved+string(os.PathSeparator))
+}
+
+func regularFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// pendingPath возвращает имя для новой версии файла рядом с ним, на той же файловой системе
+func pendingPath(path string) string {
+	return filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".go-qt-installer-new")
+}
+
+// compareUserData сравнивает файл пользователя с новой версией. Одинаковая новая
+// версия сразу удаляется, отличающаяся возвращается для решения пользователя.
+func compareUserData(name, path, pending string) (ChangedFile, bool) {
+	oldData, errOld := ioutil.ReadFile(path)
+	newData, errNew := ioutil.ReadFile(pending)
+	if errOld == nil && errNew == nil && bytes.Equal(oldData, newData) {
+		os.Remove(pending)
+		return ChangedFile{}, false
+	}
+	return ChangedFile{
+		Name:    name,
+		Path:    path,
+		Pending: pending,
+		OldSize: int64(len(oldData)),
+		NewSize: int64(len(newData)),
+	}, true
+}
+
+// applyUserData заменяет файлы пользователя новыми версиями или удаляет новые версии.
+// Заменяемые файлы попадают в резервную копию обновления, если она ведется.
+// Возвращает файлы, оставленные без изменений.
+func applyUserData(changed []ChangedFile, overwrite bool, saved *backup.Session) []string {
+	var kept []string
+	for _, f := range changed {
+		if overwrite {
+			if saved != nil {
+				if err := saved.Save(f.Name); err != nil {
+					log.Printf("Ошибка резервного копирования: %v", err)
+					os.Remove(f.Pending)
+					kept = append(kept, f.Name)
+					continue
+				}
+			}
+			if err := os.Rename(f.Pending, f.Path); err != nil {
+				log.Printf("Ошибка замены %s: %v", f.Path, err)
+			}
+			continue
+		}
+		os.Remove(f.Pending)
+		kept = append(kept, f.Name)
+		log.Printf("Файл пользователя сохранен без изменений: %s", f.Name)
+	}
+	return kept
+}
+
+// Options — параметры установки без интерфейса
+type Options struct {
+	InstallPath       string
+	Assets            []string // Локальные пути к архивам
+	AllowUnsafe       bool     // Пропускать опасные записи вместо отказа от установки
+	UserData          []string // Шаблоны файлов пользователя
+	OverwriteUserData bool     // Заменять измененные файлы пользователя, как ответ «Заменить» в окне
+	// Обновление с версии PreviousVersion до Version ведет резервную копию, как в окне
+	PreviousVersion string
+	Version         string
+	MemoryLimit     int64 // Потолок памяти в байтах, 0 — без ограничения
+}
+
+// Result — итог установки
+type Result struct {
+	Files       int   // Распакованные файлы и директории
+	Bytes       int64 // Объем распакованных данных
+	Quarantined []Unsafe
+	Kept        []string // Файлы пользователя, оставленные без изменений
+	Warnings    []string // Пропущенные записи с причинами
+}
+
+// headless отвечает на вопросы распаковки так, как их задали в Options
+type headless struct {
+	opts     Options
+	result   *Result
+	total    int
+	progress func(done, total int)
+}
+
+func (h *headless) Extracted(size int64) {
+	h.result.Files++
+	h.result.Bytes += size
+	if h.progress != nil {
+		h.progress(h.result.Files, h.total)
+	}
+}
+
+func (h *headless) Warn(message, file string, err error) {
+	warning := message
+	if file != "" {
+		warning += ": " + file
+	}
+	if err != nil {
+		warning += ": " + err.Error()
+	}
+	h.result.Warnings = append(h.result.Warnings, warning)
+}
+
+func (h *headless) ConfirmUserData(changed []ChangedFile) bool {
+	return h.opts.OverwriteUserData
+}
+
+// Install распаковывает архивы в директорию установки тем же кодом, что и окно
+// установщика. Как и окно, сначала открывает и проверяет все архивы и ничего
+// не распаковывает, если в них есть опасные записи. Пропущенные записи, которые
+// окно показало бы в панели предупреждений, возвращаются ошибкой вместе с
+// результатом. progress может быть nil.
+func Install(ctx context.Context, opts Options, progress func(done, total int)) (*Result, error) {
+	if opts.MemoryLimit > 0 {
+		membudget.SetCeiling(opts.MemoryLimit)
+	}
+
+	extractors := make(map[string]archive.Extractor)
+	entries := make(map[string][]archive.Entry)
+	defer func() {
+		for _, ext := range extractors {
+			ext.Close()
+		}
+	}()
+
+	total := 0
+	for _, asset := range opts.Assets {
+		ext, err := archive.Open(asset)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при открытии архива: %v", err)
+		}
+		extractors[asset] = ext
+		if entries[asset], err = ext.Enumerate(); err != nil {
+			return nil, fmt.Errorf("ошибка при чтении архива %s: %v", filepath.Base(asset), err)
+		}
+		total += len(entries[asset])
+	}
+
+	result := &Result{}
+	quarantined := make(map[string]bool)
+	if unsafe := ScanUnsafe(opts.InstallPath, opts.Assets, entries); len(unsafe) > 0 {
+		if !opts.AllowUnsafe {
+			return nil, &UnsafeError{Entries: unsafe}
+		}
+		for _, e := range unsafe {
+			quarantined[e.Archive+"\x00"+e.Name] = true
+		}
+		result.Quarantined = unsafe
+		total -= len(unsafe)
+	}
+
+	if err := os.MkdirAll(opts.InstallPath, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("не удалось создать директорию для установки: %v", err)
+	}
+
+	x := &Extraction{
+		Root:        opts.InstallPath,
+		UserData:    opts.UserData,
+		Quarantined: quarantined,
+		UI:          &headless{opts: opts, result: result, total: total, progress: progress},
+	}
+	if opts.PreviousVersion != "" && opts.PreviousVersion != opts.Version {
+		x.Backup = backup.Begin(opts.InstallPath, opts.PreviousVersion, opts.Version)
+	}
+	for _, asset := range opts.Assets {
+		if _, err := x.Archive(ctx, asset, extractors[asset], entries[asset]); err != nil {
+			return result, err
+		}
+	}
+	result.Kept = x.Finish()
+
+	if len(result.Warnings) > 0 {
+		return result, fmt.Errorf("записей пропущено: %d, первая: %s", len(result.Warnings), result.Warnings[0])
+	}
+	return result, nil
+}
@@ -0,0 +1,524 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"testing"
+
+	"golang-installer/internal/archive"
+	"golang-installer/internal/backup"
+	"golang-installer/internal/chaos"
+	"golang-installer/internal/membudget"
+	"golang-installer/internal/testutil"
+)
+
+func makeArchive(t *testing.T, name string, files ...[]testutil.File) string {
+	t.Helper()
+	path, err := testutil.Archive(t.TempDir(), name, files...)
+	if err != nil {
+		t.Fatalf("создание архива: %v", err)
+	}
+	return path
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("чтение %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestInstall(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "game")
+	var calls, last int
+	result, err := Install(context.Background(), Options{
+		InstallPath: root,
+		Assets:      []string{makeArchive(t, "game.zip", testutil.Game())},
+	}, func(done, total int) {
+		calls++
+		last = done
+		if done > total {
+			t.Errorf("прогресс %d из %d", done, total)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Files != len(testutil.Game()) || calls != result.Files || last != result.Files {
+		t.Errorf("файлов %d, вызовов прогресса %d, последний %d", result.Files, calls, last)
+	}
+	if got := readFile(t, filepath.Join(root, "data/levels/2.dat")); got != "level 2" {
+		t.Errorf("data/levels/2.dat = %q", got)
+	}
+	info, err := os.Stat(filepath.Join(root, "bin/Game"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm()&0100 == 0 {
+		t.Errorf("bin/Game не исполняемый: %v", info.Mode())
+	}
+}
+
+// backend собирает архив из files для одного из распаковщиков
+type backend struct {
+	name string
+	make func(t *testing.T, files []testutil.File) string
+	// staged — архив распаковывается внешней программой, которая воссоздает ссылки
+	staged bool
+}
+
+// backends — все форматы, архивы которых тесты умеют создавать. RAR создать
+// нечем: rardecode только читает, а формат закрыт.
+var backends = []backend{
+	{name: "zip", make: func(t *testing.T, files []testutil.File) string {
+		return makeArchive(t, "game.zip", files)
+	}},
+	{name: "mojosetup", make: func(t *testing.T, files []testutil.File) string {
+		path, err := testutil.GOGInstaller(t.TempDir(), "gog_game_1.0.sh", "Game", "1.0", files)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}},
+	{name: "7z", staged: true, make: func(t *testing.T, files []testutil.File) string {
+		dir := t.TempDir()
+		path, err := testutil.FakeSevenZip(dir, "game.7z", files)
+		if err != nil {
+			t.Fatal(err)
+		}
+		withHelpers(t, dir)
+		return path
+	}},
+	{name: "squashfs", staged: true, make: func(t *testing.T, files []testutil.File) string {
+		dir := t.TempDir()
+		path, err := testutil.FakeUnsquashfs(dir, "game.squashfs", files)
+		if err != nil {
+			t.Fatal(err)
+		}
+		withHelpers(t, dir)
+		return path
+	}},
+}
+
+// withHelpers добавляет в PATH директорию с поддельными программами распаковки
+func withHelpers(t *testing.T, dir string) {
+	t.Setenv("PATH", filepath.Join(dir, "bin")+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestInstallZipSlip(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			dir := t.TempDir()
+			root := filepath.Join(dir, "game")
+			_, err := Install(context.Background(), Options{
+				InstallPath: root,
+				Assets:      []string{b.make(t, append(testutil.Game(), testutil.ZipSlip()...))},
+			}, nil)
+
+			var unsafe *UnsafeError
+			if !errors.As(err, &unsafe) {
+				t.Fatalf("ожидалась UnsafeError, получено %v", err)
+			}
+			if len(unsafe.Entries) != len(testutil.ZipSlip()) {
+				t.Errorf("опасных записей %d: %v", len(unsafe.Entries), unsafe.Entries)
+			}
+			// Отказ происходит до распаковки
+			if _, err := os.Stat(root); !os.IsNotExist(err) {
+				t.Errorf("директория установки создана: %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "evil.sh")); !os.IsNotExist(err) {
+				t.Errorf("запись вышла за пределы директории: %v", err)
+			}
+		})
+	}
+}
+
+func TestInstallQuarantine(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			dir := t.TempDir()
+			root := filepath.Join(dir, "game")
+			result, err := Install(context.Background(), Options{
+				InstallPath: root,
+				Assets:      []string{b.make(t, append(testutil.Game(), testutil.ZipSlip()...))},
+				AllowUnsafe: true,
+			}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Quarantined) != len(testutil.ZipSlip()) || result.Files != len(testutil.Game()) {
+				t.Errorf("пропущено %d, распаковано %d", len(result.Quarantined), result.Files)
+			}
+			if got := readFile(t, filepath.Join(root, "data/levels/2.dat")); got != "level 2" {
+				t.Errorf("data/levels/2.dat = %q", got)
+			}
+			for _, name := range []string{"evil.sh", "evil.txt"} {
+				if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+					t.Errorf("%s распакован за пределы директории: %v", name, err)
+				}
+			}
+		})
+	}
+}
+
+func TestInstallKeepsUserData(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "options.ini"), []byte("volume=3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result, err := Install(context.Background(), Options{
+		InstallPath: root,
+		Assets:      []string{makeArchive(t, "game.zip", testutil.Game())},
+		UserData:    []string{"*.ini"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Kept) != 1 || result.Kept[0] != "options.ini" {
+		t.Errorf("оставлены %v", result.Kept)
+	}
+	if got := readFile(t, filepath.Join(root, "options.ini")); got != "volume=3\n" {
+		t.Errorf("options.ini перезаписан: %q", got)
+	}
+}
+
+func TestInstallOverwritesUserData(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "options.ini"), []byte("volume=3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result, err := Install(context.Background(), Options{
+		InstallPath:       root,
+		Assets:            []string{makeArchive(t, "game.zip", testutil.Game())},
+		UserData:          []string{"*.ini"},
+		OverwriteUserData: true,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Kept) != 0 {
+		t.Errorf("оставлены %v", result.Kept)
+	}
+	if got := readFile(t, filepath.Join(root, "options.ini")); got != "volume=10\n" {
+		t.Errorf("options.ini не заменен: %q", got)
+	}
+	// Новая версия, распакованная рядом, не остается в директории игры
+	if _, err := os.Stat(pendingPath(filepath.Join(root, "options.ini"))); !os.IsNotExist(err) {
+		t.Errorf("осталась новая версия файла: %v", err)
+	}
+}
+
+func TestInstallBackup(t *testing.T) {
+	root := t.TempDir()
+	if _, err := Install(context.Background(), Options{
+		InstallPath: root,
+		Assets:      []string{makeArchive(t, "game.zip", testutil.Game())},
+	}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "options.ini"), []byte("volume=3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	update := []testutil.File{
+		{Name: "data/levels/1.dat", Body: "level 1 v2"},
+		{Name: "data/levels/3.dat", Body: "level 3"},
+		{Name: "options.ini", Body: "volume=10\nmusic=5\n"},
+	}
+	if _, err := Install(context.Background(), Options{
+		InstallPath:       root,
+		Assets:            []string{makeArchive(t, "update.zip", update)},
+		UserData:          []string{"*.ini"},
+		OverwriteUserData: true,
+		PreviousVersion:   "1.0",
+		Version:           "1.1",
+	}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	m := backup.Latest(root)
+	if m == nil {
+		t.Fatal("резервная копия обновления не создана")
+	}
+	if m.Version != "1.0" || m.NewVersion != "1.1" {
+		t.Errorf("версии копии %q -> %q", m.Version, m.NewVersion)
+	}
+	// Замененный с согласия файл пользователя тоже попадает в копию
+	sort.Strings(m.Replaced)
+	if len(m.Replaced) != 2 || m.Replaced[0] != "data/levels/1.dat" || m.Replaced[1] != "options.ini" {
+		t.Errorf("заменены %v", m.Replaced)
+	}
+	if len(m.Added) != 1 || m.Added[0] != "data/levels/3.dat" {
+		t.Errorf("добавлены %v", m.Added)
+	}
+	if got := readFile(t, filepath.Join(m.Dir, "files", "data/levels/1.dat")); got != "level 1" {
+		t.Errorf("в копии data/levels/1.dat = %q", got)
+	}
+
+	if err := backup.Rollback(root, m); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filepath.Join(root, "options.ini")); got != "volume=3\n" {
+		t.Errorf("после отката options.ini = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(root, "data/levels/3.dat")); !os.IsNotExist(err) {
+		t.Errorf("после отката остался добавленный файл: %v", err)
+	}
+}
+
+func TestInstallHuge(t *testing.T) {
+	if testing.Short() {
+		t.Skip("большой файл")
+	}
+	const size = 64 << 20
+	root := t.TempDir()
+	result, err := Install(context.Background(), Options{
+		InstallPath: root,
+		Assets:      []string{makeArchive(t, "huge.zip", testutil.Huge(size))},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filepath.Join(root, "data/huge.pak"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != size || result.Bytes != size {
+		t.Errorf("размер %d, учтено %d", info.Size(), result.Bytes)
+	}
+}
+
+func TestInstallMemoryLimit(t *testing.T) {
+	defer func() {
+		debug.SetMemoryLimit(math.MaxInt64)
+		membudget.Default.SetLimit(membudget.DefaultLimit)
+	}()
+	const size = 8 << 20
+	root := t.TempDir()
+	result, err := Install(context.Background(), Options{
+		InstallPath: root,
+		Assets:      []string{makeArchive(t, "huge.zip", testutil.Huge(size))},
+		MemoryLimit: 1 << 20,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Bytes != size {
+		t.Errorf("учтено %d", result.Bytes)
+	}
+}
+
+func TestInstallWeirdNames(t *testing.T) {
+	root := t.TempDir()
+	files := testutil.WeirdNames()
+	if _, err := Install(context.Background(), Options{
+		InstallPath: root,
+		Assets:      []string{makeArchive(t, "names.zip", files)},
+	}, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if got := readFile(t, filepath.Join(root, f.Name)); got != f.Body {
+			t.Errorf("%q = %q, ожидалось %q", f.Name, got, f.Body)
+		}
+	}
+}
+
+func TestInstallSymlinks(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			root, outside := t.TempDir(), filepath.Join(t.TempDir(), "home")
+			if err := os.Mkdir(outside, 0700); err != nil {
+				t.Fatal(err)
+			}
+			result, err := Install(context.Background(), Options{
+				InstallPath: root,
+				Assets:      []string{b.make(t, testutil.Symlinks(outside))},
+			}, nil)
+			// Запись внутри ссылки наружу не распаковывается ни одним распаковщиком
+			if err == nil {
+				t.Error("запись внутри ссылки наружу распакована без предупреждения")
+			}
+			if _, err := os.Lstat(filepath.Join(root, "lib/outside/evil.sh")); err == nil {
+				t.Error("распакована запись внутри ссылки")
+			}
+
+			if b.staged {
+				// Ссылка внутри архива становится копией файла, ссылка наружу пропускается
+				if got := readFile(t, filepath.Join(root, "lib/libgame.so")); got != "ELF" {
+					t.Errorf("lib/libgame.so = %q", got)
+				}
+				if _, err := os.Lstat(filepath.Join(root, "lib/outside")); !os.IsNotExist(err) {
+					t.Errorf("распакована ссылка наружу: %v", err)
+				}
+				if len(result.Warnings) != 2 {
+					t.Errorf("предупреждения %v", result.Warnings)
+				}
+			} else {
+				// Ссылки из zip распаковываются обычными файлами с путем внутри
+				if got := readFile(t, filepath.Join(root, "lib/libgame.so")); got != "libgame.so.1" {
+					t.Errorf("lib/libgame.so = %q", got)
+				}
+				if got := readFile(t, filepath.Join(root, "lib/outside")); got != outside {
+					t.Errorf("lib/outside = %q", got)
+				}
+				if len(result.Warnings) != 1 {
+					t.Errorf("предупреждения %v", result.Warnings)
+				}
+			}
+
+			filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+				if err == nil && info.Mode()&os.ModeSymlink != 0 {
+					t.Errorf("%s распакован как символическая ссылка", path)
+				}
+				return nil
+			})
+			// Права на ссылку не меняют права директории, на которую она указывает
+			info, err := os.Stat(outside)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != 0700 {
+				t.Errorf("права %s изменены: %v", outside, info.Mode())
+			}
+		})
+	}
+}
+
+func TestInstallThroughExistingLink(t *testing.T) {
+	dir := t.TempDir()
+	outside := filepath.Join(dir, "outside")
+	root := filepath.Join(dir, "game")
+	for _, d := range []string{outside, root} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "data")); err != nil {
+		t.Fatal(err)
+	}
+	result, err := Install(context.Background(), Options{
+		InstallPath: root,
+		Assets:      []string{makeArchive(t, "game.zip", testutil.Game())},
+	}, nil)
+	if err == nil || len(result.Warnings) != 2 {
+		t.Errorf("ошибка %v, предупреждения %v", err, result.Warnings)
+	}
+	entries, _ := os.ReadDir(outside)
+	if len(entries) != 0 {
+		t.Errorf("записи распакованы через ссылку в %s: %v", outside, entries)
+	}
+}
+
+func TestInstallUnsupportedFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "game.tar.gz")
+	if err := os.WriteFile(path, []byte("\x1f\x8b\x08\x00"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Install(context.Background(), Options{InstallPath: t.TempDir(), Assets: []string{path}}, nil)
+	if err == nil {
+		t.Fatal("tar.gz открыт, хотя формат не зарегистрирован")
+	}
+}
+
+func TestInstallCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := Install(ctx, Options{
+		InstallPath: t.TempDir(),
+		Assets:      []string{makeArchive(t, "game.zip", testutil.Game())},
+	}, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("ожидалась отмена, получено %v", err)
+	}
+}
+
+func TestInstallGOG(t *testing.T) {
+	dir, root := t.TempDir(), t.TempDir()
+	path, err := testutil.GOGInstaller(dir, "gog_game_1.0.sh", "Game", "1.0", testutil.Game())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ext, err := archive.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, ok := ext.(archive.Metadata)
+	if !ok {
+		t.Fatal("установщик GOG не сообщает название и версию")
+	}
+	if name, version := meta.GameInfo(); name != "Game" || version != "1.0" {
+		t.Errorf("GameInfo() = %q, %q", name, version)
+	}
+	ext.Close()
+
+	result, err := Install(context.Background(), Options{InstallPath: root, Assets: []string{path}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Files != len(testutil.Game()) {
+		t.Errorf("распаковано %d", result.Files)
+	}
+	if got := readFile(t, filepath.Join(root, "data/levels/1.dat")); got != "level 1" {
+		t.Errorf("data/levels/1.dat = %q", got)
+	}
+	// Служебные файлы установщика не попадают в директорию игры
+	if _, err := os.Stat(filepath.Join(root, "data/noarch")); !os.IsNotExist(err) {
+		t.Errorf("распакован каталог установщика: %v", err)
+	}
+}
+
+func TestInstallChaos(t *testing.T) {
+	chaos.Enable(1, 1)
+	defer chaos.Enable(0, 0)
+	result, err := Install(context.Background(), Options{
+		InstallPath: t.TempDir(),
+		Assets:      []string{makeArchive(t, "game.zip", testutil.Game())},
+	}, nil)
+	if err == nil {
+		t.Fatal("установка прошла, хотя каждая запись на диск завершается сбоем")
+	}
+	// Сбойные записи пропускаются с предупреждением, как в окне установщика
+	if len(result.Warnings) == 0 {
+		t.Error("пропущенные записи не попали в предупреждения")
+	}
+}
+
+func TestCheckEntryName(t *testing.T) {
+	for name, unsafe := range map[string]bool{
+		"data/a.pak":  false,
+		"./a":         false,
+		"a/../b":      false,
+		"../a":        true,
+		"/etc/passwd": true,
+		"a/../../b":   true,
+		"..":          true,
+	} {
+		if got := CheckEntryName("/games/x", name) != ""; got != unsafe {
+			t.Errorf("CheckEntryName(%q) опасна = %v, ожидалось %v", name, got, unsafe)
+		}
+	}
+}
+
+func TestIsUserData(t *testing.T) {
+	patterns := []string{"saves/**", "*.ini"}
+	for name, want := range map[string]bool{
+		"saves/1.sav":      true,
+		"saves/slot/2.sav": true,
+		"options.ini":      true,
+		"config/game.ini":  false,
+		"savesx/1.sav":     false,
+		"data/saves/1.sav": false,
+	} {
+		if got := IsUserData(patterns, name); got != want {
+			t.Errorf("IsUserData(%q) = %v, ожидалось %v", name, got, want)
+		}
+	}
+}
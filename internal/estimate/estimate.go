@@ -0,0 +1,151 @@
+// Package estimate оценивает время установки: замеряет скорость записи на целевой
+// диск и скорость распаковки, а во время установки уточняет оценку по фактической скорости.
+package estimate
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+)
+
+// benchmarkBlock — размер блока при замере записи
+const benchmarkBlock = 1 << 20
+
+// DiskWrite записывает во временный файл в dir size байт с синхронизацией
+// и возвращает скорость записи в байтах в секунду
+func DiskWrite(dir string, size int64) (float64, error) {
+	f, err := os.CreateTemp(dir, ".go-qt-installer-bench-")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	// Случайные данные, чтобы сжатие на уровне файловой системы не искажало замер
+	block := make([]byte, benchmarkBlock)
+	rand.New(rand.NewSource(1)).Read(block)
+
+	start := time.Now()
+	for written := int64(0); written < size; written += benchmarkBlock {
+		if _, err := f.Write(block); err != nil {
+			return 0, err
+		}
+	}
+	if err := f.Sync(); err != nil {
+		return 0, err
+	}
+	return float64(size) / time.Since(start).Seconds(), nil
+}
+
+// Inflate замеряет скорость распаковки deflate, которым сжато большинство архивов,
+// и возвращает ее в байтах распакованных данных в секунду
+func Inflate(size int) (float64, error) {
+	// Данные, похожие на ресурсы игры: повторяющиеся фрагменты вперемешку со случайными
+	data := make([]byte, size)
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < size; i += 4096 {
+		end := min(i+4096, size)
+		if rnd.Intn(2) == 0 {
+			rnd.Read(data[i:end])
+		}
+	}
+
+	var compressed bytes.Buffer
+	w, err := flate.NewWriter(&compressed, flate.DefaultCompression)
+	if err != nil {
+		return 0, err
+	}
+	w.Write(data)
+	w.Close()
+
+	start := time.Now()
+	n, err := io.Copy(io.Discard, flate.NewReader(&compressed))
+	if err != nil {
+		return 0, err
+	}
+	return float64(n) / time.Since(start).Seconds(), nil
+}
+
+// Combine возвращает скорость установки при последовательной распаковке и записи
+func Combine(writeRate, inflateRate float64) float64 {
+	if writeRate <= 0 || inflateRate <= 0 {
+		return 0
+	}
+	return 1 / (1/writeRate + 1/inflateRate)
+}
+
+// Tracker считает оставшееся время установки. В начале используется скорость
+// из замера, затем все больший вес получает фактическая скорость.
+type Tracker struct {
+	mu    sync.Mutex
+	total int64
+	done  int64
+	rate  float64 // Скорость из замера, байт в секунду
+	start time.Time
+}
+
+// minObserved — сколько нужно проработать, чтобы фактической скорости можно было верить
+const minObserved = 3 * time.Second
+
+// NewTracker создает счетчик для установки total байт
+func NewTracker(total int64) *Tracker {
+	return &Tracker{total: total, start: time.Now()}
+}
+
+// Calibrate задает скорость, измеренную до начала установки
+func (t *Tracker) Calibrate(rate float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rate = rate
+	t.start = time.Now()
+	t.done = 0
+}
+
+// Add учитывает записанные байты
+func (t *Tracker) Add(n int64) {
+	t.mu.Lock()
+	t.done += n
+	t.mu.Unlock()
+}
+
+// Remaining возвращает оценку оставшегося времени. Второе значение равно false,
+// если оценивать пока не по чему.
+func (t *Tracker) Remaining() (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.total <= 0 {
+		return 0, false
+	}
+	left := float64(t.total - t.done)
+	if left < 0 {
+		left = 0
+	}
+
+	rate := t.rate
+	elapsed := time.Since(t.start)
+	if elapsed >= minObserved && t.done > 0 {
+		observed := float64(t.done) / elapsed.Seconds()
+		// К пятой части установки оценка полностью опирается на фактическую скорость
+		weight := min(1, 5*float64(t.done)/float64(t.total))
+		if rate <= 0 {
+			weight = 1
+		}
+		rate = rate*(1-weight) + observed*weight
+	}
+	if rate <= 0 {
+		return 0, false
+	}
+	return time.Duration(left / rate * float64(time.Second)), true
+}
+
+// Grow увеличивает объем установки, когда становится известен размер очередного архива
+func (t *Tracker) Grow(n int64) {
+	t.mu.Lock()
+	t.total += n
+	t.mu.Unlock()
+}
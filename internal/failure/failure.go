@@ -0,0 +1,82 @@
+// Package failure делит ошибки установки на классы для программ, которые
+// запускают установщик: у каждого класса свой код выхода процесса и свой код
+// error_code в JSON событий API и веб-хуков. Текст ошибки для человека и может
+// меняться, а коды — нет.
+package failure
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"syscall"
+)
+
+// Kind — класс ошибки
+type Kind string
+
+// Классы ошибок. Коды выхода в комментариях; 0 — установка завершена.
+const (
+	Other       Kind = "other"       // 1: прочие ошибки
+	Config      Kind = "config"      // 2: ошибка в конфигурации установщика или файле ответов
+	Space       Kind = "space"       // 3: не хватает места на диске
+	Download    Kind = "download"    // 4: файлы игры не удалось получить: сеть, вход, источник
+	Extraction  Kind = "extraction"  // 5: архивы повреждены или не распаковываются
+	Permissions Kind = "permissions" // 6: нет прав на запись или нужны права администратора
+	Cancelled   Kind = "cancelled"   // 7: установку отменили или отказали в ней до начала
+)
+
+// exitCodes — коды выхода классов
+var exitCodes = map[Kind]int{
+	Other:       1,
+	Config:      2,
+	Space:       3,
+	Download:    4,
+	Extraction:  5,
+	Permissions: 6,
+	Cancelled:   7,
+}
+
+// ExitCode возвращает код выхода установщика для класса
+func (k Kind) ExitCode() int {
+	if code, ok := exitCodes[k]; ok {
+		return code
+	}
+	return exitCodes[Other]
+}
+
+// FromExitCode возвращает класс по коду выхода установщика, пустой для 0
+func FromExitCode(code int) Kind {
+	if code == 0 {
+		return ""
+	}
+	for kind, c := range exitCodes {
+		if c == code {
+			return kind
+		}
+	}
+	return Other
+}
+
+// Error — ошибка установки с классом
+type Error struct {
+	Kind    Kind
+	Message string
+}
+
+func (e Error) Error() string {
+	return e.Message
+}
+
+// Classify определяет класс ошибки файловой системы: нехватка места и прав
+// видна по коду ошибки. Остальные ошибки получают класс fallback.
+func Classify(err error, fallback Kind) Kind {
+	switch {
+	case errors.Is(err, fs.ErrPermission), errors.Is(err, syscall.EROFS):
+		return Permissions
+	case errors.Is(err, syscall.ENOSPC), errors.Is(err, syscall.EDQUOT):
+		return Space
+	case errors.Is(err, context.Canceled):
+		return Cancelled
+	}
+	return fallback
+}
@@ -0,0 +1,205 @@
+// Package flatpak упаковывает установленную игру в локальный Flatpak: собирает
+// приложение на среде выполнения Freedesktop, экспортирует его в репозиторий
+// пользователя и устанавливает оттуда. Игра запускается в песочнице и удаляется
+// средствами самого flatpak.
+package flatpak
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang-installer/internal/runner"
+)
+
+const (
+	// Runtime — среда выполнения и SDK, на которых собирается приложение
+	Runtime = "org.freedesktop.Platform"
+	SDK     = "org.freedesktop.Sdk"
+	// DefaultRuntimeVersion — ветка среды выполнения по умолчанию
+	DefaultRuntimeVersion = "24.08"
+)
+
+// gameDir — директория игры внутри приложения
+const gameDir = "/app/game"
+
+// timeout — установка может скачивать среду выполнения с Flathub
+const timeout = 30 * time.Minute
+
+// permissions — доступ, который нужен большинству игр
+var permissions = []string{
+	"--share=ipc", "--share=network", "--socket=x11", "--socket=wayland",
+	"--socket=pulseaudio", "--device=all",
+}
+
+// Export — установленное приложение Flatpak и локальный репозиторий, из которого оно пришло
+type Export struct {
+	AppID  string `json:"app_id"`
+	Remote string `json:"remote"`
+	Repo   string `json:"repo"`
+}
+
+// Options — что и как упаковать
+type Options struct {
+	AppID          string
+	Slug           string
+	Source         string   // Директория установленной игры
+	Exclude        []string // Записи верхнего уровня, которые не входят в приложение
+	Exec           string   // Исполняемый файл относительно Source
+	WorkDir        string   // Рабочая директория относительно Source
+	Args           []string
+	Env            []string // "ИМЯ=значение"
+	RuntimeVersion string
+}
+
+// Available сообщает, установлен ли flatpak
+func Available() bool {
+	_, err := exec.LookPath("flatpak")
+	return err == nil
+}
+
+// RepoDir — локальный репозиторий приложения игры
+func RepoDir(slug string) string {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(os.Getenv("HOME"), ".local", "share")
+	}
+	return filepath.Join(dataHome, "go-qt-installer", "flatpak", slug)
+}
+
+func flatpak(args ...string) error {
+	_, err := runner.Output(timeout, "flatpak", args...)
+	return err
+}
+
+// Build собирает приложение из установленной игры во временной директории tmpRoot,
+// экспортирует его и устанавливает для текущего пользователя. Повторная сборка той
+// же игры переустанавливает приложение.
+func Build(opts Options, tmpRoot string) (*Export, error) {
+	if opts.RuntimeVersion == "" {
+		opts.RuntimeVersion = DefaultRuntimeVersion
+	}
+	dir, err := os.MkdirTemp(tmpRoot, "go-qt-installer-flatpak-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	build := filepath.Join(dir, "build")
+	if err := flatpak("build-init", build, opts.AppID, SDK, Runtime, opts.RuntimeVersion); err != nil {
+		return nil, err
+	}
+	if err := copyTree(opts.Source, filepath.Join(build, "files", "game"), opts.Exclude); err != nil {
+		return nil, fmt.Errorf("ошибка при копировании игры: %v", err)
+	}
+
+	// Скрипт запуска внутри песочницы переходит в рабочую директорию и передает аргументы
+	script := "#!/bin/sh\ncd " + shellQuote(filepath.Join(gameDir, opts.WorkDir)) + " || exit 1\n"
+	script += "exec " + shellQuote(filepath.Join(gameDir, opts.Exec))
+	for _, arg := range opts.Args {
+		script += " " + shellQuote(arg)
+	}
+	script += " \"$@\"\n"
+	binDir := filepath.Join(build, "files", "bin")
+	if err := os.MkdirAll(binDir, 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(binDir, "run-game"), []byte(script), 0755); err != nil {
+		return nil, err
+	}
+
+	finish := append([]string{"build-finish", build, "--command=run-game"}, permissions...)
+	for _, kv := range opts.Env {
+		finish = append(finish, "--env="+kv)
+	}
+	if err := flatpak(finish...); err != nil {
+		return nil, err
+	}
+
+	e := &Export{AppID: opts.AppID, Remote: "go-qt-" + opts.Slug, Repo: RepoDir(opts.Slug)}
+	if err := flatpak("build-export", e.Repo, build); err != nil {
+		return nil, err
+	}
+	if err := flatpak("--user", "remote-add", "--if-not-exists", "--no-gpg-verify", e.Remote, e.Repo); err != nil {
+		return nil, err
+	}
+	if err := flatpak("--user", "install", "-y", "--noninteractive", "--reinstall", e.Remote, e.AppID); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Remove удаляет приложение, его репозиторий и источник из flatpak
+func Remove(e *Export) error {
+	if err := flatpak("--user", "uninstall", "-y", "--noninteractive", e.AppID); err != nil {
+		return err
+	}
+	flatpak("--user", "remote-delete", "--force", e.Remote)
+	return os.RemoveAll(e.Repo)
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// copyTree копирует директорию с правами и символическими ссылками, пропуская
+// записи верхнего уровня из exclude
+func copyTree(src, dst string, exclude []string) error {
+	skip := make(map[string]bool, len(exclude))
+	for _, name := range exclude {
+		skip[name] = true
+	}
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, path)
+		if rel != "." && skip[rel] {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			return copyFile(path, target, info.Mode().Perm())
+		}
+		return nil
+	})
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
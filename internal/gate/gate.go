@@ -0,0 +1,90 @@
+// Package gate — ограничения по возрасту и региону, которые некоторые издатели
+// обязаны показывать перед установкой. Установщик проверяет регион по языковым
+// настройкам системы и спрашивает дату рождения, а в запись об установке попадает
+// только факт подтверждения, без самой даты.
+package gate
+
+import (
+	"os"
+	"strings"
+	"time"
+)
+
+// Config — ограничения из конфигурации установщика
+type Config struct {
+	MinAge         int      `json:"min_age"`         // Минимальный возраст игрока, 0 — без проверки
+	BlockedLocales []string `json:"blocked_locales"` // "de_DE" — локаль, "de" — язык, "DE" — страна
+	Text           string   `json:"text"`            // Пояснение на странице проверки, допускается HTML
+}
+
+// Confirmation — результат проверки, сохраняется в записи об установке
+type Confirmation struct {
+	MinAge      int       `json:"min_age,omitempty"`
+	Locale      string    `json:"locale,omitempty"`
+	Method      string    `json:"method"` // birth_date или preseed
+	ConfirmedAt time.Time `json:"confirmed_at"`
+}
+
+const (
+	// MethodBirthDate — пользователь ввел дату рождения
+	MethodBirthDate = "birth_date"
+	// MethodPreseed — возраст подтвержден в файле ответов автоматической установки
+	MethodPreseed = "preseed"
+)
+
+// Enabled сообщает, задано ли хотя бы одно ограничение
+func (c Config) Enabled() bool {
+	return c.MinAge > 0 || len(c.BlockedLocales) > 0
+}
+
+// SystemLocale возвращает локаль системы без кодировки, например "ru_RU"
+func SystemLocale() string {
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		locale := os.Getenv(env)
+		if locale == "" {
+			continue
+		}
+		locale = strings.SplitN(locale, ".", 2)[0]
+		return strings.SplitN(locale, "@", 2)[0]
+	}
+	return ""
+}
+
+// Blocked сообщает, запрещена ли установка в локали locale. Локали C и POSIX
+// не говорят о регионе и не блокируются.
+func (c Config) Blocked(locale string) bool {
+	if locale == "" || locale == "C" || locale == "POSIX" {
+		return false
+	}
+	parts := strings.SplitN(locale, "_", 2)
+	language, country := parts[0], ""
+	if len(parts) == 2 {
+		country = parts[1]
+	}
+	for _, blocked := range c.BlockedLocales {
+		switch {
+		case strings.Contains(blocked, "_"):
+			if strings.EqualFold(blocked, locale) {
+				return true
+			}
+		case blocked == strings.ToUpper(blocked):
+			if country != "" && strings.EqualFold(blocked, country) {
+				return true
+			}
+		default:
+			if strings.EqualFold(blocked, language) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Age возвращает полное число лет на дату now для даты рождения birth
+func Age(birth, now time.Time) int {
+	age := now.Year() - birth.Year()
+	if now.Month() < birth.Month() || (now.Month() == birth.Month() && now.Day() < birth.Day()) {
+		age--
+	}
+	return age
+}
@@ -0,0 +1,221 @@
+// Package imagecache загружает иконки и баннеры из директорий игр или по URL,
+// уменьшает их до нужного размера и хранит результат на диске. Вся работа идет
+// в фоновых горутинах, чтобы список игр в менеджере не подтормаживал.
+package imagecache
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Result — готовое изображение для источника
+type Result struct {
+	Source string
+	Path   string // Путь к уменьшенной копии в кэше
+	Err    error
+}
+
+// Cache хранит уменьшенные изображения в директории на диске
+type Cache struct {
+	dir     string
+	client  *http.Client
+	workers chan struct{}
+
+	mu       sync.Mutex
+	inflight map[string]bool
+}
+
+// DefaultDir возвращает директорию кэша в $XDG_CACHE_HOME
+func DefaultDir() string {
+	cacheHome := os.Getenv("XDG_CACHE_HOME")
+	if cacheHome == "" {
+		cacheHome = filepath.Join(os.Getenv("HOME"), ".cache")
+	}
+	return filepath.Join(cacheHome, "go-qt-installer", "images")
+}
+
+// New создает кэш в указанной директории
+func New(dir string) *Cache {
+	return &Cache{
+		dir:      dir,
+		client:   &http.Client{Timeout: 30 * time.Second},
+		workers:  make(chan struct{}, 4),
+		inflight: make(map[string]bool),
+	}
+}
+
+func isURL(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
+
+// cachePath возвращает путь к уменьшенной копии. Для локальных файлов в ключ входит
+// время изменения, чтобы обновленная картинка не бралась из старого кэша.
+func (c *Cache) cachePath(source string, maxW, maxH int) (string, error) {
+	key := fmt.Sprintf("%s|%dx%d", source, maxW, maxH)
+	if !isURL(source) {
+		info, err := os.Stat(source)
+		if err != nil {
+			return "", err
+		}
+		key += "|" + info.ModTime().UTC().Format(time.RFC3339Nano)
+	}
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, fmt.Sprintf("%x.png", sum[:16])), nil
+}
+
+// Get возвращает путь к уменьшенной копии, создавая ее при необходимости
+func (c *Cache) Get(source string, maxW, maxH int) (string, error) {
+	path, err := c.cachePath(source, maxW, maxH)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	img, err := c.decode(source)
+	if err != nil {
+		return "", err
+	}
+	img = Downscale(img, maxW, maxH)
+
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return "", fmt.Errorf("не удалось создать директорию кэша: %v", err)
+	}
+	tmp, err := os.CreateTemp(c.dir, ".tmp-*.png")
+	if err != nil {
+		return "", err
+	}
+	if err := png.Encode(tmp, img); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	tmp.Close()
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return path, nil
+}
+
+// Load готовит изображение в фоне и отправляет результат в канал.
+// Повторные запросы того же источника, пока первый не завершен, игнорируются.
+func (c *Cache) Load(source string, maxW, maxH int, results chan<- Result) {
+	key := fmt.Sprintf("%s|%dx%d", source, maxW, maxH)
+	c.mu.Lock()
+	if c.inflight[key] {
+		c.mu.Unlock()
+		return
+	}
+	c.inflight[key] = true
+	c.mu.Unlock()
+
+	go func() {
+		c.workers <- struct{}{}
+		path, err := c.Get(source, maxW, maxH)
+		<-c.workers
+
+		c.mu.Lock()
+		delete(c.inflight, key)
+		c.mu.Unlock()
+
+		results <- Result{Source: source, Path: path, Err: err}
+	}()
+}
+
+func (c *Cache) decode(source string) (image.Image, error) {
+	var r io.ReadCloser
+	if isURL(source) {
+		resp, err := c.client.Get(source)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при загрузке %s: %v", source, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("ошибка при загрузке %s: %s", source, resp.Status)
+		}
+		r = resp.Body
+	} else {
+		f, err := os.Open(source)
+		if err != nil {
+			return nil, err
+		}
+		r = f
+	}
+	defer r.Close()
+
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать изображение %s: %v", source, err)
+	}
+	return img, nil
+}
+
+// Downscale уменьшает изображение с сохранением пропорций так, чтобы оно поместилось
+// в maxW×maxH. Каждый пиксель результата — среднее по соответствующей области исходника.
+func Downscale(src image.Image, maxW, maxH int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxW && h <= maxH || w == 0 || h == 0 {
+		return src
+	}
+
+	scale := float64(maxW) / float64(w)
+	if s := float64(maxH) / float64(h); s < scale {
+		scale = s
+	}
+	dw, dh := int(float64(w)*scale), int(float64(h)*scale)
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := b.Min.Y+y*h/dh, b.Min.Y+(y+1)*h/dh
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < dw; x++ {
+			x0, x1 := b.Min.X+x*w/dw, b.Min.X+(x+1)*w/dw
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			if a == 0 {
+				continue
+			}
+			// Усредняем в premultiplied-виде и переводим обратно в NRGBA
+			dst.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(r * 0xff / a),
+				G: uint8(g * 0xff / a),
+				B: uint8(bl * 0xff / a),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,141 @@
+// Package integrity проверяет файлы установленной игры без установщика, как
+// «Проверить целостность» в Steam. Файлы сверяются с манифестом обновлений,
+// если в нем та же версия, что установлена, а иначе с записью последней
+// синхронизации в директории игры. Поврежденные и пропавшие файлы по манифесту
+// обновлений можно скачать заново; по записи синхронизации — только найти.
+package integrity
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"golang-installer/internal/common"
+	"golang-installer/internal/manifest"
+	"golang-installer/internal/source"
+)
+
+// Result — итог проверки
+type Result struct {
+	Manifest *manifest.Manifest
+	Plan     *manifest.Plan
+	// Source — с чем сверялись файлы, для отчета
+	Source string
+	// CanFix — в манифесте есть ссылки на файлы, и их можно скачать заново
+	CanFix bool
+}
+
+// Damaged возвращает поврежденные и пропавшие файлы
+func (r *Result) Damaged() []string {
+	var paths []string
+	for _, f := range r.Plan.Fetch {
+		paths = append(paths, f.Path)
+	}
+	return paths
+}
+
+// Load выбирает, с чем сверять файлы игры. Сама проверка — Verify.
+func Load(ctx context.Context, info *common.InstallInfo) (*Result, error) {
+	if _, err := os.Stat(info.InstallPath); err != nil {
+		return nil, fmt.Errorf("директория игры недоступна: %v", err)
+	}
+	r := &Result{}
+	if info.UpdateManifest != "" {
+		m, err := remoteManifest(ctx, info.UpdateManifest)
+		switch {
+		case err != nil:
+			log.Printf("%s: манифест обновлений недоступен, проверка по записи синхронизации: %v", info.GameName, err)
+		case m.Version != "" && info.Version != "" && m.Version != info.Version:
+			// Файлы новой версии отличаются от установленных, это не повреждение
+			log.Printf("%s: в манифесте обновлений версия %s, установлена %s, проверка по записи синхронизации",
+				info.GameName, m.Version, info.Version)
+		default:
+			r.Manifest, r.Source, r.CanFix = m, "манифест "+info.UpdateManifest, true
+		}
+	}
+	if r.Manifest == nil {
+		m, err := manifest.Installed(info.InstallPath)
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("игра установлена из архивов, а не по манифесту: сверять файлы не с чем")
+		}
+		if err != nil {
+			return nil, err
+		}
+		r.Manifest, r.Source = m, "запись синхронизации "+filepath.Join(info.InstallPath, manifest.StateFile)
+	}
+	return r, nil
+}
+
+// Verify сверяет файлы игры по хэшам. onPiece получает каждый проверенный участок.
+func (r *Result) Verify(info *common.InstallInfo, onPiece func(manifest.Piece)) error {
+	plan, err := manifest.CompareProgress(r.Manifest, info.InstallPath, info.UserData, true, onPiece)
+	if err != nil {
+		return err
+	}
+	// Файлы прежних версий проверка не удаляет: они не повреждение
+	plan.Remove = nil
+	r.Plan = plan
+	log.Printf("%s: проверка файлов (%s): совпадает %d, повреждено или нет %d",
+		info.GameName, r.Source, plan.UpToDate, len(plan.Fetch))
+	return nil
+}
+
+// Available сообщает, что файлы игры есть с чем сверить
+func Available(info *common.InstallInfo) bool {
+	if info.UpdateManifest != "" {
+		return true
+	}
+	_, err := os.Stat(filepath.Join(info.InstallPath, manifest.StateFile))
+	return err == nil
+}
+
+// Fix после Verify скачивает заново поврежденные и пропавшие файлы. progress получает
+// число полученных байт из total.
+func Fix(ctx context.Context, info *common.InstallInfo, r *Result, progress func(done, total int64)) error {
+	if !r.CanFix {
+		return fmt.Errorf("файлы сверялись с записью синхронизации, скачать их заново можно только установщиком")
+	}
+	total := r.Plan.FetchBytes()
+	if source.Hashes == nil {
+		source.Hashes = make(map[string]string)
+	}
+	for ref, hash := range r.Manifest.Hashes(r.Plan.Fetch) {
+		source.Hashes[ref] = hash
+	}
+	tempDir, _ := source.TempDir()
+	opts := manifest.Options{
+		Fetch: func(ctx context.Context, ref string, progress func(done, total int64)) (string, error) {
+			return source.Fetch(ctx, ref, progress)
+		},
+		Progress: func(done int64) {
+			if progress != nil {
+				progress(done, total)
+			}
+		},
+		Release: func(path string) {
+			if tempDir != "" && filepath.Dir(path) == tempDir {
+				os.Remove(path)
+			}
+		},
+	}
+	if err := manifest.Apply(ctx, r.Manifest, info.InstallPath, r.Plan, opts); err != nil {
+		return err
+	}
+	log.Printf("%s: заново получено файлов: %d (%.1f МБ)", info.GameName, len(r.Plan.Fetch), float64(total)/1024/1024)
+	return nil
+}
+
+// remoteManifest скачивает свежий манифест обновлений
+func remoteManifest(ctx context.Context, ref string) (*manifest.Manifest, error) {
+	if source.Fresh == nil {
+		source.Fresh = make(map[string]bool)
+	}
+	source.Fresh[ref] = true
+	file, err := source.Fetch(ctx, ref, nil)
+	if err != nil {
+		return nil, err
+	}
+	return manifest.Load(file)
+}
@@ -0,0 +1,103 @@
+// Package itch обновляет игры патчами itch.io в формате wharf (.pwr) и проверяет
+// результат по подписи (.pws). Сами патчи применяет программа butler: ее кладут
+// рядом с установщиком или устанавливают в PATH.
+package itch
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"golang-installer/internal/archive"
+	"golang-installer/internal/runner"
+)
+
+// verifyTimeout — проверка читает всю игру целиком
+const verifyTimeout = time.Hour
+
+// Butler возвращает путь к butler или ошибку, если он не найден
+func Butler() (string, error) {
+	return archive.FindHelper("butler")
+}
+
+// message — строка вывода butler с флагом --json
+type message struct {
+	Type       string  `json:"type"`
+	Level      string  `json:"level"`
+	Message    string  `json:"message"`
+	Percentage float64 `json:"percentage"`
+}
+
+// Apply применяет патч к директории dir. Файлы сначала собираются в staging, поэтому
+// прерванное обновление не оставляет игру наполовину пропатченной. progress получает
+// долю выполненной работы от 0 до 1 и может быть nil.
+func Apply(ctx context.Context, patch, dir, staging string, progress func(float64)) error {
+	butler, err := Butler()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(staging, 0755); err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	cmd, err := runner.Command(ctx, butler, "apply", "--json", "--staging-dir="+staging, patch, dir)
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	var lastError string
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		var m message
+		if json.Unmarshal(scanner.Bytes(), &m) != nil {
+			continue
+		}
+		switch m.Type {
+		case "progress":
+			if progress != nil {
+				progress(m.Percentage)
+			}
+		case "error":
+			lastError = m.Message
+		case "log":
+			log.Printf("butler: %s", m.Message)
+		}
+	}
+
+	if err := cmd.Wait(); err != nil {
+		if lastError != "" {
+			return fmt.Errorf("%s", lastError)
+		}
+		return fmt.Errorf("butler apply: %v", err)
+	}
+	return nil
+}
+
+// Verify сверяет директорию dir с подписью signature
+func Verify(signature, dir string) error {
+	butler, err := Butler()
+	if err != nil {
+		return err
+	}
+	out, err := runner.Output(verifyTimeout, butler, "verify", signature, dir)
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%v: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+// Package keyring хранит секреты — токены входа, ключи активации, пароли с дополнительных
+// страниц — в связке ключей рабочего стола, а не открытым текстом в JSON. По умолчанию
+// используется Secret Service (GNOME Keyring, KWallet и совместимые) через secret-tool
+// из libsecret, поэтому установщику не нужна привязка к D-Bus.
+package keyring
+
+import (
+	"errors"
+)
+
+// Service — атрибут service, под которым лежат секреты установщика. По нему секрет
+// находит и сама игра: secret-tool lookup service go-qt-installer key <ключ>.
+const Service = "go-qt-installer"
+
+var (
+	// ErrNotFound — секрета с таким ключом нет
+	ErrNotFound = errors.New("секрет не найден в связке ключей")
+	// ErrUnavailable — связки ключей нет, секрет сохранить негде
+	ErrUnavailable = errors.New("связка ключей недоступна")
+)
+
+// Backend — хранилище секретов
+type Backend interface {
+	// Set сохраняет secret под ключом key; label видит пользователь в менеджере паролей
+	Set(key, label, secret string) error
+	// Get возвращает секрет или ErrNotFound
+	Get(key string) (string, error)
+	// Delete удаляет секрет; отсутствие секрета ошибкой не считается
+	Delete(key string) error
+}
+
+// Default — хранилище, через которое работают функции пакета
+var Default Backend = secretTool{}
+
+// Set сохраняет секрет в хранилище по умолчанию
+func Set(key, label, secret string) error {
+	return Default.Set(key, label, secret)
+}
+
+// Get возвращает секрет из хранилища по умолчанию
+func Get(key string) (string, error) {
+	return Default.Get(key)
+}
+
+// Delete удаляет секрет из хранилища по умолчанию
+func Delete(key string) error {
+	return Default.Delete(key)
+}
@@ -0,0 +1,56 @@
+package keyring
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang-installer/internal/runner"
+)
+
+// secretTool — Secret Service через secret-tool из libsecret
+type secretTool struct{}
+
+// attributes превращает ключ в атрибуты secret-tool
+func attributes(key string) []string {
+	return []string{"service", Service, "key", key}
+}
+
+func (secretTool) Set(key, label, secret string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), runner.DefaultTimeout)
+	defer cancel()
+	cmd, err := runner.Command(ctx, "secret-tool", append([]string{"store", "--label=" + label}, attributes(key)...)...)
+	if err != nil {
+		return ErrUnavailable
+	}
+	// Секрет передается через stdin, чтобы не попасть в список процессов
+	cmd.Stdin = strings.NewReader(secret)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("не удалось сохранить секрет в связке ключей: %v %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func (secretTool) Get(key string) (string, error) {
+	out, err := runner.Output(runner.DefaultTimeout, "secret-tool", append([]string{"lookup"}, attributes(key)...)...)
+	if errors.Is(err, runner.ErrNotFound) {
+		return "", ErrUnavailable
+	}
+	// secret-tool завершается с кодом 1 и без вывода, если секрета нет
+	if err != nil || len(out) == 0 {
+		return "", ErrNotFound
+	}
+	return string(out), nil
+}
+
+func (secretTool) Delete(key string) error {
+	_, err := runner.Output(runner.DefaultTimeout, "secret-tool", append([]string{"clear"}, attributes(key)...)...)
+	if errors.Is(err, runner.ErrNotFound) {
+		return ErrUnavailable
+	}
+	return nil
+}
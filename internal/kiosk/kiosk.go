@@ -0,0 +1,129 @@
+// Package kiosk — режим киоска для демо-стендов и компьютерных клубов: окно
+// установщика без рамки на весь экран и без кнопки закрытия, установка
+// начинается сама, а выйти можно только сочетанием клавиш. Пока идет установка,
+// экран не гаснет и компьютер не засыпает: стенд с погасшим экраном выглядит
+// выключенным.
+package kiosk
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/therecipe/qt/core"
+	"github.com/therecipe/qt/gui"
+	"github.com/therecipe/qt/widgets"
+
+	"golang-installer/internal/runner"
+)
+
+// DefaultExitShortcut — сочетание клавиш выхода, если в конфигурации его нет.
+// Его трудно нажать случайно.
+const DefaultExitShortcut = "Ctrl+Alt+Shift+Q"
+
+// DefaultStartDelay — через столько секунд установка начинается сама
+const DefaultStartDelay = 10
+
+// Config — режим киоска в конфигурации установщика
+type Config struct {
+	Enabled      bool   `json:"enabled"`       // Включить режим киоска, как с ключом --kiosk
+	ExitShortcut string `json:"exit_shortcut"` // Сочетание клавиш выхода, по умолчанию Ctrl+Alt+Shift+Q
+	StartDelay   int    `json:"start_delay"`   // Через сколько секунд начать установку, по умолчанию 10, -1 — ждать нажатия
+	LaunchGame   bool   `json:"launch_game"`   // После установки сразу запустить игру вместо окна завершения
+}
+
+// Shortcut возвращает сочетание клавиш выхода
+func (c Config) Shortcut() string {
+	if c.ExitShortcut == "" {
+		return DefaultExitShortcut
+	}
+	return c.ExitShortcut
+}
+
+// Delay возвращает, через сколько установка начнется сама, или 0, если ее
+// начинает игрок
+func (c Config) Delay() time.Duration {
+	switch {
+	case c.StartDelay < 0:
+		return 0
+	case c.StartDelay == 0:
+		return DefaultStartDelay * time.Second
+	}
+	return time.Duration(c.StartDelay) * time.Second
+}
+
+// windowID — окно киоска, для которого приостанавливается хранитель экрана
+var windowID uintptr
+
+// Show показывает окно в режиме киоска: без рамки на весь экран. Закрыть его
+// можно только сочетанием клавиш c.Shortcut(). Содержимое окна остается своего
+// размера в середине экрана, поэтому размер ему задается заранее.
+func Show(window *widgets.QMainWindow, c Config) {
+	content := window.TakeCentralWidget()
+	container := widgets.NewQWidget(nil, 0)
+	layout := widgets.NewQGridLayout(container)
+	layout.AddWidget3(content, 0, 0, 1, 1, core.Qt__AlignCenter)
+	window.SetCentralWidget(container)
+
+	window.SetWindowFlags(core.Qt__Window | core.Qt__FramelessWindowHint)
+	window.ConnectCloseEvent(func(event *gui.QCloseEvent) {
+		// Alt+F4 и кнопки оконного менеджера не закрывают киоск
+		event.Ignore()
+	})
+	shortcut := widgets.NewQShortcut(window)
+	shortcut.SetKey(gui.NewQKeySequence2(c.Shortcut(), gui.QKeySequence__PortableText))
+	shortcut.SetContext(core.Qt__ApplicationShortcut)
+	shortcut.ConnectActivated(func() {
+		log.Printf("Выход из режима киоска по %s", c.Shortcut())
+		core.QCoreApplication_Exit(0)
+	})
+	window.ShowFullScreen()
+	windowID = window.WinId()
+}
+
+// Inhibitor не дает экрану погаснуть, а компьютеру уснуть
+type Inhibitor struct {
+	process *os.Process
+}
+
+// Inhibit запрещает гашение экрана и сон, пока не вызван Release или пока
+// установщик работает. who и why видны в списке запретов systemd-inhibit --list.
+func Inhibit(who, why string) *Inhibitor {
+	i := &Inhibitor{}
+	// Запрет logind держится, пока жив systemd-inhibit, а тот ждет выхода
+	// установщика: если установщик упадет, запрет снимется сам
+	cmd, err := runner.Command(context.Background(), "systemd-inhibit", "--what=idle:sleep", "--who="+who, "--why="+why,
+		"--mode=block", "tail", fmt.Sprintf("--pid=%d", os.Getpid()), "-f", "/dev/null")
+	if err == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		if err := cmd.Start(); err != nil {
+			log.Printf("Не удалось запретить сон: %v", err)
+		} else {
+			i.process = cmd.Process
+			go cmd.Wait()
+		}
+	}
+	// Хранитель экрана X11 запрет logind не всегда учитывает
+	if windowID != 0 {
+		runner.Run("xdg-screensaver", "suspend", fmt.Sprint(windowID))
+	}
+	return i
+}
+
+// Release снимает запрет. Вызывать можно и у nil.
+func (i *Inhibitor) Release() {
+	if i == nil {
+		return
+	}
+	if i.process != nil {
+		// systemd-inhibit и tail в одной группе процессов
+		syscall.Kill(-i.process.Pid, syscall.SIGTERM)
+		i.process = nil
+	}
+	if windowID != 0 {
+		runner.Run("xdg-screensaver", "resume", fmt.Sprint(windowID))
+	}
+}
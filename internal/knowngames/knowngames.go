@@ -0,0 +1,144 @@
+// Package knowngames узнает игры, установленные вручную, по списку известных игр:
+// относительному пути к исполняемому файлу и, если задан, его хэшу SHA-256.
+// Так игры, поставленные без установщика, можно добавить в реестр менеджера.
+package knowngames
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileName — имя файла со списком известных игр
+const FileName = "known-games.json"
+
+// maxDepth — на сколько уровней вниз от выбранной директории ищется директория игры.
+// Пользователь может указать директорию, в которой лежит папка с игрой.
+const maxDepth = 3
+
+// Game — описание известной игры
+type Game struct {
+	Name    string   `json:"name"`
+	Slug    string   `json:"slug,omitempty"`
+	Version string   `json:"version,omitempty"`
+	Exec    string   `json:"exec"`             // Исполняемый файл относительно директории игры
+	SHA256  string   `json:"sha256,omitempty"` // Хэш исполняемого файла, если версии различаются файлами
+	Icon    string   `json:"icon,omitempty"`   // Иконка относительно директории игры
+	WorkDir string   `json:"work_dir,omitempty"`
+	Args    []string `json:"args,omitempty"`
+}
+
+// Match — найденная на диске игра
+type Match struct {
+	Game Game
+	Root string // Директория игры
+}
+
+// Load читает списки из файлов paths и объединяет их. Отсутствующие файлы пропускаются.
+func Load(paths ...string) ([]Game, error) {
+	var games []Game
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при чтении файла %s: %v", path, err)
+		}
+		var list []Game
+		if err := json.Unmarshal(data, &list); err != nil {
+			return nil, fmt.Errorf("ошибка при разборе %s: %v", path, err)
+		}
+		for _, g := range list {
+			if g.Name == "" || g.Exec == "" || filepath.IsAbs(g.Exec) {
+				continue
+			}
+			g.Exec = filepath.Clean(g.Exec)
+			games = append(games, g)
+		}
+	}
+	return games, nil
+}
+
+// Find ищет известные игры в dir и ее поддиректориях. Если для исполняемого файла
+// задан хэш, подходят только файлы с этим хэшем; так различаются версии одной игры.
+func Find(games []Game, dir string) ([]Match, error) {
+	dir = filepath.Clean(dir)
+	depth := maxDepth
+	for _, g := range games {
+		if d := maxDepth + strings.Count(g.Exec, string(os.PathSeparator)); d > depth {
+			depth = d
+		}
+	}
+	var matches []Match
+	seen := make(map[string]bool)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Недоступные поддиректории не мешают искать в остальных
+			if path != dir && d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		if d.IsDir() {
+			if rel != "." && strings.Count(rel, string(os.PathSeparator)) >= depth {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		for _, g := range games {
+			root, ok := rootFor(path, g.Exec)
+			if !ok || seen[root] || !within(root, dir) {
+				continue
+			}
+			if g.SHA256 != "" {
+				sum, err := fileHash(path)
+				if err != nil || !strings.EqualFold(sum, g.SHA256) {
+					continue
+				}
+			}
+			seen[root] = true
+			matches = append(matches, Match{Game: g, Root: root})
+		}
+		return nil
+	})
+	return matches, err
+}
+
+// rootFor возвращает директорию игры, если path оканчивается на относительный путь exec
+func rootFor(path, exec string) (string, bool) {
+	suffix := string(os.PathSeparator) + exec
+	if !strings.HasSuffix(path, suffix) {
+		return "", false
+	}
+	return strings.TrimSuffix(path, suffix), true
+}
+
+func within(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(os.PathSeparator))
+}
+
+func fileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,279 @@
+// Package lanshare раздает ресурсы игры другим установщикам в локальной сети.
+// Компьютер, на котором ресурсы уже есть, запускает HTTP-сервер с кодом доступа
+// и объявляет его через mDNS; установщики на других компьютерах находят его
+// и скачивают архивы у него, а не из интернета.
+package lanshare
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Manifest описывает, что раздает сервер
+type Manifest struct {
+	Game    string           `json:"game"`
+	Version string           `json:"version"`
+	Assets  map[string]int64 `json:"assets"` // Ссылка из game_assets → размер файла
+}
+
+// Сколько раз подряд можно ошибиться в коде доступа, прежде чем адрес
+// блокируется. Каждая следующая блокировка вдвое длиннее предыдущей.
+const (
+	maxFailures = 5
+	lockout     = 30 * time.Second
+	maxLockout  = 30 * time.Minute
+)
+
+// attempts — неудачные попытки ввести код с одного адреса
+type attempts struct {
+	failures int
+	lockouts int
+	until    time.Time
+}
+
+// Server раздает файлы по ссылкам из конфигурации установщика
+type Server struct {
+	Token    string // Код доступа, который нужно ввести на другом компьютере
+	Port     int
+	manifest Manifest
+	paths    map[string]string
+	http     *http.Server
+	mdns     *responder
+
+	mu       sync.Mutex
+	attempts map[string]*attempts // Адрес клиента без порта → попытки
+	now      func() time.Time
+}
+
+// newToken создает короткий код доступа, который удобно продиктовать
+func newToken() (string, error) {
+	buf := make([]byte, 5)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	code := base32.StdEncoding.EncodeToString(buf)
+	return code[:4] + "-" + code[4:], nil
+}
+
+// normalizeToken убирает различия в регистре, пробелах и дефисах при вводе кода
+func normalizeToken(token string) string {
+	token = strings.ToUpper(token)
+	token = strings.ReplaceAll(token, "-", "")
+	return strings.ReplaceAll(token, " ", "")
+}
+
+// Serve начинает раздачу файлов paths (ссылка → локальный путь)
+func Serve(game, version string, paths map[string]string) (*Server, error) {
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		Token:    token,
+		manifest: Manifest{Game: game, Version: version, Assets: make(map[string]int64)},
+		paths:    paths,
+		attempts: make(map[string]*attempts),
+		now:      time.Now,
+	}
+	for ref, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		s.manifest.Assets[ref] = info.Size()
+	}
+
+	listener, err := net.Listen("tcp4", ":0")
+	if err != nil {
+		return nil, err
+	}
+	s.Port = listener.Addr().(*net.TCPAddr).Port
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/manifest", s.authorized(s.handleManifest))
+	mux.HandleFunc("/asset", s.authorized(s.handleAsset))
+	s.http = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go s.http.Serve(listener)
+
+	s.mdns, err = announce(game, version, s.Port)
+	if err != nil {
+		s.http.Close()
+		return nil, fmt.Errorf("не удалось объявить раздачу в сети: %v", err)
+	}
+	return s, nil
+}
+
+// authorized пропускает только запросы с правильным кодом доступа. После
+// maxFailures неверных кодов подряд адрес клиента на время блокируется, чтобы
+// код нельзя было подобрать перебором.
+func (s *Server) authorized(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		if wait := s.locked(host); wait > 0 {
+			w.Header().Set("Retry-After", fmt.Sprint(int(wait.Seconds()+1)))
+			http.Error(w, "слишком много неверных кодов доступа", http.StatusTooManyRequests)
+			return
+		}
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(normalizeToken(token)), []byte(normalizeToken(s.Token))) != 1 {
+			s.fail(host)
+			http.Error(w, "неверный код доступа", http.StatusUnauthorized)
+			return
+		}
+		s.mu.Lock()
+		delete(s.attempts, host)
+		s.mu.Unlock()
+		h(w, r)
+	}
+}
+
+// locked возвращает, сколько еще заблокирован адрес host
+func (s *Server) locked(host string) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if a := s.attempts[host]; a != nil {
+		if wait := a.until.Sub(s.now()); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// fail учитывает неверный код с адреса host и блокирует адрес, если
+// неудачных попыток набралось maxFailures
+func (s *Server) fail(host string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := s.attempts[host]
+	if a == nil {
+		a = &attempts{}
+		s.attempts[host] = a
+	}
+	a.failures++
+	if a.failures < maxFailures {
+		return
+	}
+	wait := lockout << a.lockouts
+	if wait <= 0 || wait > maxLockout {
+		wait = maxLockout
+	}
+	a.failures = 0
+	a.lockouts++
+	a.until = s.now().Add(wait)
+}
+
+func (s *Server) handleManifest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.manifest)
+}
+
+func (s *Server) handleAsset(w http.ResponseWriter, r *http.Request) {
+	path, ok := s.paths[r.URL.Query().Get("ref")]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
+
+// Close останавливает раздачу
+func (s *Server) Close() error {
+	s.mdns.close()
+	return s.http.Close()
+}
+
+// Peer — найденный в сети компьютер, раздающий ресурсы
+type Peer struct {
+	Instance string // Имя в mDNS, например "Game (hostname)"
+	Game     string
+	Version  string
+	Addr     string // host:port
+	Token    string // Код доступа, вводится пользователем
+	assets   map[string]int64
+}
+
+func (p *Peer) get(ctx context.Context, path string) (*http.Response, error) {
+	req, err := p.Request(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		return nil, fmt.Errorf("неверный код доступа")
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		resp.Body.Close()
+		return nil, fmt.Errorf("слишком много неверных кодов доступа, повторите через %s с", resp.Header.Get("Retry-After"))
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s вернул %s", p.Addr, resp.Status)
+	}
+	return resp, nil
+}
+
+// Connect проверяет код доступа и загружает список раздаваемых файлов
+func (p *Peer) Connect(ctx context.Context) error {
+	resp, err := p.get(ctx, "/manifest")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var m Manifest
+	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+		return err
+	}
+	p.assets = m.Assets
+	return nil
+}
+
+// Has сообщает, раздает ли компьютер ресурс ref. Работает после Connect.
+func (p *Peer) Has(ref string) bool {
+	_, ok := p.assets[ref]
+	return ok
+}
+
+// Request создает запрос к компьютеру с кодом доступа. Для файла используйте AssetPath.
+func (p *Peer) Request(ctx context.Context, path string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+p.Addr+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.Token)
+	return req, nil
+}
+
+// AssetPath возвращает путь запроса для ресурса ref
+func AssetPath(ref string) string {
+	return "/asset?ref=" + url.QueryEscape(ref)
+}
@@ -0,0 +1,53 @@
+package lanshare
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAuthorizedLockout(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	s := &Server{Token: "ABCD-EFGH", attempts: make(map[string]*attempts), now: func() time.Time { return now }}
+	h := s.authorized(func(w http.ResponseWriter, r *http.Request) {})
+
+	request := func(addr, token string) int {
+		r := httptest.NewRequest(http.MethodGet, "/manifest", nil)
+		r.RemoteAddr = addr
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		h(w, r)
+		return w.Code
+	}
+
+	if code := request("192.168.1.5:4000", "abcd efgh"); code != http.StatusOK {
+		t.Fatalf("верный код: %d", code)
+	}
+	for i := 0; i < maxFailures; i++ {
+		if code := request("192.168.1.5:4000", "WRONG"); code != http.StatusUnauthorized {
+			t.Fatalf("попытка %d: %d", i+1, code)
+		}
+	}
+	// Заблокирован адрес, а не порт, и даже верный код не проходит
+	if code := request("192.168.1.5:4001", "ABCD-EFGH"); code != http.StatusTooManyRequests {
+		t.Errorf("после %d ошибок: %d, ожидалось 429", maxFailures, code)
+	}
+	if code := request("192.168.1.6:4000", "ABCD-EFGH"); code != http.StatusOK {
+		t.Errorf("другой адрес: %d", code)
+	}
+
+	now = now.Add(lockout)
+	for i := 0; i < maxFailures; i++ {
+		request("192.168.1.5:4000", "WRONG")
+	}
+	// Вторая блокировка вдвое длиннее первой
+	now = now.Add(lockout)
+	if code := request("192.168.1.5:4000", "ABCD-EFGH"); code != http.StatusTooManyRequests {
+		t.Errorf("вторая блокировка закончилась раньше времени: %d", code)
+	}
+	now = now.Add(lockout)
+	if code := request("192.168.1.5:4000", "ABCD-EFGH"); code != http.StatusOK {
+		t.Errorf("после второй блокировки: %d", code)
+	}
+}
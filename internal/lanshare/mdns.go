@@ -0,0 +1,244 @@
+package lanshare
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// serviceName — тип службы DNS-SD, под которым установщики ищут друг друга
+const serviceName = "_go-qt-installer._tcp.local."
+
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// responder отвечает на mDNS-запросы о службе установщика. Ответ отправляется
+// напрямую спросившему: так его получит и клиент, слушающий не 5353 порт.
+type responder struct {
+	conn     *net.UDPConn
+	instance dnsmessage.Name
+	host     dnsmessage.Name
+	port     int
+	txt      []string
+}
+
+func announce(game, version string, port int) (*responder, error) {
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "localhost"
+	}
+	hostname = strings.SplitN(hostname, ".", 2)[0]
+
+	// Точки в имени экземпляра разделили бы его на лишние метки
+	label := strings.ReplaceAll(game+" ("+hostname+")", ".", "_")
+	instance, err := dnsmessage.NewName(label + "." + serviceName)
+	if err != nil {
+		return nil, err
+	}
+	host, err := dnsmessage.NewName(hostname + ".local.")
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenMulticastUDP("udp4", nil, mdnsGroup)
+	if err != nil {
+		return nil, err
+	}
+	r := &responder{
+		conn:     conn,
+		instance: instance,
+		host:     host,
+		port:     port,
+		txt:      []string{"game=" + game, "version=" + version},
+	}
+	go r.serve()
+	return r, nil
+}
+
+func (r *responder) close() {
+	r.conn.Close()
+}
+
+func (r *responder) serve() {
+	buf := make([]byte, 9000)
+	for {
+		n, addr, err := r.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		if !asksForService(buf[:n]) {
+			continue
+		}
+		if reply, err := r.reply(); err == nil {
+			r.conn.WriteToUDP(reply, addr)
+		}
+	}
+}
+
+// asksForService проверяет, есть ли в пакете запрос PTR нашей службы
+func asksForService(msg []byte) bool {
+	var p dnsmessage.Parser
+	header, err := p.Start(msg)
+	if err != nil || header.Response {
+		return false
+	}
+	questions, err := p.AllQuestions()
+	if err != nil {
+		return false
+	}
+	for _, q := range questions {
+		if (q.Type == dnsmessage.TypePTR || q.Type == dnsmessage.TypeALL) &&
+			strings.EqualFold(q.Name.String(), serviceName) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *responder) reply() ([]byte, error) {
+	service := dnsmessage.MustNewName(serviceName)
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{Response: true, Authoritative: true})
+	b.EnableCompression()
+
+	if err := b.StartAnswers(); err != nil {
+		return nil, err
+	}
+	hdr := func(name dnsmessage.Name) dnsmessage.ResourceHeader {
+		return dnsmessage.ResourceHeader{Name: name, Class: dnsmessage.ClassINET, TTL: 120}
+	}
+	if err := b.PTRResource(hdr(service), dnsmessage.PTRResource{PTR: r.instance}); err != nil {
+		return nil, err
+	}
+
+	if err := b.StartAdditionals(); err != nil {
+		return nil, err
+	}
+	if err := b.SRVResource(hdr(r.instance), dnsmessage.SRVResource{Port: uint16(r.port), Target: r.host}); err != nil {
+		return nil, err
+	}
+	if err := b.TXTResource(hdr(r.instance), dnsmessage.TXTResource{TXT: r.txt}); err != nil {
+		return nil, err
+	}
+	addrs, _ := net.InterfaceAddrs()
+	for _, a := range addrs {
+		ipnet, ok := a.(*net.IPNet)
+		if !ok || ipnet.IP.IsLoopback() || ipnet.IP.To4() == nil {
+			continue
+		}
+		var ip [4]byte
+		copy(ip[:], ipnet.IP.To4())
+		if err := b.AResource(hdr(r.host), dnsmessage.AResource{A: ip}); err != nil {
+			return nil, err
+		}
+	}
+	return b.Finish()
+}
+
+// Discover ищет раздающие компьютеры в сети в течение wait
+func Discover(ctx context.Context, wait time.Duration) ([]*Peer, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{})
+	b.StartQuestions()
+	b.Question(dnsmessage.Question{
+		Name:  dnsmessage.MustNewName(serviceName),
+		Type:  dnsmessage.TypePTR,
+		Class: dnsmessage.ClassINET,
+	})
+	query, err := b.Finish()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.WriteToUDP(query, mdnsGroup); err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(wait)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetReadDeadline(deadline)
+
+	peers := make(map[string]*Peer)
+	var order []string
+	buf := make([]byte, 9000)
+	for {
+		n, addr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			// Истечение срока ожидания — нормальное завершение поиска
+			break
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		peer := parseReply(buf[:n], addr.IP)
+		if peer == nil {
+			continue
+		}
+		if _, seen := peers[peer.Addr]; !seen {
+			order = append(order, peer.Addr)
+		}
+		peers[peer.Addr] = peer
+	}
+
+	result := make([]*Peer, 0, len(order))
+	for _, addr := range order {
+		result = append(result, peers[addr])
+	}
+	return result, nil
+}
+
+// parseReply извлекает из ответа порт и описание раздачи. Адресом служит отправитель
+// пакета: он гарантированно доступен из нашей сети в отличие от всех его A-записей.
+func parseReply(msg []byte, from net.IP) *Peer {
+	var p dnsmessage.Parser
+	header, err := p.Start(msg)
+	if err != nil || !header.Response {
+		return nil
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return nil
+	}
+	answers, err := p.AllAnswers()
+	if err != nil {
+		return nil
+	}
+	if err := p.SkipAllAuthorities(); err != nil {
+		return nil
+	}
+	additionals, _ := p.AllAdditionals()
+
+	peer := &Peer{}
+	for _, res := range append(answers, additionals...) {
+		switch body := res.Body.(type) {
+		case *dnsmessage.PTRResource:
+			if strings.EqualFold(res.Header.Name.String(), serviceName) {
+				peer.Instance = strings.TrimSuffix(body.PTR.String(), "."+serviceName)
+			}
+		case *dnsmessage.SRVResource:
+			peer.Addr = net.JoinHostPort(from.String(), strconv.Itoa(int(body.Port)))
+		case *dnsmessage.TXTResource:
+			for _, kv := range body.TXT {
+				key, value, _ := strings.Cut(kv, "=")
+				switch key {
+				case "game":
+					peer.Game = value
+				case "version":
+					peer.Version = value
+				}
+			}
+		}
+	}
+	if peer.Instance == "" || peer.Addr == "" {
+		return nil
+	}
+	return peer
+}
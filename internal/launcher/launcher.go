@@ -0,0 +1,82 @@
+// Package launcher запускает игры, установщики и внешние программы так, чтобы они
+// не зависели от запустившего их процесса: в отдельной сессии, без унаследованного
+// терминала и дескрипторов. Завершившиеся процессы сразу забираются через Wait,
+// поэтому за установщиком и менеджером не остается зомби-процессов.
+package launcher
+
+import (
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+)
+
+// Spec описывает запускаемый процесс
+type Spec struct {
+	Path    string
+	Args    []string
+	Dir     string   // Рабочая директория, по умолчанию директория программы
+	Env     []string // Переменные "ИМЯ=значение" в дополнение к окружению текущего процесса
+	LogFile string   // Куда писать stdout и stderr, по умолчанию /dev/null
+}
+
+// Process — запущенный процесс
+type Process struct {
+	Pid int
+	// Done закрывается после завершения процесса; Err после этого содержит результат
+	Done chan struct{}
+	Err  error
+}
+
+// Start запускает процесс в новой сессии (setsid) и в фоне ждет его завершения,
+// записывая код выхода в журнал
+func Start(spec Spec) (*Process, error) {
+	cmd := exec.Command(spec.Path, spec.Args...)
+	cmd.Dir = spec.Dir
+	if cmd.Dir == "" {
+		cmd.Dir = filepath.Dir(spec.Path)
+	}
+	if len(spec.Env) > 0 {
+		cmd.Env = append(os.Environ(), spec.Env...)
+	}
+	// Новая сессия отвязывает процесс от терминала и группы процессов родителя:
+	// закрытие установщика или Ctrl+C в его терминале не завершат игру
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+
+	// os/exec открывает дескрипторы с O_CLOEXEC и передает только stdin, stdout и stderr,
+	// которые без явного назначения направляются в /dev/null
+	var logFile *os.File
+	if spec.LogFile != "" {
+		f, err := os.OpenFile(spec.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+		logFile = f
+		cmd.Stdout = f
+		cmd.Stderr = f
+	}
+
+	if err := cmd.Start(); err != nil {
+		if logFile != nil {
+			logFile.Close()
+		}
+		return nil, err
+	}
+	if logFile != nil {
+		// Дочерний процесс получил свою копию дескриптора
+		logFile.Close()
+	}
+
+	p := &Process{Pid: cmd.Process.Pid, Done: make(chan struct{})}
+	go func() {
+		p.Err = cmd.Wait()
+		if p.Err != nil {
+			log.Printf("Процесс %s (pid %d) завершился с ошибкой: %v", filepath.Base(spec.Path), p.Pid, p.Err)
+		} else {
+			log.Printf("Процесс %s (pid %d) завершился", filepath.Base(spec.Path), p.Pid)
+		}
+		close(p.Done)
+	}()
+	return p, nil
+}
@@ -0,0 +1,312 @@
+// Package maintenance — обслуживание установленной игры из менеджера: очистка
+// кэшей шейдеров и сброс настроек к состоянию сразу после установки.
+//
+// Кэш шейдеров игры — файлы DXVK и vkd3d-proton в директории игры, директории из
+// переменных окружения запуска и шаблоны shader_caches из конфигурации. Общий
+// кэш драйвера (Mesa, NVIDIA) один на все игры и очищается отдельно. Для сброса
+// настроек установщик сохраняет копии файлов настроек первого запуска в
+// logs/defaults директории игры; сброс убирает текущие файлы в корзину и
+// возвращает эти копии.
+package maintenance
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang-installer/internal/engine"
+	"golang-installer/internal/trash"
+)
+
+// gamePrefix и homePrefix отделяют копии файлов из директории игры от файлов в
+// домашней директории игрока
+const (
+	gamePrefix = "game/"
+	homePrefix = "home/"
+)
+
+// Config — обслуживание в конфигурации установщика и в записи об установке
+type Config struct {
+	ShaderCaches []string `json:"shader_caches,omitempty"` // Шаблоны кэша шейдеров игры относительно директории игры или от ~/
+	ConfigFiles  []string `json:"config_files,omitempty"`  // Шаблоны файлов настроек относительно директории игры или от ~/
+}
+
+// translationCache сообщает, что файл — кэш шейдеров, который DXVK или
+// vkd3d-proton пишет в рабочую директорию игры
+func translationCache(name string) bool {
+	return strings.HasSuffix(name, ".dxvk-cache") || strings.HasPrefix(name, "vkd3d-proton.cache")
+}
+
+// cacheEnv — переменные окружения, которыми игре назначают свой кэш шейдеров
+var cacheEnv = []string{"MESA_SHADER_CACHE_DIR", "DXVK_STATE_CACHE_PATH", "VKD3D_SHADER_CACHE_PATH", "__GL_SHADER_DISK_CACHE_PATH"}
+
+// Task — действие обслуживания: что будет удалено и сколько места освободится
+type Task struct {
+	Paths []string
+	Size  int64
+
+	// restore — копии первого запуска и куда их вернуть, только для сброса настроек
+	restore map[string]string
+}
+
+// Empty сообщает, что делать нечего
+func (t Task) Empty() bool {
+	return len(t.Paths) == 0 && len(t.restore) == 0
+}
+
+// GameShaderCache находит кэш шейдеров игры. env — переменные окружения запуска
+// "ИМЯ=значение".
+func GameShaderCache(installPath string, env []string, c Config) Task {
+	var t Task
+	logs := filepath.Join(installPath, "logs")
+	filepath.Walk(installPath, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() && p == logs {
+			return filepath.SkipDir
+		}
+		if info.Mode().IsRegular() && translationCache(info.Name()) {
+			t.add(p)
+		}
+		return nil
+	})
+	for _, p := range collect(installPath, c.ShaderCaches) {
+		if !t.has(p) {
+			t.add(p)
+		}
+	}
+	for _, v := range env {
+		name, value, _ := strings.Cut(v, "=")
+		for _, known := range cacheEnv {
+			if name != known || value == "" {
+				continue
+			}
+			dir := value
+			if !filepath.IsAbs(dir) {
+				dir = filepath.Join(installPath, dir)
+			}
+			// Общий кэш драйвера в кэш игры не входит, даже если указан явно
+			if !shared(dir) && !t.has(dir) {
+				t.add(dir)
+			}
+		}
+	}
+	return t
+}
+
+// SharedShaderCache находит общий кэш шейдеров драйверов. Его пересоберут все
+// игры, не только эта.
+func SharedShaderCache() Task {
+	var t Task
+	for _, dir := range sharedCaches() {
+		if _, err := os.Stat(dir); err == nil {
+			t.add(dir)
+		}
+	}
+	return t
+}
+
+// ResetConfig находит текущие файлы настроек и копии первого запуска
+func ResetConfig(installPath string, c Config) Task {
+	t := Task{restore: make(map[string]string)}
+	defaults := defaultsDir(installPath)
+	for _, p := range collect(installPath, c.ConfigFiles) {
+		if !strings.HasPrefix(p, defaults+string(filepath.Separator)) {
+			t.add(p)
+		}
+	}
+	filepath.Walk(defaults, func(p string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(defaults, p)
+		if err != nil {
+			return nil
+		}
+		if target, ok := target(installPath, filepath.ToSlash(rel)); ok {
+			t.restore[p] = target
+			// Возвращенная копия снова займет место
+			t.Size -= info.Size()
+		}
+		return nil
+	})
+	if t.Size < 0 {
+		t.Size = 0
+	}
+	return t
+}
+
+// Run выполняет действие. Кэши удаляются, файлы настроек уходят в корзину.
+func (t Task) Run() error {
+	for _, p := range t.Paths {
+		if t.restore != nil {
+			if _, err := trash.Move(p); err != nil {
+				return fmt.Errorf("не удалось переместить %s в корзину: %v", p, err)
+			}
+			continue
+		}
+		if err := os.RemoveAll(p); err != nil {
+			return err
+		}
+	}
+	for src, dst := range t.restore {
+		if err := copyFile(src, dst); err != nil {
+			return fmt.Errorf("не удалось вернуть %s: %v", dst, err)
+		}
+	}
+	return nil
+}
+
+// SaveDefaults сохраняет файлы настроек сразу после установки как копии первого
+// запуска. Уже сохраненные копии обновление не заменяет: к этому времени игрок
+// мог поменять настройки.
+func SaveDefaults(installPath string, c Config) error {
+	home := os.Getenv("HOME")
+	defaults := defaultsDir(installPath)
+	for _, p := range collect(installPath, c.ConfigFiles) {
+		var name string
+		if rel, err := filepath.Rel(installPath, p); err == nil && !strings.HasPrefix(rel, "..") {
+			name = gamePrefix + filepath.ToSlash(rel)
+		} else if rel, err := filepath.Rel(home, p); err == nil && !strings.HasPrefix(rel, "..") {
+			name = homePrefix + filepath.ToSlash(rel)
+		} else {
+			continue
+		}
+		dst := filepath.Join(defaults, filepath.FromSlash(name))
+		if _, err := os.Stat(dst); err == nil {
+			continue
+		}
+		if err := copyFile(p, dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *Task) add(p string) {
+	size := pathSize(p)
+	if size < 0 {
+		return
+	}
+	t.Paths = append(t.Paths, p)
+	t.Size += size
+}
+
+func (t *Task) has(p string) bool {
+	for _, existing := range t.Paths {
+		if existing == p {
+			return true
+		}
+	}
+	return false
+}
+
+func defaultsDir(installPath string) string {
+	return filepath.Join(installPath, "logs", "defaults")
+}
+
+// target возвращает, куда вернуть копию первого запуска с именем name
+func target(installPath, name string) (string, bool) {
+	if rest, ok := strings.CutPrefix(name, gamePrefix); ok {
+		if engine.CheckEntryName(installPath, rest) != "" {
+			return "", false
+		}
+		return filepath.Join(installPath, filepath.FromSlash(rest)), true
+	}
+	if rest, ok := strings.CutPrefix(name, homePrefix); ok {
+		home := os.Getenv("HOME")
+		if home == "" || engine.CheckEntryName(home, rest) != "" {
+			return "", false
+		}
+		return filepath.Join(home, filepath.FromSlash(rest)), true
+	}
+	return "", false
+}
+
+// collect находит файлы по шаблонам относительно директории игры или от ~/
+func collect(installPath string, patterns []string) []string {
+	home := os.Getenv("HOME")
+	found := make(map[string]bool)
+	for _, pattern := range patterns {
+		root := installPath
+		if rest, ok := strings.CutPrefix(pattern, "~/"); ok {
+			if home == "" {
+				continue
+			}
+			root, pattern = home, rest
+		}
+		// Обходим только неизменную часть шаблона до первого подстановочного символа
+		static := pattern
+		if i := strings.IndexAny(static, "*?["); i >= 0 {
+			static = path.Dir(static[:i+1])
+		}
+		filepath.Walk(filepath.Join(root, filepath.FromSlash(static)), func(p string, info os.FileInfo, err error) error {
+			if err != nil || !info.Mode().IsRegular() {
+				return nil
+			}
+			if rel, err := filepath.Rel(root, p); err == nil && engine.IsUserData([]string{pattern}, filepath.ToSlash(rel)) {
+				found[p] = true
+			}
+			return nil
+		})
+	}
+	paths := make([]string, 0, len(found))
+	for p := range found {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// sharedCaches — общие кэши шейдеров драйверов в $XDG_CACHE_HOME
+func sharedCaches() []string {
+	cache := os.Getenv("XDG_CACHE_HOME")
+	if cache == "" {
+		cache = filepath.Join(os.Getenv("HOME"), ".cache")
+	}
+	return []string{
+		filepath.Join(cache, "mesa_shader_cache"),
+		filepath.Join(cache, "mesa_shader_cache_db"),
+		filepath.Join(cache, "nvidia", "GLCache"),
+	}
+}
+
+func shared(dir string) bool {
+	for _, s := range sharedCaches() {
+		if filepath.Clean(dir) == s {
+			return true
+		}
+	}
+	return false
+}
+
+// pathSize возвращает объем файла или директории, -1 — если их нет
+func pathSize(p string) int64 {
+	if _, err := os.Lstat(p); err != nil {
+		return -1
+	}
+	var size int64
+	filepath.Walk(p, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+func copyFile(src, dst string) error {
+	data, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dst, data, 0644)
+}
@@ -0,0 +1,91 @@
+package manifest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang-installer/internal/downloadcache"
+)
+
+// FileName — манифест в директории публикации
+const FileName = "manifest.json"
+
+// ChunksDir — хранилище частей в директории публикации
+const ChunksDir = "chunks"
+
+// Build готовит публикацию версии игры из директории dir: делит файлы на части,
+// раскладывает части в out/chunks и записывает out/manifest.json. url — адрес,
+// по которому будет доступна директория out; без него используется путь к out.
+// Одинаковые части разных файлов и версий хранятся один раз, поэтому out можно
+// переиспользовать для следующей версии.
+func Build(dir, out, url, version string) (*Manifest, error) {
+	if url == "" {
+		abs, err := filepath.Abs(out)
+		if err != nil {
+			return nil, err
+		}
+		url = abs
+	}
+	m := &Manifest{Version: version, ChunkBase: strings.TrimSuffix(url, "/") + "/" + ChunksDir}
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		f := File{
+			Path:       filepath.ToSlash(rel),
+			Size:       info.Size(),
+			Executable: info.Mode().Perm()&0111 != 0,
+		}
+		if f.SHA256, err = downloadcache.FileHash(path); err != nil {
+			return err
+		}
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		err = Split(in, func(chunk []byte) error {
+			c := Chunk{SHA256: chunkHash(chunk), Size: int64(len(chunk))}
+			f.Chunks = append(f.Chunks, c)
+			return storeChunk(filepath.Join(out, ChunksDir), c.SHA256, chunk)
+		})
+		if err != nil {
+			return err
+		}
+		m.Files = append(m.Files, f)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return m, ioutil.WriteFile(filepath.Join(out, FileName), data, 0644)
+}
+
+// storeChunk записывает часть, если такой еще нет
+func storeChunk(root, hash string, data []byte) error {
+	path := filepath.Join(root, hash[:2], hash)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
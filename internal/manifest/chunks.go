@@ -0,0 +1,107 @@
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+)
+
+// Границы частей файла. Части режутся по содержимому (content-defined chunking):
+// точка разреза зависит только от последних байт перед ней, поэтому вставка
+// в начало огромного архива данных сдвигает лишь соседние части, а остальные
+// совпадают с прежней версией и повторно не скачиваются.
+const (
+	MinChunk = 1 << 20
+	MaxChunk = 4 << 20
+	// cutBits — столько старших бит скользящего хэша должны быть нулевыми
+	// в точке разреза: в среднем часть на 1 МБ длиннее минимальной
+	cutBits = 20
+)
+
+// Chunk — часть файла в манифесте
+type Chunk struct {
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// gear — таблица случайных чисел скользящего хэша. Она не должна меняться:
+// от нее зависят границы частей во всех опубликованных манифестах.
+var gear [256]uint64
+
+func init() {
+	// splitmix64 с фиксированным началом
+	x := uint64(0x6a09e667f3bcc908)
+	for i := range gear {
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		gear[i] = z ^ (z >> 31)
+	}
+}
+
+// cutPoint возвращает длину первой части в data. data содержит MaxChunk байт
+// или остаток файла.
+func cutPoint(data []byte) int {
+	if len(data) <= MinChunk {
+		return len(data)
+	}
+	var h uint64
+	for i := MinChunk; i < len(data); i++ {
+		h = h<<1 + gear[data[i]]
+		if h>>(64-cutBits) == 0 {
+			return i + 1
+		}
+	}
+	return len(data)
+}
+
+// Split делит поток на части и передает каждую в fn. Срез действителен только
+// до возврата из fn.
+func Split(r io.Reader, fn func(chunk []byte) error) error {
+	buf := make([]byte, MaxChunk)
+	n, eof := 0, false
+	for {
+		if !eof {
+			read, err := io.ReadFull(r, buf[n:])
+			n += read
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				eof = true
+			} else if err != nil {
+				return err
+			}
+		}
+		if n == 0 {
+			return nil
+		}
+		cut := cutPoint(buf[:n])
+		if err := fn(buf[:cut]); err != nil {
+			return err
+		}
+		n = copy(buf, buf[cut:n])
+	}
+}
+
+// chunkHash возвращает хэш части в виде, в котором он записан в манифесте
+func chunkHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// localChunk — часть, которая уже есть на диске в прежней версии файла
+type localChunk struct {
+	offset int64
+	size   int64
+}
+
+// indexChunks делит существующий файл на части и запоминает, где какая лежит
+func indexChunks(r io.Reader) (map[string]localChunk, error) {
+	index := make(map[string]localChunk)
+	var offset int64
+	err := Split(r, func(chunk []byte) error {
+		index[chunkHash(chunk)] = localChunk{offset: offset, size: int64(len(chunk))}
+		offset += int64(len(chunk))
+		return nil
+	})
+	return index, err
+}
@@ -0,0 +1,555 @@
+// Package manifest устанавливает и обновляет игру по манифесту — списку файлов
+// с размерами и хэшами, как «Проверить и синхронизировать» в Steam. Установщик
+// сравнивает манифест с директорией игры и скачивает только недостающие и
+// изменившиеся файлы, поэтому первая установка и обновление идут одним путем.
+package manifest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang-installer/internal/downloadcache"
+	"golang-installer/internal/engine"
+)
+
+// StateFile — описание файлов, записанных по манифесту, в директории игры. По нему
+// неизменившиеся файлы узнаются без чтения, а файлы, которых нет в новой версии,
+// удаляются.
+const StateFile = ".sync-manifest.json"
+
+// File — файл игры в манифесте
+type File struct {
+	Path       string  `json:"path"` // Путь относительно директории игры с разделителями "/"
+	Size       int64   `json:"size"`
+	SHA256     string  `json:"sha256"`
+	Executable bool    `json:"executable,omitempty"`
+	URL        string  `json:"url,omitempty"`    // Ссылка на файл, по умолчанию base_url + path
+	Chunks     []Chunk `json:"chunks,omitempty"` // Части файла; если заданы, файл собирается из них
+}
+
+// Manifest — файлы одной версии игры
+type Manifest struct {
+	Version   string `json:"version"`
+	BaseURL   string `json:"base_url"`  // Ссылка, к которой добавляются пути файлов
+	ChunkBase string `json:"chunk_url"` // Хранилище частей: часть лежит в <chunk_url>/<2 знака хэша>/<хэш>
+	Files     []File `json:"files"`
+}
+
+// Load читает манифест
+func Load(path string) (*Manifest, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при чтении манифеста: %v", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("ошибка при разборе манифеста: %v", err)
+	}
+	for _, f := range m.Files {
+		if f.Path == "" || f.SHA256 == "" {
+			return nil, fmt.Errorf("в манифесте файл без пути или хэша: %q", f.Path)
+		}
+		var chunked int64
+		for _, c := range f.Chunks {
+			if len(downloadcache.NormalizeHash(c.SHA256)) != sha256.Size*2 {
+				return nil, fmt.Errorf("в манифесте неверный хэш части файла %s", f.Path)
+			}
+			chunked += c.Size
+		}
+		if len(f.Chunks) > 0 && chunked != f.Size {
+			return nil, fmt.Errorf("в манифесте части файла %s не совпадают с его размером", f.Path)
+		}
+	}
+	return &m, nil
+}
+
+// URL возвращает ссылку на файл. В ссылках HTTP путь кодируется, ключи хранилищ
+// и пути на диске передаются как есть.
+func (m *Manifest) URL(f File) string {
+	if f.URL != "" {
+		return f.URL
+	}
+	name := f.Path
+	if strings.HasPrefix(m.BaseURL, "http://") || strings.HasPrefix(m.BaseURL, "https://") {
+		parts := strings.Split(f.Path, "/")
+		for i, part := range parts {
+			parts[i] = url.PathEscape(part)
+		}
+		name = strings.Join(parts, "/")
+	}
+	return strings.TrimSuffix(m.BaseURL, "/") + "/" + name
+}
+
+// ChunkURL возвращает ссылку на часть файла
+func (m *Manifest) ChunkURL(c Chunk) string {
+	hash := downloadcache.NormalizeHash(c.SHA256)
+	return strings.TrimSuffix(m.ChunkBase, "/") + "/" + hash[:2] + "/" + hash
+}
+
+// Hashes возвращает ожидаемые хэши всех ссылок, по которым будут получены files
+func (m *Manifest) Hashes(files []File) map[string]string {
+	hashes := make(map[string]string)
+	for _, f := range files {
+		if len(f.Chunks) == 0 {
+			hashes[m.URL(f)] = f.SHA256
+			continue
+		}
+		for _, c := range f.Chunks {
+			hashes[m.ChunkURL(c)] = c.SHA256
+		}
+	}
+	return hashes
+}
+
+// TotalBytes возвращает размер игры по манифесту
+func (m *Manifest) TotalBytes() int64 {
+	var total int64
+	for _, f := range m.Files {
+		total += f.Size
+	}
+	return total
+}
+
+// stateEntry — файл, записанный по манифесту, и его время изменения на диске
+type stateEntry struct {
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mtime"` // Наносекунды Unix
+	SHA256  string `json:"sha256"`
+}
+
+type state struct {
+	Version string                `json:"version"`
+	Files   map[string]stateEntry `json:"files"`
+}
+
+func loadState(root string) state {
+	s := state{Files: make(map[string]stateEntry)}
+	if data, err := ioutil.ReadFile(filepath.Join(root, StateFile)); err == nil {
+		json.Unmarshal(data, &s)
+	}
+	if s.Files == nil {
+		s.Files = make(map[string]stateEntry)
+	}
+	return s
+}
+
+// Installed возвращает манифест файлов, записанных при последней синхронизации
+// root. В нем нет ссылок и частей: по нему можно проверить файлы, но не скачать.
+func Installed(root string) (*Manifest, error) {
+	data, err := ioutil.ReadFile(filepath.Join(root, StateFile))
+	if err != nil {
+		return nil, err
+	}
+	var s state
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("ошибка при разборе %s: %v", StateFile, err)
+	}
+	m := &Manifest{Version: s.Version}
+	for name, entry := range s.Files {
+		m.Files = append(m.Files, File{Path: name, Size: entry.Size, SHA256: entry.SHA256})
+	}
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Path < m.Files[j].Path })
+	return m, nil
+}
+
+// Plan — что нужно сделать, чтобы директория игры совпала с манифестом
+type Plan struct {
+	Fetch    []File   // Недостающие и изменившиеся файлы
+	Remove   []string // Файлы прежней версии, которых нет в манифесте
+	UpToDate int      // Файлы, которые уже совпадают
+
+	Downloaded int64 // Сколько байт получено при Apply
+	Reused     int64 // Сколько байт частей взято из прежних версий файлов
+}
+
+// FetchBytes возвращает объем загрузки
+func (p *Plan) FetchBytes() int64 {
+	var total int64
+	for _, f := range p.Fetch {
+		total += f.Size
+	}
+	return total
+}
+
+// Compare сравнивает манифест с директорией root. Файл, размер и время изменения
+// которого совпадают с записанными при прошлой синхронизации, не читается; verify
+// заставляет проверить хэши всех файлов. Существующие файлы пользователя (userData)
+// не заменяются и не удаляются.
+func Compare(m *Manifest, root string, userData []string, verify bool) (*Plan, error) {
+	return CompareProgress(m, root, userData, verify, nil)
+}
+
+// Piece — проверенный участок игры: часть файла или файл целиком, если манифест
+// не делит его на части. Файлы идут подряд в порядке манифеста, так что
+// участки покрывают всю игру от 0 до TotalBytes.
+type Piece struct {
+	Path   string
+	Offset int64 // Смещение от начала игры
+	Size   int64
+	OK     bool
+	// Hashed — участок прочитан и сверен с хэшем. Файлы, которые узнаны по
+	// записи прошлой синхронизации или которых нет на диске, не читаются.
+	Hashed bool
+}
+
+// CompareProgress работает как Compare и передает в onPiece каждый проверенный
+// участок. Файлы с частями сверяются по частям, поэтому видно, какие именно
+// участки повреждены.
+func CompareProgress(m *Manifest, root string, userData []string, verify bool, onPiece func(Piece)) (*Plan, error) {
+	if onPiece == nil {
+		onPiece = func(Piece) {}
+	}
+	s := loadState(root)
+	plan := &Plan{}
+	listed := make(map[string]bool)
+	var offset int64
+	for _, f := range m.Files {
+		if reason := engine.CheckEntryName(root, f.Path); reason != "" {
+			return nil, fmt.Errorf("файл манифеста %s: %s", f.Path, reason)
+		}
+		listed[f.Path] = true
+		start := offset
+		offset += f.Size
+		whole := Piece{Path: f.Path, Offset: start, Size: f.Size}
+		info, err := os.Stat(filepath.Join(root, filepath.FromSlash(f.Path)))
+		switch {
+		case err != nil || !info.Mode().IsRegular():
+			plan.Fetch = append(plan.Fetch, f)
+			onPiece(whole)
+			continue
+		case engine.IsUserData(userData, f.Path):
+			plan.UpToDate++
+			whole.OK = true
+			onPiece(whole)
+			continue
+		case info.Size() != f.Size:
+			plan.Fetch = append(plan.Fetch, f)
+			onPiece(whole)
+			continue
+		}
+		known, ok := s.Files[f.Path]
+		if !verify && ok && known.Size == info.Size() && known.ModTime == info.ModTime().UnixNano() &&
+			known.SHA256 == downloadcache.NormalizeHash(f.SHA256) {
+			plan.UpToDate++
+			whole.OK = true
+			onPiece(whole)
+			continue
+		}
+		match, err := verifyFile(filepath.Join(root, filepath.FromSlash(f.Path)), f, start, onPiece)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			plan.UpToDate++
+		} else {
+			plan.Fetch = append(plan.Fetch, f)
+		}
+	}
+	for name := range s.Files {
+		if !listed[name] && !engine.IsUserData(userData, name) && engine.CheckEntryName(root, name) == "" {
+			plan.Remove = append(plan.Remove, name)
+		}
+	}
+	return plan, nil
+}
+
+// verifyFile сверяет файл с манифестом по частям, а без частей — целиком
+func verifyFile(path string, f File, offset int64, onPiece func(Piece)) (bool, error) {
+	if len(f.Chunks) == 0 {
+		sum, err := downloadcache.FileHash(path)
+		if err != nil {
+			return false, err
+		}
+		ok := sum == downloadcache.NormalizeHash(f.SHA256)
+		onPiece(Piece{Path: f.Path, Offset: offset, Size: f.Size, OK: ok, Hashed: true})
+		return ok, nil
+	}
+	in, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer in.Close()
+	// Файл совпадает, когда совпали все его части: они получены разрезанием
+	// того же содержимого, хэш которого записан в манифесте
+	match := true
+	for _, c := range f.Chunks {
+		h := sha256.New()
+		if _, err := io.CopyN(h, in, c.Size); err != nil {
+			return false, err
+		}
+		ok := hex.EncodeToString(h.Sum(nil)) == downloadcache.NormalizeHash(c.SHA256)
+		match = match && ok
+		onPiece(Piece{Path: f.Path, Offset: offset, Size: c.Size, OK: ok, Hashed: true})
+		offset += c.Size
+	}
+	return match, nil
+}
+
+// Fetcher делает файл по ссылке доступным локально, как source.Fetch
+type Fetcher func(ctx context.Context, ref string, progress func(done, total int64)) (string, error)
+
+// Options — как применять план
+type Options struct {
+	Fetch Fetcher
+	// Progress сообщает, сколько байт из FetchBytes уже получено
+	Progress func(done int64)
+	// Replace вызывается перед заменой или удалением существующего файла, например
+	// чтобы сохранить его в резервную копию. Файл после вызова может уже отсутствовать.
+	Replace func(name string) error
+	// Added вызывается для нового файла
+	Added func(name string)
+	// Release вызывается, когда полученный файл скопирован на место и больше не нужен
+	Release func(path string)
+}
+
+// Apply скачивает файлы плана в root, проверяя их хэши, удаляет устаревшие и
+// записывает StateFile
+func Apply(ctx context.Context, m *Manifest, root string, plan *Plan, opts Options) error {
+	var done int64
+	for _, f := range plan.Fetch {
+		base := done
+		report := func(got int64) {
+			if opts.Progress != nil {
+				opts.Progress(base + got)
+			}
+		}
+		tmp, err := receive(ctx, m, root, f, plan, opts, report)
+		if err != nil {
+			return fmt.Errorf("%s: %v", f.Path, err)
+		}
+		if err := place(tmp, root, f, opts); err != nil {
+			return err
+		}
+		done += f.Size
+		report(f.Size)
+	}
+
+	for _, name := range plan.Remove {
+		target := filepath.Join(root, filepath.FromSlash(name))
+		if _, err := os.Stat(target); err != nil {
+			continue
+		}
+		if opts.Replace != nil {
+			if err := opts.Replace(name); err != nil {
+				return err
+			}
+		}
+		if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("не удалось удалить %s: %v", name, err)
+		}
+	}
+	return writeState(m, root)
+}
+
+// Prefetch заранее получает то, что скачает Apply, не трогая root: файлы целиком
+// и части, которых нет в прежних версиях файлов. Полученное остается у Fetch,
+// например в кэше загрузок, и при обновлении берется оттуда.
+func Prefetch(ctx context.Context, m *Manifest, root string, plan *Plan, opts Options) error {
+	var done int64
+	get := func(ref string, size int64) error {
+		base := done
+		path, err := opts.Fetch(ctx, ref, func(got, _ int64) {
+			if opts.Progress != nil {
+				opts.Progress(base + got)
+			}
+		})
+		if err != nil {
+			return err
+		}
+		if opts.Release != nil {
+			opts.Release(path)
+		}
+		done += size
+		plan.Downloaded += size
+		return nil
+	}
+
+	for _, f := range plan.Fetch {
+		switch {
+		case f.Size == 0:
+		case len(f.Chunks) > 0:
+			index := make(map[string]localChunk)
+			if in, err := os.Open(filepath.Join(root, filepath.FromSlash(f.Path))); err == nil {
+				index, err = indexChunks(in)
+				in.Close()
+				if err != nil {
+					return fmt.Errorf("%s: ошибка при чтении прежней версии: %v", f.Path, err)
+				}
+			}
+			for _, c := range f.Chunks {
+				if local, ok := index[downloadcache.NormalizeHash(c.SHA256)]; ok && local.size == c.Size {
+					plan.Reused += c.Size
+					continue
+				}
+				if err := get(m.ChunkURL(c), c.Size); err != nil {
+					return fmt.Errorf("%s: %v", f.Path, err)
+				}
+			}
+		default:
+			if err := get(m.URL(f), f.Size); err != nil {
+				return fmt.Errorf("%s: %v", f.Path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// receive собирает новую версию файла во временном файле рядом с ним
+func receive(ctx context.Context, m *Manifest, root string, f File, plan *Plan, opts Options, report func(int64)) (string, error) {
+	target := filepath.Join(root, filepath.FromSlash(f.Path))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(target), ".sync-*")
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case f.Size == 0:
+		// Пустой файл скачивать не нужно
+	case len(f.Chunks) > 0:
+		err = assemble(ctx, m, target, f, tmp, plan, opts, report)
+	default:
+		var n int64
+		n, err = fetchInto(ctx, m.URL(f), "", tmp, opts, report)
+		plan.Downloaded += n
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// fetchInto получает ссылку ref и дописывает ее содержимое в w. Если expected
+// не пустой, хэш содержимого сверяется с ним.
+func fetchInto(ctx context.Context, ref, expected string, w io.Writer, opts Options, report func(int64)) (int64, error) {
+	path, err := opts.Fetch(ctx, ref, func(got, _ int64) {
+		report(got)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if opts.Release != nil {
+		defer opts.Release(path)
+	}
+	in, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+	hasher := sha256.New()
+	n, err := io.Copy(io.MultiWriter(w, hasher), in)
+	if err != nil {
+		return n, err
+	}
+	if expected != "" && hex.EncodeToString(hasher.Sum(nil)) != downloadcache.NormalizeHash(expected) {
+		return n, fmt.Errorf("контрольная сумма части %s не совпадает с манифестом", expected)
+	}
+	return n, nil
+}
+
+// assemble собирает файл из частей: совпадающие части берутся из прежней версии
+// файла на диске, остальные скачиваются
+func assemble(ctx context.Context, m *Manifest, target string, f File, w io.Writer, plan *Plan, opts Options, report func(int64)) error {
+	var old *os.File
+	index := make(map[string]localChunk)
+	if in, err := os.Open(target); err == nil {
+		defer in.Close()
+		if index, err = indexChunks(in); err != nil {
+			return fmt.Errorf("ошибка при чтении прежней версии: %v", err)
+		}
+		old = in
+	}
+
+	var written int64
+	for _, c := range f.Chunks {
+		if local, ok := index[downloadcache.NormalizeHash(c.SHA256)]; ok && local.size == c.Size {
+			if _, err := io.Copy(w, io.NewSectionReader(old, local.offset, local.size)); err != nil {
+				return err
+			}
+			plan.Reused += c.Size
+		} else {
+			base := written
+			n, err := fetchInto(ctx, m.ChunkURL(c), c.SHA256, w, opts, func(got int64) {
+				report(base + got)
+			})
+			if err != nil {
+				return err
+			}
+			plan.Downloaded += n
+		}
+		written += c.Size
+		report(written)
+	}
+	return nil
+}
+
+// place проверяет хэш собранного файла и атомарно ставит его на место
+func place(tmp, root string, f File, opts Options) error {
+	sum, err := downloadcache.FileHash(tmp)
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if sum != downloadcache.NormalizeHash(f.SHA256) {
+		os.Remove(tmp)
+		return fmt.Errorf("контрольная сумма %s не совпадает с манифестом", f.Path)
+	}
+	mode := os.FileMode(0644)
+	if f.Executable {
+		mode = 0755
+	}
+	os.Chmod(tmp, mode)
+
+	target := filepath.Join(root, filepath.FromSlash(f.Path))
+	if _, err := os.Stat(target); err == nil {
+		if opts.Replace != nil {
+			if err := opts.Replace(f.Path); err != nil {
+				os.Remove(tmp)
+				return err
+			}
+		}
+	} else if opts.Added != nil {
+		opts.Added(f.Path)
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("ошибка при замене %s: %v", f.Path, err)
+	}
+	return nil
+}
+
+// writeState запоминает размеры и время изменения файлов после синхронизации
+func writeState(m *Manifest, root string) error {
+	s := state{Version: m.Version, Files: make(map[string]stateEntry)}
+	for _, f := range m.Files {
+		info, err := os.Stat(filepath.Join(root, filepath.FromSlash(f.Path)))
+		if err != nil {
+			continue
+		}
+		s.Files[f.Path] = stateEntry{
+			Size:    info.Size(),
+			ModTime: info.ModTime().UnixNano(),
+			SHA256:  downloadcache.NormalizeHash(f.SHA256),
+		}
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(root, StateFile), data, 0644)
+}
@@ -0,0 +1,98 @@
+// Package membudget ограничивает память, которую одновременно занимают буферы
+// распаковки и загрузки. Горутина, которой не хватает бюджета, ждет, пока другие
+// вернут свои буферы, поэтому на слабых машинах установка замедляется, а не
+// раздувает память.
+package membudget
+
+import (
+	"context"
+	"runtime/debug"
+	"sync"
+)
+
+// DefaultLimit — бюджет буферов по умолчанию
+const DefaultLimit = 64 << 20
+
+// minLimit — меньше этого бюджет не опускается, иначе установка встанет
+const minLimit = 1 << 20
+
+// bufferShare — доля потолка памяти, отдаваемая буферам. Остальное остается
+// интерфейсу, распаковщикам и сборщику мусора.
+const bufferShare = 4
+
+// Budget — семафор, считающий байты
+type Budget struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	limit int64
+	used  int64
+}
+
+// Default — общий бюджет для всех буферов установщика
+var Default = New(DefaultLimit)
+
+// New создает бюджет на limit байт
+func New(limit int64) *Budget {
+	b := &Budget{limit: limit}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// SetLimit меняет размер бюджета
+func (b *Budget) SetLimit(limit int64) {
+	b.mu.Lock()
+	b.limit = limit
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
+
+// Acquire занимает n байт, дожидаясь, пока они освободятся. Запрос больше всего
+// бюджета урезается до бюджета, чтобы не ждать вечно.
+func (b *Budget) Acquire(ctx context.Context, n int64) (int64, error) {
+	// Отмена контекста будит ожидание
+	stop := context.AfterFunc(ctx, func() {
+		b.mu.Lock()
+		b.mu.Unlock()
+		b.cond.Broadcast()
+	})
+	defer stop()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		granted := min(n, b.limit)
+		if b.used+granted <= b.limit {
+			b.used += granted
+			return granted, nil
+		}
+		b.cond.Wait()
+	}
+}
+
+// Release возвращает n байт в бюджет
+func (b *Budget) Release(n int64) {
+	b.mu.Lock()
+	b.used -= n
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
+
+// Buffer выделяет буфер размером до size байт из общего бюджета. Функцию release
+// нужно вызвать, когда буфер больше не используется.
+func Buffer(ctx context.Context, size int) (buf []byte, release func(), err error) {
+	granted, err := Default.Acquire(ctx, int64(size))
+	if err != nil {
+		return nil, nil, err
+	}
+	return make([]byte, granted), func() { Default.Release(granted) }, nil
+}
+
+// SetCeiling задает потолок памяти процесса: сборщик мусора старается не выходить
+// за него, а буферам достается его четверть
+func SetCeiling(limit int64) {
+	debug.SetMemoryLimit(limit)
+	Default.SetLimit(max(limit/bufferShare, minLimit))
+}
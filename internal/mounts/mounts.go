@@ -0,0 +1,128 @@
+// Package mounts знает, на каком диске лежит игра, и сообщает о подключении
+// и отключении дисков. Игра на отключенном съемном диске не сломана, а просто
+// недоступна, пока диск не подключат снова.
+package mounts
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang-installer/internal/runner"
+)
+
+// removableRoots — куда монтируются съемные диски
+var removableRoots = []string{"/media/", "/run/media/", "/mnt/"}
+
+// Points возвращает список точек монтирования из /proc/self/mounts
+func Points() []string {
+	data, err := ioutil.ReadFile("/proc/self/mounts")
+	if err != nil {
+		return nil
+	}
+	var points []string
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 1 {
+			// Пробелы в путях экранируются как \040
+			points = append(points, strings.ReplaceAll(fields[1], "\\040", " "))
+		}
+	}
+	return points
+}
+
+// Of возвращает точку монтирования отдельного диска, на котором лежит path, или пустую
+// строку, если path лежит на корневой файловой системе
+func Of(path string) string {
+	path = filepath.Clean(path)
+	best := ""
+	for _, point := range Points() {
+		if point != "/" && within(path, point) && len(point) > len(best) {
+			best = point
+		}
+	}
+	return best
+}
+
+// Mounted сообщает, подключен ли сейчас диск с точкой монтирования point
+func Mounted(point string) bool {
+	for _, p := range Points() {
+		if p == point {
+			return true
+		}
+	}
+	return false
+}
+
+// Expected возвращает точку монтирования, на которой должен лежать path: записанную
+// при установке или, если ее нет, угаданную по пути вида /media/<пользователь>/<диск>.
+// Для путей не на съемных дисках возвращается пустая строка.
+func Expected(path, recorded string) string {
+	if recorded != "" {
+		return recorded
+	}
+	for _, root := range removableRoots {
+		rest, ok := strings.CutPrefix(path, root)
+		if !ok {
+			continue
+		}
+		parts := strings.SplitN(rest, "/", 3)
+		// /mnt/<диск>, но /media/<пользователь>/<диск> и /run/media/<пользователь>/<диск>
+		n := 2
+		if root == "/mnt/" {
+			n = 1
+		}
+		if len(parts) < n {
+			return ""
+		}
+		return root + strings.Join(parts[:n], "/")
+	}
+	return ""
+}
+
+// Offline сообщает, что path лежит на диске, который сейчас не подключен
+func Offline(path, recorded string) bool {
+	point := Expected(path, recorded)
+	if point == "" {
+		return false
+	}
+	if _, err := os.Stat(path); err == nil {
+		return false
+	}
+	return !Mounted(point)
+}
+
+// Monitor следит за подключением дисков через udisks2 и пишет в events после каждого
+// изменения точек монтирования. Работает до отмены ctx; если udisksctl не найден,
+// сразу возвращает ошибку.
+func Monitor(ctx context.Context, events chan<- struct{}) error {
+	cmd, err := runner.Command(ctx, "udisksctl", "monitor")
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.Contains(line, "MountPoints") || strings.Contains(line, "Removed interface") {
+			select {
+			case events <- struct{}{}:
+			default:
+			}
+		}
+	}
+	return cmd.Wait()
+}
+
+func within(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(os.PathSeparator))
+}
@@ -0,0 +1,131 @@
+// Package network узнает у NetworkManager состояние подключения по D-Bus. Запросы
+// идут через busctl или gdbus, чтобы не тянуть в установщик библиотеку D-Bus.
+// Без NetworkManager состояние неизвестно, и установщик ведет себя как раньше.
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	"golang-installer/internal/runner"
+)
+
+const (
+	nmService = "org.freedesktop.NetworkManager"
+	nmPath    = "/org/freedesktop/NetworkManager"
+	// queryTimeout — NetworkManager отвечает сразу, долгий ответ значит, что его нет
+	queryTimeout = 5 * time.Second
+	// pollInterval — как часто WaitOnline проверяет подключение
+	pollInterval = 5 * time.Second
+)
+
+// Connectivity — доступность интернета по оценке NetworkManager (NMConnectivityState)
+type Connectivity uint32
+
+const (
+	ConnectivityUnknown Connectivity = iota
+	ConnectivityNone
+	ConnectivityPortal  // Нужен вход на странице сети, например Wi-Fi в гостинице
+	ConnectivityLimited // Есть локальная сеть, но нет интернета
+	ConnectivityFull
+)
+
+// String объясняет состояние пользователю
+func (c Connectivity) String() string {
+	switch c {
+	case ConnectivityNone:
+		return "нет подключения к сети"
+	case ConnectivityPortal:
+		return "сеть требует входа через браузер"
+	case ConnectivityLimited:
+		return "локальная сеть есть, но интернет недоступен"
+	case ConnectivityFull:
+		return "интернет доступен"
+	}
+	return "состояние сети неизвестно"
+}
+
+// Check возвращает доступность интернета
+func Check() (Connectivity, error) {
+	value, err := nmProperty("Connectivity")
+	return Connectivity(value), err
+}
+
+// Offline сообщает, что интернета точно нет. Если NetworkManager недоступен или
+// сам не знает, считается, что интернет есть: пусть загрузка попробует.
+func Offline() bool {
+	c, err := Check()
+	return err == nil && c != ConnectivityUnknown && c != ConnectivityFull
+}
+
+// WaitOnline ждет, пока появится интернет, или отмены ctx
+func WaitOnline(ctx context.Context) error {
+	for Offline() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+	return nil
+}
+
+// Значения NMMetered
+const (
+	meteredYes      = 1
+	meteredGuessYes = 3
+)
+
+// Metered сообщает, что основное подключение лимитное. NetworkManager помечает так
+// подключения, настроенные пользователем, и сам угадывает точку доступа на телефоне.
+func Metered() (bool, error) {
+	value, err := nmProperty("Metered")
+	if err != nil {
+		return false, err
+	}
+	return value == meteredYes || value == meteredGuessYes, nil
+}
+
+// errUnavailable — NetworkManager не ответил при прошлом запросе
+var errUnavailable = errors.New("NetworkManager недоступен")
+
+// unavailable выставляется после первого неудачного запроса: состояние сети
+// проверяется периодически, и без NetworkManager каждая проверка писала бы в журнал
+var unavailable atomic.Bool
+
+// nmProperty читает числовое свойство NetworkManager
+func nmProperty(name string) (uint32, error) {
+	if unavailable.Load() {
+		return 0, errUnavailable
+	}
+	out, err := runner.Output(queryTimeout, "busctl", "--system", "get-property", nmService, nmPath, nmService, name)
+	if errors.Is(err, runner.ErrNotFound) {
+		out, err = runner.Output(queryTimeout, "gdbus", "call", "--system", "--dest", nmService,
+			"--object-path", nmPath, "--method", "org.freedesktop.DBus.Properties.Get", nmService, name)
+	}
+	if err != nil {
+		unavailable.Store(true)
+		return 0, err
+	}
+	return parseUint(string(out))
+}
+
+// parseUint достает число из ответа busctl ("u 4") или gdbus ("(<uint32 4>,)"):
+// это последняя группа цифр
+func parseUint(out string) (uint32, error) {
+	fields := strings.FieldsFunc(out, func(r rune) bool { return !unicode.IsDigit(r) })
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("непонятный ответ NetworkManager: %q", strings.TrimSpace(out))
+	}
+	value, err := strconv.ParseUint(fields[len(fields)-1], 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(value), nil
+}
@@ -0,0 +1,98 @@
+// Package objstore — простые клиенты объектных хранилищ: S3-совместимых бакетов
+// и ресурсов WebDAV. Установщик читает из них файлы и кладет туда сохранения,
+// поэтому клиентам нужны только чтение и запись объекта по ключу.
+package objstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	// TypeS3 — S3-совместимое хранилище: AWS, MinIO, Yandex Object Storage и другие
+	TypeS3 = "s3"
+	// TypeWebDAV — ресурс WebDAV, например Nextcloud
+	TypeWebDAV = "webdav"
+)
+
+// ErrNotFound — объекта с таким ключом нет
+var ErrNotFound = errors.New("объект не найден")
+
+// Config — подключение к хранилищу
+type Config struct {
+	Type      string `json:"type"`       // s3 или webdav
+	URL       string `json:"url"`        // Адрес сервера S3 или корень ресурса WebDAV
+	Bucket    string `json:"bucket"`     // Бакет S3
+	Region    string `json:"region"`     // Регион S3, по умолчанию us-east-1
+	AccessKey string `json:"access_key"` // Ключ доступа S3, без него запросы анонимные
+	SecretKey string `json:"secret_key"`
+	Username  string `json:"username"` // Пользователь WebDAV
+	Password  string `json:"password"`
+}
+
+// Secret возвращает секретную часть подключения: ключ S3 или пароль WebDAV
+func (c Config) Secret() string {
+	if c.Type == TypeS3 {
+		return c.SecretKey
+	}
+	return c.Password
+}
+
+// WithSecret возвращает копию подключения с другой секретной частью. Пустая строка
+// убирает секрет, например перед сохранением подключения на диск.
+func (c Config) WithSecret(secret string) Config {
+	if c.Type == TypeS3 {
+		c.SecretKey = secret
+	} else {
+		c.Password = secret
+	}
+	return c
+}
+
+// Store читает и записывает объекты по ключам вида "a/b/c"
+type Store interface {
+	// Get возвращает содержимое объекта и его размер (-1, если неизвестен)
+	Get(ctx context.Context, key string) (io.ReadCloser, int64, error)
+	// Put записывает объект размером size байт
+	Put(ctx context.Context, key string, body io.Reader, size int64) error
+	// Request создает запрос к объекту с авторизацией. Его выполняют сами, когда
+	// нужен доступ к ответу, например для прогресса загрузки.
+	Request(ctx context.Context, method, key string, body io.Reader) (*http.Request, error)
+}
+
+// Open возвращает клиент хранилища по подключению
+func Open(c Config) (Store, error) {
+	if c.URL == "" {
+		return nil, fmt.Errorf("не указан адрес хранилища")
+	}
+	switch c.Type {
+	case TypeS3:
+		if c.Bucket == "" {
+			return nil, fmt.Errorf("не указан бакет S3")
+		}
+		return &s3Store{config: c}, nil
+	case TypeWebDAV:
+		return &webdavStore{config: c}, nil
+	}
+	return nil, fmt.Errorf("неизвестный тип хранилища %q", c.Type)
+}
+
+// check превращает ответ сервера с ошибкой в error и закрывает его тело
+func check(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if text := strings.TrimSpace(string(body)); text != "" {
+		return fmt.Errorf("сервер вернул %s: %s", resp.Status, text)
+	}
+	return fmt.Errorf("сервер вернул %s", resp.Status)
+}
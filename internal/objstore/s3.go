@@ -0,0 +1,147 @@
+package objstore
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// unsignedPayload — тело запроса не входит в подпись, поэтому его не нужно читать дважды
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// s3Store обращается к бакету по адресу вида <url>/<bucket>/<key>, который понимают
+// все S3-совместимые серверы, и подписывает запросы AWS Signature Version 4
+type s3Store struct {
+	config Config
+}
+
+func (s *s3Store) Get(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	req, err := s.Request(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := check(resp); err != nil {
+		return nil, 0, err
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+func (s *s3Store) Put(ctx context.Context, key string, body io.Reader, size int64) error {
+	req, err := s.Request(ctx, http.MethodPut, key, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	if err := check(resp); err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Request создает подписанный запрос к объекту key
+func (s *s3Store) Request(ctx context.Context, method, key string, body io.Reader) (*http.Request, error) {
+	base, err := url.Parse(strings.TrimSuffix(s.config.URL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("неверный адрес хранилища: %v", err)
+	}
+	objectPath := base.Path + "/" + s.config.Bucket + "/" + strings.TrimPrefix(key, "/")
+	base.Path = objectPath
+	base.RawPath = awsEscape(objectPath)
+
+	req, err := http.NewRequestWithContext(ctx, method, base.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if s.config.AccessKey != "" {
+		s.sign(req, time.Now().UTC())
+	}
+	return req, nil
+}
+
+// sign добавляет к запросу подпись AWS Signature Version 4
+func (s *s3Store) sign(req *http.Request, now time.Time) {
+	region := s.config.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + unsignedPayload,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		unsignedPayload,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + s.config.SecretKey)
+	for _, part := range []string{date, region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.config.AccessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery сортирует параметры и кодирует их так, как требует подпись
+func canonicalQuery(values url.Values) string {
+	var pairs []string
+	for name, list := range values {
+		for _, value := range list {
+			pairs = append(pairs, awsEscape(name)+"="+awsEscape(value))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// awsEscape кодирует все символы, кроме букв, цифр, "-_.~" и "/"
+func awsEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
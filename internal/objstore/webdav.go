@@ -0,0 +1,77 @@
+package objstore
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// webdavStore хранит объекты файлами внутри корня ресурса WebDAV
+type webdavStore struct {
+	config Config
+}
+
+func (s *webdavStore) Get(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, -1)
+	if err != nil {
+		return nil, 0, err
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+func (s *webdavStore) Put(ctx context.Context, key string, body io.Reader, size int64) error {
+	// Сервер не создает коллекции сам: без них PUT завершается ошибкой 409
+	parts := strings.Split(strings.Trim(key, "/"), "/")
+	for i := 1; i < len(parts); i++ {
+		resp, err := s.do(ctx, "MKCOL", strings.Join(parts[:i], "/")+"/", nil, 0)
+		if err == nil {
+			resp.Body.Close()
+		}
+	}
+	resp, err := s.do(ctx, http.MethodPut, key, body, size)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// do выполняет запрос к объекту key. MKCOL существующей коллекции не считается ошибкой.
+func (s *webdavStore) do(ctx context.Context, method, key string, body io.Reader, size int64) (*http.Response, error) {
+	req, err := s.Request(ctx, method, key, body)
+	if err != nil {
+		return nil, err
+	}
+	if size >= 0 {
+		req.ContentLength = size
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if method == "MKCOL" && resp.StatusCode == http.StatusMethodNotAllowed {
+		return resp, nil
+	}
+	if err := check(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Request создает запрос к объекту key с авторизацией пользователя WebDAV
+func (s *webdavStore) Request(ctx context.Context, method, key string, body io.Reader) (*http.Request, error) {
+	var escaped []string
+	for _, part := range strings.Split(strings.TrimPrefix(key, "/"), "/") {
+		escaped = append(escaped, url.PathEscape(part))
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(s.config.URL, "/")+"/"+strings.Join(escaped, "/"), body)
+	if err != nil {
+		return nil, err
+	}
+	if s.config.Username != "" {
+		req.SetBasicAuth(s.config.Username, s.config.Password)
+	}
+	return req, nil
+}
@@ -0,0 +1,89 @@
+// Package overlay монтирует распакованную игру через overlayfs для пробного запуска.
+// Нижний слой — распакованные файлы, он остается нетронутым; все, что игра
+// записывает во время пробы, попадает во временный верхний слой и удаляется
+// вместе с ним.
+package overlay
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang-installer/internal/runner"
+)
+
+// timeout — ограничение по времени для монтирования и отмонтирования
+const timeout = 30 * time.Second
+
+// Mount — смонтированный пробный экземпляр игры
+type Mount struct {
+	Dir  string // Директория, в которой видна игра
+	tmp  string
+	fuse bool
+}
+
+// Available сообщает, можно ли смонтировать overlayfs: от root через ядро,
+// иначе через fuse-overlayfs
+func Available() bool {
+	if os.Geteuid() == 0 {
+		return true
+	}
+	_, err := exec.LookPath("fuse-overlayfs")
+	return err == nil
+}
+
+// New монтирует lower с временным верхним слоем в tmpRoot
+func New(lower, tmpRoot string) (*Mount, error) {
+	tmp, err := os.MkdirTemp(tmpRoot, "go-qt-installer-trial-")
+	if err != nil {
+		return nil, err
+	}
+	m := &Mount{Dir: filepath.Join(tmp, "merged"), tmp: tmp, fuse: os.Geteuid() != 0}
+	upper, work := filepath.Join(tmp, "upper"), filepath.Join(tmp, "work")
+	for _, dir := range []string{m.Dir, upper, work} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			os.RemoveAll(tmp)
+			return nil, err
+		}
+	}
+
+	// Запятые и двоеточия в путях разделяют параметры, такие пути не смонтировать
+	for _, dir := range []string{lower, upper, work} {
+		if strings.ContainsAny(dir, ",:") {
+			os.RemoveAll(tmp)
+			return nil, fmt.Errorf("путь %s не подходит для overlayfs", dir)
+		}
+	}
+	options := "lowerdir=" + lower + ",upperdir=" + upper + ",workdir=" + work
+
+	if m.fuse {
+		_, err = runner.Output(timeout, "fuse-overlayfs", "-o", options, m.Dir)
+	} else {
+		_, err = runner.Output(timeout, "mount", "-t", "overlay", "overlay", "-o", options, m.Dir)
+	}
+	if err != nil {
+		os.RemoveAll(tmp)
+		return nil, err
+	}
+	return m, nil
+}
+
+// Unmount отмонтирует игру и удаляет верхний слой со всеми изменениями. Если
+// игра еще работает, отмонтирование откладывается до ее завершения.
+func (m *Mount) Unmount() error {
+	var err error
+	if m.fuse {
+		if err = runner.Run("fusermount3", "-u", "-z", m.Dir); err != nil {
+			err = runner.Run("fusermount", "-u", "-z", m.Dir)
+		}
+	} else {
+		err = runner.Run("umount", "-l", m.Dir)
+	}
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(m.tmp)
+}
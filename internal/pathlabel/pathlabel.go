@@ -0,0 +1,126 @@
+// Package pathlabel — подпись с путем к директории игры. Путь можно выделить и
+// скопировать, щелчок открывает директорию в файловом менеджере через xdg-open,
+// а в контекстном меню есть «Копировать путь» и «Открыть в файловом менеджере».
+// Shortcuts добавляет то же самое в окно сочетаниями клавиш, чтобы путь не
+// приходилось перепечатывать.
+package pathlabel
+
+import (
+	"html"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/therecipe/qt/core"
+	"github.com/therecipe/qt/gui"
+	"github.com/therecipe/qt/widgets"
+
+	"golang-installer/internal/launcher"
+)
+
+// Сочетания клавиш окна
+const (
+	CopyShortcut = "Ctrl+Shift+C"
+	OpenShortcut = "Ctrl+Shift+O"
+)
+
+// Label — подпись «prefix путь»
+type Label struct {
+	*widgets.QLabel
+	prefix string
+	path   string
+}
+
+// New создает подпись. prefix стоит перед путем, например «Путь установки: ».
+func New(prefix string) *Label {
+	l := &Label{QLabel: widgets.NewQLabel2(html.EscapeString(prefix), nil, 0), prefix: prefix}
+	l.SetWordWrap(true)
+	l.SetTextFormat(core.Qt__RichText)
+	l.SetTextInteractionFlags(core.Qt__TextBrowserInteraction)
+	l.ConnectLinkActivated(func(string) {
+		Open(l.path)
+	})
+	l.SetContextMenuPolicy(core.Qt__CustomContextMenu)
+	l.ConnectCustomContextMenuRequested(func(pos *core.QPoint) {
+		if l.path == "" {
+			return
+		}
+		menu := widgets.NewQMenu(nil)
+		menu.AddAction("Копировать путь").ConnectTriggered(func(bool) {
+			Copy(l.path)
+		})
+		menu.AddAction("Открыть в файловом менеджере").ConnectTriggered(func(bool) {
+			Open(l.path)
+		})
+		menu.Exec2(l.MapToGlobal(pos), nil)
+	})
+	return l
+}
+
+// SetPath показывает путь. Пустой путь показывается текстом empty.
+func (l *Label) SetPath(path, empty string) {
+	l.path = path
+	if path == "" {
+		l.SetText(html.EscapeString(l.prefix + empty))
+		l.SetToolTip("")
+		return
+	}
+	l.SetText(html.EscapeString(l.prefix) + `<a href="` + html.EscapeString(core.QUrl_FromLocalFile(path).ToString(core.QUrl__None)) +
+		`">` + html.EscapeString(path) + "</a>")
+	l.SetToolTip("Щелчок открывает папку, " + CopyShortcut + " копирует путь")
+}
+
+// Path возвращает показанный путь
+func (l *Label) Path() string {
+	return l.path
+}
+
+// Copy копирует путь в буфер обмена
+func Copy(path string) {
+	if path == "" {
+		return
+	}
+	gui.QGuiApplication_Clipboard().SetText(path, gui.QClipboard__Clipboard)
+	// Средняя кнопка мыши вставляет выделение, а не буфер обмена
+	gui.QGuiApplication_Clipboard().SetText(path, gui.QClipboard__Selection)
+}
+
+// Open открывает директорию в файловом менеджере. Директории, которой еще нет,
+// например до установки, открывается ближайшая существующая родительская.
+func Open(path string) {
+	if path == "" {
+		return
+	}
+	for {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			break
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return
+		}
+		path = parent
+	}
+	// xdg-open в своей сессии: файловый менеджер не закроется вместе с установщиком
+	if xdgOpen, err := exec.LookPath("xdg-open"); err == nil {
+		_, err := launcher.Start(launcher.Spec{Path: xdgOpen, Args: []string{path}, Dir: path})
+		if err == nil {
+			return
+		}
+		log.Printf("Ошибка при запуске xdg-open: %v", err)
+	}
+	gui.QDesktopServices_OpenUrl(core.QUrl_FromLocalFile(path))
+}
+
+// Shortcuts добавляет в окно сочетания клавиш для пути, который возвращает path
+func Shortcuts(window widgets.QWidget_ITF, path func() string) {
+	for key, action := range map[string]func(string){CopyShortcut: Copy, OpenShortcut: Open} {
+		action := action
+		shortcut := widgets.NewQShortcut(window)
+		shortcut.SetKey(gui.NewQKeySequence2(key, gui.QKeySequence__PortableText))
+		shortcut.ConnectActivated(func() {
+			action(path())
+		})
+	}
+}
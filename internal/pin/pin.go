@@ -0,0 +1,196 @@
+// Package pin делает новую игру заметной сразу после установки: в KDE Plasma
+// закрепляет ее в избранном меню запуска, в GNOME кладет в папку приложений.
+// Избранное Plasma хранит служба активностей, к ней обращаемся по D-Bus; папки
+// GNOME — это ключи dconf, их меняет gsettings.
+package pin
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"golang-installer/internal/runner"
+)
+
+// Рабочие столы, которые поддерживают закрепление
+const (
+	KDE   = "kde"
+	GNOME = "gnome"
+)
+
+// DefaultFolder — папка приложений GNOME по умолчанию
+const DefaultFolder = "Games"
+
+// Record — куда закреплена игра, чтобы при удалении убрать ее оттуда
+type Record struct {
+	DesktopID   string `json:"desktop_id"`             // Имя файла ярлыка, например io.github.foxixus1.goqtinstaller.game.desktop
+	KDEFavorite bool   `json:"kde_favorite,omitempty"` // В избранном меню запуска Plasma
+	GNOMEFolder string `json:"gnome_folder,omitempty"` // Идентификатор папки приложений GNOME
+}
+
+// Desktop возвращает рабочий стол, на котором можно закрепить игру, или пустую строку
+func Desktop() string {
+	for _, desktop := range strings.Split(os.Getenv("XDG_CURRENT_DESKTOP"), ":") {
+		switch strings.ToUpper(desktop) {
+		case "KDE":
+			return KDE
+		case "GNOME":
+			return GNOME
+		}
+	}
+	return ""
+}
+
+// Pin закрепляет ярлык desktopID на текущем рабочем столе. folder — папка
+// приложений для GNOME.
+func Pin(desktopID, folder string) (*Record, error) {
+	record := &Record{DesktopID: desktopID}
+	switch Desktop() {
+	case KDE:
+		if err := linkFavorite("LinkResourceToActivity", desktopID); err != nil {
+			return nil, err
+		}
+		record.KDEFavorite = true
+	case GNOME:
+		if folder == "" {
+			folder = DefaultFolder
+		}
+		if err := addToFolder(folder, desktopID); err != nil {
+			return nil, err
+		}
+		record.GNOMEFolder = folder
+	default:
+		return nil, fmt.Errorf("закрепление в меню не поддерживается на этом рабочем столе")
+	}
+	return record, nil
+}
+
+// Unpin убирает игру из избранного и из папки приложений
+func Unpin(r *Record) error {
+	if r.KDEFavorite {
+		return linkFavorite("UnlinkResourceFromActivity", r.DesktopID)
+	}
+	if r.GNOMEFolder != "" {
+		return removeFromFolder(r.GNOMEFolder, r.DesktopID)
+	}
+	return nil
+}
+
+const (
+	activityService = "org.kde.ActivityManager"
+	linkingPath     = "/ActivityManager/Resources/Linking"
+	linkingIface    = "org.kde.ActivityManager.ResourcesLinking"
+	// favoritesAgent — избранное приложений в Kickoff, Kicker и других меню Plasma
+	favoritesAgent = "org.kde.plasma.favorites.applications"
+	// globalActivity — избранное во всех активностях
+	globalActivity = ":global"
+	dbusTimeout    = 10 * time.Second
+)
+
+// linkFavorite вызывает method службы активностей для ярлыка. Запрос идет через
+// gdbus, а без него через qdbus, который есть в любой Plasma.
+func linkFavorite(method, desktopID string) error {
+	resource := "applications:" + desktopID
+	_, err := runner.Output(dbusTimeout, "gdbus", "call", "--session", "--dest", activityService,
+		"--object-path", linkingPath, "--method", linkingIface+"."+method, favoritesAgent, resource, globalActivity)
+	if errors.Is(err, runner.ErrNotFound) {
+		for _, qdbus := range []string{"qdbus6", "qdbus"} {
+			_, err = runner.Output(dbusTimeout, qdbus, activityService, linkingPath, linkingIface+"."+method,
+				favoritesAgent, resource, globalActivity)
+			if !errors.Is(err, runner.ErrNotFound) {
+				break
+			}
+		}
+	}
+	return err
+}
+
+const foldersSchema = "org.gnome.desktop.app-folders"
+
+// folderSchema — настройки одной папки (схема с путем)
+func folderSchema(folder string) string {
+	return foldersSchema + ".folder:/org/gnome/desktop/app-folders/folders/" + folder + "/"
+}
+
+// addToFolder добавляет ярлык в папку приложений GNOME, создавая папку при необходимости
+func addToFolder(folder, desktopID string) error {
+	folders, err := getList(foldersSchema, "folder-children")
+	if err != nil {
+		return err
+	}
+	if !contains(folders, folder) {
+		if err := setList(foldersSchema, "folder-children", append(folders, folder)); err != nil {
+			return err
+		}
+		if err := runner.Run("gsettings", "set", folderSchema(folder), "name", quote(folder)); err != nil {
+			return err
+		}
+	}
+	apps, err := getList(folderSchema(folder), "apps")
+	if err != nil {
+		return err
+	}
+	if contains(apps, desktopID) {
+		return nil
+	}
+	return setList(folderSchema(folder), "apps", append(apps, desktopID))
+}
+
+// removeFromFolder убирает ярлык из папки. Пустую папку GNOME скрывает сам.
+func removeFromFolder(folder, desktopID string) error {
+	apps, err := getList(folderSchema(folder), "apps")
+	if err != nil {
+		return err
+	}
+	var kept []string
+	for _, app := range apps {
+		if app != desktopID {
+			kept = append(kept, app)
+		}
+	}
+	if len(kept) == len(apps) {
+		return nil
+	}
+	return setList(folderSchema(folder), "apps", kept)
+}
+
+// getList читает ключ-массив строк: gsettings печатает его как ['a', 'b'] или @as []
+func getList(schema, key string) ([]string, error) {
+	out, err := runner.Output(dbusTimeout, "gsettings", "get", schema, key)
+	if err != nil {
+		return nil, err
+	}
+	value := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(string(out)), "@as"))
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.Trim(strings.TrimSpace(item), `'"`); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list, nil
+}
+
+func setList(schema, key string, list []string) error {
+	quoted := make([]string, len(list))
+	for i, item := range list {
+		quoted[i] = quote(item)
+	}
+	return runner.Run("gsettings", "set", schema, key, "["+strings.Join(quoted, ", ")+"]")
+}
+
+// quote записывает строку GVariant. Идентификаторы ярлыков и папок не содержат кавычек.
+func quote(s string) string {
+	return "'" + s + "'"
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
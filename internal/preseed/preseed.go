@@ -0,0 +1,58 @@
+// Package preseed читает файл ответов для автоматической установки. В нем заранее
+// даны ответы на все вопросы установщика, поэтому производители компьютеров и киоски
+// ставят игру без участия человека, а окно установщика только показывает прогресс.
+package preseed
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Answers — ответы на вопросы установщика. Незаданные поля означают ответ по умолчанию.
+type Answers struct {
+	InstallPath       string            `json:"install_path"`        // Директория игры, по умолчанию в директории для игр из настроек
+	Language          string            `json:"language"`            // Код языкового пакета
+	Shortcut          *bool             `json:"shortcut"`            // Создать ярлыки, по умолчанию да
+	AcceptEULA        bool              `json:"accept_eula"`         // Согласие с лицензионным соглашением, если оно есть
+	ConfirmAge        bool              `json:"confirm_age"`         // Возраст игрока подтвержден тем, кто готовит установку
+	Options           map[string]string `json:"options"`             // Значения полей дополнительных страниц по id
+	TempDir           string            `json:"temp_dir"`            // Директория временных файлов
+	OverwriteUserData bool              `json:"overwrite_user_data"` // Заменять измененные пользователем файлы при обновлении
+	SystemWide        bool              `json:"system_wide"`         // Установить для всех пользователей
+	Title             string            `json:"title"`               // Заголовок окна вместо «Установщик <игра>»
+	Banner            string            `json:"banner"`              // Баннер вместо баннера из конфигурации
+	Message           string            `json:"message"`             // Текст над прогрессом установки
+}
+
+// Load читает файл ответов. Относительные пути в нем считаются от директории файла,
+// чтобы файл можно было положить рядом с установщиком на носитель.
+func Load(path string) (*Answers, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при чтении файла ответов %s: %v", path, err)
+	}
+	var answers Answers
+	if err := json.Unmarshal(data, &answers); err != nil {
+		return nil, fmt.Errorf("ошибка при разборе файла ответов %s: %v", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	for _, p := range []*string{&answers.InstallPath, &answers.TempDir, &answers.Banner} {
+		if *p == "" {
+			continue
+		}
+		*p = os.ExpandEnv(*p)
+		if !filepath.IsAbs(*p) {
+			*p = filepath.Join(dir, *p)
+		}
+	}
+	return &answers, nil
+}
+
+// CreateShortcut сообщает, нужно ли создавать ярлыки
+func (a *Answers) CreateShortcut() bool {
+	return a.Shortcut == nil || *a.Shortcut
+}
@@ -0,0 +1,158 @@
+// Package profile записывает отчет о производительности установки: длительность
+// этапов, скорость распаковки каждого архива и профили pprof, по которым видно,
+// на каких системных вызовах и функциях уходит время. Методы Recorder можно
+// вызывать у nil, тогда они ничего не делают, и вызывающему коду не нужны проверки.
+package profile
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// ReportName — файл отчета в директории логов игры
+	ReportName = "performance-report.txt"
+	cpuName    = "cpu.pprof"
+	blockName  = "block.pprof"
+)
+
+// blockRate — как часто записывать блокировки горутин, в наносекундах
+const blockRate = 10000
+
+type phase struct {
+	name     string
+	duration time.Duration
+}
+
+type archiveStat struct {
+	name     string
+	bytes    int64
+	duration time.Duration
+}
+
+// Recorder собирает замеры одной установки
+type Recorder struct {
+	mu       sync.Mutex
+	start    time.Time
+	phases   []phase
+	archives []archiveStat
+	cpuFile  *os.File
+}
+
+// Start начинает запись профиля CPU во временный файл. Директория логов появляется
+// только в конце установки, поэтому файлы переносятся туда в Finish.
+func Start() (*Recorder, error) {
+	f, err := os.CreateTemp("", "go-qt-installer-cpu-")
+	if err != nil {
+		return nil, err
+	}
+	if err := pprof.StartCPUProfile(f); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	runtime.SetBlockProfileRate(blockRate)
+	return &Recorder{start: time.Now(), cpuFile: f}, nil
+}
+
+// Phase отмечает начало этапа и возвращает функцию, завершающую его
+func (r *Recorder) Phase(name string) func() {
+	if r == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		r.mu.Lock()
+		r.phases = append(r.phases, phase{name: name, duration: time.Since(start)})
+		r.mu.Unlock()
+	}
+}
+
+// Archive записывает объем и время распаковки архива
+func (r *Recorder) Archive(name string, bytes int64, duration time.Duration) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.archives = append(r.archives, archiveStat{name: name, bytes: bytes, duration: duration})
+	r.mu.Unlock()
+}
+
+// Finish останавливает профилирование и сохраняет отчет и профили в dir.
+// Возвращает путь к отчету.
+func (r *Recorder) Finish(dir, title string) (string, error) {
+	if r == nil {
+		return "", nil
+	}
+	pprof.StopCPUProfile()
+	r.cpuFile.Close()
+	defer os.Remove(r.cpuFile.Name())
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	if data, err := ioutil.ReadFile(r.cpuFile.Name()); err == nil {
+		ioutil.WriteFile(filepath.Join(dir, cpuName), data, 0644)
+	}
+	if f, err := os.Create(filepath.Join(dir, blockName)); err == nil {
+		pprof.Lookup("block").WriteTo(f, 0)
+		f.Close()
+	}
+	runtime.SetBlockProfileRate(0)
+
+	reportPath := filepath.Join(dir, ReportName)
+	if err := ioutil.WriteFile(reportPath, []byte(r.report(title)), 0644); err != nil {
+		return "", err
+	}
+	return reportPath, nil
+}
+
+// Abort останавливает профилирование без отчета, если установка не началась
+func (r *Recorder) Abort() {
+	if r == nil {
+		return
+	}
+	pprof.StopCPUProfile()
+	runtime.SetBlockProfileRate(0)
+	r.cpuFile.Close()
+	os.Remove(r.cpuFile.Name())
+}
+
+func (r *Recorder) report(title string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Отчет о производительности установки: %s\n", title)
+	fmt.Fprintf(&b, "Дата: %s\n", r.start.Format("02.01.2006 15:04:05"))
+	fmt.Fprintf(&b, "Всего: %s\n", time.Since(r.start).Round(time.Millisecond))
+	fmt.Fprintf(&b, "Система: %s/%s, процессоров: %d\n\n", runtime.GOOS, runtime.GOARCH, runtime.NumCPU())
+
+	b.WriteString("Этапы:\n")
+	for _, p := range r.phases {
+		fmt.Fprintf(&b, "  %-32s %10s\n", p.name, p.duration.Round(time.Millisecond))
+	}
+
+	if len(r.archives) > 0 {
+		b.WriteString("\nАрхивы:\n")
+		for _, a := range r.archives {
+			speed := 0.0
+			if a.duration > 0 {
+				speed = float64(a.bytes) / a.duration.Seconds() / (1 << 20)
+			}
+			fmt.Fprintf(&b, "  %-32s %10.1f МБ %10s %8.1f МБ/с\n",
+				a.name, float64(a.bytes)/(1<<20), a.duration.Round(time.Millisecond), speed)
+		}
+	}
+
+	fmt.Fprintf(&b, "\nПрофили: %s (процессор), %s (ожидание). Просмотр: go tool pprof -top %s\n",
+		cpuName, blockName, cpuName)
+	return b.String()
+}
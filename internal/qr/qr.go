@@ -0,0 +1,440 @@
+// Package qr строит QR-коды (ISO/IEC 18004) для ссылок: байтовый режим, уровень
+// коррекции M, версии 1–10, то есть до 213 байт. Код строится на месте, чтобы ссылка
+// с токеном установки не уходила стороннему сервису.
+package qr
+
+import "fmt"
+
+// block описывает блоки коррекции версии на уровне M: число байт коррекции в блоке
+// и число блоков с data1 и data2 байтами данных
+type block struct {
+	ec            int
+	count1, data1 int
+	count2, data2 int
+}
+
+var blocks = []block{
+	{10, 1, 16, 0, 0},
+	{16, 1, 28, 0, 0},
+	{26, 1, 44, 0, 0},
+	{18, 2, 32, 0, 0},
+	{24, 2, 43, 0, 0},
+	{16, 4, 27, 0, 0},
+	{18, 4, 31, 0, 0},
+	{22, 2, 38, 2, 39},
+	{22, 3, 36, 2, 37},
+	{26, 4, 43, 1, 44},
+}
+
+// alignment — центры выравнивающих узоров по версиям
+var alignment = [][]int{
+	nil,
+	{6, 18},
+	{6, 22},
+	{6, 26},
+	{6, 30},
+	{6, 34},
+	{6, 22, 38},
+	{6, 24, 42},
+	{6, 26, 46},
+	{6, 28, 50},
+}
+
+// Code — построенный QR-код
+type Code struct {
+	Size     int // Сторона в модулях, без обязательного светлого поля в 4 модуля
+	modules  []bool
+	function []bool
+}
+
+// Dark сообщает, темный ли модуль в столбце x и строке y
+func (c *Code) Dark(x, y int) bool {
+	return c.modules[y*c.Size+x]
+}
+
+// Encode строит QR-код для text в наименьшей подходящей версии
+func Encode(text string) (*Code, error) {
+	return encode(text, -1)
+}
+
+// encode строит QR-код с маской mask или, если она отрицательная, с маской
+// с наименьшим штрафом
+func encode(text string, mask int) (*Code, error) {
+	data := []byte(text)
+	version := 0
+	for v := 1; v <= len(blocks); v++ {
+		countBits := 8
+		if v >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+8*len(data) <= dataCodewords(v)*8 {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, fmt.Errorf("текст для QR-кода слишком длинный: %d байт", len(data))
+	}
+
+	size := 17 + 4*version
+	c := &Code{Size: size, modules: make([]bool, size*size), function: make([]bool, size*size)}
+	c.drawFunctionPatterns(version)
+	c.drawCodewords(addErrorCorrection(version, encodeData(version, data)))
+
+	// Выбираем маску с наименьшим штрафом, как требует стандарт
+	if mask < 0 {
+		bestPenalty := -1
+		for m := 0; m < 8; m++ {
+			c.applyMask(m)
+			c.drawFormatBits(m)
+			if p := c.penalty(); bestPenalty < 0 || p < bestPenalty {
+				mask, bestPenalty = m, p
+			}
+			c.applyMask(m)
+		}
+	}
+	c.applyMask(mask)
+	c.drawFormatBits(mask)
+	return c, nil
+}
+
+func dataCodewords(version int) int {
+	b := blocks[version-1]
+	return b.count1*b.data1 + b.count2*b.data2
+}
+
+// encodeData кодирует текст в байтовом режиме и дополняет до емкости версии
+func encodeData(version int, data []byte) []byte {
+	var bits []bool
+	appendBits := func(value, n int) {
+		for i := n - 1; i >= 0; i-- {
+			bits = append(bits, (value>>i)&1 != 0)
+		}
+	}
+	countBits := 8
+	if version >= 10 {
+		countBits = 16
+	}
+	appendBits(0x4, 4)
+	appendBits(len(data), countBits)
+	for _, b := range data {
+		appendBits(int(b), 8)
+	}
+
+	capacity := dataCodewords(version) * 8
+	terminator := capacity - len(bits)
+	if terminator > 4 {
+		terminator = 4
+	}
+	appendBits(0, terminator)
+	appendBits(0, (8-len(bits)%8)%8)
+
+	result := make([]byte, 0, capacity/8)
+	for i := 0; i < len(bits); i += 8 {
+		var b byte
+		for j := 0; j < 8; j++ {
+			if bits[i+j] {
+				b |= 1 << (7 - j)
+			}
+		}
+		result = append(result, b)
+	}
+	for pad := byte(0xEC); len(result) < capacity/8; pad ^= 0xEC ^ 0x11 {
+		result = append(result, pad)
+	}
+	return result
+}
+
+// addErrorCorrection делит данные на блоки, добавляет коды Рида — Соломона
+// и перемежает байты блоков
+func addErrorCorrection(version int, data []byte) []byte {
+	b := blocks[version-1]
+	divisor := rsDivisor(b.ec)
+	var dataBlocks, ecBlocks [][]byte
+	offset := 0
+	for i := 0; i < b.count1+b.count2; i++ {
+		n := b.data1
+		if i >= b.count1 {
+			n = b.data2
+		}
+		chunk := data[offset : offset+n]
+		offset += n
+		dataBlocks = append(dataBlocks, chunk)
+		ecBlocks = append(ecBlocks, rsRemainder(chunk, divisor))
+	}
+
+	var result []byte
+	for i := 0; i < b.data1 || i < b.data2; i++ {
+		for _, chunk := range dataBlocks {
+			if i < len(chunk) {
+				result = append(result, chunk[i])
+			}
+		}
+	}
+	for i := 0; i < b.ec; i++ {
+		for _, chunk := range ecBlocks {
+			result = append(result, chunk[i])
+		}
+	}
+	return result
+}
+
+// gfMul умножает в поле GF(256) с многочленом x^8+x^4+x^3+x^2+1
+func gfMul(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>i)&1) * int(x)
+	}
+	return byte(z)
+}
+
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMul(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMul(root, 0x02)
+	}
+	return result
+}
+
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i := range result {
+			result[i] ^= gfMul(divisor[i], factor)
+		}
+	}
+	return result
+}
+
+func (c *Code) set(x, y int, dark bool) {
+	c.modules[y*c.Size+x] = dark
+	c.function[y*c.Size+x] = true
+}
+
+func (c *Code) drawFunctionPatterns(version int) {
+	for i := 0; i < c.Size; i++ {
+		c.set(6, i, i%2 == 0)
+		c.set(i, 6, i%2 == 0)
+	}
+	c.drawFinder(3, 3)
+	c.drawFinder(c.Size-4, 3)
+	c.drawFinder(3, c.Size-4)
+
+	positions := alignment[version-1]
+	last := len(positions) - 1
+	for i, y := range positions {
+		for j, x := range positions {
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					c.set(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// Место под формат резервируется сейчас, заполняется после выбора маски
+	c.drawFormatBits(0)
+
+	if version >= 7 {
+		rem := version
+		for i := 0; i < 12; i++ {
+			rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+		}
+		bits := version<<12 | rem
+		for i := 0; i < 18; i++ {
+			dark := (bits>>i)&1 != 0
+			a, b := c.Size-11+i%3, i/3
+			c.set(a, b, dark)
+			c.set(b, a, dark)
+		}
+	}
+}
+
+// drawFinder рисует поисковый узор с центром в (x, y) вместе со светлой рамкой
+func (c *Code) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || xx >= c.Size || yy < 0 || yy >= c.Size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			c.set(xx, yy, dist != 2 && dist != 4)
+		}
+	}
+}
+
+// drawFormatBits записывает уровень коррекции M и маску в обе копии поля формата
+func (c *Code) drawFormatBits(mask int) {
+	data := mask // Уровень M кодируется битами 00
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return (bits>>i)&1 != 0 }
+
+	for i := 0; i <= 5; i++ {
+		c.set(8, i, bit(i))
+	}
+	c.set(8, 7, bit(6))
+	c.set(8, 8, bit(7))
+	c.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.set(14-i, 8, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		c.set(c.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.set(8, c.Size-15+i, bit(i))
+	}
+	c.set(8, c.Size-8, true)
+}
+
+// drawCodewords укладывает байты зигзагом по парам столбцов, начиная с правого нижнего угла
+func (c *Code) drawCodewords(data []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				if c.function[y*c.Size+x] || i >= len(data)*8 {
+					continue
+				}
+				c.modules[y*c.Size+x] = (data[i>>3]>>(7-i&7))&1 != 0
+				i++
+			}
+		}
+	}
+}
+
+// applyMask инвертирует модули данных по маске; повторный вызов снимает маску
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !c.function[y*c.Size+x] {
+				c.modules[y*c.Size+x] = !c.modules[y*c.Size+x]
+			}
+		}
+	}
+}
+
+// penalty оценивает, насколько код трудно читать: длинные серии, квадраты одного
+// цвета, ложные поисковые узоры и перекос темных модулей
+func (c *Code) penalty() int {
+	result := 0
+	line := make([]bool, c.Size)
+	for _, rows := range []bool{true, false} {
+		for a := 0; a < c.Size; a++ {
+			for b := 0; b < c.Size; b++ {
+				if rows {
+					line[b] = c.Dark(b, a)
+				} else {
+					line[b] = c.Dark(a, b)
+				}
+			}
+			run := 1
+			for b := 1; b <= c.Size; b++ {
+				if b < c.Size && line[b] == line[b-1] {
+					run++
+					continue
+				}
+				if run >= 5 {
+					result += 3 + run - 5
+				}
+				run = 1
+			}
+			for b := 0; b+7 <= c.Size; b++ {
+				if !finderLike(line[b : b+7]) {
+					continue
+				}
+				if lightRun(line, b-4, b) || lightRun(line, b+7, b+11) {
+					result += 40
+				}
+			}
+		}
+	}
+
+	dark := 0
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.Dark(x, y) {
+				dark++
+			}
+			if x+1 < c.Size && y+1 < c.Size {
+				color := c.Dark(x, y)
+				if color == c.Dark(x+1, y) && color == c.Dark(x, y+1) && color == c.Dark(x+1, y+1) {
+					result += 3
+				}
+			}
+		}
+	}
+	total := c.Size * c.Size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	result += k * 10
+	return result
+}
+
+// finderLike сообщает, что семь модулей образуют узор 1:1:3:1:1
+func finderLike(m []bool) bool {
+	return m[0] && !m[1] && m[2] && m[3] && m[4] && !m[5] && m[6]
+}
+
+// lightRun сообщает, что модули с from по to светлые; за краем кода модули светлые
+func lightRun(line []bool, from, to int) bool {
+	for i := from; i < to; i++ {
+		if i >= 0 && i < len(line) && line[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,128 @@
+package qr
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// render рисует код строками из "#" и "." без светлого поля
+func render(c *Code) string {
+	var b strings.Builder
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.Dark(x, y) {
+				b.WriteByte('#')
+			} else {
+				b.WriteByte('.')
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// link возвращает ссылку с токеном длиной n байт
+func link(n int) string {
+	s := "https://example.com/bind?install="
+	for len(s) < n {
+		s += "0123456789abcdef"
+	}
+	return s[:n]
+}
+
+// goldens — эталонные коды из testdata, построенные rsc.io/qr/coding в байтовом
+// режиме с уровнем M и той же маской. Маска задана явно: кодировщики по-разному
+// считают штраф за ложные поисковые узоры и выбирают разные маски.
+var goldens = []struct {
+	file string
+	text string
+	mask int
+}{
+	{"v1-mask0", "https://a.io/x", 0},
+	// Цифры и заглавные буквы другие кодировщики пишут в цифровом и буквенном
+	// режимах, здесь они остаются в байтовом
+	{"v1-digits-mask3", "0123456789", 3},
+	{"v2-upper-mask5", "HTTPS://EXAMPLE.COM", 5},
+	{"v5-mask1", link(80), 1},
+	// С версии 7 в коде есть поле версии, с 8 — блоки двух размеров
+	{"v7-mask2", link(120), 2},
+	{"v8-mask6", link(150), 6},
+	// В версии 10 длина данных записывается 16 битами
+	{"v10-mask4", link(213), 4},
+	{"v10-mask7", link(200), 7},
+}
+
+func TestGolden(t *testing.T) {
+	for _, g := range goldens {
+		t.Run(g.file, func(t *testing.T) {
+			want, err := os.ReadFile(filepath.Join("testdata", g.file+".txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, err := encode(g.text, g.mask)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := render(c)
+			if got == string(want) {
+				return
+			}
+			gotRows, wantRows := strings.Split(got, "\n"), strings.Split(string(want), "\n")
+			if len(gotRows) != len(wantRows) {
+				t.Fatalf("сторона %d, ожидалось %d", c.Size, len(wantRows)-1)
+			}
+			for y := range wantRows {
+				if gotRows[y] != wantRows[y] {
+					t.Errorf("строка %d:\n%s\nожидалось:\n%s", y, gotRows[y], wantRows[y])
+				}
+			}
+		})
+	}
+}
+
+// Выбранная маска должна давать один из правильных кодов, то есть поле формата
+// должно соответствовать маске, которой закрыты данные
+func TestEncodeMask(t *testing.T) {
+	for _, g := range goldens {
+		c, err := Encode(g.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := render(c)
+		found := false
+		for mask := 0; mask < 8 && !found; mask++ {
+			masked, _ := encode(g.text, mask)
+			found = render(masked) == got
+		}
+		if !found {
+			t.Errorf("%s: код не совпадает ни с одной маской", g.file)
+		}
+	}
+}
+
+func TestEncodeVersion(t *testing.T) {
+	for _, tt := range []struct {
+		n    int
+		size int
+	}{
+		{1, 21},
+		{14, 21}, // Наибольшая длина для версии 1
+		{15, 25},
+		{106, 41}, // Версия 6
+		{107, 45},
+		{213, 57}, // Наибольшая поддерживаемая длина
+	} {
+		c, err := Encode(link(tt.n))
+		if err != nil {
+			t.Fatalf("%d байт: %v", tt.n, err)
+		}
+		if c.Size != tt.size {
+			t.Errorf("%d байт: сторона %d, ожидалось %d", tt.n, c.Size, tt.size)
+		}
+	}
+	if _, err := Encode(link(214)); err == nil {
+		t.Error("214 байт закодированы, хотя не помещаются в версию 10")
+	}
+}
@@ -0,0 +1,219 @@
+// Package receipt регистрирует установленную игру в пакетной базе дистрибутива,
+// чтобы ее видели dpkg -l, rpm -qa и программы учета ПО. Устанавливается пустой
+// пакет-квитанция: он не содержит файлов игры, только описание, версию и размер.
+// Для установки и удаления пакета нужны права root, их запрашивает pkexec.
+package receipt
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang-installer/internal/runner"
+)
+
+const (
+	Deb = "deb"
+	RPM = "rpm"
+)
+
+// timeout — ограничение по времени с учетом того, что пользователь вводит пароль
+const timeout = 5 * time.Minute
+
+// Receipt — установленный пакет-квитанция
+type Receipt struct {
+	Kind    string `json:"kind"`
+	Package string `json:"package"`
+}
+
+// Info — описание игры для квитанции
+type Info struct {
+	Slug        string
+	Name        string
+	Version     string
+	InstallPath string
+	Size        int64 // Байт
+}
+
+// Detect возвращает формат пакетов системы или пустую строку, если пакетный
+// менеджер не найден. Для RPM нужен еще rpmbuild, которым собирается квитанция.
+func Detect() string {
+	if _, err := exec.LookPath("dpkg"); err == nil {
+		return Deb
+	}
+	if _, err := exec.LookPath("rpm"); err == nil {
+		if _, err := exec.LookPath("rpmbuild"); err == nil {
+			return RPM
+		}
+	}
+	return ""
+}
+
+// PackageName возвращает имя пакета для игры
+func PackageName(slug string) string {
+	return "game-" + slug
+}
+
+// packageVersion приводит версию игры к виду, который примут dpkg и rpm: только
+// допустимые символы и цифра в начале
+func packageVersion(version string) string {
+	var b strings.Builder
+	for _, r := range version {
+		switch {
+		case r >= '0' && r <= '9', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '.', r == '+', r == '~':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('.')
+		}
+	}
+	v := strings.Trim(b.String(), ".")
+	if v == "" || v[0] < '0' || v[0] > '9' {
+		v = "0+" + v
+	}
+	return strings.TrimSuffix(v, "+")
+}
+
+// Register собирает квитанцию и устанавливает ее. Повторная регистрация той же
+// игры обновляет версию пакета.
+func Register(kind string, info Info, tmpRoot string) (*Receipt, error) {
+	dir, err := os.MkdirTemp(tmpRoot, "go-qt-installer-receipt-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	r := &Receipt{Kind: kind, Package: PackageName(info.Slug)}
+	switch kind {
+	case Deb:
+		path := filepath.Join(dir, r.Package+".deb")
+		if err := writeDeb(path, r.Package, info); err != nil {
+			return nil, err
+		}
+		err = privileged("dpkg", "-i", path)
+	case RPM:
+		var path string
+		if path, err = buildRPM(dir, r.Package, info); err != nil {
+			return nil, err
+		}
+		// --replacepkgs и --oldpackage позволяют переустановить ту же или более старую версию
+		err = privileged("rpm", "-U", "--replacepkgs", "--oldpackage", "--nodeps", path)
+	default:
+		return nil, fmt.Errorf("неизвестный формат пакетов %q", kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Remove удаляет квитанцию из пакетной базы
+func Remove(r *Receipt) error {
+	switch r.Kind {
+	case Deb:
+		return privileged("dpkg", "-r", r.Package)
+	case RPM:
+		return privileged("rpm", "-e", "--nodeps", r.Package)
+	}
+	return fmt.Errorf("неизвестный формат пакетов %q", r.Kind)
+}
+
+// privileged запускает программу от root: напрямую, если установщик уже запущен
+// от root, иначе через pkexec
+func privileged(name string, args ...string) error {
+	if os.Geteuid() != 0 {
+		args = append([]string{name}, args...)
+		name = "pkexec"
+	}
+	_, err := runner.Output(timeout, name, args...)
+	return err
+}
+
+func description(info Info) string {
+	return fmt.Sprintf("%s (установлено go-qt-installer)", info.Name)
+}
+
+func longDescription(info Info) string {
+	return "Игра установлена в " + info.InstallPath + ". Пакет содержит только сведения об установке, файлы игры в него не входят."
+}
+
+// writeDeb собирает пакет .deb: архив ar из debian-binary, control.tar.gz и пустого data.tar.gz
+func writeDeb(path, name string, info Info) error {
+	control := fmt.Sprintf("Package: %s\nVersion: %s\nArchitecture: all\nMaintainer: go-qt-installer <root@localhost>\n"+
+		"Installed-Size: %d\nSection: games\nPriority: optional\nDescription: %s\n %s\n",
+		name, packageVersion(info.Version), (info.Size+1023)/1024, description(info), longDescription(info))
+
+	controlTar, err := tarGz(map[string]string{"./control": control})
+	if err != nil {
+		return err
+	}
+	dataTar, err := tarGz(nil)
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	b.WriteString("!<arch>\n")
+	now := time.Now().Unix()
+	for _, member := range []struct {
+		name string
+		data []byte
+	}{
+		{"debian-binary", []byte("2.0\n")},
+		{"control.tar.gz", controlTar},
+		{"data.tar.gz", dataTar},
+	} {
+		fmt.Fprintf(&b, "%-16s%-12d%-6d%-6d%-8s%-10d`\n", member.name, now, 0, 0, "100644", len(member.data))
+		b.Write(member.data)
+		if len(member.data)%2 == 1 {
+			b.WriteByte('\n')
+		}
+	}
+	return os.WriteFile(path, b.Bytes(), 0644)
+}
+
+func tarGz(files map[string]string) ([]byte, error) {
+	var b bytes.Buffer
+	gz := gzip.NewWriter(&b)
+	w := tar.NewWriter(gz)
+	for name, content := range files {
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), ModTime: time.Now()}
+		if err := w.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// buildRPM собирает пустой пакет через rpmbuild и возвращает путь к нему
+func buildRPM(dir, name string, info Info) (string, error) {
+	spec := fmt.Sprintf("Name: %s\nVersion: %s\nRelease: 1\nSummary: %s\nLicense: Proprietary\nBuildArch: noarch\n\n"+
+		"%%description\n%s\n\n%%files\n",
+		name, packageVersion(info.Version), description(info), longDescription(info))
+	specPath := filepath.Join(dir, name+".spec")
+	if err := os.WriteFile(specPath, []byte(spec), 0644); err != nil {
+		return "", err
+	}
+	if _, err := runner.Output(timeout, "rpmbuild", "-bb", "--define", "_topdir "+dir, specPath); err != nil {
+		return "", err
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, "RPMS", "noarch", name+"-*.rpm"))
+	if len(matches) == 0 {
+		return "", fmt.Errorf("rpmbuild не создал пакет")
+	}
+	return matches[0], nil
+}
@@ -0,0 +1,302 @@
+// Package report собирает отчет для поддержки: журнал, сводку об установке и
+// сведения о системе в одном zip-архиве. Отчет отправляется на адрес издателя,
+// а если адреса нет или он недоступен, сохраняется в домашней директории, чтобы
+// игрок приложил его к письму.
+package report
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang-installer/internal/common"
+	"golang-installer/internal/diagnostics"
+)
+
+// maxLog — сколько последних байт журнала хранится в памяти
+const maxLog = 4 << 20
+
+// Timeout — сколько ждать ответа сервера поддержки
+const Timeout = time.Minute
+
+// session — журнал текущего запуска
+var session struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+type sessionWriter struct{}
+
+func (sessionWriter) Write(p []byte) (int, error) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	session.buf = append(session.buf, p...)
+	if len(session.buf) > maxLog {
+		session.buf = append([]byte(nil), session.buf[len(session.buf)-maxLog:]...)
+	}
+	return len(p), nil
+}
+
+// Capture дублирует журнал программы в память, чтобы приложить его к отчету
+func Capture() {
+	log.SetOutput(io.MultiWriter(os.Stderr, sessionWriter{}))
+}
+
+// SessionLog возвращает журнал текущего запуска
+func SessionLog() []byte {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return append([]byte(nil), session.buf...)
+}
+
+// AppendLog дописывает журнал текущего запуска в файл: при обновлении в нем
+// остаются журналы прежних установок
+func AppendLog(path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fmt.Fprintf(f, "===== %s =====\n", time.Now().Format(time.RFC3339))
+	_, err = f.Write(SessionLog())
+	return err
+}
+
+// Report — содержимое отчета
+type Report struct {
+	Game      string
+	InstallID string
+	Summary   interface{}       // Сводка об установке, записывается в summary.json
+	Log       []byte            // Журнал текущего запуска, session.log
+	Files     map[string]string // Другие файлы: имя в архиве — путь на диске
+	Dir       string            // Директория, для диска которой указывается свободное место
+}
+
+// Zip упаковывает отчет. Недоступные файлы пропускаются, о них пишется в system.txt.
+func (r *Report) Zip() ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	add := func(name string, data []byte) error {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+
+	summary, err := json.MarshalIndent(r.Summary, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := add("summary.json", summary); err != nil {
+		return nil, err
+	}
+	if len(r.Log) > 0 {
+		if err := add("session.log", r.Log); err != nil {
+			return nil, err
+		}
+	}
+	system := System(r.Dir)
+	for name, path := range r.Files {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			system += fmt.Sprintf("Файл %s не приложен: %v\n", name, err)
+			continue
+		}
+		if err := add(name, data); err != nil {
+			return nil, err
+		}
+	}
+	if err := add("system.txt", []byte(system)); err != nil {
+		return nil, err
+	}
+	if err := add("diagnostics.txt", []byte(diagnostics.Text(diagnostics.Run()))); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// System описывает систему: дистрибутив, ядро, рабочий стол, локаль, память и
+// свободное место на диске dir
+func System(dir string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Дистрибутив: %s\n", osRelease())
+	if kernel, err := ioutil.ReadFile("/proc/sys/kernel/osrelease"); err == nil {
+		fmt.Fprintf(&b, "Ядро: %s %s\n", strings.TrimSpace(string(kernel)), runtime.GOARCH)
+	}
+	fmt.Fprintf(&b, "Процессоров: %d\n", runtime.NumCPU())
+	if mem := memTotal(); mem != "" {
+		fmt.Fprintf(&b, "Память: %s\n", mem)
+	}
+	for _, name := range []string{"XDG_CURRENT_DESKTOP", "XDG_SESSION_TYPE", "WAYLAND_DISPLAY", "DISPLAY", "LANG", "LC_ALL"} {
+		fmt.Fprintf(&b, "%s=%s\n", name, os.Getenv(name))
+	}
+	if dir != "" {
+		var stat syscall.Statfs_t
+		if err := syscall.Statfs(existing(dir), &stat); err == nil {
+			fmt.Fprintf(&b, "Свободно на диске %s: %.1f ГБ\n", dir, float64(stat.Bavail*uint64(stat.Bsize))/(1<<30))
+		}
+	}
+	return b.String()
+}
+
+// osRelease возвращает название дистрибутива из /etc/os-release
+func osRelease() string {
+	f, err := os.Open("/etc/os-release")
+	if err != nil {
+		return "неизвестен"
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "PRETTY_NAME="); ok {
+			return strings.Trim(value, `"`)
+		}
+	}
+	return "неизвестен"
+}
+
+// memTotal возвращает объем памяти из /proc/meminfo
+func memTotal() string {
+	data, err := ioutil.ReadFile("/proc/meminfo")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if value, ok := strings.CutPrefix(line, "MemTotal:"); ok {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// existing возвращает ближайшую существующую директорию на пути
+func existing(dir string) string {
+	for {
+		if _, err := os.Stat(dir); err == nil || dir == filepath.Dir(dir) {
+			return dir
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// Upload отправляет архив отчета POST-запросом на адрес url и возвращает номер
+// обращения из ответа: поле "ticket" JSON или первую строку текста
+func Upload(ctx context.Context, url string, r *Report, data []byte) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/zip")
+	req.Header.Set("User-Agent", "go-qt-installer")
+	req.Header.Set("X-Game", r.Game)
+	if r.InstallID != "" {
+		req.Header.Set("X-Install-Id", r.InstallID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("сервер вернул %s", resp.Status)
+	}
+
+	var reply struct {
+		Ticket string `json:"ticket"`
+	}
+	if json.Unmarshal(body, &reply) == nil && reply.Ticket != "" {
+		return reply.Ticket, nil
+	}
+	if line := strings.TrimSpace(strings.SplitN(string(body), "\n", 2)[0]); line != "" && !strings.HasPrefix(line, "{") {
+		return line, nil
+	}
+	return "", fmt.Errorf("сервер не вернул номер обращения")
+}
+
+// Reference — номер сохраненного отчета, который игрок сообщает поддержке сам:
+// код установки и время, например 3F2A-9C1B-20261016-1230
+func Reference(supportCode string, t time.Time) string {
+	if supportCode == "" {
+		supportCode = "REPORT"
+	}
+	return supportCode + "-" + t.Format("20060102-1504")
+}
+
+// Result — чем закончилась отправка отчета
+type Result struct {
+	Ticket    string // Номер обращения от сервера или номер сохраненного отчета
+	Saved     string // Архив на диске, если отчет не отправлен
+	UploadErr error  // Почему отчет не удалось отправить
+}
+
+// Message объясняет результат игроку
+func (res *Result) Message() string {
+	if res.Saved == "" {
+		return "Отчет отправлен в поддержку. Номер обращения: " + res.Ticket
+	}
+	text := fmt.Sprintf("Отчет сохранен в %s.\nПриложите файл к письму в поддержку и укажите номер %s.", res.Saved, res.Ticket)
+	if res.UploadErr != nil {
+		text += fmt.Sprintf("\n\nОтправить отчет не удалось: %v", res.UploadErr)
+	}
+	return text
+}
+
+// Send отправляет отчет на адрес url. Если адреса нет или отправить не удалось,
+// архив сохраняется на диске, чтобы игрок приложил его к письму.
+func Send(ctx context.Context, url string, r *Report) (*Result, error) {
+	data, err := r.Zip()
+	if err != nil {
+		return nil, err
+	}
+	res := &Result{}
+	if url != "" {
+		if res.Ticket, res.UploadErr = Upload(ctx, url, r, data); res.UploadErr == nil {
+			log.Printf("Отчет для поддержки отправлен, номер обращения %s", res.Ticket)
+			return res, nil
+		}
+		log.Printf("Отчет для поддержки не отправлен: %v", res.UploadErr)
+	}
+	res.Ticket = Reference(common.SupportCode(r.InstallID), time.Now())
+	if res.Saved, err = SaveLocal(res.Ticket, data); err != nil {
+		return nil, err
+	}
+	log.Printf("Отчет для поддержки сохранен в %s", res.Saved)
+	return res, nil
+}
+
+// SaveLocal сохраняет архив отчета в домашней директории и возвращает путь к нему
+func SaveLocal(reference string, data []byte) (string, error) {
+	dir := os.Getenv("HOME")
+	if documents := filepath.Join(dir, "Documents"); isDir(documents) {
+		dir = documents
+	}
+	path := filepath.Join(dir, "support-report-"+reference+".zip")
+	return path, ioutil.WriteFile(path, data, 0600)
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
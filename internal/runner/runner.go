@@ -0,0 +1,105 @@
+// Package runner запускает служебные программы (gio, update-desktop-database,
+// xprop и другие) с ограничением по времени и урезанным окружением. Вывод программ
+// попадает в журнал, а отсутствие необязательной программы не считается ошибкой
+// установки: шаг просто пропускается.
+package runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout — ограничение по времени для служебных программ
+const DefaultTimeout = 30 * time.Second
+
+// ErrNotFound — программа не установлена в системе
+var ErrNotFound = errors.New("программа не найдена")
+
+// envAllowlist — переменные, которые нужны служебным программам: поиск программ,
+// локаль, доступ к сессии рабочего стола и к каталогам XDG
+var envAllowlist = []string{
+	"PATH", "HOME", "USER", "LOGNAME", "LANG", "LANGUAGE", "LC_ALL", "LC_MESSAGES", "LC_CTYPE",
+	"DISPLAY", "XAUTHORITY", "WAYLAND_DISPLAY", "XDG_RUNTIME_DIR", "DBUS_SESSION_BUS_ADDRESS",
+	"XDG_DATA_HOME", "XDG_DATA_DIRS", "XDG_CONFIG_HOME", "XDG_CONFIG_DIRS", "XDG_CACHE_HOME",
+	"XDG_CURRENT_DESKTOP", "XDG_SESSION_TYPE", "SWAYSOCK", "HYPRLAND_INSTANCE_SIGNATURE", "TMPDIR",
+	"GNUPGHOME", "GPG_TTY",
+}
+
+// Env возвращает урезанное окружение для служебных программ
+func Env() []string {
+	var env []string
+	for _, name := range envAllowlist {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	return env
+}
+
+// missingLogged — программы, об отсутствии которых уже написано в журнал
+var missingLogged sync.Map
+
+// Command готовит команду с урезанным окружением для длительных программ, вывод
+// которых читается по ходу работы. Для коротких вызовов используйте Run и Output.
+func Command(ctx context.Context, name string, args ...string) (*exec.Cmd, error) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		if _, logged := missingLogged.LoadOrStore(name, true); !logged {
+			log.Printf("%s не найден, шаг пропущен", name)
+		}
+		return nil, fmt.Errorf("%s: %w", name, ErrNotFound)
+	}
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Env = Env()
+	// После отмены даем программе немного времени завершиться и закрыть вывод
+	cmd.WaitDelay = 5 * time.Second
+	return cmd, nil
+}
+
+// Output запускает программу, ждет ее не дольше timeout и возвращает стандартный вывод.
+// Вывод ошибок пишется в журнал.
+func Output(timeout time.Duration, name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd, err := Command(ctx, name, args...)
+	if err != nil {
+		return nil, err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("%s не завершился за %s", name, timeout)
+	}
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		log.Printf("%s: %s", name, msg)
+	}
+	if err != nil {
+		log.Printf("Ошибка выполнения %s %s: %v", name, strings.Join(args, " "), err)
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = fmt.Errorf("%v: %s", err, msg)
+		}
+	}
+	return stdout.Bytes(), err
+}
+
+// Run запускает служебную программу с ограничением DefaultTimeout, весь ее вывод
+// пишется в журнал
+func Run(name string, args ...string) error {
+	out, err := Output(DefaultTimeout, name, args...)
+	if msg := strings.TrimSpace(string(out)); msg != "" {
+		log.Printf("%s: %s", name, msg)
+	}
+	return err
+}
@@ -0,0 +1,233 @@
+// Package settings хранит общие настройки установщика и менеджера игр в одном
+// файле ~/.config/go-qt-installer/settings.ini (формат QSettings) и показывает
+// диалог для их изменения. Настройки пользователя действуют во всех установщиках,
+// а значения из config.json конкретной игры важнее их.
+package settings
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/therecipe/qt/core"
+	"github.com/therecipe/qt/gui"
+	"github.com/therecipe/qt/widgets"
+
+	"golang-installer/internal/diagnostics"
+	"golang-installer/internal/downloadcache"
+	"golang-installer/internal/source"
+)
+
+const (
+	// ThemeDark — темная палитра, с которой установщик выглядел всегда
+	ThemeDark = "dark"
+	// ThemeSystem — палитра стиля Qt, обычно совпадающая с темой рабочего стола
+	ThemeSystem = "system"
+)
+
+const (
+	// MeteredWarn — спросить перед большой загрузкой по лимитному подключению
+	MeteredWarn = "warn"
+	// MeteredDefer — дождаться безлимитного подключения
+	MeteredDefer = "defer"
+)
+
+// Settings — настройки пользователя
+type Settings struct {
+	Theme         string
+	Language      string // Предпочтительный язык игр, пустая строка — язык системы
+	InstallRoot   string // Директория, в которую по умолчанию ставятся игры
+	DownloadLimit int    // КБ/с, 0 — без ограничений
+	UpdateLimit   int    // КБ/с для фоновых обновлений, 0 — как DownloadLimit
+	Metered       string // Что делать с большой загрузкой по лимитному подключению
+	CacheDir      string // Кэш загрузок, пустая строка — $XDG_CACHE_HOME
+	Telemetry     bool   // Согласие на отправку анонимной статистики
+}
+
+// Path возвращает файл настроек
+func Path() string {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(os.Getenv("HOME"), ".config")
+	}
+	return filepath.Join(configHome, "go-qt-installer", "settings.ini")
+}
+
+func open() *core.QSettings {
+	return core.NewQSettings4(Path(), core.QSettings__IniFormat, nil)
+}
+
+// Load читает настройки. Отсутствующие значения заменяются значениями по умолчанию.
+func Load() Settings {
+	q := open()
+	defer q.DestroyQSettings()
+	return Settings{
+		Theme:         q.Value("ui/theme", core.NewQVariant12(ThemeDark)).ToString(),
+		Language:      q.Value("ui/language", core.NewQVariant12("")).ToString(),
+		InstallRoot:   q.Value("install/root", core.NewQVariant12("")).ToString(),
+		DownloadLimit: q.Value("downloads/limit_kb", core.NewQVariant5(0)).ToInt(nil),
+		UpdateLimit:   q.Value("updates/limit_kb", core.NewQVariant5(0)).ToInt(nil),
+		Metered:       q.Value("downloads/metered", core.NewQVariant12(MeteredWarn)).ToString(),
+		CacheDir:      q.Value("downloads/cache_dir", core.NewQVariant12("")).ToString(),
+		Telemetry:     q.Value("privacy/telemetry", core.NewQVariant9(false)).ToBool(),
+	}
+}
+
+// Save записывает настройки на диск
+func (s Settings) Save() error {
+	if err := os.MkdirAll(filepath.Dir(Path()), 0755); err != nil {
+		return err
+	}
+	q := open()
+	defer q.DestroyQSettings()
+	q.SetValue("ui/theme", core.NewQVariant12(s.Theme))
+	q.SetValue("ui/language", core.NewQVariant12(s.Language))
+	q.SetValue("install/root", core.NewQVariant12(s.InstallRoot))
+	q.SetValue("downloads/limit_kb", core.NewQVariant5(s.DownloadLimit))
+	q.SetValue("updates/limit_kb", core.NewQVariant5(s.UpdateLimit))
+	q.SetValue("downloads/metered", core.NewQVariant12(s.Metered))
+	q.SetValue("downloads/cache_dir", core.NewQVariant12(s.CacheDir))
+	q.SetValue("privacy/telemetry", core.NewQVariant9(s.Telemetry))
+	q.Sync()
+	return nil
+}
+
+// Apply передает настройки загрузок пакетам, которые их используют. Кэш загрузок
+// после смены директории нужно создать заново.
+func Apply(s Settings) {
+	downloadcache.CustomDir = s.CacheDir
+	source.RateLimit = int64(s.DownloadLimit) << 10
+}
+
+// ApplyTheme устанавливает палитру приложения
+func ApplyTheme(app *widgets.QApplication, theme string) {
+	if theme == ThemeSystem {
+		app.SetPalette(widgets.QApplication_Style().StandardPalette(), "")
+		return
+	}
+
+	darkPalette := gui.NewQPalette()
+	darkColor := gui.NewQColor3(53, 53, 53, 255)
+	whiteColor := gui.NewQColor3(255, 255, 255, 255)
+	darkGreyColor := gui.NewQColor3(25, 25, 25, 255)
+
+	darkPalette.SetColor2(gui.QPalette__Window, darkColor)
+	darkPalette.SetColor2(gui.QPalette__WindowText, whiteColor)
+	darkPalette.SetColor2(gui.QPalette__Base, darkGreyColor)
+	darkPalette.SetColor2(gui.QPalette__AlternateBase, darkGreyColor)
+	darkPalette.SetColor2(gui.QPalette__ToolTipBase, darkColor)
+	darkPalette.SetColor2(gui.QPalette__ToolTipText, whiteColor)
+	darkPalette.SetColor2(gui.QPalette__Text, whiteColor)
+	darkPalette.SetColor2(gui.QPalette__Button, darkColor)
+	darkPalette.SetColor2(gui.QPalette__ButtonText, whiteColor)
+	darkPalette.SetColor2(gui.QPalette__BrightText, whiteColor)
+
+	app.SetPalette(darkPalette, "")
+}
+
+// directoryField — поле с путем и кнопкой выбора директории
+func directoryField(value, placeholder, title string) (*widgets.QHBoxLayout, *widgets.QLineEdit) {
+	edit := widgets.NewQLineEdit2(value, nil)
+	edit.SetPlaceholderText(placeholder)
+	browse := widgets.NewQPushButton2("Обзор…", nil)
+	browse.ConnectClicked(func(bool) {
+		if dir := widgets.QFileDialog_GetExistingDirectory(nil, title, edit.Text(), 0); dir != "" {
+			edit.SetText(dir)
+		}
+	})
+	layout := widgets.NewQHBoxLayout()
+	layout.AddWidget(edit, 1, 0)
+	layout.AddWidget(browse, 0, 0)
+	return layout, edit
+}
+
+// ShowDialog показывает диалог настроек. Если пользователь сохранил изменения,
+// возвращает новые настройки и true; тема применяется сразу.
+func ShowDialog(app *widgets.QApplication) (Settings, bool) {
+	current := Load()
+
+	dialog := widgets.NewQDialog(nil, 0)
+	dialog.SetWindowTitle("Настройки")
+
+	theme := widgets.NewQComboBox(nil)
+	theme.AddItem("Темная", core.NewQVariant12(ThemeDark))
+	theme.AddItem("Как в системе", core.NewQVariant12(ThemeSystem))
+	if i := theme.FindData(core.NewQVariant12(current.Theme), int(core.Qt__UserRole), core.Qt__MatchExactly); i >= 0 {
+		theme.SetCurrentIndex(i)
+	}
+
+	language := widgets.NewQLineEdit2(current.Language, nil)
+	language.SetPlaceholderText("как в системе, например ru или en")
+
+	rootLayout, root := directoryField(current.InstallRoot, "выбирается при установке", "Директория для игр")
+	cacheLayout, cache := directoryField(current.CacheDir, "~/.cache/go-qt-installer/downloads", "Кэш загрузок")
+
+	limit := widgets.NewQSpinBox(nil)
+	limit.SetRange(0, 1000000)
+	limit.SetSuffix(" КБ/с")
+	limit.SetSpecialValueText("без ограничений")
+	limit.SetValue(current.DownloadLimit)
+
+	updateLimit := widgets.NewQSpinBox(nil)
+	updateLimit.SetRange(0, 1000000)
+	updateLimit.SetSuffix(" КБ/с")
+	updateLimit.SetSpecialValueText("как для загрузок")
+	updateLimit.SetValue(current.UpdateLimit)
+
+	metered := widgets.NewQComboBox(nil)
+	metered.AddItem("Спросить", core.NewQVariant12(MeteredWarn))
+	metered.AddItem("Дождаться безлимитного", core.NewQVariant12(MeteredDefer))
+	if i := metered.FindData(core.NewQVariant12(current.Metered), int(core.Qt__UserRole), core.Qt__MatchExactly); i >= 0 {
+		metered.SetCurrentIndex(i)
+	}
+
+	telemetry := widgets.NewQCheckBox2("Отправлять анонимную статистику установок", nil)
+	telemetry.SetChecked(current.Telemetry)
+	telemetry.SetToolTip("Без согласия менеджер не показывает опросы издателей при удалении игр")
+
+	form := widgets.NewQFormLayout(nil)
+	form.AddRow3("Оформление:", theme)
+	form.AddRow3("Язык игр:", language)
+	form.AddRow4("Директория для игр:", rootLayout)
+	form.AddRow3("Скорость загрузки:", limit)
+	form.AddRow3("Фоновые обновления:", updateLimit)
+	form.AddRow3("Лимитное подключение:", metered)
+	form.AddRow4("Кэш загрузок:", cacheLayout)
+	form.AddRow5(telemetry)
+
+	buttons := widgets.NewQDialogButtonBox3(widgets.QDialogButtonBox__Ok|widgets.QDialogButtonBox__Cancel, nil)
+	buttons.ConnectAccepted(dialog.Accept)
+	buttons.ConnectRejected(dialog.Reject)
+	// Диагностика здесь, потому что окно настроек есть и в установщике, и в менеджере
+	diagnosticsButton := buttons.AddButton2("Диагностика…", widgets.QDialogButtonBox__ActionRole)
+	diagnosticsButton.ConnectClicked(func(bool) {
+		diagnostics.ShowDialog()
+	})
+
+	layout := widgets.NewQVBoxLayout()
+	layout.AddLayout(form, 0)
+	layout.AddWidget(buttons, 0, 0)
+	dialog.SetLayout(layout)
+
+	if dialog.Exec() != int(widgets.QDialog__Accepted) {
+		return current, false
+	}
+
+	updated := Settings{
+		Theme:         theme.CurrentData(int(core.Qt__UserRole)).ToString(),
+		Language:      strings.TrimSpace(language.Text()),
+		InstallRoot:   root.Text(),
+		DownloadLimit: limit.Value(),
+		UpdateLimit:   updateLimit.Value(),
+		Metered:       metered.CurrentData(int(core.Qt__UserRole)).ToString(),
+		CacheDir:      cache.Text(),
+		Telemetry:     telemetry.IsChecked(),
+	}
+	if err := updated.Save(); err != nil {
+		widgets.QMessageBox_Warning(nil, "Предупреждение", "Не удалось сохранить настройки: "+err.Error(),
+			widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+		return current, false
+	}
+	ApplyTheme(app, updated.Theme)
+	return updated, true
+}
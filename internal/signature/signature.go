@@ -0,0 +1,123 @@
+// Package signature подписывает файлы с информацией об установке, чтобы деинсталлятор
+// мог обнаружить их ручное изменение перед удалением файлов игры.
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Field — имя поля JSON, в котором хранится подпись
+const Field = "signature"
+
+var (
+	// ErrUnsigned возвращается для файлов, записанных без подписи (старые версии установщика)
+	ErrUnsigned = errors.New("информация об установке не подписана")
+	// ErrTampered возвращается, если содержимое файла не совпадает с подписью
+	ErrTampered = errors.New("информация об установке была изменена после установки")
+)
+
+// KeyPath возвращает путь к секретному ключу пользователя
+func KeyPath() string {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(os.Getenv("HOME"), ".local", "share")
+	}
+	return filepath.Join(dataHome, "go-qt-installer", "install.key")
+}
+
+// loadKey читает ключ пользователя и создает его при первом обращении, если create == true
+func loadKey(create bool) ([]byte, error) {
+	keyPath := KeyPath()
+	data, err := os.ReadFile(keyPath)
+	if err == nil {
+		return hex.DecodeString(strings.TrimSpace(string(data)))
+	}
+	if !os.IsNotExist(err) || !create {
+		return nil, fmt.Errorf("не удалось прочитать ключ подписи: %v", err)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("не удалось сгенерировать ключ подписи: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(keyPath), 0700); err != nil {
+		return nil, fmt.Errorf("не удалось создать директорию для ключа подписи: %v", err)
+	}
+	if err := os.WriteFile(keyPath, []byte(hex.EncodeToString(key)), 0600); err != nil {
+		return nil, fmt.Errorf("не удалось сохранить ключ подписи: %v", err)
+	}
+	return key, nil
+}
+
+// canonical приводит JSON к каноническому виду без поля подписи, чтобы подпись
+// не зависела от порядка полей и форматирования
+func canonical(data []byte) (map[string]json.RawMessage, []byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, nil, fmt.Errorf("ошибка при разборе JSON: %v", err)
+	}
+	signed := make(map[string]json.RawMessage, len(fields))
+	for k, v := range fields {
+		if k != Field {
+			signed[k] = v
+		}
+	}
+	out, err := json.Marshal(signed)
+	if err != nil {
+		return nil, nil, err
+	}
+	return fields, out, nil
+}
+
+func mac(key, data []byte) string {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Sign вычисляет подпись для JSON-документа; поле подписи в документе игнорируется
+func Sign(data []byte) (string, error) {
+	key, err := loadKey(true)
+	if err != nil {
+		return "", err
+	}
+	_, body, err := canonical(data)
+	if err != nil {
+		return "", err
+	}
+	return mac(key, body), nil
+}
+
+// Verify проверяет подпись JSON-документа
+func Verify(data []byte) error {
+	fields, body, err := canonical(data)
+	if err != nil {
+		return err
+	}
+
+	var sig string
+	if raw, ok := fields[Field]; ok {
+		json.Unmarshal(raw, &sig)
+	}
+	if sig == "" {
+		return ErrUnsigned
+	}
+
+	key, err := loadKey(false)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(sig), []byte(mac(key, body))) {
+		return ErrTampered
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+// Package slug строит из названия игры идентификатор для имен файлов: .desktop,
+// записей реестра и файлов с информацией об установке. Идентификатор состоит
+// только из строчных латинских букв, цифр и дефисов.
+package slug
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// maxLength ограничивает длину, чтобы имена файлов не упирались в лимит файловой системы
+const maxLength = 64
+
+// cyrillic — транслитерация кириллицы, близкая к ГОСТ 7.79-2000 (схема Б) без апострофов
+var cyrillic = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "j", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "x", 'ц': "cz",
+	'ч': "ch", 'ш': "sh", 'щ': "shh", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+	'я': "ya", 'і': "i", 'ї': "yi", 'є': "ye", 'ґ': "g", 'ў': "u",
+}
+
+// latin — буквы, которые не раскладываются на основу и диакритический знак
+var latin = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'đ': "d", 'ð': "d", 'þ': "th", 'ł': "l", 'ı': "i",
+}
+
+// Make возвращает идентификатор для названия name. Если в названии нет ни одного
+// символа, который можно транслитерировать, идентификатор строится из его хэша.
+func Make(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(norm.NFC.String(name)) {
+		part, ok := transliterate(r)
+		if !ok {
+			dash = b.Len() > 0
+			continue
+		}
+		if dash {
+			b.WriteByte('-')
+			dash = false
+		}
+		b.WriteString(part)
+	}
+
+	slug := b.String()
+	if len(slug) > maxLength {
+		slug = strings.TrimRight(slug[:maxLength], "-")
+	}
+	if slug == "" {
+		sum := sha256.Sum256([]byte(name))
+		slug = fmt.Sprintf("game-%x", sum[:4])
+	}
+	return slug
+}
+
+// transliterate возвращает латинскую запись символа или false для разделителей
+// и символов, которые не удается записать латиницей
+func transliterate(r rune) (string, bool) {
+	if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+		return string(r), true
+	}
+	if part, ok := cyrillic[r]; ok {
+		return part, true
+	}
+	if part, ok := latin[r]; ok {
+		return part, true
+	}
+	// Буквы с диакритикой раскладываются на основу и знаки, знаки отбрасываем: é → e
+	var base strings.Builder
+	for _, d := range norm.NFD.String(string(r)) {
+		if d < unicode.MaxASCII && (unicode.IsLetter(d) || unicode.IsDigit(d)) {
+			base.WriteRune(d)
+		}
+	}
+	return base.String(), base.Len() > 0
+}
+
+// Unique добавляет к base суффикс -2, -3 и так далее, пока taken сообщает,
+// что идентификатор уже занят
+func Unique(base string, taken func(string) bool) string {
+	if !taken(base) {
+		return base
+	}
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s-%d", base, i)
+		if !taken(candidate) {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package slug
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMake(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		want string
+	}{
+		{"Half-Life 2", "half-life-2"},
+		{"Ведьмак 3: Дикая Охота", "vedmak-3-dikaya-oxota"},
+		{"Щука и Ёж", "shhuka-i-yozh"},
+		{"Їжак Ґудзик", "yizhak-gudzik"},
+		{"Café Überdrive", "cafe-uberdrive"},
+		{"Cafe\u0301", "cafe"}, // Разложенная форма NFD
+		{"Straße Œuvre", "strasse-oeuvre"},
+		{"AC/DC: Live", "ac-dc-live"},
+		{"  --Game--  ", "game"},
+		{"a//b::c", "a-b-c"},
+		{strings.Repeat("a", 100), strings.Repeat("a", 64)},
+		// Обрезка не оставляет дефис в конце
+		{strings.Repeat("a", 63) + " bbb", strings.Repeat("a", 63)},
+		{"", "game-e3b0c442"},
+		{"★★★", "game-597d9776"},
+		{"ゲーム", "game-fd246083"},
+	} {
+		if got := Make(tt.name); got != tt.want {
+			t.Errorf("Make(%q) = %q, ожидалось %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUnique(t *testing.T) {
+	for _, tt := range []struct {
+		taken []string
+		want  string
+	}{
+		{nil, "game"},
+		{[]string{"game"}, "game-2"},
+		{[]string{"game", "game-2"}, "game-3"},
+		// Свободный номер в середине не ищется заново: важна только занятость кандидата
+		{[]string{"game", "game-3"}, "game-2"},
+		{[]string{"game-2"}, "game"},
+	} {
+		taken := make(map[string]bool)
+		for _, s := range tt.taken {
+			taken[s] = true
+		}
+		if got := Unique("game", func(s string) bool { return taken[s] }); got != tt.want {
+			t.Errorf("Unique при занятых %v = %q, ожидалось %q", tt.taken, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+// Package snapshot устанавливает игру в отдельный подтом btrfs или набор данных ZFS
+// и делает снимок после успешной установки. Снимок разделяет блоки с файлами игры
+// и почти не занимает места, а по нему можно мгновенно вернуть исходное состояние
+// и быстро найти измененные файлы.
+package snapshot
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang-installer/internal/runner"
+	"golang-installer/internal/slug"
+)
+
+const (
+	Btrfs = "btrfs"
+	ZFS   = "zfs"
+)
+
+// Магические числа файловых систем из statfs
+const (
+	btrfsMagic = 0x9123683E
+	zfsMagic   = 0x2FC12FC1
+)
+
+// timeout — ограничение по времени для btrfs и zfs; удаление большого тома бывает долгим
+const timeout = 5 * time.Minute
+
+// nameLayout — формат имени снимка, допустимый и в btrfs, и в ZFS
+const nameLayout = "20060102-150405"
+
+// Snapshot — снимок директории игры после установки
+type Snapshot struct {
+	Name    string    `json:"name"`
+	Version string    `json:"version,omitempty"`
+	Date    time.Time `json:"date"`
+}
+
+// Volume — подтом или набор данных, в который установлена игра
+type Volume struct {
+	Kind      string     `json:"kind"`
+	Path      string     `json:"path"`
+	Dataset   string     `json:"dataset,omitempty"` // Только для ZFS
+	Snapshots []Snapshot `json:"snapshots,omitempty"`
+}
+
+// Detect возвращает файловую систему со снимками, на которой окажется path, или пустую
+// строку, если снимки недоступны: другая файловая система или нет нужной программы
+func Detect(path string) string {
+	dir := path
+	var stat syscall.Statfs_t
+	for syscall.Statfs(dir, &stat) != nil {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+
+	var kind string
+	switch uint32(stat.Type) {
+	case btrfsMagic:
+		kind = Btrfs
+	case zfsMagic:
+		kind = ZFS
+	default:
+		return ""
+	}
+	if _, err := exec.LookPath(kind); err != nil {
+		return ""
+	}
+	return kind
+}
+
+// Create создает подтом или набор данных для новой директории path
+func Create(kind, path string) (*Volume, error) {
+	if _, err := os.Lstat(path); err == nil {
+		return nil, fmt.Errorf("директория %s уже существует", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	v := &Volume{Kind: kind, Path: filepath.Clean(path)}
+	switch kind {
+	case Btrfs:
+		if _, err := runner.Output(timeout, "btrfs", "subvolume", "create", v.Path); err != nil {
+			return nil, err
+		}
+	case ZFS:
+		out, err := runner.Output(timeout, "zfs", "list", "-H", "-o", "name", filepath.Dir(v.Path))
+		if err != nil {
+			return nil, err
+		}
+		parent := strings.TrimSpace(string(out))
+		if parent == "" {
+			return nil, fmt.Errorf("не найден набор данных ZFS для %s", filepath.Dir(v.Path))
+		}
+		v.Dataset = parent + "/" + slug.Make(filepath.Base(v.Path))
+		if _, err := runner.Output(timeout, "zfs", "create", "-o", "mountpoint="+v.Path, v.Dataset); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("снимки не поддерживаются для %q", kind)
+	}
+	return v, nil
+}
+
+// snapshotsDir — директория снимков btrfs рядом с директорией игры. Внутри самого
+// подтома снимки попали бы в следующие снимки и в размер игры.
+func (v *Volume) snapshotsDir() string {
+	return filepath.Join(filepath.Dir(v.Path), "."+filepath.Base(v.Path)+".snapshots")
+}
+
+// Dir возвращает директорию, в которой доступны файлы снимка только для чтения
+func (v *Volume) Dir(s Snapshot) string {
+	if v.Kind == ZFS {
+		return filepath.Join(v.Path, ".zfs", "snapshot", s.Name)
+	}
+	return filepath.Join(v.snapshotsDir(), s.Name)
+}
+
+// Latest возвращает последний снимок. У nil снимков нет.
+func (v *Volume) Latest() (Snapshot, bool) {
+	if v == nil || len(v.Snapshots) == 0 {
+		return Snapshot{}, false
+	}
+	return v.Snapshots[len(v.Snapshots)-1], true
+}
+
+// Take делает снимок текущего состояния игры версии version
+func (v *Volume) Take(version string) error {
+	s := Snapshot{Name: time.Now().Format(nameLayout), Version: version, Date: time.Now()}
+	if last, ok := v.Latest(); ok && last.Name == s.Name {
+		s.Name += "-2"
+	}
+
+	switch v.Kind {
+	case Btrfs:
+		if err := os.MkdirAll(v.snapshotsDir(), 0755); err != nil {
+			return err
+		}
+		if _, err := runner.Output(timeout, "btrfs", "subvolume", "snapshot", "-r", v.Path, v.Dir(s)); err != nil {
+			return err
+		}
+	case ZFS:
+		if _, err := runner.Output(timeout, "zfs", "snapshot", v.Dataset+"@"+s.Name); err != nil {
+			return err
+		}
+	}
+	v.Snapshots = append(v.Snapshots, s)
+	return nil
+}
+
+// Prune удаляет старые снимки, оставляя keep последних
+func (v *Volume) Prune(keep int) {
+	for len(v.Snapshots) > max(keep, 1) {
+		if err := v.drop(v.Snapshots[0]); err != nil {
+			return
+		}
+		v.Snapshots = v.Snapshots[1:]
+	}
+}
+
+func (v *Volume) drop(s Snapshot) error {
+	if v.Kind == ZFS {
+		_, err := runner.Output(timeout, "zfs", "destroy", v.Dataset+"@"+s.Name)
+		return err
+	}
+	return deleteSubvolume(v.Dir(s))
+}
+
+// deleteSubvolume удаляет подтом btrfs. Без прав root и параметра монтирования
+// user_subvol_rm_allowed btrfs откажет, тогда подтом удаляется как обычная
+// директория: ядро позволяет владельцу удалить пустой подтом.
+func deleteSubvolume(path string) error {
+	if _, err := runner.Output(timeout, "btrfs", "subvolume", "delete", path); err == nil {
+		return nil
+	}
+	// Снимки только для чтения сначала нужно сделать доступными для записи
+	runner.Output(timeout, "btrfs", "property", "set", "-ts", path, "ro", "false")
+	return os.RemoveAll(path)
+}
+
+// Rollback возвращает игру к последнему снимку. Изменения после снимка теряются.
+func (v *Volume) Rollback() error {
+	s, ok := v.Latest()
+	if !ok {
+		return fmt.Errorf("снимков нет")
+	}
+
+	if v.Kind == ZFS {
+		_, err := runner.Output(timeout, "zfs", "rollback", v.Dataset+"@"+s.Name)
+		return err
+	}
+
+	// Подтом нельзя откатить на месте: текущий отодвигается, на его место встает
+	// доступная для записи копия снимка, после чего старый удаляется
+	aside := v.Path + ".rollback-" + time.Now().Format(nameLayout)
+	if err := os.Rename(v.Path, aside); err != nil {
+		return err
+	}
+	if _, err := runner.Output(timeout, "btrfs", "subvolume", "snapshot", v.Dir(s), v.Path); err != nil {
+		os.Rename(aside, v.Path)
+		return err
+	}
+	return deleteSubvolume(aside)
+}
+
+// Destroy удаляет снимки и сам том вместе с файлами игры
+func (v *Volume) Destroy() error {
+	if v.Kind == ZFS {
+		_, err := runner.Output(timeout, "zfs", "destroy", "-r", v.Dataset)
+		return err
+	}
+	for _, s := range v.Snapshots {
+		if err := deleteSubvolume(v.Dir(s)); err != nil {
+			return err
+		}
+	}
+	os.Remove(v.snapshotsDir())
+	return deleteSubvolume(v.Path)
+}
+
+// Change — отличие файла игры от снимка
+type Change struct {
+	Name   string
+	Reason string
+}
+
+// skipDirs — директории верхнего уровня, которые меняются при обычной работе
+var skipDirs = map[string]bool{"logs": true, "backup": true, ".zfs": true}
+
+// Verify сравнивает файлы игры с последним снимком. Файлы с тем же размером и
+// временем изменения считаются нетронутыми, поэтому проверка читает только те
+// файлы, которые действительно могли измениться.
+func (v *Volume) Verify() ([]Change, error) {
+	s, ok := v.Latest()
+	if !ok {
+		return nil, fmt.Errorf("снимков нет")
+	}
+	pristine := v.Dir(s)
+
+	var changes []Change
+	seen := make(map[string]bool)
+	err := filepath.WalkDir(pristine, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name, _ := filepath.Rel(pristine, path)
+		if name == "." {
+			return nil
+		}
+		if d.IsDir() && skipDirs[name] {
+			return filepath.SkipDir
+		}
+		seen[name] = true
+		if d.IsDir() {
+			return nil
+		}
+
+		current := filepath.Join(v.Path, name)
+		want, err := d.Info()
+		if err != nil {
+			return err
+		}
+		got, err := os.Lstat(current)
+		switch {
+		case err != nil:
+			changes = append(changes, Change{Name: name, Reason: "удален"})
+		case got.Mode() != want.Mode():
+			changes = append(changes, Change{Name: name, Reason: "изменены права"})
+		case got.Size() != want.Size():
+			changes = append(changes, Change{Name: name, Reason: "изменен"})
+		case !got.ModTime().Equal(want.ModTime()) && want.Mode().IsRegular():
+			if same, err := sameContent(path, current); err != nil || !same {
+				changes = append(changes, Change{Name: name, Reason: "изменен"})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return changes, err
+	}
+
+	err = filepath.WalkDir(v.Path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name, _ := filepath.Rel(v.Path, path)
+		if name == "." {
+			return nil
+		}
+		if d.IsDir() && skipDirs[name] {
+			return filepath.SkipDir
+		}
+		if !seen[name] {
+			changes = append(changes, Change{Name: name, Reason: "добавлен"})
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+		}
+		return nil
+	})
+	return changes, err
+}
+
+func sameContent(a, b string) (bool, error) {
+	fa, err := os.Open(a)
+	if err != nil {
+		return false, err
+	}
+	defer fa.Close()
+	fb, err := os.Open(b)
+	if err != nil {
+		return false, err
+	}
+	defer fb.Close()
+
+	bufA, bufB := make([]byte, 256*1024), make([]byte, 256*1024)
+	for {
+		na, errA := io.ReadFull(fa, bufA)
+		nb, errB := io.ReadFull(fb, bufB)
+		if na != nb || !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return false, nil
+		}
+		if errA == io.EOF || errA == io.ErrUnexpectedEOF {
+			return errB == io.EOF || errB == io.ErrUnexpectedEOF, nil
+		}
+		if errA != nil {
+			return false, errA
+		}
+		if errB != nil {
+			return false, errB
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package source
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"golang-installer/internal/codesign"
+)
+
+// BuildManifest — описание собранного установщика. По нему издатель проверяет,
+// что публикует, а игрок — что скачал. Времени сборки в нем нет: из тех же
+// входных файлов получается тот же манифест.
+type BuildManifest struct {
+	Name      string      `json:"name"`
+	Size      int64       `json:"size"`
+	SHA256    string      `json:"sha256"`
+	Version   string      `json:"version,omitempty"` // Версия игры из config.json
+	GoVersion string      `json:"go_version"`
+	Modified  time.Time   `json:"modified"`          // Время файлов во встроенном архиве
+	Installer BuildFile   `json:"installer"`         // Установщик без архива
+	Payload   BuildFile   `json:"payload"`           // Встроенный архив целиком
+	Entries   []BuildFile `json:"entries,omitempty"` // Файлы встроенного архива
+	// Signatures — подписи установщика, их проверяет --verify-build
+	Signatures []codesign.Signature `json:"signatures,omitempty"`
+}
+
+// LoadBuildManifest читает манифест сборки
+func LoadBuildManifest(path string) (*BuildManifest, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m BuildManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("ошибка в манифесте сборки %s: %v", path, err)
+	}
+	return &m, nil
+}
+
+// Save записывает манифест сборки
+func (m *BuildManifest) Save(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Sign подписывает установщик path и добавляет подпись в манифест. Встроенная
+// подпись меняет файл, поэтому его размер и хеш в манифесте обновляются, а
+// установщик с дописанным архивом так подписать нельзя: архив перестанет
+// сходиться с отметкой.
+func (m *BuildManifest) Sign(path string, signer codesign.Signer) error {
+	if signer.Embedded() {
+		if r, err := zip.OpenReader(path); err == nil {
+			r.Close()
+			return fmt.Errorf("%s меняет файл и портит встроенный архив: подпишите установщик до --embed-uninstaller", signer.Tool)
+		}
+	}
+	sig, err := signer.Sign(path)
+	if err != nil {
+		return err
+	}
+	if m.Name == "" {
+		m.Name = filepath.Base(path)
+		m.GoVersion = runtime.Version()
+	}
+	if m.Size, m.SHA256, err = hashFile(path); err != nil {
+		return err
+	}
+	// Повторная подпись тем же способом заменяет прежнюю
+	signatures := m.Signatures[:0]
+	for _, s := range m.Signatures {
+		if s.Tool != sig.Tool {
+			signatures = append(signatures, s)
+		}
+	}
+	m.Signatures = append(signatures, sig)
+	return nil
+}
+
+// BuildFile — размер и хеш одного файла сборки
+type BuildFile struct {
+	Name   string `json:"name,omitempty"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// DescribeBuild описывает установщик out, собранный EmbedPayload из installer
+func DescribeBuild(out, installer, version string, modified time.Time) (*BuildManifest, error) {
+	m := &BuildManifest{Name: filepath.Base(out), Version: version, GoVersion: runtime.Version(), Modified: modified.UTC()}
+	var err error
+	if m.Size, m.SHA256, err = hashFile(out); err != nil {
+		return nil, err
+	}
+	if m.Installer.Size, m.Installer.SHA256, err = hashFile(installer); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(out)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	payload := io.NewSectionReader(f, m.Installer.Size, m.Size-m.Installer.Size)
+	m.Payload.Size = payload.Size()
+	if m.Payload.SHA256, err = hashReader(payload); err != nil {
+		return nil, err
+	}
+
+	r, err := zip.NewReader(f, m.Size)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range r.File {
+		rc, err := entry.Open()
+		if err != nil {
+			return nil, err
+		}
+		sum, err := hashReader(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", entry.Name, err)
+		}
+		m.Entries = append(m.Entries, BuildFile{Name: entry.Name, Size: int64(entry.UncompressedSize64), SHA256: sum})
+	}
+	return m, nil
+}
+
+// VerifyBuild сверяет файл path с манифестом сборки и проверяет его подписи
+func VerifyBuild(path string, m *BuildManifest) error {
+	size, sum, err := hashFile(path)
+	if err != nil {
+		return err
+	}
+	if size != m.Size {
+		return fmt.Errorf("размер %d байт, в манифесте %d", size, m.Size)
+	}
+	if sum != m.SHA256 {
+		return fmt.Errorf("SHA-256 %s, в манифесте %s", sum, m.SHA256)
+	}
+	for _, sig := range m.Signatures {
+		if err := codesign.Verify(path, sig); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hashFile(path string) (int64, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, "", err
+	}
+	sum, err := hashReader(f)
+	return info.Size(), sum, err
+}
+
+func hashReader(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,158 @@
+package source
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang-installer/internal/chaos"
+	"golang-installer/internal/downloadcache"
+)
+
+// DownloadCache — кэш скачанных ресурсов; nil отключает кэширование
+var DownloadCache *downloadcache.Cache
+
+// Hashes — ожидаемые SHA-256 ресурсов по ссылкам из конфигурации
+var Hashes map[string]string
+
+// Fresh — ссылки, содержимое которых меняется без смены адреса, например манифест
+// последней версии игры. Они всегда скачиваются заново: по адресу кэш их не находит.
+var Fresh map[string]bool
+
+// Authorize, если задана, добавляет к запросам ресурсов данные авторизации, например
+// токен, без которого CDN издателя не отдает файлы. К запросам в локальной сети
+// токен не добавляется.
+var Authorize func(*http.Request)
+
+func init() {
+	Register(httpSource{})
+}
+
+// httpSource скачивает ресурсы по HTTP(S) во временную директорию
+type httpSource struct{}
+
+func (httpSource) Name() string { return "HTTP" }
+
+func (httpSource) Match(ref string) bool {
+	return strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://")
+}
+
+func (httpSource) Fetch(ctx context.Context, ref string, progress Progress) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ref, nil)
+	if err != nil {
+		return "", err
+	}
+	if Authorize != nil {
+		Authorize(req)
+	}
+	return download(ctx, req, ref, ref, progress)
+}
+
+// download скачивает ресурс ref запросом req через кэш загрузок. По ссылке urlKey
+// файл ищется в кэше, когда хэш неизвестен; пустая строка отключает такой поиск.
+func download(ctx context.Context, req *http.Request, ref, urlKey string, progress Progress) (string, error) {
+	expected := Hashes[ref]
+	if Fresh[ref] {
+		urlKey = ""
+	}
+	if DownloadCache != nil {
+		path, ok := "", false
+		if expected != "" {
+			path, ok = DownloadCache.Lookup(expected)
+		} else if urlKey != "" {
+			path, ok = DownloadCache.LookupURL(urlKey)
+		}
+		if ok {
+			if progress != nil {
+				if info, err := os.Stat(path); err == nil {
+					progress(info.Size(), info.Size())
+				}
+			}
+			return linkWithName(path, ref)
+		}
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("сервер вернул %s", resp.Status)
+	}
+	body := chaos.Reader(throttle(ctx, resp.Body), downloadName(ref))
+
+	if DownloadCache != nil {
+		w, err := DownloadCache.Create()
+		if err != nil {
+			return "", err
+		}
+		if err := copyWithProgress(ctx, w, body, resp.ContentLength, progress); err != nil {
+			w.Abort()
+			return "", err
+		}
+		path, err := w.Commit(urlKey, expected)
+		if err != nil {
+			return "", err
+		}
+		return linkWithName(path, ref)
+	}
+
+	dir, err := TempDir()
+	if err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp(dir, "*-"+downloadName(ref))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	if err := copyWithProgress(ctx, io.MultiWriter(f, hasher), body, resp.ContentLength, progress); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	if expected != "" && downloadcache.NormalizeHash(expected) != hex.EncodeToString(hasher.Sum(nil)) {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("контрольная сумма %s не совпадает", downloadName(ref))
+	}
+	return filepath.Clean(f.Name()), nil
+}
+
+// downloadName возвращает имя файла из ссылки: по расширению определяется формат архива
+func downloadName(ref string) string {
+	name := path.Base(strings.SplitN(strings.SplitN(ref, "?", 2)[0], "#", 2)[0])
+	if name == "/" || name == "." || strings.HasSuffix(ref, "/") {
+		name = "download"
+	}
+	return name
+}
+
+// linkWithName создает во временной директории ссылку на файл из кэша с исходным именем,
+// так как в кэше файлы называются по хэшу и теряют расширение
+func linkWithName(cached, ref string) (string, error) {
+	dir, err := TempDir()
+	if err != nil {
+		return "", err
+	}
+	link := filepath.Join(dir, urlPrefix(ref)+downloadName(ref))
+	os.Remove(link)
+	if err := os.Symlink(cached, link); err != nil {
+		return "", err
+	}
+	return link, nil
+}
+
+// urlPrefix различает одноименные файлы с разных адресов
+func urlPrefix(ref string) string {
+	sum := sha256.Sum256([]byte(ref))
+	return hex.EncodeToString(sum[:4]) + "-"
+}
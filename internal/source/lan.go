@@ -0,0 +1,33 @@
+package source
+
+import (
+	"context"
+	"fmt"
+
+	"golang-installer/internal/lanshare"
+)
+
+// LANPeer — компьютер в локальной сети, у которого берутся ресурсы вместо
+// исходных ссылок; nil, если раздача не используется
+var LANPeer *lanshare.Peer
+
+// lan скачивает ресурсы у другого установщика в локальной сети
+type lan struct{}
+
+func (lan) Name() string { return "локальная сеть (" + LANPeer.Instance + ")" }
+
+// Match не используется: Find выбирает этот источник сам, если LANPeer раздает ресурс
+func (lan) Match(ref string) bool { return false }
+
+func (lan) Fetch(ctx context.Context, ref string, progress Progress) (string, error) {
+	if Hashes[ref] == "" {
+		return "", fmt.Errorf("для %s нет контрольной суммы в конфигурации, скачивать его из локальной сети небезопасно", downloadName(ref))
+	}
+	req, err := LANPeer.Request(ctx, lanshare.AssetPath(ref))
+	if err != nil {
+		return "", err
+	}
+	// Ссылки вида "./game.zip" одинаковы у разных игр, поэтому в кэше ищем только
+	// по хэшу; он же проверяется после загрузки
+	return download(ctx, req, ref, "", progress)
+}
@@ -0,0 +1,24 @@
+package source
+
+import (
+	"context"
+	"os"
+)
+
+// local — файлы на локальном диске, пути относительно текущей директории
+type local struct{}
+
+func (local) Name() string { return "локальный диск" }
+
+func (local) Match(ref string) bool { return true }
+
+func (local) Fetch(ctx context.Context, ref string, progress Progress) (string, error) {
+	info, err := os.Stat(ref)
+	if err != nil {
+		return "", err
+	}
+	if progress != nil {
+		progress(info.Size(), info.Size())
+	}
+	return ref, nil
+}
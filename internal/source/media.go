@@ -0,0 +1,171 @@
+package source
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang-installer/internal/runner"
+)
+
+// mediaScheme — ссылки вида "media://МЕТКА/путь/к/файлу" указывают на файл
+// на сменном носителе (DVD, USB) с заданной меткой тома
+const mediaScheme = "media://"
+
+// MediaPrompt просит пользователя вставить носитель с меткой label и возвращает false,
+// если пользователь отказался. Устанавливается интерфейсом установщика; без него
+// отсутствующий носитель считается ошибкой.
+var MediaPrompt func(label string) bool
+
+func init() {
+	Register(media{})
+}
+
+type media struct{}
+
+func (media) Name() string { return "сменный носитель" }
+
+func (media) Match(ref string) bool { return strings.HasPrefix(ref, mediaScheme) }
+
+// MediaLabel возвращает метку тома, если ссылка указывает на сменный носитель
+func MediaLabel(ref string) (string, bool) {
+	label, rel, ok := strings.Cut(strings.TrimPrefix(ref, mediaScheme), "/")
+	if !strings.HasPrefix(ref, mediaScheme) || !ok || label == "" || rel == "" {
+		return "", false
+	}
+	return label, true
+}
+
+func (media) Fetch(ctx context.Context, ref string, progress Progress) (string, error) {
+	label, ok := MediaLabel(ref)
+	if !ok {
+		return "", fmt.Errorf("неверная ссылка на носитель: %s", ref)
+	}
+	rel := strings.TrimPrefix(ref, mediaScheme+label+"/")
+
+	mountTried := false
+	for {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		if root := FindMedia(label); root != "" {
+			path := filepath.Join(root, filepath.FromSlash(rel))
+			if info, err := os.Stat(path); err == nil {
+				if progress != nil {
+					progress(info.Size(), info.Size())
+				}
+				return path, nil
+			}
+			return "", fmt.Errorf("на носителе %s нет файла %s", label, rel)
+		}
+		// Вставленный, но не смонтированный носитель монтируем без вопросов
+		if !mountTried && MediaInserted(label) {
+			mountTried = true
+			if MountMedia(label) == nil {
+				continue
+			}
+		}
+		if MediaPrompt == nil || !MediaPrompt(label) {
+			return "", fmt.Errorf("носитель %s не найден", label)
+		}
+	}
+}
+
+// mediaRoots — директории, в которые udisks и fstab обычно монтируют сменные носители
+func mediaRoots() []string {
+	user := os.Getenv("USER")
+	return []string{
+		filepath.Join("/run/media", user),
+		filepath.Join("/media", user),
+		"/media",
+		"/mnt",
+	}
+}
+
+// ErrNoMedia — носитель с нужной меткой не вставлен
+var ErrNoMedia = errors.New("носитель не вставлен")
+
+// labelDevice возвращает устройство с меткой тома label по ссылкам udev в /dev/disk/by-label
+func labelDevice(label string) string {
+	// udev экранирует пробелы, "/" и другие небезопасные символы как \xNN
+	var escaped strings.Builder
+	for _, b := range []byte(label) {
+		if b == ' ' || b == '/' || b == '\\' || b == '"' || b == '\'' || b < 0x20 || b == 0x7f {
+			fmt.Fprintf(&escaped, "\\x%02x", b)
+		} else {
+			escaped.WriteByte(b)
+		}
+	}
+	dev, err := filepath.EvalSymlinks(filepath.Join("/dev/disk/by-label", escaped.String()))
+	if err != nil {
+		return ""
+	}
+	return dev
+}
+
+// mountPointOf ищет в /proc/mounts, куда смонтировано устройство dev
+func mountPointOf(dev string) string {
+	f, err := os.Open("/proc/mounts")
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		if real, err := filepath.EvalSymlinks(fields[0]); err != nil || real != dev {
+			continue
+		}
+		// Пробелы и другие символы в точке монтирования записаны как \040
+		if unquoted, err := strconv.Unquote(`"` + fields[1] + `"`); err == nil {
+			return unquoted
+		}
+		return fields[1]
+	}
+	return ""
+}
+
+// MediaInserted сообщает, вставлен ли носитель с меткой label, даже если он еще не смонтирован
+func MediaInserted(label string) bool {
+	return labelDevice(label) != ""
+}
+
+// MountMedia монтирует вставленный носитель через udisks2, как это сделал бы файловый менеджер
+func MountMedia(label string) error {
+	dev := labelDevice(label)
+	if dev == "" {
+		return ErrNoMedia
+	}
+	if mountPointOf(dev) != "" {
+		return nil
+	}
+	if err := runner.Run("udisksctl", "mount", "--no-user-interaction", "-b", dev); err != nil {
+		return fmt.Errorf("не удалось смонтировать %s: %v", dev, err)
+	}
+	return nil
+}
+
+// FindMedia ищет смонтированный носитель с меткой label и возвращает его корень
+func FindMedia(label string) string {
+	if dev := labelDevice(label); dev != "" {
+		if dir := mountPointOf(dev); dir != "" {
+			return dir
+		}
+	}
+	for _, root := range mediaRoots() {
+		dir := filepath.Join(root, label)
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+	}
+	return ""
+}
@@ -0,0 +1,228 @@
+package source
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// payloadScheme — ссылки вида "payload:имя" указывают на файл из zip-архива,
+// дописанного в конец исполняемого файла установщика (cat installer game.zip > setup)
+const payloadScheme = "payload:"
+
+func init() {
+	Register(payload{})
+}
+
+type payload struct{}
+
+func (payload) Name() string { return "встроенный архив" }
+
+func (payload) Match(ref string) bool { return strings.HasPrefix(ref, payloadScheme) }
+
+func (payload) Fetch(ctx context.Context, ref string, progress Progress) (string, error) {
+	name := strings.TrimPrefix(ref, payloadScheme)
+
+	r, err := openPayload()
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		if f.Name != name {
+			continue
+		}
+		src, err := f.Open()
+		if err != nil {
+			return "", err
+		}
+		defer src.Close()
+
+		dir, err := TempDir()
+		if err != nil {
+			return "", err
+		}
+		dst, err := os.CreateTemp(dir, "*-"+path.Base(name))
+		if err != nil {
+			return "", err
+		}
+		defer dst.Close()
+
+		if err := copyWithProgress(ctx, dst, src, int64(f.UncompressedSize64), progress); err != nil {
+			os.Remove(dst.Name())
+			return "", err
+		}
+		return dst.Name(), nil
+	}
+	return "", fmt.Errorf("во встроенном архиве нет файла %s", name)
+}
+
+// openPayload открывает архив, дописанный к установщику
+func openPayload() (*zip.ReadCloser, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	// archive/zip сам учитывает данные, дописанные перед архивом
+	r, err := zip.OpenReader(exe)
+	if err != nil {
+		return nil, fmt.Errorf("в установщик не встроен архив: %v", err)
+	}
+	return r, nil
+}
+
+// PayloadUninstaller — менеджер игр во встроенном архиве. Установщик, собранный
+// с ним, не зависит от файла uninstaller рядом с собой.
+const PayloadUninstaller = "uninstaller"
+
+// ErrNotInPayload — файла нет во встроенном архиве или архива нет вовсе
+var ErrNotInPayload = errors.New("файла нет во встроенном архиве")
+
+// ExtractPayload записывает файл name из встроенного архива в dst
+func ExtractPayload(name, dst string) error {
+	r, err := openPayload()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNotInPayload, err)
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		if f.Name != name {
+			continue
+		}
+		src, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		tmp := dst + ".tmp"
+		out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, f.Mode().Perm()|0600)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, src); err != nil {
+			out.Close()
+			os.Remove(tmp)
+			return err
+		}
+		if err := out.Close(); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+		// Запущенный менеджер заменяется, а не перезаписывается
+		return os.Rename(tmp, dst)
+	}
+	return fmt.Errorf("%w: %s", ErrNotInPayload, name)
+}
+
+// EmbedPayload записывает в out установщик installer с дописанным архивом и
+// отметкой для VerifyPayload. В архив попадают файлы files (имя в архиве →
+// путь) и записи zip-архива game, если он задан: к установщику можно дописать
+// только один архив, поэтому файлы игры и менеджер игр оказываются в одном.
+//
+// Результат воспроизводим: записи идут по именам, а время файлов заменяется
+// на modified, так что из тех же входных файлов получается тот же установщик
+// байт в байт.
+func EmbedPayload(out, installer string, files map[string]string, game string, modified time.Time) error {
+	if r, err := zip.OpenReader(installer); err == nil {
+		r.Close()
+		return fmt.Errorf("к %s уже дописан архив, нужен установщик без него", installer)
+	}
+	exe, err := os.ReadFile(installer)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(out, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(exe); err != nil {
+		return err
+	}
+
+	// Хеш архива считается по ходу записи и попадает в отметку установщика
+	h := sha256.New()
+	w := zip.NewWriter(io.MultiWriter(f, h))
+	w.SetOffset(int64(len(exe)))
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := addFile(w, name, files[name], modified); err != nil {
+			return err
+		}
+	}
+	if game != "" {
+		r, err := zip.OpenReader(game)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		entries := append([]*zip.File(nil), r.File...)
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+		for _, entry := range entries {
+			if _, ok := files[entry.Name]; ok {
+				return fmt.Errorf("в %s уже есть %s", game, entry.Name)
+			}
+			// Записи копируются без перепаковки
+			if err := w.Copy(entry); err != nil {
+				return err
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	end, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	p := payloadStamp{sum: h.Sum(nil), offset: int64(len(exe)), size: end - int64(len(exe))}
+	if err := writeStamp(f, exe, p); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func addFile(w *zip.Writer, name, path string, modified time.Time) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	header.Method = zip.Deflate
+	header.Modified = modified
+	// Права зависят от umask сборщика, важен только признак исполняемого файла
+	mode := os.FileMode(0644)
+	if info.Mode().Perm()&0111 != 0 {
+		mode = 0755
+	}
+	header.SetMode(mode)
+	dst, err := w.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	return err
+}
@@ -0,0 +1,65 @@
+package source
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// RateLimit ограничивает скорость загрузки по сети, байт в секунду; 0 — без ограничений.
+// Ограничение общее для всех одновременных загрузок.
+var RateLimit int64
+
+// limiter распределяет RateLimit между загрузками: каждое чтение сдвигает
+// момент, раньше которого следующее чтение не продолжится
+var limiter struct {
+	mu   sync.Mutex
+	next time.Time
+}
+
+// waitRate ждет, пока n байт уложатся в ограничение скорости
+func waitRate(ctx context.Context, n int) error {
+	limit := RateLimit
+	if limit <= 0 || n <= 0 {
+		return nil
+	}
+	limiter.mu.Lock()
+	now := time.Now()
+	if limiter.next.Before(now) {
+		limiter.next = now
+	}
+	delay := limiter.next.Sub(now)
+	limiter.next = limiter.next.Add(time.Duration(int64(n) * int64(time.Second) / limit))
+	limiter.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+type throttledReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (t throttledReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if werr := waitRate(t.ctx, n); werr != nil {
+		return n, werr
+	}
+	return n, err
+}
+
+// throttle ограничивает скорость чтения из r по RateLimit
+func throttle(ctx context.Context, r io.Reader) io.Reader {
+	return throttledReader{ctx: ctx, r: r}
+}
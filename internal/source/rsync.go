@@ -0,0 +1,182 @@
+package source
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang-installer/internal/downloadcache"
+	"golang-installer/internal/runner"
+)
+
+// SSHConfig — подключение к серверу внутренних сборок для ссылок ssh://
+type SSHConfig struct {
+	Key        string `json:"key"`         // Закрытый ключ, по умолчанию ssh-agent и ключи из ~/.ssh
+	Port       int    `json:"port"`        // Порт, если он не указан в ссылке
+	KnownHosts string `json:"known_hosts"` // Файл с ключами серверов вместо ~/.ssh/known_hosts
+}
+
+// SSH — подключение для ссылок ssh://
+var SSH SSHConfig
+
+func init() {
+	Register(rsyncSource{})
+}
+
+// rsyncSource получает сборки по SSH программой rsync. Копия сборки хранится
+// в кэше между установками, поэтому при следующей сборке передаются только
+// изменившиеся файлы и части файлов. Ссылка на директорию (с "/" на конце)
+// дает директорию, которая устанавливается без упаковки в архив.
+type rsyncSource struct{}
+
+func (rsyncSource) Name() string { return "SSH (rsync)" }
+
+func (rsyncSource) Match(ref string) bool {
+	return strings.HasPrefix(ref, "ssh://")
+}
+
+func (rsyncSource) Fetch(ctx context.Context, ref string, progress Progress) (string, error) {
+	u, err := url.Parse(ref)
+	if err != nil || u.Host == "" || u.Path == "" {
+		return "", fmt.Errorf("неверная ссылка %s", ref)
+	}
+	isDir := strings.HasSuffix(u.Path, "/")
+
+	mirror := filepath.Join(mirrorRoot(), urlPrefix(ref)+downloadName(ref))
+	if err := os.MkdirAll(mirror, 0755); err != nil {
+		return "", err
+	}
+	remote := u.Hostname() + ":" + u.Path
+	if u.User != nil {
+		remote = u.User.Username() + "@" + remote
+	}
+
+	args := []string{"--archive", "--delete", "--partial", "--no-inc-recursive", "--info=progress2",
+		"-e", sshCommand(u.Port()), remote, mirror + "/"}
+	cmd, err := runner.Command(ctx, "rsync", args...)
+	if err != nil {
+		return "", err
+	}
+	// ssh-agent нужен для входа по ключу без пароля
+	if sock, ok := os.LookupEnv("SSH_AUTH_SOCK"); ok {
+		cmd.Env = append(cmd.Env, "SSH_AUTH_SOCK="+sock)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+	readRsyncProgress(stdout, progress)
+	if err := cmd.Wait(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("rsync: %s", msg)
+		}
+		return "", fmt.Errorf("rsync: %v", err)
+	}
+
+	if isDir {
+		return filepath.Clean(mirror), nil
+	}
+	path := filepath.Join(mirror, filepath.Base(u.Path))
+	if expected := Hashes[ref]; expected != "" {
+		if err := checkHash(path, expected); err != nil {
+			// Поврежденная копия не должна стать основой для следующей передачи
+			os.Remove(path)
+			return "", err
+		}
+	}
+	return path, nil
+}
+
+// mirrorRoot возвращает директорию копий сборок, полученных rsync
+func mirrorRoot() string {
+	cacheHome := os.Getenv("XDG_CACHE_HOME")
+	if cacheHome == "" {
+		cacheHome = filepath.Join(os.Getenv("HOME"), ".cache")
+	}
+	return filepath.Join(cacheHome, "go-qt-installer", "rsync")
+}
+
+// sshCommand строит команду ssh для rsync. Запрос пароля отключен: установщик
+// не может ответить на него.
+func sshCommand(port string) string {
+	parts := []string{"ssh", "-o", "BatchMode=yes"}
+	if port == "" && SSH.Port != 0 {
+		port = strconv.Itoa(SSH.Port)
+	}
+	if port != "" {
+		parts = append(parts, "-p", port)
+	}
+	if SSH.Key != "" {
+		parts = append(parts, "-i", quoteRsyncArg(expandHome(SSH.Key)))
+	}
+	if SSH.KnownHosts != "" {
+		parts = append(parts, "-o", quoteRsyncArg("UserKnownHostsFile="+expandHome(SSH.KnownHosts)))
+	}
+	return strings.Join(parts, " ")
+}
+
+// quoteRsyncArg заключает аргумент команды -e в кавычки: rsync делит ее по пробелам
+func quoteRsyncArg(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		return filepath.Join(os.Getenv("HOME"), rest)
+	}
+	return path
+}
+
+// readRsyncProgress разбирает строки --info=progress2 вида
+// "  1,234,567  45%  1.23MB/s  0:00:12". rsync разделяет их символом "\r".
+func readRsyncProgress(r io.Reader, progress Progress) {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+			return i + 1, data[:i], nil
+		}
+		if atEOF && len(data) > 0 {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	})
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || !strings.HasSuffix(fields[1], "%") || progress == nil {
+			continue
+		}
+		done, err := strconv.ParseInt(strings.ReplaceAll(fields[0], ",", ""), 10, 64)
+		if err != nil {
+			continue
+		}
+		total := int64(-1)
+		if percent, err := strconv.Atoi(strings.TrimSuffix(fields[1], "%")); err == nil && percent > 0 {
+			total = done * 100 / int64(percent)
+		}
+		progress(done, total)
+	}
+}
+
+// checkHash сверяет SHA-256 файла с ожидаемым
+func checkHash(path, expected string) error {
+	sum, err := downloadcache.FileHash(path)
+	if err != nil {
+		return err
+	}
+	if downloadcache.NormalizeHash(expected) != sum {
+		return fmt.Errorf("контрольная сумма %s не совпадает", filepath.Base(path))
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+// Package source описывает, откуда установщик берет ресурсы игры: с локального диска,
+// со сменного носителя, по HTTP или из архива, дописанного к самому установщику.
+// Движок установки получает от источника обычный локальный файл и не зависит
+// от того, как он был получен.
+package source
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"golang-installer/internal/membudget"
+)
+
+// Progress сообщает, сколько байт ресурса уже получено; total равен -1, если размер неизвестен
+type Progress func(done, total int64)
+
+// Source получает ресурсы по ссылке из конфигурации
+type Source interface {
+	// Name возвращает имя источника для сообщений
+	Name() string
+	// Match сообщает, обслуживает ли источник ссылку
+	Match(ref string) bool
+	// Fetch делает ресурс доступным как локальный файл и возвращает путь к нему.
+	// Временные файлы создаются в TempDir и удаляются в Cleanup.
+	Fetch(ctx context.Context, ref string, progress Progress) (string, error)
+}
+
+var (
+	mu      sync.Mutex
+	sources []Source
+	tempDir string
+)
+
+// TempRoot — директория, в которой создаются временные файлы источников.
+// Если не задана, используется системная временная директория.
+var TempRoot string
+
+// Register добавляет источник. Источники проверяются в порядке регистрации,
+// локальный диск проверяется последним.
+func Register(s Source) {
+	mu.Lock()
+	defer mu.Unlock()
+	sources = append(sources, s)
+}
+
+// FromLAN сообщает, что ресурс берется у компьютера в локальной сети: тот его
+// раздает, а хэш ресурса известен из конфигурации. Без хэша ответу чужого
+// компьютера верить нельзя, и ресурс берется из исходного источника.
+func FromLAN(ref string) bool {
+	return LANPeer != nil && LANPeer.Has(ref) && Hashes[ref] != ""
+}
+
+// Find возвращает источник, который обслуживает ссылку. Ресурс, который берется
+// у компьютера в локальной сети, имеет приоритет над остальными источниками.
+func Find(ref string) Source {
+	if FromLAN(ref) {
+		return lan{}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, s := range sources {
+		if s.Match(ref) {
+			return s
+		}
+	}
+	return local{}
+}
+
+// Fetch получает ресурс из подходящего источника
+func Fetch(ctx context.Context, ref string, progress Progress) (string, error) {
+	s := Find(ref)
+	path, err := s.Fetch(ctx, ref, progress)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", s.Name(), err)
+	}
+	return path, nil
+}
+
+// TempDir возвращает общую временную директорию для полученных ресурсов
+func TempDir() (string, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if tempDir == "" {
+		dir, err := os.MkdirTemp(TempRoot, "go-qt-installer-source-")
+		if err != nil {
+			return "", err
+		}
+		tempDir = dir
+	}
+	return tempDir, nil
+}
+
+// Cleanup удаляет временные файлы, созданные источниками
+func Cleanup() error {
+	mu.Lock()
+	dir := tempDir
+	tempDir = ""
+	mu.Unlock()
+
+	if dir == "" {
+		return nil
+	}
+	return os.RemoveAll(dir)
+}
+
+// copyWithProgress копирует данные, сообщая о прогрессе после каждого блока
+func copyWithProgress(ctx context.Context, dst io.Writer, src io.Reader, total int64, progress Progress) error {
+	buf, release, err := membudget.Buffer(ctx, 256*1024)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	var done int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, err := src.Read(buf)
+		if n > 0 {
+			if _, werr := dst.Write(buf[:n]); werr != nil {
+				return werr
+			}
+			done += int64(n)
+			if progress != nil {
+				progress(done, total)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Remote сообщает, что ресурс получается по сети. Загрузку таких ресурсов
+// имеет смысл совмещать с распаковкой уже полученных.
+func Remote(ref string) bool {
+	switch Find(ref).(type) {
+	case httpSource, lan:
+		return true
+	}
+	return false
+}
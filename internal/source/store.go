@@ -0,0 +1,46 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang-installer/internal/objstore"
+)
+
+// storeScheme — ссылки вида store://<имя хранилища>/<ключ объекта>
+const storeScheme = "store://"
+
+// Stores — хранилища S3 и WebDAV из конфигурации по именам, используемым в ссылках
+var Stores map[string]objstore.Store
+
+func init() {
+	Register(storeSource{})
+}
+
+// storeSource скачивает ресурсы из бакета S3 или ресурса WebDAV. Загрузка идет через
+// тот же кэш, с тем же прогрессом и проверкой хэша, что и по HTTP.
+type storeSource struct{}
+
+func (storeSource) Name() string { return "хранилище S3/WebDAV" }
+
+func (storeSource) Match(ref string) bool {
+	return strings.HasPrefix(ref, storeScheme)
+}
+
+func (storeSource) Fetch(ctx context.Context, ref string, progress Progress) (string, error) {
+	name, key, _ := strings.Cut(strings.TrimPrefix(ref, storeScheme), "/")
+	store, ok := Stores[name]
+	if !ok {
+		return "", fmt.Errorf("хранилище %q не описано в конфигурации", name)
+	}
+	if key == "" {
+		return "", fmt.Errorf("в ссылке %s не указан объект", ref)
+	}
+	req, err := store.Request(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return "", err
+	}
+	return download(ctx, req, ref, ref, progress)
+}
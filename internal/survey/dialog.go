@@ -0,0 +1,61 @@
+package survey
+
+import (
+	"strings"
+
+	"github.com/therecipe/qt/widgets"
+)
+
+// Ask показывает опрос. ok ложно, если игрок его пропустил.
+func Ask(c Config, game, version string) (answer Answer, ok bool) {
+	dialog := widgets.NewQDialog(nil, 0)
+	dialog.SetWindowTitle("Игра " + game + " удалена")
+
+	question := c.Question
+	if question == "" {
+		question = DefaultQuestion
+	}
+	title := widgets.NewQLabel2(question, nil, 0)
+	title.SetWordWrap(true)
+
+	layout := widgets.NewQVBoxLayout()
+	layout.AddWidget(title, 0, 0)
+	group := widgets.NewQButtonGroup(dialog)
+	choices := make([]*widgets.QRadioButton, len(c.Choices))
+	for i, choice := range c.Choices {
+		choices[i] = widgets.NewQRadioButton2(choice, nil)
+		group.AddButton(choices[i], i)
+		layout.AddWidget(choices[i], 0, 0)
+	}
+	var comment *widgets.QLineEdit
+	if c.Comment {
+		comment = widgets.NewQLineEdit(nil)
+		comment.SetPlaceholderText("Своими словами (необязательно)")
+		comment.SetMaxLength(500)
+		layout.AddWidget(comment, 0, 0)
+	}
+	note := widgets.NewQLabel2("Ответ анонимный: издатель получит только название игры, версию, ответ и дату.", nil, 0)
+	note.SetWordWrap(true)
+	layout.AddWidget(note, 0, 0)
+
+	buttons := widgets.NewQDialogButtonBox(nil)
+	send := buttons.AddButton2("Отправить", widgets.QDialogButtonBox__AcceptRole)
+	send.SetEnabled(false)
+	buttons.AddButton2("Пропустить", widgets.QDialogButtonBox__RejectRole)
+	buttons.ConnectAccepted(dialog.Accept)
+	buttons.ConnectRejected(dialog.Reject)
+	group.ConnectButtonClicked(func(*widgets.QAbstractButton) {
+		send.SetEnabled(true)
+	})
+	layout.AddWidget(buttons, 0, 0)
+	dialog.SetLayout(layout)
+
+	if dialog.Exec() != int(widgets.QDialog__Accepted) || group.CheckedId() < 0 {
+		return Answer{}, false
+	}
+	answer = Answer{Game: game, Version: version, Choice: c.Choices[group.CheckedId()]}
+	if comment != nil {
+		answer.Comment = strings.TrimSpace(comment.Text())
+	}
+	return answer, true
+}
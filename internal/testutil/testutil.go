@@ -0,0 +1,282 @@
+// Package testutil создает архивы для тестов: с обычными файлами и с каверзным
+// содержимым — записями за пределами директории, символическими ссылками,
+// огромными файлами и именами в разных кодировках. Форматы, распаковываемые
+// внешними программами, получают поддельную программу, которая ведет себя
+// как настоящая.
+package testutil
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// File — запись создаваемого архива
+type File struct {
+	Name    string
+	Body    string
+	Size    int64       // Если больше нуля, запись заполняется нулями до этого размера вместо Body
+	Mode    os.FileMode // Права; по умолчанию 0644, для директорий 0755
+	Dir     bool
+	Symlink string // Цель ссылки; запись становится символической ссылкой
+	RawName bool   // Имя не в UTF-8: флаг UTF-8 в zip не выставляется
+}
+
+// modTime — фиксированное время записей, чтобы архивы были воспроизводимыми
+var modTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func (f File) mode() os.FileMode {
+	switch {
+	case f.Symlink != "":
+		return os.ModeSymlink | 0777
+	case f.Dir:
+		return os.ModeDir | 0755
+	case f.Mode != 0:
+		return f.Mode
+	}
+	return 0644
+}
+
+// body возвращает содержимое записи; огромные записи генерируются на лету
+func (f File) body() (io.Reader, int64) {
+	switch {
+	case f.Symlink != "":
+		return strings.NewReader(f.Symlink), int64(len(f.Symlink))
+	case f.Size > 0:
+		return io.LimitReader(zeros{}, f.Size), f.Size
+	}
+	return strings.NewReader(f.Body), int64(len(f.Body))
+}
+
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// WriteZip создает zip-архив с записями files
+func WriteZip(path string, files []File) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := zip.NewWriter(out)
+	for _, f := range files {
+		header := &zip.FileHeader{
+			Name:     f.Name,
+			Method:   zip.Deflate,
+			Modified: modTime,
+			NonUTF8:  f.RawName,
+		}
+		header.SetMode(f.mode())
+		if f.Dir {
+			header.Method = zip.Store
+			if !strings.HasSuffix(header.Name, "/") {
+				header.Name += "/"
+			}
+		}
+		fw, err := w.CreateHeader(header)
+		if err != nil {
+			out.Close()
+			return err
+		}
+		if f.Dir {
+			continue
+		}
+		r, _ := f.body()
+		if _, err := io.Copy(fw, r); err != nil {
+			out.Close()
+			return err
+		}
+	}
+	if err := w.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Game — обычная игра: исполняемый файл, ресурсы и вложенные директории
+func Game() []File {
+	return []File{
+		{Name: "bin", Dir: true},
+		{Name: "bin/Game", Body: "#!/bin/sh\necho game\n", Mode: 0755},
+		{Name: "data/levels/1.dat", Body: "level 1"},
+		{Name: "data/levels/2.dat", Body: "level 2"},
+		{Name: "options.ini", Body: "volume=10\n"},
+	}
+}
+
+// ZipSlip — записи, пытающиеся выйти за пределы директории установки
+func ZipSlip() []File {
+	return []File{
+		{Name: "../evil.sh", Body: "rm -rf ~"},
+		{Name: "/etc/evil.conf", Body: "evil"},
+		{Name: "data/../../evil.txt", Body: "evil"},
+	}
+}
+
+// Symlinks — символические ссылки внутрь директории игры и на директорию outside
+// за ее пределами, а следом запись внутри ссылки наружу. Системные файлы тесты
+// не трогают: при ошибке распаковки испорчена будет только outside.
+func Symlinks(outside string) []File {
+	return []File{
+		{Name: "lib/libgame.so.1", Body: "ELF"},
+		{Name: "lib/libgame.so", Symlink: "libgame.so.1"},
+		{Name: "lib/outside", Symlink: outside},
+		{Name: "lib/outside/evil.sh", Body: "evil"},
+	}
+}
+
+// Huge — одна запись размером size, заполненная нулями; в архиве занимает мало места
+func Huge(size int64) []File {
+	return []File{{Name: "data/huge.pak", Size: size}}
+}
+
+// WeirdNames — имена с пробелами, юникодом в разных формах и в кодировке CP866
+func WeirdNames() []File {
+	return []File{
+		{Name: "Мои документы/сохранение 1.sav", Body: "utf-8"},
+		{Name: "cafe\u0301/menu.txt", Body: "nfd"},
+		{Name: "caf\u00e9/menu.txt", Body: "nfc"},
+		{Name: "\x8f\xe0\xa8\xa2\xa5\xe2.txt", Body: "cp866", RawName: true},
+		{Name: strings.Repeat("d", 200) + "/" + strings.Repeat("f", 200) + ".txt", Body: "long"},
+	}
+}
+
+// Archive создает в dir zip-архив с именем name и возвращает путь к нему
+func Archive(dir, name string, files ...[]File) (string, error) {
+	var all []File
+	for _, f := range files {
+		all = append(all, f...)
+	}
+	path := filepath.Join(dir, name)
+	return path, WriteZip(path, all)
+}
+
+// GOGInstaller создает в dir установщик в формате GOG: скрипт makeself, за которым
+// дописан zip с файлами игры в data/noarch/game/ и описанием в data/noarch/gameinfo
+func GOGInstaller(dir, name, game, version string, files []File) (string, error) {
+	all := []File{{Name: "data/noarch/gameinfo", Body: game + "\n" + version + "\n"}}
+	for _, f := range files {
+		f.Name = "data/noarch/game/" + f.Name
+		all = append(all, f)
+	}
+	zipPath := filepath.Join(dir, name+".zip")
+	if err := WriteZip(zipPath, all); err != nil {
+		return "", err
+	}
+	defer os.Remove(zipPath)
+	data, err := os.ReadFile(zipPath)
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, name)
+	script := "#!/bin/sh\n# This script was generated using Makeself 2.4.0\nexit 0\n"
+	return path, os.WriteFile(path, append([]byte(script), data...), 0755)
+}
+
+// FakeSevenZip создает в dir архив name и поддельную программу bin/7z, которая
+// выводит список files и распаковывает их так же, как 7z. Ссылки воссоздаются
+// как есть, а запись внутри ссылки на директорию пишется туда, куда ссылка
+// ведет, как в старых версиях p7zip. Директорию bin нужно добавить в PATH.
+func FakeSevenZip(dir, name string, files []File) (string, error) {
+	var list strings.Builder
+	for _, f := range files {
+		_, size := f.body()
+		attr, folder := "A_", "-"
+		if f.Dir {
+			attr, folder, size = "D_", "+", 0
+		}
+		fmt.Fprintf(&list, "Path = %s\nSize = %d\nFolder = %s\nAttributes = %s %s\n\n", f.Name, size, folder, attr, f.lsMode())
+	}
+	script := "case \"$1\" in\n" +
+		"l)\ncat <<'LIST'\n" + list.String() + "LIST\n;;\n" +
+		"x)\nfor a in \"$@\"; do case \"$a\" in -o*) out=\"${a#-o}\";; esac; done\n" +
+		"cd \"$out\" || exit 2\n" + extractCommands(files, "- ") + ";;\n" +
+		"esac\n"
+	return fakeHelper(dir, name, "7z", script)
+}
+
+// FakeUnsquashfs создает в dir образ name и поддельную программу bin/unsquashfs,
+// которая выводит список files и распаковывает их так же, как unsquashfs.
+// Директорию bin нужно добавить в PATH.
+func FakeUnsquashfs(dir, name string, files []File) (string, error) {
+	var list strings.Builder
+	fmt.Fprintf(&list, "drwxr-xr-x 0/0 0 2024-01-01 00:00 squashfs-root\n")
+	for _, f := range files {
+		_, size := f.body()
+		path := "squashfs-root/" + f.Name
+		if f.Symlink != "" {
+			path += " -> " + f.Symlink
+		}
+		fmt.Fprintf(&list, "%s 1000/1000 %d 2024-01-01 00:00 %s\n", f.lsMode(), size, path)
+	}
+	script := "case \"$1\" in\n" +
+		"-lln)\ncat <<'LIST'\n" + list.String() + "LIST\n;;\n" +
+		"-i)\nout=\"$4\"\ncd \"$out\" || exit 2\n" + extractCommands(files, "$out/") + ";;\n" +
+		"esac\n"
+	return fakeHelper(dir, name, "unsquashfs", script)
+}
+
+// lsMode возвращает права записи в виде "-rwxr-xr-x", как их печатают ls и 7z
+func (f File) lsMode() string {
+	kind := "-"
+	switch {
+	case f.Symlink != "":
+		kind = "l"
+	case f.Dir:
+		kind = "d"
+	}
+	return kind + f.mode().Perm().String()[1:]
+}
+
+// extractCommands возвращает команды sh, которые воссоздают files в текущей
+// директории и печатают имя каждой записи после prefix. Как и настоящие
+// программы, записи за пределами директории назначения они не создают.
+func extractCommands(files []File, prefix string) string {
+	var sh strings.Builder
+	for _, f := range files {
+		if !filepath.IsLocal(f.Name) {
+			continue
+		}
+		name := shellQuote(f.Name)
+		fmt.Fprintf(&sh, "echo \"%s\"%s\n", prefix, name)
+		switch {
+		case f.Dir:
+			fmt.Fprintf(&sh, "mkdir -p %s\n", name)
+		case f.Symlink != "":
+			fmt.Fprintf(&sh, "mkdir -p \"$(dirname %s)\" && ln -s %s %s\n", name, shellQuote(f.Symlink), name)
+		case f.Size > 0:
+			fmt.Fprintf(&sh, "mkdir -p \"$(dirname %s)\" && head -c %d /dev/zero > %s\n", name, f.Size, name)
+		default:
+			fmt.Fprintf(&sh, "mkdir -p \"$(dirname %s)\" && printf '%%s' %s > %s && chmod %o %s\n",
+				name, shellQuote(f.Body), name, f.mode().Perm(), name)
+		}
+	}
+	return sh.String()
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// fakeHelper записывает программу dir/bin/helper со скриптом script и пустой
+// архив dir/name, который программа будто бы читает
+func fakeHelper(dir, name, helper, script string) (string, error) {
+	bin := filepath.Join(dir, "bin")
+	if err := os.MkdirAll(bin, 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(bin, helper), []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name)
+	return path, os.WriteFile(path, nil, 0644)
+}
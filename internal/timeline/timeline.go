@@ -0,0 +1,98 @@
+// Package timeline записывает ключевые события установки с временем: начало и
+// конец этапов, ошибки, предупреждения и решения игрока. События хранятся в
+// записи об установке, поэтому поддержка восстановит ход неудачной установки и
+// через несколько недель, когда журнал сеанса уже потерян. Add и Once можно
+// вызывать из любой горутины.
+package timeline
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Kind — вид события
+type Kind string
+
+// Виды событий
+const (
+	Start      Kind = "start"       // Начало установки или обновления
+	PhaseStart Kind = "phase_start" // Начало этапа
+	PhaseEnd   Kind = "phase_end"   // Конец этапа, в Detail длительность
+	Choice     Kind = "choice"      // Решение игрока
+	Warning    Kind = "warning"     // Предупреждение, установка продолжается
+	Error      Kind = "error"       // Ошибка, в Name ее класс
+	Finish     Kind = "finish"      // Установка завершена
+)
+
+// MaxEvents — столько последних событий хранит запись. События прежних
+// установок остаются после обновлений, пока помещаются.
+const MaxEvents = 300
+
+// Event — событие установки
+type Event struct {
+	Time   time.Time `json:"time"`
+	Kind   Kind      `json:"kind"`
+	Name   string    `json:"name"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// Timeline — события текущего запуска установщика. Нулевое значение готово к работе.
+type Timeline struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+// Add добавляет событие
+func (t *Timeline) Add(kind Kind, name, detail string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, Event{Time: time.Now(), Kind: kind, Name: name, Detail: detail})
+}
+
+// Once добавляет событие, только если такого вида и с таким именем еще не было.
+// Так сотня одинаковых предупреждений неудачной установки не вытесняет из записи
+// остальные события.
+func (t *Timeline) Once(kind Kind, name, detail string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, e := range t.events {
+		if e.Kind == kind && e.Name == name {
+			return
+		}
+	}
+	t.events = append(t.events, Event{Time: time.Now(), Kind: kind, Name: name, Detail: detail})
+}
+
+// Merge возвращает события прежних установок previous и текущего запуска по
+// времени, не больше MaxEvents последних. События этого запуска, которые уже
+// есть в previous, не повторяются: неудачная попытка сохраняет их до повтора
+// установки в том же окне.
+func (t *Timeline) Merge(previous []Event) []Event {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	events := append([]Event(nil), previous...)
+	for _, e := range t.events {
+		if !contains(previous, e) {
+			events = append(events, e)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time.Before(events[j].Time)
+	})
+	if len(events) > MaxEvents {
+		events = events[len(events)-MaxEvents:]
+	}
+	return events
+}
+
+// contains сообщает, что событие e уже есть в events. Время сравнивается через
+// Equal: после JSON у него другие часовой пояс и монотонные часы.
+func contains(events []Event, e Event) bool {
+	for _, other := range events {
+		if other.Time.Equal(e.Time) && other.Kind == e.Kind && other.Name == e.Name && other.Detail == e.Detail {
+			return true
+		}
+	}
+	return false
+}
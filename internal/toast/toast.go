@@ -0,0 +1,136 @@
+// Package toast показывает короткие уведомления поверх окна, не прерывая
+// работу: уведомление появляется в нижнем углу окна и само исчезает через
+// несколько секунд. Окна с кнопкой OK остаются для вопросов, на которые нужно
+// ответить, а о том, что просто случилось, достаточно уведомления.
+//
+// Show можно вызывать из любой горутины: уведомления показываются по таймеру.
+package toast
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/therecipe/qt/core"
+	"github.com/therecipe/qt/gui"
+	"github.com/therecipe/qt/widgets"
+)
+
+// Duration — столько уведомление остается на экране после последнего сообщения
+const Duration = 6 * time.Second
+
+// margin — отступ уведомления от краев окна
+const margin = 12
+
+// Toaster — уведомления одного окна
+type Toaster struct {
+	// OnClick вызывается по щелчку на уведомлении, например чтобы открыть
+	// подробности
+	OnClick func()
+
+	parent *widgets.QWidget
+	frame  *widgets.QFrame
+	label  *widgets.QLabel
+
+	mu      sync.Mutex
+	text    string
+	repeats int // Сколько еще сообщений пришло, пока уведомление на экране
+	until   time.Time
+	changed bool
+}
+
+// New создает уведомления поверх окна parent
+func New(parent widgets.QWidget_ITF) *Toaster {
+	t := &Toaster{parent: widgets.NewQWidgetFromPointer(parent.QWidget_PTR().Pointer())}
+
+	t.frame = widgets.NewQFrame(t.parent, 0)
+	t.frame.SetFrameShape(widgets.QFrame__StyledPanel)
+	t.frame.SetAutoFillBackground(true)
+	t.frame.SetStyleSheet("QFrame { background: palette(tooltip-base); color: palette(tooltip-text); border: 1px solid palette(mid); border-radius: 4px; }")
+	t.frame.SetCursor(gui.NewQCursor2(core.Qt__PointingHandCursor))
+	t.frame.ConnectMousePressEvent(func(*gui.QMouseEvent) {
+		t.hide()
+		if t.OnClick != nil {
+			t.OnClick()
+		}
+	})
+
+	t.label = widgets.NewQLabel2("", nil, 0)
+	t.label.SetWordWrap(true)
+	t.label.SetStyleSheet("border: none;")
+	// Щелчок по тексту — щелчок по уведомлению
+	t.label.SetAttribute(core.Qt__WA_TransparentForMouseEvents, true)
+	closeButton := widgets.NewQPushButton2("Скрыть", nil)
+	closeButton.SetFlat(true)
+	closeButton.ConnectClicked(func(bool) {
+		t.hide()
+	})
+
+	layout := widgets.NewQHBoxLayout()
+	layout.SetContentsMargins(10, 6, 6, 6)
+	layout.AddWidget(t.label, 1, 0)
+	layout.AddWidget(closeButton, 0, core.Qt__AlignTop)
+	t.frame.SetLayout(layout)
+	t.frame.Hide()
+
+	timer := core.NewQTimer(t.frame)
+	timer.ConnectTimeout(t.refresh)
+	timer.Start(200)
+	return t
+}
+
+// Show показывает сообщение. Если уведомление уже на экране, в нем остается
+// первое сообщение и счетчик остальных.
+func (t *Toaster) Show(text string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if time.Now().Before(t.until) {
+		t.repeats++
+	} else {
+		t.text, t.repeats = text, 0
+	}
+	t.until = time.Now().Add(Duration)
+	t.changed = true
+}
+
+// hide убирает уведомление до следующего сообщения
+func (t *Toaster) hide() {
+	t.mu.Lock()
+	t.until, t.changed = time.Time{}, true
+	t.mu.Unlock()
+	t.frame.Hide()
+}
+
+// refresh показывает или прячет уведомление
+func (t *Toaster) refresh() {
+	t.mu.Lock()
+	visible := time.Now().Before(t.until)
+	changed := t.changed
+	text, repeats := t.text, t.repeats
+	t.changed = false
+	t.mu.Unlock()
+
+	if !visible {
+		if t.frame.IsVisible() {
+			t.frame.Hide()
+		}
+		return
+	}
+	if !changed {
+		return
+	}
+	if repeats > 0 {
+		text += fmt.Sprintf("\nи еще сообщений: %d", repeats)
+	}
+	if t.OnClick != nil {
+		text += "\nЩелкните, чтобы открыть подробности"
+	}
+	t.label.SetText(text)
+
+	width := min(360, t.parent.Width()-2*margin)
+	t.frame.SetFixedWidth(width)
+	t.frame.AdjustSize()
+	t.frame.Move2(t.parent.Width()-width-margin, t.parent.Height()-t.frame.Height()-margin)
+	t.frame.Show()
+	t.frame.Raise()
+}
@@ -0,0 +1,167 @@
+// Package trash перемещает файлы в корзину по спецификации freedesktop.org Trash
+// и умеет возвращать их обратно.
+package trash
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Item описывает файл или директорию, перемещенные в корзину
+type Item struct {
+	Original string // Исходный путь
+	Trashed  string // Путь внутри files/ корзины
+	InfoPath string // Путь к .trashinfo
+}
+
+// homeTrash возвращает корзину пользователя в $XDG_DATA_HOME
+func homeTrash() string {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(os.Getenv("HOME"), ".local", "share")
+	}
+	return filepath.Join(dataHome, "Trash")
+}
+
+// device возвращает номер устройства, на котором находится путь
+func device(path string) (uint64, error) {
+	var st syscall.Stat_t
+	if err := syscall.Lstat(path, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Dev), nil
+}
+
+// mountRoot ищет корень файловой системы, на которой находится путь
+func mountRoot(path string) (string, error) {
+	dev, err := device(path)
+	if err != nil {
+		return "", err
+	}
+	dir := path
+	for {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir, nil
+		}
+		parentDev, err := device(parent)
+		if err != nil || parentDev != dev {
+			return dir, nil
+		}
+		dir = parent
+	}
+}
+
+// trashDirFor выбирает корзину на той же файловой системе, что и путь. Для
+// корзины в корне файловой системы возвращается и сам корень: пути в ней
+// записываются относительно него.
+func trashDirFor(path string) (trashDir, topDir string, err error) {
+	home := homeTrash()
+	if err := os.MkdirAll(home, 0700); err != nil {
+		return "", "", err
+	}
+	pathDev, err := device(path)
+	if err != nil {
+		return "", "", err
+	}
+	if homeDev, err := device(home); err == nil && homeDev == pathDev {
+		return home, "", nil
+	}
+
+	root, err := mountRoot(path)
+	if err != nil {
+		return "", "", err
+	}
+	return filepath.Join(root, ".Trash-"+strconv.Itoa(os.Getuid())), root, nil
+}
+
+// pathValue возвращает значение Path= для .trashinfo: в корзине пользователя это
+// абсолютный путь, в корзине $topdir/.Trash-$uid — путь относительно $topdir,
+// чтобы файл восстанавливался и после подключения диска в другое место
+func pathValue(path, topDir string) string {
+	if topDir != "" {
+		if rel, err := filepath.Rel(topDir, path); err == nil && filepath.IsLocal(rel) {
+			path = rel
+		}
+	}
+	return (&url.URL{Path: path}).EscapedPath()
+}
+
+// Move перемещает файл или директорию в корзину
+func Move(path string) (*Item, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	trashDir, topDir, err := trashDirFor(path)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось определить корзину для %s: %v", path, err)
+	}
+	filesDir := filepath.Join(trashDir, "files")
+	infoDir := filepath.Join(trashDir, "info")
+	if err := os.MkdirAll(filesDir, 0700); err != nil {
+		return nil, fmt.Errorf("не удалось создать корзину: %v", err)
+	}
+	if err := os.MkdirAll(infoDir, 0700); err != nil {
+		return nil, fmt.Errorf("не удалось создать корзину: %v", err)
+	}
+
+	info := "[Trash Info]\n" +
+		"Path=" + pathValue(path, topDir) + "\n" +
+		"DeletionDate=" + time.Now().Format("2006-01-02T15:04:05") + "\n"
+
+	// По спецификации сначала атомарно создается .trashinfo, который резервирует имя
+	base := filepath.Base(path)
+	for i := 1; ; i++ {
+		name := base
+		if i > 1 {
+			name = base + "." + strconv.Itoa(i)
+		}
+		infoPath := filepath.Join(infoDir, name+".trashinfo")
+		f, err := os.OpenFile(infoPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("не удалось создать %s: %v", infoPath, err)
+		}
+		_, err = f.WriteString(info)
+		f.Close()
+		if err != nil {
+			os.Remove(infoPath)
+			return nil, fmt.Errorf("не удалось записать %s: %v", infoPath, err)
+		}
+
+		trashed := filepath.Join(filesDir, name)
+		if _, err := os.Lstat(trashed); err == nil {
+			os.Remove(infoPath)
+			continue
+		}
+		if err := os.Rename(path, trashed); err != nil {
+			os.Remove(infoPath)
+			return nil, fmt.Errorf("не удалось переместить %s в корзину: %v", path, err)
+		}
+		return &Item{Original: path, Trashed: trashed, InfoPath: infoPath}, nil
+	}
+}
+
+// Restore возвращает файл из корзины на исходное место
+func Restore(item *Item) error {
+	if _, err := os.Lstat(item.Original); err == nil {
+		return fmt.Errorf("не удалось восстановить %s: путь уже существует", item.Original)
+	}
+	if err := os.MkdirAll(filepath.Dir(item.Original), 0755); err != nil {
+		return fmt.Errorf("не удалось восстановить %s: %v", item.Original, err)
+	}
+	if err := os.Rename(item.Trashed, item.Original); err != nil {
+		return fmt.Errorf("не удалось восстановить %s: %v", item.Original, err)
+	}
+	os.Remove(item.InfoPath)
+	return nil
+}
@@ -0,0 +1,55 @@
+package trash
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPathValue(t *testing.T) {
+	for _, tt := range []struct {
+		path, topDir string
+		want         string
+	}{
+		// Корзина пользователя: абсолютный путь
+		{"/home/user/Games/My Game", "", "/home/user/Games/My%20Game"},
+		// $topdir/.Trash-$uid: путь относительно $topdir
+		{"/media/disk/Games/My Game", "/media/disk", "Games/My%20Game"},
+		{"/media/disk/game:1", "/media/disk", "game:1"},
+		{"/media/disk/Игры/игра", "/media/disk", "%D0%98%D0%B3%D1%80%D1%8B/%D0%B8%D0%B3%D1%80%D0%B0"},
+		{"/data/game", "/", "data/game"},
+	} {
+		if got := pathValue(tt.path, tt.topDir); got != tt.want {
+			t.Errorf("pathValue(%q, %q) = %q, ожидалось %q", tt.path, tt.topDir, got, tt.want)
+		}
+	}
+}
+
+func TestMoveHomeTrash(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", filepath.Join(dir, "data"))
+	path := filepath.Join(dir, "My Game")
+	if err := os.Mkdir(path, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := Move(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(item.InfoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Path=" + strings.ReplaceAll(path, " ", "%20") + "\n"; !strings.Contains(string(data), want) {
+		t.Errorf(".trashinfo:\n%s\nнет строки %q", data, want)
+	}
+	if err := Restore(item); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("не восстановлено: %v", err)
+	}
+}
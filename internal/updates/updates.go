@@ -0,0 +1,141 @@
+// Package updates ищет обновления установленных игр в фоне. Для игр, установленных
+// по манифесту файлов, служба менеджера (--daemon) периодически скачивает манифест,
+// заранее получает в кэш загрузок недостающие файлы новой версии и записывает
+// готовое обновление. Применяет его установщик, запущенный из менеджера: файлы
+// берутся из кэша, поэтому обновление занимает секунды.
+package updates
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang-installer/internal/manifest"
+	"golang-installer/internal/runner"
+	"golang-installer/internal/source"
+)
+
+// DefaultInterval — как часто служба проверяет обновления
+const DefaultInterval = 6 * time.Hour
+
+// Pending — обновление, скачанное в кэш и ожидающее установки
+type Pending struct {
+	Game      string    `json:"game"`
+	Version   string    `json:"version"`
+	Manifest  string    `json:"manifest"` // Ссылка на манифест новой версии
+	Files     int       `json:"files"`    // Сколько файлов изменится
+	Bytes     int64     `json:"bytes"`    // Сколько байт скачано заранее
+	Ready     bool      `json:"ready"`    // Все файлы уже в кэше
+	Notified  bool      `json:"notified"` // Пользователю уже сообщили
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Dir возвращает директорию найденных обновлений
+func Dir() string {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(os.Getenv("HOME"), ".local", "share")
+	}
+	return filepath.Join(dataHome, "go-qt-installer", "updates")
+}
+
+func path(slug string) string {
+	return filepath.Join(Dir(), slug+".json")
+}
+
+// Load возвращает найденное обновление игры slug или nil
+func Load(slug string) *Pending {
+	data, err := ioutil.ReadFile(path(slug))
+	if err != nil {
+		return nil
+	}
+	var p Pending
+	if err := json.Unmarshal(data, &p); err != nil {
+		log.Printf("Ошибка при чтении %s: %v", path(slug), err)
+		return nil
+	}
+	return &p
+}
+
+// Save записывает найденное обновление
+func (p *Pending) Save(slug string) error {
+	if err := os.MkdirAll(Dir(), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path(slug), data, 0644)
+}
+
+// Clear забывает обновление игры: оно установлено или больше не актуально
+func Clear(slug string) {
+	if err := os.Remove(path(slug)); err != nil && !os.IsNotExist(err) {
+		log.Printf("Ошибка при удалении %s: %v", path(slug), err)
+	}
+}
+
+// Check скачивает манифест по ссылке ref и, если в нем версия новее installed,
+// заранее получает файлы обновления игры из root. Возвращает nil, когда
+// обновления нет.
+func Check(ctx context.Context, game, ref, root, installed string) (*Pending, error) {
+	if source.Fresh == nil {
+		source.Fresh = make(map[string]bool)
+	}
+	source.Fresh[ref] = true
+	file, err := source.Fetch(ctx, ref, nil)
+	if err != nil {
+		return nil, err
+	}
+	m, err := manifest.Load(file)
+	if err != nil {
+		return nil, err
+	}
+	if m.Version == "" || m.Version == installed {
+		return nil, nil
+	}
+
+	// Файлы пользователя из конфигурации игры здесь неизвестны, поэтому
+	// измененные пользователем файлы тоже попадают в кэш. Установщик их не заменит.
+	plan, err := manifest.Compare(m, root, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	if source.Hashes == nil {
+		source.Hashes = make(map[string]string)
+	}
+	for ref, hash := range m.Hashes(plan.Fetch) {
+		source.Hashes[ref] = hash
+	}
+	log.Printf("%s: найдена версия %s, изменилось файлов: %d", game, m.Version, len(plan.Fetch))
+
+	pending := &Pending{Game: game, Version: m.Version, Manifest: ref, Files: len(plan.Fetch), CheckedAt: time.Now()}
+	tempDir, _ := source.TempDir()
+	opts := manifest.Options{
+		Fetch: func(ctx context.Context, ref string, progress func(done, total int64)) (string, error) {
+			return source.Fetch(ctx, ref, progress)
+		},
+		// В кэше остается сам файл, ссылка на него во временной директории не нужна
+		Release: func(path string) {
+			if tempDir != "" && filepath.Dir(path) == tempDir {
+				os.Remove(path)
+			}
+		},
+	}
+	err = manifest.Prefetch(ctx, m, root, plan, opts)
+	pending.Bytes = plan.Downloaded
+	pending.Ready = err == nil
+	return pending, err
+}
+
+// Notify сообщает пользователю, что обновление готово к установке
+func Notify(p *Pending) error {
+	return runner.Run("notify-send", "--app-name=Менеджер игр", "--icon=system-software-update",
+		"Обновление "+p.Game,
+		"Версия "+p.Version+" скачана. Установите ее в менеджере игр.")
+}
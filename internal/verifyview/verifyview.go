@@ -0,0 +1,267 @@
+// Package verifyview показывает проверку файлов игры по хэшам как в
+// торрент-клиентах: карту участков игры, где видно проверенные и поврежденные
+// места, и скорость проверки в частях в секунду. Проверка идет в горутине,
+// а окно обновляется по таймеру, поэтому Start, Add и Finish можно вызывать из
+// любой горутины.
+package verifyview
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/therecipe/qt/core"
+	"github.com/therecipe/qt/gui"
+	"github.com/therecipe/qt/widgets"
+
+	"golang-installer/internal/manifest"
+)
+
+// cells — столько клеток в карте участков, каждая — доля игры по объему
+const cells = 240
+
+// rateWindow — за такой промежуток считается скорость проверки
+const rateWindow = 3 * time.Second
+
+// Цвета клеток карты. QColor создается при рисовании: до QApplication Qt
+// объекты создавать нельзя.
+var (
+	colorPending = [3]int{200, 200, 200}
+	colorPartial = [3]int{150, 210, 150}
+	colorOK      = [3]int{60, 170, 80}
+	colorFailed  = [3]int{210, 50, 50}
+)
+
+// View — страница проверки
+type View struct {
+	Widget *widgets.QWidget
+
+	// HideOnFinish прячет страницу после проверки и снова показывает виджет,
+	// который она заменяла. Иначе итог остается на экране.
+	HideOnFinish bool
+
+	replaced *widgets.QWidget
+	shown    bool
+	title    *widgets.QLabel
+	area     *widgets.QWidget
+	stats    *widgets.QLabel
+
+	mu      sync.Mutex
+	active  bool
+	changed bool
+	caption string
+	total   int64
+	covered [cells]int64
+	failed  [cells]bool
+	pieces  int
+	hashed  int
+	bytes   int64
+	bad     map[string]bool
+	started time.Time
+	ended   time.Time
+	samples []sample
+}
+
+// sample — число прочитанных частей в момент времени, для скорости
+type sample struct {
+	at     time.Time
+	hashed int
+	bytes  int64
+}
+
+// New создает страницу проверки. На время проверки она прячет replaced —
+// обычно общий индикатор прогресса.
+func New(replaced widgets.QWidget_ITF) *View {
+	v := &View{Widget: widgets.NewQWidget(nil, 0), bad: make(map[string]bool)}
+	if replaced != nil {
+		v.replaced = widgets.NewQWidgetFromPointer(replaced.QWidget_PTR().Pointer())
+	}
+
+	v.title = widgets.NewQLabel2("", nil, 0)
+	v.area = widgets.NewQWidget(nil, 0)
+	v.area.SetMinimumHeight(22)
+	v.area.SetToolTip("Карта игры по объему: зеленые участки проверены, красные повреждены или отсутствуют")
+	v.area.ConnectPaintEvent(func(*gui.QPaintEvent) {
+		v.paint()
+	})
+	v.stats = widgets.NewQLabel2("", nil, 0)
+	v.stats.SetWordWrap(true)
+
+	layout := widgets.NewQVBoxLayout()
+	layout.SetContentsMargins(0, 0, 0, 0)
+	layout.AddWidget(v.title, 0, 0)
+	layout.AddWidget(v.area, 0, 0)
+	layout.AddWidget(v.stats, 0, 0)
+	v.Widget.SetLayout(layout)
+	v.Widget.Hide()
+
+	timer := core.NewQTimer(v.Widget)
+	timer.ConnectTimeout(v.refresh)
+	timer.Start(200)
+	return v
+}
+
+// Start начинает новую проверку total байт
+func (v *View) Start(caption string, total int64) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.active, v.changed = true, true
+	v.caption, v.total = caption, total
+	v.covered, v.failed = [cells]int64{}, [cells]bool{}
+	v.pieces, v.hashed, v.bytes = 0, 0, 0
+	v.bad = make(map[string]bool)
+	v.started, v.ended = time.Now(), time.Time{}
+	v.samples = nil
+}
+
+// Add отмечает проверенный участок
+func (v *View) Add(p manifest.Piece) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.changed = true
+	v.pieces++
+	if p.Hashed {
+		v.hashed++
+		v.bytes += p.Size
+	}
+	if !p.OK {
+		v.bad[p.Path] = true
+	}
+	if v.total <= 0 {
+		return
+	}
+	// Участок раскладывается по клеткам, которые он задевает
+	for offset, end := p.Offset, p.Offset+p.Size; offset < end; {
+		cell := int(offset * cells / v.total)
+		if cell >= cells {
+			break
+		}
+		cellEnd := (int64(cell) + 1) * v.total / cells
+		n := min(end, cellEnd) - offset
+		if n <= 0 {
+			break
+		}
+		v.covered[cell] += n
+		if !p.OK {
+			v.failed[cell] = true
+		}
+		offset += n
+	}
+}
+
+// Finish завершает проверку
+func (v *View) Finish() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.active, v.changed = false, true
+	v.ended = time.Now()
+}
+
+// Failed возвращает файлы с поврежденными или отсутствующими участками
+func (v *View) Failed() []string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.failedFiles()
+}
+
+// failedFiles — поврежденные файлы по алфавиту. Вызывается под v.mu.
+func (v *View) failedFiles() []string {
+	files := make([]string, 0, len(v.bad))
+	for path := range v.bad {
+		files = append(files, path)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// refresh переносит накопленное состояние на экран
+func (v *View) refresh() {
+	v.mu.Lock()
+	if !v.changed && !v.active {
+		v.mu.Unlock()
+		return
+	}
+	v.changed = false
+	now := time.Now()
+	v.samples = append(v.samples, sample{at: now, hashed: v.hashed, bytes: v.bytes})
+	for len(v.samples) > 2 && now.Sub(v.samples[0].at) > rateWindow {
+		v.samples = v.samples[1:]
+	}
+	// После проверки итог остается на экране, если его не просили спрятать
+	showing := v.active || !v.HideOnFinish && !v.started.IsZero()
+	caption := v.caption
+	text := v.statsText(now)
+	failed := v.failedFiles()
+	v.mu.Unlock()
+
+	// Заменяемый виджет переключается только при смене состояния: до первой
+	// проверки он может быть скрыт по своим причинам
+	if showing != v.shown {
+		v.shown = showing
+		v.Widget.SetVisible(showing)
+		if v.replaced != nil {
+			v.replaced.SetVisible(!showing)
+		}
+	}
+	v.title.SetText(caption)
+	v.stats.SetText(text)
+	if len(failed) > 20 {
+		failed = append(failed[:20], fmt.Sprintf("и еще %d", len(failed)-20))
+	}
+	v.stats.SetToolTip(strings.Join(failed, "\n"))
+	v.area.Update()
+}
+
+// statsText — счетчики и скорость. Вызывается под v.mu.
+func (v *View) statsText(now time.Time) string {
+	var done int64
+	for _, n := range v.covered {
+		done += n
+	}
+	percent := 100
+	if v.total > 0 {
+		percent = int(done * 100 / v.total)
+	}
+	text := fmt.Sprintf("%d%%, проверено участков: %d, прочитано %.1f МБ", percent, v.pieces, float64(v.bytes)/1024/1024)
+	if v.active && len(v.samples) > 1 {
+		first, last := v.samples[0], v.samples[len(v.samples)-1]
+		if dt := last.at.Sub(first.at).Seconds(); dt > 0 {
+			text += fmt.Sprintf(", %.1f частей/с (%.1f МБ/с)", float64(last.hashed-first.hashed)/dt,
+				float64(last.bytes-first.bytes)/1024/1024/dt)
+		}
+	}
+	if !v.active && !v.ended.IsZero() {
+		text += fmt.Sprintf(" за %s", v.ended.Sub(v.started).Round(time.Second))
+	}
+	if len(v.bad) > 0 {
+		text += fmt.Sprintf(". Повреждены или отсутствуют файлов: %d", len(v.bad))
+	}
+	return text
+}
+
+// paint рисует карту участков
+func (v *View) paint() {
+	v.mu.Lock()
+	covered, failed, total := v.covered, v.failed, v.total
+	v.mu.Unlock()
+
+	painter := gui.NewQPainter2(v.area)
+	defer painter.End()
+	width, height := v.area.Width(), v.area.Height()
+	for i := 0; i < cells; i++ {
+		x0, x1 := i*width/cells, (i+1)*width/cells
+		color := colorPending
+		size := (int64(i)+1)*total/cells - int64(i)*total/cells
+		switch {
+		case failed[i]:
+			color = colorFailed
+		case covered[i] > 0 && covered[i] >= size:
+			color = colorOK
+		case covered[i] > 0:
+			color = colorPartial
+		}
+		painter.FillRect5(x0, 0, max(1, x1-x0), height, gui.NewQColor3(color[0], color[1], color[2], 255))
+	}
+}
@@ -0,0 +1,227 @@
+// Package warnings собирает предупреждения установки в постоянную панель вместо
+// окон, которые при неудачной установке всплывали десятки раз подряд.
+// Одинаковые предупреждения складываются в одну строку со счетчиком, временем
+// первого и последнего раза и списком затронутых файлов. Над панелью всегда
+// виден счетчик, пока предупреждения не очищены.
+//
+// Add можно вызывать из любой горутины: панель обновляется по таймеру.
+package warnings
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/therecipe/qt/core"
+	"github.com/therecipe/qt/widgets"
+)
+
+// maxFiles — столько затронутых файлов хранится у одного предупреждения
+const maxFiles = 500
+
+// Entry — одно предупреждение
+type Entry struct {
+	Message string // Что случилось; по нему предупреждения складываются
+	File    string // Затронутый файл или архив, если есть
+	Detail  string // Текст ошибки
+}
+
+// group — одинаковые предупреждения
+type group struct {
+	message     string
+	count       int
+	first, last time.Time
+	detail      string // Текст последней ошибки без файла
+	files       []file
+	seen        map[string]bool
+	dropped     int // Файлы сверх maxFiles
+}
+
+type file struct {
+	name   string
+	detail string
+	at     time.Time
+}
+
+// Panel — панель предупреждений
+type Panel struct {
+	Widget *widgets.QWidget
+
+	header *widgets.QPushButton
+	tree   *widgets.QTreeWidget
+
+	mu      sync.Mutex
+	groups  []*group
+	total   int
+	changed bool
+}
+
+// New создает панель. Пока предупреждений нет, она скрыта.
+func New() *Panel {
+	p := &Panel{Widget: widgets.NewQWidget(nil, 0)}
+
+	p.header = widgets.NewQPushButton2("", nil)
+	p.header.SetCheckable(true)
+	p.header.SetFlat(true)
+	p.header.SetToolTip("Показать или скрыть предупреждения установки")
+	p.tree = widgets.NewQTreeWidget(nil)
+	p.tree.SetHeaderLabels([]string{"Предупреждение", "Раз", "Время"})
+	p.tree.SetEditTriggers(widgets.QAbstractItemView__NoEditTriggers)
+	p.tree.SetMinimumHeight(120)
+	p.tree.Header().SetStretchLastSection(false)
+	p.tree.Header().SetSectionResizeMode2(0, widgets.QHeaderView__Stretch)
+	p.tree.Header().SetSectionResizeMode2(1, widgets.QHeaderView__ResizeToContents)
+	p.tree.Header().SetSectionResizeMode2(2, widgets.QHeaderView__ResizeToContents)
+	p.tree.Hide()
+	p.header.ConnectToggled(p.tree.SetVisible)
+
+	clearButton := widgets.NewQPushButton2("Очистить", nil)
+	clearButton.ConnectClicked(func(bool) {
+		p.Clear()
+	})
+
+	headerLayout := widgets.NewQHBoxLayout()
+	headerLayout.SetContentsMargins(0, 0, 0, 0)
+	headerLayout.AddWidget(p.header, 1, 0)
+	headerLayout.AddWidget(clearButton, 0, 0)
+	layout := widgets.NewQVBoxLayout()
+	layout.SetContentsMargins(0, 0, 0, 0)
+	layout.AddLayout(headerLayout, 0)
+	layout.AddWidget(p.tree, 0, 0)
+	p.Widget.SetLayout(layout)
+	p.Widget.Hide()
+
+	timer := core.NewQTimer(p.Widget)
+	timer.ConnectTimeout(p.refresh)
+	timer.Start(250)
+	return p
+}
+
+// Add добавляет предупреждение
+func (p *Panel) Add(e Entry) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	var g *group
+	for _, existing := range p.groups {
+		if existing.message == e.Message {
+			g = existing
+			break
+		}
+	}
+	if g == nil {
+		g = &group{message: e.Message, first: now, seen: make(map[string]bool)}
+		p.groups = append(p.groups, g)
+	}
+	g.count++
+	g.last = now
+	g.detail = e.Detail
+	if e.File != "" && !g.seen[e.File] {
+		if len(g.files) < maxFiles {
+			g.seen[e.File] = true
+			g.files = append(g.files, file{name: e.File, detail: e.Detail, at: now})
+		} else {
+			g.dropped++
+		}
+	}
+	p.total++
+	p.changed = true
+}
+
+// Expand раскрывает список предупреждений. Вызывается из потока интерфейса.
+func (p *Panel) Expand() {
+	p.header.SetChecked(true)
+}
+
+// Count возвращает число предупреждений с последней очистки
+func (p *Panel) Count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.total
+}
+
+// Clear убирает все предупреждения
+func (p *Panel) Clear() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.groups, p.total, p.changed = nil, 0, true
+}
+
+// Summary — предупреждения одной строкой на каждое, для отчета в поддержку
+func (p *Panel) Summary() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var lines []string
+	for _, g := range p.groups {
+		line := fmt.Sprintf("%s ×%d (%s–%s)", g.message, g.count, g.first.Format("15:04:05"), g.last.Format("15:04:05"))
+		if len(g.files) > 0 {
+			names := make([]string, 0, len(g.files))
+			for _, f := range g.files {
+				names = append(names, f.name)
+			}
+			line += ": " + strings.Join(names, ", ")
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// refresh переносит накопленные предупреждения на экран
+func (p *Panel) refresh() {
+	p.mu.Lock()
+	if !p.changed {
+		p.mu.Unlock()
+		return
+	}
+	p.changed = false
+	total := p.total
+	var last time.Time
+	type row struct {
+		message, count, when, detail string
+		files                        []file
+		dropped                      int
+	}
+	rows := make([]row, 0, len(p.groups))
+	for _, g := range p.groups {
+		when := g.last.Format("15:04:05")
+		if g.count > 1 {
+			when = g.first.Format("15:04:05") + "–" + when
+		}
+		rows = append(rows, row{g.message, fmt.Sprint(g.count), when, g.detail, append([]file(nil), g.files...), g.dropped})
+		if g.last.After(last) {
+			last = g.last
+		}
+	}
+	p.mu.Unlock()
+
+	if total == 0 {
+		p.tree.Clear()
+		p.header.SetChecked(false)
+		p.Widget.Hide()
+		return
+	}
+	p.header.SetText(fmt.Sprintf("Предупреждения: %d, последнее в %s", total, last.Format("15:04:05")))
+
+	// Раскрытые строки остаются раскрытыми после обновления
+	expanded := make(map[string]bool)
+	for i := 0; i < p.tree.TopLevelItemCount(); i++ {
+		if item := p.tree.TopLevelItem(i); item.IsExpanded() {
+			expanded[item.Text(0)] = true
+		}
+	}
+	p.tree.Clear()
+	for _, r := range rows {
+		item := widgets.NewQTreeWidgetItem4(p.tree, []string{r.message, r.count, r.when}, 0)
+		item.SetToolTip(0, r.detail)
+		for _, f := range r.files {
+			child := widgets.NewQTreeWidgetItem7(item, []string{f.name, "", f.at.Format("15:04:05")}, 0)
+			child.SetToolTip(0, f.detail)
+		}
+		if r.dropped > 0 {
+			widgets.NewQTreeWidgetItem7(item, []string{fmt.Sprintf("и еще файлов: %d", r.dropped)}, 0)
+		}
+		item.SetExpanded(expanded[r.message])
+	}
+	p.Widget.Show()
+}
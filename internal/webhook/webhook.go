@@ -0,0 +1,84 @@
+// Package webhook сообщает об окончании установки, обновления и удаления игры
+// POST-запросом с JSON на адреса из конфигурации: так издатель узнает о проблемах
+// установки раньше, чем игрок напишет в поддержку, а домашняя панель — об изменениях.
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Timeout — сколько ждать ответа каждого адреса
+const Timeout = 5 * time.Second
+
+const (
+	ActionInstall   = "install"
+	ActionUpdate    = "update"
+	ActionUninstall = "uninstall"
+)
+
+// Event — тело запроса
+type Event struct {
+	Action          string    `json:"event"` // install, update или uninstall
+	Game            string    `json:"game"`
+	Slug            string    `json:"slug,omitempty"`
+	InstallID       string    `json:"install_id,omitempty"`
+	Version         string    `json:"version,omitempty"`
+	PreviousVersion string    `json:"previous_version,omitempty"` // Версия до обновления
+	Success         bool      `json:"success"`
+	Error           string    `json:"error,omitempty"`
+	ErrorCode       string    `json:"error_code,omitempty"` // Класс ошибки: config, space, download, extraction, permissions, cancelled или other
+	Duration        float64   `json:"duration_seconds"`
+	FinishedAt      time.Time `json:"finished_at"`
+}
+
+// Send отправляет событие на все адреса одновременно и ждет ответов не дольше Timeout.
+// Ошибки только пишутся в журнал: недоступный адрес не должен мешать установке.
+func Send(urls []string, e Event) {
+	if len(urls) == 0 {
+		return
+	}
+	if e.FinishedAt.IsZero() {
+		e.FinishedAt = time.Now()
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("Ошибка при сериализации события: %v", err)
+		return
+	}
+
+	client := &http.Client{Timeout: Timeout}
+	var wg sync.WaitGroup
+	for _, url := range urls {
+		if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+			log.Printf("Адрес уведомления %s пропущен: нужен http или https", url)
+			continue
+		}
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+			if err != nil {
+				log.Printf("Ошибка уведомления %s: %v", url, err)
+				return
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("User-Agent", "go-qt-installer")
+			resp, err := client.Do(req)
+			if err != nil {
+				log.Printf("Ошибка уведомления %s: %v", url, err)
+				return
+			}
+			resp.Body.Close()
+			if resp.StatusCode >= 300 {
+				log.Printf("Уведомление %s отклонено: %s", url, resp.Status)
+			}
+		}(url)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,246 @@
+// Package wmclass определяет класс окна запущенной игры, чтобы прописать его
+// в StartupWMClass ярлыка: без этого окружения рабочего стола не связывают окно
+// с ярлыком и показывают в панели чужую иконку.
+package wmclass
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang-installer/internal/runner"
+)
+
+// commandTimeout ограничивает один опрос списка окон
+const commandTimeout = 5 * time.Second
+
+// ErrUnsupported — окружение не позволяет узнать класс окна
+var ErrUnsupported = errors.New("определение класса окна не поддерживается в этом окружении")
+
+// window — окно верхнего уровня и процесс, которому оно принадлежит
+type window struct {
+	pid   int
+	class string
+}
+
+// Detect ждет, пока у процесса pid или его потомков появится окно, и возвращает
+// его класс (WM_CLASS в X11, app_id в Wayland). Игры часто запускаются через
+// скрипт, поэтому учитываются все потомки.
+func Detect(ctx context.Context, pid int) (string, error) {
+	list := listerFor()
+	if list == nil {
+		return "", ErrUnsupported
+	}
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		pids := descendants(pid)
+		windows, err := list()
+		if err != nil {
+			return "", err
+		}
+		for _, w := range windows {
+			if pids[w.pid] && w.class != "" {
+				return w.class, nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// listerFor выбирает способ получить список окон для текущей сессии
+func listerFor() func() ([]window, error) {
+	if os.Getenv("SWAYSOCK") != "" {
+		if _, err := exec.LookPath("swaymsg"); err == nil {
+			return swayWindows
+		}
+	}
+	if os.Getenv("HYPRLAND_INSTANCE_SIGNATURE") != "" {
+		if _, err := exec.LookPath("hyprctl"); err == nil {
+			return hyprlandWindows
+		}
+	}
+	// Остальные Wayland-композиторы не раскрывают app_id чужих окон, но игры
+	// обычно работают через XWayland, и xprop их видит
+	if os.Getenv("DISPLAY") != "" {
+		if _, err := exec.LookPath("xprop"); err == nil {
+			return x11Windows
+		}
+	}
+	return nil
+}
+
+// descendants возвращает pid и всех его потомков по данным /proc
+func descendants(root int) map[int]bool {
+	children := make(map[int][]int)
+	entries, _ := os.ReadDir("/proc")
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join("/proc", e.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		// Имя процесса в скобках может содержать пробелы, поля идут после последней ")"
+		fields := strings.Fields(string(data[strings.LastIndexByte(string(data), ')')+1:]))
+		if len(fields) < 2 {
+			continue
+		}
+		ppid, _ := strconv.Atoi(fields[1])
+		children[ppid] = append(children[ppid], pid)
+	}
+
+	result := map[int]bool{root: true}
+	queue := []int{root}
+	for len(queue) > 0 {
+		pid := queue[0]
+		queue = queue[1:]
+		for _, child := range children[pid] {
+			if !result[child] {
+				result[child] = true
+				queue = append(queue, child)
+			}
+		}
+	}
+	return result
+}
+
+var (
+	xWindowID = regexp.MustCompile(`0x[0-9a-fA-F]+`)
+	xPid      = regexp.MustCompile(`_NET_WM_PID\(CARDINAL\) = (\d+)`)
+	xClass    = regexp.MustCompile(`WM_CLASS\(STRING\) = "((?:[^"\\]|\\.)*)", "((?:[^"\\]|\\.)*)"`)
+)
+
+func x11Windows() ([]window, error) {
+	out, err := runner.Output(commandTimeout, "xprop", "-root", "_NET_CLIENT_LIST")
+	if err != nil {
+		return nil, err
+	}
+	var windows []window
+	for _, id := range xWindowID.FindAllString(string(out), -1) {
+		props, err := runner.Output(commandTimeout, "xprop", "-id", id, "_NET_WM_PID", "WM_CLASS")
+		if err != nil {
+			continue
+		}
+		pid := xPid.FindStringSubmatch(string(props))
+		class := xClass.FindStringSubmatch(string(props))
+		if pid == nil || class == nil {
+			continue
+		}
+		n, _ := strconv.Atoi(pid[1])
+		// StartupWMClass сравнивается с классом, вторым значением WM_CLASS
+		windows = append(windows, window{pid: n, class: class[2]})
+	}
+	return windows, nil
+}
+
+// swayNode — узел дерева окон sway
+type swayNode struct {
+	Pid              int    `json:"pid"`
+	AppID            string `json:"app_id"`
+	WindowProperties *struct {
+		Class string `json:"class"`
+	} `json:"window_properties"`
+	Nodes         []swayNode `json:"nodes"`
+	FloatingNodes []swayNode `json:"floating_nodes"`
+}
+
+func swayWindows() ([]window, error) {
+	out, err := runner.Output(commandTimeout, "swaymsg", "-r", "-t", "get_tree")
+	if err != nil {
+		return nil, err
+	}
+	var root swayNode
+	if err := json.Unmarshal(out, &root); err != nil {
+		return nil, err
+	}
+
+	var windows []window
+	var walk func(n swayNode)
+	walk = func(n swayNode) {
+		if n.Pid > 0 {
+			class := n.AppID
+			if n.WindowProperties != nil && n.WindowProperties.Class != "" {
+				class = n.WindowProperties.Class
+			}
+			windows = append(windows, window{pid: n.Pid, class: class})
+		}
+		for _, child := range append(n.Nodes, n.FloatingNodes...) {
+			walk(child)
+		}
+	}
+	walk(root)
+	return windows, nil
+}
+
+func hyprlandWindows() ([]window, error) {
+	out, err := runner.Output(commandTimeout, "hyprctl", "clients", "-j")
+	if err != nil {
+		return nil, err
+	}
+	var clients []struct {
+		Pid   int    `json:"pid"`
+		Class string `json:"class"`
+	}
+	if err := json.Unmarshal(out, &clients); err != nil {
+		return nil, err
+	}
+	windows := make([]window, 0, len(clients))
+	for _, c := range clients {
+		windows = append(windows, window{pid: c.Pid, class: c.Class})
+	}
+	return windows, nil
+}
+
+// FixDesktopFile записывает класс окна в StartupWMClass ярлыка, заменяя прежнее значение
+func FixDesktopFile(path, class string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	var lines []string
+	inEntry, written := false, false
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") {
+			// Дописываем ключ в конец группы [Desktop Entry], если его не было
+			if inEntry && !written {
+				lines = append(lines, "StartupWMClass="+class)
+				written = true
+			}
+			inEntry = trimmed == "[Desktop Entry]"
+		}
+		if inEntry && strings.HasPrefix(trimmed, "StartupWMClass=") {
+			line = "StartupWMClass=" + class
+			written = true
+		}
+		lines = append(lines, line)
+	}
+	if inEntry && !written {
+		lines = append(lines, "StartupWMClass="+class)
+	}
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), info.Mode().Perm())
+}
@@ -1,46 +1,186 @@
 package main
 
 import (
-	"archive/zip"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
 	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/therecipe/qt/core"
 	"github.com/therecipe/qt/gui"
 	"github.com/therecipe/qt/widgets"
+
+	"golang-installer/internal/accessibility"
+	"golang-installer/internal/archive"
+	"golang-installer/internal/auth"
+	"golang-installer/internal/backup"
+	"golang-installer/internal/banner"
+	"golang-installer/internal/branding"
+	"golang-installer/internal/bundle"
+	"golang-installer/internal/chaos"
+	"golang-installer/internal/cloudsave"
+	"golang-installer/internal/codesign"
+	"golang-installer/internal/common"
+	"golang-installer/internal/deploy"
+	"golang-installer/internal/desktopfile"
+	"golang-installer/internal/diagnostics"
+	"golang-installer/internal/downloadcache"
+	"golang-installer/internal/engine"
+	"golang-installer/internal/estimate"
+	"golang-installer/internal/failure"
+	"golang-installer/internal/flatpak"
+	"golang-installer/internal/gate"
+	"golang-installer/internal/itch"
+	"golang-installer/internal/keyring"
+	"golang-installer/internal/kiosk"
+	"golang-installer/internal/lanshare"
+	"golang-installer/internal/launcher"
+	"golang-installer/internal/maintenance"
+	"golang-installer/internal/manifest"
+	"golang-installer/internal/membudget"
+	"golang-installer/internal/mounts"
+	"golang-installer/internal/network"
+	"golang-installer/internal/objstore"
+	"golang-installer/internal/overlay"
+	"golang-installer/internal/pathlabel"
+	"golang-installer/internal/pin"
+	"golang-installer/internal/preseed"
+	"golang-installer/internal/profile"
+	"golang-installer/internal/qr"
+	"golang-installer/internal/receipt"
+	"golang-installer/internal/report"
+	"golang-installer/internal/runner"
+	"golang-installer/internal/settings"
+	"golang-installer/internal/signature"
+	"golang-installer/internal/slug"
+	"golang-installer/internal/snapshot"
+	"golang-installer/internal/source"
+	"golang-installer/internal/survey"
+	"golang-installer/internal/timeline"
+	"golang-installer/internal/toast"
+	"golang-installer/internal/updates"
+	"golang-installer/internal/verifyview"
+	"golang-installer/internal/warnings"
+	"golang-installer/internal/webhook"
 )
 
-// InstallInfo структура для хранения информации об установке
-type InstallInfo struct {
-	GameName        string    `json:"game_name"`
-	InstallPath     string    `json:"install_path"`
-	InstallDate     time.Time `json:"install_date"`
-	DesktopFile     string    `json:"desktop_file"`
-	MenuFile        string    `json:"menu_file"`
-	InstallerPath   string    `json:"installer_path"`
-	InstallerDir    string    `json:"installer_dir"`
-	UninstallerPath string    `json:"uninstaller_path"` // Новое поле для пути к uninstaller
-}
+// Запись об установке общая с менеджером игр
+type (
+	InstallInfo  = common.InstallInfo
+	VersionEntry = common.VersionEntry
+)
 
 type Config struct {
-	InstallPath        string             `json:"install_path"`
-	IconPath           string             `json:"icon_path"`
-	BannerPath         string             `json:"banner_path"`
-	GameAssets         []string           `json:"game_assets"`
-	DLLPath            string             `json:"dll_path"`
-	ExecPath           string             `json:"exec_path"` // Путь к основному исполняемому файлу
-	ExecDirs           []string           `json:"exec_dirs"` // Директории, где искать исполняемые файлы
-	DesktopEntry       DesktopEntryConfig `json:"desktop_entry"`
-	MinRequiredSpaceGB float64            `json:"min_required_space_gb"`
+	InstallPath        string                     `json:"install_path"`
+	Version            string                     `json:"version"`
+	IconPath           string                     `json:"icon_path"`
+	BannerPath         string                     `json:"banner_path"`
+	GameAssets         []string                   `json:"game_assets"`
+	DLLPath            string                     `json:"dll_path"`
+	ExecPath           string                     `json:"exec_path"` // Путь к основному исполняемому файлу
+	ExecDirs           []string                   `json:"exec_dirs"` // Директории, где искать исполняемые файлы
+	DesktopEntry       DesktopEntryConfig         `json:"desktop_entry"`
+	MinRequiredSpaceGB float64                    `json:"min_required_space_gb"`
+	AllowUnsafeEntries bool                       `json:"allow_unsafe_entries"` // Пропускать опасные записи архива вместо прерывания установки
+	Pages              []WizardPage               `json:"pages"`                // Дополнительные страницы перед установкой
+	OptionsFile        string                     `json:"options_file"`         // Куда записать значения полей со страниц (относительно директории игры)
+	SquashfsMount      bool                       `json:"squashfs_mount"`       // Не распаковывать образ squashfs, а монтировать его при запуске через squashfuse
+	AssetHashes        map[string]string          `json:"asset_hashes"`         // SHA-256 скачиваемых ресурсов по ссылкам из game_assets
+	AssetStores        map[string]objstore.Config `json:"asset_stores"`         // Хранилища S3 и WebDAV для ссылок store://<имя>/<ключ>
+	SSH                source.SSHConfig           `json:"ssh"`                  // Ключ и порт для сборок по ссылкам ssh://, которые получает rsync
+	CacheLimitMB       int64                      `json:"cache_limit_mb"`       // Ограничение кэша загрузок, по умолчанию 10 ГБ
+	AppID              string                     `json:"app_id"`               // Идентификатор ярлыка, например com.publisher.Game
+	Launch             LaunchConfig               `json:"launch"`               // Параметры запуска игры
+	UserData           []string                   `json:"user_data"`            // Шаблоны файлов пользователя (настройки, сохранения), которые обновление не перезаписывает
+	FreeSpaceMarginGB  float64                    `json:"free_space_margin_gb"` // Запас свободного места сверх расчетного, по умолчанию 0.5 ГБ
+	TempSpaceGB        float64                    `json:"temp_space_gb"`        // Сколько места временно нужно для загрузки и распаковки
+	TempDir            string                     `json:"temp_dir"`             // Директория временных файлов, по умолчанию в $XDG_CACHE_HOME
+	MemoryLimitMB      int64                      `json:"memory_limit_mb"`      // Потолок памяти установщика для машин с малым объемом ОЗУ
+	DiskBenchmark      bool                       `json:"disk_benchmark"`       // Замерить скорость диска перед установкой, чтобы точнее оценить время
+	KeepBackups        int                        `json:"keep_backups"`         // Сколько резервных копий обновлений хранить, по умолчанию 3, -1 отключает копии
+	Snapshot           bool                       `json:"snapshot"`             // На btrfs и ZFS устанавливать игру в отдельный том и делать снимок после установки
+	TryBeforeInstall   bool                       `json:"try_before_install"`   // Экспериментально: предложить запустить игру через overlayfs до переноса на место
+	SystemReceipt      bool                       `json:"system_receipt"`       // Зарегистрировать игру в dpkg или rpm пустым пакетом-квитанцией
+	FlatpakExport      bool                       `json:"flatpak_export"`       // Упаковать игру в локальный Flatpak и запускать ее в песочнице
+	FlatpakRuntime     string                     `json:"flatpak_runtime"`      // Ветка среды выполнения Freedesktop, по умолчанию 24.08
+	ItchPatches        map[string]string          `json:"itch_patches"`         // Патчи itch.io (.pwr) до текущей версии по установленной версии
+	SyncManifest       string                     `json:"sync_manifest"`        // Манифест файлов игры: скачиваются только недостающие и изменившиеся файлы
+	ItchSignature      string                     `json:"itch_signature"`       // Подпись itch.io (.pws) текущей версии для проверки после патча
+	Languages          []LanguagePack             `json:"languages"`            // Языковые пакеты на выбор
+	LanguageFile       string                     `json:"language_file"`        // Файл настроек первого запуска игры, куда записывается язык
+	LanguageTemplate   string                     `json:"language_template"`    // Содержимое файла языка, {{language}} заменяется кодом
+	SystemWide         bool                       `json:"system_wide"`          // Установить для всех пользователей (нужны права root), как с ключом --system
+	Auth               auth.Config                `json:"auth"`                 // Вход, без которого CDN не отдает файлы игры
+	Gate               gate.Config                `json:"gate"`                 // Ограничения по возрасту и региону, проверяются до лицензионного соглашения
+	EULA               string                     `json:"eula"`                 // Текстовый файл лицензионного соглашения, которое нужно принять перед установкой
+	Webhooks           []string                   `json:"webhooks"`             // Адреса, на которые POST-запросом уходит событие об окончании установки и удаления
+	Companion          CompanionConfig            `json:"companion"`            // QR-код на странице завершения для продолжения на телефоне
+	CloudSaves         cloudsave.Config           `json:"cloud_saves"`          // Хранилище S3 или WebDAV, через которое переносятся сохранения
+	SupportURL         string                     `json:"support_url"`          // Адрес, на который POST-запросом уходят отчеты для поддержки
+	PinToMenu          bool                       `json:"pin_to_menu"`          // Отметить по умолчанию закрепление в избранном KDE или папке приложений GNOME
+	AppFolder          string                     `json:"app_folder"`           // Папка приложений GNOME, по умолчанию Games
+	Maintenance        maintenance.Config         `json:"maintenance"`          // Кэши шейдеров и файлы настроек игры для обслуживания из менеджера
+	UninstallSurvey    survey.Config              `json:"uninstall_survey"`     // Необязательный опрос о причине удаления, только с согласия на статистику
+	UninstallEntry     string                     `json:"uninstall_entry"`      // Отметить пункт удаления игры в меню: action — действие ярлыка игры, menu — отдельный ярлык в «Настройках»
+	Kiosk              kiosk.Config               `json:"kiosk"`                // Режим киоска для демо-стендов и компьютерных клубов
+	Fonts              branding.Fonts             `json:"fonts"`                // Шрифты оформления из пакета, как у игры
+	BannerAnimation    string                     `json:"banner_animation"`     // GIF, WebP или видео MP4, WebM в шапке вместо banner_path
+	BannerSound        bool                       `json:"banner_sound"`         // Включить звук видео баннера сразу, по умолчанию без звука
+}
+
+// CompanionConfig — ссылка для телефона на странице завершения установки: руководство
+// или привязка установки к учетной записи издателя
+type CompanionConfig struct {
+	URL  string `json:"url"`  // {{token}} заменяется токеном установки, допускаются поля {{id}}
+	Text string `json:"text"` // Подпись под кодом
+}
+
+// LaunchConfig описывает, как запускать игру: из окна завершения установки,
+// из ярлыка и из скрипта запуска смонтированного образа
+type LaunchConfig struct {
+	WorkDir string            `json:"work_dir"` // Рабочая директория относительно директории игры
+	Args    []string          `json:"args"`     // Аргументы, допускаются поля {{id}}
+	Env     map[string]string `json:"env"`      // Переменные окружения, допускаются поля {{id}}
+}
+
+// LanguagePack — архивы с озвучкой и текстами на одном языке
+type LanguagePack struct {
+	Code   string   `json:"code"` // Например "ru", попадает в {{language}}
+	Name   string   `json:"name"`
+	Assets []string `json:"assets"`
+}
+
+// WizardPage описывает дополнительную страницу установщика из конфигурации
+type WizardPage struct {
+	Title  string        `json:"title"`
+	Text   string        `json:"text"` // Текст страницы, допускается HTML
+	Fields []WizardField `json:"fields"`
+}
+
+// WizardField — поле ввода на дополнительной странице
+type WizardField struct {
+	ID       string   `json:"id"`
+	Label    string   `json:"label"`
+	Type     string   `json:"type"` // text, choice, checkbox или secret (ключ активации, пароль)
+	Options  []string `json:"options"`
+	Default  string   `json:"default"`
+	Required bool     `json:"required"`
 }
 
 type DesktopEntryConfig struct {
@@ -48,231 +188,3551 @@ type DesktopEntryConfig struct {
 	Exec       string `json:"exec"`
 	Icon       string `json:"icon"`
 	Categories string `json:"categories"`
+	Genre      string `json:"genre"` // Жанры через запятую (platformer, rpg, strategy), переводятся в категории меню
 	Type       string `json:"type"`
 	Terminal   bool   `json:"terminal"`
 	Comment    string `json:"comment"`
+	// StartupWMClass — класс окна игры. Если не задан, менеджер определит его при первом запуске.
+	StartupWMClass string `json:"startup_wm_class"`
 }
 
+// launcherFileName — скрипт запуска игры из смонтированного образа squashfs
+const launcherFileName = "launch.sh"
+
+// flatpakLauncherName — скрипт в директории игры, запускающий ее приложение Flatpak
+const flatpakLauncherName = "launch-flatpak.sh"
+
 var config Config
 var installButton *widgets.QPushButton
-var pathLabel *widgets.QLabel
+var pathLabel *pathlabel.Label
 var progressBar *widgets.QProgressBar
+
+// verifyView — карта проверки файлов по манифесту, на время проверки заменяет progressBar
+var verifyView *verifyview.View
+
+// progressVoice озвучивает этапы и прогресс установки для экранного диктора
+var progressVoice *accessibility.Tracker
+
+// warningsPanel — предупреждения установки со счетчиком, вместо окна на каждое
+var warningsPanel *warnings.Panel
+
+// installEvents — ключевые события этого запуска установщика для записи об установке
+var installEvents timeline.Timeline
+
+// warningToast сообщает о новых предупреждениях, не прерывая установку
+var warningToast *toast.Toaster
 var createShortcutCheckBox *widgets.QCheckBox
+var lanCheckBox *widgets.QCheckBox
+var pinCheckBox *widgets.QCheckBox
+
+// uninstallEntryCheckBox добавляет в меню пункт удаления игры
+var uninstallEntryCheckBox *widgets.QCheckBox
+var spaceInfoLabel *widgets.QLabel
+var tempDirButton *widgets.QPushButton
+var scheduleButton *widgets.QPushButton
+
+// scheduledAt — время, на которое отложена установка; нулевое, если не отложена
+var scheduledAt time.Time
+
+// waitingUnmetered — установка ждет, когда подключение перестанет быть лимитным
+var waitingUnmetered bool
+
+// waitingOnline — установка ждет подключения к интернету. Вход для загрузки
+// выполняется, когда оно появится.
+var waitingOnline bool
+
+// offline — при последней проверке интернета не было
+var offline bool
+var offlineLabel *widgets.QLabel
+var languageCombo *widgets.QComboBox
+
+// meteredThresholdGB — с такого объема загрузка по лимитному подключению не
+// начинается без вопроса
+const meteredThresholdGB = 1.0
+
+// userSettings — общие настройки установщика и менеджера
+var userSettings settings.Settings
 var installInfo InstallInfo
 
+// pageValues — значения полей, введенные на дополнительных страницах
+var pageValues = make(map[string]string)
+
+// profiling включается параметром --profile или переменной GO_QT_INSTALLER_PROFILE=1:
+// установка записывает отчет о производительности в директорию логов игры
+var profiling bool
+
+// profiler собирает замеры текущей установки, если включено профилирование
+var profiler *profile.Recorder
+
+// launcherPath — скрипт запуска, если образ squashfs установлен без распаковки
+var launcherPath string
+
+// patchFailed выставляется, если обновление патчем itch.io не удалось: следующая
+// попытка ставит полную версию из архивов
+var patchFailed bool
+
+// verifyFiles включается параметром --verify: при синхронизации по манифесту хэши
+// проверяются у всех файлов, а не только у изменившихся с прошлой синхронизации
+var verifyFiles bool
+
+// patchSteps — на столько шагов делится прогресс применения патча и синхронизации
+// по манифесту
+const patchSteps = 100
+
+// answers — ответы из файла --preseed. Если они заданы, установка идет без вопросов:
+// окно только показывает прогресс, ошибки пишутся в лог, а установщик завершается
+// с кодом 0 после успешной установки и 1 после ошибки.
+var answers *preseed.Answers
+
 func loadConfig(filePath string) error {
 	data, err := ioutil.ReadFile(filePath)
 	if err != nil {
 		return err
 	}
 
-	return json.Unmarshal(data, &config)
-}
-
-func chooseInstallPath() {
-	dialog := widgets.QFileDialog_GetExistingDirectory(nil, "Выберите путь установки", "", 0)
-	if dialog != "" {
-		config.InstallPath = filepath.Join(dialog, "Celeste")
-		updateInstallPathDisplay()
-		checkInstallButtonState()
+	return json.Unmarshal(data, &config)
+}
+
+// launchDir — текущая директория при запуске. Пути из аргументов командной
+// строки считаются от нее, даже когда установщик перешел в директорию пакета.
+var launchDir string
+
+// flagPath возвращает путь из --name <путь> или --name=<путь>, приведенный к
+// абсолютному, или пустую строку
+func flagPath(name string) string {
+	value := ""
+	for i, arg := range os.Args[1:] {
+		if arg == name && i+2 < len(os.Args) {
+			value = os.Args[i+2]
+		} else if v, ok := strings.CutPrefix(arg, name+"="); ok {
+			value = v
+		}
+	}
+	if value == "" || filepath.IsAbs(value) {
+		return value
+	}
+	return filepath.Join(launchDir, value)
+}
+
+// openPackage загружает конфигурацию пакета и переходит в его директорию: пути
+// в конфигурации считаются от нее. Без --package и --config берется config.json
+// рядом с установщиком, а если его там нет — из текущей директории, как при
+// запуске из каталога сборки.
+func openPackage() error {
+	path := flagPath("--package")
+	if path == "" {
+		path = flagPath("--config")
+	}
+	if path == "" {
+		path = filepath.Join(common.ExecutableDir(), bundle.ConfigName)
+		if _, err := os.Stat(path); err != nil {
+			path = filepath.Join(launchDir, bundle.ConfigName)
+		}
+	}
+	configPath, err := bundle.Open(path)
+	if err != nil {
+		return err
+	}
+	if err := os.Chdir(filepath.Dir(configPath)); err != nil {
+		return err
+	}
+	log.Printf("Конфигурация: %s", configPath)
+	if err := loadConfig(filepath.Base(configPath)); err != nil {
+		return err
+	}
+
+	// Иконку и баннер можно заменить без правки конфигурации
+	if icon := flagPath("--icon"); icon != "" {
+		config.IconPath = icon
+	}
+	if banner := flagPath("--banner"); banner != "" {
+		config.BannerPath = banner
+		config.BannerAnimation = ""
+	}
+	// Ресурсы из конфигурации считаются от ее директории, а не от установщика
+	for _, p := range []*string{&config.IconPath, &config.BannerPath, &config.BannerAnimation} {
+		if *p != "" && !filepath.IsAbs(*p) {
+			*p = filepath.Join(filepath.Dir(configPath), *p)
+		}
+	}
+	for i, p := range config.Fonts.Files {
+		if !filepath.IsAbs(p) {
+			config.Fonts.Files[i] = filepath.Join(filepath.Dir(configPath), p)
+		}
+	}
+	return nil
+}
+
+// installUninstaller записывает деинсталлятор в директорию игры. Явный
+// --uninstaller важнее встроенного в установщик, а без обоих берется файл
+// рядом с установщиком.
+func installUninstaller(dst string) error {
+	if flagPath("--uninstaller") == "" {
+		err := source.ExtractPayload(source.PayloadUninstaller, dst)
+		if err == nil {
+			return os.Chmod(dst, 0755)
+		}
+		if !errors.Is(err, source.ErrNotInPayload) {
+			return err
+		}
+	}
+	src := uninstallerSource()
+	if _, err := os.Stat(src); err != nil {
+		return fmt.Errorf("деинсталлятор не встроен в установщик и не найден рядом с ним: %s", src)
+	}
+	return copyFile(src, dst)
+}
+
+// embedUninstaller — режим --embed-uninstaller: собирает установщик, в
+// который встроены деинсталлятор и, если задан, zip-архив игры
+func embedUninstaller(args []string) int {
+	// --sign=<способ> подписывает готовый установщик
+	var signers []codesign.Signer
+	var positional []string
+	for _, arg := range args {
+		spec, ok := strings.CutPrefix(arg, "--sign=")
+		if !ok {
+			positional = append(positional, arg)
+			continue
+		}
+		signer, err := codesign.Parse(spec)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Ошибка: %v\n", err)
+			return 2
+		}
+		signers = append(signers, signer)
+	}
+	args = positional
+	if len(args) < 2 {
+		fmt.Fprintln(os.Stderr, "Использование: --embed-uninstaller <деинсталлятор> <новый установщик> [архив игры.zip] [--sign=<способ>]")
+		return 2
+	}
+	game := ""
+	if len(args) > 2 {
+		game = args[2]
+	}
+	self, err := common.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка: %v\n", err)
+		return 1
+	}
+	modified, err := sourceDateEpoch()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка: %v\n", err)
+		return 1
+	}
+	files := map[string]string{source.PayloadUninstaller: args[0]}
+	if err := source.EmbedPayload(args[1], self, files, game, modified); err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка при сборке установщика: %v\n", err)
+		return 1
+	}
+
+	// Манифест сборки публикуется рядом с установщиком
+	m, err := source.DescribeBuild(args[1], self, config.Version, modified)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка при описании сборки: %v\n", err)
+		return 1
+	}
+	for _, signer := range signers {
+		if err := m.Sign(args[1], signer); err != nil {
+			fmt.Fprintf(os.Stderr, "Ошибка: %v\n", err)
+			return 1
+		}
+	}
+	if err := m.Save(args[1] + buildManifestSuffix); err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка при записи манифеста сборки: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Установщик со встроенным деинсталлятором: %s\nSHA-256: %s\nМанифест сборки: %s\n", args[1], m.SHA256, args[1]+buildManifestSuffix)
+	return 0
+}
+
+// buildManifestSuffix — манифест сборки лежит рядом с установщиком: setup.build.json
+const buildManifestSuffix = ".build.json"
+
+// sourceDateEpoch возвращает время файлов во встроенном архиве: из
+// SOURCE_DATE_EPOCH, как принято для воспроизводимых сборок, или 1 января 1980
+// года — самое раннее время, которое можно записать в zip
+func sourceDateEpoch() (time.Time, error) {
+	epoch := os.Getenv("SOURCE_DATE_EPOCH")
+	if epoch == "" {
+		return time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC), nil
+	}
+	seconds, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("неверный SOURCE_DATE_EPOCH: %v", err)
+	}
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+// verifyBuild — режим --verify-build: сверяет установщик с манифестом сборки.
+// Без второго аргумента проверяется сам запущенный установщик.
+func verifyBuild(args []string) int {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "Использование: --verify-build <манифест сборки> [установщик]")
+		return 2
+	}
+	m, err := source.LoadBuildManifest(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка: %v\n", err)
+		return 1
+	}
+	path := ""
+	if len(args) > 1 {
+		path = args[1]
+	} else if path, err = common.Executable(); err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка: %v\n", err)
+		return 1
+	}
+	if err := source.VerifyBuild(path, m); err != nil {
+		fmt.Fprintf(os.Stderr, "%s не совпадает со сборкой %s: %v\n", path, m.Name, err)
+		return 1
+	}
+	fmt.Printf("%s совпадает со сборкой %s %s\n", path, m.Name, m.Version)
+	for _, sig := range m.Signatures {
+		fmt.Printf("Подпись %s верна\n", sig.Tool)
+	}
+	return 0
+}
+
+// fillSharedCache — режим --fill-shared-cache: администратор добавляет файлы в
+// общий кэш загрузок. Для директории, например личного кэша пользователя,
+// добавляются все файлы в ней.
+func fillSharedCache(args []string) int {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "Использование: --fill-shared-cache <файл или директория>...")
+		return 2
+	}
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Ошибка: %v\n", err)
+			return 1
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		entries, err := ioutil.ReadDir(arg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Ошибка: %v\n", err)
+			return 1
+		}
+		for _, e := range entries {
+			// Недокачанные файлы и индекс ссылок в общий кэш не попадают
+			if e.Mode().IsRegular() && !strings.HasPrefix(e.Name(), ".") {
+				files = append(files, filepath.Join(arg, e.Name()))
+			}
+		}
+	}
+	for _, file := range files {
+		sum, err := downloadcache.Fill(downloadcache.SharedDir, file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Ошибка при добавлении %s в %s: %v\n", file, downloadcache.SharedDir, err)
+			return 1
+		}
+		fmt.Printf("%s → %s\n", file, sum)
+	}
+	return 0
+}
+
+// signBuilds — режим --sign: подписывает собранные установщики и записывает
+// подписи в их манифесты сборки, а если манифеста нет — создает его
+func signBuilds(args []string) int {
+	if len(args) < 2 {
+		fmt.Fprintln(os.Stderr, "Использование: --sign <gpg[:ключ]|osslsigncode:<ключ>|codesign:<сертификат>> <файл>...")
+		return 2
+	}
+	signer, err := codesign.Parse(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка: %v\n", err)
+		return 2
+	}
+	code := 0
+	for _, path := range args[1:] {
+		manifestPath := path + buildManifestSuffix
+		m, err := source.LoadBuildManifest(manifestPath)
+		if os.IsNotExist(err) {
+			m, err = &source.BuildManifest{}, nil
+		}
+		if err == nil {
+			err = m.Sign(path, signer)
+		}
+		if err == nil {
+			err = m.Save(manifestPath)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			code = 1
+			continue
+		}
+		fmt.Printf("%s подписан (%s), манифест сборки: %s\n", path, signer.Tool, manifestPath)
+	}
+	return code
+}
+
+// uninstallerSource возвращает менеджер игр, который копируется в директорию
+// игры: из --uninstaller или лежащий рядом с установщиком
+func uninstallerSource() string {
+	if path := flagPath("--uninstaller"); path != "" {
+		return path
+	}
+	return filepath.Join(common.ExecutableDir(), "uninstaller")
+}
+
+// dropFiles обрабатывает файлы, брошенные на окно. Конфигурация или пакет
+// открываются в новом окне установщика, архивы с файлами игры добавляются к
+// ресурсам этой установки.
+func dropFiles(paths []string) {
+	if progressBar.IsVisible() || !scheduledAt.IsZero() || waitingOnline || waitingUnmetered {
+		displayWarning("Установка уже начата или запланирована. Дождитесь ее окончания или откройте пакет в новом окне установщика.")
+		return
+	}
+	var added []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		isPackage := info.IsDir() || strings.EqualFold(filepath.Ext(path), ".json")
+		if !isPackage && bundle.IsArchive(path) {
+			_, err := bundle.Open(path)
+			if err != nil && !errors.Is(err, bundle.ErrNoConfig) {
+				displayWarning(err.Error())
+				return
+			}
+			isPackage = err == nil
+		}
+		if isPackage {
+			if err := openInNewWindow(path); err != nil {
+				displayWarning("Не удалось открыть пакет: " + err.Error())
+			}
+			return
+		}
+
+		ext, err := archive.Open(path)
+		if err != nil {
+			displayWarning(fmt.Sprintf("%s — не пакет установщика и не архив с файлами игры", filepath.Base(path)))
+			continue
+		}
+		ext.Close()
+		config.GameAssets = append(config.GameAssets, path)
+		added = append(added, filepath.Base(path))
+		log.Printf("Добавлен файл игры: %s", path)
+	}
+	if len(added) > 0 {
+		widgets.QMessageBox_Information(nil, "Файлы игры", "Будут установлены также:\n"+strings.Join(added, "\n"),
+			widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+	}
+}
+
+// openInNewWindow запускает установщик с пакетом и закрывает текущее окно
+func openInNewWindow(path string) error {
+	self, err := common.Executable()
+	if err != nil {
+		return err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if _, err := launcher.Start(launcher.Spec{Path: self, Args: []string{"--package", abs}, Dir: filepath.Dir(abs)}); err != nil {
+		return err
+	}
+	core.QCoreApplication_Exit(0)
+	return nil
+}
+
+// applyArchiveMetadata берет название и версию игры из установщиков GOG и Humble
+// Bundle среди локальных ресурсов, если они не указаны в конфигурации
+func applyArchiveMetadata() {
+	if config.DesktopEntry.Name != "" && config.Version != "" {
+		return
+	}
+	for _, asset := range config.GameAssets {
+		if _, err := os.Stat(asset); err != nil {
+			continue
+		}
+		ext, err := archive.Open(asset)
+		if err != nil {
+			continue
+		}
+		meta, ok := ext.(archive.Metadata)
+		if !ok {
+			ext.Close()
+			continue
+		}
+		name, version := meta.GameInfo()
+		ext.Close()
+		if config.DesktopEntry.Name == "" && name != "" {
+			config.DesktopEntry.Name = name
+			log.Printf("Название игры взято из %s: %s", filepath.Base(asset), name)
+		}
+		if config.Version == "" && version != "" {
+			config.Version = version
+			log.Printf("Версия игры взята из %s: %s", filepath.Base(asset), version)
+		}
+		return
+	}
+}
+
+// installRoot возвращает директорию, в которую по умолчанию ставятся игры
+func installRoot() string {
+	if common.SystemWide {
+		return deploy.Root
+	}
+	return userSettings.InstallRoot
+}
+
+func chooseInstallPath() {
+	dialog := widgets.QFileDialog_GetExistingDirectory(nil, "Выберите путь установки", installRoot(), 0)
+	if dialog != "" {
+		config.InstallPath = filepath.Join(dialog, "Celeste")
+		updateInstallPathDisplay()
+		checkInstallButtonState()
+	}
+}
+
+func updateInstallPathDisplay() {
+	pathLabel.SetPath(config.InstallPath, "не выбран")
+}
+
+func checkInstallButtonState() {
+	if config.InstallPath != "" {
+		installButton.SetEnabled(true)
+	} else {
+		installButton.SetEnabled(false)
+	}
+	if scheduleButton != nil {
+		scheduleButton.SetEnabled(installButton.IsEnabled())
+	}
+}
+
+// checkDiskSpace возвращает свободное место в ГБ на диске, где находится путь.
+// Путь может еще не существовать, тогда проверяется ближайшая существующая директория.
+func checkDiskSpace(dirPath string) (float64, error) {
+	var stat syscall.Statfs_t
+	err := syscall.Statfs(existingDir(dirPath), &stat)
+	if err != nil {
+		return 0, err
+	}
+
+	freeSpace := stat.Bavail * uint64(stat.Bsize)
+	// Convert to gigabytes
+	freeSpaceGB := float64(freeSpace) / (1024 * 1024 * 1024)
+	return freeSpaceGB, nil
+}
+
+// existingDir возвращает ближайшую существующую директорию на пути
+func existingDir(dirPath string) string {
+	dirPath, _ = filepath.Abs(dirPath)
+	for {
+		if _, err := os.Stat(dirPath); err == nil {
+			return dirPath
+		}
+		parent := filepath.Dir(dirPath)
+		if parent == dirPath {
+			return "."
+		}
+		dirPath = parent
+	}
+}
+
+// sameFilesystem проверяет, находятся ли два пути на одном диске
+func sameFilesystem(a, b string) bool {
+	var stA, stB syscall.Stat_t
+	if syscall.Stat(existingDir(a), &stA) != nil || syscall.Stat(existingDir(b), &stB) != nil {
+		return false
+	}
+	return stA.Dev == stB.Dev
+}
+
+// defaultFreeSpaceMarginGB — запас свободного места сверх расчетного по умолчанию
+const defaultFreeSpaceMarginGB = 0.5
+
+// freeSpaceMargin возвращает запас свободного места из конфигурации
+func freeSpaceMargin() float64 {
+	if config.FreeSpaceMarginGB > 0 {
+		return config.FreeSpaceMarginGB
+	}
+	return defaultFreeSpaceMarginGB
+}
+
+// bytesToGB переводит байты в гигабайты
+func bytesToGB(n int64) float64 {
+	return float64(n) / (1024 * 1024 * 1024)
+}
+
+// showSpaceInfo показывает размер игры и отдельно пиковый объем временных файлов
+func showSpaceInfo(finalGB, tempGB float64) {
+	text := fmt.Sprintf("Требуемое свободное место: %.2f ГБ", finalGB)
+	if tempGB > 0 {
+		text += fmt.Sprintf(", временно еще %.2f ГБ в %s", tempGB, archive.StagingDir())
+	}
+	spaceInfoLabel.SetText(text)
+}
+
+// checkSpace проверяет, хватит ли места для игры и временных файлов с учетом запаса,
+// и возвращает описание нехватки. Если временные файлы лежат на том же диске, что
+// и игра, их объемы складываются.
+func checkSpace(finalGB, tempGB float64) (string, error) {
+	margin := freeSpaceMargin()
+	tempDir := archive.StagingDir()
+	required := finalGB + margin
+	details := fmt.Sprintf("игра %.2f ГБ", finalGB)
+
+	if tempGB > 0 && sameFilesystem(config.InstallPath, tempDir) {
+		required += tempGB
+		details += fmt.Sprintf(", временные файлы %.2f ГБ", tempGB)
+	} else if tempGB > 0 {
+		free, err := checkDiskSpace(tempDir)
+		if err != nil {
+			return "", err
+		}
+		if free < tempGB+margin {
+			return fmt.Sprintf("Недостаточно места для временных файлов в %s. Свободно: %.2f ГБ, требуется: %.2f ГБ. "+
+				"Выберите для временных файлов другой диск.", tempDir, free, tempGB+margin), nil
+		}
+	}
+
+	free, err := checkDiskSpace(config.InstallPath)
+	if err != nil {
+		return "", err
+	}
+	if free < required {
+		return fmt.Sprintf("Недостаточно места для установки. Свободно: %.2f ГБ, требуется: %.2f ГБ (%s, запас %.2f ГБ).",
+			free, required, details, margin), nil
+	}
+	return "", nil
+}
+
+// defaultTempDir возвращает директорию временных файлов в $XDG_CACHE_HOME. Системный
+// /tmp часто находится в оперативной памяти и не вмещает архивы в несколько гигабайт.
+func defaultTempDir() string {
+	cacheHome := os.Getenv("XDG_CACHE_HOME")
+	if cacheHome == "" {
+		cacheHome = filepath.Join(os.Getenv("HOME"), ".cache")
+	}
+	return filepath.Join(cacheHome, "go-qt-installer", "tmp")
+}
+
+// setTempDir направляет временные файлы загрузки и распаковки в указанную директорию
+func setTempDir(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("не удалось создать директорию для временных файлов: %v", err)
+	}
+	archive.StagingRoot = dir
+	source.TempRoot = dir
+	return nil
+}
+
+// benchmarkSize — сколько данных записывается при замере скорости диска
+const benchmarkSize = 64 << 20
+
+// calibrateEstimate замеряет скорость записи на целевой диск и скорость распаковки
+// и возвращает ожидаемое время установки
+func calibrateEstimate(tracker *estimate.Tracker) (time.Duration, bool) {
+	writeRate, err := estimate.DiskWrite(config.InstallPath, benchmarkSize)
+	if err != nil {
+		log.Printf("Ошибка при замере скорости диска: %v", err)
+		return 0, false
+	}
+	inflateRate, err := estimate.Inflate(16 << 20)
+	if err != nil {
+		log.Printf("Ошибка при замере скорости распаковки: %v", err)
+		return 0, false
+	}
+	log.Printf("Скорость записи: %.1f МБ/с, распаковки: %.1f МБ/с", writeRate/(1<<20), inflateRate/(1<<20))
+	tracker.Calibrate(estimate.Combine(writeRate, inflateRate))
+	return tracker.Remaining()
+}
+
+// formatDuration выводит оставшееся время в виде "~2 мин"
+func formatDuration(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("~%d с", int(d.Seconds())+1)
+	case d < time.Hour:
+		return fmt.Sprintf("~%d мин", int(d.Minutes()+0.5))
+	default:
+		return fmt.Sprintf("~%d ч %d мин", int(d.Hours()), int(d.Minutes())%60)
+	}
+}
+
+// chooseTempDir позволяет перенести временные файлы загрузки и распаковки на другой диск
+func chooseTempDir() {
+	dir := widgets.QFileDialog_GetExistingDirectory(nil, "Выберите директорию для временных файлов", archive.StagingDir(), 0)
+	if dir == "" {
+		return
+	}
+	if err := setTempDir(dir); err != nil {
+		displayError(err.Error())
+		return
+	}
+	tempDirButton.SetText("Временные файлы: " + dir)
+	showSpaceInfo(config.MinRequiredSpaceGB, config.TempSpaceGB)
+}
+
+// Функция для установки прав на исполнение для файлов
+func setExecutablePermissions(path string) error {
+	return os.Chmod(path, 0755)
+}
+
+// Функция для рекурсивного поиска и установки прав на исполнение для бинарных файлов
+func makeFilesExecutable(dir string, patterns []string) {
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+
+		// Пропускаем директории
+		if info.IsDir() {
+			return nil
+		}
+
+		// Проверяем, является ли файл исполняемым по расширению или имени
+		isExecutable := false
+
+		// Проверяем по расширению
+		ext := strings.ToLower(filepath.Ext(path))
+		if ext == ".sh" || ext == ".bin" || ext == ".x86" || ext == ".x86_64" || ext == "" {
+			isExecutable = true
+		}
+
+		// Проверяем по имени файла
+		baseName := strings.ToLower(filepath.Base(path))
+		if strings.Contains(baseName, "run") || strings.Contains(baseName, "start") ||
+			strings.Contains(baseName, "game") || strings.Contains(baseName, "celeste") {
+			isExecutable = true
+		}
+
+		// Проверяем по шаблонам из конфига
+		for _, pattern := range patterns {
+			if matched, _ := filepath.Match(pattern, filepath.Base(path)); matched {
+				isExecutable = true
+				break
+			}
+		}
+
+		if isExecutable {
+			log.Printf("Устанавливаем права на исполнение для: %s", path)
+			if err := setExecutablePermissions(path); err != nil {
+				log.Printf("Ошибка при установке прав на исполнение для %s: %v", path, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// Коды завершения диалога дополнительной страницы
+const (
+	pageCancel = 0
+	pageNext   = 1
+	pageBack   = 2
+)
+
+// showWizardPage показывает одну дополнительную страницу и сохраняет введенные значения
+func showWizardPage(page WizardPage, index int) int {
+	dialog := widgets.NewQDialog(nil, 0)
+	dialog.SetWindowTitle(page.Title)
+
+	textLabel := widgets.NewQLabel2(page.Text, nil, 0)
+	textLabel.SetTextFormat(core.Qt__RichText)
+	textLabel.SetWordWrap(true)
+	textLabel.SetOpenExternalLinks(true)
+
+	form := widgets.NewQFormLayout(nil)
+	readers := make(map[string]func() string)
+	for _, field := range page.Fields {
+		value, ok := pageValues[field.ID]
+		if !ok {
+			value = field.Default
+		}
+
+		switch field.Type {
+		case "choice":
+			combo := widgets.NewQComboBox(nil)
+			combo.AddItems(field.Options)
+			combo.SetCurrentText(value)
+			readers[field.ID] = combo.CurrentText
+			form.AddRow3(field.Label, combo)
+		case "secret":
+			// Значение скрыто при вводе и сохраняется только в связке ключей
+			lineEdit := widgets.NewQLineEdit2(value, nil)
+			lineEdit.SetEchoMode(widgets.QLineEdit__Password)
+			readers[field.ID] = lineEdit.Text
+			form.AddRow3(field.Label, lineEdit)
+		case "checkbox":
+			checkBox := widgets.NewQCheckBox2(field.Label, nil)
+			checkBox.SetChecked(value == "true")
+			readers[field.ID] = func() string { return fmt.Sprintf("%t", checkBox.IsChecked()) }
+			form.AddRow5(checkBox)
+		default:
+			lineEdit := widgets.NewQLineEdit2(value, nil)
+			readers[field.ID] = lineEdit.Text
+			form.AddRow3(field.Label, lineEdit)
+		}
+	}
+
+	backButton := widgets.NewQPushButton2("Назад", nil)
+	backButton.SetEnabled(index > 0)
+	backButton.ConnectClicked(func(bool) {
+		dialog.Done(pageBack)
+	})
+
+	cancelButton := widgets.NewQPushButton2("Отмена", nil)
+	cancelButton.ConnectClicked(func(bool) {
+		dialog.Done(pageCancel)
+	})
+
+	nextButton := widgets.NewQPushButton2("Далее", nil)
+	nextButton.SetDefault(true)
+	nextButton.ConnectClicked(func(bool) {
+		for _, field := range page.Fields {
+			if field.Required && strings.TrimSpace(readers[field.ID]()) == "" {
+				widgets.QMessageBox_Warning(nil, "Предупреждение", "Заполните поле «"+field.Label+"»",
+					widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+				return
+			}
+		}
+		dialog.Done(pageNext)
+	})
+
+	buttonsLayout := widgets.NewQHBoxLayout()
+	buttonsLayout.AddWidget(backButton, 0, 0)
+	buttonsLayout.AddStretch(1)
+	buttonsLayout.AddWidget(cancelButton, 0, 0)
+	buttonsLayout.AddWidget(nextButton, 0, 0)
+
+	layout := widgets.NewQVBoxLayout()
+	layout.AddWidget(textLabel, 0, 0)
+	layout.AddLayout(form, 0)
+	layout.AddStretch(1)
+	layout.AddLayout(buttonsLayout, 0)
+	dialog.SetLayout(layout)
+	dialog.Resize(core.NewQSize2(500, 300))
+
+	result := dialog.Exec()
+	for id, read := range readers {
+		pageValues[id] = read()
+	}
+	return result
+}
+
+// passGate проверяет ограничения по региону и возрасту из конфигурации. Возвращает
+// true, если ограничений нет или пользователь их прошел; подтверждение попадает
+// в запись об установке.
+func passGate() bool {
+	if !config.Gate.Enabled() {
+		return true
+	}
+	locale := gate.SystemLocale()
+	if config.Gate.Blocked(locale) {
+		log.Printf("Установка недоступна в регионе %s", locale)
+		displayFailure(failure.Cancelled, fmt.Sprintf("%s недоступна для установки в вашем регионе (%s).", config.DesktopEntry.Name, locale))
+		return false
+	}
+	confirmation := &gate.Confirmation{
+		MinAge: config.Gate.MinAge,
+		Locale: locale,
+		Method: gate.MethodBirthDate,
+	}
+	if config.Gate.MinAge > 0 {
+		if answers != nil {
+			if !answers.ConfirmAge {
+				displayFailure(failure.Config, "В файле ответов не подтвержден возраст игрока (confirm_age)")
+				return false
+			}
+			confirmation.Method = gate.MethodPreseed
+		} else if !confirmAge() {
+			return false
+		}
+	}
+	confirmation.ConfirmedAt = time.Now()
+	installInfo.Gate = confirmation
+	log.Printf("Проверка возраста и региона пройдена: возраст %d+, локаль %s, способ %s",
+		confirmation.MinAge, confirmation.Locale, confirmation.Method)
+	return true
+}
+
+// confirmAge спрашивает дату рождения и сравнивает возраст с минимальным
+func confirmAge() bool {
+	dialog := widgets.NewQDialog(nil, 0)
+	dialog.SetWindowTitle("Проверка возраста")
+
+	text := config.Gate.Text
+	if text == "" {
+		text = fmt.Sprintf("%s предназначена для игроков старше %d лет. Укажите дату рождения.",
+			config.DesktopEntry.Name, config.Gate.MinAge)
+	}
+	textLabel := widgets.NewQLabel2(text, nil, 0)
+	textLabel.SetWordWrap(true)
+
+	today := core.QDate_CurrentDate()
+	birthDateEdit := widgets.NewQDateEdit2(core.NewQDate3(today.Year()-config.Gate.MinAge, 1, 1), nil)
+	birthDateEdit.SetDisplayFormat("dd.MM.yyyy")
+	birthDateEdit.SetCalendarPopup(true)
+	birthDateEdit.SetMaximumDate(today)
+
+	form := widgets.NewQFormLayout(nil)
+	form.AddRow3("Дата рождения:", birthDateEdit)
+
+	cancelButton := widgets.NewQPushButton2("Отмена", nil)
+	cancelButton.ConnectClicked(func(bool) {
+		dialog.Reject()
+	})
+	nextButton := widgets.NewQPushButton2("Далее", nil)
+	nextButton.SetDefault(true)
+	nextButton.ConnectClicked(func(bool) {
+		dialog.Accept()
+	})
+
+	buttonsLayout := widgets.NewQHBoxLayout()
+	buttonsLayout.AddStretch(1)
+	buttonsLayout.AddWidget(cancelButton, 0, 0)
+	buttonsLayout.AddWidget(nextButton, 0, 0)
+
+	layout := widgets.NewQVBoxLayout()
+	layout.AddWidget(textLabel, 0, 0)
+	layout.AddLayout(form, 0)
+	layout.AddStretch(1)
+	layout.AddLayout(buttonsLayout, 0)
+	dialog.SetLayout(layout)
+	dialog.Resize(core.NewQSize2(450, 200))
+
+	if dialog.Exec() != int(widgets.QDialog__Accepted) {
+		return false
+	}
+	date := birthDateEdit.Date()
+	birth := time.Date(date.Year(), time.Month(date.Month()), date.Day(), 0, 0, 0, 0, time.Local)
+	// Дата рождения нигде не сохраняется, в запись попадает только факт проверки
+	if gate.Age(birth, time.Now()) < config.Gate.MinAge {
+		log.Printf("Проверка возраста не пройдена, нужно %d+", config.Gate.MinAge)
+		displayFailure(failure.Cancelled, fmt.Sprintf("%s доступна только игрокам старше %d лет.", config.DesktopEntry.Name, config.Gate.MinAge))
+		return false
+	}
+	return true
+}
+
+// nextOccurrence возвращает ближайший после now момент с временем суток hour:minute
+func nextOccurrence(now time.Time, hour, minute int) time.Time {
+	at := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+	if !at.After(now) {
+		at = at.AddDate(0, 0, 1)
+	}
+	return at
+}
+
+// chooseStartTime спрашивает, в какое время начать установку
+func chooseStartTime() (time.Time, bool) {
+	dialog := widgets.NewQDialog(nil, 0)
+	dialog.SetWindowTitle("Установить позже")
+
+	textLabel := widgets.NewQLabel2("Скачивание и установка начнутся в выбранное время, например ночью, "+
+		"когда сеть свободна. Не закрывайте установщик и не переводите компьютер в спящий режим.", nil, 0)
+	textLabel.SetWordWrap(true)
+
+	timeEdit := widgets.NewQTimeEdit2(core.NewQTime3(2, 0, 0, 0), nil)
+	timeEdit.SetDisplayFormat("HH:mm")
+
+	form := widgets.NewQFormLayout(nil)
+	form.AddRow3("Начать в:", timeEdit)
+
+	buttons := widgets.NewQDialogButtonBox3(widgets.QDialogButtonBox__Ok|widgets.QDialogButtonBox__Cancel, nil)
+	buttons.ConnectAccepted(dialog.Accept)
+	buttons.ConnectRejected(dialog.Reject)
+
+	layout := widgets.NewQVBoxLayout()
+	layout.AddWidget(textLabel, 0, 0)
+	layout.AddLayout(form, 0)
+	layout.AddWidget(buttons, 0, 0)
+	dialog.SetLayout(layout)
+	dialog.Resize(core.NewQSize2(400, 150))
+
+	if dialog.Exec() != int(widgets.QDialog__Accepted) {
+		return time.Time{}, false
+	}
+	chosen := timeEdit.Time()
+	return nextOccurrence(time.Now(), chosen.Hour(), chosen.Minute()), true
+}
+
+// scheduleInstallation откладывает установку до времени at. Кнопка установки
+// в это время начинает ее сразу.
+func scheduleInstallation(at time.Time) {
+	scheduledAt = at
+	log.Printf("Установка отложена до %s", at.Format("02.01.2006 15:04"))
+	installButton.SetText("Начать сейчас")
+	scheduleButton.SetText("Отменить установку в " + at.Format("15:04"))
+}
+
+// cancelSchedule отменяет отложенную установку и ожидание безлимитного подключения
+func cancelSchedule() {
+	scheduledAt = time.Time{}
+	waitingUnmetered = false
+	waitingOnline = false
+	installButton.SetText("Начать установку")
+	scheduleButton.SetText("Установить позже…")
+}
+
+// checkSchedule начинает отложенную установку, когда подошло ее время или
+// подключение перестало быть лимитным. Время сверяется с часами, а не с
+// длительностью таймера: после сна компьютера таймер отстает.
+func checkSchedule() {
+	if waitingUnmetered {
+		if meteredDownload() {
+			return
+		}
+		log.Printf("Подключение больше не лимитное, установка начинается")
+		cancelSchedule()
+		startInstallation()
+		return
+	}
+	if scheduledAt.IsZero() || time.Now().Before(scheduledAt) {
+		return
+	}
+	log.Printf("Начинается отложенная установка")
+	cancelSchedule()
+	if needsInternet() && network.Offline() {
+		waitForOnline()
+		return
+	}
+	// Ночью спросить некого: по лимитному подключению установка ждет
+	if meteredDownload() {
+		waitForUnmetered()
+		return
+	}
+	// Вход сохранен, когда установку откладывали, если тогда была сеть
+	if authorizeDownloads() {
+		startInstallation()
+	}
+}
+
+// needsInternet сообщает, что файлы игры скачиваются из интернета, а не берутся
+// с диска, носителя или компьютера в локальной сети
+func needsInternet() bool {
+	if source.LANPeer == nil && source.Remote(config.SyncManifest) {
+		return true
+	}
+	for _, asset := range installAssets() {
+		if source.Remote(asset) && !source.FromLAN(asset) {
+			return true
+		}
+	}
+	return false
+}
+
+// downloadEstimateGB оценивает объем загрузки из сети; 0, если все файлы игры
+// берутся с диска или носителя
+func downloadEstimateGB() float64 {
+	if !needsInternet() {
+		return 0
+	}
+	if config.TempSpaceGB > 0 {
+		return config.TempSpaceGB
+	}
+	return config.MinRequiredSpaceGB
+}
+
+// meteredDownload сообщает, что предстоит большая загрузка по лимитному
+// подключению, например через точку доступа на телефоне
+func meteredDownload() bool {
+	if downloadEstimateGB() < meteredThresholdGB {
+		return false
+	}
+	metered, err := network.Metered()
+	if err != nil {
+		// Без NetworkManager о подключении ничего не известно
+		return false
+	}
+	return metered
+}
+
+// waitForOnline откладывает установку до подключения к интернету
+func waitForOnline() {
+	log.Printf("Нет подключения к интернету, установка ждет его")
+	waitingOnline = true
+	installButton.SetText("Начать сейчас")
+	scheduleButton.SetText("Не ждать подключения")
+	showConnectivity()
+}
+
+// checkConnectivity проверяет подключение к интернету и объясняет, что можно
+// сделать без него. Когда подключение появляется, начинается установка, которая
+// его ждала.
+func checkConnectivity() {
+	if now := network.Offline(); now != offline {
+		offline = now
+		if offline {
+			c, _ := network.Check()
+			log.Printf("Нет подключения к интернету: %s", c)
+		} else {
+			log.Printf("Подключение к интернету появилось")
+		}
+	}
+	showConnectivity()
+	if waitingOnline && !offline {
+		startAfterWaiting()
+	}
+}
+
+// startAfterWaiting начинает установку, которая ждала подключения. Вход нужен
+// только для загрузки, поэтому выполняется сейчас.
+func startAfterWaiting() {
+	cancelSchedule()
+	if authorizeDownloads() && confirmMetered() {
+		startInstallation()
+	}
+}
+
+// showConnectivity показывает, что будет с установкой без интернета, и выключает
+// языки, которые нельзя скачать
+func showConnectivity() {
+	internet := needsInternet()
+	if languageCombo != nil {
+		model := gui.NewQStandardItemModelFromPointer(languageCombo.Model().Pointer())
+		for i, pack := range config.Languages {
+			remote := false
+			for _, asset := range pack.Assets {
+				remote = remote || source.Remote(asset)
+			}
+			// Если без сети ждет вся игра, язык можно выбрать любой
+			if item := model.Item(i, 0); item != nil {
+				item.SetEnabled(!offline || !remote || internet)
+			}
+		}
+	}
+
+	if !offline {
+		offlineLabel.Hide()
+		return
+	}
+	c, _ := network.Check()
+	text := "Нет доступа к интернету: " + c.String() + "."
+	switch {
+	case waitingOnline:
+		text += " Установка начнется сама, когда подключение появится."
+	case internet:
+		text += " Файлы игры скачиваются из сети. Можно ответить на вопросы установщика сейчас, " +
+			"а скачивание начнется само, когда подключение появится."
+		if !lanCheckBox.IsChecked() {
+			text += " Если игра есть на другом компьютере в локальной сети, включите поиск файлов в локальной сети."
+		}
+	default:
+		text += " Файлы игры есть на этом компьютере, установка пройдет без сети."
+	}
+	offlineLabel.SetText(text)
+	offlineLabel.Show()
+}
+
+// fetchRetrying получает ресурс. Если загрузка не удалась, потому что пропал
+// интернет, вместо ошибки HTTP вызывается wait, а после подключения загрузка
+// повторяется.
+func fetchRetrying(ctx context.Context, ref string, progress source.Progress, wait func(context.Context) error) (string, error) {
+	for {
+		path, err := source.Fetch(ctx, ref, progress)
+		if err == nil || ctx.Err() != nil || !source.Remote(ref) || !network.Offline() {
+			return path, err
+		}
+		log.Printf("Загрузка %s прервана, нет доступа к интернету: %v", ref, err)
+		if err := wait(ctx); err != nil {
+			return "", err
+		}
+		log.Printf("Подключение появилось, загрузка %s повторяется", ref)
+	}
+}
+
+// waitForUnmetered откладывает установку до безлимитного подключения
+func waitForUnmetered() {
+	log.Printf("Подключение лимитное, установка ждет безлимитного")
+	waitingUnmetered = true
+	installButton.SetText("Начать сейчас")
+	scheduleButton.SetText("Не ждать безлимитного подключения")
+}
+
+// confirmMetered спрашивает, скачивать ли игру по лимитному подключению, или,
+// если так выбрано в настройках, сразу откладывает установку. Возвращает true,
+// если установку можно начинать.
+func confirmMetered() bool {
+	if !meteredDownload() {
+		return true
+	}
+	if userSettings.Metered == settings.MeteredDefer {
+		waitForUnmetered()
+		return false
+	}
+	if answers != nil {
+		log.Printf("Подключение лимитное, автоматическая установка продолжается")
+		return true
+	}
+
+	msgBox := widgets.NewQMessageBox(nil)
+	msgBox.SetWindowTitle("Лимитное подключение")
+	msgBox.SetIcon(widgets.QMessageBox__Warning)
+	msgBox.SetText(fmt.Sprintf("Подключение к сети лимитное, например точка доступа на телефоне. "+
+		"Для установки нужно скачать около %.1f ГБ.", downloadEstimateGB()))
+	downloadButton := msgBox.AddButton2("Скачать сейчас", widgets.QMessageBox__AcceptRole)
+	waitButton := msgBox.AddButton2("Дождаться безлимитного", widgets.QMessageBox__ActionRole)
+	msgBox.AddButton3(widgets.QMessageBox__Cancel)
+	msgBox.Exec()
+
+	switch msgBox.ClickedButton().Pointer() {
+	case downloadButton.Pointer():
+		recordChoice("лимитное подключение", "скачать сейчас")
+		return true
+	case waitButton.Pointer():
+		recordChoice("лимитное подключение", "дождаться безлимитного")
+		waitForUnmetered()
+		return false
+	}
+	recordChoice("лимитное подключение", "отмена")
+	return false
+}
+
+// acceptEULA показывает лицензионное соглашение из конфигурации. Возвращает true,
+// если соглашения нет или пользователь его принял.
+func acceptEULA() bool {
+	if config.EULA == "" {
+		return true
+	}
+	text, err := ioutil.ReadFile(config.EULA)
+	if err != nil {
+		displayFailure(failure.Config, "Не удалось прочитать лицензионное соглашение: "+err.Error())
+		return false
+	}
+
+	dialog := widgets.NewQDialog(nil, 0)
+	dialog.SetWindowTitle("Лицензионное соглашение")
+
+	textEdit := widgets.NewQPlainTextEdit(nil)
+	textEdit.SetReadOnly(true)
+	textEdit.SetPlainText(string(text))
+
+	acceptCheckBox := widgets.NewQCheckBox2("Я принимаю условия лицензионного соглашения", nil)
+
+	cancelButton := widgets.NewQPushButton2("Отмена", nil)
+	cancelButton.ConnectClicked(func(bool) {
+		dialog.Reject()
+	})
+
+	nextButton := widgets.NewQPushButton2("Далее", nil)
+	nextButton.SetEnabled(false)
+	nextButton.ConnectClicked(func(bool) {
+		dialog.Accept()
+	})
+	acceptCheckBox.ConnectToggled(nextButton.SetEnabled)
+
+	buttonsLayout := widgets.NewQHBoxLayout()
+	buttonsLayout.AddStretch(1)
+	buttonsLayout.AddWidget(cancelButton, 0, 0)
+	buttonsLayout.AddWidget(nextButton, 0, 0)
+
+	layout := widgets.NewQVBoxLayout()
+	layout.AddWidget(textEdit, 1, 0)
+	layout.AddWidget(acceptCheckBox, 0, 0)
+	layout.AddLayout(buttonsLayout, 0)
+	dialog.SetLayout(layout)
+	dialog.Resize(core.NewQSize2(600, 450))
+
+	accepted := dialog.Exec() == int(widgets.QDialog__Accepted)
+	recordChoice("лицензионное соглашение принято", yesNo(accepted))
+	return accepted
+}
+
+// runWizardPages проводит пользователя по дополнительным страницам из конфигурации.
+// Возвращает false, если пользователь отменил установку.
+func runWizardPages() bool {
+	for i := 0; i < len(config.Pages); {
+		switch showWizardPage(config.Pages[i], i) {
+		case pageNext:
+			i++
+		case pageBack:
+			i--
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// expandTemplate подставляет значения полей вместо {{id}}. Секреты не подставляются:
+// результат попадает в ярлыки и запись об установке.
+func expandTemplate(text string) string {
+	for id, value := range publicValues() {
+		text = strings.ReplaceAll(text, "{{"+id+"}}", value)
+	}
+	return text
+}
+
+// secretFields возвращает поля дополнительных страниц с типом secret
+func secretFields() []WizardField {
+	var fields []WizardField
+	for _, page := range config.Pages {
+		for _, field := range page.Fields {
+			if field.Type == "secret" {
+				fields = append(fields, field)
+			}
+		}
+	}
+	return fields
+}
+
+// publicValues возвращает значения полей без секретов: только они попадают в файл
+// настроек игры и в запись об установке
+func publicValues() map[string]string {
+	values := make(map[string]string, len(pageValues))
+	for id, value := range pageValues {
+		values[id] = value
+	}
+	for _, field := range secretFields() {
+		delete(values, field.ID)
+	}
+	return values
+}
+
+// storeSecrets сохраняет секреты игры в связке ключей и запоминает их ключи в записи
+// об установке. Игра читает секрет сама: secret-tool lookup service go-qt-installer
+// key "<slug> <id>". Без связки ключей секрет не сохраняется нигде.
+func storeSecrets() error {
+	var failed []string
+	store := func(name, label, secret string) {
+		key := common.SecretKey(installInfo.Slug, name)
+		if err := keyring.Set(key, label, secret); err != nil {
+			log.Printf("Секрет %s не сохранен: %v", key, err)
+			failed = append(failed, label)
+			return
+		}
+		installInfo.Secrets = append(installInfo.Secrets, key)
+	}
+
+	if installInfo.InstallToken != "" {
+		store(common.InstallTokenSecret, "Токен установки "+config.DesktopEntry.Name, installInfo.InstallToken)
+	}
+	for _, field := range secretFields() {
+		if value := pageValues[field.ID]; value != "" {
+			store(field.ID, config.DesktopEntry.Name+": "+field.Label, value)
+		}
+	}
+	// Менеджер отправляет сохранения в облако перед удалением игры
+	if config.CloudSaves.Enabled() {
+		if secret := cloudSaveConfig().Secret(); secret != "" {
+			store(common.CloudSaveSecret, "Облачные сохранения "+config.DesktopEntry.Name, secret)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("связка ключей недоступна, не сохранено: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// cloudSaveConfig возвращает подключение к облачным сохранениям с подставленными
+// полями. В ключ или пароль подставляются и поля secret.
+func cloudSaveConfig() cloudsave.Config {
+	c := config.CloudSaves
+	c.Prefix = expandTemplate(c.Prefix)
+	c.Username = expandTemplate(c.Username)
+	c.AccessKey = expandTemplate(c.AccessKey)
+	secret := c.Secret()
+	for id, value := range pageValues {
+		secret = strings.ReplaceAll(secret, "{{"+id+"}}", value)
+	}
+	c.Config = c.Config.WithSecret(secret)
+	return c
+}
+
+// pullSaves забирает сохранения из облака после установки. Если сохранения
+// изменились и здесь, и в облаке, выбор делает resolve: true — взять облачные,
+// тогда локальные упаковываются в logs/cloud-saves.
+func pullSaves(ctx context.Context, previous *InstallInfo, resolve func(remote *cloudsave.Manifest) bool) error {
+	c := cloudSaveConfig()
+	// Секрет хранится в связке ключей, в запись попадает подключение без него
+	installInfo.CloudSave = &cloudsave.Record{Config: c}
+	installInfo.CloudSave.Config.Config = c.Config.WithSecret("")
+	if previous != nil && previous.CloudSave != nil {
+		installInfo.CloudSave.Hash = previous.CloudSave.Hash
+	}
+
+	session, err := cloudsave.New(c, installInfo.Slug, config.InstallPath)
+	if err != nil {
+		return err
+	}
+	local, err := session.LocalHash()
+	if err != nil {
+		return err
+	}
+	remote, err := session.Remote(ctx)
+	if err != nil {
+		return err
+	}
+	remoteHash := ""
+	if remote != nil {
+		remoteHash = remote.Hash
+	}
+
+	last := installInfo.CloudSave.Hash
+	switch cloudsave.Compare(local, remoteHash, last) {
+	case cloudsave.InSync:
+		last = local
+	case cloudsave.LocalNewer:
+		// Локальные сохранения уйдут в облако перед удалением игры
+		log.Printf("Сохранения на этом компьютере новее облачных")
+	case cloudsave.RemoteNewer:
+		if err := session.Pull(ctx, remote); err != nil {
+			return err
+		}
+		log.Printf("Сохранения восстановлены из облака (%s, %s)", remote.Host, remote.UpdatedAt.Format(time.RFC3339))
+		last = remote.Hash
+	case cloudsave.Conflict:
+		if !resolve(remote) {
+			// Облачные сохранения будут заменены локальными перед удалением игры
+			log.Printf("Конфликт сохранений: оставлены сохранения с этого компьютера")
+			last = remote.Hash
+			break
+		}
+		backupPath, err := session.Backup(filepath.Join(config.InstallPath, "logs", "cloud-saves"))
+		if err != nil {
+			return fmt.Errorf("не удалось сохранить локальные сохранения перед заменой: %v", err)
+		}
+		if err := session.Pull(ctx, remote); err != nil {
+			return err
+		}
+		log.Printf("Конфликт сохранений: взяты облачные, прежние сохранены в %s", backupPath)
+		last = remote.Hash
+	}
+	installInfo.CloudSave.Hash = last
+	installInfo.CloudSave.SyncedAt = time.Now()
+	return nil
+}
+
+// chooseCloudSaves спрашивает, какие сохранения оставить при конфликте. Автоматическая
+// установка ничего не заменяет.
+func chooseCloudSaves(remote *cloudsave.Manifest) bool {
+	if answers != nil {
+		return false
+	}
+	msgBox := widgets.NewQMessageBox(nil)
+	msgBox.SetIcon(widgets.QMessageBox__Question)
+	msgBox.SetWindowTitle("Облачные сохранения")
+	msgBox.SetText(fmt.Sprintf("В облаке есть сохранения с компьютера %s от %s, а на этом компьютере — другие. "+
+		"Какие сохранения оставить?", remote.Host, remote.UpdatedAt.Local().Format("02.01.2006 15:04")))
+	msgBox.SetInformativeText("Сохранения с этого компьютера перед заменой будут упакованы в директорию logs игры. " +
+		"Если оставить их, облачные будут заменены при удалении игры.")
+	cloudButton := msgBox.AddButton2("Из облака", widgets.QMessageBox__AcceptRole)
+	msgBox.AddButton2("С этого компьютера", widgets.QMessageBox__RejectRole)
+	msgBox.SetDefaultButton(cloudButton)
+	msgBox.Exec()
+	return msgBox.ClickedButton().Pointer() == cloudButton.Pointer()
+}
+
+// saveOptionsFile записывает значения полей в файл, указанный в конфигурации
+func saveOptionsFile() error {
+	values := publicValues()
+	if config.OptionsFile == "" || len(values) == 0 {
+		return nil
+	}
+	optionsPath := filepath.Join(config.InstallPath, config.OptionsFile)
+	if engine.CheckEntryName(config.InstallPath, config.OptionsFile) != "" {
+		return fmt.Errorf("файл настроек %s находится вне директории установки", config.OptionsFile)
+	}
+
+	data, err := json.MarshalIndent(values, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(optionsPath), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(optionsPath, data, 0644)
+}
+
+// selectedLanguage — код языкового пакета, выбранного пользователем
+var selectedLanguage string
+
+// installAssets возвращает ресурсы для установки: общие и выбранного языкового пакета
+func installAssets() []string {
+	assets := append([]string(nil), config.GameAssets...)
+	for _, pack := range config.Languages {
+		if pack.Code == selectedLanguage {
+			assets = append(assets, pack.Assets...)
+		}
+	}
+	return assets
+}
+
+// allAssets возвращает ресурсы игры вместе со всеми языковыми пакетами
+func allAssets() []string {
+	assets := append([]string(nil), config.GameAssets...)
+	for _, pack := range config.Languages {
+		assets = append(assets, pack.Assets...)
+	}
+	return assets
+}
+
+// defaultLanguage выбирает пакет по языку из настроек или языку системы, иначе первый
+// из конфигурации
+func defaultLanguage() string {
+	for _, pack := range config.Languages {
+		if answers != nil && strings.EqualFold(pack.Code, answers.Language) {
+			return pack.Code
+		}
+	}
+	for _, pack := range config.Languages {
+		if userSettings.Language != "" && strings.EqualFold(pack.Code, userSettings.Language) {
+			return pack.Code
+		}
+	}
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		locale := os.Getenv(env)
+		if locale == "" {
+			continue
+		}
+		code := strings.ToLower(strings.SplitN(strings.SplitN(locale, ".", 2)[0], "_", 2)[0])
+		for _, pack := range config.Languages {
+			if strings.ToLower(pack.Code) == code {
+				return pack.Code
+			}
+		}
+		break
+	}
+	if len(config.Languages) > 0 {
+		return config.Languages[0].Code
+	}
+	return ""
+}
+
+// beginPhase отмечает начало этапа установки в профиле и в событиях установки и
+// озвучивает его
+func beginPhase(name string) func() {
+	progressVoice.Phase(name)
+	installEvents.Add(timeline.PhaseStart, name, "")
+	started := time.Now()
+	end := profiler.Phase(name)
+	return func() {
+		end()
+		installEvents.Add(timeline.PhaseEnd, name, time.Since(started).Round(time.Millisecond).String())
+	}
+}
+
+// recordChoice записывает решение игрока в события установки
+func recordChoice(name, value string) {
+	log.Printf("Выбор: %s: %s", name, value)
+	installEvents.Add(timeline.Choice, name, value)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "да"
+	}
+	return "нет"
+}
+
+// maintenanceConfig — обслуживание игры из менеджера. Файлы языка и значений со
+// страниц установщика — тоже настройки первого запуска.
+func maintenanceConfig() maintenance.Config {
+	c := config.Maintenance
+	c.ConfigFiles = append([]string(nil), c.ConfigFiles...)
+	for _, file := range []string{config.LanguageFile, config.OptionsFile} {
+		if file != "" {
+			c.ConfigFiles = append(c.ConfigFiles, filepath.ToSlash(file))
+		}
+	}
+	return c
+}
+
+// saveLanguageFile записывает выбранный язык в файл настроек первого запуска игры
+func saveLanguageFile() error {
+	if config.LanguageFile == "" || selectedLanguage == "" {
+		return nil
+	}
+	if engine.CheckEntryName(config.InstallPath, config.LanguageFile) != "" {
+		return fmt.Errorf("файл языка %s находится вне директории установки", config.LanguageFile)
+	}
+
+	template := config.LanguageTemplate
+	if template == "" {
+		template = "language={{language}}\n"
+	}
+	languagePath := filepath.Join(config.InstallPath, config.LanguageFile)
+	if err := os.MkdirAll(filepath.Dir(languagePath), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(languagePath, []byte(expandTemplate(template)), 0644)
+}
+
+// fetchAssets получает ресурсы assets и возвращает их локальные пути.
+// Загрузка идет в потоке интерфейса, поэтому события обрабатываются из обратного вызова прогресса.
+func fetchAssets(assets []string) (map[string]string, error) {
+	paths := make(map[string]string)
+	for _, asset := range assets {
+		src := source.Find(asset)
+		progress := func(done, total int64) {
+			if total > 0 {
+				progressBar.SetRange(0, int(total/1024))
+				progressBar.SetValue(int(done / 1024))
+			} else {
+				progressBar.SetRange(0, 0)
+			}
+			progressBar.SetFormat(fmt.Sprintf("%s: %.1f МБ", filepath.Base(asset), float64(done)/1024/1024))
+			core.QCoreApplication_ProcessEvents(core.QEventLoop__AllEvents)
+		}
+
+		progressBar.Show()
+		log.Printf("Получение %s (%s)", asset, src.Name())
+		path, err := fetchRetrying(context.Background(), asset, progress, func(ctx context.Context) error {
+			progressBar.SetRange(0, 0)
+			progressBar.SetFormat("Нет доступа к интернету, загрузка продолжится, когда подключение появится")
+			progressVoice.Say("Нет доступа к интернету, загрузка продолжится, когда подключение появится")
+			for network.Offline() {
+				for i := 0; i < 50; i++ {
+					core.QCoreApplication_ProcessEvents(core.QEventLoop__AllEvents)
+					time.Sleep(100 * time.Millisecond)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		paths[asset] = path
+	}
+	return paths, nil
+}
+
+// pipelineProgress — общий прогресс установки, когда архивы скачиваются во время
+// распаковки предыдущих. Загрузка и распаковка учитываются в байтах. Пока архив
+// не скачан и не открыт, объем его распаковки принимается равным размеру загрузки.
+type pipelineProgress struct {
+	mu         sync.Mutex
+	pending    []string            // Ресурсы, скачиваемые по ходу установки
+	downloaded map[string][2]int64 // Получено и всего байт по ресурсу
+	unpacked   map[string]int64    // Объем распаковки открытых архивов
+	extracted  int64
+	files      int
+	totalFiles int
+}
+
+func newPipelineProgress(pending []string) *pipelineProgress {
+	return &pipelineProgress{
+		pending:    pending,
+		downloaded: make(map[string][2]int64),
+		unpacked:   make(map[string]int64),
+	}
+}
+
+// download учитывает ход загрузки ресурса
+func (p *pipelineProgress) download(asset string, done, total int64) {
+	p.mu.Lock()
+	p.downloaded[asset] = [2]int64{done, total}
+	p.mu.Unlock()
+}
+
+// open учитывает открытый архив
+func (p *pipelineProgress) open(asset string, size int64, files int) {
+	p.mu.Lock()
+	p.unpacked[asset] = size
+	p.totalFiles += files
+	p.mu.Unlock()
+}
+
+// extract учитывает распакованный файл
+func (p *pipelineProgress) extract(size int64) {
+	p.mu.Lock()
+	p.extracted += size
+	p.files++
+	p.mu.Unlock()
+}
+
+// state возвращает долю выполненной работы и число распакованных и известных файлов
+func (p *pipelineProgress) state() (float64, int, int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Размер еще не начатых загрузок оцениваем по среднему из известных
+	var known, count int64
+	for _, d := range p.downloaded {
+		if d[1] > 0 {
+			known += d[1]
+			count++
+		}
+	}
+	var average int64
+	if count > 0 {
+		average = known / count
+	}
+
+	done := p.extracted
+	var total int64
+	for _, size := range p.unpacked {
+		total += size
+	}
+	for _, asset := range p.pending {
+		d, started := p.downloaded[asset]
+		size := d[1]
+		if !started || size <= 0 {
+			size = average
+		}
+		done += d[0]
+		total += size
+		if _, opened := p.unpacked[asset]; !opened {
+			total += size
+		}
+	}
+	if total <= 0 {
+		return 0, p.files, p.totalFiles
+	}
+	return min(1, float64(done)/float64(total)), p.files, p.totalFiles
+}
+
+// showPipelineProgress показывает общий прогресс загрузки и распаковки
+func showPipelineProgress(p *pipelineProgress, tracker *estimate.Tracker, downloading string) {
+	fraction, files, totalFiles := p.state()
+	progressBar.SetValue(int(fraction * 1000))
+	text := fmt.Sprintf("%d%% (%d/%d)", int(fraction*100), files, totalFiles)
+	if left, ok := tracker.Remaining(); ok {
+		text += ", осталось " + formatDuration(left)
+	}
+	if downloading != "" {
+		text += ", загрузка " + downloading
+	}
+	progressBar.SetFormat(text)
+}
+
+// fetchResult — скачанный по ходу установки ресурс
+type fetchResult struct {
+	asset string
+	path  string
+	err   error
+}
+
+// fetchPipelined скачивает ресурсы по очереди в отдельной горутине. Следующий ресурс
+// скачивается, пока распаковывается предыдущий, но не дальше чем на один вперед:
+// готовый результат ждет, пока распаковка его заберет.
+func fetchPipelined(ctx context.Context, assets []string, progress *pipelineProgress, notify chan<- string) <-chan fetchResult {
+	results := make(chan fetchResult)
+	go func() {
+		defer close(results)
+		for _, asset := range assets {
+			var last time.Time
+			report := func(done, total int64) {
+				progress.download(asset, done, total)
+				// Не чаще десяти раз в секунду, чтобы не загружать интерфейс
+				if time.Since(last) >= 100*time.Millisecond {
+					last = time.Now()
+					select {
+					case notify <- filepath.Base(asset):
+					default:
+					}
+				}
+			}
+			log.Printf("Получение %s (%s) во время распаковки", asset, source.Find(asset).Name())
+			path, err := fetchRetrying(ctx, asset, report, func(ctx context.Context) error {
+				select {
+				case notify <- filepath.Base(asset) + " приостановлена: нет доступа к интернету":
+				case <-ctx.Done():
+				}
+				return network.WaitOnline(ctx)
+			})
+			select {
+			case results <- fetchResult{asset: asset, path: path, err: err}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return results
+}
+
+// countEntries возвращает объем и число записей архива без записей из карантина
+func countEntries(asset string, list []archive.Entry, quarantined map[string]bool) (int64, int) {
+	var size int64
+	files := 0
+	for _, e := range list {
+		if quarantined[asset+"\x00"+e.Name] {
+			continue
+		}
+		size += e.Size
+		files++
+	}
+	return size, files
+}
+
+// openFetched открывает скачанный по ходу установки архив и проверяет его записи.
+// Архив с опасными записями пропускается, если они не разрешены в конфигурации,
+// иначе такие записи попадают в карантин.
+func openFetched(result fetchResult, entries map[string][]archive.Entry, quarantined map[string]bool) (archive.Extractor, error) {
+	if result.err != nil {
+		return nil, fmt.Errorf("не удалось получить %s: %v", filepath.Base(result.asset), result.err)
+	}
+	ext, err := archive.Open(result.path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при открытии архива: %v", err)
+	}
+	list, err := ext.Enumerate()
+	if err != nil {
+		ext.Close()
+		return nil, fmt.Errorf("ошибка при открытии архива: %v", err)
+	}
+	entries[result.asset] = list
+
+	unsafe := scanUnsafeEntries(map[string][]archive.Entry{result.asset: list})
+	if len(unsafe) > 0 && !config.AllowUnsafeEntries {
+		ext.Close()
+		return nil, fmt.Errorf("архив %s содержит записи за пределами директории установки (%d) и пропущен",
+			filepath.Base(result.asset), len(unsafe))
+	}
+	for _, e := range unsafe {
+		log.Printf("Запись помещена в карантин: %s: %s (%s)", e.Archive, e.Name, e.Reason)
+		quarantined[e.Archive+"\x00"+e.Name] = true
+	}
+	return ext, nil
+}
+
+// mediaNumber возвращает номер носителя по порядку его первого упоминания в ресурсах игры
+func mediaNumber(label string) int {
+	seen := make(map[string]bool)
+	for _, asset := range allAssets() {
+		l, ok := source.MediaLabel(asset)
+		if !ok || seen[l] {
+			continue
+		}
+		seen[l] = true
+		if l == label {
+			return len(seen)
+		}
+	}
+	return 0
+}
+
+// waitForMedia просит вставить носитель с нужной меткой и ждет его появления.
+// Вставленный носитель монтируется через udisks2, и окно закрывается само.
+func waitForMedia(label string) bool {
+	dialog := widgets.NewQDialog(nil, 0)
+	dialog.SetWindowTitle("Смена носителя")
+
+	text := fmt.Sprintf("Вставьте носитель «%s».", label)
+	if n := mediaNumber(label); n > 0 {
+		text = fmt.Sprintf("Вставьте носитель %d («%s»).", n, label)
+	}
+	textLabel := widgets.NewQLabel2(text, nil, 0)
+	statusLabel := widgets.NewQLabel2("Ожидание носителя...", nil, 0)
+
+	cancelButton := widgets.NewQPushButton2("Отмена", nil)
+	cancelButton.ConnectClicked(func(bool) {
+		dialog.Reject()
+	})
+
+	// Проверяем приводы раз в секунду, смонтировать пробуем один раз на каждую вставку
+	mountTried := false
+	timer := core.NewQTimer(dialog)
+	timer.ConnectTimeout(func() {
+		if source.FindMedia(label) != "" {
+			dialog.Accept()
+			return
+		}
+		if !source.MediaInserted(label) {
+			mountTried = false
+			statusLabel.SetText("Ожидание носителя...")
+			return
+		}
+		if mountTried {
+			return
+		}
+		mountTried = true
+		statusLabel.SetText("Носитель обнаружен, подключение...")
+		if err := source.MountMedia(label); err != nil {
+			log.Printf("Ошибка подключения носителя %s: %v", label, err)
+			statusLabel.SetText("Не удалось подключить носитель: " + err.Error())
+		}
+	})
+	timer.Start(1000)
+
+	layout := widgets.NewQVBoxLayout()
+	layout.AddWidget(textLabel, 0, 0)
+	layout.AddWidget(statusLabel, 0, 0)
+	layout.AddWidget(cancelButton, 0, 0)
+	dialog.SetLayout(layout)
+
+	result := dialog.Exec() == int(widgets.QDialog__Accepted)
+	timer.Stop()
+	return result
+}
+
+// loginResult — чем закончился вход в горутине
+type loginResult struct {
+	token *auth.Token
+	err   error
+}
+
+// authorizeDownloads выполняет вход, если CDN издателя требует токен, проверяет,
+// что игра куплена, и передает токен источнику загрузок. Вход запоминается
+// в связке ключей, поэтому при следующей установке окно входа не появится.
+// Возвращает false, если пользователь отменил вход или вход не удался.
+func authorizeDownloads() bool {
+	if config.Auth.Type == "" {
+		return true
+	}
+	remote := false
+	for _, asset := range installAssets() {
+		remote = remote || source.Remote(asset)
+	}
+	if !remote {
+		return true
+	}
+	provider, err := auth.New(config.Auth)
+	if err != nil {
+		displayFailure(failure.Config, err.Error())
+		return false
+	}
+
+	token := restoreLogin()
+	if token == nil {
+		if token = showLoginDialog(provider); token == nil {
+			return false
+		}
+		saveLogin(token)
+	}
+	source.Authorize = config.Auth.Attach(token.AccessToken)
+	log.Printf("Вход выполнен, токен добавляется к загрузкам")
+	return true
+}
+
+// loginKey — ключ токена обновления в связке ключей: один вход на сервер входа
+func loginKey() string {
+	return "login " + config.Auth.TokenEndpoint + " " + config.Auth.ClientID
+}
+
+// saveLogin запоминает токен обновления в связке ключей. Без связки ключей вход
+// просто не запоминается: хранить токен открытым текстом нельзя.
+func saveLogin(token *auth.Token) {
+	if token.RefreshToken == "" {
+		return
+	}
+	label := "Вход для загрузки " + config.DesktopEntry.Name
+	if err := keyring.Set(loginKey(), label, token.RefreshToken); err != nil {
+		log.Printf("Вход не запомнен: %v", err)
+	}
+}
+
+// restoreLogin обновляет запомненный вход и проверяет покупку игры. Возвращает nil,
+// если войти нужно заново.
+func restoreLogin() *auth.Token {
+	refreshToken, err := keyring.Get(loginKey())
+	if err != nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	token, err := auth.Refresh(ctx, config.Auth, refreshToken)
+	if err != nil {
+		log.Printf("Запомненный вход недействителен: %v", err)
+		keyring.Delete(loginKey())
+		return nil
+	}
+	if err := auth.CheckEntitlement(ctx, config.Auth, token.AccessToken); err != nil {
+		log.Printf("Проверка покупки по запомненному входу: %v", err)
+		return nil
+	}
+	saveLogin(token)
+	return token
+}
+
+// showLoginDialog показывает страницу входа в учетную запись издателя: форму имени
+// и пароля или код для входа с другого устройства. После входа проверяет, что игра
+// куплена. Возвращает nil, если пользователь закрыл окно.
+func showLoginDialog(provider auth.Provider) *auth.Token {
+	dialog := widgets.NewQDialog(nil, 0)
+	dialog.SetWindowTitle("Вход в учетную запись")
+
+	textLabel := widgets.NewQLabel2("Подключение к серверу входа...", nil, 0)
+	textLabel.SetWordWrap(true)
+	textLabel.SetTextFormat(core.Qt__RichText)
+	textLabel.SetOpenExternalLinks(true)
+	codeLabel := widgets.NewQLabel2("", nil, 0)
+	codeLabel.SetAlignment(core.Qt__AlignCenter)
+	codeLabel.Hide()
+	qrLabel := widgets.NewQLabel2("", nil, 0)
+	qrLabel.SetAlignment(core.Qt__AlignCenter)
+	qrLabel.Hide()
+
+	cancelButton := widgets.NewQPushButton2("Отмена", nil)
+	cancelButton.ConnectClicked(func(bool) {
+		dialog.Reject()
+	})
+
+	// Вход идет в горутине, окно забирает ее сообщения по таймеру
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	prompts := make(chan auth.Prompt, 1)
+	results := make(chan loginResult, 1)
+	login := func(run func() (*auth.Token, error)) {
+		go func() {
+			token, err := run()
+			if err == nil {
+				err = auth.CheckEntitlement(ctx, config.Auth, token.AccessToken)
+			}
+			results <- loginResult{token, err}
+		}()
+	}
+
+	layout := widgets.NewQVBoxLayout()
+	layout.AddWidget(textLabel, 0, 0)
+
+	var loginButton *widgets.QPushButton
+	if passwords, ok := provider.(auth.PasswordProvider); ok {
+		textLabel.SetText("Войдите в учетную запись, в которой куплена игра.")
+		username := widgets.NewQLineEdit(nil)
+		password := widgets.NewQLineEdit(nil)
+		password.SetEchoMode(widgets.QLineEdit__Password)
+		form := widgets.NewQFormLayout(nil)
+		form.AddRow3("Имя пользователя:", username)
+		form.AddRow3("Пароль:", password)
+		layout.AddLayout(form, 0)
+
+		loginButton = widgets.NewQPushButton2("Войти", nil)
+		loginButton.SetDefault(true)
+		loginButton.ConnectClicked(func(bool) {
+			loginButton.SetEnabled(false)
+			textLabel.SetText("Вход...")
+			user, pass := username.Text(), password.Text()
+			login(func() (*auth.Token, error) { return passwords.LoginPassword(ctx, user, pass) })
+		})
+		layout.AddWidget(loginButton, 0, 0)
+	} else {
+		login(func() (*auth.Token, error) {
+			return provider.Login(ctx, func(p auth.Prompt) { prompts <- p })
+		})
+	}
+
+	var token *auth.Token
+	timer := core.NewQTimer(dialog)
+	timer.ConnectTimeout(func() {
+		select {
+		case p := <-prompts:
+			link := p.VerificationURI
+			textLabel.SetText(fmt.Sprintf("Чтобы скачать игру, войдите в учетную запись: откройте "+
+				"<a href=\"%s\">%s</a> и введите код ниже или отсканируйте QR-код телефоном.",
+				html.EscapeString(link), html.EscapeString(link)))
+			codeLabel.SetText("<span style=\"font-size: 20pt; font-weight: bold\">" + html.EscapeString(p.UserCode) + "</span>")
+			codeLabel.Show()
+			if p.VerificationURIComplete != "" {
+				link = p.VerificationURIComplete
+			}
+			if code, err := qr.Encode(link); err == nil {
+				qrLabel.SetPixmap(qrPixmap(code))
+				qrLabel.Show()
+			}
+		case result := <-results:
+			if result.err != nil {
+				log.Printf("Ошибка входа: %v", result.err)
+				textLabel.SetText(html.EscapeString(result.err.Error()))
+				codeLabel.Hide()
+				qrLabel.Hide()
+				if loginButton != nil {
+					// Можно исправить имя или пароль и попробовать еще раз
+					loginButton.SetEnabled(true)
+				} else {
+					cancelButton.SetText("Закрыть")
+				}
+				return
+			}
+			token = result.token
+			dialog.Accept()
+		default:
+		}
+	})
+	timer.Start(200)
+
+	layout.AddWidget(codeLabel, 0, 0)
+	layout.AddWidget(qrLabel, 0, 0)
+	layout.AddWidget(cancelButton, 0, 0)
+	dialog.SetLayout(layout)
+	dialog.Resize(core.NewQSize2(420, 0))
+
+	accepted := dialog.Exec() == int(widgets.QDialog__Accepted)
+	timer.Stop()
+	if !accepted {
+		return nil
+	}
+	return token
+}
+
+// choosePeer ищет в локальной сети компьютеры, раздающие эту игру, и предлагает
+// скачать файлы у одного из них. Отказ или ошибка не мешают обычной установке.
+func choosePeer() {
+	source.LANPeer = nil
+
+	// Файл с другого компьютера принимается только с контрольной суммой из конфигурации
+	var unverified []string
+	for _, asset := range installAssets() {
+		if config.AssetHashes[asset] == "" {
+			unverified = append(unverified, asset)
+		}
+	}
+	if len(unverified) == len(installAssets()) {
+		widgets.QMessageBox_Warning(nil, "Локальная сеть",
+			"В конфигурации игры нет контрольных сумм файлов (asset_hashes), поэтому получать их из локальной сети небезопасно. Файлы будут скачаны из исходных источников.",
+			widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+		return
+	}
+	if len(unverified) > 0 {
+		log.Printf("Без контрольной суммы, будут получены из исходных источников: %s", strings.Join(unverified, ", "))
+	}
+
+	progressBar.Show()
+	progressBar.SetRange(0, 0)
+	progressBar.SetFormat("Поиск в локальной сети...")
+	core.QCoreApplication_ProcessEvents(core.QEventLoop__AllEvents)
+
+	found, err := lanshare.Discover(context.Background(), 2*time.Second)
+	progressBar.SetRange(0, 1)
+	progressBar.SetFormat("")
+	if err != nil {
+		log.Printf("Ошибка поиска в локальной сети: %v", err)
+		return
+	}
+
+	var peers []*lanshare.Peer
+	items := []string{"Не использовать"}
+	for _, p := range found {
+		if p.Game == config.DesktopEntry.Name && p.Version == config.Version {
+			peers = append(peers, p)
+			items = append(items, fmt.Sprintf("%s — %s", p.Instance, p.Addr))
+		}
+	}
+	if len(peers) == 0 {
+		log.Printf("В локальной сети нет компьютеров, раздающих игру")
+		return
+	}
+
+	ok := false
+	choice := widgets.QInputDialog_GetItem(nil, "Локальная сеть",
+		"Найдены компьютеры с файлами игры. Скачать у:", items, 1, false, &ok, 0, 0)
+	if !ok {
+		return
+	}
+	var peer *lanshare.Peer
+	for i, item := range items[1:] {
+		if item == choice {
+			peer = peers[i]
+		}
+	}
+	if peer == nil {
+		return
+	}
+
+	for {
+		ok = false
+		peer.Token = widgets.QInputDialog_GetText(nil, "Локальная сеть",
+			"Введите код доступа, показанный на компьютере "+peer.Instance+":",
+			widgets.QLineEdit__Normal, "", &ok, 0, 0)
+		if !ok {
+			return
+		}
+		err := peer.Connect(context.Background())
+		if err == nil {
+			source.LANPeer = peer
+			log.Printf("Файлы игры будут получены с %s (%s)", peer.Instance, peer.Addr)
+			return
+		}
+		widgets.QMessageBox_Warning(nil, "Предупреждение", "Не удалось подключиться: "+err.Error(),
+			widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+	}
+}
+
+// shareAssets раздает файлы игры другим установщикам в локальной сети, пока открыто окно
+func shareAssets() {
+	// Раздаем все языковые пакеты: на другом компьютере могут выбрать другой язык
+	paths, err := fetchAssets(allAssets())
+	if err != nil {
+		source.Cleanup()
+		displayError("Не удалось получить файлы игры: " + err.Error())
+		return
+	}
+	defer source.Cleanup()
+
+	server, err := lanshare.Serve(config.DesktopEntry.Name, config.Version, paths)
+	if err != nil {
+		displayError("Не удалось начать раздачу: " + err.Error())
+		return
+	}
+	defer server.Close()
+	progressBar.Hide()
+
+	dialog := widgets.NewQDialog(nil, 0)
+	dialog.SetWindowTitle("Раздача по сети")
+
+	infoText := widgets.NewQLabel2(fmt.Sprintf("Файлы игры доступны установщикам в локальной сети (порт %d).\n"+
+		"На другом компьютере отметьте «Искать файлы игры в локальной сети» и введите код:", server.Port), nil, 0)
+	infoText.SetWordWrap(true)
+
+	tokenLabel := widgets.NewQLabel2(server.Token, nil, 0)
+	tokenLabel.SetAlignment(core.Qt__AlignCenter)
+	tokenLabel.SetStyleSheet("font-size: 24pt; font-weight: bold;")
+	tokenLabel.SetTextInteractionFlags(core.Qt__TextSelectableByMouse)
+
+	stopButton := widgets.NewQPushButton2("Остановить раздачу", nil)
+	stopButton.ConnectClicked(func(bool) {
+		dialog.Accept()
+	})
+
+	layout := widgets.NewQVBoxLayout()
+	layout.AddWidget(infoText, 0, 0)
+	layout.AddWidget(tokenLabel, 0, 0)
+	layout.AddWidget(stopButton, 0, 0)
+	dialog.SetLayout(layout)
+	dialog.Exec()
+}
+
+// findMountableImage возвращает образ squashfs из списка ресурсов, который можно
+// установить без распаковки. Если squashfuse в системе нет, образ будет распакован.
+func findMountableImage(paths map[string]string) string {
+	if _, err := exec.LookPath("squashfuse"); err != nil {
+		log.Printf("squashfuse не найден, образ squashfs будет распакован")
+		return ""
+	}
+	for _, asset := range installAssets() {
+		if format, err := archive.Detect(paths[asset]); err == nil && format.Name == "squashfs" {
+			return asset
+		}
+	}
+	return ""
+}
+
+// launchArgs возвращает аргументы запуска с подставленными значениями полей
+func launchArgs() []string {
+	args := make([]string, 0, len(config.Launch.Args))
+	for _, arg := range config.Launch.Args {
+		args = append(args, expandTemplate(arg))
+	}
+	return args
+}
+
+// launchEnv возвращает переменные окружения запуска в виде "ИМЯ=значение" по алфавиту.
+// Переменные с недопустимыми для sh именами пропускаются.
+func launchEnv() []string {
+	var env []string
+	for name, value := range config.Launch.Env {
+		valid := name != "" && !(name[0] >= '0' && name[0] <= '9')
+		for _, r := range name {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_') {
+				valid = false
+			}
+		}
+		if !valid {
+			log.Printf("Пропущена переменная окружения с недопустимым именем: %q", name)
+			continue
+		}
+		env = append(env, name+"="+expandTemplate(value))
+	}
+	sort.Strings(env)
+	return env
+}
+
+// launchGame запускает установленную игру в отдельной сессии, чтобы она продолжила
+// работать после закрытия установщика
+func launchGame() error {
+	if installInfo.ExecPath == "" {
+		return fmt.Errorf("исполняемый файл игры не указан в конфигурации")
+	}
+	spec := launcher.Spec{Path: installInfo.ExecPath, Dir: config.InstallPath}
+	// Скрипт запуска образа сам задает аргументы, окружение и рабочую директорию
+	if launcherPath == "" {
+		spec.Args = launchArgs()
+		spec.Env = launchEnv()
+		spec.Dir = filepath.Join(config.InstallPath, config.Launch.WorkDir)
+	}
+	_, err := launcher.Start(spec)
+	return err
+}
+
+// shellQuote заключает строку в одинарные кавычки для sh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// installSquashfsImage копирует образ в директорию игры и создает скрипт, который
+// монтирует его через squashfuse на время работы игры. Образ доступен только для
+// чтения, поэтому игра должна хранить сохранения и настройки вне своей директории.
+func installSquashfsImage(imageName, image string) error {
+	if err := copyFile(image, filepath.Join(config.InstallPath, imageName)); err != nil {
+		return err
+	}
+	os.Chmod(filepath.Join(config.InstallPath, imageName), 0644)
+
+	script := "#!/bin/sh\n"
+	script += "# Монтирует образ игры через squashfuse и запускает ее\n"
+	script += "DIR=\"$(cd \"$(dirname \"$0\")\" && pwd)\"\n"
+	script += "MNT=\"$(mktemp -d \"${XDG_RUNTIME_DIR:-/tmp}/" + installInfo.Slug + ".XXXXXX\")\" || exit 1\n"
+	script += "squashfuse \"$DIR\"/" + shellQuote(imageName) + " \"$MNT\" || { rmdir \"$MNT\"; exit 1; }\n"
+	script += "cleanup() { fusermount -u \"$MNT\" 2>/dev/null || umount \"$MNT\"; rmdir \"$MNT\"; }\n"
+	script += "trap cleanup EXIT\n"
+	script += "trap 'exit 130' INT TERM\n"
+	for _, kv := range launchEnv() {
+		name, value, _ := strings.Cut(kv, "=")
+		script += "export " + name + "=" + shellQuote(value) + "\n"
+	}
+	script += "cd \"$MNT\"/" + shellQuote(config.Launch.WorkDir) + " || exit 1\n"
+	script += "\"$MNT\"/" + shellQuote(config.ExecPath)
+	for _, arg := range launchArgs() {
+		script += " " + shellQuote(arg)
+	}
+	script += " \"$@\"\n"
+
+	path := filepath.Join(config.InstallPath, launcherFileName)
+	if err := ioutil.WriteFile(path, []byte(script), 0755); err != nil {
+		return err
+	}
+	launcherPath = path
+	log.Printf("Образ %s установлен без распаковки, скрипт запуска: %s", imageName, path)
+	return nil
+}
+
+// scanUnsafeEntries проверяет все архивы до начала распаковки и собирает опасные записи
+func scanUnsafeEntries(entries map[string][]archive.Entry) []engine.Unsafe {
+	return engine.ScanUnsafe(config.InstallPath, installAssets(), entries)
+}
+
+// showSecurityReport показывает список опасных записей архива
+func showSecurityReport(entries []engine.Unsafe, aborted bool) {
+	report := ""
+	for _, e := range entries {
+		log.Printf("Опасная запись в архиве %s: %s (%s)", e.Archive, e.Name, e.Reason)
+		report += fmt.Sprintf("%s: %s (%s)\n", filepath.Base(e.Archive), e.Name, e.Reason)
+	}
+
+	summary := fmt.Sprintf("В архивах обнаружено опасных записей: %d.\n", len(entries))
+	if aborted {
+		summary += "Установка прервана, файлы не были распакованы."
+	} else {
+		summary += "Эти записи помещены в карантин и не будут распакованы."
+	}
+	if answers != nil {
+		// Записи уже в логе, автоматическую установку отчет не останавливает
+		log.Print(summary)
+		return
+	}
+
+	dialog := widgets.NewQDialog(nil, 0)
+	dialog.SetWindowTitle("Отчет безопасности")
+
+	summaryLabel := widgets.NewQLabel2(summary, nil, 0)
+	summaryLabel.SetWordWrap(true)
+
+	reportText := widgets.NewQPlainTextEdit(nil)
+	reportText.SetReadOnly(true)
+	reportText.SetPlainText(report)
+
+	closeButton := widgets.NewQPushButton2("Закрыть", nil)
+	closeButton.ConnectClicked(func(bool) {
+		dialog.Accept()
+	})
+
+	layout := widgets.NewQVBoxLayout()
+	layout.AddWidget(summaryLabel, 0, 0)
+	layout.AddWidget(reportText, 0, 0)
+	layout.AddWidget(closeButton, 0, 0)
+	dialog.SetLayout(layout)
+	dialog.Resize(core.NewQSize2(500, 300))
+	dialog.Exec()
+}
+
+// windowUI передает ход распаковки и ее вопросы обработчику сообщений окна
+type windowUI struct {
+	files         *int // Счетчик прогрессбара, его же двигают патч и образ
+	tracker       *estimate.Tracker
+	pipeline      *pipelineProgress
+	updateChan    chan<- int
+	errorChan     chan<- warnings.Entry
+	userDataChan  chan<- []engine.ChangedFile
+	userDataReply <-chan bool
+}
+
+func (u *windowUI) Extracted(size int64) {
+	u.tracker.Add(size)
+	if u.pipeline != nil {
+		u.pipeline.extract(size)
+	}
+	*u.files++
+	u.updateChan <- *u.files
+}
+
+func (u *windowUI) Warn(message, file string, err error) {
+	entry := warnings.Entry{Message: message, File: file}
+	if err != nil {
+		entry.Detail = err.Error()
+	}
+	u.errorChan <- entry
+}
+
+// ConfirmUserData ждет решения о файлах пользователя от обработчика сообщений
+func (u *windowUI) ConfirmUserData(changed []engine.ChangedFile) bool {
+	u.userDataChan <- changed
+	return <-u.userDataReply
+}
+
+// confirmUserDataOverwrite показывает файлы пользователя, отличающиеся от новой
+// версии, и спрашивает, заменить ли их. По умолчанию файлы сохраняются.
+func confirmUserDataOverwrite(changed []engine.ChangedFile) bool {
+	if answers != nil {
+		return answers.OverwriteUserData
+	}
+
+	var report strings.Builder
+	for _, f := range changed {
+		fmt.Fprintf(&report, "~ %s\n", f.Name)
+		fmt.Fprintf(&report, "    - ваша версия: %d байт\n", f.OldSize)
+		fmt.Fprintf(&report, "    + новая версия: %d байт\n", f.NewSize)
+	}
+
+	dialog := widgets.NewQDialog(nil, 0)
+	dialog.SetWindowTitle("Файлы пользователя")
+
+	summaryLabel := widgets.NewQLabel2(fmt.Sprintf("Обновление содержит другие версии ваших файлов (%d). "+
+		"Чтобы не потерять настройки и сохранения, они оставлены без изменений.", len(changed)), nil, 0)
+	summaryLabel.SetWordWrap(true)
+
+	reportText := widgets.NewQPlainTextEdit(nil)
+	reportText.SetReadOnly(true)
+	reportText.SetPlainText(report.String())
+
+	keepButton := widgets.NewQPushButton2("Оставить мои файлы", nil)
+	keepButton.SetDefault(true)
+	keepButton.ConnectClicked(func(bool) {
+		dialog.Reject()
+	})
+	overwriteButton := widgets.NewQPushButton2("Заменить новыми", nil)
+	overwriteButton.ConnectClicked(func(bool) {
+		dialog.Accept()
+	})
+
+	buttonsLayout := widgets.NewQHBoxLayout()
+	buttonsLayout.AddWidget(keepButton, 0, 0)
+	buttonsLayout.AddWidget(overwriteButton, 0, 0)
+
+	layout := widgets.NewQVBoxLayout()
+	layout.AddWidget(summaryLabel, 0, 0)
+	layout.AddWidget(reportText, 0, 0)
+	layout.AddLayout(buttonsLayout, 0)
+	dialog.SetLayout(layout)
+	dialog.Resize(core.NewQSize2(500, 300))
+	return dialog.Exec() == int(widgets.QDialog__Accepted)
+}
+
+// copyFile копирует файл из src в dst и устанавливает права на исполнение
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+	if err != nil {
+		return err
+	}
+
+	return os.Chmod(dst, 0755) // Устанавливаем права на исполнение
+}
+
+// chooseSlug выбирает идентификатор игры для имен файлов. Если в реестре под тем же
+// идентификатором записана другая игра, к нему добавляется номер.
+func chooseSlug() string {
+	return slug.Unique(slug.Make(config.DesktopEntry.Name), func(candidate string) bool {
+		existing, err := common.Load(filepath.Join(common.RegistryDir(), common.RecordName(candidate)))
+		if err != nil {
+			return false
+		}
+		return existing.GameName != config.DesktopEntry.Name
+	})
+}
+
+// previousInstall возвращает запись о прежней установке этой игры из реестра. Записи
+// прежних версий установщика приводятся к текущему формату.
+func previousInstall() *InstallInfo {
+	previous, err := common.Load(filepath.Join(common.RegistryDir(), common.RecordName(installInfo.Slug)))
+	if err != nil || previous.GameName != config.DesktopEntry.Name {
+		return nil
+	}
+	return previous
+}
+
+// maxRecent — сколько последних установок показывает стартовая страница
+const maxRecent = 10
+
+// recentInstalls возвращает игры из реестра, последние установленные первыми
+func recentInstalls() []*InstallInfo {
+	files, _ := filepath.Glob(filepath.Join(common.RegistryDir(), "*.json"))
+	var installs []*InstallInfo
+	for _, file := range files {
+		if info, err := common.Load(file); err == nil {
+			installs = append(installs, info)
+		}
+	}
+	sort.Slice(installs, func(i, j int) bool {
+		return installs[i].InstallDate.After(installs[j].InstallDate)
+	})
+	if len(installs) > maxRecent {
+		installs = installs[:maxRecent]
+	}
+	return installs
+}
+
+// installStatus описывает состояние установленной игры и сообщает, требует ли
+// она внимания: обновления или исправления в менеджере
+func installStatus(info *InstallInfo) (string, bool) {
+	if _, err := os.Stat(info.InstallPath); err != nil {
+		if info.MountPoint != "" {
+			return "на отключенном диске", false
+		}
+		return "файлы не найдены, исправьте установку в менеджере", true
+	}
+	if pending := updates.Load(common.GameSlug(info)); pending != nil && pending.Ready && pending.Version != info.Version {
+		return "готово обновление до версии " + pending.Version, true
+	}
+	if info.Version != "" {
+		return "установлена версия " + info.Version, false
+	}
+	return "установлена", false
+}
+
+// openManager открывает менеджер игр на записи об игре. Берется менеджер из
+// директории игры, а если его нет, то лежащий рядом с установщиком.
+func openManager(info *InstallInfo) error {
+	manager := info.UninstallerPath
+	if _, err := os.Stat(manager); err != nil {
+		manager = uninstallerSource()
+	}
+	_, err := launcher.Start(launcher.Spec{Path: manager, Args: []string{"--select=" + info.InstallPath}})
+	return err
+}
+
+// showRecentInstalls показывает уже установленные игры с их состоянием. Двойной
+// щелчок открывает игру в менеджере, где ее можно обновить или исправить.
+func showRecentInstalls(installs []*InstallInfo) {
+	dialog := widgets.NewQDialog(nil, 0)
+	dialog.SetWindowTitle("Установленные игры")
+
+	list := widgets.NewQListWidget(nil)
+	for _, info := range installs {
+		status, attention := installStatus(info)
+		text := info.GameName + " — " + status
+		if info.GameName == config.DesktopEntry.Name {
+			text += " (эта игра)"
+		}
+		item := widgets.NewQListWidgetItem2(text, list, 0)
+		item.SetToolTip(info.InstallPath + "\nУстановлена " + info.InstallDate.Format("02.01.2006 15:04"))
+		if attention {
+			item.SetIcon(dialog.Style().StandardIcon(widgets.QStyle__SP_MessageBoxWarning, nil, nil))
+		}
+	}
+
+	open := func() {
+		row := list.CurrentRow()
+		if row < 0 || row >= len(installs) {
+			return
+		}
+		if err := openManager(installs[row]); err != nil {
+			displayWarning("Не удалось открыть менеджер игр: " + err.Error())
+			return
+		}
+		dialog.Accept()
+	}
+	list.ConnectItemDoubleClicked(func(*widgets.QListWidgetItem) { open() })
+
+	openButton := widgets.NewQPushButton2("Открыть в менеджере", nil)
+	openButton.SetEnabled(false)
+	list.ConnectCurrentRowChanged(func(row int) {
+		openButton.SetEnabled(row >= 0)
+	})
+	openButton.ConnectClicked(func(bool) { open() })
+	closeButton := widgets.NewQPushButton2("Закрыть", nil)
+	closeButton.ConnectClicked(func(bool) { dialog.Reject() })
+
+	buttonsLayout := widgets.NewQHBoxLayout()
+	buttonsLayout.AddWidget(openButton, 0, 0)
+	buttonsLayout.AddWidget(closeButton, 0, 0)
+
+	layout := widgets.NewQVBoxLayout()
+	layout.AddWidget(list, 1, 0)
+	layout.AddLayout(buttonsLayout, 0)
+	dialog.SetLayout(layout)
+	dialog.Resize(core.NewQSize2(450, 300))
+	dialog.Exec()
+}
+
+// defaultKeepBackups — сколько резервных копий обновлений хранится по умолчанию
+const defaultKeepBackups = 3
+
+// maxHistory ограничивает длину истории версий в записи об установке
+const maxHistory = 20
+
+// keepBackups возвращает число хранимых резервных копий из конфигурации
+func keepBackups() int {
+	if config.KeepBackups == 0 {
+		return defaultKeepBackups
+	}
+	return config.KeepBackups
+}
+
+// versionHistory дополняет историю прежней установки ее версией при обновлении
+func versionHistory(previous *InstallInfo) []VersionEntry {
+	if previous == nil || filepath.Clean(previous.InstallPath) != filepath.Clean(config.InstallPath) {
+		return nil
+	}
+	history := previous.History
+	if previous.Version != config.Version {
+		history = append(history, VersionEntry{Version: previous.Version, Date: previous.InstallDate})
+	}
+	if len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+	}
+	return history
+}
+
+// updateBackup начинает резервную копию, если игра обновляется до другой версии
+// в той же директории. При переустановке той же версии копия не нужна.
+func updateBackup(previous *InstallInfo) *backup.Session {
+	if keepBackups() < 0 || previous == nil || filepath.Clean(previous.InstallPath) != filepath.Clean(config.InstallPath) ||
+		previous.Version == config.Version {
+		return nil
+	}
+	log.Printf("Обновление с версии %q, заменяемые файлы сохраняются в %s", previous.Version, backup.DirName)
+	return backup.Begin(config.InstallPath, previous.Version, config.Version)
+}
+
+// exportFlatpak собирает из установленной игры приложение Flatpak и подменяет запуск
+// игры скриптом, который запускает это приложение
+func exportFlatpak() error {
+	if launcherPath != "" {
+		return fmt.Errorf("игра из смонтированного образа не упаковывается")
+	}
+	if config.ExecPath == "" {
+		return fmt.Errorf("в конфигурации не указан исполняемый файл")
+	}
+	if !flatpak.Available() {
+		return fmt.Errorf("flatpak не установлен")
+	}
+
+	export, err := flatpak.Build(flatpak.Options{
+		AppID:          installInfo.AppID,
+		Slug:           installInfo.Slug,
+		Source:         config.InstallPath,
+		Exclude:        []string{"logs", "uninstaller", backup.DirName, common.SentinelFileName, flatpakLauncherName},
+		Exec:           config.ExecPath,
+		WorkDir:        config.Launch.WorkDir,
+		Args:           launchArgs(),
+		Env:            launchEnv(),
+		RuntimeVersion: config.FlatpakRuntime,
+	}, source.TempRoot)
+	if err != nil {
+		return err
+	}
+
+	script := "#!/bin/sh\n# Запускает игру в песочнице Flatpak\nexec flatpak run " + shellQuote(export.AppID) + " \"$@\"\n"
+	path := filepath.Join(config.InstallPath, flatpakLauncherName)
+	if err := ioutil.WriteFile(path, []byte(script), 0755); err != nil {
+		return err
+	}
+	launcherPath = path
+	installInfo.Flatpak = export
+	log.Printf("Игра упакована во Flatpak %s, репозиторий %s", export.AppID, export.Repo)
+	return nil
+}
+
+// removeNativeFiles удаляет из директории игры файлы, которые вошли во Flatpak.
+// Остаются логи с записью об установке, деинсталлятор, резервные копии, скрипт
+// запуска и иконка ярлыка.
+func removeNativeFiles() error {
+	keep := map[string]bool{
+		"logs": true, "uninstaller": true, backup.DirName: true,
+		common.SentinelFileName: true, flatpakLauncherName: true, convertedIconName: true,
+	}
+	if rel, err := filepath.Rel(config.InstallPath, installInfo.IconPath); err == nil && filepath.IsLocal(rel) {
+		keep[strings.SplitN(filepath.ToSlash(rel), "/", 2)[0]] = true
+	}
+
+	entries, err := os.ReadDir(config.InstallPath)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if keep[e.Name()] {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(config.InstallPath, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// itchPatch возвращает патч itch.io для обновления установленной версии или пустую
+// строку, если патча нет, butler не найден или обновление патчем уже не удалось
+func itchPatch(previous *InstallInfo) string {
+	if patchFailed || previous == nil || filepath.Clean(previous.InstallPath) != filepath.Clean(config.InstallPath) ||
+		previous.Version == config.Version {
+		return ""
+	}
+	patch, ok := config.ItchPatches[previous.Version]
+	if !ok {
+		return ""
+	}
+	if _, err := itch.Butler(); err != nil {
+		log.Printf("Есть патч с версии %s, но butler не найден, устанавливается полная версия", previous.Version)
+		return ""
+	}
+	log.Printf("Обновление с версии %s патчем %s", previous.Version, patch)
+	return patch
+}
+
+// applyItchPatch применяет патч к директории игры и, если задана подпись, сверяет
+// с ней результат
+func applyItchPatch(ctx context.Context, patch string, progress func(float64)) error {
+	staging, err := os.MkdirTemp(source.TempRoot, "go-qt-installer-butler-")
+	if err != nil {
+		return err
+	}
+	if err := itch.Apply(ctx, patch, config.InstallPath, staging, progress); err != nil {
+		return err
+	}
+	if config.ItchSignature == "" {
+		return nil
+	}
+	signature, err := source.Fetch(ctx, config.ItchSignature, nil)
+	if err != nil {
+		return fmt.Errorf("не удалось получить подпись: %v", err)
+	}
+	if err := itch.Verify(signature, config.InstallPath); err != nil {
+		return fmt.Errorf("файлы после патча не совпадают с подписью: %v", err)
+	}
+	return nil
+}
+
+// applySyncManifest приводит директорию игры в соответствие с манифестом: скачивает
+// недостающие и изменившиеся файлы и удаляет файлы прежней версии. Заменяемые файлы
+// попадают в резервную копию обновления.
+func applySyncManifest(ctx context.Context, m *manifest.Manifest, root string, saved *backup.Session, progress func(float64)) error {
+	// В запись попадает версия, которая на самом деле установлена: манифест
+	// последней версии мог обновиться раньше конфигурации установщика
+	if m.Version != "" && m.Version != config.Version {
+		log.Printf("Версия в манифесте (%s) отличается от версии в конфигурации (%s)", m.Version, config.Version)
+		config.Version = m.Version
+	}
+	// Проверка по хэшам показывается картой участков, а не общим прогрессом
+	verifyView.Start("Проверка файлов игры", m.TotalBytes())
+	progressVoice.Say("Проверка файлов игры")
+	plan, err := manifest.CompareProgress(m, root, config.UserData, verifyFiles, verifyView.Add)
+	verifyView.Finish()
+	if err != nil {
+		return err
+	}
+	progressVoice.Say(fmt.Sprintf("Проверка файлов завершена, скачать нужно файлов: %d", len(plan.Fetch)))
+	total := plan.FetchBytes()
+	log.Printf("Синхронизация по манифесту: совпадает файлов %d, скачать %d (%.1f МБ), удалить %d",
+		plan.UpToDate, len(plan.Fetch), float64(total)/1024/1024, len(plan.Remove))
+
+	// По хэшам из манифеста файлы и части находятся в кэше загрузок, даже если
+	// их ссылка изменилась
+	if source.Hashes == nil {
+		source.Hashes = make(map[string]string)
+	}
+	for ref, hash := range m.Hashes(plan.Fetch) {
+		source.Hashes[ref] = hash
+	}
+	tempDir, _ := source.TempDir()
+
+	opts := manifest.Options{
+		Fetch: func(ctx context.Context, ref string, progress func(done, total int64)) (string, error) {
+			return fetchRetrying(ctx, ref, progress, network.WaitOnline)
+		},
+		Progress: func(done int64) {
+			if total > 0 {
+				progress(float64(done) / float64(total))
+			}
+		},
+		Release: func(path string) {
+			// Скачанная копия больше не нужна, файлы на диске источника не трогаем
+			if tempDir != "" && strings.HasPrefix(path, tempDir+string(os.PathSeparator)) {
+				os.Remove(path)
+			}
+		},
+	}
+	if saved != nil {
+		opts.Replace = saved.Save
+		opts.Added = saved.Added
+	}
+	if err := manifest.Apply(ctx, m, root, plan, opts); err != nil {
+		return err
+	}
+	log.Printf("Синхронизация завершена: скачано %.1f МБ, взято из прежних версий файлов %.1f МБ",
+		float64(plan.Downloaded)/1024/1024, float64(plan.Reused)/1024/1024)
+	return nil
+}
+
+// makeManifest готовит публикацию игры для синхронизации по манифесту:
+// --make-manifest <директория игры> <директория публикации> [адрес публикации]
+func makeManifest(args []string) int {
+	if len(args) < 2 {
+		fmt.Fprintln(os.Stderr, "Использование: --make-manifest <директория игры> <директория публикации> [адрес публикации]")
+		return 2
+	}
+	url := ""
+	if len(args) > 2 {
+		url = args[2]
+	}
+	m, err := manifest.Build(args[0], args[1], url, config.Version)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка при подготовке манифеста: %v\n", err)
+		return 1
+	}
+	var chunks int
+	for _, f := range m.Files {
+		chunks += len(f.Chunks)
+	}
+	fmt.Printf("Манифест %s: файлов %d, частей %d, %.1f МБ\n",
+		filepath.Join(args[1], manifest.FileName), len(m.Files), chunks, float64(m.TotalBytes())/1024/1024)
+	return 0
+}
+
+// registerReceipt устанавливает пакет-квитанцию для игры. При обновлении пакет
+// переустанавливается с новой версией, а если это не удалось, остается прежний.
+func registerReceipt(previous *InstallInfo, size int64) error {
+	if previous != nil && filepath.Clean(previous.InstallPath) == filepath.Clean(config.InstallPath) {
+		installInfo.Receipt = previous.Receipt
+	}
+	kind := receipt.Detect()
+	if kind == "" {
+		return fmt.Errorf("не найден dpkg или rpm с rpmbuild")
+	}
+	r, err := receipt.Register(kind, receipt.Info{
+		Slug:        installInfo.Slug,
+		Name:        config.DesktopEntry.Name,
+		Version:     config.Version,
+		InstallPath: config.InstallPath,
+		Size:        size,
+	}, source.TempRoot)
+	if err != nil {
+		return err
+	}
+	log.Printf("Игра зарегистрирована в пакетной базе как %s", r.Package)
+	installInfo.Receipt = r
+	return nil
+}
+
+// trialDir — директория рядом с директорией игры, куда она распаковывается для
+// пробного запуска. На той же файловой системе перенос на место мгновенный.
+func trialDir() string {
+	path := filepath.Clean(config.InstallPath)
+	return filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".trial")
+}
+
+// trialAllowed сообщает, можно ли предложить пробный запуск. Он доступен только
+// для новой установки в обычную директорию: обновление и том со снимками пришлось
+// бы переносить целиком, а смонтированный образ и так не меняет файлов.
+func trialAllowed(mountImage string) bool {
+	if !config.TryBeforeInstall || answers != nil || mountImage != "" || installInfo.Snapshot != nil || config.ExecPath == "" {
+		return false
+	}
+	if _, err := os.Stat(config.InstallPath); err == nil {
+		return false
+	}
+	if !overlay.Available() {
+		log.Printf("Пробный запуск недоступен: нужен fuse-overlayfs")
+		return false
+	}
+	return true
+}
+
+// tryBeforeInstall предлагает запустить распакованную в root игру до установки.
+// Игра монтируется через overlayfs, поэтому все ее изменения при пробе отбрасываются.
+// Возвращает true, если игра перенесена на место и установку нужно продолжить.
+func tryBeforeInstall(root string) bool {
+	var trial *overlay.Mount
+	defer func() {
+		if trial != nil {
+			if err := trial.Unmount(); err != nil {
+				log.Printf("Ошибка при отмонтировании пробного запуска: %v", err)
+			}
+		}
+	}()
+
+	for {
+		msgBox := widgets.NewQMessageBox(nil)
+		msgBox.SetWindowTitle("Пробный запуск")
+		msgBox.SetIcon(widgets.QMessageBox__Question)
+		msgBox.SetText("Игра распакована. Можно запустить ее и проверить до завершения установки.")
+		msgBox.SetInformativeText("Изменения, сделанные игрой при пробном запуске, не сохранятся. " +
+			"При отмене распакованные файлы будут удалены.")
+		tryButton := msgBox.AddButton2("Запустить", widgets.QMessageBox__ActionRole)
+		keepButton := msgBox.AddButton2("Установить", widgets.QMessageBox__AcceptRole)
+		msgBox.AddButton2("Отменить установку", widgets.QMessageBox__RejectRole)
+		msgBox.SetDefaultButton(keepButton)
+		msgBox.Exec()
+
+		switch msgBox.ClickedButton().Pointer() {
+		case tryButton.Pointer():
+			if trial == nil {
+				var err error
+				if trial, err = overlay.New(root, source.TempRoot); err != nil {
+					displayError("Не удалось смонтировать игру для пробного запуска: " + err.Error())
+					continue
+				}
+			}
+			spec := launcher.Spec{
+				Path: filepath.Join(trial.Dir, config.ExecPath),
+				Dir:  filepath.Join(trial.Dir, config.Launch.WorkDir),
+				Args: launchArgs(),
+				Env:  launchEnv(),
+			}
+			if _, err := launcher.Start(spec); err != nil {
+				displayError("Не удалось запустить игру: " + err.Error())
+			}
+		case keepButton.Pointer():
+			if err := os.Rename(root, config.InstallPath); err != nil {
+				displayFailure(failure.Classify(err, failure.Extraction), "Не удалось перенести игру в директорию установки: "+err.Error())
+				return false
+			}
+			return true
+		default:
+			log.Printf("Установка отменена после пробного запуска")
+			return false
+		}
+	}
+}
+
+// prepareVolume создает для новой установки подтом btrfs или набор данных ZFS, если
+// это включено в конфигурации и поддерживается файловой системой. Обновление
+// продолжает пользоваться томом предыдущей установки. При любой неудаче игра
+// ставится в обычную директорию.
+func prepareVolume(previous *InstallInfo) *snapshot.Volume {
+	if !config.Snapshot {
+		return nil
+	}
+	path := filepath.Clean(config.InstallPath)
+	// Том мог остаться от прерванной попытки установки
+	if installInfo.Snapshot != nil && installInfo.Snapshot.Path == path {
+		return installInfo.Snapshot
+	}
+	if previous != nil && previous.Snapshot != nil && previous.Snapshot.Path == path {
+		return previous.Snapshot
+	}
+	if _, err := os.Stat(path); err == nil {
+		log.Printf("Директория %s уже существует, снимки не используются", path)
+		return nil
+	}
+
+	kind := snapshot.Detect(path)
+	if kind == "" {
+		return nil
+	}
+	volume, err := snapshot.Create(kind, path)
+	if err != nil {
+		log.Printf("Не удалось создать том %s, игра будет установлена в обычную директорию: %v", kind, err)
+		return nil
+	}
+	log.Printf("Игра устанавливается в отдельный том %s: %s", kind, path)
+	return volume
+}
+
+// chooseAppID выбирает идентификатор ярлыка. Сгенерированный идентификатор берется
+// из прежней установки, чтобы он не менялся при переустановке и обновлении.
+func chooseAppID(previous *InstallInfo) string {
+	if config.AppID != "" {
+		if common.ValidAppID(config.AppID) {
+			return config.AppID
+		}
+		log.Printf("Некорректный app_id в конфигурации: %s", config.AppID)
+	}
+	if previous != nil && previous.AppID != "" {
+		return previous.AppID
+	}
+	return common.DefaultAppID(installInfo.Slug)
+}
+
+// removeOldShortcuts удаляет ярлыки прежней установки в ту же директорию, если они
+// назывались иначе. Ярлыки копии игры в другой директории не трогаем.
+func removeOldShortcuts(previous *InstallInfo) {
+	if previous == nil || filepath.Clean(previous.InstallPath) != filepath.Clean(config.InstallPath) {
+		return
+	}
+	if previous.Pin != nil && (installInfo.Pin == nil || *installInfo.Pin != *previous.Pin) {
+		if err := pin.Unpin(previous.Pin); err != nil {
+			log.Printf("Ошибка при откреплении ярлыка прежней установки: %v", err)
+		}
+	}
+	for _, file := range []string{previous.MenuFile, previous.DesktopFile, previous.UninstallMenuFile} {
+		if file == "" || file == installInfo.MenuFile || file == installInfo.DesktopFile || file == installInfo.UninstallMenuFile {
+			continue
+		}
+		if err := os.Remove(file); err == nil {
+			log.Printf("Удален ярлык прежней установки: %s", file)
+		}
+	}
+}
+
+// chooseRegistryPath выбирает файл в реестре. Повторная установка той же игры в другую
+// директорию получает отдельную запись, чтобы не затереть запись о первой копии.
+func chooseRegistryPath(gameSlug string) string {
+	registryPath := filepath.Join(common.RegistryDir(), common.RecordName(gameSlug))
+	existing, err := common.Load(registryPath)
+	if err != nil || filepath.Clean(existing.InstallPath) == filepath.Clean(config.InstallPath) {
+		return registryPath
+	}
+	if _, err := os.Stat(existing.InstallPath); err != nil {
+		// Прежней копии уже нет на диске, запись можно переиспользовать
+		return registryPath
+	}
+
+	sum := sha256.Sum256([]byte(filepath.Clean(config.InstallPath)))
+	return filepath.Join(common.RegistryDir(), fmt.Sprintf("%s-%x-install.json", gameSlug, sum[:4]))
+}
+
+// writeSentinel создает файл-метку в директории установки
+func writeSentinel() error {
+	sentinelPath := filepath.Join(config.InstallPath, common.SentinelFileName)
+	content := config.DesktopEntry.Name + "\n" + time.Now().Format(time.RFC3339) + "\n"
+	return ioutil.WriteFile(sentinelPath, []byte(content), 0644)
+}
+
+// saveInstallInfo сохраняет информацию об установке в директории игры
+func saveInstallInfo() error {
+	logsDir := filepath.Join(config.InstallPath, "logs")
+	if err := os.MkdirAll(logsDir, 0755); err != nil {
+		return fmt.Errorf("не удалось создать директорию для логов: %v", err)
+	}
+
+	infoFilePath := filepath.Join(logsDir, common.RecordName(installInfo.Slug))
+
+	installInfo.GameName = config.DesktopEntry.Name
+	installInfo.InstallPath = config.InstallPath
+	installInfo.InstallDate = time.Now()
+	installInfo.InstallerPath, _ = common.Executable()
+	installInfo.InstallerDir = filepath.Dir(installInfo.InstallerPath)
+	installInfo.UninstallerPath = filepath.Join(config.InstallPath, "uninstaller")
+	installInfo.Version = config.Version
+	installInfo.MountPoint = mounts.Of(config.InstallPath)
+	installInfo.Webhooks = config.Webhooks
+	installInfo.SupportURL = config.SupportURL
+	installInfo.UserData = config.UserData
+	if c := maintenanceConfig(); len(c.ShaderCaches) > 0 || len(c.ConfigFiles) > 0 {
+		installInfo.Maintenance = &c
+	}
+	if config.UninstallSurvey.Enabled() {
+		c := config.UninstallSurvey
+		installInfo.Survey = &c
+	}
+	if source.Remote(config.SyncManifest) {
+		installInfo.UpdateManifest = config.SyncManifest
+	}
+	if values := publicValues(); len(values) > 0 {
+		installInfo.Options = values
+	}
+	installInfo.RegistryFile = chooseRegistryPath(installInfo.Slug)
+	if launcherPath != "" {
+		installInfo.ExecPath = launcherPath
+	} else if config.ExecPath != "" {
+		installInfo.ExecPath = filepath.Join(config.InstallPath, config.ExecPath)
+		// Менеджер запускает игру с теми же параметрами, что и ярлык
+		installInfo.WorkDir = filepath.Join(config.InstallPath, config.Launch.WorkDir)
+		installInfo.LaunchArgs = launchArgs()
+		installInfo.LaunchEnv = launchEnv()
+	}
+
+	// Копируем баннер, чтобы деинсталлятор мог показать его без установщика
+	if config.BannerPath != "" {
+		if banner, err := ioutil.ReadFile(config.BannerPath); err == nil {
+			bannerPath := filepath.Join(logsDir, "banner"+filepath.Ext(config.BannerPath))
+			if err := ioutil.WriteFile(bannerPath, banner, 0644); err == nil {
+				installInfo.BannerPath = bannerPath
+			}
+		}
+	}
+
+	installEvents.Add(timeline.Finish, "установка завершена", fmt.Sprintf("предупреждений: %d", warningsPanel.Count()))
+	installInfo.Events = installEvents.Merge(installInfo.Events)
+
+	data, err := common.Marshal(&installInfo)
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(infoFilePath, data, 0644); err != nil {
+		return fmt.Errorf("ошибка при сохранении информации об установке: %v", err)
+	}
+
+	log.Printf("Информация об установке сохранена в %s", infoFilePath)
+
+	// Дублируем запись в общий реестр, с которым сверяется деинсталлятор
+	if err := os.MkdirAll(common.RegistryDir(), 0755); err != nil {
+		return fmt.Errorf("не удалось создать директорию реестра: %v", err)
+	}
+	registryPath := installInfo.RegistryFile
+	if err := ioutil.WriteFile(registryPath, data, 0644); err != nil {
+		return fmt.Errorf("ошибка при сохранении записи в реестре: %v", err)
+	}
+
+	log.Printf("Запись в реестре сохранена в %s", registryPath)
+	// События неудачных попыток теперь в записи
+	os.Remove(failedEventsPath(installInfo.Slug))
+	return nil
+}
+
+// failedInstall — события неудачной новой установки: записи об игре еще нет
+type failedInstall struct {
+	Game        string           `json:"game"`
+	Version     string           `json:"version"`
+	InstallPath string           `json:"install_path"`
+	InstallID   string           `json:"install_id,omitempty"`
+	Events      []timeline.Event `json:"events"`
+}
+
+// failedEventsPath — файл с событиями неудачных установок игры gameSlug рядом с реестром
+func failedEventsPath(gameSlug string) string {
+	return filepath.Join(filepath.Dir(common.RegistryDir()), "failed", common.RecordName(gameSlug))
+}
+
+// failedEvents возвращает события прежних неудачных установок игры
+func failedEvents(gameSlug string) []timeline.Event {
+	data, err := ioutil.ReadFile(failedEventsPath(gameSlug))
+	if err != nil {
+		return nil
+	}
+	var failed failedInstall
+	if err := json.Unmarshal(data, &failed); err != nil {
+		return nil
+	}
+	return failed.Events
+}
+
+// saveFailureEvents сохраняет события неудачной установки, чтобы поддержка
+// увидела их и после закрытия окна. Неудачное обновление дописывает их в запись
+// об установленной версии, неудачная новая установка — в отдельный файл, события
+// из которого перейдут в запись после удачной установки.
+func saveFailureEvents() {
+	if installInfo.Slug == "" {
+		return
+	}
+	registryPath := filepath.Join(common.RegistryDir(), common.RecordName(installInfo.Slug))
+	if previous := previousInstall(); previous != nil {
+		if err := appendRecordEvents(registryPath, previous); err != nil {
+			log.Printf("Не удалось сохранить события в записи %s: %v", registryPath, err)
+		}
+		return
+	}
+
+	failed := failedInstall{
+		Game:        config.DesktopEntry.Name,
+		Version:     config.Version,
+		InstallPath: config.InstallPath,
+		InstallID:   installInfo.InstallID,
+		Events:      installEvents.Merge(failedEvents(installInfo.Slug)),
+	}
+	data, err := json.MarshalIndent(failed, "", "  ")
+	if err != nil {
+		return
+	}
+	path := failedEventsPath(installInfo.Slug)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		log.Printf("Не удалось сохранить события установки: %v", err)
+		return
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		log.Printf("Не удалось сохранить события установки: %v", err)
+		return
+	}
+	log.Printf("События неудачной установки сохранены в %s", path)
+}
+
+// appendRecordEvents добавляет события этого запуска в запись об установленной
+// версии в реестре и в логах игры. Запись с неверной подписью не меняется, чтобы
+// новая подпись не узаконила ее; неподписанная остается неподписанной.
+func appendRecordEvents(registryPath string, previous *InstallInfo) error {
+	data, err := ioutil.ReadFile(registryPath)
+	if err != nil {
+		return err
+	}
+	verifyErr := signature.Verify(data)
+	if verifyErr != nil && verifyErr != signature.ErrUnsigned {
+		return verifyErr
+	}
+	migrated, _, err := common.Migrate(data)
+	if err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(migrated, &fields); err != nil {
+		return err
+	}
+	if fields["events"], err = json.Marshal(installEvents.Merge(previous.Events)); err != nil {
+		return err
+	}
+	var record []byte
+	if verifyErr == signature.ErrUnsigned {
+		delete(fields, signature.Field)
+		record, err = json.MarshalIndent(fields, "", "  ")
+	} else if migrated, err = json.Marshal(fields); err == nil {
+		record, err = common.Resign(migrated)
+	}
+	if err != nil {
+		return err
+	}
+
+	targets := []string{registryPath}
+	if logsDir := filepath.Join(previous.InstallPath, "logs"); previous.InstallPath != "" {
+		if _, err := os.Stat(logsDir); err == nil {
+			targets = append(targets, filepath.Join(logsDir, common.RecordName(common.GameSlug(previous))))
+		}
+	}
+	for _, target := range targets {
+		if err := ioutil.WriteFile(target, record, 0644); err != nil {
+			return err
+		}
 	}
+	log.Printf("События неудачного обновления сохранены в %s", registryPath)
+	return nil
 }
 
-func updateInstallPathDisplay() {
-	pathLabel.SetText("Путь установки: " + config.InstallPath)
+// installID возвращает идентификатор установки: прежний при обновлении или новый
+func installID(previous *InstallInfo) string {
+	if previous != nil && previous.InstallID != "" {
+		return previous.InstallID
+	}
+	id, err := common.NewInstallID()
+	if err != nil {
+		log.Printf("Не удалось создать идентификатор установки: %v", err)
+		return ""
+	}
+	return id
 }
 
-func checkInstallButtonState() {
-	if config.InstallPath != "" {
-		installButton.SetEnabled(true)
-	} else {
-		installButton.SetEnabled(false)
+// installToken возвращает токен, по которому сайт издателя узнает установку. Токен
+// создается, только если в конфигурации есть ссылка для телефона, и сохраняется
+// при обновлении, чтобы привязка к учетной записи не терялась.
+func installToken(previous *InstallInfo) string {
+	if config.Companion.URL == "" {
+		return ""
+	}
+	if previous != nil {
+		// Токен из записи, которую менеджер еще не перевел на связку ключей
+		if previous.InstallToken != "" {
+			return previous.InstallToken
+		}
+		if token, err := keyring.Get(common.SecretKey(previous.Slug, common.InstallTokenSecret)); err == nil {
+			return token
+		}
 	}
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		log.Printf("Не удалось создать токен установки: %v", err)
+		return ""
+	}
+	return hex.EncodeToString(buf)
 }
 
-func checkDiskSpace() (float64, error) {
-	// Check if the directory exists
-	dirPath := config.InstallPath
-
-	// If the directory doesn't exist yet, check the parent directory
-	if _, err := os.Stat(dirPath); os.IsNotExist(err) {
-		dirPath = filepath.Dir(dirPath)
+// qrModuleSize — сторона модуля QR-кода в пикселях
+const qrModuleSize = 5
 
-		// If parent directory also doesn't exist, use the current directory
-		if _, err := os.Stat(dirPath); os.IsNotExist(err) {
-			dirPath = "."
+// qrPixmap рисует QR-код. Светлое поле в 4 модуля вокруг кода обязательно для сканеров.
+func qrPixmap(code *qr.Code) *gui.QPixmap {
+	side := (code.Size + 8) * qrModuleSize
+	image := gui.NewQImage3(side, side, gui.QImage__Format_RGB32)
+	image.Fill3(core.Qt__white)
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if !code.Dark(x, y) {
+				continue
+			}
+			for dy := 0; dy < qrModuleSize; dy++ {
+				for dx := 0; dx < qrModuleSize; dx++ {
+					image.SetPixel2((x+4)*qrModuleSize+dx, (y+4)*qrModuleSize+dy, 0xFF000000)
+				}
+			}
 		}
 	}
+	return gui.QPixmap_FromImage(image, core.Qt__AutoColor)
+}
 
-	var stat syscall.Statfs_t
-	err := syscall.Statfs(dirPath, &stat)
+// showCompanionCode добавляет в окно завершения QR-код со ссылкой для телефона.
+// Код строится на месте, токен установки не уходит сторонним сервисам.
+func showCompanionCode(msgBox *widgets.QMessageBox) {
+	if config.Companion.URL == "" || installInfo.InstallToken == "" {
+		return
+	}
+	link := strings.ReplaceAll(expandTemplate(config.Companion.URL), "{{token}}", installInfo.InstallToken)
+	code, err := qr.Encode(link)
 	if err != nil {
-		return 0, err
+		log.Printf("QR-код не показан: %v", err)
+		return
 	}
 
-	freeSpace := stat.Bavail * uint64(stat.Bsize)
-	// Convert to gigabytes
-	freeSpaceGB := float64(freeSpace) / (1024 * 1024 * 1024)
-	return freeSpaceGB, nil
-}
+	msgBox.SetIconPixmap(qrPixmap(code))
 
-// Функция для установки прав на исполнение для файлов
-func setExecutablePermissions(path string) error {
-	return os.Chmod(path, 0755)
+	text := config.Companion.Text
+	if text == "" {
+		text = "Отсканируйте код телефоном, чтобы продолжить на сайте издателя."
+	}
+	msgBox.SetTextFormat(core.Qt__RichText)
+	msgBox.SetText(fmt.Sprintf("Установка игры успешно завершена!<br><br>%s<br><a href=\"%s\">%s</a>",
+		html.EscapeString(expandTemplate(text)), html.EscapeString(link), html.EscapeString(link)))
 }
 
-// Функция для рекурсивного поиска и установки прав на исполнение для бинарных файлов
-func makeFilesExecutable(dir string, patterns []string) {
-	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil
-		}
+// notifyWebhooks сообщает на адреса из конфигурации, чем закончилась установка.
+// Пустой errMsg означает успешную установку.
+func notifyWebhooks(previous *InstallInfo, started time.Time, kind failure.Kind, errMsg string) {
+	event := webhook.Event{
+		Action:    webhook.ActionInstall,
+		Game:      config.DesktopEntry.Name,
+		Slug:      installInfo.Slug,
+		InstallID: installInfo.InstallID,
+		Version:   config.Version,
+		Success:   errMsg == "",
+		Error:     errMsg,
+		ErrorCode: string(kind),
+		Duration:  time.Since(started).Seconds(),
+	}
+	if previous != nil {
+		event.Action = webhook.ActionUpdate
+		event.PreviousVersion = previous.Version
+	}
+	webhook.Send(config.Webhooks, event)
+}
 
-		// Пропускаем директории
-		if info.IsDir() {
-			return nil
-		}
+func startInstallation() {
+	// Блокируем кнопку на время установки и меняем текст
+	installButton.SetEnabled(false)
+	installButton.SetText("Установка...")
 
-		// Проверяем, является ли файл исполняемым по расширению или имени
-		isExecutable := false
+	started := time.Now()
+	installInfo.Slug = chooseSlug()
+	previous := previousInstall()
+	// Счетчик предупреждений относится к одной установке
+	warningsPanel.Clear()
+	// failInstallation прерывает установку до начала распаковки
+	failInstallation := func(kind failure.Kind, message string) {
+		notifyWebhooks(previous, started, kind, message)
+		displayFailure(kind, message)
+	}
+	installInfo.AppID = chooseAppID(previous)
+	installInfo.History = versionHistory(previous)
+	installInfo.InstallToken = installToken(previous)
+	installInfo.InstallID = installID(previous)
+	if installInfo.InstallID != "" {
+		log.Printf("Идентификатор установки %s, код для поддержки %s", installInfo.InstallID, common.SupportCode(installInfo.InstallID))
+	}
 
-		// Проверяем по расширению
-		ext := strings.ToLower(filepath.Ext(path))
-		if ext == ".sh" || ext == ".bin" || ext == ".x86" || ext == ".x86_64" || ext == "" {
-			isExecutable = true
-		}
+	// События прежних установок и неудачных попыток остаются в записи рядом с
+	// событиями этой
+	installInfo.Events = failedEvents(installInfo.Slug)
+	action := "установка"
+	if previous != nil {
+		installInfo.Events = previous.Events
+		action = "обновление с версии " + previous.Version
+	}
+	installEvents.Add(timeline.Start, action, fmt.Sprintf("версия %s в %s", config.Version, config.InstallPath))
+	recordChoice("ярлык в меню", yesNo(createShortcutCheckBox.IsChecked()))
+	if selectedLanguage != "" {
+		recordChoice("язык", selectedLanguage)
+	}
 
-		// Проверяем по имени файла
-		baseName := strings.ToLower(filepath.Base(path))
-		if strings.Contains(baseName, "run") || strings.Contains(baseName, "start") ||
-			strings.Contains(baseName, "game") || strings.Contains(baseName, "celeste") {
-			isExecutable = true
+	// Замеры предыдущей, не начавшейся попытки установки не нужны
+	if profiling {
+		profiler.Abort()
+		var err error
+		if profiler, err = profile.Start(); err != nil {
+			log.Printf("Не удалось включить профилирование: %v", err)
 		}
+	}
 
-		// Проверяем по шаблонам из конфига
-		for _, pattern := range patterns {
-			if matched, _ := filepath.Match(pattern, filepath.Base(path)); matched {
-				isExecutable = true
-				break
-			}
+	// Подсчет общего размера файлов для прогрессбара
+	totalFiles := 0
+	extractors := make(map[string]archive.Extractor)
+	entries := make(map[string][]archive.Entry)
+	closeArchives := func() {
+		for _, ext := range extractors {
+			ext.Close()
 		}
+		source.Cleanup()
+	}
 
-		if isExecutable {
-			log.Printf("Устанавливаем права на исполнение для: %s", path)
-			if err := setExecutablePermissions(path); err != nil {
-				log.Printf("Ошибка при установке прав на исполнение для %s: %v", path, err)
-			}
+	// Получаем ресурсы из их источников: с диска, носителя, по сети или из установщика.
+	// Архивы из сети скачиваются во время распаковки предыдущих. Образ squashfs
+	// для монтирования нужно найти заранее, поэтому в этом режиме все скачивается сразу.
+	assets := installAssets()
+	// Обновление с версии, для которой есть патч itch.io, получает только патч
+	patchAsset := itchPatch(previous)
+	// Игра с манифестом всегда синхронизируется по нему: нужен только сам манифест,
+	// файлы игры скачиваются по одному во время синхронизации
+	syncAsset := config.SyncManifest
+	if syncAsset != "" {
+		patchAsset = ""
+		assets = []string{syncAsset}
+	} else if patchAsset != "" {
+		assets = []string{patchAsset}
+	}
+	var upfront, pending []string
+	pipelined := make(map[string]bool)
+	for _, asset := range assets {
+		if source.Remote(asset) && !config.SquashfsMount && asset != patchAsset && asset != syncAsset {
+			pending = append(pending, asset)
+			pipelined[asset] = true
+		} else {
+			upfront = append(upfront, asset)
 		}
-
-		return nil
-	})
-}
-
-// copyFile копирует файл из src в dst и устанавливает права на исполнение
-func copyFile(src, dst string) error {
-	in, err := os.Open(src)
-	if err != nil {
-		return err
 	}
-	defer in.Close()
-
-	out, err := os.Create(dst)
+	endPhase := beginPhase("получение ресурсов")
+	paths, err := fetchAssets(upfront)
+	endPhase()
 	if err != nil {
-		return err
+		closeArchives()
+		failInstallation(failure.Download, "Не удалось получить файлы игры: "+err.Error())
+		installButton.SetEnabled(true)
+		installButton.SetText("Начать установку")
+		return
 	}
-	defer out.Close()
 
-	_, err = io.Copy(out, in)
-	if err != nil {
-		return err
+	// Образ squashfs в режиме монтирования копируется целиком, его не нужно открывать
+	mountImage := ""
+	if config.SquashfsMount {
+		mountImage = findMountableImage(paths)
+		if mountImage != "" {
+			totalFiles++
+		}
 	}
 
-	return os.Chmod(dst, 0755) // Устанавливаем права на исполнение
-}
-
-// saveInstallInfo сохраняет информацию об установке в директории игры
-func saveInstallInfo() error {
-	logsDir := filepath.Join(config.InstallPath, "logs")
-	if err := os.MkdirAll(logsDir, 0755); err != nil {
-		return fmt.Errorf("не удалось создать директорию для логов: %v", err)
+	// Размер игры после установки и пиковый объем временных файлов: распаковщики
+	// внешними программами держат во временной директории весь архив сразу
+	var finalBytes, stagingBytes int64
+	if mountImage != "" {
+		if info, err := os.Stat(paths[mountImage]); err == nil {
+			finalBytes += info.Size()
+		}
 	}
-
-	gameName := strings.ToLower(config.DesktopEntry.Name)
-	gameName = strings.ReplaceAll(gameName, " ", "-")
-	infoFilePath := filepath.Join(logsDir, gameName+"-install.json")
-
-	installInfo.GameName = config.DesktopEntry.Name
-	installInfo.InstallPath = config.InstallPath
-	installInfo.InstallDate = time.Now()
-	installInfo.InstallerPath, _ = os.Executable()
-	installInfo.InstallerDir = filepath.Dir(installInfo.InstallerPath)
-	installInfo.UninstallerPath = filepath.Join(config.InstallPath, "uninstaller")
-
-	data, err := json.MarshalIndent(installInfo, "", "  ")
-	if err != nil {
-		return fmt.Errorf("ошибка при сериализации информации об установке: %v", err)
+	// Патч не распаковывается, а применяется к установленной игре. Изменятся
+	// не больше данных, чем в нем есть.
+	if patchAsset != "" {
+		totalFiles += patchSteps
+		if info, err := os.Stat(paths[patchAsset]); err == nil {
+			finalBytes += info.Size()
+		}
 	}
 
-	if err := ioutil.WriteFile(infoFilePath, data, 0644); err != nil {
-		return fmt.Errorf("ошибка при сохранении информации об установке: %v", err)
+	var syncManifest *manifest.Manifest
+	if syncAsset != "" {
+		if syncManifest, err = manifest.Load(paths[syncAsset]); err != nil {
+			closeArchives()
+			failInstallation(failure.Download, err.Error())
+			installButton.SetEnabled(true)
+			installButton.SetText("Начать установку")
+			return
+		}
+		totalFiles += patchSteps
+		finalBytes += syncManifest.TotalBytes()
 	}
 
-	log.Printf("Информация об установке сохранена в %s", infoFilePath)
-	return nil
-}
-
-func startInstallation() {
-	// Блокируем кнопку на время установки и меняем текст
-	installButton.SetEnabled(false)
-	installButton.SetText("Установка...")
-
-	// Подсчет общего размера файлов для прогрессбара
-	totalFiles := 0
-	zipFiles := make(map[string]*zip.ReadCloser)
-
-	// Открываем все zip-файлы для подсчета содержимого
-	for _, asset := range config.GameAssets {
-		r, err := zip.OpenReader(asset)
+	// Открываем все архивы для подсчета содержимого. Скачиваемые по ходу установки
+	// архивы будут открыты и проверены перед их распаковкой.
+	endPhase = beginPhase("открытие архивов")
+	for _, asset := range assets {
+		if asset == mountImage || asset == patchAsset || asset == syncAsset || pipelined[asset] {
+			continue
+		}
+		ext, err := archive.Open(paths[asset])
+		if err == nil {
+			extractors[asset] = ext
+			entries[asset], err = ext.Enumerate()
+		}
+		if err == nil {
+			finalBytes += ext.TotalBytes()
+			if stager, ok := ext.(archive.Stager); ok && stager.StagingBytes() > stagingBytes {
+				stagingBytes = stager.StagingBytes()
+			}
+		}
+		// Архив со сменного носителя откроем заново перед распаковкой:
+		// к тому времени в приводе может оказаться другой диск
+		if _, isMedia := source.MediaLabel(asset); isMedia && err == nil {
+			ext.Close()
+			delete(extractors, asset)
+		}
 		if err != nil {
-			displayError("Ошибка при открытии архива: " + err.Error())
+			closeArchives()
+			failInstallation(failure.Extraction, "Ошибка при открытии архива: "+err.Error())
 			installButton.SetEnabled(true)
 			installButton.SetText("Начать установку")
 			return
 		}
-		zipFiles[asset] = r
-		totalFiles += len(r.File)
+		totalFiles += len(entries[asset])
 	}
 
 	// Если нет файлов для распаковки
-	if totalFiles == 0 {
-		displayError("Архивы пусты или повреждены")
+	if totalFiles == 0 && len(pending) == 0 {
+		closeArchives()
+		failInstallation(failure.Extraction, "Архивы пусты или повреждены")
 		installButton.SetEnabled(true)
 		installButton.SetText("Начать установку")
 		return
 	}
 
-	// Проверяем свободное место на диске
-	freeSpaceGB, err := checkDiskSpace()
+	// Ищем записи, которые пытаются выйти за пределы директории установки
+	quarantined := make(map[string]bool)
+	if unsafe := scanUnsafeEntries(entries); len(unsafe) > 0 {
+		if !config.AllowUnsafeEntries {
+			closeArchives()
+			showSecurityReport(unsafe, true)
+			installButton.SetEnabled(true)
+			installButton.SetText("Начать установку")
+			return
+		}
+
+		showSecurityReport(unsafe, false)
+		for _, e := range unsafe {
+			quarantined[e.Archive+"\x00"+e.Name] = true
+		}
+		totalFiles -= len(unsafe)
+	}
+
+	endPhase()
+
+	// Проверяем свободное место на диске. Минимум из конфигурации действует,
+	// даже если архивы занимают меньше.
+	finalGB := bytesToGB(finalBytes)
+	if finalGB < config.MinRequiredSpaceGB {
+		finalGB = config.MinRequiredSpaceGB
+	}
+	showSpaceInfo(finalGB, bytesToGB(stagingBytes))
+	shortage, err := checkSpace(finalGB, bytesToGB(stagingBytes))
 	if err != nil {
-		displayError("Ошибка при проверке дискового пространства: " + err.Error())
+		closeArchives()
+		failInstallation(failure.Space, "Ошибка при проверке дискового пространства: "+err.Error())
 		installButton.SetEnabled(true)
 		installButton.SetText("Начать установку")
 		return
 	}
-
-	// Проверяем требуемое минимальное пространство из конфигурации
-	if freeSpaceGB < config.MinRequiredSpaceGB {
-		displayError(fmt.Sprintf("Недостаточно места для установки. Свободно: %.2f ГБ, требуется: %.2f ГБ.",
-			freeSpaceGB, config.MinRequiredSpaceGB))
+	if shortage != "" {
+		closeArchives()
+		failInstallation(failure.Space, shortage)
 		installButton.SetEnabled(true)
 		installButton.SetText("Начать установку")
 		return
 	}
 
 	// Создаем базовую директорию для установки
-	err = os.MkdirAll(config.InstallPath, os.ModePerm)
+	installInfo.Snapshot = prepareVolume(previous)
+	// Для пробного запуска игра распаковывается рядом и переносится на место после подтверждения
+	extractRoot := config.InstallPath
+	if trialAllowed(mountImage) {
+		extractRoot = trialDir()
+		os.RemoveAll(extractRoot)
+	}
+	err = os.MkdirAll(extractRoot, os.ModePerm)
 	if err != nil {
-		displayError("Не удалось создать директорию для установки: " + err.Error())
+		failInstallation(failure.Classify(err, failure.Permissions), "Не удалось создать директорию для установки: "+err.Error())
 		installButton.SetEnabled(true)
 		installButton.SetText("Начать установку")
 		return
@@ -283,10 +3743,47 @@ func startInstallation() {
 	progressBar.SetValue(0)
 	progressBar.Show()
 
+	// При загрузке во время распаковки прогресс считается в байтах по обеим фазам
+	var pipeline *pipelineProgress
+	if len(pending) > 0 {
+		pipeline = newPipelineProgress(pending)
+		for _, asset := range upfront {
+			if asset == mountImage {
+				if info, err := os.Stat(paths[asset]); err == nil {
+					pipeline.open(asset, info.Size(), 1)
+				}
+				continue
+			}
+			size, files := countEntries(asset, entries[asset], quarantined)
+			pipeline.open(asset, size, files)
+		}
+		progressBar.SetRange(0, 1000)
+	}
+
 	// Создаем канал для обновления прогрессбара
 	updateChan := make(chan int)
-	errorChan := make(chan string)
+	errorChan := make(chan warnings.Entry)
 	doneChan := make(chan bool)
+	mediaChan := make(chan string)
+	mediaReply := make(chan bool)
+	userDataChan := make(chan []engine.ChangedFile)
+	userDataReply := make(chan bool)
+	savesChan := make(chan *cloudsave.Manifest)
+	savesReply := make(chan bool)
+	abortChan := make(chan failure.Error)
+	trialChan := make(chan string)
+	trialReply := make(chan bool)
+	estimateChan := make(chan time.Duration)
+	downloadChan := make(chan string)
+
+	// Оценка оставшегося времени по объему распакованных данных
+	tracker := estimate.NewTracker(finalBytes)
+
+	// На стенде киоска экран не гаснет, пока идет установка
+	var idleLock *kiosk.Inhibitor
+	if config.Kiosk.Enabled {
+		idleLock = kiosk.Inhibit(config.DesktopEntry.Name, "Идет установка")
+	}
 
 	// Обработчик сообщений от горутины установки
 	go func() {
@@ -294,19 +3791,123 @@ func startInstallation() {
 			select {
 			case progress := <-updateChan:
 				// Обновляем прогрессбар
+				if pipeline != nil {
+					showPipelineProgress(pipeline, tracker, "")
+					continue
+				}
 				progressBar.SetValue(progress)
-				progressBar.SetFormat(fmt.Sprintf("%d%% (%d/%d)", progress*100/totalFiles, progress, totalFiles))
-			case errMsg := <-errorChan:
-				// Показываем сообщение об ошибке
-				widgets.QMessageBox_Warning(nil, "Предупреждение", errMsg, widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+				text := fmt.Sprintf("%d%% (%d/%d)", progress*100/totalFiles, progress, totalFiles)
+				if left, ok := tracker.Remaining(); ok {
+					text += ", осталось " + formatDuration(left)
+				}
+				progressBar.SetFormat(text)
+			case name := <-downloadChan:
+				// Идет загрузка следующего архива
+				showPipelineProgress(pipeline, tracker, name)
+			case expected := <-estimateChan:
+				progressBar.SetFormat("Ожидаемое время установки: " + formatDuration(expected))
+			case warning := <-errorChan:
+				// Предупреждение копится в панели, а не прерывает установку окном
+				addWarning(warning)
+			case label := <-mediaChan:
+				// Распаковка приостановлена до смены носителя
+				progressBar.SetFormat(fmt.Sprintf("Ожидание носителя «%s»", label))
+				progressVoice.Say(fmt.Sprintf("Вставьте носитель «%s»", label))
+				inserted := waitForMedia(label)
+				recordChoice("носитель «"+label+"» вставлен", yesNo(inserted))
+				mediaReply <- inserted
+			case changed := <-userDataChan:
+				// Распаковка закончена, ждем решения о файлах пользователя
+				overwrite := confirmUserDataOverwrite(changed)
+				recordChoice(fmt.Sprintf("заменить измененные файлы пользователя (%d)", len(changed)), yesNo(overwrite))
+				userDataReply <- overwrite
+			case remote := <-savesChan:
+				// Сохранения изменились и здесь, и в облаке
+				progressBar.SetFormat("Ожидание выбора сохранений")
+				useCloud := chooseCloudSaves(remote)
+				recordChoice("сохранения из облака с "+remote.Host, yesNo(useCloud))
+				savesReply <- useCloud
+			case fail := <-abortChan:
+				// Установка прервана, дальше горутина установки ничего не делает
+				progressVoice.Say("Установка прервана")
+				idleLock.Release()
+				displayFailure(fail.Kind, fail.Message)
+				progressBar.Hide()
+				installButton.SetEnabled(true)
+				installButton.SetText("Начать установку")
+				return
+			case root := <-trialChan:
+				// Игра распакована, ждем решения после пробного запуска
+				progressBar.SetFormat("Распаковано, ожидание подтверждения")
+				keep := tryBeforeInstall(root)
+				recordChoice("оставить игру после пробного запуска", yesNo(keep))
+				if !keep {
+					trialReply <- false
+					idleLock.Release()
+					progressBar.Hide()
+					installButton.SetEnabled(true)
+					installButton.SetText("Начать установку")
+					return
+				}
+				trialReply <- true
 			case <-doneChan:
 				// Установка завершена
-				progressBar.SetValue(totalFiles)
+				progressBar.SetValue(progressBar.Maximum())
 				progressBar.SetFormat("100% - Установка завершена")
-				widgets.QMessageBox_Information(nil, "Установка завершена",
-					"Установка игры успешно завершена!", widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+				progressVoice.Say("Установка завершена")
+				idleLock.Release()
 				installButton.SetEnabled(true)
 				installButton.SetText("Начать установку")
+
+				// Киоск сразу запускает игру, отвечать на окно завершения некому
+				if config.Kiosk.Enabled && config.Kiosk.LaunchGame && installInfo.ExecPath != "" {
+					if err := launchGame(); err != nil {
+						displayError("Не удалось запустить игру: " + err.Error())
+						return
+					}
+					core.QCoreApplication_Exit(0)
+					return
+				}
+
+				installFinished = true
+				if answers != nil {
+					log.Printf("Автоматическая установка завершена: %s", config.InstallPath)
+					core.QCoreApplication_Exit(0)
+					return
+				}
+
+				msgBox := widgets.NewQMessageBox(nil)
+				msgBox.SetWindowTitle("Установка завершена")
+				msgBox.SetIcon(widgets.QMessageBox__Information)
+				msgBox.SetText("Установка игры успешно завершена!")
+				showCompanionCode(msgBox)
+				// Путь можно выделить и скопировать, а папку открыть кнопкой
+				informative := "Игра установлена в " + config.InstallPath
+				if code := common.SupportCode(installInfo.InstallID); code != "" {
+					informative += "\nКод для поддержки: " + code
+				}
+				msgBox.SetInformativeText(informative)
+				msgBox.SetTextInteractionFlags(core.Qt__TextBrowserInteraction)
+				var launchButton *widgets.QPushButton
+				if installInfo.ExecPath != "" {
+					launchButton = msgBox.AddButton2("Запустить игру", widgets.QMessageBox__AcceptRole)
+				}
+				openButton := msgBox.AddButton2("Открыть папку", widgets.QMessageBox__ActionRole)
+				msgBox.AddButton2("Закрыть", widgets.QMessageBox__RejectRole)
+				msgBox.Exec()
+
+				if msgBox.ClickedButton().Pointer() == openButton.Pointer() {
+					pathlabel.Open(config.InstallPath)
+				}
+
+				if launchButton != nil && msgBox.ClickedButton().Pointer() == launchButton.Pointer() {
+					if err := launchGame(); err != nil {
+						displayError("Не удалось запустить игру: " + err.Error())
+						return
+					}
+					// Игра работает в своей сессии, установщик больше не нужен
+					core.QCoreApplication_Exit(0)
+				}
 				return
 			}
 		}
@@ -316,71 +3917,162 @@ func startInstallation() {
 	go func() {
 		extractedFiles := 0
 
-		// Распаковка файлов
-		for _, asset := range config.GameAssets {
-			r := zipFiles[asset]
-			defer r.Close()
+		// Полученные из источников временные файлы больше не нужны после распаковки
+		defer source.Cleanup()
 
-			for _, f := range r.File {
-				fpath := filepath.Join(config.InstallPath, f.Name)
+		// Замер скорости диска дает оценку времени еще до начала распаковки
+		if config.DiskBenchmark {
+			endPhase := beginPhase("замер скорости диска")
+			expected, ok := calibrateEstimate(tracker)
+			endPhase()
+			if ok {
+				estimateChan <- expected
+			}
+		}
+		endPhase := beginPhase("распаковка")
 
-				// Проверка на путь выхода за пределы
-				if !strings.HasPrefix(fpath, filepath.Clean(config.InstallPath)+string(os.PathSeparator)) {
-					errorChan <- "Обнаружена попытка распаковки за пределы директории установки"
-					continue
+		// Распаковка файлов, параллельно скачиваются архивы из сети
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var fetched <-chan fetchResult
+		if pipeline != nil {
+			fetched = fetchPipelined(ctx, pending, pipeline, downloadChan)
+		}
+		// При обновлении прежние версии заменяемых файлов сохраняются для отката.
+		// Патч butler применяет сам, поэтому для него резервной копии нет.
+		var saved *backup.Session
+		if patchAsset == "" {
+			saved = updateBackup(previous)
+		}
+		extraction := &engine.Extraction{
+			Root:        extractRoot,
+			UserData:    config.UserData,
+			Backup:      saved,
+			Quarantined: quarantined,
+			UI: &windowUI{
+				files:         &extractedFiles,
+				tracker:       tracker,
+				pipeline:      pipeline,
+				updateChan:    updateChan,
+				errorChan:     errorChan,
+				userDataChan:  userDataChan,
+				userDataReply: userDataReply,
+			},
+		}
+		for _, asset := range assets {
+			if asset == patchAsset {
+				err := applyItchPatch(ctx, paths[asset], func(fraction float64) {
+					updateChan <- extractedFiles + int(fraction*patchSteps)
+				})
+				if err != nil {
+					log.Printf("Ошибка обновления патчем itch.io: %v", err)
+					patchFailed = true
+					endPhase()
+					notifyWebhooks(previous, started, failure.Extraction, err.Error())
+					abortChan <- failure.Error{Kind: failure.Extraction, Message: "Не удалось обновить игру патчем: " + err.Error() +
+						"\n\nЗапустите установку еще раз, будет установлена полная версия."}
+					return
 				}
-
-				// Создаем директории для файлов
-				if f.FileInfo().IsDir() {
-					os.MkdirAll(fpath, os.ModePerm)
-					extractedFiles++
-					updateChan <- extractedFiles
-					continue
+				extractedFiles += patchSteps
+				updateChan <- extractedFiles
+				continue
+			}
+			if asset == syncAsset {
+				err := applySyncManifest(ctx, syncManifest, extractRoot, saved, func(fraction float64) {
+					updateChan <- extractedFiles + int(fraction*patchSteps)
+				})
+				if err != nil {
+					log.Printf("Ошибка синхронизации по манифесту: %v", err)
+					endPhase()
+					notifyWebhooks(previous, started, failure.Download, err.Error())
+					abortChan <- failure.Error{Kind: failure.Download, Message: "Не удалось синхронизировать файлы игры: " + err.Error() +
+						"\n\nЗапустите установку еще раз, уже полученные файлы скачиваться не будут."}
+					return
 				}
-
-				// Создание директорий для файла, если нет
-				if err := os.MkdirAll(filepath.Dir(fpath), os.ModePerm); err != nil {
-					errorChan <- "Не удалось создать директорию: " + err.Error()
-					continue
+				extractedFiles += patchSteps
+				updateChan <- extractedFiles
+				continue
+			}
+			if asset == mountImage {
+				if err := installSquashfsImage(filepath.Base(asset), paths[asset]); err != nil {
+					errorChan <- warnings.Entry{Message: "Ошибка установки образа", File: filepath.Base(asset), Detail: err.Error()}
+				}
+				if info, err := os.Stat(paths[asset]); err == nil {
+					tracker.Add(info.Size())
+					if pipeline != nil {
+						pipeline.extract(info.Size())
+					}
 				}
+				extractedFiles++
+				updateChan <- extractedFiles
+				continue
+			}
 
-				// Создание файла
-				outFile, err := os.Create(fpath)
+			ext := extractors[asset]
+			if pipelined[asset] {
+				// Архив скачан, пока распаковывались предыдущие
+				var err error
+				ext, err = openFetched(<-fetched, entries, quarantined)
 				if err != nil {
-					errorChan <- "Не удалось создать файл: " + err.Error()
+					errorChan <- warnings.Entry{Message: "Архив пропущен", File: filepath.Base(asset), Detail: err.Error()}
 					continue
 				}
-
-				// Копирование содержимого
-				rc, err := f.Open()
+				size, files := countEntries(asset, entries[asset], quarantined)
+				pipeline.open(asset, size, files)
+				tracker.Grow(size)
+			} else if ext == nil {
+				// Ресурс на сменном носителе: ждем нужный диск и открываем архив заново
+				label, _ := source.MediaLabel(asset)
+				if source.FindMedia(label) == "" {
+					mediaChan <- label
+					if !<-mediaReply {
+						errorChan <- warnings.Entry{Message: "Носитель «" + label + "» не вставлен, файлы пропущены", File: filepath.Base(asset)}
+						continue
+					}
+				}
+				path, err := source.Fetch(ctx, asset, nil)
+				if err == nil {
+					ext, err = archive.Open(path)
+				}
 				if err != nil {
-					outFile.Close()
-					errorChan <- "Не удалось открыть файл в архиве: " + err.Error()
+					errorChan <- warnings.Entry{Message: "Ошибка при открытии архива", File: filepath.Base(asset), Detail: err.Error()}
 					continue
 				}
+			}
 
-				_, err = io.Copy(outFile, rc)
-				rc.Close()
-				outFile.Close()
+			archiveStart := time.Now()
+			archiveBytes, err := extraction.Archive(ctx, asset, ext, entries[asset])
+			if err != nil {
+				ext.Close()
+				endPhase()
+				abortChan <- failure.Error{Kind: failure.Extraction, Message: "Распаковка прервана: " + err.Error()}
+				return
+			}
 
-				if err != nil {
-					errorChan <- "Ошибка копирования данных: " + err.Error()
-					continue
-				}
+			// Закрываем архив сразу, чтобы носитель можно было извлечь
+			ext.Close()
+			profiler.Archive(filepath.Base(asset), archiveBytes, time.Since(archiveStart))
+		}
+		endPhase()
+		endPhase = beginPhase("права, ярлыки и запись данных")
 
-				extractedFiles++
-				updateChan <- extractedFiles
+		// Файлы пользователя заменяем только с его согласия
+		extraction.Finish()
+		if saved != nil {
+			if err := backup.Prune(config.InstallPath, keepBackups()); err != nil {
+				log.Printf("Ошибка при удалении старых резервных копий: %v", err)
 			}
 		}
 
-		// Устанавливаем права на исполнение для основного исполняемого файла
-		if config.ExecPath != "" {
-			execFullPath := filepath.Join(config.InstallPath, config.ExecPath)
+		// Устанавливаем права на исполнение для основного исполняемого файла.
+		// Внутри смонтированного образа права уже заданы при его сборке.
+		if config.ExecPath != "" && mountImage == "" {
+			execFullPath := filepath.Join(extractRoot, config.ExecPath)
 			log.Printf("Устанавливаем права на исполнение для основного файла: %s", execFullPath)
 
 			if err := setExecutablePermissions(execFullPath); err != nil {
 				log.Printf("Ошибка при установке прав на исполнение: %v", err)
-				errorChan <- "Не удалось установить права на исполнение для игры: " + err.Error()
+				errorChan <- warnings.Entry{Message: "Не удалось установить права на исполнение для игры", Detail: err.Error()}
 			} else {
 				log.Printf("Права на исполнение успешно установлены для основного файла")
 			}
@@ -392,29 +4084,108 @@ func startInstallation() {
 		// Если указаны директории для поиска исполняемых файлов
 		if len(config.ExecDirs) > 0 {
 			for _, dir := range config.ExecDirs {
-				fullDir := filepath.Join(config.InstallPath, dir)
+				fullDir := filepath.Join(extractRoot, dir)
 				makeFilesExecutable(fullDir, []string{"*.sh", "*.bin", "*.x86", "*.x86_64"})
 			}
 		} else {
 			// Иначе ищем во всей директории установки
-			makeFilesExecutable(config.InstallPath, []string{"*.sh", "*.bin", "*.x86", "*.x86_64"})
+			makeFilesExecutable(extractRoot, []string{"*.sh", "*.bin", "*.x86", "*.x86_64"})
+		}
+
+		// Пробный запуск до переноса игры на место; при отказе распакованное удаляется
+		if extractRoot != config.InstallPath {
+			trialChan <- extractRoot
+			if !<-trialReply {
+				os.RemoveAll(extractRoot)
+				endPhase()
+				return
+			}
 		}
 
 		// Копируем uninstaller в директорию игры
-		installerDir := filepath.Dir(os.Args[0])
-		uninstallerSrc := filepath.Join(installerDir, "uninstaller")
 		uninstallerDst := filepath.Join(config.InstallPath, "uninstaller")
 
-		if err := copyFile(uninstallerSrc, uninstallerDst); err != nil {
+		if err := installUninstaller(uninstallerDst); err != nil {
 			log.Printf("Ошибка при копировании деинсталлятора: %v", err)
-			errorChan <- "Не удалось скопировать деинсталлятор: " + err.Error()
+			errorChan <- warnings.Entry{Message: "Не удалось скопировать деинсталлятор", Detail: err.Error()}
 		} else {
 			log.Printf("Деинсталлятор успешно скопирован в %s", uninstallerDst)
 		}
 
+		// Записываем значения с дополнительных страниц для игры, секреты — в связку ключей
+		if err := storeSecrets(); err != nil {
+			errorChan <- warnings.Entry{Message: "Секреты игры не сохранены", Detail: err.Error()}
+		}
+		if err := saveOptionsFile(); err != nil {
+			log.Printf("Ошибка при сохранении файла настроек: %v", err)
+			errorChan <- warnings.Entry{Message: "Не удалось сохранить файл настроек", File: config.OptionsFile, Detail: err.Error()}
+		}
+		if err := saveLanguageFile(); err != nil {
+			log.Printf("Ошибка при сохранении языка игры: %v", err)
+			errorChan <- warnings.Entry{Message: "Не удалось сохранить язык игры", File: config.LanguageFile, Detail: err.Error()}
+		}
+		// Настройки сразу после установки — то, к чему менеджер сбрасывает настройки игры
+		if err := maintenance.SaveDefaults(config.InstallPath, maintenanceConfig()); err != nil {
+			log.Printf("Ошибка при сохранении настроек первого запуска: %v", err)
+		}
+
+		// Упаковываем игру во Flatpak со всеми записанными выше файлами; ярлыки
+		// тогда запускают приложение, а не файлы из директории игры
+		if config.FlatpakExport {
+			if err := exportFlatpak(); err != nil {
+				log.Printf("Ошибка при упаковке во Flatpak: %v", err)
+				errorChan <- warnings.Entry{Message: "Не удалось упаковать игру во Flatpak, она установлена обычным образом", Detail: err.Error()}
+			}
+		}
+
 		// Создаем ярлык если нужно
 		if createShortcutCheckBox.IsChecked() {
 			createShortcut()
+			if pinCheckBox.IsChecked() && installInfo.MenuFile != "" {
+				pinShortcut()
+			}
+		}
+		removeOldShortcuts(previous)
+
+		// Файлы игры теперь внутри Flatpak, копия в директории игры не нужна
+		if installInfo.Flatpak != nil {
+			if err := removeNativeFiles(); err != nil {
+				log.Printf("Ошибка при удалении файлов, перенесенных во Flatpak: %v", err)
+			}
+		}
+
+		// Оставляем метку, подтверждающую, что директория создана установщиком
+		if err := writeSentinel(); err != nil {
+			log.Printf("Ошибка при создании файла-метки: %v", err)
+		}
+
+		// Квитанция в пакетной базе, чтобы игру видели системные средства учета ПО
+		if config.SystemReceipt {
+			if err := registerReceipt(previous, finalBytes); err != nil {
+				log.Printf("Ошибка при регистрации в пакетной базе: %v", err)
+				errorChan <- warnings.Entry{Message: "Не удалось зарегистрировать игру в пакетной базе", Detail: err.Error()}
+			}
+		}
+
+		// Снимок исходного состояния для мгновенного отката и проверки файлов
+		if installInfo.Snapshot != nil {
+			if err := installInfo.Snapshot.Take(config.Version); err != nil {
+				log.Printf("Не удалось сделать снимок установки: %v", err)
+			} else {
+				installInfo.Snapshot.Prune(keepBackups())
+			}
+		}
+
+		// Сохранения из облака восстанавливают прогресс после переустановки
+		if config.CloudSaves.Enabled() {
+			err := pullSaves(ctx, previous, func(remote *cloudsave.Manifest) bool {
+				savesChan <- remote
+				return <-savesReply
+			})
+			if err != nil {
+				log.Printf("Ошибка синхронизации сохранений: %v", err)
+				errorChan <- warnings.Entry{Message: "Не удалось получить сохранения из облака", Detail: err.Error()}
+			}
 		}
 
 		// Сохраняем информацию об установке
@@ -422,24 +4193,124 @@ func startInstallation() {
 			log.Printf("Ошибка при сохранении информации об установке: %v", err)
 		}
 
+		endPhase()
+		if profiler != nil {
+			reportPath, err := profiler.Finish(filepath.Join(config.InstallPath, "logs"), config.DesktopEntry.Name+" "+config.Version)
+			if err != nil {
+				log.Printf("Ошибка при сохранении отчета о производительности: %v", err)
+			} else {
+				log.Printf("Отчет о производительности сохранен в %s", reportPath)
+			}
+			profiler = nil
+		}
+
+		// Уведомления отправляются до сообщения о завершении: автоматическая
+		// установка сразу после него завершает установщик
+		notifyWebhooks(previous, started, "", "")
+
+		// Журнал остается у игры: менеджер приложит его к отчету для поддержки
+		if err := report.AppendLog(filepath.Join(config.InstallPath, "logs", "install.log")); err != nil {
+			log.Printf("Ошибка при сохранении журнала установки: %v", err)
+		}
+
 		// Сигнализируем о завершении установки
 		doneChan <- true
 	}()
 }
 
+// convertedIconSize — сторона PNG, в который преобразуются иконки других форматов
+const convertedIconSize = 256
+
+// convertedIconName — преобразованная иконка в директории игры
+const convertedIconName = ".go-qt-installer-icon.png"
+
+// needsIconConversion сообщает, что формат иконки плохо поддерживается в .desktop
+func needsIconConversion(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ico", ".bmp", ".svg", ".svgz":
+		return true
+	}
+	return false
+}
+
+// convertIcon преобразует иконку ICO, BMP или SVG в квадратный PNG не больше
+// convertedIconSize средствами Qt и возвращает путь к нему
+func convertIcon(src string) (string, error) {
+	// В ICO хранится несколько изображений разного размера, выбираем самое большое
+	reader := gui.NewQImageReader3(src, core.NewQByteArray())
+	if !reader.CanRead() {
+		return "", fmt.Errorf("не удалось прочитать %s: %s", src, reader.ErrorString())
+	}
+	best, bestArea := 0, -1
+	for i := 0; i < reader.ImageCount(); i++ {
+		if !reader.JumpToImage(i) {
+			break
+		}
+		size := reader.Size()
+		if area := size.Width() * size.Height(); area > bestArea {
+			best, bestArea = i, area
+		}
+	}
+
+	reader = gui.NewQImageReader3(src, core.NewQByteArray())
+	if best > 0 {
+		reader.JumpToImage(best)
+	}
+	// Векторную иконку сразу отрисовываем в нужном размере, а не растягиваем растр
+	ext := strings.ToLower(filepath.Ext(src))
+	if size := reader.Size(); (ext == ".svg" || ext == ".svgz") && size.Width() > 0 && size.Height() > 0 {
+		scaled := size.Scaled2(core.NewQSize2(convertedIconSize, convertedIconSize), core.Qt__KeepAspectRatio)
+		reader.SetScaledSize(scaled)
+	}
+	image := reader.Read()
+	if image.IsNull() {
+		return "", fmt.Errorf("не удалось прочитать %s: %s", src, reader.ErrorString())
+	}
+	if image.Width() > convertedIconSize || image.Height() > convertedIconSize {
+		image = image.Scaled2(convertedIconSize, convertedIconSize, core.Qt__KeepAspectRatio, core.Qt__SmoothTransformation)
+	}
+
+	// Иконка должна быть квадратной: дополняем прозрачными полями
+	side := image.Width()
+	if image.Height() > side {
+		side = image.Height()
+	}
+	canvas := gui.NewQImage3(side, side, gui.QImage__Format_ARGB32)
+	canvas.Fill3(core.Qt__transparent)
+	painter := gui.NewQPainter2(canvas)
+	painter.DrawImage9((side-image.Width())/2, (side-image.Height())/2, image, 0, 0, -1, -1, core.Qt__AutoColor)
+	painter.End()
+
+	dst := filepath.Join(config.InstallPath, convertedIconName)
+	if !canvas.Save(dst, "PNG", -1) {
+		return "", fmt.Errorf("не удалось сохранить %s", dst)
+	}
+	return dst, nil
+}
+
 func createShortcut() {
+	// Неполадки окружения попадают в журнал установки: по нему поддержка поймет,
+	// почему ярлык не появился
+	for _, c := range diagnostics.Run() {
+		if c.Status != diagnostics.Pass {
+			log.Printf("Диагностика: %s: %s. %s", c.Name, c.Detail, c.Hint)
+		}
+	}
+
 	// Для Linux
-	appDir := filepath.Join(os.Getenv("HOME"), ".local", "share", "applications")
+	appDir := common.ApplicationsDir()
 	os.MkdirAll(appDir, os.ModePerm)
 
-	// Имя файла .desktop на основе названия приложения
-	appName := strings.ToLower(config.DesktopEntry.Name)
-	appName = strings.ReplaceAll(appName, " ", "-")
+	// Имя файла .desktop — идентификатор в стиле обратного DNS, чтобы не совпасть с ярлыками дистрибутива
+	appName := installInfo.AppID
 	desktopFile := filepath.Join(appDir, appName+".desktop")
 
 	// Создание исполняемого пути, если в конфиге указана только относительная часть
-	execPath := config.DesktopEntry.Exec
-	if !filepath.IsAbs(execPath) {
+	execPath := expandTemplate(config.DesktopEntry.Exec)
+	if launcherPath != "" && !filepath.IsAbs(execPath) {
+		// Файлы игры лежат внутри образа и доступны только через скрипт запуска
+		execPath = launcherPath
+	} else if !filepath.IsAbs(execPath) {
 		execPath = filepath.Join(config.InstallPath, execPath)
 	}
 
@@ -455,9 +4326,6 @@ func createShortcut() {
 	} else if config.IconPath != "" {
 		// Используем иконку из основного конфига
 		iconPath = config.IconPath
-		if !filepath.IsAbs(iconPath) {
-			iconPath = filepath.Join(filepath.Dir(os.Args[0]), iconPath)
-		}
 	}
 
 	// Проверяем существование файла иконки
@@ -478,11 +4346,43 @@ func createShortcut() {
 		}
 	}
 
+	// Иконки Windows-игр и векторные иконки переводим в PNG, с которыми .desktop работает везде
+	if iconPath != "" && needsIconConversion(iconPath) {
+		if converted, err := convertIcon(iconPath); err != nil {
+			log.Printf("Ошибка преобразования иконки: %v", err)
+		} else {
+			log.Printf("Иконка %s преобразована в %s", iconPath, converted)
+			iconPath = converted
+		}
+	}
+
+	installInfo.IconPath = iconPath
+
 	// Формирование содержимого файла .desktop
 	content := "[Desktop Entry]\n"
 	content += "Type=" + config.DesktopEntry.Type + "\n"
-	content += "Name=" + config.DesktopEntry.Name + "\n"
-	content += "Exec=\"" + execPath + "\"\n"
+	content += "Name=" + expandTemplate(config.DesktopEntry.Name) + "\n"
+	if launcherPath != "" {
+		content += "Exec=\"" + execPath + "\"\n"
+	} else {
+		// Окружение передаем через env, аргументы и рабочую директорию — ключами Exec и Path
+		execLine := ""
+		if env := launchEnv(); len(env) > 0 {
+			execLine = "env"
+			for _, kv := range env {
+				execLine += " " + common.DesktopQuote(kv)
+			}
+			execLine += " "
+		}
+		execLine += common.DesktopQuote(execPath)
+		for _, arg := range launchArgs() {
+			execLine += " " + common.DesktopQuote(arg)
+		}
+		content += "Exec=" + execLine + "\n"
+		if config.Launch.WorkDir != "" {
+			content += "Path=" + filepath.Join(config.InstallPath, config.Launch.WorkDir) + "\n"
+		}
+	}
 
 	if iconPath != "" {
 		content += "Icon=" + iconPath + "\n"
@@ -490,51 +4390,73 @@ func createShortcut() {
 
 	content += "Terminal=" + fmt.Sprintf("%t", config.DesktopEntry.Terminal) + "\n"
 
-	if config.DesktopEntry.Categories != "" {
-		content += "Categories=" + config.DesktopEntry.Categories + "\n"
+	categories, skipped := common.Categories(config.DesktopEntry.Genre, config.DesktopEntry.Categories)
+	for _, warning := range skipped {
+		log.Printf("Категории ярлыка: %s, пропускаем", warning)
 	}
+	content += "Categories=" + categories + "\n"
+	installInfo.Categories = categories
 
 	if config.DesktopEntry.Comment != "" {
-		content += "Comment=" + config.DesktopEntry.Comment + "\n"
+		content += "Comment=" + expandTemplate(config.DesktopEntry.Comment) + "\n"
 	}
 
 	// Добавляем дополнительные поля для лучшей совместимости
 	content += "Version=1.0\n"
 	content += "StartupNotify=true\n"
-	content += "StartupWMClass=" + config.DesktopEntry.Name + "\n"
+	// Название игры почти никогда не совпадает с классом окна, поэтому без известного
+	// класса ключ не пишем: его допишет менеджер после первого запуска
+	if config.DesktopEntry.StartupWMClass != "" {
+		content += "StartupWMClass=" + config.DesktopEntry.StartupWMClass + "\n"
+		installInfo.WMClass = config.DesktopEntry.StartupWMClass
+	}
+
+	// Пункт удаления в контекстном меню ярлыка; менеджер игр лежит в директории игры
+	uninstallEntry := uninstallEntryCheckBox.IsChecked() && !common.SystemWide
+	if uninstallEntry && uninstallEntryStyle() == common.UninstallAction {
+		content += common.UninstallActionEntry(&installInfo)
+		installInfo.UninstallEntry = common.UninstallAction
+	}
 
-	err := ioutil.WriteFile(desktopFile, []byte(content), 0755)
+	err := common.WriteShortcut(desktopFile, content)
 	if err != nil {
 		log.Printf("Ошибка при создании ярлыка: %v", err)
-		widgets.QMessageBox_Warning(nil, "Предупреждение",
-			"Не удалось создать ярлык в меню приложений: "+err.Error(),
-			widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+		addWarning(warnings.Entry{Message: "Не удалось создать ярлык в меню приложений", Detail: err.Error()})
 	} else {
 		log.Printf("Ярлык успешно создан: %s", desktopFile)
 
 		// Сохраняем путь к файлу .desktop для деинсталлятора
 		installInfo.MenuFile = desktopFile
 
-		// Разрешаем запуск на "GNOME 3 derivatives Desktop"
-		exec.Command("gio", "set", desktopFile, "metadata::trusted", "yes").Run()
-		exec.Command("killall", "nautilus-desktop").Run()
-		exec.Command("gio", "set", desktopFile, "metadata::trusted", "true").Run()
+		runner.Run("killall", "nautilus-desktop")
 
 		// Обновляем кэш иконок и приложений
-		exec.Command("gtk-update-icon-cache", "-f", "-t", filepath.Join(os.Getenv("HOME"), ".local", "share", "icons")).Run()
-		exec.Command("update-desktop-database", filepath.Join(os.Getenv("HOME"), ".local", "share", "applications")).Run()
+		runner.Run("gtk-update-icon-cache", "-f", "-t", filepath.Join(os.Getenv("HOME"), ".local", "share", "icons"))
+		common.UpdateDesktopDatabase()
+
+		// Ярлык записан, теперь проверяем, что он сработает из меню
+		verifyShortcut(desktopFile, "Ярлык в меню приложений не сработает")
 	}
 
-	// Создаем ярлык на рабочем столе, если нужно
-	desktopDir := filepath.Join(os.Getenv("HOME"), "Desktop")
-	if _, err := os.Stat(desktopDir); os.IsNotExist(err) {
-		// Если директория Desktop не существует, пробуем локализованное имя
-		desktopDir = filepath.Join(os.Getenv("HOME"), "Рабочий стол")
+	if uninstallEntry && uninstallEntryStyle() == common.UninstallMenu {
+		createUninstallShortcut(appName)
+	}
+
+	// При установке для всех ярлык на рабочем столе получит каждый пользователь при входе
+	if common.SystemWide {
+		if provisioned, err := deploy.Provision(appName, content); err != nil {
+			log.Printf("Ошибка при раздаче ярлыка пользователям: %v", err)
+		} else {
+			log.Printf("Ярлык будет раздан пользователям при входе: %s", provisioned)
+			installInfo.Provisioning = provisioned
+		}
+		return
 	}
 
-	if _, err := os.Stat(desktopDir); err == nil {
+	// Создаем ярлык на рабочем столе, если нужно
+	if desktopDir := common.DesktopDir(); desktopDir != "" {
 		desktopShortcut := filepath.Join(desktopDir, appName+".desktop")
-		if err := ioutil.WriteFile(desktopShortcut, []byte(content), 0755); err != nil {
+		if err := common.WriteShortcut(desktopShortcut, content); err != nil {
 			log.Printf("Ошибка при создании ярлыка на рабочем столе: %v", err)
 		} else {
 			log.Printf("Ярлык на рабочем столе успешно создан: %s", desktopShortcut)
@@ -542,89 +4464,662 @@ func createShortcut() {
 			// Сохраняем путь к файлу .desktop на рабочем столе для деинсталлятора
 			installInfo.DesktopFile = desktopShortcut
 
-			// Разрешаем запуск на "GNOME 3 derivatives Desktop"
-			exec.Command("gio", "set", desktopShortcut, "metadata::trusted", "yes").Run()
-			exec.Command("gio", "set", desktopShortcut, "metadata::trusted", "true").Run()
+			// Программу и иконку уже проверил ярлык меню, на рабочем столе важно разрешение запуска
+			if trusted, err := desktopfile.Trusted(desktopShortcut); err == nil && !trusted {
+				addWarning(warnings.Entry{Message: "Ярлык на рабочем столе не запустится двойным щелчком", File: desktopShortcut,
+					Detail: "GNOME не разрешает его запуск. Что сделать: нажмите на ярлык правой кнопкой и выберите «Разрешить запуск»"})
+			}
 		}
 	}
 }
 
+// uninstallEntryStyle возвращает вид пункта удаления игры из конфигурации
+func uninstallEntryStyle() string {
+	if config.UninstallEntry == common.UninstallMenu {
+		return common.UninstallMenu
+	}
+	return common.UninstallAction
+}
+
+// createUninstallShortcut создает отдельный ярлык «Удалить <игра>», который
+// открывает менеджер игр на этой игре
+func createUninstallShortcut(appID string) {
+	file := common.UninstallShortcutPath(appID)
+	if err := common.WriteShortcut(file, common.UninstallShortcut(&installInfo)); err != nil {
+		log.Printf("Ошибка при создании ярлыка удаления: %v", err)
+		addWarning(warnings.Entry{Message: "Не удалось добавить в меню пункт удаления игры", File: file, Detail: err.Error()})
+		return
+	}
+	log.Printf("Ярлык удаления создан: %s", file)
+	installInfo.UninstallEntry = common.UninstallMenu
+	installInfo.UninstallMenuFile = file
+	common.UpdateDesktopDatabase()
+	verifyShortcut(file, "Пункт удаления игры в меню не сработает")
+}
+
+// verifyShortcut проверяет записанный ярлык и о каждой неполадке сообщает
+// предупреждением с тем, что сделать, а не только строкой в журнале
+func verifyShortcut(path, message string) {
+	problems := desktopfile.Verify(path)
+	for _, p := range problems {
+		log.Printf("Проверка ярлыка %s: %s", path, p.Issue)
+		addWarning(warnings.Entry{Message: message + ": " + p.Issue, File: path, Detail: "Что сделать: " + p.Hint})
+	}
+	if len(problems) == 0 {
+		log.Printf("Ярлык %s проверен: программа и иконка на месте, меню его покажет", path)
+	}
+}
+
+// pinShortcut закрепляет ярлык в избранном KDE или кладет его в папку приложений
+// GNOME, чтобы игру было видно сразу после установки
+func pinShortcut() {
+	record, err := pin.Pin(filepath.Base(installInfo.MenuFile), config.AppFolder)
+	if err != nil {
+		log.Printf("Ошибка при закреплении ярлыка в меню: %v", err)
+		return
+	}
+	log.Printf("Ярлык закреплен в меню: %s", record.DesktopID)
+	installInfo.Pin = record
+}
+
+// displayError показывает ошибку без определенного класса
 func displayError(message string) {
-	widgets.QMessageBox_Critical(nil, "Ошибка", message, widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+	displayFailure(failure.Other, message)
+}
+
+// displayFailure показывает ошибку класса kind. Автоматическую установку она
+// завершает с кодом выхода этого класса.
+func displayFailure(kind failure.Kind, message string) {
+	lastError, lastErrorKind = message, kind
+	installEvents.Add(timeline.Error, string(kind), message)
+	saveFailureEvents()
+	if answers != nil {
+		// При автоматической установке ошибка завершает установщик, отвечать на нее некому
+		log.Printf("Ошибка (%s): %s", kind, message)
+		core.QCoreApplication_Exit(kind.ExitCode())
+		return
+	}
+
+	msgBox := widgets.NewQMessageBox(nil)
+	msgBox.SetWindowTitle("Ошибка")
+	msgBox.SetIcon(widgets.QMessageBox__Critical)
+	msgBox.SetText(message)
+	reportButton := msgBox.AddButton2("Отправить отчет в поддержку", widgets.QMessageBox__ActionRole)
+	msgBox.AddButton3(widgets.QMessageBox__Ok)
+	msgBox.Exec()
+	if msgBox.ClickedButton().Pointer() == reportButton.Pointer() {
+		sendSupportReport()
+	}
+}
+
+// lastError — последняя ошибка, показанная игроку; попадает в отчет для поддержки
+var lastError string
+
+// lastErrorKind — класс последней ошибки
+var lastErrorKind failure.Kind
+
+// installFinished — установка завершена; по нему видно, что автоматическую
+// установку не прервали закрытием окна
+var installFinished bool
+
+// exitConfigError завершает установщик, который не может начать работу из-за
+// ошибки в конфигурации или файле ответов
+func exitConfigError(err error) {
+	log.Printf("Ошибка конфигурации: %v", err)
+	os.Exit(failure.Config.ExitCode())
+}
+
+// exitCode — код выхода установщика после закрытия окна. Автоматическая
+// установка, окно которой закрыли до конца установки, считается отмененной.
+func exitCode(code int) int {
+	if code == 0 && answers != nil && !installFinished {
+		log.Printf("Окно автоматической установки закрыто до ее завершения")
+		return failure.Cancelled.ExitCode()
+	}
+	return code
+}
+
+// installSummary — сводка об установке для отчета. Ссылки без параметров: в них
+// бывают подписи и токены CDN. Значения полей мастера не попадают в отчет, среди
+// них могут быть секреты.
+func installSummary() map[string]interface{} {
+	var assets []string
+	for _, asset := range installAssets() {
+		assets = append(assets, strings.SplitN(asset, "?", 2)[0])
+	}
+	summary := map[string]interface{}{
+		"game":         config.DesktopEntry.Name,
+		"version":      config.Version,
+		"install_path": config.InstallPath,
+		"install_id":   installInfo.InstallID,
+		"support_code": common.SupportCode(installInfo.InstallID),
+		"slug":         installInfo.Slug,
+		"assets":       assets,
+		"system_wide":  config.SystemWide,
+		"language":     selectedLanguage,
+		"offline":      offline,
+		"last_error":   lastError,
+		"error_code":   lastErrorKind,
+	}
+	if config.SyncManifest != "" {
+		summary["sync_manifest"] = strings.SplitN(config.SyncManifest, "?", 2)[0]
+	}
+	if warningsPanel != nil && warningsPanel.Count() > 0 {
+		summary["warnings"] = warningsPanel.Summary()
+	}
+	// Неудачная установка не сохраняет запись, события попадают только в отчет
+	summary["events"] = installEvents.Merge(installInfo.Events)
+	return summary
+}
+
+// sendSupportReport отправляет издателю журнал этого запуска, сводку об установке
+// и сведения о системе и показывает номер обращения
+func sendSupportReport() {
+	r := &report.Report{
+		Game:      config.DesktopEntry.Name,
+		InstallID: installInfo.InstallID,
+		Summary:   installSummary(),
+		Log:       report.SessionLog(),
+		Dir:       config.InstallPath,
+	}
+	res, err := report.Send(context.Background(), config.SupportURL, r)
+	if err != nil {
+		widgets.QMessageBox_Critical(nil, "Ошибка", "Не удалось сохранить отчет: "+err.Error(), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+		return
+	}
+	msgBox := widgets.NewQMessageBox(nil)
+	msgBox.SetWindowTitle("Отчет для поддержки")
+	msgBox.SetIcon(widgets.QMessageBox__Information)
+	msgBox.SetText(res.Message())
+	// Номер обращения можно скопировать
+	msgBox.SetTextInteractionFlags(core.Qt__TextSelectableByMouse)
+	msgBox.Exec()
+}
+
+// displayWarning показывает предупреждение окном, до начала установки. Во время
+// установки предупреждения идут в addWarning и не останавливают ее.
+func displayWarning(message string) {
+	if answers != nil {
+		log.Printf("Предупреждение: %s", message)
+		return
+	}
+	widgets.QMessageBox_Warning(nil, "Предупреждение", message, widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+}
+
+// addWarning записывает предупреждение установки в журнал и панель предупреждений
+// и показывает уведомление. Окна с OK предупреждение не открывает: отвечать на
+// него не нужно, а установка идет дальше. Можно вызывать из любой горутины.
+func addWarning(e warnings.Entry) {
+	text := e.Message
+	if e.File != "" {
+		text += ": " + e.File
+	}
+	if e.Detail != "" {
+		text += ": " + e.Detail
+	}
+	log.Printf("Предупреждение: %s", text)
+	installEvents.Once(timeline.Warning, e.Message, text)
+	warningsPanel.Add(e)
+	warningToast.Show(text)
+}
+
+// startUnattended начинает установку по файлу ответов. Ответы проверяются так же,
+// как проверил бы их мастер установки.
+func startUnattended() {
+	if !passGate() {
+		return
+	}
+	if config.EULA != "" && !answers.AcceptEULA {
+		displayFailure(failure.Config, "В файле ответов не принято лицензионное соглашение (accept_eula)")
+		return
+	}
+	if config.InstallPath == "" {
+		displayFailure(failure.Config, "В файле ответов не указан путь установки (install_path)")
+		return
+	}
+	for _, page := range config.Pages {
+		for _, field := range page.Fields {
+			if _, ok := pageValues[field.ID]; !ok {
+				pageValues[field.ID] = field.Default
+			}
+			if field.Required && strings.TrimSpace(pageValues[field.ID]) == "" {
+				displayFailure(failure.Config, fmt.Sprintf("В файле ответов нет значения поля «%s» (options.%s)", field.Label, field.ID))
+				return
+			}
+		}
+	}
+	if needsInternet() && network.Offline() {
+		waitForOnline()
+		return
+	}
+	// Код входа показывается на экране, подтвердить его можно с любого устройства
+	if !authorizeDownloads() {
+		displayFailure(failure.Download, "Вход не выполнен, файлы игры не могут быть загружены")
+		return
+	}
+	log.Printf("Автоматическая установка в %s", config.InstallPath)
+	if !confirmMetered() {
+		return
+	}
+	startInstallation()
 }
 
 func main() {
-	if err := loadConfig("config.json"); err != nil {
-		log.Fatal(err)
+	report.Capture()
+	// Подготовка публикации не требует окна, версия берется из config.json, если он есть
+	if len(os.Args) > 1 && os.Args[1] == "--make-manifest" {
+		loadConfig("config.json")
+		os.Exit(makeManifest(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "--embed-uninstaller" {
+		loadConfig("config.json")
+		os.Exit(embedUninstaller(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "--verify-build" {
+		os.Exit(verifyBuild(os.Args[2:]))
 	}
+	if len(os.Args) > 1 && os.Args[1] == "--sign" {
+		os.Exit(signBuilds(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "--fill-shared-cache" {
+		os.Exit(fillSharedCache(os.Args[2:]))
+	}
+	// Открытие пакетов .gqi двойным щелчком в файловом менеджере
+	if len(os.Args) > 1 && (os.Args[1] == "--register-package-handler" || os.Args[1] == "--unregister-package-handler") {
+		var err error
+		if os.Args[1] == "--register-package-handler" {
+			var exe string
+			if exe, err = common.Executable(); err == nil {
+				err = bundle.RegisterHandler(exe)
+			}
+		} else {
+			err = bundle.UnregisterHandler()
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Готово: %s", os.Args[1])
+		return
+	}
+	launchDir, _ = os.Getwd()
+	if err := openPackage(); err != nil {
+		exitConfigError(err)
+	}
+	applyArchiveMetadata()
 
 	app := widgets.NewQApplication(len(os.Args), os.Args)
+	source.MediaPrompt = waitForMedia
+
+	// Общие с менеджером настройки: тема, язык игр, директория для игр и загрузки
+	userSettings = settings.Load()
+	settings.Apply(userSettings)
+	source.Hashes = config.AssetHashes
+	source.SSH = config.SSH
+	if config.SyncManifest != "" {
+		// Манифест последней версии меняется по тому же адресу
+		source.Fresh = map[string]bool{config.SyncManifest: true}
+	}
+	source.Stores = make(map[string]objstore.Store)
+	for name, c := range config.AssetStores {
+		store, err := objstore.Open(c)
+		if err != nil {
+			exitConfigError(fmt.Errorf("хранилище %s в конфигурации: %v", name, err))
+		}
+		source.Stores[name] = store
+	}
+
+	// Кэш загрузок общий для всех установщиков на этом компьютере
+	cacheLimit := int64(downloadcache.DefaultLimit)
+	if config.CacheLimitMB > 0 {
+		cacheLimit = config.CacheLimitMB << 20
+	}
+	source.DownloadCache = downloadcache.New(cacheLimit)
+
+	profiling = os.Getenv("GO_QT_INSTALLER_PROFILE") == "1"
+	for _, arg := range os.Args[1:] {
+		if arg == "--profile" {
+			profiling = true
+		}
+		if arg == "--verify" {
+			verifyFiles = true
+		}
+	}
+	// Скрытый режим сбоев для тестировщиков: --chaos или GO_QT_INSTALLER_CHAOS
+	chaos.Setup(os.Args[1:])
+
+	// Автоматическая установка по файлу ответов: --preseed <файл> или --preseed=<файл>
+	for i, arg := range os.Args[1:] {
+		preseedPath := ""
+		if arg == "--preseed" && i+2 < len(os.Args) {
+			preseedPath = os.Args[i+2]
+		} else if strings.HasPrefix(arg, "--preseed=") {
+			preseedPath = strings.TrimPrefix(arg, "--preseed=")
+		}
+		if preseedPath != "" && !filepath.IsAbs(preseedPath) {
+			preseedPath = filepath.Join(launchDir, preseedPath)
+		}
+		if preseedPath == "" {
+			continue
+		}
+		var err error
+		if answers, err = preseed.Load(preseedPath); err != nil {
+			exitConfigError(err)
+		}
+		if answers.SystemWide {
+			config.SystemWide = true
+		}
+		if answers.TempDir != "" {
+			config.TempDir = answers.TempDir
+		}
+	}
+
+	// Недокачанный установщик иначе падает на распаковке с непонятной ошибкой.
+	// При установке без присмотра окно с ошибкой некому закрыть, хватит кода выхода.
+	if err := source.VerifyPayload(); err != nil {
+		log.Printf("Проверка целостности установщика: %v", err)
+		if answers == nil {
+			widgets.QMessageBox_Critical(nil, "Установщик поврежден", err.Error(), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+		}
+		os.Exit(failure.Extraction.ExitCode())
+	}
 
-	// Создание темной палитры
-	darkPalette := gui.NewQPalette()
+	// Установка для всех пользователей компьютера: игра в /opt/games, записи в общем
+	// реестре, ярлыки на рабочие столы раздаются при входе пользователей
+	for _, arg := range os.Args[1:] {
+		if arg == "--system" {
+			config.SystemWide = true
+		}
+		// Режим киоска для демо-стендов: окно на весь экран, установка начинается сама
+		if arg == "--kiosk" {
+			config.Kiosk.Enabled = true
+		}
+	}
+	if config.SystemWide {
+		if !deploy.Available() {
+			displayFailure(failure.Permissions, "Установка для всех пользователей требует прав администратора. Запустите установщик через sudo или pkexec.")
+			os.Exit(failure.Permissions.ExitCode())
+		}
+		common.SystemWide = true
+	}
 
-	// Создаем цвета
-	darkColor := gui.NewQColor3(53, 53, 53, 255)
-	whiteColor := gui.NewQColor3(255, 255, 255, 255)
-	darkGreyColor := gui.NewQColor3(25, 25, 25, 255)
+	// На машинах с малым объемом памяти буферы распаковки и загрузки ждут друг друга
+	if config.MemoryLimitMB > 0 {
+		membudget.SetCeiling(config.MemoryLimitMB << 20)
+	}
 
-	// Устанавливаем цвета в палитру
-	darkPalette.SetColor2(gui.QPalette__Window, darkColor)
-	darkPalette.SetColor2(gui.QPalette__WindowText, whiteColor)
-	darkPalette.SetColor2(gui.QPalette__Base, darkGreyColor)
-	darkPalette.SetColor2(gui.QPalette__AlternateBase, darkGreyColor)
-	darkPalette.SetColor2(gui.QPalette__ToolTipBase, darkColor)
-	darkPalette.SetColor2(gui.QPalette__ToolTipText, whiteColor)
-	darkPalette.SetColor2(gui.QPalette__Text, whiteColor)
-	darkPalette.SetColor2(gui.QPalette__Button, darkColor)
-	darkPalette.SetColor2(gui.QPalette__ButtonText, whiteColor)
-	darkPalette.SetColor2(gui.QPalette__BrightText, whiteColor)
+	// Временные файлы по умолчанию пишутся в кэш пользователя, а не в /tmp
+	tempDir := defaultTempDir()
+	if config.TempDir != "" {
+		tempDir = os.ExpandEnv(config.TempDir)
+	}
+	if err := setTempDir(tempDir); err != nil {
+		log.Printf("%v, используется системная временная директория", err)
+	}
 
-	app.SetPalette(darkPalette, "")
+	settings.ApplyTheme(app, userSettings.Theme)
+	// Шрифты игры назначаются до создания окна, чтобы их получили все виджеты
+	branding.Apply(app, config.Fonts)
 
 	window := widgets.NewQMainWindow(nil, 0)
 
-	// Добавление баннера из конфигурации
-	bannerLabel := widgets.NewQLabel(nil, 0)
-	bannerPixmap := gui.NewQPixmap3(config.BannerPath, "", 0)
-	bannerLabel.SetPixmap(bannerPixmap)
-	bannerLabel.SetScaledContents(true)
+	// На окно можно бросить другой пакет установщика или архив с файлами игры
+	window.SetAcceptDrops(answers == nil)
+	window.ConnectDragEnterEvent(func(event *gui.QDragEnterEvent) {
+		if event.MimeData().HasUrls() {
+			event.AcceptProposedAction()
+		}
+	})
+	window.ConnectDropEvent(func(event *gui.QDropEvent) {
+		var paths []string
+		for _, url := range event.MimeData().Urls() {
+			if path := url.ToLocalFile(); path != "" {
+				paths = append(paths, path)
+			}
+		}
+		event.AcceptProposedAction()
+		dropFiles(paths)
+	})
+
+	// Добавление баннера из конфигурации. Баннер из файла ответов заменяет и анимацию.
+	bannerPath, bannerAnimation := config.BannerPath, config.BannerAnimation
+	if answers != nil && answers.Banner != "" {
+		bannerPath, bannerAnimation = answers.Banner, ""
+	}
+	bannerWidget := banner.New(bannerPath, bannerAnimation, config.BannerSound)
 
 	choosePathButton := widgets.NewQPushButton2("Выбрать путь", nil)
 	choosePathButton.ConnectClicked(func(bool) {
 		chooseInstallPath()
 	})
 
-	pathLabel = widgets.NewQLabel2("Путь установки: не выбран", nil, 0)
+	pathLabel = pathlabel.New("Путь установки: ")
+	pathLabel.SetPath("", "не выбран")
 
 	// Добавляем информацию о требуемом месте
-	spaceInfoLabel := widgets.NewQLabel2(fmt.Sprintf("Требуемое свободное место: %.2f ГБ", config.MinRequiredSpaceGB), nil, 0)
+	spaceInfoLabel = widgets.NewQLabel2("", nil, 0)
+	spaceInfoLabel.SetWordWrap(true)
+	showSpaceInfo(config.MinRequiredSpaceGB, config.TempSpaceGB)
+
+	// Временные файлы можно вынести на другой диск, если системный мал
+	tempDirButton = widgets.NewQPushButton2("Временные файлы: "+archive.StagingDir(), nil)
+	tempDirButton.ConnectClicked(func(bool) {
+		chooseTempDir()
+	})
+
+	settingsButton := widgets.NewQPushButton2("Настройки…", nil)
+	settingsButton.ConnectClicked(func(bool) {
+		if updated, ok := settings.ShowDialog(app); ok {
+			userSettings = updated
+			settings.Apply(updated)
+			source.DownloadCache = downloadcache.New(cacheLimit)
+		}
+	})
+
+	// Уже установленные игры: видно, что есть, и что ждет обновления или исправления
+	recent := recentInstalls()
+	attention := 0
+	for _, info := range recent {
+		if _, ok := installStatus(info); ok {
+			attention++
+		}
+	}
+	recentButton := widgets.NewQPushButton2(fmt.Sprintf("Уже установлено игр: %d…", len(recent)), nil)
+	if attention > 0 {
+		recentButton.SetText(fmt.Sprintf("Уже установлено игр: %d, требуют внимания: %d…", len(recent), attention))
+		recentButton.SetIcon(recentButton.Style().StandardIcon(widgets.QStyle__SP_MessageBoxWarning, nil, nil))
+	}
+	recentButton.SetFlat(true)
+	recentButton.SetVisible(len(recent) > 0)
+	recentButton.ConnectClicked(func(bool) {
+		showRecentInstalls(recent)
+	})
 
 	// Создаем чекбокс для создания ярлыка
 	createShortcutCheckBox = widgets.NewQCheckBox2("Создать ярлык запуска в меню приложений", nil)
 	createShortcutCheckBox.SetChecked(true)
 
+	// Избранное и папки приложений свои у каждого пользователя
+	pinCheckBox = widgets.NewQCheckBox2("", nil)
+	switch pin.Desktop() {
+	case pin.KDE:
+		pinCheckBox.SetText("Закрепить в избранном меню запуска")
+	case pin.GNOME:
+		folder := config.AppFolder
+		if folder == "" {
+			folder = pin.DefaultFolder
+		}
+		pinCheckBox.SetText("Добавить в папку приложений «" + folder + "»")
+	}
+	pinCheckBox.SetChecked(config.PinToMenu)
+	pinCheckBox.SetVisible(pin.Desktop() != "" && !common.SystemWide)
+	// Пункт удаления отмечен, если издатель выбрал его вид в конфигурации
+	uninstallEntryCheckBox = widgets.NewQCheckBox2("Добавить в меню пункт удаления игры", nil)
+	uninstallEntryCheckBox.SetChecked(config.UninstallEntry != "")
+	uninstallEntryCheckBox.SetVisible(!common.SystemWide)
+	if uninstallEntryStyle() == common.UninstallMenu {
+		uninstallEntryCheckBox.SetToolTip("Ярлык «Удалить " + config.DesktopEntry.Name + "» в разделе «Настройки» откроет менеджер игр на этой игре")
+	} else {
+		uninstallEntryCheckBox.SetToolTip("Пункт «Удалить игру» в контекстном меню ярлыка откроет менеджер игр на этой игре")
+	}
+	createShortcutCheckBox.ConnectToggled(func(checked bool) {
+		pinCheckBox.SetEnabled(checked)
+		uninstallEntryCheckBox.SetEnabled(checked)
+	})
+
 	// Создаем прогрессбар
 	progressBar = widgets.NewQProgressBar(nil)
 	progressBar.SetTextVisible(true)
 	progressBar.SetAlignment(core.Qt__AlignCenter)
 	progressBar.Hide() // Скрываем до начала установки
+	progressBar.SetAccessibleName("Ход установки")
+	progressVoice = accessibility.Track(progressBar)
+	verifyView = verifyview.New(progressBar)
+	verifyView.HideOnFinish = true
+	warningsPanel = warnings.New()
 
 	installButton = widgets.NewQPushButton2("Начать установку", nil)
+	branding.Heading(installButton)
 	installButton.SetEnabled(false)
 	installButton.ConnectClicked(func(bool) {
+		// Все вопросы уже заданы, когда установку откладывали
+		if waitingOnline {
+			startAfterWaiting()
+			return
+		}
+		if !scheduledAt.IsZero() || waitingUnmetered {
+			cancelSchedule()
+			startInstallation()
+			return
+		}
+		if !passGate() || !acceptEULA() || !runWizardPages() {
+			return
+		}
+		if needsInternet() && network.Offline() {
+			waitForOnline()
+			return
+		}
+		if !authorizeDownloads() {
+			return
+		}
+		if lanCheckBox.IsChecked() {
+			choosePeer()
+		}
+		if !confirmMetered() {
+			return
+		}
 		startInstallation()
 	})
 
+	// Установка в выбранное время: вопросы задаются сейчас, а скачивание начнется,
+	// например, ночью
+	scheduleButton = widgets.NewQPushButton2("Установить позже…", nil)
+	scheduleButton.SetEnabled(false)
+	scheduleButton.ConnectClicked(func(bool) {
+		if !scheduledAt.IsZero() || waitingUnmetered || waitingOnline {
+			cancelSchedule()
+			showConnectivity()
+			return
+		}
+		// Пока идет установка, кнопка установки выключена
+		if !installButton.IsEnabled() || !passGate() || !acceptEULA() || !runWizardPages() {
+			return
+		}
+		// Без интернета войти нельзя, вход будет перед началом установки
+		if !network.Offline() && !authorizeDownloads() {
+			return
+		}
+		at, ok := chooseStartTime()
+		if !ok {
+			return
+		}
+		if lanCheckBox.IsChecked() {
+			choosePeer()
+		}
+		scheduleInstallation(at)
+	})
+	scheduleTimer := core.NewQTimer(window)
+	scheduleTimer.ConnectTimeout(checkSchedule)
+	scheduleTimer.Start(30 * 1000)
+
+	// Директория для игр из настроек подставляется сразу, выбрать другую можно кнопкой
+	if root := installRoot(); root != "" {
+		config.InstallPath = filepath.Join(root, config.DesktopEntry.Name)
+		updateInstallPathDisplay()
+		checkInstallButtonState()
+	}
+	if answers != nil && answers.InstallPath != "" {
+		config.InstallPath = answers.InstallPath
+		updateInstallPathDisplay()
+	}
+
+	// Выбор языка озвучки и текстов, если в конфигурации есть языковые пакеты
+	languageLayout := widgets.NewQHBoxLayout()
+	if len(config.Languages) > 0 {
+		languageCombo = widgets.NewQComboBox(nil)
+		selectedLanguage = defaultLanguage()
+		for i, pack := range config.Languages {
+			languageCombo.AddItem(pack.Name, core.NewQVariant1(pack.Code))
+			if pack.Code == selectedLanguage {
+				languageCombo.SetCurrentIndex(i)
+			}
+		}
+		pageValues["language"] = selectedLanguage
+		languageCombo.ConnectCurrentIndexChanged(func(index int) {
+			selectedLanguage = config.Languages[index].Code
+			pageValues["language"] = selectedLanguage
+			showConnectivity()
+		})
+		languageLayout.AddWidget(widgets.NewQLabel2("Язык игры:", nil, 0), 0, 0)
+		languageCombo.SetEnabled(answers == nil)
+		languageLayout.AddWidget(languageCombo, 1, 0)
+	}
+
+	// Раздача файлов другим установщикам в локальной сети
+	lanCheckBox = widgets.NewQCheckBox2("Искать файлы игры в локальной сети", nil)
+	shareButton := widgets.NewQPushButton2("Раздать по сети", nil)
+	shareButton.ConnectClicked(func(bool) {
+		shareAssets()
+	})
+	reportButton := widgets.NewQPushButton2("Отчет для поддержки…", nil)
+	reportButton.ConnectClicked(func(bool) {
+		sendSupportReport()
+	})
+	lanCheckBox.ConnectToggled(func(bool) {
+		showConnectivity()
+	})
+
+	// Без интернета объясняем, что будет с установкой, и ждем подключения
+	offlineLabel = widgets.NewQLabel2("", nil, 0)
+	offlineLabel.SetWordWrap(true)
+	offlineLabel.SetStyleSheet("color: #e0a030;")
+	offlineLabel.Hide()
+	checkConnectivity()
+	connectivityTimer := core.NewQTimer(window)
+	connectivityTimer.ConnectTimeout(checkConnectivity)
+	connectivityTimer.Start(10 * 1000)
+
 	// Создание вертикального layout
 	layout := widgets.NewQVBoxLayout()
-	layout.AddWidget(bannerLabel, 0, 0)
+	layout.AddWidget(bannerWidget, 0, 0)
+	layout.AddWidget(recentButton, 0, 0)
 	layout.AddWidget(pathLabel, 0, 0)
 	layout.AddWidget(spaceInfoLabel, 0, 0) // Добавляем информацию о требуемом месте
 	layout.AddWidget(choosePathButton, 0, 0)
+	layout.AddWidget(tempDirButton, 0, 0)
+	layout.AddWidget(settingsButton, 0, 0)
 	layout.AddWidget(createShortcutCheckBox, 0, 0)
+	layout.AddWidget(pinCheckBox, 0, 0)
+	layout.AddWidget(uninstallEntryCheckBox, 0, 0)
+	layout.AddLayout(languageLayout, 0)
+	layout.AddWidget(lanCheckBox, 0, 0)
+	layout.AddWidget(offlineLabel, 0, 0)
 	layout.AddWidget(progressBar, 0, 0)
+	layout.AddWidget(verifyView.Widget, 0, 0)
+	layout.AddWidget(warningsPanel.Widget, 0, 0)
 	layout.AddWidget(installButton, 0, 0)
+	layout.AddWidget(scheduleButton, 0, 0)
+	layout.AddWidget(shareButton, 0, 0)
+	layout.AddWidget(reportButton, 0, 0)
 
 	centralWidget := widgets.NewQWidget(nil, 0)
 	centralWidget.SetLayout(layout)
@@ -632,10 +5127,92 @@ func main() {
 
 	// Устанавливаем заголовок окна с названием игры из конфига
 	windowTitle := "Установщик " + config.DesktopEntry.Name
+	if answers != nil && answers.Title != "" {
+		windowTitle = answers.Title
+	}
 	window.SetWindowTitle(windowTitle)
+	pathlabel.Shortcuts(window, func() string { return config.InstallPath })
+
+	// При автоматической установке окно только показывает прогресс: выбирать нечего
+	if answers != nil {
+		for _, w := range []widgets.QWidget_ITF{recentButton, choosePathButton, tempDirButton, settingsButton,
+			createShortcutCheckBox, pinCheckBox, uninstallEntryCheckBox, lanCheckBox, installButton, scheduleButton, shareButton, reportButton} {
+			w.QWidget_PTR().Hide()
+		}
+		createShortcutCheckBox.SetChecked(answers.CreateShortcut())
+		for id, value := range answers.Options {
+			pageValues[id] = value
+		}
+		if answers.Message != "" {
+			messageLabel := widgets.NewQLabel2(answers.Message, nil, 0)
+			messageLabel.SetWordWrap(true)
+			messageLabel.SetAlignment(core.Qt__AlignCenter)
+			layout.InsertWidget(layout.IndexOf(progressBar), messageLabel, 0, 0)
+		}
+		// Установка начинается, когда окно уже показано
+		startTimer := core.NewQTimer(window)
+		startTimer.SetSingleShot(true)
+		startTimer.ConnectTimeout(startUnattended)
+		startTimer.Start(0)
+	}
+
+	// Уведомления о предупреждениях поверх окна; щелчок раскрывает панель
+	warningToast = toast.New(window)
+	warningToast.OnClick = warningsPanel.Expand
+
+	if config.Kiosk.Enabled {
+		// Окно на весь экран, установщик размером с обычное окно в середине
+		centralWidget.SetFixedSize(core.NewQSize2(500, 530))
+		kiosk.Show(window, config.Kiosk)
+		if answers == nil {
+			startKioskCountdown(window)
+		}
+		os.Exit(exitCode(app.Exec()))
+	}
 
-	window.SetFixedSize(core.NewQSize2(500, 400))
+	window.SetFixedSize(core.NewQSize2(500, 530))
 	window.SetWindowFlags(core.Qt__Window | core.Qt__WindowTitleHint | core.Qt__WindowCloseButtonHint)
 	window.Show()
-	app.Exec()
+	os.Exit(exitCode(app.Exec()))
+}
+
+// startKioskCountdown в режиме киоска сам нажимает «Начать установку», если за
+// config.Kiosk.StartDelay секунд этого никто не сделал. Отсчет виден на кнопке.
+func startKioskCountdown(window *widgets.QMainWindow) {
+	left := int(config.Kiosk.Delay() / time.Second)
+	if left <= 0 {
+		return
+	}
+	countdownText := func() string {
+		return fmt.Sprintf("Начать установку (%d)", left)
+	}
+	timer := core.NewQTimer(window)
+	timer.ConnectTimeout(func() {
+		// Кнопку уже нажали или установка отложена: отсчет больше не нужен
+		if text := installButton.Text(); text != "Начать установку" && text != countdownText() {
+			timer.Stop()
+			return
+		}
+		// Пока путь установки не выбран, начать нельзя, отсчет ждет
+		if !installButton.IsEnabled() {
+			return
+		}
+		left--
+		if left > 0 {
+			installButton.SetText(countdownText())
+			return
+		}
+		timer.Stop()
+		installButton.SetText("Начать установку")
+		log.Printf("Режим киоска: установка начата автоматически")
+		installButton.Click()
+	})
+	// Игрок нажал кнопку сам: отсчет не должен нажать ее еще раз поверх его окон
+	installButton.ConnectPressed(func() {
+		if timer.IsActive() {
+			timer.Stop()
+			installButton.SetText("Начать установку")
+		}
+	})
+	timer.Start(1000)
 }
@@ -1,29 +1,95 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/therecipe/qt/core"
 	"github.com/therecipe/qt/gui"
 	"github.com/therecipe/qt/widgets"
+
+	"golang-installer/internal/accessibility"
+	"golang-installer/internal/api"
+	"golang-installer/internal/backup"
+	"golang-installer/internal/cloudsave"
+	"golang-installer/internal/common"
+	"golang-installer/internal/deploy"
+	"golang-installer/internal/desktopfile"
+	"golang-installer/internal/diagnostics"
+	"golang-installer/internal/downloadcache"
+	"golang-installer/internal/failure"
+	"golang-installer/internal/flatpak"
+	"golang-installer/internal/imagecache"
+	"golang-installer/internal/integrity"
+	"golang-installer/internal/keyring"
+	"golang-installer/internal/knowngames"
+	"golang-installer/internal/launcher"
+	"golang-installer/internal/maintenance"
+	"golang-installer/internal/mounts"
+	"golang-installer/internal/network"
+	"golang-installer/internal/pathlabel"
+	"golang-installer/internal/pin"
+	"golang-installer/internal/receipt"
+	"golang-installer/internal/report"
+	"golang-installer/internal/runner"
+	"golang-installer/internal/settings"
+	"golang-installer/internal/signature"
+	"golang-installer/internal/slug"
+	"golang-installer/internal/source"
+	"golang-installer/internal/survey"
+	"golang-installer/internal/trash"
+	"golang-installer/internal/updates"
+	"golang-installer/internal/verifyview"
+	"golang-installer/internal/webhook"
+	"golang-installer/internal/wmclass"
+)
+
+// Запись об установке общая с установщиком
+type (
+	InstallInfo  = common.InstallInfo
+	VersionEntry = common.VersionEntry
+)
+
+// installProblem — неисправность установленной игры, найденная при обновлении списка
+type installProblem int
+
+const (
+	problemMissingDir installProblem = iota
+	problemUnmounted
+	problemMissingExec
+	problemMissingMenu
+	problemMissingDesktop
 )
 
-type InstallInfo struct {
-	GameName      string    `json:"game_name"`
-	InstallPath   string    `json:"install_path"`
-	InstallDate   time.Time `json:"install_date"`
-	DesktopFile   string    `json:"desktop_file"`
-	MenuFile      string    `json:"menu_file"`
-	InstallerPath string    `json:"installer_path"`
-	InstallerDir  string    `json:"installer_dir"`
+func (p installProblem) String() string {
+	switch p {
+	case problemMissingDir:
+		return "директория игры не найдена"
+	case problemUnmounted:
+		return "диск с игрой не подключен"
+	case problemMissingExec:
+		return "исполняемый файл не найден"
+	case problemMissingMenu:
+		return "ярлык в меню приложений удален"
+	case problemMissingDesktop:
+		return "ярлык на рабочем столе удален"
+	}
+	return "неизвестная проблема"
 }
 
 var (
@@ -32,161 +98,3462 @@ var (
 	uninstallButton *widgets.QPushButton
 	infoLabel       *widgets.QLabel
 	progressBar     *widgets.QProgressBar
+	// progressVoice озвучивает ход удаления и переноса для экранного диктора
+	progressVoice    *accessibility.Tracker
+	trashCheckBox    *widgets.QCheckBox
+	undoButton       *widgets.QPushButton
+	cancelButton     *widgets.QPushButton
+	currentPathLabel *widgets.QLabel
+
+	detailsPane         *widgets.QWidget
+	detailsBanner       *widgets.QLabel
+	detailsTitle        *widgets.QLabel
+	detailsVersion      *widgets.QLabel
+	detailsPath         *pathlabel.Label
+	detailsSize         *widgets.QLabel
+	detailsDate         *widgets.QLabel
+	detailsLastPlayed   *widgets.QLabel
+	detailsShortcuts    *widgets.QLabel
+	detailsComponents   *widgets.QLabel
+	detailsOpenButton   *widgets.QPushButton
+	detailsLaunchButton *widgets.QPushButton
+	detailsMoveButton   *widgets.QPushButton
+
+	detailsSupport      *widgets.QLabel
+	detailsProblems     *widgets.QLabel
+	detailsRepairButton *widgets.QPushButton
+	detailsMenuButton   *widgets.QPushButton
+	detailsUpdateButton *widgets.QPushButton
+	detailsReportButton *widgets.QPushButton
+
+	detailsDuplicates       *widgets.QLabel
+	detailsDuplicatesButton *widgets.QPushButton
+
+	detailsRollbackButton *widgets.QPushButton
+	detailsHistoryButton  *widgets.QPushButton
+	detailsVerifyButton   *widgets.QPushButton
+	detailsFilesButton    *widgets.QPushButton
+	detailsCleanupButton  *widgets.QPushButton
+
+	// duplicatesByFile — другие копии той же игры для каждой записи
+	duplicatesByFile = make(map[string][]string)
+	// problemsByFile — проблемы, найденные при последнем обновлении списка
+	problemsByFile = make(map[string][]installProblem)
+	// selectedFile — файл с информацией об игре, показанной в панели подробностей
+	selectedFile string
+	// selectedInfo — игра, показанная в панели подробностей
+	selectedInfo *InstallInfo
+	// images уменьшает иконки и баннеры в фоне; готовые результаты приходят в imageResults
+	// и применяются таймером в потоке интерфейса
+	images       = imagecache.New(imagecache.DefaultDir())
+	imageResults = make(chan imagecache.Result, 64)
+	// itemsByIcon — элементы списка, ожидающие иконку из кэша
+	itemsByIcon = make(map[string][]*widgets.QListWidgetItem)
+	// sizeCache хранит посчитанные размеры директорий, чтобы не обходить их повторно
+	sizeCache = make(map[string]int64)
+	// sizeResults — размеры директорий для панели подробностей, посчитанные в фоне;
+	// sizePending — директории, которые сейчас считаются
+	sizeResults = make(chan sizeResult, 16)
+	sizePending = make(map[string]bool)
+
+	// baseDir — директория деинсталлятора, определяется один раз при запуске,
+	// потому что после перемещения игры в корзину os.Executable указывает в корзину
+	baseDir string
+	// cancelRequested выставляется кнопкой отмены во время пофайлового удаления
+	cancelRequested bool
+	// undoStack — удаления, которые можно отменить до закрытия окна
+	undoStack []*undoEntry
+
+	// watcher следит за реестром и директориями игр; изменения собираются
+	// refreshTimer и обновляют список один раз
+	watcher      *core.QFileSystemWatcher
+	refreshTimer *core.QTimer
+	// knownMounts — точки монтирования при последней проверке
+	knownMounts string
+	// diskEvents получает события udisks2; nil, если udisksctl недоступен и точки
+	// монтирования проверяются по таймеру
+	diskEvents chan struct{}
+
+	// apiServer — REST API менеджера, если он запущен с ключом --api
+	apiServer *api.Server
+	// apiRequests — команды API; выполняются в основном потоке по таймеру, потому
+	// что трогают виджеты
+	apiRequests = make(chan func(), 16)
+	// apiAction и apiGame — операция API, ход которой сейчас публикуется
+	apiAction, apiGame string
 )
 
+// refreshDelay — сколько ждать после последнего изменения, прежде чем обновить список
+const refreshDelay = 500
+
+// listInfoFiles возвращает файлы с информацией об установке из директории
+func listInfoFiles(dir string) []string {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		log.Printf("Директория с информацией об установке не найдена: %s", dir)
+		return nil
+	}
+
+	var infoFiles []string
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		log.Printf("Ошибка при чтении директории %s: %v", dir, err)
+		return nil
+	}
+
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), "-install.json") {
+			infoFiles = append(infoFiles, filepath.Join(dir, file.Name()))
+		}
+	}
+	return infoFiles
+}
+
+// findInstallInfoFiles собирает игры из логов рядом с деинсталлятором и из общего реестра.
+// Одна и та же установка может быть записана в обоих местах, поэтому записи
+// объединяются по пути установки.
 func findInstallInfoFiles() []string {
-	uninstallerPath, err := os.Executable()
+	var candidates []string
+	if baseDir != "" {
+		candidates = append(candidates, listInfoFiles(filepath.Join(baseDir, "logs"))...)
+	}
+	candidates = append(candidates, listInfoFiles(common.RegistryDir())...)
+	// Игры, установленные администратором для всех пользователей
+	candidates = append(candidates, listInfoFiles(common.SystemRegistryDir)...)
+
+	var infoFiles []string
+	seen := make(map[string]bool)
+	for _, file := range candidates {
+		// Записи прежних версий установщика переписываются в текущем формате
+		if err := common.Upgrade(file); err != nil {
+			log.Printf("Не удалось обновить формат записи %s: %v", file, err)
+		}
+		info, err := common.Load(file)
+		if err != nil {
+			infoFiles = append(infoFiles, file)
+			continue
+		}
+		key := filepath.Clean(info.InstallPath)
+		if !seen[key] {
+			seen[key] = true
+			infoFiles = append(infoFiles, file)
+		}
+	}
+	return infoFiles
+}
+
+// registryExport — формат файла со списком установленных игр
+type registryExport struct {
+	ExportedAt time.Time         `json:"exported_at"`
+	Games      []json.RawMessage `json:"games"`
+}
+
+// exportRegistry сохраняет записи обо всех установленных играх в один файл
+func exportRegistry(filePath string) (int, error) {
+	export := registryExport{ExportedAt: time.Now()}
+	for _, file := range findInstallInfoFiles() {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			log.Printf("Ошибка при чтении %s: %v", file, err)
+			continue
+		}
+		export.Games = append(export.Games, json.RawMessage(data))
+	}
+
+	data, err := json.MarshalIndent(export, "", "  ")
 	if err != nil {
-		log.Printf("Ошибка при получении пути к деинсталлятору: %v", err)
+		return 0, fmt.Errorf("ошибка при сериализации списка игр: %v", err)
+	}
+	if err := ioutil.WriteFile(filePath, data, 0644); err != nil {
+		return 0, fmt.Errorf("ошибка при сохранении списка игр: %v", err)
+	}
+	return len(export.Games), nil
+}
+
+// importResult — итог импорта списка игр
+type importResult struct {
+	Restored  []string      // Записи вернулись в реестр
+	Unsigned  []string      // Записи вернулись без подписи: удаление игры потребует подтверждения
+	Reinstall []InstallInfo // Игры нет на диске, но есть ее установщик
+	Missing   []string      // Нет ни игры, ни установщика
+}
+
+// Report возвращает итог импорта для пользователя
+func (r importResult) Report() string {
+	report := fmt.Sprintf("Восстановлено записей: %d", len(r.Restored))
+	if len(r.Unsigned) > 0 {
+		report += "\nБез подписи, удаление потребует подтверждения: " + strings.Join(r.Unsigned, ", ")
+	}
+	if len(r.Missing) > 0 {
+		report += "\nНе найдены ни игра, ни установщик: " + strings.Join(r.Missing, ", ")
+	}
+	return report
+}
+
+// importRegistry восстанавливает записи из экспортированного списка. Игры, которые
+// есть на диске, снова попадают в реестр. Установщики отсутствующих игр только
+// находятся: запускать их или нет, решает пользователь.
+//
+// Файл списка мог прийти откуда угодно, поэтому подпись получают только записи,
+// которые подписаны ключом этого пользователя, или запись из логов самой игры,
+// если ее подпись верна. Остальные записи сохраняются без подписи: пути из них
+// не считаются проверенными, и перед удалением менеджер спросит подтверждение.
+func importRegistry(filePath string) (importResult, error) {
+	var result importResult
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return result, fmt.Errorf("ошибка при чтении файла %s: %v", filePath, err)
+	}
+	var export registryExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return result, fmt.Errorf("ошибка при разборе JSON: %v", err)
+	}
+
+	if err := os.MkdirAll(common.RegistryDir(), 0755); err != nil {
+		return result, fmt.Errorf("не удалось создать директорию реестра: %v", err)
+	}
+
+	for _, original := range export.Games {
+		// Список мог быть выгружен менеджером, который писал записи в старом формате
+		raw, _, err := common.Migrate(original)
+		if err != nil {
+			log.Printf("Пропускаем некорректную запись при импорте: %v", err)
+			continue
+		}
+		var info InstallInfo
+		if err := json.Unmarshal(raw, &info); err != nil || info.GameName == "" {
+			log.Printf("Пропускаем некорректную запись при импорте: %v", err)
+			continue
+		}
+
+		// Директория, созданная установщиком, уже на месте — достаточно вернуть запись
+		if _, err := os.Stat(filepath.Join(info.InstallPath, common.SentinelFileName)); err == nil {
+			record, signed, err := importedRecord(original, raw, &info)
+			if err != nil {
+				log.Printf("Ошибка при подготовке записи %s: %v", info.GameName, err)
+				continue
+			}
+			registryPath := common.RegistryPath(&info)
+			if err := ioutil.WriteFile(registryPath, record, 0644); err != nil {
+				log.Printf("Ошибка при сохранении записи %s: %v", registryPath, err)
+				continue
+			}
+			result.Restored = append(result.Restored, info.GameName)
+			if !signed {
+				result.Unsigned = append(result.Unsigned, info.GameName)
+			}
+			continue
+		}
+
+		if _, err := os.Stat(info.InstallerPath); err == nil {
+			result.Reinstall = append(result.Reinstall, info)
+			continue
+		}
+		result.Missing = append(result.Missing, info.GameName)
+	}
+	return result, nil
+}
+
+// importedRecord возвращает запись для реестра и сообщает, подписана ли она.
+// original — запись из файла списка, raw — она же в текущем формате.
+func importedRecord(original, raw []byte, info *InstallInfo) ([]byte, bool, error) {
+	if signature.Verify(original) == nil {
+		record, err := common.Resign(raw)
+		return record, err == nil, err
+	}
+	// Запись в логах игры проверяется на месте и важнее записи из списка
+	local := filepath.Join(info.InstallPath, "logs", common.RecordName(common.GameSlug(info)))
+	if data, err := ioutil.ReadFile(local); err == nil && signature.Verify(data) == nil {
+		if migrated, _, err := common.Migrate(data); err == nil {
+			var record InstallInfo
+			if json.Unmarshal(migrated, &record) == nil && filepath.Clean(record.InstallPath) == filepath.Clean(info.InstallPath) {
+				signed, err := common.Resign(migrated)
+				if err == nil {
+					*info = record
+				}
+				return signed, err == nil, err
+			}
+		}
+	}
+	log.Printf("Запись %s из списка не подписана этим пользователем, она импортируется без подписи", info.GameName)
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, false, err
+	}
+	delete(fields, signature.Field)
+	record, err := json.MarshalIndent(fields, "", "  ")
+	return record, false, err
+}
+
+// confirmReinstall показывает игры, которых нет на диске, и их установщики и
+// запускает установщики, только если пользователь согласен
+func confirmReinstall(games []InstallInfo) string {
+	var lines []string
+	for _, info := range games {
+		lines = append(lines, fmt.Sprintf("%s: %s", info.GameName, info.InstallerPath))
+	}
+	answer := widgets.QMessageBox_Question(nil, "Импорт",
+		fmt.Sprintf("Этих игр нет на диске, но найдены их установщики:\n- %s\n\nЗапустить установщики?", strings.Join(lines, "\n- ")),
+		widgets.QMessageBox__Yes|widgets.QMessageBox__No, widgets.QMessageBox__No)
+	if answer != widgets.QMessageBox__Yes {
+		return ""
+	}
+	var started, failed []string
+	for _, info := range games {
+		spec := launcher.Spec{Path: info.InstallerPath, Dir: info.InstallerDir}
+		if _, err := launcher.Start(spec); err != nil {
+			log.Printf("Не удалось запустить установщик %s: %v", info.InstallerPath, err)
+			failed = append(failed, info.GameName)
+			continue
+		}
+		started = append(started, info.GameName)
+	}
+	var report string
+	if len(started) > 0 {
+		report += "\nЗапущена переустановка: " + strings.Join(started, ", ")
+	}
+	if len(failed) > 0 {
+		report += "\nНе удалось запустить установщик: " + strings.Join(failed, ", ")
+	}
+	return report
+}
+
+// knownGamesFiles возвращает списки известных игр: рядом с менеджером и в данных пользователя
+func knownGamesFiles() []string {
+	files := []string{filepath.Join(filepath.Dir(common.RegistryDir()), knowngames.FileName)}
+	if baseDir != "" {
+		files = append([]string{filepath.Join(baseDir, knowngames.FileName)}, files...)
+	}
+	return files
+}
+
+// addExistingInstall ищет в выбранной директории игры, установленные без установщика,
+// и добавляет их в реестр с ярлыками. Возвращает отчет или пустую строку, если
+// пользователь передумал.
+func addExistingInstall() (string, error) {
+	files := knownGamesFiles()
+	games, err := knowngames.Load(files...)
+	if err != nil {
+		return "", err
+	}
+	if len(games) == 0 {
+		return "", fmt.Errorf("список известных игр не найден, ожидается один из файлов:\n%s", strings.Join(files, "\n"))
+	}
+
+	dir := widgets.QFileDialog_GetExistingDirectory(nil, "Выберите директорию с игрой", os.Getenv("HOME"), 0)
+	if dir == "" {
+		return "", nil
+	}
+	matches, err := knowngames.Find(games, dir)
+	if err != nil {
+		return "", fmt.Errorf("ошибка при поиске игр в %s: %v", dir, err)
+	}
+
+	registered := make(map[string]bool)
+	for _, file := range findInstallInfoFiles() {
+		if info, err := common.Load(file); err == nil {
+			registered[filepath.Clean(info.InstallPath)] = true
+		}
+	}
+	var found, already []string
+	var pending []knowngames.Match
+	for _, m := range matches {
+		if registered[m.Root] {
+			already = append(already, m.Game.Name)
+			continue
+		}
+		pending = append(pending, m)
+		found = append(found, m.Game.Name+" — "+m.Root)
+	}
+	if len(pending) == 0 {
+		if len(already) > 0 {
+			return "Эти игры уже есть в списке: " + strings.Join(already, ", "), nil
+		}
+		return fmt.Sprintf("В директории %s не найдено известных игр", dir), nil
+	}
+
+	reply := widgets.QMessageBox_Question(nil, "Добавление игр",
+		fmt.Sprintf("Найдены игры:\n- %s\n\nДобавить их в список? В директориях игр появится файл-метка установщика, будут созданы ярлыки.", strings.Join(found, "\n- ")),
+		widgets.QMessageBox__Yes|widgets.QMessageBox__No, widgets.QMessageBox__Yes)
+	if reply != widgets.QMessageBox__Yes {
+		return "", nil
+	}
+
+	if err := os.MkdirAll(common.RegistryDir(), 0755); err != nil {
+		return "", fmt.Errorf("не удалось создать директорию реестра: %v", err)
+	}
+	var added, failed []string
+	for _, m := range pending {
+		if err := registerExistingInstall(m); err != nil {
+			log.Printf("Ошибка при добавлении %s из %s: %v", m.Game.Name, m.Root, err)
+			failed = append(failed, m.Game.Name+": "+err.Error())
+			continue
+		}
+		added = append(added, m.Game.Name)
+	}
+
+	report := fmt.Sprintf("Добавлено игр: %d", len(added))
+	if len(already) > 0 {
+		report += "\nУже были в списке: " + strings.Join(already, ", ")
+	}
+	if len(failed) > 0 {
+		report += "\nНе удалось добавить:\n" + strings.Join(failed, "\n")
+	}
+	return report, nil
+}
+
+// registerExistingInstall создает запись в реестре и ярлыки для найденной игры.
+// Файл-метка не создается: директорию создал не установщик, и перед ее удалением
+// менеджер спросит подтверждение.
+func registerExistingInstall(m knowngames.Match) error {
+	base := m.Game.Slug
+	if base == "" {
+		base = slug.Make(m.Game.Name)
+	}
+	gameSlug := slug.Unique(base, func(candidate string) bool {
+		_, err := os.Stat(filepath.Join(common.RegistryDir(), common.RecordName(candidate)))
+		return err == nil
+	})
+	appID := common.DefaultAppID(gameSlug)
+
+	info := &InstallInfo{
+		GameName:     m.Game.Name,
+		InstallPath:  m.Root,
+		InstallDate:  time.Now(),
+		MenuFile:     filepath.Join(common.ApplicationsDir(), appID+".desktop"),
+		Version:      m.Game.Version,
+		ExecPath:     filepath.Join(m.Root, m.Game.Exec),
+		RegistryFile: filepath.Join(common.RegistryDir(), common.RecordName(gameSlug)),
+		Slug:         gameSlug,
+		AppID:        appID,
+		WorkDir:      filepath.Join(m.Root, m.Game.WorkDir),
+		LaunchArgs:   m.Game.Args,
+		Imported:     true,
+	}
+	if m.Game.Icon != "" {
+		info.IconPath = filepath.Join(m.Root, m.Game.Icon)
+	}
+	if desktopDir := common.DesktopDir(); desktopDir != "" {
+		info.DesktopFile = filepath.Join(desktopDir, appID+".desktop")
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	record, err := common.Resign(data)
+	if err != nil {
+		return fmt.Errorf("ошибка при подписи записи: %v", err)
+	}
+	if err := ioutil.WriteFile(info.RegistryFile, record, 0644); err != nil {
+		return fmt.Errorf("ошибка при сохранении записи: %v", err)
+	}
+	return recreateShortcuts(info)
+}
+
+// checkInstallPathSafety проверяет, можно ли удалять директорию игры.
+// Первый список содержит причины, запрещающие удаление, второй — подозрительные
+// признаки, при которых удаление требует дополнительного подтверждения.
+func checkInstallPathSafety(info *InstallInfo) (fatal []string, suspicious []string) {
+	installPath := filepath.Clean(info.InstallPath)
+	if info.InstallPath == "" {
+		return []string{"путь установки не указан"}, nil
+	}
+	if !filepath.IsAbs(installPath) {
+		fatal = append(fatal, "путь установки не является абсолютным")
+	}
+	if installPath == "/" {
+		fatal = append(fatal, "путь установки указывает на корень файловой системы")
+	}
+	if home := os.Getenv("HOME"); home != "" {
+		home = filepath.Clean(home)
+		if installPath == home || strings.HasPrefix(home, installPath+string(os.PathSeparator)) {
+			fatal = append(fatal, "путь установки совпадает с домашней директорией или содержит ее")
+		}
+	}
+	if len(fatal) > 0 {
+		return fatal, nil
+	}
+
+	registryPath := common.RegistryPath(info)
+	if record, err := common.Load(registryPath); err != nil {
+		suspicious = append(suspicious, "запись об игре не найдена в реестре")
+	} else if filepath.Clean(record.InstallPath) != installPath {
+		suspicious = append(suspicious, fmt.Sprintf("путь не совпадает с записью в реестре (%s)", record.InstallPath))
+	}
+
+	if _, err := os.Stat(filepath.Join(installPath, common.SentinelFileName)); err != nil {
+		suspicious = append(suspicious, "в директории нет файла-метки установщика")
+	}
+	if info.Imported {
+		suspicious = append(suspicious, "игра добавлена в менеджер, ее директорию создал не установщик")
+	}
+
+	return nil, suspicious
+}
+
+// confirmSuspiciousUninstall просит пользователя ввести название игры для подтверждения удаления
+func confirmSuspiciousUninstall(info *InstallInfo, reasons []string) bool {
+	message := fmt.Sprintf("Директория %s не прошла проверку:\n- %s\n\nЧтобы всё равно удалить ее, введите название игры (%s):",
+		info.InstallPath, strings.Join(reasons, "\n- "), info.GameName)
+
+	ok := false
+	typed := widgets.QInputDialog_GetText(nil, "Подтверждение удаления", message,
+		widgets.QLineEdit__Normal, "", &ok, 0, 0)
+
+	return ok && strings.TrimSpace(typed) == info.GameName
+}
+
+// verifyInstallInfo проверяет подпись файла с информацией об установке
+func verifyInstallInfo(filePath string) error {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("ошибка при чтении файла %s: %v", filePath, err)
+	}
+	return signature.Verify(data)
+}
+
+// undoEntry хранит все, что нужно для отмены удаления одной игры
+type undoEntry struct {
+	GameName     string
+	Items        []*trash.Item
+	RegistryPath string
+	RegistryData []byte
+}
+
+// removePath удаляет путь или перемещает его в корзину, запоминая его для отмены
+func removePath(path string, toTrash bool, entry *undoEntry) error {
+	if !toTrash {
+		return os.RemoveAll(path)
+	}
+	item, err := trash.Move(path)
+	if err != nil {
+		return err
+	}
+	entry.Items = append(entry.Items, item)
+	return nil
+}
+
+// waitProcessingEvents выполняет fn в отдельной горутине, не останавливая окно
+func waitProcessingEvents(fn func() error) error {
+	done := make(chan error, 1)
+	go func() { done <- fn() }()
+	for {
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(50 * time.Millisecond):
+			core.QCoreApplication_ProcessEvents(core.QEventLoop__AllEvents)
+		}
+	}
+}
+
+// pushSaves отправляет сохранения игры в облако перед удалением. При конфликте
+// ни одна версия не теряется: та, что не стала основной, остается в облаке копией.
+func pushSaves(info *InstallInfo) error {
+	gameSlug := common.GameSlug(info)
+	c := info.CloudSave.Config
+	if secret, err := keyring.Get(common.SecretKey(gameSlug, common.CloudSaveSecret)); err == nil {
+		c.Config = c.Config.WithSecret(secret)
+	}
+	session, err := cloudsave.New(c, gameSlug, info.InstallPath)
+	if err != nil {
+		return err
+	}
+
+	currentPathLabel.SetText("Отправка сохранений в облако...")
+	currentPathLabel.Show()
+	defer currentPathLabel.Hide()
+
+	ctx := context.Background()
+	var local string
+	var remote *cloudsave.Manifest
+	err = waitProcessingEvents(func() error {
+		var err error
+		if local, err = session.LocalHash(); err != nil {
+			return err
+		}
+		remote, err = session.Remote(ctx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	remoteHash := ""
+	if remote != nil {
+		remoteHash = remote.Hash
+	}
+
+	switch cloudsave.Compare(local, remoteHash, info.CloudSave.Hash) {
+	case cloudsave.InSync, cloudsave.RemoteNewer:
+		// В облаке уже эти сохранения или более новые с другого компьютера
+		return nil
+	case cloudsave.LocalNewer:
+		return waitProcessingEvents(func() error {
+			_, err := session.Push(ctx, "")
+			return err
+		})
+	}
+
+	if apiAction == "" && replaceCloudSaves(info, remote) {
+		return waitProcessingEvents(func() error {
+			if err := session.CopyRemote(ctx, cloudsave.ConflictName(remote.Host)); err != nil {
+				return err
+			}
+			_, err := session.Push(ctx, "")
+			return err
+		})
+	}
+	host, _ := os.Hostname()
+	log.Printf("Конфликт сохранений %s: сохранения с этого компьютера оставлены в облаке копией", info.GameName)
+	return waitProcessingEvents(func() error {
+		_, err := session.Push(ctx, cloudsave.ConflictName(host))
+		return err
+	})
+}
+
+// replaceCloudSaves спрашивает, заменить ли облачные сохранения сохранениями с этого компьютера
+func replaceCloudSaves(info *InstallInfo, remote *cloudsave.Manifest) bool {
+	msgBox := widgets.NewQMessageBox(nil)
+	msgBox.SetIcon(widgets.QMessageBox__Question)
+	msgBox.SetWindowTitle("Облачные сохранения")
+	msgBox.SetText(fmt.Sprintf("Сохранения %s изменились и на этом компьютере, и в облаке (компьютер %s, %s).",
+		info.GameName, remote.Host, remote.UpdatedAt.Local().Format("02.01.2006 15:04")))
+	msgBox.SetInformativeText("Вторая версия останется в облаке копией, ее можно будет восстановить вручную.")
+	replaceButton := msgBox.AddButton2("Отправить сохранения с этого компьютера", widgets.QMessageBox__AcceptRole)
+	msgBox.AddButton2("Оставить облачные", widgets.QMessageBox__RejectRole)
+	msgBox.SetDefaultButton(replaceButton)
+	msgBox.Exec()
+	return msgBox.ClickedButton().Pointer() == replaceButton.Pointer()
+}
+
+// removeTreeWithProgress удаляет директорию пофайлово, показывая прогресс и текущий путь.
+// Между файлами обрабатываются события Qt, чтобы кнопка отмены оставалась активной.
+func removeTreeWithProgress(root string) error {
+	currentPathLabel.SetText("Подсчет файлов...")
+	currentPathLabel.Show()
+	core.QCoreApplication_ProcessEvents(core.QEventLoop__AllEvents)
+
+	var files, dirs []string
+	var totalBytes int64
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			dirs = append(dirs, path)
+		} else {
+			files = append(files, path)
+			totalBytes += info.Size()
+		}
 		return nil
+	})
+	if err != nil {
+		currentPathLabel.Hide()
+		return err
+	}
+
+	cancelRequested = false
+	cancelButton.SetEnabled(true)
+	cancelButton.Show()
+	defer func() {
+		cancelButton.Hide()
+		currentPathLabel.Hide()
+	}()
+
+	progressBar.SetRange(0, len(files))
+	progressBar.SetValue(0)
+
+	var removedBytes int64
+	for i, path := range files {
+		if cancelRequested {
+			return fmt.Errorf("удаление отменено пользователем, удалено файлов: %d из %d", i, len(files))
+		}
+
+		var size int64
+		if info, err := os.Lstat(path); err == nil {
+			size = info.Size()
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		removedBytes += size
+
+		progressBar.SetValue(i + 1)
+		progressBar.SetFormat(fmt.Sprintf("%d/%d файлов, %.1f/%.1f МБ", i+1, len(files),
+			float64(removedBytes)/(1024*1024), float64(totalBytes)/(1024*1024)))
+		currentPathLabel.SetText(path)
+		core.QCoreApplication_ProcessEvents(core.QEventLoop__AllEvents)
+	}
+
+	// Удаляем директории снизу вверх, когда в них не осталось файлов
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Remove(dirs[i]); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	progressBar.SetFormat("%p%")
+	return nil
+}
+
+// askUninstallSurvey показывает опрос издателя о причине удаления. Без согласия
+// на анонимную статистику в настройках опрос не показывается.
+func askUninstallSurvey(info *InstallInfo) {
+	if info.Survey == nil || !info.Survey.Enabled() || !settings.Load().Telemetry {
+		return
+	}
+	answer, ok := survey.Ask(*info.Survey, info.GameName, info.Version)
+	if !ok {
+		return
+	}
+	c := *info.Survey
+	go func() {
+		if err := survey.Submit(c, answer); err != nil {
+			log.Printf("Ответ на опрос об удалении %s не отправлен: %v", info.GameName, err)
+		}
+	}()
+}
+
+func uninstallGame(info *InstallInfo, toTrash bool) (err error) {
+	// Об удалении узнают адреса уведомлений, записанные установщиком
+	started := time.Now()
+	defer func() {
+		event := webhook.Event{
+			Action:    webhook.ActionUninstall,
+			Game:      info.GameName,
+			Slug:      common.GameSlug(info),
+			InstallID: info.InstallID,
+			Version:   info.Version,
+			Success:   err == nil,
+			Duration:  time.Since(started).Seconds(),
+		}
+		if err != nil {
+			event.Error = err.Error()
+			event.ErrorCode = string(failure.Classify(err, failure.Other))
+			progressVoice.Say("Удаление " + info.GameName + " не удалось")
+		} else {
+			progressVoice.Say("Игра " + info.GameName + " удалена")
+		}
+		go webhook.Send(info.Webhooks, event)
+	}()
+
+	registryPath := common.RegistryPath(info)
+	if filepath.Dir(registryPath) == common.SystemRegistryDir && !deploy.Available() {
+		return fmt.Errorf("игра %s установлена для всех пользователей, удалить ее может только администратор", info.GameName)
+	}
+
+	progressBar.SetRange(0, 4)
+	progressBar.SetValue(0)
+	progressBar.Show()
+	progressVoice.Phase("Удаление " + info.GameName)
+	// Видимый прогрессбар означает, что идет операция: список пока не обновляется
+	defer progressBar.Hide()
+
+	// Сохранения уходят в облако до удаления файлов игры
+	if info.CloudSave != nil {
+		if err := pushSaves(info); err != nil {
+			log.Printf("Ошибка отправки сохранений %s в облако: %v", info.GameName, err)
+			if apiAction != "" || widgets.QMessageBox_Question(nil, "Облачные сохранения",
+				fmt.Sprintf("Не удалось отправить сохранения в облако: %v\n\nУдалить игру вместе с сохранениями?", err),
+				widgets.QMessageBox__Yes|widgets.QMessageBox__No, widgets.QMessageBox__No) != widgets.QMessageBox__Yes {
+				return fmt.Errorf("сохранения не отправлены в облако: %v", err)
+			}
+		}
+	}
+
+	entry := &undoEntry{GameName: info.GameName}
+
+	if info.MenuFile != "" {
+		if _, err := os.Stat(info.MenuFile); err == nil {
+			if err := removePath(info.MenuFile, toTrash, entry); err != nil {
+				log.Printf("Ошибка при удалении ярлыка из меню: %v", err)
+			}
+		}
+	}
+	// Пункт удаления в меню больше не нужен
+	if info.UninstallMenuFile != "" {
+		if _, err := os.Stat(info.UninstallMenuFile); err == nil {
+			if err := removePath(info.UninstallMenuFile, toTrash, entry); err != nil {
+				log.Printf("Ошибка при удалении ярлыка удаления из меню: %v", err)
+			}
+		}
+	}
+	progressBar.SetValue(1)
+
+	if info.DesktopFile != "" {
+		if _, err := os.Stat(info.DesktopFile); err == nil {
+			if err := removePath(info.DesktopFile, toTrash, entry); err != nil {
+				log.Printf("Ошибка при удалении ярлыка с рабочего стола: %v", err)
+			}
+		}
+	}
+	// Ярлык перестает раздаваться и исчезнет с рабочих столов пользователей при входе
+	if info.Provisioning != "" {
+		if err := deploy.Unprovision(info.Provisioning); err != nil {
+			log.Printf("Ошибка при удалении ярлыка для пользователей: %v", err)
+		}
+	}
+	progressBar.SetValue(2)
+
+	if info.InstallPath != "" {
+		if _, err := os.Stat(info.InstallPath); err == nil {
+			var err error
+			if toTrash {
+				err = removePath(info.InstallPath, true, entry)
+			} else if info.Snapshot != nil && info.Snapshot.Destroy() == nil {
+				// Том удаляется вместе со снимками, файлы не нужно обходить
+				log.Printf("Том %s удален вместе со снимками", info.Snapshot.Path)
+			} else {
+				err = removeTreeWithProgress(info.InstallPath)
+				progressBar.SetRange(0, 4)
+			}
+			if err != nil {
+				if len(entry.Items) > 0 {
+					undoStack = append(undoStack, entry)
+					undoButton.SetEnabled(true)
+				}
+				return fmt.Errorf("ошибка при удалении директории с игрой: %v", err)
+			}
+		}
+	}
+	progressBar.SetValue(3)
+
+	// Игра, упакованная во Flatpak, удаляется средствами flatpak
+	if info.Flatpak != nil {
+		if err := flatpak.Remove(info.Flatpak); err != nil {
+			log.Printf("Ошибка при удалении приложения Flatpak %s: %v", info.Flatpak.AppID, err)
+		}
+	}
+
+	// Секреты игры больше никому не нужны
+	for _, key := range info.Secrets {
+		if err := keyring.Delete(key); err != nil {
+			log.Printf("Ошибка при удалении секрета %s из связки ключей: %v", key, err)
+		}
+	}
+
+	if info.Pin != nil {
+		if err := pin.Unpin(info.Pin); err != nil {
+			log.Printf("Ошибка при откреплении ярлыка %s: %v", info.Pin.DesktopID, err)
+		}
+	}
+
+	// Квитанция в пакетной базе больше не нужна. Ошибка не мешает удалению игры.
+	if info.Receipt != nil {
+		if err := receipt.Remove(info.Receipt); err != nil {
+			log.Printf("Ошибка при удалении пакета %s: %v", info.Receipt.Package, err)
+		}
+	}
+
+	runner.Run("gtk-update-icon-cache", "-f", "-t", filepath.Join(os.Getenv("HOME"), ".local", "share", "icons"))
+	common.UpdateDesktopDatabase()
+	if filepath.Dir(info.MenuFile) == deploy.ApplicationsDir {
+		runner.Run("update-desktop-database", deploy.ApplicationsDir)
+	}
+
+	progressBar.SetValue(4)
+
+	infoFilePath := filepath.Join(info.InstallPath, "logs", common.RecordName(common.GameSlug(info)))
+	if _, err := os.Stat(infoFilePath); err == nil {
+		if err := os.Remove(infoFilePath); err != nil {
+			log.Printf("Ошибка при удалении файла с информацией об установке: %v", err)
+		}
+	}
+
+	if data, err := ioutil.ReadFile(registryPath); err == nil {
+		if err := os.Remove(registryPath); err != nil {
+			log.Printf("Ошибка при удалении записи из реестра: %v", err)
+		} else if toTrash {
+			entry.RegistryPath = registryPath
+			entry.RegistryData = data
+		}
+	}
+
+	if toTrash {
+		undoStack = append(undoStack, entry)
+		undoButton.SetEnabled(true)
+	}
+
+	return nil
+}
+
+// undoLastUninstall возвращает из корзины последнюю удаленную игру
+func undoLastUninstall() error {
+	if len(undoStack) == 0 {
+		return nil
+	}
+	entry := undoStack[len(undoStack)-1]
+	undoStack = undoStack[:len(undoStack)-1]
+	undoButton.SetEnabled(len(undoStack) > 0)
+
+	var failed []string
+	for i := len(entry.Items) - 1; i >= 0; i-- {
+		if err := trash.Restore(entry.Items[i]); err != nil {
+			log.Printf("Ошибка при восстановлении: %v", err)
+			failed = append(failed, err.Error())
+		}
+	}
+
+	if entry.RegistryPath != "" {
+		if err := os.MkdirAll(filepath.Dir(entry.RegistryPath), 0755); err == nil {
+			if err := ioutil.WriteFile(entry.RegistryPath, entry.RegistryData, 0644); err != nil {
+				failed = append(failed, "не удалось восстановить запись в реестре: "+err.Error())
+			}
+		}
+	}
+
+	common.UpdateDesktopDatabase()
+
+	if len(failed) > 0 {
+		return fmt.Errorf("игра %s восстановлена не полностью:\n%s", entry.GameName, strings.Join(failed, "\n"))
+	}
+	return nil
+}
+
+// dirSize считает размер директории в байтах
+func dirSize(path string) int64 {
+	if size, ok := sizeCache[path]; ok {
+		return size
+	}
+	size := walkSize(path)
+	sizeCache[path] = size
+	return size
+}
+
+// walkSize обходит директорию и складывает размеры файлов
+func walkSize(path string) int64 {
+	var size int64
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// sizeResult — размер директории, посчитанный в фоне
+type sizeResult struct {
+	path string
+	size int64
+}
+
+// backgroundSize возвращает размер директории из кэша. Если его там нет, размер
+// считается в фоне, а результат придет в sizeResults: обход большой игры
+// надолго остановил бы окно.
+func backgroundSize(path string) (int64, bool) {
+	if size, ok := sizeCache[path]; ok {
+		return size, true
+	}
+	if !sizePending[path] {
+		sizePending[path] = true
+		go func() {
+			sizeResults <- sizeResult{path: path, size: walkSize(path)}
+		}()
+	}
+	return 0, false
+}
+
+// sizeText — размер директории для панели подробностей
+func sizeText(path string) string {
+	if size, ok := backgroundSize(path); ok {
+		return fmt.Sprintf("%.2f ГБ", float64(size)/(1024*1024*1024))
+	}
+	return "считается…"
+}
+
+// applySizeResults сохраняет размеры, посчитанные в фоне, и обновляет панель подробностей
+func applySizeResults() {
+	updated := false
+	for {
+		select {
+		case result := <-sizeResults:
+			sizeCache[result.path] = result.size
+			delete(sizePending, result.path)
+			updated = true
+		default:
+			if updated && selectedInfo != nil {
+				showDetailsSizes(selectedInfo, selectedFile)
+			}
+			return
+		}
+	}
+}
+
+// lastPlayed оценивает время последнего запуска по времени доступа к исполняемому файлу
+func lastPlayed(execPath string) (time.Time, bool) {
+	var stat syscall.Stat_t
+	if execPath == "" || syscall.Stat(execPath, &stat) != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(stat.Atim.Sec), int64(stat.Atim.Nsec)), true
+}
+
+// Размеры уменьшенных копий изображений в менеджере
+const (
+	bannerWidth  = 360
+	bannerHeight = 120
+	iconSize     = 32
+)
+
+// wmClassResult — класс окна, определенный после запуска игры
+type wmClassResult struct {
+	filePath string
+	class    string
+}
+
+var wmClassResults = make(chan wmClassResult, 4)
+
+// detectWMClass ждет окно запущенной игры и сообщает его класс через wmClassResults
+func detectWMClass(filePath string, pid int) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	class, err := wmclass.Detect(ctx, pid)
+	if err != nil {
+		log.Printf("Не удалось определить класс окна игры: %v", err)
+		return
+	}
+	wmClassResults <- wmClassResult{filePath: filePath, class: class}
+}
+
+// applyWMClassResults прописывает найденный класс окна в ярлыки и запись об установке
+func applyWMClassResults() {
+	for {
+		select {
+		case result := <-wmClassResults:
+			info, err := common.Load(result.filePath)
+			if err != nil {
+				log.Printf("Ошибка чтения записи %s: %v", result.filePath, err)
+				continue
+			}
+			for _, file := range []string{info.MenuFile, info.DesktopFile} {
+				if file == "" {
+					continue
+				}
+				if err := wmclass.FixDesktopFile(file, result.class); err != nil {
+					log.Printf("Ошибка обновления ярлыка %s: %v", file, err)
+				}
+			}
+			if err := updateRecord(result.filePath, map[string]interface{}{"wm_class": result.class}); err != nil {
+				log.Printf("Ошибка сохранения класса окна: %v", err)
+			}
+			if selectedInfo != nil && selectedFile == result.filePath {
+				selectedInfo.WMClass = result.class
+			}
+			log.Printf("Класс окна %s записан в ярлыки %s", result.class, info.GameName)
+		default:
+			return
+		}
+	}
+}
+
+// applyImageResults применяет изображения, подготовленные кэшем в фоне
+func applyImageResults() {
+	for {
+		select {
+		case result := <-imageResults:
+			if result.Err != nil {
+				log.Printf("Ошибка при загрузке изображения: %v", result.Err)
+				continue
+			}
+			if selectedInfo != nil && selectedInfo.BannerPath == result.Source {
+				detailsBanner.SetPixmap(gui.NewQPixmap3(result.Path, "", 0))
+			}
+			for _, item := range itemsByIcon[result.Source] {
+				item.SetIcon(gui.NewQIcon5(result.Path))
+			}
+			delete(itemsByIcon, result.Source)
+		default:
+			return
+		}
+	}
+}
+
+// newDetailsPane создает панель с подробной информацией о выбранной игре
+func newDetailsPane() *widgets.QWidget {
+	detailsBanner = widgets.NewQLabel(nil, 0)
+	detailsBanner.SetFixedHeight(120)
+	detailsBanner.SetAlignment(core.Qt__AlignCenter)
+
+	detailsTitle = widgets.NewQLabel2("", nil, 0)
+	detailsTitle.SetWordWrap(true)
+	detailsVersion = widgets.NewQLabel2("", nil, 0)
+	detailsPath = pathlabel.New("Путь: ")
+	detailsSize = widgets.NewQLabel2("", nil, 0)
+	detailsDate = widgets.NewQLabel2("", nil, 0)
+	detailsLastPlayed = widgets.NewQLabel2("", nil, 0)
+	detailsShortcuts = widgets.NewQLabel2("", nil, 0)
+	detailsShortcuts.SetWordWrap(true)
+	detailsComponents = widgets.NewQLabel2("", nil, 0)
+	detailsComponents.SetWordWrap(true)
+	// Код можно выделить и скопировать для письма в поддержку
+	detailsSupport = widgets.NewQLabel2("", nil, 0)
+	detailsSupport.SetTextInteractionFlags(core.Qt__TextSelectableByMouse)
+
+	detailsOpenButton = widgets.NewQPushButton2("Открыть папку", nil)
+	detailsOpenButton.ConnectClicked(func(bool) {
+		if selectedInfo != nil {
+			gui.QDesktopServices_OpenUrl(core.QUrl_FromLocalFile(selectedInfo.InstallPath))
+		}
+	})
+
+	detailsLaunchButton = widgets.NewQPushButton2("Запустить", nil)
+	detailsLaunchButton.ConnectClicked(func(bool) {
+		if selectedInfo == nil || selectedInfo.ExecPath == "" {
+			return
+		}
+		spec := launcher.Spec{
+			Path: selectedInfo.ExecPath,
+			Args: selectedInfo.LaunchArgs,
+			Dir:  selectedInfo.WorkDir,
+			Env:  selectedInfo.LaunchEnv,
+		}
+		if spec.Dir == "" {
+			spec.Dir = selectedInfo.InstallPath
+		}
+		process, err := launcher.Start(spec)
+		if err != nil {
+			widgets.QMessageBox_Critical(nil, "Ошибка", "Не удалось запустить игру: "+err.Error(), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+			return
+		}
+		if selectedInfo.WMClass == "" {
+			go detectWMClass(selectedFile, process.Pid)
+		}
+	})
+
+	detailsProblems = widgets.NewQLabel2("", nil, 0)
+	detailsProblems.SetWordWrap(true)
+	detailsProblems.SetStyleSheet("color: #e0a030;")
+
+	detailsMoveButton = widgets.NewQPushButton2("Переместить…", nil)
+	detailsMoveButton.ConnectClicked(func(bool) {
+		if selectedInfo == nil {
+			return
+		}
+		uninstallButton.SetEnabled(false)
+		err := moveInstall(selectedFile, selectedInfo)
+		uninstallButton.SetEnabled(true)
+		if err != nil {
+			widgets.QMessageBox_Critical(nil, "Ошибка", "Ошибка при перемещении игры: "+err.Error(), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+		}
+		updateGamesList()
+	})
+
+	detailsDuplicates = widgets.NewQLabel2("", nil, 0)
+	detailsDuplicates.SetWordWrap(true)
+
+	detailsDuplicatesButton = widgets.NewQPushButton2("Удалить одну из копий…", nil)
+	detailsDuplicatesButton.ConnectClicked(func(bool) {
+		if selectedInfo != nil {
+			resolveDuplicates(selectedFile, selectedInfo)
+		}
+	})
+
+	detailsRollbackButton = widgets.NewQPushButton2("Откатить обновление…", nil)
+	detailsRollbackButton.ConnectClicked(func(bool) {
+		if selectedInfo != nil {
+			rollbackUpdate(selectedFile, selectedInfo)
+		}
+	})
+
+	detailsHistoryButton = widgets.NewQPushButton2("История версий…", nil)
+	detailsHistoryButton.ConnectClicked(func(bool) {
+		if selectedInfo != nil {
+			showVersionHistory(selectedFile, selectedInfo)
+		}
+	})
+
+	detailsVerifyButton = widgets.NewQPushButton2("Сверить со снимком…", nil)
+	detailsVerifyButton.ConnectClicked(func(bool) {
+		if selectedInfo != nil {
+			verifySnapshot(selectedFile, selectedInfo)
+		}
+	})
+
+	detailsFilesButton = widgets.NewQPushButton2("Проверить файлы…", nil)
+	detailsFilesButton.SetToolTip("Сверить файлы игры по хэшам с манифестом и скачать заново поврежденные")
+	detailsFilesButton.ConnectClicked(func(bool) {
+		if selectedInfo != nil {
+			checkGameFiles(selectedInfo)
+		}
+	})
+
+	detailsCleanupButton = widgets.NewQPushButton2("Обслуживание…", nil)
+	detailsCleanupButton.SetToolTip("Очистить кэш шейдеров или сбросить настройки игры")
+	detailsCleanupButton.ConnectClicked(func(bool) {
+		if selectedInfo != nil {
+			showMaintenance(selectedInfo)
+		}
+	})
+
+	detailsRepairButton = widgets.NewQPushButton2("Исправить…", nil)
+	detailsRepairButton.ConnectClicked(func(bool) {
+		if selectedInfo != nil {
+			repairGame(selectedFile, selectedInfo)
+		}
+	})
+
+	detailsMenuButton = widgets.NewQPushButton2("Нет в меню?", nil)
+	detailsMenuButton.SetToolTip("Пересоздать ярлык, проверить его и иконку, обновить кэш меню и убедиться, что меню его покажет")
+	detailsMenuButton.ConnectClicked(func(bool) {
+		if selectedInfo != nil {
+			file, info := selectedFile, selectedInfo
+			diagnostics.ShowResults("Ярлык "+info.GameName, "Исправить снова", func() []diagnostics.Check {
+				return troubleshootShortcut(file, info)
+			})
+			updateGamesList()
+		}
+	})
+
+	detailsUpdateButton = widgets.NewQPushButton2("", nil)
+	detailsUpdateButton.ConnectClicked(func(bool) {
+		if selectedInfo != nil {
+			applyUpdate(selectedInfo)
+		}
+	})
+
+	detailsReportButton = widgets.NewQPushButton2("Отчет для поддержки…", nil)
+	detailsReportButton.ConnectClicked(func(bool) {
+		if selectedInfo != nil {
+			sendGameReport(selectedInfo)
+		}
+	})
+
+	versionsLayout := widgets.NewQHBoxLayout()
+	versionsLayout.AddWidget(detailsUpdateButton, 0, 0)
+	versionsLayout.AddWidget(detailsRollbackButton, 0, 0)
+	versionsLayout.AddWidget(detailsHistoryButton, 0, 0)
+	versionsLayout.AddWidget(detailsVerifyButton, 0, 0)
+	versionsLayout.AddWidget(detailsFilesButton, 0, 0)
+
+	buttonsLayout := widgets.NewQHBoxLayout()
+	buttonsLayout.AddWidget(detailsOpenButton, 0, 0)
+	buttonsLayout.AddWidget(detailsLaunchButton, 0, 0)
+	buttonsLayout.AddWidget(detailsMoveButton, 0, 0)
+	buttonsLayout.AddWidget(detailsRepairButton, 0, 0)
+	buttonsLayout.AddWidget(detailsCleanupButton, 0, 0)
+	buttonsLayout.AddWidget(detailsMenuButton, 0, 0)
+	buttonsLayout.AddWidget(detailsReportButton, 0, 0)
+
+	layout := widgets.NewQVBoxLayout()
+	layout.AddWidget(detailsBanner, 0, 0)
+	layout.AddWidget(detailsTitle, 0, 0)
+	layout.AddWidget(detailsVersion, 0, 0)
+	layout.AddWidget(detailsPath, 0, 0)
+	layout.AddWidget(detailsSize, 0, 0)
+	layout.AddWidget(detailsDate, 0, 0)
+	layout.AddWidget(detailsLastPlayed, 0, 0)
+	layout.AddWidget(detailsShortcuts, 0, 0)
+	layout.AddWidget(detailsComponents, 0, 0)
+	layout.AddWidget(detailsSupport, 0, 0)
+	layout.AddWidget(detailsProblems, 0, 0)
+	layout.AddWidget(detailsDuplicates, 0, 0)
+	layout.AddWidget(detailsDuplicatesButton, 0, 0)
+	layout.AddLayout(versionsLayout, 0)
+	layout.AddStretch(1)
+	layout.AddLayout(buttonsLayout, 0)
+
+	detailsPane = widgets.NewQWidget(nil, 0)
+	detailsPane.SetLayout(layout)
+	detailsPane.Hide()
+	return detailsPane
+}
+
+// showDetailsSizes показывает в панели подробностей размер игры и ее других копий.
+// Размеры, которых еще нет в кэше, считаются в фоне, и панель обновится сама.
+func showDetailsSizes(info *InstallInfo, filePath string) {
+	if isOffline(problemsByFile[filePath]) {
+		detailsSize.SetText("Размер: диск не подключен")
+	} else if _, err := os.Stat(info.InstallPath); err == nil {
+		detailsSize.SetText("Размер: " + sizeText(info.InstallPath))
+	} else {
+		detailsSize.SetText("Размер: директория не найдена")
+	}
+
+	duplicates := duplicatesByFile[filePath]
+	if len(duplicates) == 0 {
+		detailsDuplicates.Hide()
+		return
+	}
+	text := "Игра установлена несколько раз. Эта копия: " + sizeText(info.InstallPath)
+	for _, file := range duplicates {
+		if other, err := common.Load(file); err == nil {
+			text += fmt.Sprintf("\nДругая копия: %s (%s)", other.InstallPath, sizeText(other.InstallPath))
+		}
+	}
+	detailsDuplicates.SetText(text)
+	detailsDuplicates.Show()
+}
+
+// components перечисляет, из чего состоит установка игры
+func components(info *InstallInfo) []string {
+	list := []string{"файлы игры"}
+	if info.UninstallerPath != "" {
+		list = append(list, "менеджер игр")
+	}
+	if info.Flatpak != nil {
+		list = append(list, "приложение Flatpak "+info.Flatpak.AppID)
+	}
+	if info.Snapshot != nil {
+		list = append(list, fmt.Sprintf("том %s со снимками (%d)", info.Snapshot.Kind, len(info.Snapshot.Snapshots)))
+	}
+	if info.Receipt != nil {
+		list = append(list, fmt.Sprintf("пакет %s %s", info.Receipt.Kind, info.Receipt.Package))
+	}
+	if info.CloudSave != nil {
+		list = append(list, "облачные сохранения")
+	}
+	if len(info.Secrets) > 0 {
+		list = append(list, fmt.Sprintf("секреты в связке ключей (%d)", len(info.Secrets)))
+	}
+	if info.UninstallMenuFile != "" {
+		list = append(list, "ярлык удаления в меню")
+	}
+	if len(info.Options) > 0 {
+		var options []string
+		for key, value := range info.Options {
+			options = append(options, key+"="+value)
+		}
+		sort.Strings(options)
+		list = append(list, "параметры установки: "+strings.Join(options, "; "))
+	}
+	return list
+}
+
+// showGameDetails заполняет панель подробностей данными из InstallInfo
+func showGameDetails(info *InstallInfo, filePath string) {
+	selectedInfo = info
+	selectedFile = filePath
+	if info == nil {
+		detailsPane.Hide()
+		return
+	}
+
+	detailsBanner.Clear()
+	if info.BannerPath != "" {
+		images.Load(info.BannerPath, bannerWidth, bannerHeight, imageResults)
+		detailsBanner.Show()
+	} else {
+		detailsBanner.Hide()
+	}
+
+	detailsTitle.SetText("<b>" + html.EscapeString(info.GameName) + "</b>")
+	version := info.Version
+	if version == "" {
+		version = "не указана"
+	}
+	detailsVersion.SetText("Версия: " + version)
+	detailsPath.SetPath(info.InstallPath, "не указан")
+
+	problems := problemsByFile[filePath]
+	offline := isOffline(problems)
+	showDetailsSizes(info, filePath)
+
+	detailsDate.SetText("Установлена: " + info.InstallDate.Format("02.01.2006 15:04:05"))
+	if played, ok := lastPlayed(info.ExecPath); ok {
+		detailsLastPlayed.SetText("Последний запуск: " + played.Format("02.01.2006 15:04:05"))
+	} else {
+		detailsLastPlayed.SetText("Последний запуск: нет данных")
+	}
+
+	var shortcuts []string
+	if info.MenuFile != "" {
+		shortcuts = append(shortcuts, "меню приложений")
+	}
+	if info.DesktopFile != "" {
+		shortcuts = append(shortcuts, "рабочий стол")
+	}
+	if len(shortcuts) == 0 {
+		shortcuts = append(shortcuts, "не создавались")
+	}
+	detailsShortcuts.SetText("Ярлыки: " + strings.Join(shortcuts, ", "))
+	detailsComponents.SetText("Компоненты: " + strings.Join(components(info), ", "))
+
+	if code := common.SupportCode(info.InstallID); code != "" {
+		detailsSupport.SetText("Код для поддержки: " + code)
+		detailsSupport.SetToolTip("Идентификатор установки: " + info.InstallID)
+		detailsSupport.Show()
+	} else {
+		detailsSupport.Hide()
+	}
+
+	if offline {
+		detailsProblems.SetText("Не в сети: игра станет доступна, когда диск появится в " +
+			mounts.Expected(info.InstallPath, info.MountPoint))
+		detailsProblems.Show()
+	} else if len(problems) > 0 {
+		var descriptions []string
+		for _, p := range problems {
+			descriptions = append(descriptions, p.String())
+		}
+		detailsProblems.SetText("Проблемы: " + strings.Join(descriptions, ", "))
+		detailsProblems.Show()
+	} else {
+		detailsProblems.Hide()
+	}
+	detailsRepairButton.SetVisible(len(problems) > 0 && !offline)
+	// Ярлык игры во Flatpak создает сам flatpak
+	detailsMenuButton.SetVisible(info.ExecPath != "" && info.Flatpak == nil && !offline)
+
+	detailsDuplicatesButton.SetVisible(len(duplicatesByFile[filePath]) > 0)
+
+	if latest := backup.Latest(info.InstallPath); latest != nil {
+		detailsRollbackButton.SetText("Откатить обновление до " + versionLabel(latest.Version) + "…")
+		detailsRollbackButton.Show()
+		detailsHistoryButton.Show()
+	} else {
+		detailsRollbackButton.Hide()
+		detailsHistoryButton.SetVisible(len(info.History) > 0)
+	}
+	if pending := updates.Load(common.GameSlug(info)); pending != nil && pending.Ready && pending.Version != info.Version {
+		detailsUpdateButton.SetText("Обновить до версии " + pending.Version)
+		detailsUpdateButton.Show()
+	} else {
+		detailsUpdateButton.Hide()
+	}
+	_, hasSnapshot := info.Snapshot.Latest()
+	detailsVerifyButton.SetVisible(hasSnapshot)
+	detailsFilesButton.SetVisible(integrity.Available(info))
+
+	// Пока диск не подключен, с файлами игры ничего сделать нельзя
+	detailsLaunchButton.SetEnabled(info.ExecPath != "" && !offline)
+	detailsOpenButton.SetEnabled(!offline)
+	detailsMoveButton.SetEnabled(!offline)
+	detailsUpdateButton.SetEnabled(!offline)
+	detailsFilesButton.SetEnabled(!offline)
+	detailsCleanupButton.SetEnabled(!offline)
+	uninstallButton.SetEnabled(!offline)
+	detailsPane.Show()
+}
+
+// isOffline сообщает, что игра недоступна только потому, что ее диск не подключен
+func isOffline(problems []installProblem) bool {
+	return len(problems) == 1 && problems[0] == problemUnmounted
+}
+
+// checkInstallHealth ищет неисправности установленной игры
+func checkInstallHealth(info *InstallInfo) []installProblem {
+	if _, err := os.Stat(info.InstallPath); err != nil {
+		if mounts.Offline(info.InstallPath, info.MountPoint) {
+			return []installProblem{problemUnmounted}
+		}
+		return []installProblem{problemMissingDir}
+	}
+
+	var problems []installProblem
+	if info.ExecPath != "" {
+		if _, err := os.Stat(info.ExecPath); err != nil {
+			problems = append(problems, problemMissingExec)
+		}
+	}
+	if info.MenuFile != "" {
+		if _, err := os.Stat(info.MenuFile); err != nil {
+			problems = append(problems, problemMissingMenu)
+		}
+	}
+	if info.DesktopFile != "" {
+		if _, err := os.Stat(info.DesktopFile); err != nil {
+			problems = append(problems, problemMissingDesktop)
+		}
+	}
+	return problems
+}
+
+// updateRecord меняет поля записи об установке, сохраняет ее в реестр и в логи игры
+// и заново подписывает. Поле со значением nil удаляется. Запись с неверной
+// подписью не меняется, неподписанная остается неподписанной.
+func updateRecord(filePath string, changes map[string]interface{}) error {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("ошибка при чтении файла %s: %v", filePath, err)
+	}
+	// Изменение не должно узаконить запись, которую правили вручную: измененную
+	// запись менеджер не трогает, а неподписанная остается без подписи, и перед
+	// удалением игры по-прежнему нужно подтверждение
+	verifyErr := signature.Verify(data)
+	if verifyErr != nil && verifyErr != signature.ErrUnsigned {
+		log.Printf("Проверка подписи %s не пройдена: %v", filePath, verifyErr)
+		return fmt.Errorf("запись %s не изменена: %v", filePath, verifyErr)
+	}
+	if data, _, err = common.Migrate(data); err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return fmt.Errorf("ошибка при разборе JSON: %v", err)
+	}
+	for key, value := range changes {
+		if value == nil {
+			delete(fields, key)
+			continue
+		}
+		fields[key], _ = json.Marshal(value)
+	}
+	var record []byte
+	if verifyErr == signature.ErrUnsigned {
+		delete(fields, signature.Field)
+		if record, err = json.MarshalIndent(fields, "", "  "); err != nil {
+			return err
+		}
+	} else {
+		if data, err = json.Marshal(fields); err != nil {
+			return err
+		}
+		if record, err = common.Resign(data); err != nil {
+			return fmt.Errorf("ошибка при подписи записи: %v", err)
+		}
+	}
+
+	var info InstallInfo
+	json.Unmarshal(record, &info)
+	targets := []string{common.RegistryPath(&info)}
+	if logsDir := filepath.Join(info.InstallPath, "logs"); info.InstallPath != "" {
+		if _, err := os.Stat(logsDir); err == nil {
+			targets = append(targets, filepath.Join(logsDir, common.RecordName(common.GameSlug(&info))))
+		}
+	}
+	for _, target := range targets {
+		if err := ioutil.WriteFile(target, record, 0644); err != nil {
+			return fmt.Errorf("ошибка при сохранении %s: %v", target, err)
+		}
+	}
+	return nil
+}
+
+// recreateShortcuts заново создает удаленные ярлыки игры
+func recreateShortcuts(info *InstallInfo) error {
+	content := shortcutContent(info)
+	for _, file := range []string{info.MenuFile, info.DesktopFile} {
+		if file == "" {
+			continue
+		}
+		if _, err := os.Stat(file); err == nil {
+			continue
+		}
+		if err := common.WriteShortcut(file, content); err != nil {
+			return fmt.Errorf("не удалось создать ярлык %s: %v", file, err)
+		}
+	}
+
+	common.UpdateDesktopDatabase()
+	return nil
+}
+
+// shortcutContent строит ярлык игры по записи об установке
+func shortcutContent(info *InstallInfo) string {
+	content := "[Desktop Entry]\n"
+	content += "Type=Application\n"
+	content += "Name=" + info.GameName + "\n"
+	exec := common.DesktopQuote(info.ExecPath)
+	for _, arg := range info.LaunchArgs {
+		exec += " " + common.DesktopQuote(arg)
+	}
+	content += "Exec=" + exec + "\n"
+	if info.WorkDir != "" {
+		content += "Path=" + info.WorkDir + "\n"
+	}
+	if info.IconPath != "" {
+		content += "Icon=" + info.IconPath + "\n"
+	}
+	if info.WMClass != "" {
+		content += "StartupWMClass=" + info.WMClass + "\n"
+	}
+	content += "Terminal=false\n"
+	if info.Categories != "" {
+		content += "Categories=" + info.Categories + "\n"
+	} else {
+		content += "Categories=Game;\n"
+	}
+	if info.UninstallEntry == common.UninstallAction {
+		content += common.UninstallActionEntry(info)
+	}
+	return content
+}
+
+// troubleshootShortcut по шагам исправляет ярлык игры в меню: пересоздает его,
+// если файла нет или он испорчен, проверяет ярлык и иконку, обновляет кэши меню
+// и проверяет, что меню покажет ярлык. Каждый шаг возвращается как проверка.
+func troubleshootShortcut(filePath string, info *InstallInfo) []diagnostics.Check {
+	menuFile := info.MenuFile
+	if menuFile == "" {
+		appID := info.AppID
+		if appID == "" {
+			appID = common.DefaultAppID(common.GameSlug(info))
+		}
+		menuFile = filepath.Join(common.ApplicationsDir(), appID+".desktop")
+	}
+
+	var checks []diagnostics.Check
+	step := diagnostics.Check{Name: "Ярлык меню", Detail: menuFile + " на месте"}
+	reason := ""
+	if _, err := os.Stat(menuFile); err != nil {
+		reason = "файла не было"
+	} else if issues := desktopfile.Validate(menuFile); len(issues) > 0 {
+		reason = "в нем были ошибки: " + strings.Join(issues, "; ")
+	}
+	if reason != "" {
+		os.MkdirAll(filepath.Dir(menuFile), 0755)
+		if err := common.WriteShortcut(menuFile, shortcutContent(info)); err != nil {
+			step.Status = diagnostics.Fail
+			step.Detail = fmt.Sprintf("не удалось создать %s: %v", menuFile, err)
+			step.Hint = "проверьте права на директорию в окне «Настройки → Диагностика»"
+			return append(checks, step)
+		}
+		step.Detail = menuFile + " создан заново: " + reason
+		if info.MenuFile != menuFile {
+			if err := updateRecord(filePath, map[string]interface{}{"menu_file": menuFile}); err != nil {
+				log.Printf("Ошибка при обновлении записи: %v", err)
+			}
+			info.MenuFile = menuFile
+		}
+	}
+	checks = append(checks, step)
+
+	step = diagnostics.Check{Name: "Проверка ярлыка", Detail: "ошибок нет"}
+	if _, err := exec.LookPath("desktop-file-validate"); err != nil {
+		step.Detail += " (проверено без desktop-file-validate)"
+	}
+	if issues := desktopfile.Validate(menuFile); len(issues) > 0 {
+		step.Status = diagnostics.Fail
+		step.Detail = strings.Join(issues, "; ")
+		step.Hint = "если игру переместили или удалили ее файлы, укажите новое расположение кнопкой «Исправить…» или переустановите игру"
+	}
+	checks = append(checks, step)
+
+	step = diagnostics.Check{Name: "Иконка"}
+	if group, err := desktopfile.Parse(menuFile); err == nil {
+		if icon, err := desktopfile.ResolveIcon(group["Icon"]); err != nil {
+			step.Status = diagnostics.Warn
+			step.Detail = err.Error()
+			step.Hint = "меню покажет игру со стандартной иконкой"
+		} else {
+			step.Detail = icon
+		}
+	}
+	checks = append(checks, step)
+
+	// Кэши меню: KDE строит свой через kbuildsycoca, он есть только в Plasma
+	caches := [][]string{{"update-desktop-database", filepath.Dir(menuFile)}}
+	icons := filepath.Join(os.Getenv("HOME"), ".local", "share", "icons")
+	if _, err := os.Stat(icons); err == nil {
+		caches = append(caches, []string{"gtk-update-icon-cache", "-f", "-t", icons})
+	}
+	for _, name := range []string{"kbuildsycoca6", "kbuildsycoca5"} {
+		if _, err := exec.LookPath(name); err == nil {
+			caches = append(caches, []string{name})
+			break
+		}
+	}
+	for _, command := range caches {
+		step = diagnostics.Check{Name: "Обновление кэша: " + command[0], Detail: "выполнено"}
+		if err := runner.Run(command[0], command[1:]...); errors.Is(err, runner.ErrNotFound) {
+			step.Status = diagnostics.Warn
+			step.Detail = "программа не установлена"
+			step.Hint = "ярлык может появиться только после перезахода в систему"
+		} else if err != nil {
+			step.Status = diagnostics.Warn
+			step.Detail = err.Error()
+		}
+		checks = append(checks, step)
+	}
+
+	step = diagnostics.Check{Name: "Видимость в меню"}
+	if err := desktopfile.Visible(menuFile); err != nil {
+		step.Status = diagnostics.Fail
+		step.Detail = err.Error()
+		step.Hint = "устраните причину и нажмите «Исправить снова»"
+	} else {
+		id, _ := desktopfile.ID(menuFile)
+		step.Detail = "меню покажет ярлык " + id
+	}
+	checks = append(checks, step)
+
+	if info.DesktopFile == "" {
+		return checks
+	}
+	step = diagnostics.Check{Name: "Ярлык на рабочем столе", Detail: info.DesktopFile}
+	if _, err := os.Stat(info.DesktopFile); err != nil {
+		if err := common.WriteShortcut(info.DesktopFile, shortcutContent(info)); err != nil {
+			step.Status = diagnostics.Fail
+			step.Detail = fmt.Sprintf("не удалось создать %s: %v", info.DesktopFile, err)
+			return append(checks, step)
+		}
+		step.Detail += " создан заново"
+	}
+	if trusted, err := desktopfile.Trusted(info.DesktopFile); err == nil && !trusted {
+		runner.Run("gio", "set", info.DesktopFile, "metadata::trusted", "true")
+		if trusted, _ = desktopfile.Trusted(info.DesktopFile); !trusted {
+			step.Status = diagnostics.Warn
+			step.Detail += ": GNOME не разрешает его запуск"
+			step.Hint = "нажмите на ярлык правой кнопкой и выберите «Разрешить запуск»"
+		}
+	}
+	return append(checks, step)
+}
+
+// locateMovedInstall просит пользователя указать новое расположение игры
+func locateMovedInstall(filePath string, info *InstallInfo) error {
+	dir := widgets.QFileDialog_GetExistingDirectory(nil, "Укажите новое расположение "+info.GameName, "", 0)
+	if dir == "" {
+		return nil
+	}
+	if info.Imported {
+		// У добавленной игры нет файла-метки, ее узнаем по исполняемому файлу
+		if _, err := os.Stat(common.RebasePath(info.ExecPath, filepath.Clean(info.InstallPath), dir)); err != nil {
+			return fmt.Errorf("в директории %s нет исполняемого файла игры", dir)
+		}
+	} else if _, err := os.Stat(filepath.Join(dir, common.SentinelFileName)); err != nil {
+		return fmt.Errorf("в директории %s нет файла-метки установщика", dir)
+	}
+
+	return relocateRecord(filePath, info, dir)
+}
+
+// relocateRecord переписывает пути в записи об установке на новую директорию
+func relocateRecord(filePath string, info *InstallInfo, newRoot string) error {
+	oldRoot := filepath.Clean(info.InstallPath)
+	changes := map[string]interface{}{
+		"install_path":     newRoot,
+		"exec_path":        common.RebasePath(info.ExecPath, oldRoot, newRoot),
+		"banner_path":      common.RebasePath(info.BannerPath, oldRoot, newRoot),
+		"icon_path":        common.RebasePath(info.IconPath, oldRoot, newRoot),
+		"work_dir":         common.RebasePath(info.WorkDir, oldRoot, newRoot),
+		"uninstaller_path": filepath.Join(newRoot, "uninstaller"),
+		// Игра могла переехать на другой диск, отключенным считается уже он
+		"mount_point": mounts.Of(newRoot),
+	}
+	// Снимки остались на старом месте: откат и удаление тома по ним после
+	// переноса испортили бы старую директорию, поэтому запись о них убирается
+	if info.Snapshot != nil {
+		log.Printf("Снимки %s остаются в %s и больше не используются", info.GameName, info.Snapshot.Path)
+		changes["snapshot"] = nil
+	}
+	return updateRecord(filePath, changes)
+}
+
+// copyTreeWithProgress копирует директорию с сохранением прав и символических ссылок,
+// показывая прогресс и позволяя отменить копирование
+func copyTreeWithProgress(src, dst string) error {
+	progressVoice.Phase("Перенос файлов игры")
+	currentPathLabel.SetText("Подсчет файлов...")
+	currentPathLabel.Show()
+	core.QCoreApplication_ProcessEvents(core.QEventLoop__AllEvents)
+
+	var totalBytes int64
+	var paths []string
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		paths = append(paths, path)
+		if info.Mode().IsRegular() {
+			totalBytes += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		currentPathLabel.Hide()
+		return err
+	}
+
+	cancelRequested = false
+	cancelButton.SetEnabled(true)
+	cancelButton.Show()
+	defer func() {
+		cancelButton.Hide()
+		currentPathLabel.Hide()
+		progressBar.Hide()
+		progressBar.SetFormat("%p%")
+	}()
+
+	progressBar.SetRange(0, len(paths))
+	progressBar.SetValue(0)
+	progressBar.Show()
+
+	var copiedBytes int64
+	for i, path := range paths {
+		if cancelRequested {
+			return fmt.Errorf("перемещение отменено пользователем")
+		}
+
+		rel, _ := filepath.Rel(src, path)
+		target := filepath.Join(dst, rel)
+		info, err := os.Lstat(path)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case info.IsDir():
+			err = os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode()&os.ModeSymlink != 0:
+			var link string
+			if link, err = os.Readlink(path); err == nil {
+				err = os.Symlink(link, target)
+			}
+		default:
+			err = copyRegularFile(path, target, info.Mode().Perm())
+			copiedBytes += info.Size()
+		}
+		if err != nil {
+			return fmt.Errorf("ошибка при копировании %s: %v", path, err)
+		}
+
+		progressBar.SetValue(i + 1)
+		progressBar.SetFormat(fmt.Sprintf("%.1f/%.1f МБ", float64(copiedBytes)/(1024*1024), float64(totalBytes)/(1024*1024)))
+		currentPathLabel.SetText(path)
+		core.QCoreApplication_ProcessEvents(core.QEventLoop__AllEvents)
+	}
+	return nil
+}
+
+// copyRegularFile копирует один файл с заданными правами
+func copyRegularFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// freeSpace возвращает свободное место в байтах на диске, где находится путь
+func freeSpace(path string) (int64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), nil
+}
+
+// rewriteShortcuts заменяет старый путь установки в ярлыках игры на новый
+func rewriteShortcuts(info *InstallInfo, oldRoot, newRoot string) {
+	for _, file := range []string{info.MenuFile, info.DesktopFile, info.UninstallMenuFile} {
+		if file == "" {
+			continue
+		}
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		content := common.RebaseShortcut(string(data), oldRoot, newRoot)
+		if err := common.WriteShortcut(file, content); err != nil {
+			log.Printf("Ошибка при обновлении ярлыка %s: %v", file, err)
+		}
+	}
+	common.UpdateDesktopDatabase()
+}
+
+// moveInstall переносит игру в другую директорию, обновляя ярлыки и реестр
+func moveInstall(filePath string, info *InstallInfo) error {
+	// Подтом btrfs и набор данных ZFS нельзя перенести копированием вместе со снимками
+	if info.Snapshot != nil {
+		return fmt.Errorf("%s установлена в том со снимками (%s), его нельзя перенести. Удалите игру и установите ее заново в новое место", info.GameName, info.Snapshot.Kind)
+	}
+	dir := widgets.QFileDialog_GetExistingDirectory(nil, "Куда переместить "+info.GameName, "", 0)
+	if dir == "" {
+		return nil
+	}
+
+	oldRoot := filepath.Clean(info.InstallPath)
+	newRoot := filepath.Join(dir, filepath.Base(oldRoot))
+	if newRoot == oldRoot || strings.HasPrefix(newRoot, oldRoot+string(os.PathSeparator)) {
+		return fmt.Errorf("нельзя переместить игру внутрь ее собственной директории")
+	}
+	if _, err := os.Stat(newRoot); err == nil {
+		return fmt.Errorf("директория %s уже существует", newRoot)
+	}
+
+	// На том же диске достаточно переименования
+	if err := os.Rename(oldRoot, newRoot); err == nil {
+		// Файл с записью мог переехать вместе с игрой
+		if err := relocateRecord(common.RebasePath(filePath, oldRoot, newRoot), info, newRoot); err != nil {
+			return err
+		}
+		rewriteShortcuts(info, oldRoot, newRoot)
+		return nil
+	}
+
+	if free, err := freeSpace(dir); err == nil && free < dirSize(oldRoot) {
+		return fmt.Errorf("недостаточно места в %s: свободно %.2f ГБ, требуется %.2f ГБ", dir,
+			float64(free)/(1024*1024*1024), float64(dirSize(oldRoot))/(1024*1024*1024))
+	}
+
+	if err := copyTreeWithProgress(oldRoot, newRoot); err != nil {
+		os.RemoveAll(newRoot)
+		return err
+	}
+	if err := relocateRecord(filePath, info, newRoot); err != nil {
+		os.RemoveAll(newRoot)
+		return err
+	}
+	rewriteShortcuts(info, oldRoot, newRoot)
+
+	// Старую копию удаляем только после того, как новая записана в реестр
+	if err := removeTreeWithProgress(oldRoot); err != nil {
+		return fmt.Errorf("игра перемещена, но старую копию удалить не удалось: %v", err)
+	}
+	return nil
+}
+
+// removeStaleRecord удаляет запись об игре, которой больше нет на диске
+func removeStaleRecord(filePath string, info *InstallInfo) error {
+	registryPath := common.RegistryPath(info)
+	for _, file := range []string{filePath, registryPath} {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("не удалось удалить запись %s: %v", file, err)
+		}
+	}
+	return nil
+}
+
+// repairGame предлагает исправления для найденных проблем
+func repairGame(filePath string, info *InstallInfo) {
+	problems := problemsByFile[filePath]
+	if len(problems) == 0 {
+		return
+	}
+
+	var descriptions []string
+	missingDir, missingShortcut := false, false
+	for _, p := range problems {
+		descriptions = append(descriptions, p.String())
+		switch p {
+		case problemMissingDir, problemUnmounted:
+			missingDir = true
+		case problemMissingMenu, problemMissingDesktop:
+			missingShortcut = true
+		}
+	}
+
+	msgBox := widgets.NewQMessageBox(nil)
+	msgBox.SetWindowTitle("Исправление установки")
+	msgBox.SetIcon(widgets.QMessageBox__Warning)
+	msgBox.SetText(info.GameName + ":\n- " + strings.Join(descriptions, "\n- "))
+
+	var shortcutButton, locateButton, removeButton *widgets.QPushButton
+	if missingShortcut {
+		shortcutButton = msgBox.AddButton2("Восстановить ярлыки", widgets.QMessageBox__ActionRole)
+	}
+	if missingDir {
+		locateButton = msgBox.AddButton2("Указать новое расположение…", widgets.QMessageBox__ActionRole)
+		removeButton = msgBox.AddButton2("Удалить запись", widgets.QMessageBox__DestructiveRole)
+	}
+	msgBox.AddButton3(widgets.QMessageBox__Cancel)
+	msgBox.Exec()
+
+	var err error
+	clicked := msgBox.ClickedButton()
+	switch {
+	case shortcutButton != nil && clicked.Pointer() == shortcutButton.Pointer():
+		err = recreateShortcuts(info)
+	case locateButton != nil && clicked.Pointer() == locateButton.Pointer():
+		err = locateMovedInstall(filePath, info)
+	case removeButton != nil && clicked.Pointer() == removeButton.Pointer():
+		err = removeStaleRecord(filePath, info)
+	default:
+		return
+	}
+
+	if err != nil {
+		widgets.QMessageBox_Critical(nil, "Ошибка", err.Error(), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+	}
+	updateGamesList()
+}
+
+// applyUpdate запускает установщик игры, чтобы он поставил обновление, скачанное
+// службой. Установщик берет манифест по той же ссылке, а файлы из кэша загрузок.
+func applyUpdate(info *InstallInfo) {
+	if _, err := os.Stat(info.InstallerPath); err != nil {
+		widgets.QMessageBox_Warning(nil, "Обновление",
+			"Установщик игры не найден: "+info.InstallerPath+"\nЗапустите установщик новой версии вручную.",
+			widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+		return
+	}
+	spec := launcher.Spec{Path: info.InstallerPath, Dir: info.InstallerDir}
+	if _, err := launcher.Start(spec); err != nil {
+		widgets.QMessageBox_Critical(nil, "Ошибка", "Не удалось запустить установщик: "+err.Error(), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+	}
+}
+
+// sendGameReport отправляет в поддержку запись об игре (summary.json), журнал установки и
+// сведения о системе и показывает номер обращения
+func sendGameReport(info *InstallInfo) {
+	r := &report.Report{
+		Game:      info.GameName,
+		InstallID: info.InstallID,
+		Summary:   info,
+		Log:       report.SessionLog(),
+		Files:     map[string]string{"install.log": filepath.Join(info.InstallPath, "logs", "install.log")},
+		Dir:       info.InstallPath,
+	}
+	res, err := report.Send(context.Background(), info.SupportURL, r)
+	if err != nil {
+		widgets.QMessageBox_Critical(nil, "Ошибка", "Не удалось сохранить отчет: "+err.Error(), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+		return
+	}
+	msgBox := widgets.NewQMessageBox(nil)
+	msgBox.SetWindowTitle("Отчет для поддержки")
+	msgBox.SetIcon(widgets.QMessageBox__Information)
+	msgBox.SetText(res.Message())
+	msgBox.SetTextInteractionFlags(core.Qt__TextSelectableByMouse)
+	msgBox.Exec()
+}
+
+// daemonUnit — пользовательская служба systemd для фоновых обновлений
+const daemonUnit = `[Unit]
+Description=Фоновое обновление игр go-qt-installer
+After=network-online.target
+
+[Service]
+Type=simple
+ExecStart=%q --daemon
+Restart=on-failure
+RestartSec=5min
+Nice=10
+IOSchedulingClass=idle
+
+[Install]
+WantedBy=default.target
+`
+
+// daemonUnitName — имя службы в systemd
+const daemonUnitName = "go-qt-installer-updates.service"
+
+// installDaemon записывает службу с путем к этому менеджеру и включает ее
+func installDaemon() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(os.Getenv("HOME"), ".config")
+	}
+	unitPath := filepath.Join(configHome, "systemd", "user", daemonUnitName)
+	if err := os.MkdirAll(filepath.Dir(unitPath), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(unitPath, []byte(fmt.Sprintf(daemonUnit, exe)), 0644); err != nil {
+		return err
+	}
+	log.Printf("Служба записана в %s", unitPath)
+	if err := runner.Run("systemctl", "--user", "daemon-reload"); err != nil {
+		return err
+	}
+	return runner.Run("systemctl", "--user", "enable", "--now", daemonUnitName)
+}
+
+// trayAutostart — ярлык автозапуска значка в области уведомлений
+const trayAutostart = `[Desktop Entry]
+Type=Application
+Name=Обновления игр
+Exec=%s --tray
+Icon=applications-games
+NoDisplay=true
+X-GNOME-Autostart-enabled=true
+`
+
+// installTray добавляет значок обновлений в автозапуск текущего пользователя
+func installTray() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(os.Getenv("HOME"), ".config")
+	}
+	path := filepath.Join(configHome, "autostart", "go-qt-installer-tray.desktop")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, []byte(fmt.Sprintf(trayAutostart, common.DesktopQuote(exe))), 0644); err != nil {
+		return err
+	}
+	log.Printf("Значок обновлений добавлен в автозапуск: %s", path)
+	return nil
+}
+
+// readyUpdates возвращает записи игр, для которых служба скачала обновление
+func readyUpdates() ([]*InstallInfo, []*updates.Pending) {
+	var games []*InstallInfo
+	var pending []*updates.Pending
+	for _, file := range findInstallInfoFiles() {
+		info, err := common.Load(file)
+		if err != nil {
+			continue
+		}
+		if p := updates.Load(common.GameSlug(info)); p != nil && p.Ready && p.Version != info.Version {
+			games = append(games, info)
+			pending = append(pending, p)
+		}
+	}
+	return games, pending
+}
+
+// badgeIcon рисует на иконке красный кружок с числом
+func badgeIcon(base *gui.QIcon, count int) *gui.QIcon {
+	const size = 64
+	pixmap := base.Pixmap(core.NewQSize2(size, size), gui.QIcon__Normal, gui.QIcon__Off)
+	painter := gui.NewQPainter2(pixmap)
+	painter.SetRenderHint(gui.QPainter__Antialiasing, true)
+	painter.SetPen(gui.NewQPen3(gui.NewQColor3(255, 255, 255, 255)))
+	painter.SetBrush(gui.NewQBrush3(gui.NewQColor3(220, 40, 40, 255), core.Qt__SolidPattern))
+	painter.DrawEllipse3(size/2-2, 0, size/2, size/2)
+	font := gui.NewQFont2("", -1, int(gui.QFont__Bold), false)
+	font.SetPixelSize(size / 4)
+	painter.SetFont(font)
+	text := fmt.Sprint(count)
+	if count > 9 {
+		text = "9+"
+	}
+	painter.DrawText5(size/2-2, 0, size/2, size/2, int(core.Qt__AlignCenter), text, nil)
+	painter.End()
+	return gui.NewQIcon2(pixmap)
+}
+
+// runTray показывает значок в области уведомлений. Значок следит за реестром и
+// за обновлениями, которые скачала служба, показывает их число и открывает
+// менеджер по щелчку.
+func runTray(app *widgets.QApplication) {
+	if !widgets.QSystemTrayIcon_IsSystemTrayAvailable() {
+		log.Fatalf("Область уведомлений недоступна: в GNOME для нее нужно расширение AppIndicator")
+	}
+	gui.QGuiApplication_SetQuitOnLastWindowClosed(false)
+
+	base := gui.QIcon_FromTheme("applications-games")
+	if base.IsNull() {
+		base = app.Style().StandardIcon(widgets.QStyle__SP_ComputerIcon, nil, nil)
+	}
+	tray := widgets.NewQSystemTrayIcon2(base, nil)
+
+	var games []*InstallInfo
+	shown := make(map[string]bool)
+	openManager := func() {
+		exe, err := os.Executable()
+		if err != nil {
+			log.Printf("Ошибка при получении пути к менеджеру: %v", err)
+			return
+		}
+		spec := launcher.Spec{Path: exe}
+		if len(games) == 1 {
+			spec.Args = []string{"--select=" + games[0].InstallPath}
+		}
+		if _, err := launcher.Start(spec); err != nil {
+			log.Printf("Не удалось открыть менеджер игр: %v", err)
+		}
+	}
+	refresh := func() {
+		var pending []*updates.Pending
+		games, pending = readyUpdates()
+		if len(games) == 0 {
+			tray.SetIcon(base)
+			tray.SetToolTip("Менеджер игр: обновлений нет")
+			return
+		}
+		tray.SetIcon(badgeIcon(base, len(games)))
+		lines := []string{"Готовы обновления:"}
+		var fresh []string
+		for i, info := range games {
+			lines = append(lines, info.GameName+" "+pending[i].Version)
+			key := common.GameSlug(info) + " " + pending[i].Version
+			if !shown[key] {
+				shown[key] = true
+				fresh = append(fresh, info.GameName)
+			}
+		}
+		tray.SetToolTip(strings.Join(lines, "\n"))
+		if len(fresh) > 0 {
+			tray.ShowMessage("Готовы обновления", strings.Join(fresh, ", ")+"\nНажмите, чтобы установить.",
+				widgets.QSystemTrayIcon__Information, 10000)
+		}
+	}
+
+	// Каталоги меняются пачками файлов: обновляем значок, когда запись закончилась
+	refreshTimer := core.NewQTimer(nil)
+	refreshTimer.SetSingleShot(true)
+	refreshTimer.ConnectTimeout(refresh)
+	watcher := core.NewQFileSystemWatcher(nil)
+	for _, dir := range []string{common.RegistryDir(), updates.Dir()} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			log.Printf("Ошибка при создании %s: %v", dir, err)
+			continue
+		}
+		watcher.AddPath(dir)
+	}
+	watcher.ConnectDirectoryChanged(func(string) { refreshTimer.Start(1000) })
+	// Записи игр лежат и в их директориях, изменения там видны только при опросе
+	pollTimer := core.NewQTimer(nil)
+	pollTimer.ConnectTimeout(refresh)
+	pollTimer.Start(10 * 60 * 1000)
+
+	menu := widgets.NewQMenu(nil)
+	menu.AddAction("Открыть менеджер игр").ConnectTriggered(func(bool) { openManager() })
+	menu.AddSeparator()
+	menu.AddAction("Выход").ConnectTriggered(func(bool) { app.Quit() })
+	tray.SetContextMenu(menu)
+	tray.ConnectActivated(func(reason widgets.QSystemTrayIcon__ActivationReason) {
+		if reason == widgets.QSystemTrayIcon__Trigger {
+			openManager()
+		}
+	})
+	tray.ConnectMessageClicked(openManager)
+
+	refresh()
+	tray.Show()
+	app.Exec()
+}
+
+// paletteAction — команда палитры: действие над игрой или над всем менеджером
+type paletteAction struct {
+	label  string
+	file   string               // Запись об игре; пусто для общих команд
+	button *widgets.QPushButton // Кнопка, которую нажимает команда
+	run    func()               // Функция для команд без кнопки
+}
+
+// managerActions — общие команды палитры, их собирает main
+var managerActions []paletteAction
+
+// gameActions возвращает действия над играми: каждое нажимает кнопку панели
+// подробностей, поэтому палитра делает ровно то же, что и мышь
+func gameActions() []paletteAction {
+	buttons := []struct {
+		label  string
+		button *widgets.QPushButton
+	}{
+		{"Запустить", detailsLaunchButton},
+		{"Открыть папку", detailsOpenButton},
+		{"Обновить", detailsUpdateButton},
+		{"Исправить", detailsRepairButton},
+		{"Обслуживание", detailsCleanupButton},
+		{"Исправить ярлык в меню", detailsMenuButton},
+		{"Переместить", detailsMoveButton},
+		{"Откатить обновление", detailsRollbackButton},
+		{"История версий", detailsHistoryButton},
+		{"Сверить со снимком", detailsVerifyButton},
+		{"Проверить файлы", detailsFilesButton},
+		{"Отчет для поддержки", detailsReportButton},
+		{"Удалить", uninstallButton},
+	}
+	var actions []paletteAction
+	for row := 0; row < gamesList.Count(); row++ {
+		file := gamesList.Item(row).Data(int(core.Qt__UserRole)).ToString()
+		info, err := common.Load(file)
+		if err != nil {
+			continue
+		}
+		for _, b := range buttons {
+			actions = append(actions, paletteAction{label: info.GameName + " — " + b.label, file: file, button: b.button})
+		}
+	}
+	return actions
+}
+
+// showCommandPalette показывает палитру команд (Ctrl+K): все действия менеджера
+// с поиском по названию игры и действия
+func showCommandPalette() {
+	actions := append(append([]paletteAction(nil), managerActions...), gameActions()...)
+
+	dialog := widgets.NewQDialog(window, 0)
+	dialog.SetWindowTitle("Команды")
+	search := widgets.NewQLineEdit(nil)
+	search.SetPlaceholderText("Игра или действие, например «celeste удалить»")
+	list := widgets.NewQListWidget(nil)
+
+	var matched []paletteAction
+	filter := func(text string) {
+		words := strings.Fields(strings.ToLower(text))
+		matched = matched[:0]
+		list.Clear()
+		for _, a := range actions {
+			label := strings.ToLower(a.label)
+			ok := true
+			for _, word := range words {
+				if !strings.Contains(label, word) {
+					ok = false
+					break
+				}
+			}
+			if ok {
+				matched = append(matched, a)
+				list.AddItem(a.label)
+			}
+		}
+		list.SetCurrentRow(0)
+	}
+	var chosen *paletteAction
+	execute := func() {
+		if row := list.CurrentRow(); row >= 0 && row < len(matched) {
+			chosen = &matched[row]
+			dialog.Accept()
+		}
+	}
+	search.ConnectTextChanged(filter)
+	search.ConnectReturnPressed(execute)
+	list.ConnectItemActivated(func(*widgets.QListWidgetItem) { execute() })
+
+	// Стрелки двигают выбор, не уводя фокус из поля поиска
+	for key, step := range map[string]int{"Up": -1, "Down": 1} {
+		step := step
+		shortcut := widgets.NewQShortcut(dialog)
+		shortcut.SetKey(gui.NewQKeySequence2(key, gui.QKeySequence__PortableText))
+		shortcut.ConnectActivated(func() {
+			if row := list.CurrentRow() + step; row >= 0 && row < list.Count() {
+				list.SetCurrentRow(row)
+			}
+		})
+	}
+
+	layout := widgets.NewQVBoxLayout()
+	layout.AddWidget(search, 0, 0)
+	layout.AddWidget(list, 1, 0)
+	dialog.SetLayout(layout)
+	dialog.Resize(core.NewQSize2(500, 350))
+	filter("")
+	if dialog.Exec() != int(widgets.QDialog__Accepted) || chosen == nil {
+		return
+	}
+	runPaletteAction(*chosen)
+}
+
+// runPaletteAction выполняет команду палитры
+func runPaletteAction(a paletteAction) {
+	if a.run != nil {
+		a.run()
+		return
+	}
+	if a.file != "" {
+		for row := 0; row < gamesList.Count(); row++ {
+			if gamesList.Item(row).Data(int(core.Qt__UserRole)).ToString() == a.file {
+				gamesList.SetCurrentRow(row)
+				break
+			}
+		}
+	}
+	// Скрытая или выключенная кнопка значит, что действие к этой игре неприменимо
+	if a.button.IsHidden() || !a.button.IsEnabled() {
+		widgets.QMessageBox_Information(nil, "Команды", "Сейчас нельзя: "+a.label,
+			widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+		return
+	}
+	a.button.Click()
+}
+
+// updatesChecked получает сигнал, когда проверка обновлений из менеджера закончилась
+var updatesChecked = make(chan struct{}, 1)
+
+// checkingUpdates — проверка обновлений из менеджера уже идет
+var checkingUpdates bool
+
+// checkUpdatesNow проверяет обновления всех игр в фоне так же, как служба
+func checkUpdatesNow() {
+	if checkingUpdates {
+		return
+	}
+	checkingUpdates = true
+	infoLabel.SetText("Проверка обновлений…")
+	go func() {
+		checkUpdates(context.Background())
+		updatesChecked <- struct{}{}
+	}()
+}
+
+// applyUpdatesChecked обновляет список, когда проверка обновлений закончилась
+func applyUpdatesChecked() {
+	select {
+	case <-updatesChecked:
+		checkingUpdates = false
+		refreshGamesList()
+	default:
+	}
+}
+
+// selectRequestedGame выбирает игру из --select=<директория игры>: так установщик
+// открывает менеджер на игре, которую нужно обновить или исправить
+func selectRequestedGame() {
+	for _, arg := range os.Args[1:] {
+		installPath, ok := strings.CutPrefix(arg, "--select=")
+		if !ok {
+			continue
+		}
+		for row := 0; row < gamesList.Count(); row++ {
+			file := gamesList.Item(row).Data(int(core.Qt__UserRole)).ToString()
+			if info, err := common.Load(file); err == nil && filepath.Clean(info.InstallPath) == filepath.Clean(installPath) {
+				gamesList.SetCurrentRow(row)
+				return
+			}
+		}
+		log.Printf("Игра в %s не найдена в списке", installPath)
+	}
+}
+
+// daemonInterval разбирает --daemon и --daemon=<интервал>, например --daemon=2h
+func daemonInterval() (time.Duration, bool) {
+	for _, arg := range os.Args[1:] {
+		if arg == "--daemon" {
+			return updates.DefaultInterval, true
+		}
+		if value, ok := strings.CutPrefix(arg, "--daemon="); ok {
+			interval, err := time.ParseDuration(value)
+			if err != nil || interval < time.Minute {
+				log.Fatalf("Неверный интервал проверки обновлений %q", value)
+			}
+			return interval, true
+		}
+	}
+	return 0, false
+}
+
+// runDaemon работает без окна: периодически ищет обновления игр и скачивает их
+// в кэш загрузок, пока службу не остановят
+func runDaemon(interval time.Duration) {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	log.Printf("Служба обновлений запущена, проверка каждые %v", interval)
+	for {
+		checkUpdates(ctx)
+		if err := source.Cleanup(); err != nil {
+			log.Printf("Ошибка при удалении временных файлов: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			log.Printf("Служба обновлений остановлена")
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// checkUpdates проверяет обновления всех игр, установленных по манифесту.
+// Настройки перечитываются каждый раз, чтобы ограничение скорости из диалога
+// настроек действовало без перезапуска службы.
+func checkUpdates(ctx context.Context) {
+	userSettings := settings.Load()
+	settings.Apply(userSettings)
+	if userSettings.UpdateLimit > 0 {
+		source.RateLimit = int64(userSettings.UpdateLimit) << 10
+	}
+	source.DownloadCache = downloadcache.New(downloadcache.DefaultLimit)
+	if network.Offline() {
+		log.Printf("Нет доступа к интернету, проверка обновлений пропущена")
+		return
+	}
+	// Фоновая загрузка не стоит денег за трафик: дождемся безлимитного подключения
+	if metered, err := network.Metered(); err == nil && metered {
+		log.Printf("Подключение лимитное, проверка обновлений пропущена")
+		return
+	}
+
+	for _, file := range findInstallInfoFiles() {
+		info, err := common.Load(file)
+		if err != nil || info.UpdateManifest == "" {
+			continue
+		}
+		// Игра на отключенном диске обновится, когда диск вернется
+		if _, err := os.Stat(info.InstallPath); err != nil {
+			continue
+		}
+		slug := common.GameSlug(info)
+		pending, err := updates.Check(ctx, info.GameName, info.UpdateManifest, info.InstallPath, info.Version)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("%s: ошибка при проверке обновлений: %v", info.GameName, err)
+		}
+		if pending == nil {
+			if err == nil {
+				updates.Clear(slug)
+			}
+			continue
+		}
+		if previous := updates.Load(slug); previous != nil && previous.Version == pending.Version {
+			pending.Notified = previous.Notified
+		}
+		if pending.Ready && !pending.Notified {
+			if err := updates.Notify(pending); err != nil {
+				log.Printf("Не удалось показать уведомление: %v", err)
+			} else {
+				pending.Notified = true
+			}
+		}
+		if err := pending.Save(slug); err != nil {
+			log.Printf("Ошибка при сохранении обновления %s: %v", info.GameName, err)
+		}
+	}
+}
+
+// versionLabel подписывает версию из резервной копии для сообщений
+func versionLabel(version string) string {
+	if version == "" {
+		return "предыдущей версии"
+	}
+	return "версии " + version
+}
+
+// rollbackUpdate возвращает игру к версии до последнего обновления по резервной копии
+func rollbackUpdate(filePath string, info *InstallInfo) {
+	latest := backup.Latest(info.InstallPath)
+	if latest == nil {
+		return
+	}
+
+	text := fmt.Sprintf("Вернуть %s к %s?\n\nБудет восстановлено файлов: %d, удалено добавленных обновлением: %d.",
+		info.GameName, versionLabel(latest.Version), len(latest.Replaced), len(latest.Added))
+	reply := widgets.QMessageBox_Question(nil, "Откат обновления", text,
+		widgets.QMessageBox__Yes|widgets.QMessageBox__No, widgets.QMessageBox__No)
+	if reply != widgets.QMessageBox__Yes {
+		return
+	}
+
+	if err := rollbackTo(filePath, info, []*backup.Manifest{latest}); err != nil {
+		widgets.QMessageBox_Critical(nil, "Ошибка", err.Error(), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+	}
+	updateGamesList()
+}
+
+// rollbackTo откатывает обновления по очереди, от нового к старому. Версия в записи
+// меняется после каждого шага, чтобы при ошибке она совпадала с файлами на диске.
+func rollbackTo(filePath string, info *InstallInfo, manifests []*backup.Manifest) error {
+	for _, m := range manifests {
+		if err := backup.Rollback(info.InstallPath, m); err != nil {
+			return fmt.Errorf("ошибка при откате обновления: %v", err)
+		}
+		if err := updateRecord(filePath, map[string]interface{}{"version": m.Version}); err != nil {
+			return fmt.Errorf("не удалось обновить запись об установке: %v", err)
+		}
+		log.Printf("Обновление %s откачено до %s", info.GameName, versionLabel(m.Version))
+	}
+	return nil
+}
+
+// showVersionHistory показывает установленные ранее версии игры и позволяет вернуть
+// любую из тех, для которых сохранилась резервная копия
+func showVersionHistory(filePath string, info *InstallInfo) {
+	manifests, err := backup.List(info.InstallPath)
+	if err != nil {
+		log.Printf("Ошибка при чтении резервных копий: %v", err)
+	}
+
+	dialog := widgets.NewQDialog(nil, 0)
+	dialog.SetWindowTitle("История версий: " + info.GameName)
+
+	current := info.Version
+	if current == "" {
+		current = "не указана"
+	}
+	history := fmt.Sprintf("Текущая версия: %s (установлена %s)", current, info.InstallDate.Format("02.01.2006 15:04"))
+	for i := len(info.History) - 1; i >= 0; i-- {
+		entry := info.History[i]
+		version := entry.Version
+		if version == "" {
+			version = "без номера"
+		}
+		history += fmt.Sprintf("\nРанее: %s (установлена %s)", version, entry.Date.Format("02.01.2006 15:04"))
+	}
+	historyLabel := widgets.NewQLabel2(history, nil, 0)
+	historyLabel.SetWordWrap(true)
+
+	backupsLabel := widgets.NewQLabel2("Можно вернуть:", nil, 0)
+	backupsList := widgets.NewQListWidget(nil)
+	for _, m := range manifests {
+		backupsList.AddItem(fmt.Sprintf("%s — заменена %s, файлов: %d",
+			versionLabel(m.Version), m.Date.Format("02.01.2006 15:04"), len(m.Replaced)+len(m.Added)))
+	}
+	if len(manifests) == 0 {
+		backupsLabel.SetText("Резервных копий нет, вернуть прежнюю версию нельзя.")
+		backupsList.Hide()
+	}
+
+	rollbackButton := widgets.NewQPushButton2("Вернуть выбранную версию", nil)
+	rollbackButton.SetEnabled(false)
+	backupsList.ConnectCurrentRowChanged(func(row int) {
+		rollbackButton.SetEnabled(row >= 0)
+	})
+	rollbackButton.ConnectClicked(func(bool) {
+		row := backupsList.CurrentRow()
+		if row < 0 || row >= len(manifests) {
+			return
+		}
+		// Чтобы вернуть старую версию, откатываем и все более новые обновления
+		target := manifests[row]
+		question := fmt.Sprintf("Вернуть %s к %s?", info.GameName, versionLabel(target.Version))
+		if row > 0 {
+			question += fmt.Sprintf("\n\nБудут последовательно откачены обновления: %d.", row+1)
+		}
+		reply := widgets.QMessageBox_Question(nil, "Откат обновления", question,
+			widgets.QMessageBox__Yes|widgets.QMessageBox__No, widgets.QMessageBox__No)
+		if reply != widgets.QMessageBox__Yes {
+			return
+		}
+		if err := rollbackTo(filePath, info, manifests[:row+1]); err != nil {
+			widgets.QMessageBox_Critical(nil, "Ошибка", err.Error(), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+		}
+		dialog.Accept()
+	})
+
+	closeButton := widgets.NewQPushButton2("Закрыть", nil)
+	closeButton.ConnectClicked(func(bool) {
+		dialog.Reject()
+	})
+
+	buttonsLayout := widgets.NewQHBoxLayout()
+	buttonsLayout.AddWidget(rollbackButton, 0, 0)
+	buttonsLayout.AddWidget(closeButton, 0, 0)
+
+	layout := widgets.NewQVBoxLayout()
+	layout.AddWidget(historyLabel, 0, 0)
+	layout.AddWidget(backupsLabel, 0, 0)
+	layout.AddWidget(backupsList, 0, 0)
+	layout.AddLayout(buttonsLayout, 0)
+	dialog.SetLayout(layout)
+	dialog.Resize(core.NewQSize2(450, 350))
+	if dialog.Exec() == int(widgets.QDialog__Accepted) {
+		updateGamesList()
+	}
+}
+
+// verifySnapshot сверяет файлы игры с последним снимком и предлагает вернуть
+// исходное состояние, если что-то изменилось
+func verifySnapshot(filePath string, info *InstallInfo) {
+	latest, ok := info.Snapshot.Latest()
+	if !ok {
+		return
+	}
+	changes, err := info.Snapshot.Verify()
+	if err != nil {
+		widgets.QMessageBox_Critical(nil, "Ошибка", "Не удалось сверить файлы со снимком: "+err.Error(),
+			widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+		return
+	}
+
+	date := latest.Date.Format("02.01.2006 15:04")
+	if len(changes) == 0 {
+		widgets.QMessageBox_Information(nil, "Сверка со снимком",
+			fmt.Sprintf("Файлы %s совпадают со снимком от %s.", info.GameName, date),
+			widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+		return
+	}
+
+	const maxShown = 20
+	var lines []string
+	for i, c := range changes {
+		if i == maxShown {
+			lines = append(lines, fmt.Sprintf("… и еще %d", len(changes)-maxShown))
+			break
+		}
+		lines = append(lines, c.Name+" — "+c.Reason)
+	}
+
+	msgBox := widgets.NewQMessageBox(nil)
+	msgBox.SetWindowTitle("Сверка со снимком")
+	msgBox.SetIcon(widgets.QMessageBox__Warning)
+	msgBox.SetText(fmt.Sprintf("Отличий от снимка от %s (%s): %d", date, versionLabel(latest.Version), len(changes)))
+	msgBox.SetDetailedText(strings.Join(lines, "\n"))
+	msgBox.SetInformativeText("Можно вернуть игру к состоянию снимка. Все изменения после него будут потеряны.")
+	restoreButton := msgBox.AddButton2("Вернуть исходное состояние", widgets.QMessageBox__DestructiveRole)
+	msgBox.AddButton3(widgets.QMessageBox__Cancel)
+	msgBox.Exec()
+	if msgBox.ClickedButton().Pointer() != restoreButton.Pointer() {
+		return
+	}
+
+	if err := info.Snapshot.Rollback(); err != nil {
+		widgets.QMessageBox_Critical(nil, "Ошибка", "Не удалось вернуть исходное состояние: "+err.Error(),
+			widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+		return
+	}
+	// Запись в логах игры вернулась вместе со снимком, перезаписываем ее актуальной
+	if err := updateRecord(filePath, nil); err != nil {
+		log.Printf("Ошибка при обновлении записи после отката: %v", err)
+	}
+	log.Printf("%s возвращена к снимку %s", info.GameName, latest.Name)
+	updateGamesList()
+}
+
+// checkGameFiles проверяет файлы игры по хэшам, как установщик перед
+// обновлением, и предлагает скачать заново поврежденные и пропавшие
+func checkGameFiles(info *InstallInfo) {
+	dialog := widgets.NewQDialog(window, 0)
+	dialog.SetWindowTitle("Проверка файлов: " + info.GameName)
+	dialog.SetMinimumWidth(520)
+
+	view := verifyview.New(nil)
+	summary := widgets.NewQLabel2("Загрузка манифеста…", nil, 0)
+	summary.SetWordWrap(true)
+	fixBar := widgets.NewQProgressBar(nil)
+	fixBar.Hide()
+	fixButton := widgets.NewQPushButton2("", nil)
+	fixButton.Hide()
+	againButton := widgets.NewQPushButton2("Проверить снова", nil)
+	closeButton := widgets.NewQPushButton2("Закрыть", nil)
+	closeButton.ConnectClicked(func(bool) {
+		dialog.Reject()
+	})
+
+	buttonsLayout := widgets.NewQHBoxLayout()
+	buttonsLayout.AddStretch(1)
+	buttonsLayout.AddWidget(fixButton, 0, 0)
+	buttonsLayout.AddWidget(againButton, 0, 0)
+	buttonsLayout.AddWidget(closeButton, 0, 0)
+	layout := widgets.NewQVBoxLayout()
+	layout.AddWidget(summary, 0, 0)
+	layout.AddWidget(view.Widget, 0, 0)
+	layout.AddWidget(fixBar, 0, 0)
+	layout.AddLayout(buttonsLayout, 0)
+	dialog.SetLayout(layout)
+
+	// Закрытие окна прерывает проверку и загрузку
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	type outcome struct {
+		result *integrity.Result
+		fixed  bool
+		err    error
+	}
+	done := make(chan outcome, 1)
+	var fixDone, fixTotal atomic.Int64
+	var result *integrity.Result
+
+	check := func() {
+		fixButton.Hide()
+		againButton.SetEnabled(false)
+		summary.SetText("Загрузка манифеста…")
+		go func() {
+			r, err := integrity.Load(ctx, info)
+			if err == nil {
+				view.Start("Сверка: "+r.Source, r.Manifest.TotalBytes())
+				err = r.Verify(info, view.Add)
+				view.Finish()
+			}
+			done <- outcome{result: r, err: err}
+		}()
+	}
+	fixButton.ConnectClicked(func(bool) {
+		fixButton.Hide()
+		againButton.SetEnabled(false)
+		fixDone.Store(0)
+		fixBar.SetValue(0)
+		fixBar.Show()
+		summary.SetText("Загрузка поврежденных и пропавших файлов…")
+		r := result
+		go func() {
+			err := integrity.Fix(ctx, info, r, func(d, total int64) {
+				fixDone.Store(d)
+				fixTotal.Store(total)
+			})
+			done <- outcome{result: r, fixed: true, err: err}
+		}()
+	})
+	againButton.ConnectClicked(func(bool) {
+		check()
+	})
+
+	timer := core.NewQTimer(dialog)
+	timer.ConnectTimeout(func() {
+		if total := fixTotal.Load(); total > 0 && fixBar.IsVisible() {
+			fixBar.SetValue(int(fixDone.Load() * 100 / total))
+		}
+		var o outcome
+		select {
+		case o = <-done:
+		default:
+			return
+		}
+		fixBar.Hide()
+		againButton.SetEnabled(true)
+		if o.err != nil {
+			if o.fixed {
+				summary.SetText("Не удалось скачать файлы заново: " + o.err.Error())
+			} else {
+				summary.SetText("Не удалось проверить файлы: " + o.err.Error())
+			}
+			return
+		}
+		if o.fixed {
+			// Полученные файлы тоже проверяются: итог должен быть чистым
+			updateGamesList()
+			check()
+			return
+		}
+
+		result = o.result
+		damaged := result.Damaged()
+		if len(damaged) == 0 {
+			summary.SetText(fmt.Sprintf("Все файлы (%d) совпадают: %s.", result.Plan.UpToDate, result.Source))
+			return
+		}
+		const maxShown = 10
+		shown := damaged
+		if len(shown) > maxShown {
+			shown = append(shown[:maxShown:maxShown], fmt.Sprintf("… и еще %d", len(damaged)-maxShown))
+		}
+		text := fmt.Sprintf("Повреждены или отсутствуют файлов: %d из %d.\n%s",
+			len(damaged), len(damaged)+result.Plan.UpToDate, strings.Join(shown, "\n"))
+		if result.CanFix {
+			fixButton.SetText(fmt.Sprintf("Скачать заново (%.1f МБ)", float64(result.Plan.FetchBytes())/1024/1024))
+			fixButton.Show()
+		} else {
+			text += "\n\nФайлы сверялись с записью последней синхронизации: скачать их заново можно, только запустив установщик игры еще раз."
+		}
+		summary.SetText(text)
+	})
+	timer.Start(100)
+
+	check()
+	dialog.Exec()
+}
+
+// verifyFromCommandLine проверяет файлы игры без окна: uninstaller --verify
+// <директория игры|идентификатор> [--fix]. Код выхода 0 — файлы в порядке или
+// исправлены, 1 — есть поврежденные, 2 — проверить не удалось.
+func verifyFromCommandLine(target string, fix bool) int {
+	if target == "" || strings.HasPrefix(target, "--") {
+		fmt.Fprintln(os.Stderr, "Использование: uninstaller --verify <директория игры|идентификатор> [--fix]")
+		return 2
+	}
+	info := findInstalledGame(target)
+	if info == nil {
+		fmt.Fprintf(os.Stderr, "Игра %s не найдена среди установленных\n", target)
+		return 2
+	}
+	settings.Apply(settings.Load())
+	source.DownloadCache = downloadcache.New(downloadcache.DefaultLimit)
+	defer source.Cleanup()
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	r, err := integrity.Load(ctx, info)
+	if err == nil {
+		fmt.Printf("%s: сверка — %s\n", info.GameName, r.Source)
+		err = r.Verify(info, nil)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Не удалось проверить файлы: %v\n", err)
+		return 2
+	}
+	damaged := r.Damaged()
+	if len(damaged) == 0 {
+		fmt.Printf("Все файлы (%d) совпадают\n", r.Plan.UpToDate)
+		return 0
 	}
-	uninstallerDir := filepath.Dir(uninstallerPath)
-	logsDir := filepath.Join(uninstallerDir, "logs")
-
-	if _, err := os.Stat(logsDir); os.IsNotExist(err) {
-		log.Printf("Директория с логами не найдена: %s", logsDir)
-		return nil
+	fmt.Printf("Повреждены или отсутствуют файлов: %d из %d\n", len(damaged), len(damaged)+r.Plan.UpToDate)
+	for _, path := range damaged {
+		fmt.Println("  " + path)
+	}
+	if !fix {
+		return 1
+	}
+	if !r.CanFix {
+		fmt.Println("Файлы сверялись с записью последней синхронизации: скачать их заново можно, только запустив установщик игры еще раз")
+		return 1
 	}
 
-	var infoFiles []string
-	files, err := ioutil.ReadDir(logsDir)
-	if err != nil {
-		log.Printf("Ошибка при чтении директории с логами: %v", err)
-		return nil
+	fmt.Printf("Загрузка %.1f МБ…\n", float64(r.Plan.FetchBytes())/1024/1024)
+	if err := integrity.Fix(ctx, info, r, nil); err != nil {
+		fmt.Fprintf(os.Stderr, "Не удалось скачать файлы заново: %v\n", err)
+		return 2
+	}
+	if err := r.Verify(info, nil); err != nil {
+		fmt.Fprintf(os.Stderr, "Не удалось проверить файлы: %v\n", err)
+		return 2
 	}
+	if left := r.Damaged(); len(left) > 0 {
+		fmt.Printf("После загрузки все еще не совпадают файлов: %d\n", len(left))
+		return 1
+	}
+	fmt.Println("Файлы исправлены")
+	return 0
+}
 
-	for _, file := range files {
-		if !file.IsDir() && strings.HasSuffix(file.Name(), "-install.json") {
-			infoFiles = append(infoFiles, filepath.Join(logsDir, file.Name()))
+// findInstalledGame ищет установленную игру по директории, идентификатору или названию
+func findInstalledGame(target string) *InstallInfo {
+	dir := target
+	if abs, err := filepath.Abs(target); err == nil {
+		dir = abs
+	}
+	for _, file := range findInstallInfoFiles() {
+		info, err := common.Load(file)
+		if err != nil {
+			continue
+		}
+		if filepath.Clean(info.InstallPath) == dir || common.GameSlug(info) == target || strings.EqualFold(info.GameName, target) {
+			return info
 		}
 	}
-	return infoFiles
+	return nil
 }
 
-func loadInstallInfo(filePath string) (*InstallInfo, error) {
-	data, err := ioutil.ReadFile(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("ошибка при чтении файла %s: %v", filePath, err)
+// showMaintenance показывает обслуживание игры: очистку кэшей шейдеров и сброс
+// настроек, с объемом, который освободит каждое действие
+func showMaintenance(info *InstallInfo) {
+	c := maintenance.Config{}
+	if info.Maintenance != nil {
+		c = *info.Maintenance
 	}
-	var info InstallInfo
-	if err := json.Unmarshal(data, &info); err != nil {
-		return nil, fmt.Errorf("ошибка при разборе JSON: %v", err)
+
+	dialog := widgets.NewQDialog(window, 0)
+	dialog.SetWindowTitle("Обслуживание: " + info.GameName)
+	dialog.SetMinimumWidth(480)
+	grid := widgets.NewQGridLayout2()
+
+	type action struct {
+		title   string
+		note    string
+		button  string
+		confirm string
+		empty   string
+		find    func() maintenance.Task
+	}
+	resetEmpty := "файлов настроек нет"
+	if len(c.ConfigFiles) == 0 {
+		resetEmpty = "издатель не указал файлы настроек"
+	}
+	actions := []action{
+		{
+			title:  "Кэш шейдеров игры",
+			note:   "Кэши DXVK, vkd3d-proton и директории кэша из параметров запуска. Игра соберет их заново при следующем запуске.",
+			button: "Очистить",
+			empty:  "кэша нет",
+			find: func() maintenance.Task {
+				return maintenance.GameShaderCache(info.InstallPath, info.LaunchEnv, c)
+			},
+		},
+		{
+			title:   "Общий кэш шейдеров драйвера",
+			note:    "Кэш Mesa и NVIDIA один на все игры: после очистки первые запуски всех игр будут дольше.",
+			button:  "Очистить",
+			confirm: "Очистить общий кэш шейдеров? Его заново соберут все игры, не только " + info.GameName + ".",
+			empty:   "кэша нет",
+			find:    maintenance.SharedShaderCache,
+		},
+		{
+			title:   "Настройки игры",
+			note:    "Текущие файлы настроек уйдут в корзину, а вместо них вернутся настройки сразу после установки.",
+			button:  "Сбросить",
+			confirm: "Сбросить настройки " + info.GameName + "? Текущие файлы настроек будут перемещены в корзину.",
+			empty:   resetEmpty,
+			find: func() maintenance.Task {
+				return maintenance.ResetConfig(info.InstallPath, c)
+			},
+		},
+	}
+
+	var refreshers []func()
+	for row, a := range actions {
+		a := a
+		label := widgets.NewQLabel2("", nil, 0)
+		label.SetWordWrap(true)
+		button := widgets.NewQPushButton2(a.button, nil)
+		var task maintenance.Task
+		refresh := func() {
+			task = a.find()
+			size := fmt.Sprintf("освободится %.1f МБ", float64(task.Size)/(1024*1024))
+			if task.Empty() {
+				size = a.empty
+			}
+			label.SetText(fmt.Sprintf("<b>%s</b> — %s<br><small>%s</small>", a.title, size, a.note))
+			label.SetToolTip(strings.Join(task.Paths, "\n"))
+			button.SetEnabled(!task.Empty())
+		}
+		button.ConnectClicked(func(bool) {
+			if a.confirm != "" && widgets.QMessageBox_Question(dialog, a.title, a.confirm,
+				widgets.QMessageBox__Yes|widgets.QMessageBox__No, widgets.QMessageBox__No) != widgets.QMessageBox__Yes {
+				return
+			}
+			if err := task.Run(); err != nil {
+				log.Printf("%s: %s: %v", info.GameName, a.title, err)
+				widgets.QMessageBox_Critical(dialog, "Ошибка", a.title+": "+err.Error(),
+					widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+			} else {
+				log.Printf("%s: %s — освобождено %.1f МБ", info.GameName, a.title, float64(task.Size)/(1024*1024))
+			}
+			for _, r := range refreshers {
+				r()
+			}
+		})
+		refreshers = append(refreshers, refresh)
+		grid.AddWidget2(label, row, 0, 0)
+		grid.AddWidget2(button, row, 1, 0)
+		refresh()
 	}
-	return &info, nil
+
+	closeButton := widgets.NewQPushButton2("Закрыть", nil)
+	closeButton.ConnectClicked(func(bool) {
+		dialog.Accept()
+	})
+	buttonsLayout := widgets.NewQHBoxLayout()
+	buttonsLayout.AddStretch(1)
+	buttonsLayout.AddWidget(closeButton, 0, 0)
+	layout := widgets.NewQVBoxLayout()
+	layout.AddLayout(grid, 0)
+	layout.AddLayout(buttonsLayout, 0)
+	dialog.SetLayout(layout)
+	dialog.Exec()
 }
 
-func uninstallGame(info *InstallInfo) error {
-	progressBar.SetRange(0, 4)
-	progressBar.SetValue(0)
-	progressBar.Show()
+// resolveDuplicates предлагает выбрать, какую из копий игры удалить.
+// Удаление идет через обычную кнопку, чтобы сработали все проверки безопасности.
+func resolveDuplicates(filePath string, info *InstallInfo) {
+	files := append([]string{filePath}, duplicatesByFile[filePath]...)
 
-	if info.MenuFile != "" {
-		if _, err := os.Stat(info.MenuFile); err == nil {
-			if err := os.Remove(info.MenuFile); err != nil {
-				log.Printf("Ошибка при удалении ярлыка из меню: %v", err)
-			}
+	msgBox := widgets.NewQMessageBox(nil)
+	msgBox.SetWindowTitle("Повторная установка")
+	msgBox.SetIcon(widgets.QMessageBox__Question)
+	msgBox.SetText(fmt.Sprintf("Игра %s установлена %d раз(а). Какую копию удалить?", info.GameName, len(files)))
+
+	buttons := make(map[uintptr]string)
+	for _, file := range files {
+		copyInfo, err := common.Load(file)
+		if err != nil {
+			continue
 		}
+		label := fmt.Sprintf("%s (%.2f ГБ)", copyInfo.InstallPath, float64(dirSize(copyInfo.InstallPath))/(1024*1024*1024))
+		button := msgBox.AddButton2(label, widgets.QMessageBox__ActionRole)
+		buttons[uintptr(button.Pointer())] = file
 	}
-	progressBar.SetValue(1)
+	msgBox.AddButton3(widgets.QMessageBox__Cancel)
+	msgBox.Exec()
 
-	if info.DesktopFile != "" {
-		if _, err := os.Stat(info.DesktopFile); err == nil {
-			if err := os.Remove(info.DesktopFile); err != nil {
-				log.Printf("Ошибка при удалении ярлыка с рабочего стола: %v", err)
-			}
+	target, ok := buttons[uintptr(msgBox.ClickedButton().Pointer())]
+	if !ok {
+		return
+	}
+	for row := 0; row < gamesList.Count(); row++ {
+		if gamesList.Item(row).Data(int(core.Qt__UserRole)).ToString() == target {
+			gamesList.SetCurrentRow(row)
+			uninstallButton.Click()
+			return
 		}
 	}
-	progressBar.SetValue(2)
+}
 
-	if info.InstallPath != "" {
-		if _, err := os.Stat(info.InstallPath); err == nil {
-			if err := os.RemoveAll(info.InstallPath); err != nil {
-				return fmt.Errorf("ошибка при удалении директории с игрой: %v", err)
-			}
+// nearestExisting возвращает ближайшую существующую директорию на пути к path
+func nearestExisting(path string) string {
+	for {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			return path
 		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return ""
+		}
+		path = parent
+	}
+}
+
+// watchInstalls следит за реестром, логами рядом с менеджером и директориями игр.
+// Родительская директория игры сообщает об удалении или переименовании самой игры.
+func watchInstalls(installPaths []string) {
+	if dirs := watcher.Directories(); len(dirs) > 0 {
+		watcher.RemovePaths(dirs)
+	}
+	paths := []string{nearestExisting(common.RegistryDir())}
+	if baseDir != "" {
+		paths = append(paths, nearestExisting(filepath.Join(baseDir, "logs")))
+	}
+	for _, path := range installPaths {
+		paths = append(paths, nearestExisting(path), nearestExisting(filepath.Dir(path)))
 	}
-	progressBar.SetValue(3)
 
-	exec.Command("gtk-update-icon-cache", "-f", "-t", filepath.Join(os.Getenv("HOME"), ".local", "share", "icons")).Run()
-	exec.Command("update-desktop-database", filepath.Join(os.Getenv("HOME"), ".local", "share", "applications")).Run()
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		if path == "" || path == "/" || seen[path] {
+			continue
+		}
+		seen[path] = true
+		watcher.AddPath(path)
+	}
+}
 
-	progressBar.SetValue(4)
+// scheduleRefresh обновляет список после паузы: установка или удаление меняют много
+// файлов подряд, а список достаточно перестроить один раз
+func scheduleRefresh() {
+	refreshTimer.Start(refreshDelay)
+}
 
-	infoFilePath := filepath.Join(filepath.Dir(os.Args[0]), "logs", strings.ToLower(info.GameName)+"-install.json")
-	if _, err := os.Stat(infoFilePath); err == nil {
-		if err := os.Remove(infoFilePath); err != nil {
-			log.Printf("Ошибка при удалении файла с информацией об установке: %v", err)
+// refreshGamesList перестраивает список, сохраняя выбранную игру. Пока идет удаление
+// или перенос, обновление откладывается.
+func refreshGamesList() {
+	if progressBar.IsVisible() {
+		scheduleRefresh()
+		return
+	}
+	selected := selectedFile
+	updateGamesList()
+	for row := 0; row < gamesList.Count(); row++ {
+		if gamesList.Item(row).Data(int(core.Qt__UserRole)).ToString() == selected {
+			gamesList.SetCurrentRow(row)
+			break
 		}
 	}
+}
 
-	return nil
+// checkMounts обновляет список, когда диск подключили или отключили
+func checkMounts() {
+	if diskEvents != nil {
+		select {
+		case <-diskEvents:
+		default:
+			return
+		}
+	}
+	points := strings.Join(mounts.Points(), "\n")
+	if points != knownMounts {
+		knownMounts = points
+		scheduleRefresh()
+	}
 }
 
 func updateGamesList() {
 	gamesList.Clear()
+	showGameDetails(nil, "")
+	sizeCache = make(map[string]int64)
+	problemsByFile = make(map[string][]installProblem)
+	duplicatesByFile = make(map[string][]string)
+	itemsByIcon = make(map[string][]*widgets.QListWidgetItem)
 
 	infoFiles := findInstallInfoFiles()
+	var installPaths []string
+	defer func() { watchInstalls(installPaths) }()
 	if len(infoFiles) == 0 {
 		infoLabel.SetText("Установленные игры не найдены")
 		uninstallButton.SetEnabled(false)
 		return
 	}
 
+	// Группируем записи по названию игры, чтобы найти повторные установки
+	filesByGame := make(map[string][]string)
+	for _, file := range infoFiles {
+		if info, err := common.Load(file); err == nil {
+			filesByGame[info.GameName] = append(filesByGame[info.GameName], file)
+		}
+	}
+	for _, files := range filesByGame {
+		if len(files) < 2 {
+			continue
+		}
+		for _, file := range files {
+			for _, other := range files {
+				if other != file {
+					duplicatesByFile[file] = append(duplicatesByFile[file], other)
+				}
+			}
+		}
+	}
+
 	for _, file := range infoFiles {
-		info, err := loadInstallInfo(file)
+		info, err := common.Load(file)
 		if err != nil {
 			log.Printf("Ошибка при загрузке информации об установке из %s: %v", file, err)
 			continue
 		}
+		installPaths = append(installPaths, info.InstallPath)
 		installDate := info.InstallDate.Format("02.01.2006 15:04:05")
 		item := widgets.NewQListWidgetItem2(fmt.Sprintf("%s (установлена: %s)", info.GameName, installDate), gamesList, 0)
 		item.SetData(int(core.Qt__UserRole), core.NewQVariant15(file))
+
+		// Иконку загружаем в фоне; значок предупреждения у неисправных установок важнее
+		problems := checkInstallHealth(info)
+		if info.IconPath != "" && len(problems) == 0 {
+			itemsByIcon[info.IconPath] = append(itemsByIcon[info.IconPath], item)
+			images.Load(info.IconPath, iconSize, iconSize, imageResults)
+		}
+
+		// Игра на отключенном диске не сломана: показываем, какой диск подключить
+		if isOffline(problems) {
+			problemsByFile[file] = problems
+			point := mounts.Expected(info.InstallPath, info.MountPoint)
+			item.SetText(fmt.Sprintf("%s (не в сети: подключите диск %s)", info.GameName, point))
+			item.SetIcon(window.Style().StandardIcon(widgets.QStyle__SP_DriveHDIcon, nil, nil))
+			item.SetForeground(gui.NewQBrush3(gui.NewQColor3(140, 140, 140, 255), core.Qt__SolidPattern))
+			item.SetToolTip("Игра станет доступна, когда диск появится в " + point)
+			continue
+		}
+
+		// Помечаем неисправные установки значком предупреждения
+		if len(problems) > 0 {
+			problemsByFile[file] = problems
+			var descriptions []string
+			for _, p := range problems {
+				descriptions = append(descriptions, p.String())
+			}
+			item.SetIcon(window.Style().StandardIcon(widgets.QStyle__SP_MessageBoxWarning, nil, nil))
+			item.SetToolTip(strings.Join(descriptions, "\n"))
+		}
+
+		// Повторные установки выделяем цветом и показываем путь в названии
+		if len(duplicatesByFile[file]) > 0 {
+			item.SetText(fmt.Sprintf("%s — %s (установлена: %s)", info.GameName, info.InstallPath, installDate))
+			item.SetBackground(gui.NewQBrush3(gui.NewQColor3(90, 70, 20, 255), core.Qt__SolidPattern))
+		}
 	}
 
 	if gamesList.Count() > 0 {
+		infoLabel.SetText("Выберите игру для удаления:")
+		// Кнопку удаления включает панель подробностей, если игра доступна
 		gamesList.SetCurrentRow(0)
-		uninstallButton.SetEnabled(true)
 	} else {
 		infoLabel.SetText("Установленные игры не найдены")
 		uninstallButton.SetEnabled(false)
 	}
 }
 
+// apiBackend выполняет команды REST API
+type apiBackend struct{}
+
+func (apiBackend) Games() []api.Game {
+	reply := make(chan []api.Game)
+	apiRequests <- func() {
+		var games []api.Game
+		for _, file := range findInstallInfoFiles() {
+			info, err := common.Load(file)
+			if err != nil {
+				continue
+			}
+			games = append(games, api.Game{
+				Slug:        common.GameSlug(info),
+				Name:        info.GameName,
+				Version:     info.Version,
+				InstallPath: info.InstallPath,
+				InstallDate: info.InstallDate,
+				Offline:     mounts.Offline(info.InstallPath, info.MountPoint),
+				InstallID:   info.InstallID,
+			})
+		}
+		reply <- games
+	}
+	return <-reply
+}
+
+func (apiBackend) Uninstall(slug string, toTrash bool) error {
+	reply := make(chan error)
+	apiRequests <- func() { uninstallFromAPI(slug, toTrash, reply) }
+	return <-reply
+}
+
+// uninstallFromAPI удаляет игру по команде API. Подтвердить удаление некому, поэтому
+// игры, которые в окне потребовали бы подтверждения, через API не удаляются.
+// Ответ отправляется в reply до начала удаления, ход удаления — событиями.
+func uninstallFromAPI(slug string, toTrash bool, reply chan<- error) {
+	if progressBar.IsVisible() {
+		reply <- errors.New("менеджер уже выполняет другую операцию")
+		return
+	}
+	for _, file := range findInstallInfoFiles() {
+		info, err := common.Load(file)
+		if err != nil || common.GameSlug(info) != slug {
+			continue
+		}
+		if err := verifyInstallInfo(file); err != nil {
+			reply <- fmt.Errorf("удаление отменено: %v", err)
+			return
+		}
+		if fatal, suspicious := checkInstallPathSafety(info); len(fatal)+len(suspicious) > 0 {
+			reply <- fmt.Errorf("путь %q требует проверки в окне менеджера: %s",
+				info.InstallPath, strings.Join(append(fatal, suspicious...), "; "))
+			return
+		}
+		reply <- nil
+
+		log.Printf("Удаление %s по команде API", info.GameName)
+		apiAction, apiGame = "uninstall", slug
+		apiServer.Publish(api.Event{Type: "started", Action: apiAction, Game: slug})
+		uninstallButton.SetEnabled(false)
+		err = uninstallGame(info, toTrash)
+		uninstallButton.SetEnabled(true)
+		if err != nil {
+			apiServer.Publish(api.Event{Type: "failed", Action: apiAction, Game: slug, Error: err.Error()})
+		} else {
+			apiServer.Publish(api.Event{Type: "finished", Action: apiAction, Game: slug})
+		}
+		apiAction, apiGame = "", ""
+		updateGamesList()
+		return
+	}
+	reply <- api.ErrNotFound
+}
+
+// Install запускает установщик, с файлом ответов — без участия пользователя.
+// Ход установки виден в окне установщика, в API приходят начало и результат.
+func (apiBackend) Install(installer, preseedPath string) error {
+	if fi, err := os.Stat(installer); err != nil || fi.IsDir() || fi.Mode()&0111 == 0 {
+		return fmt.Errorf("установщик %s не найден или не является исполняемым файлом", installer)
+	}
+	spec := launcher.Spec{Path: installer}
+	if preseedPath != "" {
+		spec.Args = []string{"--preseed", preseedPath}
+	}
+	proc, err := launcher.Start(spec)
+	if err != nil {
+		return fmt.Errorf("не удалось запустить установщик: %v", err)
+	}
+
+	game := filepath.Base(filepath.Dir(installer))
+	log.Printf("Запущен установщик %s по команде API", installer)
+	apiServer.Publish(api.Event{Type: "started", Action: "install", Game: game})
+	go func() {
+		<-proc.Done
+		if proc.Err != nil {
+			event := api.Event{Type: "failed", Action: "install", Game: game, Error: proc.Err.Error(), ErrorCode: string(failure.Other)}
+			// Класс ошибки установщик сообщает кодом выхода
+			var exitErr *exec.ExitError
+			if errors.As(proc.Err, &exitErr) && exitErr.ExitCode() > 0 {
+				event.ErrorCode = string(failure.FromExitCode(exitErr.ExitCode()))
+			}
+			apiServer.Publish(event)
+		} else {
+			apiServer.Publish(api.Event{Type: "finished", Action: "install", Game: game})
+		}
+	}()
+	return nil
+}
+
+// applyAPIRequests выполняет накопившиеся команды API
+func applyAPIRequests() {
+	for {
+		select {
+		case request := <-apiRequests:
+			request()
+		default:
+			return
+		}
+	}
+}
+
+// startAPI запускает REST API, если менеджер запущен с --api или --api=<адрес>
+func startAPI() {
+	addr := ""
+	for _, arg := range os.Args[1:] {
+		if arg == "--api" {
+			addr = api.DefaultAddr
+		} else if value, ok := strings.CutPrefix(arg, "--api="); ok {
+			addr = value
+		}
+	}
+	if addr == "" {
+		return
+	}
+
+	var err error
+	if apiServer, err = api.Start(addr, apiBackend{}); err != nil {
+		log.Printf("REST API не запущен: %v", err)
+		return
+	}
+	log.Printf("REST API слушает %s, токен в %s", addr, api.TokenPath())
+
+	progressBar.ConnectValueChanged(func(value int) {
+		if apiAction != "" {
+			apiServer.Publish(api.Event{Type: "progress", Action: apiAction, Game: apiGame,
+				Value: value, Max: progressBar.Maximum()})
+		}
+	})
+	apiTimer := core.NewQTimer(nil)
+	apiTimer.ConnectTimeout(applyAPIRequests)
+	apiTimer.Start(100)
+}
+
 func main() {
-	app := widgets.NewQApplication(len(os.Args), os.Args)
+	if uninstallerPath, err := common.Executable(); err != nil {
+		log.Printf("Ошибка при получении пути к деинсталлятору: %v", err)
+	} else {
+		baseDir = filepath.Dir(uninstallerPath)
+	}
+
+	// Служба фоновых обновлений работает без окна
+	for _, arg := range os.Args[1:] {
+		if arg == "--install-daemon" {
+			if err := installDaemon(); err != nil {
+				log.Fatalf("Не удалось включить службу обновлений: %v", err)
+			}
+			return
+		}
+		if arg == "--install-tray" {
+			if err := installTray(); err != nil {
+				log.Fatalf("Не удалось добавить значок обновлений в автозапуск: %v", err)
+			}
+			return
+		}
+	}
+	for i, arg := range os.Args[1:] {
+		if arg == "--verify" || strings.HasPrefix(arg, "--verify=") {
+			target := strings.TrimPrefix(arg, "--verify=")
+			if arg == "--verify" {
+				target = ""
+				if i+2 < len(os.Args) {
+					target = os.Args[i+2]
+				}
+			}
+			fix := false
+			for _, a := range os.Args[1:] {
+				fix = fix || a == "--fix"
+			}
+			os.Exit(verifyFromCommandLine(target, fix))
+		}
+	}
+	if interval, ok := daemonInterval(); ok {
+		runDaemon(interval)
+		return
+	}
 
-	darkPalette := gui.NewQPalette()
-	darkColor := gui.NewQColor3(53, 53, 53, 255)
-	whiteColor := gui.NewQColor3(255, 255, 255, 255)
-	darkGreyColor := gui.NewQColor3(25, 25, 25, 255)
+	report.Capture()
+	app := widgets.NewQApplication(len(os.Args), os.Args)
 
-	darkPalette.SetColor2(gui.QPalette__Window, darkColor)
-	darkPalette.SetColor2(gui.QPalette__WindowText, whiteColor)
-	darkPalette.SetColor2(gui.QPalette__Base, darkGreyColor)
-	darkPalette.SetColor2(gui.QPalette__AlternateBase, darkGreyColor)
-	darkPalette.SetColor2(gui.QPalette__ToolTipBase, darkColor)
-	darkPalette.SetColor2(gui.QPalette__ToolTipText, whiteColor)
-	darkPalette.SetColor2(gui.QPalette__Text, whiteColor)
-	darkPalette.SetColor2(gui.QPalette__Button, darkColor)
-	darkPalette.SetColor2(gui.QPalette__ButtonText, whiteColor)
-	darkPalette.SetColor2(gui.QPalette__BrightText, whiteColor)
+	// Общие с установщиком настройки: тема и загрузки
+	userSettings := settings.Load()
+	settings.ApplyTheme(app, userSettings.Theme)
+	settings.Apply(userSettings)
 
-	app.SetPalette(darkPalette, "")
+	for _, arg := range os.Args[1:] {
+		if arg == "--tray" {
+			runTray(app)
+			return
+		}
+	}
 
 	window = widgets.NewQMainWindow(nil, 0)
 	window.SetWindowTitle("Деинсталлятор игр")
-	window.Resize(core.NewQSize2(500, 400))
+	window.Resize(core.NewQSize2(800, 450))
 
 	infoLabel = widgets.NewQLabel2("Выберите игру для удаления:", nil, 0)
 	gamesList = widgets.NewQListWidget(nil)
 	gamesList.ConnectItemClicked(func(item *widgets.QListWidgetItem) {
-		uninstallButton.SetEnabled(true)
+		uninstallButton.SetEnabled(!isOffline(problemsByFile[selectedFile]))
+	})
+	gamesList.ConnectCurrentRowChanged(func(row int) {
+		item := gamesList.Item(row)
+		if row < 0 || item == nil {
+			showGameDetails(nil, "")
+			return
+		}
+		filePath := item.Data(int(core.Qt__UserRole)).ToString()
+		info, err := common.Load(filePath)
+		if err != nil {
+			log.Printf("Ошибка при загрузке информации об установке: %v", err)
+			showGameDetails(nil, "")
+			return
+		}
+		showGameDetails(info, filePath)
 	})
 
 	progressBar = widgets.NewQProgressBar(nil)
 	progressBar.SetTextVisible(true)
 	progressBar.SetAlignment(core.Qt__AlignCenter)
 	progressBar.Hide()
+	progressVoice = accessibility.Track(progressBar)
 
 	uninstallButton = widgets.NewQPushButton2("Удалить выбранную игру", nil)
 	uninstallButton.SetEnabled(false)
@@ -197,38 +3564,258 @@ func main() {
 		}
 
 		infoFilePath := currentItem.Data(int(core.Qt__UserRole)).ToString()
-		info, err := loadInstallInfo(infoFilePath)
+		info, err := common.Load(infoFilePath)
 		if err != nil {
 			widgets.QMessageBox_Critical(nil, "Ошибка", "Не удалось загрузить информацию об установке: "+err.Error(), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
 			return
 		}
 
-		confirmed := widgets.QMessageBox_Question(nil, "Подтверждение",
-			fmt.Sprintf("Вы действительно хотите удалить игру %s?", info.GameName),
+		// Не удаляем директорию, если информация об установке была изменена вручную
+		if err := verifyInstallInfo(infoFilePath); err != nil {
+			if err != signature.ErrUnsigned {
+				log.Printf("Проверка подписи %s не пройдена: %v", infoFilePath, err)
+				widgets.QMessageBox_Critical(nil, "Ошибка", "Удаление отменено: "+err.Error(), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+				return
+			}
+
+			unsigned := widgets.QMessageBox_Question(nil, "Подтверждение",
+				fmt.Sprintf("Информация об установке не подписана, поэтому невозможно проверить, что директория %s создана установщиком. Продолжить?", info.InstallPath),
+				widgets.QMessageBox__Yes|widgets.QMessageBox__No, widgets.QMessageBox__No)
+			if unsigned != widgets.QMessageBox__Yes {
+				return
+			}
+		}
+
+		// Проверяем путь установки перед удалением
+		fatal, suspicious := checkInstallPathSafety(info)
+		if len(fatal) > 0 {
+			widgets.QMessageBox_Critical(nil, "Ошибка",
+				fmt.Sprintf("Удаление отменено, путь %q небезопасен:\n- %s", info.InstallPath, strings.Join(fatal, "\n- ")),
+				widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+			return
+		}
+		if len(suspicious) > 0 {
+			if !confirmSuspiciousUninstall(info, suspicious) {
+				return
+			}
+		}
+
+		toTrash := trashCheckBox.IsChecked()
+		question := fmt.Sprintf("Вы действительно хотите удалить игру %s?", info.GameName)
+		if toTrash {
+			question = fmt.Sprintf("Переместить игру %s в корзину?", info.GameName)
+		}
+
+		confirmed := widgets.QMessageBox_Question(nil, "Подтверждение", question,
 			widgets.QMessageBox__Yes|widgets.QMessageBox__No, widgets.QMessageBox__No)
 
 		if confirmed != widgets.QMessageBox__Yes {
 			return
 		}
 
-		if err := uninstallGame(info); err != nil {
+		// Во время удаления обрабатываются события, поэтому не даем запустить его повторно
+		uninstallButton.SetEnabled(false)
+		err = uninstallGame(info, toTrash)
+		uninstallButton.SetEnabled(true)
+		if err != nil {
 			widgets.QMessageBox_Critical(nil, "Ошибка", "Ошибка при удалении игры: "+err.Error(), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
 		} else {
 			updateGamesList()
+			askUninstallSurvey(info)
+		}
+	})
+
+	currentPathLabel = widgets.NewQLabel2("", nil, 0)
+	currentPathLabel.SetWordWrap(true)
+	currentPathLabel.Hide()
+
+	cancelButton = widgets.NewQPushButton2("Отменить удаление", nil)
+	cancelButton.ConnectClicked(func(bool) {
+		cancelRequested = true
+		cancelButton.SetEnabled(false)
+	})
+	cancelButton.Hide()
+
+	trashCheckBox = widgets.NewQCheckBox2("Переместить в корзину (можно отменить до закрытия окна)", nil)
+	trashCheckBox.SetChecked(true)
+
+	undoButton = widgets.NewQPushButton2("Отменить последнее удаление", nil)
+	undoButton.SetEnabled(false)
+	undoButton.ConnectClicked(func(bool) {
+		if err := undoLastUninstall(); err != nil {
+			widgets.QMessageBox_Warning(nil, "Предупреждение", err.Error(), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+		}
+		updateGamesList()
+	})
+
+	exportButton := widgets.NewQPushButton2("Экспорт списка…", nil)
+	exportButton.ConnectClicked(func(bool) {
+		filePath := widgets.QFileDialog_GetSaveFileName(nil, "Экспорт списка игр", "games.json", "JSON (*.json)", "", 0)
+		if filePath == "" {
+			return
+		}
+		count, err := exportRegistry(filePath)
+		if err != nil {
+			widgets.QMessageBox_Critical(nil, "Ошибка", err.Error(), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+			return
+		}
+		widgets.QMessageBox_Information(nil, "Экспорт", fmt.Sprintf("Экспортировано игр: %d", count), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+	})
+
+	importButton := widgets.NewQPushButton2("Импорт списка…", nil)
+	importButton.ConnectClicked(func(bool) {
+		filePath := widgets.QFileDialog_GetOpenFileName(nil, "Импорт списка игр", "", "JSON (*.json)", "", 0)
+		if filePath == "" {
+			return
+		}
+		result, err := importRegistry(filePath)
+		if err != nil {
+			widgets.QMessageBox_Critical(nil, "Ошибка", err.Error(), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+			return
 		}
+		report := result.Report()
+		if len(result.Reinstall) > 0 {
+			report += confirmReinstall(result.Reinstall)
+		}
+		widgets.QMessageBox_Information(nil, "Импорт", report, widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+		updateGamesList()
+	})
+
+	// Кэш скачанных установщиком архивов
+	downloads := downloadcache.New(downloadcache.DefaultLimit)
+	clearCacheButton := widgets.NewQPushButton2("", nil)
+	updateCacheButton := func() {
+		size := downloads.Size()
+		clearCacheButton.SetText(fmt.Sprintf("Очистить кэш загрузок (%.1f МБ)", float64(size)/1024/1024))
+		clearCacheButton.SetEnabled(size > 0)
+	}
+	updateCacheButton()
+	clearCacheButton.ConnectClicked(func(bool) {
+		reply := widgets.QMessageBox_Question(nil, "Очистка кэша",
+			"Удалить скачанные архивы игр? При следующей установке их придется скачать заново.",
+			widgets.QMessageBox__Yes|widgets.QMessageBox__No, widgets.QMessageBox__No)
+		if reply != widgets.QMessageBox__Yes {
+			return
+		}
+		if err := downloads.Clear(); err != nil {
+			widgets.QMessageBox_Warning(nil, "Предупреждение", "Не удалось очистить кэш: "+err.Error(), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+		}
+		updateCacheButton()
+	})
+
+	addExistingButton := widgets.NewQPushButton2("Добавить установленную игру…", nil)
+	addExistingButton.ConnectClicked(func(bool) {
+		report, err := addExistingInstall()
+		if err != nil {
+			widgets.QMessageBox_Critical(nil, "Ошибка", err.Error(), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+			return
+		}
+		if report == "" {
+			return
+		}
+		widgets.QMessageBox_Information(nil, "Добавление игр", report, widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+		updateGamesList()
+	})
+
+	settingsButton := widgets.NewQPushButton2("Настройки…", nil)
+	settingsButton.ConnectClicked(func(bool) {
+		if updated, ok := settings.ShowDialog(app); ok {
+			settings.Apply(updated)
+			downloads = downloadcache.New(downloadcache.DefaultLimit)
+			updateCacheButton()
+		}
+	})
+
+	managerActions = []paletteAction{
+		{label: "Проверить обновления", run: checkUpdatesNow},
+		{label: "Добавить установленную игру", button: addExistingButton},
+		{label: "Экспорт списка", button: exportButton},
+		{label: "Импорт списка", button: importButton},
+		{label: "Очистить кэш загрузок", button: clearCacheButton},
+		{label: "Отменить последнее удаление", button: undoButton},
+		{label: "Настройки", button: settingsButton},
+	}
+	paletteShortcut := widgets.NewQShortcut(window)
+	paletteShortcut.SetKey(gui.NewQKeySequence2("Ctrl+K", gui.QKeySequence__PortableText))
+	paletteShortcut.ConnectActivated(showCommandPalette)
+	// Путь выбранной игры копируется и открывается без мыши
+	pathlabel.Shortcuts(window, func() string {
+		if selectedInfo == nil {
+			return ""
+		}
+		return selectedInfo.InstallPath
 	})
+	gamesList.SetToolTip("Ctrl+K — все команды менеджера с поиском")
+
+	registryLayout := widgets.NewQHBoxLayout()
+	registryLayout.AddWidget(addExistingButton, 0, 0)
+	registryLayout.AddWidget(exportButton, 0, 0)
+	registryLayout.AddWidget(importButton, 0, 0)
+	registryLayout.AddWidget(clearCacheButton, 0, 0)
+	registryLayout.AddWidget(settingsButton, 0, 0)
+
+	gamesLayout := widgets.NewQHBoxLayout()
+	gamesLayout.AddWidget(gamesList, 1, 0)
+	gamesLayout.AddWidget(newDetailsPane(), 1, 0)
 
 	layout := widgets.NewQVBoxLayout()
 	layout.AddWidget(infoLabel, 0, 0)
-	layout.AddWidget(gamesList, 0, 0)
+	layout.AddLayout(gamesLayout, 0)
 	layout.AddWidget(progressBar, 0, 0)
+	layout.AddWidget(currentPathLabel, 0, 0)
+	layout.AddWidget(cancelButton, 0, 0)
+	layout.AddWidget(trashCheckBox, 0, 0)
 	layout.AddWidget(uninstallButton, 0, 0)
+	layout.AddWidget(undoButton, 0, 0)
+	layout.AddLayout(registryLayout, 0)
 
 	widget := widgets.NewQWidget(nil, 0)
 	widget.SetLayout(layout)
 	window.SetCentralWidget(widget)
 
+	imageTimer := core.NewQTimer(nil)
+	imageTimer.ConnectTimeout(applyImageResults)
+	imageTimer.Start(100)
+
+	sizeTimer := core.NewQTimer(nil)
+	sizeTimer.ConnectTimeout(applySizeResults)
+	sizeTimer.Start(200)
+
+	wmClassTimer := core.NewQTimer(nil)
+	wmClassTimer.ConnectTimeout(applyWMClassResults)
+	wmClassTimer.Start(1000)
+
+	updatesTimer := core.NewQTimer(nil)
+	updatesTimer.ConnectTimeout(applyUpdatesChecked)
+	updatesTimer.Start(500)
+
+	// Список обновляется сам, когда установщик завершил работу, директорию игры
+	// удалили вручную или подключили диск
+	refreshTimer = core.NewQTimer(nil)
+	refreshTimer.SetSingleShot(true)
+	refreshTimer.ConnectTimeout(refreshGamesList)
+	watcher = core.NewQFileSystemWatcher(nil)
+	watcher.ConnectDirectoryChanged(func(string) { scheduleRefresh() })
+	knownMounts = strings.Join(mounts.Points(), "\n")
+	mountInterval := 2000
+	if _, err := exec.LookPath("udisksctl"); err == nil {
+		// udisks2 сообщает о подключении дисков сразу, таймер только забирает события
+		diskEvents = make(chan struct{}, 1)
+		mountInterval = 250
+		go func() {
+			if err := mounts.Monitor(context.Background(), diskEvents); err != nil {
+				log.Printf("Слежение за дисками через udisks2 остановлено: %v", err)
+			}
+		}()
+	}
+	mountTimer := core.NewQTimer(nil)
+	mountTimer.ConnectTimeout(checkMounts)
+	mountTimer.Start(mountInterval)
+
+	startAPI()
+
 	updateGamesList()
+	selectRequestedGame()
 	window.Show()
 	app.Exec()
 }